@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/audit"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// auditCommand is the command path of the running gw invocation, recorded
+// on each audit entry.
+var auditCommand string
+
+// lastSafetyBlock is the most recent blocked decision in this invocation,
+// kept so history can name the rule even when a command wraps the error.
+var lastSafetyBlock *safety.Evaluation
+
+// lastSafetyTarget is the target of the most recent safety decision in
+// this invocation, allowed or blocked, recorded with the command in
+// history.
+var lastSafetyTarget *safety.Target
+
+// installAuditObserver routes every safety decision into the audit log.
+func installAuditObserver(cmdPath string) {
+	auditCommand = cmdPath
+	safety.SetObserver(recordSafetyDecision)
+}
+
+// recordSafetyDecision appends a safety decision to the audit log.
+// Failures are reported on stderr but never block the command.
+func recordSafetyDecision(e safety.Evaluation) {
+	if e.Err != nil {
+		lastSafetyBlock = &e
+	}
+	if e.Target != (safety.Target{}) {
+		t := e.Target
+		lastSafetyTarget = &t
+	}
+	log, err := audit.Open()
+	if err == nil {
+		_, err = log.Append(auditEntryFromEvaluation(e))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not write audit log: %v\n", err)
+	}
+}
+
+// auditEntryFromEvaluation converts a safety decision to an audit entry.
+func auditEntryFromEvaluation(e safety.Evaluation) audit.Entry {
+	entry := audit.Entry{
+		Command:     auditCommand,
+		Operation:   e.Operation,
+		Tier:        e.Tier.String(),
+		PolicyRule:  e.PolicyRule,
+		Write:       e.WriteFlag,
+		Force:       e.ForceFlag,
+		AgentMode:   e.AgentMode,
+		Interactive: e.Interactive,
+		Target: audit.Target{
+			Branch:    e.Target.Branch,
+			Database:  e.Target.Database,
+			Namespace: e.Target.Namespace,
+			Worker:    e.Target.Worker,
+		},
+		Outcome: audit.OutcomeAllowed,
+	}
+	if e.BaseTier != e.Tier {
+		entry.BaseTier = e.BaseTier.String()
+	}
+	if e.Err != nil {
+		entry.Outcome = audit.OutcomeBlocked
+		entry.Reason = e.Err.Error()
+	}
+	return entry
+}
+
+// auditCmd is the parent command for the safety audit log.
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Review the tamper-evident log of safety decisions",
+	Long: `Every safety check gw performs — allowed or blocked — is appended to
+~/.grove/gw_audit.jsonl. Entries are hash-chained, and the newest entry is
+also recorded in gw_audit.jsonl.head, so gw audit verify detects edits,
+deletions, reordering and entries cut from the end of the log.
+
+The hashes are not keyed: someone who can rewrite both files can forge a
+consistent history. The log catches accidents and casual edits, not a
+determined attacker with access to your home directory.`,
+}
+
+// --- audit list ---
+
+var auditListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show recent safety decisions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		limit, _ := cmd.Flags().GetInt("limit")
+		blockedOnly, _ := cmd.Flags().GetBool("blocked")
+		agentOnly, _ := cmd.Flags().GetBool("agent-only")
+		operation, _ := cmd.Flags().GetString("operation")
+
+		log, err := audit.Open()
+		if err != nil {
+			return err
+		}
+		all, err := log.Entries()
+		if err != nil {
+			return err
+		}
+
+		// Newest first, filtered, then limited
+		var entries []audit.Entry
+		for i := len(all) - 1; i >= 0; i-- {
+			e := all[i]
+			if blockedOnly && e.Outcome != audit.OutcomeBlocked {
+				continue
+			}
+			if agentOnly && !e.AgentMode {
+				continue
+			}
+			if operation != "" && e.Operation != operation {
+				continue
+			}
+			entries = append(entries, e)
+			if limit > 0 && len(entries) >= limit {
+				break
+			}
+		}
+
+		if cfg.JSONMode {
+			return printJSON(map[string]any{
+				"entries": entries,
+				"count":   len(entries),
+			})
+		}
+
+		if len(entries) == 0 {
+			ui.Muted("No audit entries match.")
+			return nil
+		}
+
+		headers := []string{"", "#", "Operation", "Tier", "Flags", "Target", "Time"}
+		var rows [][]string
+		for _, e := range entries {
+			icon := "✓"
+			if e.Outcome == audit.OutcomeBlocked {
+				icon = "✗"
+			}
+			tier := e.Tier
+			if e.BaseTier != "" {
+				tier = e.BaseTier + "→" + e.Tier
+			}
+			ts := e.Timestamp
+			if len(ts) > 16 {
+				ts = ts[:16]
+			}
+			rows = append(rows, []string{
+				icon,
+				fmt.Sprintf("%d", e.Seq),
+				e.Operation,
+				tier,
+				auditFlags(e),
+				auditTarget(e.Target),
+				ts,
+			})
+		}
+		fmt.Print(ui.RenderTable(fmt.Sprintf("Safety Audit (%d entries)", len(entries)), headers, rows))
+		return nil
+	},
+}
+
+// --- audit verify ---
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the audit log hash chain for tampering",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+
+		log, err := audit.Open()
+		if err != nil {
+			return err
+		}
+		res, err := log.Verify()
+		if err != nil {
+			return err
+		}
+
+		if cfg.JSONMode {
+			if err := printJSON(map[string]any{
+				"path":    log.Path(),
+				"ok":      res.OK,
+				"entries": res.Entries,
+				"bad_seq": res.BadSeq,
+				"problem": res.Problem,
+			}); err != nil {
+				return err
+			}
+		} else if res.OK {
+			ui.Success(fmt.Sprintf("Audit log intact: %d entries verified (%s)", res.Entries, log.Path()))
+		} else {
+			ui.Error(fmt.Sprintf("Audit log broken at entry #%d: %s", res.BadSeq, res.Problem))
+		}
+
+		if !res.OK {
+			return errors.New("audit log verification failed")
+		}
+		return nil
+	},
+}
+
+// auditFlags renders the flags and mode of an entry compactly.
+func auditFlags(e audit.Entry) string {
+	var parts []string
+	if e.Write {
+		parts = append(parts, "write")
+	}
+	if e.Force {
+		parts = append(parts, "force")
+	}
+	if e.AgentMode {
+		parts = append(parts, "agent")
+	}
+	return strings.Join(parts, ",")
+}
+
+// auditTarget renders a decision target as "branch=main" style pairs.
+func auditTarget(t audit.Target) string {
+	var parts []string
+	for _, kv := range [][2]string{
+		{"branch", t.Branch},
+		{"db", t.Database},
+		{"namespace", t.Namespace},
+		{"worker", t.Worker},
+	} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+"="+kv[1])
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	// audit list
+	auditListCmd.Flags().IntP("limit", "n", 50, "Maximum number of entries to show")
+	auditListCmd.Flags().Bool("blocked", false, "Only show blocked attempts")
+	auditListCmd.Flags().Bool("agent-only", false, "Only show decisions made in agent mode")
+	auditListCmd.Flags().String("operation", "", "Only show this operation")
+	auditCmd.AddCommand(auditListCmd)
+
+	// audit verify
+	auditCmd.AddCommand(auditVerifyCmd)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
 )
 
@@ -91,12 +92,11 @@ var backupCreateCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a database backup",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := requireCFSafety("backup_create"); err != nil {
-			return err
-		}
-
 		cfg := config.Get()
 		dbAlias, _ := cmd.Flags().GetString("db")
+		if err := requireCFSafetyTarget("backup_create", safety.Target{Database: dbAlias}); err != nil {
+			return err
+		}
 		dbName, err := resolveDatabase(dbAlias)
 		if err != nil {
 			return err
@@ -194,20 +194,23 @@ var backupRestoreCmd = &cobra.Command{
 	Short: "Restore a database from backup",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := requireCFSafety("backup_restore"); err != nil {
+		cfg := config.Get()
+		dbAlias, _ := cmd.Flags().GetString("db")
+		if err := requireCFSafetyTarget("backup_restore", safety.Target{Database: dbAlias}); err != nil {
 			return err
 		}
-
-		cfg := config.Get()
 		backupID := args[0]
 		if err := validateCFName(backupID, "backup ID"); err != nil {
 			return err
 		}
-		dbAlias, _ := cmd.Flags().GetString("db")
 		dbName, err := resolveDatabase(dbAlias)
 		if err != nil {
 			return err
 		}
+		unverified, _ := cmd.Flags().GetBool("unverified")
+		if err := requireVerifiedBackup(dbName, backupID, unverified); err != nil {
+			return err
+		}
 
 		if !cfg.JSONMode {
 			ui.Warning(fmt.Sprintf("Restoring %s from backup %s", dbName, backupID))
@@ -239,13 +242,16 @@ var backupRestoreCmd = &cobra.Command{
 var backupHelpCategories = []ui.HelpCategory{
 	{Title: "Read (Always Safe)", Icon: "📖", Style: ui.SafeReadStyle, Commands: []ui.HelpCommand{
 		{Name: "list", Desc: "List database backups"},
+		{Name: "verify", Desc: "Check a backup's integrity and row counts"},
+		{Name: "prune --plan", Desc: "Show what the retention policy would delete"},
 	}},
 	{Title: "Write (--write)", Icon: "✏️", Style: ui.SafeWriteStyle, Commands: []ui.HelpCommand{
 		{Name: "create", Desc: "Create a database backup"},
 		{Name: "download", Desc: "Download a database backup"},
 	}},
 	{Title: "Danger (--write --force)", Icon: "⚠️", Style: ui.DangerStyle, Commands: []ui.HelpCommand{
-		{Name: "restore", Desc: "Restore a database from backup"},
+		{Name: "restore", Desc: "Restore a verified backup (--unverified skips the check)"},
+		{Name: "prune", Desc: "Delete backups the retention policy does not keep"},
 	}},
 }
 
@@ -272,5 +278,16 @@ func init() {
 
 	// backup restore
 	backupRestoreCmd.Flags().StringP("db", "d", "lattice", "Database alias or name")
+	backupRestoreCmd.Flags().Bool("unverified", false, "Restore a backup that has not passed gw backup verify (not in agent mode)")
 	backupCmd.AddCommand(backupRestoreCmd)
+
+	// backup verify
+	backupVerifyCmd.Flags().StringP("db", "d", "lattice", "Database alias or name")
+	backupVerifyCmd.Flags().Bool("no-compare", false, "Skip comparing row counts with the live database")
+	backupCmd.AddCommand(backupVerifyCmd)
+
+	// backup prune
+	backupPruneCmd.Flags().StringP("db", "d", "lattice", "Database alias or name")
+	backupPruneCmd.Flags().Bool("plan", false, "Show what would be kept and deleted without deleting")
+	backupCmd.AddCommand(backupPruneCmd)
 }
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/backups"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// d1Backups lists a database's backups. A backup whose creation time
+// cannot be read is an error rather than a guess, since prune decides by
+// age.
+func d1Backups(dbName string) ([]backups.Backup, error) {
+	output, err := exec.WranglerOutput("d1", "backup", "list", dbName, "--json")
+	if err != nil {
+		return nil, fmt.Errorf("wrangler error: %w", err)
+	}
+	var raw []struct {
+		ID        string `json:"id"`
+		State     string `json:"state"`
+		CreatedAt string `json:"created_at"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &raw); err != nil {
+		return nil, fmt.Errorf("cannot read wrangler's backup list: %w", err)
+	}
+	list := make([]backups.Backup, 0, len(raw))
+	for _, b := range raw {
+		var at time.Time
+		var err error
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+			if at, err = time.Parse(layout, b.CreatedAt); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("backup %s has an unreadable creation time %q", b.ID, b.CreatedAt)
+		}
+		list = append(list, backups.Backup{ID: b.ID, State: b.State, CreatedAt: at})
+	}
+	return list, nil
+}
+
+// retentionPolicy is the [backup.retention] section of gw.toml.
+func retentionPolicy(cfg *config.Config) backups.Policy {
+	r := cfg.Backup.Retention
+	return backups.Policy{Last: r.KeepLast, Daily: r.Daily, Weekly: r.Weekly, Monthly: r.Monthly}
+}
+
+// deleteD1Backup deletes one backup through the Cloudflare API; wrangler
+// has no command for it.
+func deleteD1Backup(accountID, databaseID, backupID, token string) error {
+	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/d1/database/%s/backup/%s",
+		accountID, databaseID, backupID)
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// --- backup prune ---
+
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete backups the retention policy does not keep",
+	Long: `Apply the retention policy in gw.toml to a database's backups.
+
+  [backup.retention]
+  daily = 7     # newest backup of each of the last 7 days
+  weekly = 4    # newest backup of each of the last 4 ISO weeks
+  monthly = 0
+  keep_last = 0 # the newest N, whatever their age
+
+Periods are in UTC. Whatever the policy, the newest backup, unfinished
+backups and the newest backup that passed gw backup verify are kept.
+
+--plan shows what would be kept and deleted and changes nothing.
+Without it the other backups are deleted, which needs --write --force.
+wrangler cannot delete backups, so prune calls the Cloudflare API: set
+CF_API_TOKEN (with D1 edit permission) and CF_ACCOUNT_ID. The database
+must have its id configured in gw.toml.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		dbAlias, _ := cmd.Flags().GetString("db")
+		planOnly, _ := cmd.Flags().GetBool("plan")
+
+		op := "backup_prune"
+		if planOnly {
+			op = "backup_prune_plan"
+		}
+		policy := retentionPolicy(cfg)
+		if policy.Empty() {
+			return fmt.Errorf("no retention policy: set [backup.retention] in gw.toml, e.g. daily = 7 and weekly = 4")
+		}
+		dbName, err := resolveDatabase(dbAlias)
+		if err != nil {
+			return err
+		}
+
+		list, err := d1Backups(dbName)
+		if err != nil {
+			return err
+		}
+		ledger, err := backups.LoadLedger(backups.LedgerPath())
+		if err != nil {
+			return err
+		}
+		decisions := backups.Plan(list, policy, func(id string) bool {
+			v, ok := ledger.Get(dbName, id)
+			return ok && v.Verified()
+		})
+		var drop []string
+		for _, d := range decisions {
+			if !d.Keep {
+				drop = append(drop, d.ID)
+			}
+		}
+
+		if !cfg.JSONMode {
+			printBackupPrunePlan(dbName, policy, decisions, len(drop))
+		}
+		if planOnly || len(drop) == 0 {
+			if cfg.JSONMode {
+				return printJSON(map[string]interface{}{
+					"database":  dbName,
+					"policy":    policy.String(),
+					"backups":   decisionsOrEmpty(decisions),
+					"to_delete": len(drop),
+					"applied":   false,
+				})
+			}
+			if len(drop) > 0 {
+				ui.Hint("Apply with: gw backup prune --db " + dbAlias + " --write --force")
+			}
+			return nil
+		}
+
+		if err := requireCFSafety(op); err != nil {
+			return err
+		}
+		dbCfg, ok := cfg.Databases[dbAlias]
+		if !ok || dbCfg.ID == "" {
+			return fmt.Errorf("database alias '%s' has no id in gw.toml; prune needs it for the Cloudflare API", dbAlias)
+		}
+		token, accountID := os.Getenv("CF_API_TOKEN"), os.Getenv("CF_ACCOUNT_ID")
+		if (token == "" || accountID == "") && !cfg.DryRun {
+			return fmt.Errorf("CF_API_TOKEN and CF_ACCOUNT_ID environment variables are required to delete backups")
+		}
+
+		var deleted []string
+		failed := map[string]string{}
+		for _, id := range drop {
+			if cfg.DryRun {
+				if !cfg.JSONMode {
+					fmt.Println(ui.WarningStyle.Render("⊘ dry-run") + " DELETE d1 backup " + id)
+				}
+				continue
+			}
+			if err := deleteD1Backup(accountID, dbCfg.ID, id, token); err != nil {
+				failed[id] = err.Error()
+				if !cfg.JSONMode {
+					fmt.Println("  " + ui.ErrorStyle.Render("✗") + " " + id + ": " + err.Error())
+				}
+				continue
+			}
+			deleted = append(deleted, id)
+			ledger.Forget(dbName, id)
+			if !cfg.JSONMode {
+				fmt.Println("  " + ui.ErrorStyle.Render("✗") + " deleted " + id)
+			}
+		}
+		if len(deleted) > 0 {
+			if err := ledger.Save(); err != nil {
+				ui.Warning(fmt.Sprintf("Could not update verification ledger: %v", err))
+			}
+		}
+
+		if cfg.JSONMode {
+			if deleted == nil {
+				deleted = []string{}
+			}
+			if err := printJSON(map[string]interface{}{
+				"database":  dbName,
+				"policy":    policy.String(),
+				"backups":   decisionsOrEmpty(decisions),
+				"to_delete": len(drop),
+				"applied":   !cfg.DryRun,
+				"deleted":   deleted,
+				"failed":    failed,
+			}); err != nil {
+				return err
+			}
+		} else if !cfg.DryRun {
+			ui.Success(fmt.Sprintf("Pruned %d of %d backup(s) of %s", len(deleted), len(drop), dbName))
+		}
+		if len(failed) > 0 {
+			return exitStatus(1)
+		}
+		return nil
+	},
+}
+
+func decisionsOrEmpty(d []backups.Decision) []backups.Decision {
+	if d == nil {
+		return []backups.Decision{}
+	}
+	return d
+}
+
+// printBackupPrunePlan renders which backups a policy keeps.
+func printBackupPrunePlan(dbName string, policy backups.Policy, decisions []backups.Decision, drop int) {
+	fmt.Print(ui.RenderInfoPanel("Prune "+dbName, [][2]string{
+		{"Policy", policy.String()},
+		{"Backups", fmt.Sprintf("%d", len(decisions))},
+		{"Keep", fmt.Sprintf("%d", len(decisions)-drop)},
+		{"Delete", fmt.Sprintf("%d", drop)},
+	}))
+	if len(decisions) == 0 {
+		ui.Muted("No backups found")
+		return
+	}
+
+	headers := []string{"ID", "Created", "State", "Action", "Why"}
+	rows := make([][]string, 0, len(decisions))
+	for _, d := range decisions {
+		action := ui.SuccessStyle.Render("keep")
+		if !d.Keep {
+			action = ui.DangerStyle.Render("delete")
+		}
+		rows = append(rows, []string{d.ID, d.CreatedAt.UTC().Format("2006-01-02 15:04"), d.State, action, strings.Join(d.Reasons, ", ")})
+	}
+	fmt.Print(ui.RenderTable("Backups of "+dbName, headers, rows))
+}
@@ -0,0 +1,324 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/backups"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlitefile"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// maxBackupProblems caps the integrity problems one verify collects.
+const maxBackupProblems = 100
+
+// backupTableCount is one table's rows in a backup and in the live
+// database. A nil count means the table is not there.
+type backupTableCount struct {
+	Table  string `json:"table"`
+	Backup *int64 `json:"backup"`
+	Live   *int64 `json:"live"`
+}
+
+// isInternalD1Table reports whether a table belongs to SQLite or D1 rather
+// than the application.
+func isInternalD1Table(name string) bool {
+	return strings.HasPrefix(name, "sqlite_") || strings.HasPrefix(name, "_cf_")
+}
+
+// checkBackupFile runs PRAGMA integrity_check on a downloaded backup
+// through the embedded SQLite driver and counts its rows. A file that is
+// not an SQLite database at all is reported as a problem.
+func checkBackupFile(path string) (*sqlitefile.CheckResult, error) {
+	r, err := sqlitefile.Open(path)
+	if err != nil {
+		if strings.Contains(err.Error(), "not an SQLite database") {
+			return &sqlitefile.CheckResult{Problems: []string{"the download is not an SQLite database"}}, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+	return r.Check(maxBackupProblems)
+}
+
+// liveD1Counts counts the rows of every application table in the remote
+// database. Tables are counted in batches of one UNION ALL query each.
+func liveD1Counts(dbName string) (map[string]int64, error) {
+	rows, err := d1Execute(dbName, true,
+		"SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE '_cf_%' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, row := range rows {
+		if name, ok := row["name"].(string); ok && !isInternalD1Table(name) {
+			names = append(names, name)
+		}
+	}
+
+	counts := make(map[string]int64, len(names))
+	const batch = 50
+	for start := 0; start < len(names); start += batch {
+		end := min(start+batch, len(names))
+		var parts []string
+		for _, name := range names[start:end] {
+			parts = append(parts, fmt.Sprintf("SELECT %s AS t, COUNT(*) AS n FROM %s",
+				sqlitefile.Literal(name), sqlitefile.QuoteIdent(name)))
+		}
+		rows, err := d1Execute(dbName, true, strings.Join(parts, " UNION ALL "))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			name, _ := row["t"].(string)
+			n, _ := row["n"].(float64)
+			counts[name] = int64(n)
+		}
+	}
+	return counts, nil
+}
+
+// compareBackupCounts pairs backup and live row counts by table name.
+func compareBackupCounts(backup []sqlitefile.TableRows, live map[string]int64) []backupTableCount {
+	var out []backupTableCount
+	seen := map[string]bool{}
+	for _, t := range backup {
+		if isInternalD1Table(t.Name) {
+			continue
+		}
+		seen[t.Name] = true
+		c := backupTableCount{Table: t.Name, Backup: &t.Rows}
+		if n, ok := live[t.Name]; ok {
+			c.Live = &n
+		}
+		out = append(out, c)
+	}
+	for name, n := range live {
+		if !seen[name] {
+			out = append(out, backupTableCount{Table: name, Live: &n})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Table < out[j].Table })
+	return out
+}
+
+// --- backup verify ---
+
+var backupVerifyCmd = &cobra.Command{
+	Use:   "verify <backup_id>",
+	Short: "Check that a backup is a sound database",
+	Long: `Download a backup to a temporary directory and check it.
+
+SQLite's own PRAGMA integrity_check runs on the file through gw's
+embedded SQLite, so no sqlite3 binary is needed: damaged pages, malformed
+records and indexes that disagree with their tables are all problems.
+Row counts per table are then compared with the live database;
+differences are expected for an older backup and do not fail the check.
+
+The outcome is recorded in ~/.grove/backup_verifications.json.
+gw backup restore refuses backups that have not been verified unless
+--unverified is given. Exits 1 when the backup has problems.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		dbAlias, _ := cmd.Flags().GetString("db")
+		if err := requireCFSafetyTarget("backup_verify", safety.Target{Database: dbAlias}); err != nil {
+			return err
+		}
+		backupID := args[0]
+		if err := validateCFName(backupID, "backup ID"); err != nil {
+			return err
+		}
+		noCompare, _ := cmd.Flags().GetBool("no-compare")
+		dbName, err := resolveDatabase(dbAlias)
+		if err != nil {
+			return err
+		}
+
+		dir, err := os.MkdirTemp("", "gw-backup-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "backup.sqlite3")
+
+		if !cfg.JSONMode {
+			ui.Info(fmt.Sprintf("Downloading backup %s of %s...", backupID, dbName))
+		}
+		result, err := exec.Wrangler("d1", "backup", "download", dbName, backupID, "--output", file)
+		if err != nil {
+			return fmt.Errorf("wrangler error: %w", err)
+		}
+		if !result.OK() {
+			return fmt.Errorf("wrangler error: %s", result.Stderr)
+		}
+		if cfg.DryRun {
+			// The download was only planned, so there is nothing to check.
+			return nil
+		}
+
+		check, err := checkBackupFile(file)
+		if err != nil {
+			return fmt.Errorf("cannot check backup: %w", err)
+		}
+		ok := len(check.Problems) == 0
+
+		var counts []backupTableCount
+		var compareErr error
+		if ok && !noCompare {
+			live, err := liveD1Counts(dbName)
+			if err != nil {
+				compareErr = err
+			} else {
+				counts = compareBackupCounts(check.Tables, live)
+			}
+		}
+
+		v := backups.Verification{
+			VerifiedAt:     time.Now().UTC().Format(time.RFC3339),
+			OK:             ok,
+			Problems:       len(check.Problems),
+			IntegrityCheck: true,
+		}
+		for _, t := range check.Tables {
+			if !isInternalD1Table(t.Name) {
+				v.Tables++
+				v.Rows += t.Rows
+			}
+		}
+		ledger, err := backups.LoadLedger(backups.LedgerPath())
+		if err == nil {
+			ledger.Record(dbName, backupID, v)
+			err = ledger.Save()
+		}
+		if err != nil {
+			return fmt.Errorf("cannot record verification: %w", err)
+		}
+
+		if cfg.JSONMode {
+			out := map[string]interface{}{
+				"database":  dbName,
+				"backup_id": backupID,
+				"ok":        ok,
+				"verified":  v.Verified(),
+				"problems":  check.Problems,
+				"tables":    v.Tables,
+				"rows":      v.Rows,
+			}
+			if counts != nil {
+				out["counts"] = counts
+			}
+			if compareErr != nil {
+				out["compare_error"] = compareErr.Error()
+			}
+			if err := printJSON(out); err != nil {
+				return err
+			}
+		} else {
+			printBackupVerification(dbName, backupID, v, check.Problems, counts, compareErr)
+		}
+
+		if !ok {
+			return exitStatus(1)
+		}
+		return nil
+	},
+}
+
+// printBackupVerification renders a verify result for humans.
+func printBackupVerification(dbName, backupID string, v backups.Verification, problems []string, counts []backupTableCount, compareErr error) {
+	integrity := ui.SuccessStyle.Render("ok")
+	if !v.OK {
+		integrity = ui.ErrorStyle.Render(fmt.Sprintf("%d problem(s)", len(problems)))
+	}
+	fmt.Print(ui.RenderInfoPanel("Backup "+backupID, [][2]string{
+		{"Database", dbName},
+		{"Integrity", integrity},
+		{"Tables", fmt.Sprintf("%d", v.Tables)},
+		{"Rows", fmt.Sprintf("%d", v.Rows)},
+	}))
+
+	if !v.OK {
+		for _, p := range problems {
+			fmt.Println("  " + ui.ErrorStyle.Render("✗") + " " + p)
+		}
+		ui.Warning("This backup is not safe to restore")
+		return
+	}
+
+	if compareErr != nil {
+		ui.Warning(fmt.Sprintf("Could not count live rows: %v", compareErr))
+	} else if len(counts) > 0 {
+		headers := []string{"Table", "Backup", "Live", "Δ"}
+		var rows [][]string
+		missing := 0
+		for _, c := range counts {
+			backup, live, delta := "—", "—", ""
+			if c.Backup != nil {
+				backup = fmt.Sprintf("%d", *c.Backup)
+			}
+			if c.Live != nil {
+				live = fmt.Sprintf("%d", *c.Live)
+			}
+			switch {
+			case c.Backup == nil:
+				missing++
+				delta = ui.WarningStyle.Render("not in backup")
+			case c.Live == nil:
+				delta = ui.WarningStyle.Render("not live")
+			case *c.Live != *c.Backup:
+				delta = fmt.Sprintf("%+d", *c.Live-*c.Backup)
+			}
+			rows = append(rows, []string{c.Table, backup, live, delta})
+		}
+		fmt.Print(ui.RenderTable("Row counts", headers, rows))
+		if missing > 0 {
+			ui.Warning(fmt.Sprintf("%d live table(s) are not in the backup; they may be newer than it", missing))
+		}
+	}
+	ui.Success(fmt.Sprintf("Backup %s verified", backupID))
+}
+
+// requireVerifiedBackup refuses to restore a backup that has not been
+// verified, with PRAGMA integrity_check, unless unverified is set, in
+// which case it only warns. --force cannot stand in for unverified: the
+// dangerous tier of restore always needs --force. Agents cannot skip the
+// check at all. Ledger entries written before gw embedded SQLite may record
+// only a structural check; those are verified again.
+func requireVerifiedBackup(dbName, backupID string, unverified bool) error {
+	cfg := config.Get()
+	ledger, err := backups.LoadLedger(backups.LedgerPath())
+	if err != nil {
+		return err
+	}
+	var problem string
+	switch v, ok := ledger.Get(dbName, backupID); {
+	case !ok:
+		problem = fmt.Sprintf("backup %s has never been verified", backupID)
+	case !v.OK:
+		problem = fmt.Sprintf("backup %s failed verification on %s with %d problem(s)", backupID, v.VerifiedAt, v.Problems)
+	case !v.IntegrityCheck:
+		problem = fmt.Sprintf("backup %s was checked by an older gw without PRAGMA integrity_check", backupID)
+	default:
+		return nil
+	}
+	if !unverified {
+		return fmt.Errorf("%s; run gw backup verify %s first, or pass --unverified to restore anyway", problem, backupID)
+	}
+	if cfg.AgentMode {
+		return fmt.Errorf("%s; --unverified is not available in agent mode", problem)
+	}
+	if !cfg.JSONMode {
+		ui.Warning(problem + " — restoring anyway (--unverified)")
+	}
+	return nil
+}
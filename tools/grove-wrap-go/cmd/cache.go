@@ -187,26 +187,111 @@ var cacheStatsCmd = &cobra.Command{
 
 // --- cache purge ---
 
+// cacheKeyTags reads the tags a cache entry was stored with, from a "tags"
+// list or comma-separated string in its metadata.
+func cacheKeyTags(metadata json.RawMessage) []string {
+	var meta struct {
+		Tags json.RawMessage `json:"tags"`
+	}
+	if len(metadata) == 0 || json.Unmarshal(metadata, &meta) != nil || len(meta.Tags) == 0 {
+		return nil
+	}
+	var tags []string
+	if json.Unmarshal(meta.Tags, &tags) != nil {
+		var joined string
+		if json.Unmarshal(meta.Tags, &joined) != nil {
+			return nil
+		}
+		tags = strings.Split(joined, ",")
+	}
+	var out []string
+	for _, t := range tags {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// cacheMatchTags keeps the keys carrying any of tags; no tags keeps all.
+func cacheMatchTags(keys []kvListing, tags []string) []kvListing {
+	if len(tags) == 0 {
+		return keys
+	}
+	var out []kvListing
+	for _, k := range keys {
+		matched := false
+		for _, have := range cacheKeyTags(k.Metadata) {
+			for _, want := range tags {
+				matched = matched || have == want
+			}
+		}
+		if matched {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// printCachePreview lists keys selected for a purge, at most limit of them.
+func printCachePreview(keys []kvListing, limit int) {
+	var rows [][]string
+	for i, k := range keys {
+		if i == limit {
+			break
+		}
+		tags := strings.Join(cacheKeyTags(k.Metadata), ", ")
+		if tags == "" {
+			tags = "—"
+		}
+		rows = append(rows, []string{k.Name, tags, kvExpirationLabel(k.Expiration)})
+	}
+	fmt.Print(ui.RenderTable(fmt.Sprintf("Matching Cache Keys (%d)", len(keys)), []string{"Key", "Tags", "Expires"}, rows))
+	if len(keys) > limit {
+		ui.Muted(fmt.Sprintf("  ... and %d more", len(keys)-limit))
+	}
+}
+
 var cachePurgeCmd = &cobra.Command{
 	Use:   "purge [KEY]",
 	Short: "Purge cache keys or CDN cache",
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Purge cache keys from the KV cache namespace, or the Cloudflare CDN cache
+with --cdn.
+
+Keys are selected by name, by --tenant or --prefix, by --tag (matched
+against the "tags" in each key's metadata; repeat for any of several), or
+with --all. --preview lists the selection without deleting anything; a
+purge in a terminal shows a sample and asks first unless --yes is given.
+
+With --cdn, --tag and --prefix become cache-tag and URL-prefix purges, and
+--preview prints the request instead of sending it.`,
+	Example: `  gw cache purge --tenant autumn --preview
+  gw cache purge --tag posts --tag feeds --write
+  gw cache purge --cdn --tag autumn-posts --write`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := requireCFSafety("cache_purge"); err != nil {
-			return err
-		}
-
 		cfg := config.Get()
 		tenant, _ := cmd.Flags().GetString("tenant")
 		prefix, _ := cmd.Flags().GetString("prefix")
+		tags, _ := cmd.Flags().GetStringArray("tag")
 		purgeAll, _ := cmd.Flags().GetBool("all")
+		preview, _ := cmd.Flags().GetBool("preview")
+		yes, _ := cmd.Flags().GetBool("yes")
 		cdnFlag, _ := cmd.Flags().GetBool("cdn")
 		cdnURL, _ := cmd.Flags().GetString("cdn-url")
 		nsAlias, _ := cmd.Flags().GetString("namespace")
 
+		op := "cache_purge"
+		if preview {
+			op = "cache_purge_preview"
+		}
+		if err := requireCFSafety(op); err != nil {
+			return err
+		}
+
 		// CDN purge path
 		if cdnFlag {
-			return cachePurgeCDN(cfg, cdnURL)
+			return cachePurgeCDN(cfg, cachePurgeCDNBody(cdnURL, prefix, tags), preview)
 		}
 
 		// KV purge path
@@ -216,7 +301,7 @@ var cachePurgeCmd = &cobra.Command{
 		}
 
 		// Single key deletion
-		if len(args) == 1 {
+		if len(args) == 1 && !preview {
 			key := args[0]
 			if err := validateCFKey(key); err != nil {
 				return err
@@ -237,8 +322,14 @@ var cachePurgeCmd = &cobra.Command{
 			return nil
 		}
 
-		// Bulk deletion by prefix or tenant
+		// Bulk deletion by prefix, tenant or tag
 		effectivePrefix := prefix
+		if len(args) == 1 {
+			if err := validateCFKey(args[0]); err != nil {
+				return err
+			}
+			effectivePrefix = args[0]
+		}
 		if tenant != "" {
 			if err := validateCFName(tenant, "tenant"); err != nil {
 				return err
@@ -251,24 +342,49 @@ var cachePurgeCmd = &cobra.Command{
 			}
 		}
 
-		if effectivePrefix == "" && !purgeAll {
-			return fmt.Errorf("specify a KEY, --tenant, --prefix, or --all to purge")
-		}
-
-		// List keys matching the prefix
-		wranglerArgs := []string{"kv:key", "list", "--namespace-id", nsID}
-		if effectivePrefix != "" {
-			wranglerArgs = append(wranglerArgs, "--prefix", effectivePrefix)
+		if effectivePrefix == "" && len(tags) == 0 && !purgeAll {
+			return fmt.Errorf("specify a KEY, --tenant, --prefix, --tag, or --all to purge")
 		}
 
-		keysOutput, err := exec.WranglerOutput(wranglerArgs...)
+		keys, err := kvList(nsID, effectivePrefix)
 		if err != nil {
-			return fmt.Errorf("wrangler error listing keys: %w", err)
+			return err
+		}
+		if len(args) == 1 {
+			// A named key is an exact match, not a prefix
+			var exact []kvListing
+			for _, k := range keys {
+				if k.Name == args[0] {
+					exact = append(exact, k)
+				}
+			}
+			keys = exact
 		}
+		keys = cacheMatchTags(keys, tags)
 
-		var keys []map[string]interface{}
-		if err := json.Unmarshal([]byte(keysOutput), &keys); err != nil {
-			return fmt.Errorf("failed to parse key list: %w", err)
+		if preview {
+			if cfg.JSONMode {
+				type previewKey struct {
+					Name       string   `json:"name"`
+					Tags       []string `json:"tags"`
+					Expiration int64    `json:"expiration,omitempty"`
+				}
+				matched := []previewKey{}
+				for _, k := range keys {
+					matched = append(matched, previewKey{k.Name, stringsOrEmpty(cacheKeyTags(k.Metadata)), k.Expiration})
+				}
+				return printJSON(map[string]interface{}{
+					"namespace": nsAlias, "prefix": effectivePrefix, "tags": stringsOrEmpty(tags),
+					"preview": true, "keys": matched, "count": len(matched),
+				})
+			}
+			if len(keys) == 0 {
+				ui.Muted("No cache keys matched")
+				return nil
+			}
+			printCachePreview(keys, 50)
+			ui.Hint("Nothing was purged. Run again without --preview to purge these keys.")
+			return nil
 		}
 
 		if len(keys) == 0 {
@@ -281,18 +397,25 @@ var cachePurgeCmd = &cobra.Command{
 			return nil
 		}
 
+		if !cfg.JSONMode && cfg.IsInteractive() && !yes && !cfg.DryRun {
+			printCachePreview(keys, 10)
+			if !ui.Confirm(fmt.Sprintf("Purge %d cache keys from %s?", len(keys), nsAlias)) {
+				ui.Muted("Cancelled")
+				return nil
+			}
+		}
+
 		// Delete each key
 		purged := 0
 		var failed []string
 		for _, k := range keys {
-			name := fmt.Sprintf("%v", k["name"])
-			if err := validateCFKey(name); err != nil {
-				failed = append(failed, name)
+			if err := validateCFKey(k.Name); err != nil {
+				failed = append(failed, k.Name)
 				continue
 			}
-			result, err := exec.Wrangler("kv:key", "delete", "--namespace-id", nsID, name)
+			result, err := exec.Wrangler("kv:key", "delete", "--namespace-id", nsID, k.Name)
 			if err != nil || !result.OK() {
-				failed = append(failed, name)
+				failed = append(failed, k.Name)
 				continue
 			}
 			purged++
@@ -303,6 +426,7 @@ var cachePurgeCmd = &cobra.Command{
 				"purged": purged,
 				"failed": failed,
 				"prefix": effectivePrefix,
+				"tags":   tags,
 			})
 			fmt.Println(string(data))
 		} else {
@@ -316,8 +440,39 @@ var cachePurgeCmd = &cobra.Command{
 	},
 }
 
-// cachePurgeCDN purges the Cloudflare CDN cache via the API.
-func cachePurgeCDN(cfg *config.Config, url string) error {
+// cachePurgeCDNBody builds a purge_cache request: one URL, URL prefixes,
+// cache tags, or everything when none is given.
+func cachePurgeCDNBody(url, prefix string, tags []string) map[string]interface{} {
+	body := map[string]interface{}{}
+	if url != "" {
+		body["files"] = []string{url}
+	}
+	if prefix != "" {
+		// Prefixes are matched without the scheme
+		body["prefixes"] = []string{strings.TrimPrefix(strings.TrimPrefix(prefix, "https://"), "http://")}
+	}
+	if len(tags) > 0 {
+		body["tags"] = tags
+	}
+	if len(body) == 0 {
+		body["purge_everything"] = true
+	}
+	return body
+}
+
+// cachePurgeCDN purges the Cloudflare CDN cache via the API. With preview
+// it prints the request body instead of sending it.
+func cachePurgeCDN(cfg *config.Config, body map[string]interface{}, preview bool) error {
+	if preview {
+		if cfg.JSONMode {
+			return printJSON(map[string]interface{}{"cdn_purge": false, "preview": true, "request": body})
+		}
+		data, _ := json.MarshalIndent(body, "", "  ")
+		ui.PrintHeader("CDN purge request (not sent)")
+		fmt.Println(string(data))
+		return nil
+	}
+
 	apiToken := os.Getenv("CF_API_TOKEN")
 	zoneID := os.Getenv("CF_ZONE_ID")
 
@@ -330,13 +485,6 @@ func cachePurgeCDN(cfg *config.Config, url string) error {
 
 	apiURL := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/purge_cache", zoneID)
 
-	var body map[string]interface{}
-	if url != "" {
-		body = map[string]interface{}{"files": []string{url}}
-	} else {
-		body = map[string]interface{}{"purge_everything": true}
-	}
-
 	bodyBytes, err := json.Marshal(body)
 	if err != nil {
 		return fmt.Errorf("failed to encode request: %w", err)
@@ -368,21 +516,32 @@ func cachePurgeCDN(cfg *config.Config, url string) error {
 		}
 		data, _ := json.Marshal(map[string]interface{}{
 			"cdn_purge": true,
-			"url":       url,
+			"request":   body,
 			"response":  result,
 		})
 		fmt.Println(string(data))
 	} else {
-		if url != "" {
-			ui.Success(fmt.Sprintf("CDN cache purged for: %s", url))
-		} else {
+		if body["purge_everything"] == true {
 			ui.Success("CDN cache purged (everything)")
+		} else {
+			ui.Success(fmt.Sprintf("CDN cache purged for: %s", cdnPurgeLabel(body)))
 		}
 	}
 
 	return nil
 }
 
+// cdnPurgeLabel names what a purge request covers.
+func cdnPurgeLabel(body map[string]interface{}) string {
+	var parts []string
+	for _, field := range []string{"files", "prefixes", "tags"} {
+		if list, ok := body[field].([]string); ok {
+			parts = append(parts, field+" "+strings.Join(list, ", "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
 func init() {
 	rootCmd.AddCommand(cacheCmd)
 
@@ -398,7 +557,10 @@ func init() {
 	// cache purge
 	cachePurgeCmd.Flags().String("tenant", "", "Purge all keys for a tenant")
 	cachePurgeCmd.Flags().StringP("prefix", "p", "", "Purge keys matching prefix")
+	cachePurgeCmd.Flags().StringArray("tag", nil, "Purge keys tagged with this tag, repeatable")
 	cachePurgeCmd.Flags().Bool("all", false, "Purge all keys in the namespace")
+	cachePurgeCmd.Flags().Bool("preview", false, "List the matching keys without purging")
+	cachePurgeCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
 	cachePurgeCmd.Flags().Bool("cdn", false, "Purge Cloudflare CDN cache instead of KV")
 	cachePurgeCmd.Flags().String("cdn-url", "", "Specific URL to purge from CDN (requires --cdn)")
 	cachePurgeCmd.Flags().StringP("namespace", "n", "cache", "KV namespace alias")
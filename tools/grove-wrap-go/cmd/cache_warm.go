@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/cachewarm"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// cacheWarmUserAgent identifies warm requests in the site's logs.
+const cacheWarmUserAgent = "gw-cli/1.0 (Grove Wrap; cache warm)"
+
+// cacheWarmBaseURL fills the {tenant} placeholder of the configured or
+// given base URL.
+func cacheWarmBaseURL(base, tenant string) (string, error) {
+	if strings.Contains(base, "{tenant}") {
+		if tenant == "" {
+			return "", fmt.Errorf("no tenant: pass one, set GROVE_TENANT, or give --base-url")
+		}
+		if err := validateCFName(tenant, "tenant"); err != nil {
+			return "", err
+		}
+		base = strings.ReplaceAll(base, "{tenant}", tenant)
+	}
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		return "", fmt.Errorf("base URL %q must start with http:// or https://", base)
+	}
+	return strings.TrimSuffix(base, "/"), nil
+}
+
+// cacheWarmRoutes picks the routes to request: --route values as given,
+// else the configured list, else the routes of the SvelteKit app. Excludes
+// apply to the last two. skipped lists the app routes with parameters.
+func cacheWarmRoutes(cfg *config.Config, routes, exclude []string) (paths, skipped []string, source string, err error) {
+	switch {
+	case len(routes) > 0:
+		paths, source = routes, "--route"
+	case len(cfg.Cache.Routes) > 0:
+		paths, source = cfg.Cache.Routes, "cache.routes"
+	default:
+		dir := cfg.Cache.RoutesDir
+		if !filepath.IsAbs(dir) {
+			if cfg.GroveRoot == "" {
+				return nil, nil, "", fmt.Errorf("not in a grove checkout: set cache.routes in gw.toml or pass --route")
+			}
+			dir = filepath.Join(cfg.GroveRoot, dir)
+		}
+		paths, skipped, err = cachewarm.Routes(dir)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("reading routes: %w", err)
+		}
+		source = dir
+	}
+	for _, p := range paths {
+		if !strings.HasPrefix(p, "/") {
+			return nil, nil, "", fmt.Errorf("route %q must start with /", p)
+		}
+	}
+	if len(routes) == 0 {
+		paths, err = cachewarm.Exclude(paths, append(append([]string{}, cfg.Cache.Exclude...), exclude...))
+		if err != nil {
+			return nil, nil, "", err
+		}
+	}
+	return paths, skipped, source, nil
+}
+
+// cacheWarmSummaryLine reads like "12 requests · 9 HIT · 3 MISS · p50 42ms".
+func cacheWarmSummaryLine(s cachewarm.Summary) string {
+	parts := []string{fmt.Sprintf("%d requests", s.Requests)}
+	var statuses []string
+	for status := range s.Cache {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		parts = append(parts, fmt.Sprintf("%d %s", s.Cache[status], status))
+	}
+	if s.Failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", s.Failed))
+	}
+	parts = append(parts, fmt.Sprintf("p50 %.0fms", s.P50MS), fmt.Sprintf("p95 %.0fms", s.P95MS), fmt.Sprintf("max %.0fms", s.MaxMS))
+	return strings.Join(parts, " · ")
+}
+
+// cacheWarmCell renders one result for the table.
+func cacheWarmCell(r cachewarm.Result) (status, cache, latency string) {
+	if r.Error != "" {
+		return "error", "—", "—"
+	}
+	cache = r.Cache
+	if cache == "" {
+		cache = "—"
+	}
+	return fmt.Sprintf("%d", r.Status), cache, fmt.Sprintf("%.0fms", r.LatencyMS)
+}
+
+var cacheWarmCmd = &cobra.Command{
+	Use:   "warm [TENANT]",
+	Short: "Request a tenant's routes to fill the cache",
+	Long: `Request a tenant's routes so the CDN cache fills before visitors arrive,
+and report how each response was served (cf-cache-status) and how long it took.
+
+Routes come from --route, else cache.routes in gw.toml, else the SvelteKit
+routes of cache.routes_dir (apps/aspen/src/routes), read the way gf routes
+reads them. Routes with parameters such as [slug] are skipped. Patterns in
+cache.exclude and --exclude drop routes: * matches within a segment, **
+across them, and /arbor/** covers /arbor too.
+
+The base URL defaults to https://{tenant}.grove.place; --base-url points the
+crawl elsewhere, such as a local preview server. --verify requests every
+route a second time to confirm it is now served from cache.`,
+	Example: `  gw cache warm autumn
+  gw cache warm autumn --verify
+  gw cache warm --base-url http://localhost:5173 --route / --route /about`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafety("cache_warm"); err != nil {
+			return err
+		}
+
+		cfg := config.Get()
+		baseFlag, _ := cmd.Flags().GetString("base-url")
+		routes, _ := cmd.Flags().GetStringArray("route")
+		exclude, _ := cmd.Flags().GetStringArray("exclude")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		verify, _ := cmd.Flags().GetBool("verify")
+
+		tenant := cfg.Grove.Tenant
+		if len(args) == 1 {
+			tenant = args[0]
+		}
+		base := cfg.Cache.BaseURL
+		if baseFlag != "" {
+			base = baseFlag
+		}
+		base, err := cacheWarmBaseURL(base, tenant)
+		if err != nil {
+			return err
+		}
+		if concurrency < 1 || concurrency > 32 {
+			return fmt.Errorf("--concurrency must be between 1 and 32")
+		}
+
+		paths, skipped, source, err := cacheWarmRoutes(cfg, routes, exclude)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("no routes to warm from %s", source)
+		}
+
+		opts := cachewarm.Options{Concurrency: concurrency, Timeout: timeout, UserAgent: cacheWarmUserAgent}
+		ctx := context.Background()
+		start := time.Now()
+		results := cachewarm.Crawl(ctx, base, paths, opts)
+		var second []cachewarm.Result
+		if verify {
+			second = cachewarm.Crawl(ctx, base, paths, opts)
+		}
+		elapsed := time.Since(start)
+
+		summary := cachewarm.Summarize(results)
+		failed := summary.Failed > 0
+
+		if cfg.JSONMode {
+			out := map[string]interface{}{
+				"base_url":   base,
+				"source":     source,
+				"skipped":    stringsOrEmpty(skipped),
+				"results":    results,
+				"summary":    summary,
+				"elapsed_ms": elapsed.Milliseconds(),
+			}
+			if verify {
+				out["verify"] = map[string]interface{}{
+					"results": second,
+					"summary": cachewarm.Summarize(second),
+				}
+			}
+			if err := printJSON(out); err != nil {
+				return err
+			}
+			if failed {
+				return exitStatus(1)
+			}
+			return nil
+		}
+
+		headers := []string{"Route", "Status", "Cache", "Latency", "Size"}
+		if verify {
+			headers = append(headers, "Then")
+		}
+		var rows [][]string
+		for i, r := range results {
+			status, cache, latency := cacheWarmCell(r)
+			size := "—"
+			if r.Error == "" {
+				size = formatBytes(r.Bytes)
+			}
+			row := []string{r.Path, status, cache, latency, size}
+			if verify {
+				_, then, thenLatency := cacheWarmCell(second[i])
+				row = append(row, then+" "+thenLatency)
+			}
+			rows = append(rows, row)
+		}
+		fmt.Print(ui.RenderTable(fmt.Sprintf("Cache Warm — %s (%d routes)", base, len(paths)), headers, rows))
+
+		for _, r := range results {
+			if r.Error != "" {
+				ui.Warning(fmt.Sprintf("%s: %s", r.Path, r.Error))
+			}
+		}
+		if len(skipped) > 0 {
+			ui.Muted(fmt.Sprintf("Skipped %d routes with parameters: %s", len(skipped), strings.Join(skipped, ", ")))
+		}
+		ui.Info(cacheWarmSummaryLine(summary))
+		if verify {
+			ui.Info("Then: " + cacheWarmSummaryLine(cachewarm.Summarize(second)))
+		}
+		if failed {
+			ui.Warning(fmt.Sprintf("%d routes failed (network error or 5xx)", summary.Failed))
+			return exitStatus(1)
+		}
+		ui.Success(fmt.Sprintf("Warmed %d routes in %s", len(paths), elapsed.Round(time.Millisecond)))
+		return nil
+	},
+}
+
+// stringsOrEmpty keeps JSON output to [] rather than null.
+func stringsOrEmpty(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func init() {
+	cacheWarmCmd.Flags().String("base-url", "", "Site to crawl (default cache.base_url, https://{tenant}.grove.place)")
+	cacheWarmCmd.Flags().StringArray("route", nil, "Route to request, repeatable (replaces the configured routes)")
+	cacheWarmCmd.Flags().StringArray("exclude", nil, "Skip routes matching a pattern, repeatable")
+	cacheWarmCmd.Flags().IntP("concurrency", "c", 4, "Requests in flight at once")
+	cacheWarmCmd.Flags().Duration("timeout", 30*time.Second, "Timeout for each request")
+	cacheWarmCmd.Flags().Bool("verify", false, "Request every route again to confirm it is cached")
+	cacheCmd.AddCommand(cacheWarmCmd)
+}
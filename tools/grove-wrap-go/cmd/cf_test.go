@@ -1,10 +1,19 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/backups"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlitefile"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/undo"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/vault"
 )
 
 // --- CF Name validation tests ---
@@ -300,6 +309,8 @@ func TestParseFlagEnabled(t *testing.T) {
 		{`{"enabled": true}`, true},
 		{`{"enabled": false}`, false},
 		{`{"enabled": true, "extra": "data"}`, true},
+		{`{"enabled": true, "rules": {"percent": 10}}`, true},
+		{`{"enabled": false, "rules": {"allow": ["autumn"]}}`, false},
 
 		// JSON booleans
 		{"true", true},
@@ -330,19 +341,142 @@ func TestParseFlagEnabled(t *testing.T) {
 	}
 }
 
+func TestCarryFlagRules(t *testing.T) {
+	value := map[string]interface{}{"enabled": false}
+	carryFlagRules(&undo.State{Value: `{"enabled": true, "rules": {"percent": 25}}`}, value)
+	data, _ := json.Marshal(value)
+	if string(data) != `{"enabled":false,"rules":{"percent":25}}` {
+		t.Errorf("rules not carried: %s", data)
+	}
+	value = map[string]interface{}{"enabled": true}
+	carryFlagRules(&undo.State{Value: "true"}, value)
+	carryFlagRules(nil, value)
+	if _, ok := value["rules"]; ok {
+		t.Errorf("a boolean flag gained rules: %v", value)
+	}
+}
+
+func TestDescribeFlagValue(t *testing.T) {
+	on, off, rolled := "true", `{"enabled": false}`, `{"enabled": true, "rules": {"percent": 25}}`
+	tests := []struct {
+		raw  *string
+		want string
+	}{
+		{nil, "absent"},
+		{&on, "on"},
+		{&off, "off"},
+		{&rolled, "on · 25% rollout"},
+	}
+	for _, tt := range tests {
+		if got := describeFlagValue(tt.raw); got != tt.want {
+			t.Errorf("describeFlagValue(%v) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+	if err := validateFlagName("_history:new-editor"); err == nil {
+		t.Error("history keys should not be accepted as flag names")
+	}
+}
+
+func TestShareSecret(t *testing.T) {
+	secret := vault.Secret{
+		vault.DefaultEnv: {Value: "d", DeployedTo: map[string]string{"grove-api": "x"}},
+		"production":     {Value: "p"},
+		"staging":        {Value: "s"},
+	}
+	if got := shareSecret(secret, nil); len(got) != 3 || got[vault.DefaultEnv].DeployedTo != nil {
+		t.Errorf("sharing every environment = %+v, want 3 entries without deployments", got)
+	}
+	got := shareSecret(secret, []string{"production", "preview"})
+	if len(got) != 2 || got["production"].Value != "p" || got[vault.DefaultEnv].Value != "d" {
+		t.Errorf("sharing production and preview = %+v, want production plus the default preview falls back to", got)
+	}
+	if got := shareSecret(vault.Secret{"staging": {Value: "s"}}, []string{"production"}); len(got) != 0 {
+		t.Errorf("a secret with no usable value should share nothing, got %+v", got)
+	}
+
+	existing := vault.Secret{"production": {Value: "p"}}
+	for _, tt := range []struct {
+		env, value string
+		overwrite  bool
+		want       string
+	}{
+		{"staging", "s", false, "new"},
+		{"production", "p", false, "same"},
+		{"production", "q", false, "conflict"},
+		{"production", "q", true, "replace"},
+	} {
+		if got := receivePlan(existing, tt.env, &vault.SecretEntry{Value: tt.value}, tt.overwrite); got != tt.want {
+			t.Errorf("receivePlan(%s=%s, overwrite %v) = %q, want %q", tt.env, tt.value, tt.overwrite, got, tt.want)
+		}
+	}
+}
+
+func TestUndoOperation(t *testing.T) {
+	present := &undo.State{Digest: "x"}
+	tests := []struct {
+		entry undo.Entry
+		want  string
+	}{
+		{undo.Entry{Kind: undo.KindKV, Namespace: "flags", After: present}, "flag_delete"},
+		{undo.Entry{Kind: undo.KindKV, Namespace: "flags", Before: present}, "flag_revert"},
+		{undo.Entry{Kind: undo.KindKV, Namespace: "cache", After: present}, "kv_delete"},
+		{undo.Entry{Kind: undo.KindKV, Namespace: "cache", Before: present}, "kv_put"},
+		{undo.Entry{Kind: undo.KindR2, Before: present}, "r2_put"},
+		{undo.Entry{Kind: undo.KindR2, After: present}, "r2_rm"},
+		{undo.Entry{Kind: undo.KindSecret, Before: present}, "secret_set"},
+		{undo.Entry{Kind: undo.KindSecret, After: present}, "secret_delete"},
+	}
+	for _, tt := range tests {
+		if got := undoOperation(&tt.entry); got != tt.want {
+			t.Errorf("undoOperation(%s %s, before %v) = %q, want %q", tt.entry.Kind, tt.entry.Namespace, tt.entry.Before != nil, got, tt.want)
+		}
+	}
+
+	// Removing a flag the journaled enable created is blocked for agents
+	created := &undo.Entry{Kind: undo.KindKV, Namespace: "flags", After: present}
+	if err := safety.CheckCloudflareSafetyTarget(undoOperation(created), safety.Target{Namespace: "flags"}, true, true, true, false); err == nil {
+		t.Error("undoing a flag creation should be refused in agent mode")
+	}
+}
+
+func TestSecretUndoDigest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	v, err := vault.Create("test-password-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := sealSecret(v, vault.Secret{vault.DefaultEnv: {Value: "sk-1", UpdatedAt: "2026-01-01"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := sealSecret(v, vault.Secret{vault.DefaultEnv: {Value: "sk-1", UpdatedAt: "2026-02-01"}})
+	c, _ := sealSecret(v, vault.Secret{vault.DefaultEnv: {Value: "sk-2"}})
+	if !strings.HasPrefix(a.Digest, undo.HMACPrefix) || strings.Contains(a.Digest, undo.Digest([]byte("sk-1"))) {
+		t.Errorf("secret digest = %q, want a vault-keyed HMAC", a.Digest)
+	}
+	if !undo.Same(a, b) {
+		t.Error("the same value with a new timestamp should not count as drift")
+	}
+	if undo.Same(a, c) {
+		t.Error("a changed secret value should count as drift")
+	}
+}
+
 // --- Cloudflare safety tiers ---
 
 func TestCloudflareSafetyTiers(t *testing.T) {
 	// READ operations should not require --write
 	readOps := []string{
-		"d1_list", "d1_tables", "d1_schema", "d1_query_read",
-		"kv_list", "kv_keys", "kv_get",
+		"d1_list", "d1_tables", "d1_schema", "d1_query_read", "d1_export", "d1_shell",
+		"kv_list", "kv_keys", "kv_get", "kv_export", "kv_diff",
 		"r2_list", "r2_ls", "r2_get",
 		"deploy_dry", "logs_tail",
-		"flag_list", "flag_get",
-		"backup_list", "backup_download",
+		"flag_list", "flag_get", "flag_eval", "flag_history", "flag_audit",
+		"backup_list", "backup_download", "backup_verify", "backup_prune_plan",
 		"do_list", "do_info", "do_alarm",
 		"email_status", "email_rules",
+		"cache_list", "cache_warm", "cache_purge_preview",
+		"secret_list", "secret_pubkey",
 	}
 	for _, op := range readOps {
 		err := safety.CheckCloudflareSafety(op, false, false, false, false)
@@ -353,13 +487,15 @@ func TestCloudflareSafetyTiers(t *testing.T) {
 
 	// WRITE operations should require --write
 	writeOps := []string{
-		"d1_query_write", "d1_migrate",
-		"kv_put", "kv_delete",
-		"r2_create", "r2_put",
+		"d1_query_write", "d1_migrate", "d1_import",
+		"kv_put", "kv_delete", "kv_import",
+		"r2_create", "r2_put", "r2_sync",
 		"deploy",
-		"flag_enable", "flag_disable",
+		"flag_enable", "flag_disable", "flag_rollout", "flag_target", "flag_revert",
 		"backup_create",
 		"email_test",
+		"cache_purge",
+		"secret_rekey", "secret_receive",
 	}
 	for _, op := range writeOps {
 		err := safety.CheckCloudflareSafety(op, false, false, false, false)
@@ -378,7 +514,8 @@ func TestCloudflareSafetyTiers(t *testing.T) {
 
 	// DESTRUCTIVE operations should require --write AND --force
 	destructiveOps := []string{
-		"r2_rm", "flag_delete", "backup_restore",
+		"r2_rm", "flag_delete", "backup_restore", "d1_export_raw", "kv_bulk_delete", "r2_sync_delete", "backup_prune",
+		"secret_share",
 	}
 	for _, op := range destructiveOps {
 		// Without --write: error
@@ -466,3 +603,446 @@ func TestResolveDatabasePassesThrough(t *testing.T) {
 		t.Errorf("resolveDatabase('mydb') = %q, want 'mydb'", name)
 	}
 }
+
+// --- D1 row limits ---
+
+func TestEnforceD1RowLimits(t *testing.T) {
+	cfg := config.Get()
+	maxDelete := cfg.EffectiveMaxDeleteRows()
+	maxUpdate := cfg.EffectiveMaxUpdateRows()
+
+	ok := []d1RowCount{
+		{Operation: "DELETE", Table: "posts", Rows: maxDelete, Exact: true},
+		{Operation: "UPDATE", Table: "posts", Rows: maxUpdate, Exact: true},
+	}
+	if err := enforceD1RowLimits("d1_query_write", "", ok); err != nil {
+		t.Errorf("counts at the limit should pass: %v", err)
+	}
+
+	over := []d1RowCount{{Operation: "DELETE", Table: "posts", Rows: maxDelete + 1, Exact: true}}
+	err := enforceD1RowLimits("d1_query_write", "", over)
+	var sqlErr *safety.SQLSafetyError
+	if !errors.As(err, &sqlErr) || sqlErr.Code != safety.ErrUnsafeDelete {
+		t.Errorf("expected UNSAFE_DELETE, got %v", err)
+	}
+
+	estimated := []d1RowCount{{Operation: "UPDATE", Table: "posts", Rows: maxUpdate + 1, CountError: "no such table"}}
+	err = enforceD1RowLimits("d1_query_write", "", estimated)
+	if !errors.As(err, &sqlErr) || sqlErr.Code != safety.ErrUnsafeUpdate {
+		t.Errorf("expected UNSAFE_UPDATE, got %v", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "estimated") {
+		t.Errorf("estimate-based refusal should say so: %v", err)
+	}
+}
+
+func TestRequireKVBulkDelete(t *testing.T) {
+	cfg := config.Get()
+	oldWrite, oldForce, oldAgent, oldMax := cfg.WriteFlag, cfg.ForceFlag, cfg.AgentMode, cfg.Safety.MaxKVDeleteKeys
+	defer func() {
+		cfg.WriteFlag, cfg.ForceFlag, cfg.AgentMode, cfg.Safety.MaxKVDeleteKeys = oldWrite, oldForce, oldAgent, oldMax
+	}()
+	cfg.WriteFlag, cfg.ForceFlag, cfg.AgentMode, cfg.Safety.MaxKVDeleteKeys = true, true, false, 10
+
+	if err := requireKVBulkDelete("cache", 10, 0); err != nil {
+		t.Errorf("10 keys at a ceiling of 10 should pass: %v", err)
+	}
+	// --force does not lift the ceiling
+	if err := requireKVBulkDelete("cache", 11, 0); err == nil || !strings.Contains(err.Error(), "--max-keys 11") {
+		t.Errorf("11 keys should exceed the ceiling, got %v", err)
+	}
+	if err := requireKVBulkDelete("cache", 11, 20); err != nil {
+		t.Errorf("--max-keys 20 should allow 11 keys: %v", err)
+	}
+	cfg.ForceFlag = false
+	if err := requireKVBulkDelete("cache", 1, 0); err == nil {
+		t.Error("a bulk delete without --force should be refused")
+	}
+}
+
+func TestRequireVerifiedBackup(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg := config.Get()
+	oldForce, oldJSON, oldAgent := cfg.ForceFlag, cfg.JSONMode, cfg.AgentMode
+	defer func() { cfg.ForceFlag, cfg.JSONMode, cfg.AgentMode = oldForce, oldJSON, oldAgent }()
+	cfg.ForceFlag, cfg.JSONMode, cfg.AgentMode = true, true, false
+
+	if err := requireVerifiedBackup("db", "new", false); err == nil || !strings.Contains(err.Error(), "never been verified") {
+		t.Errorf("an unverified backup should be refused, got %v", err)
+	}
+	ledger, err := backups.LoadLedger(backups.LedgerPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger.Record("db", "good", backups.Verification{OK: true, IntegrityCheck: true})
+	ledger.Record("db", "walked", backups.Verification{OK: true})
+	ledger.Record("db", "bad", backups.Verification{OK: false, Problems: 3, IntegrityCheck: true})
+	if err := ledger.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := requireVerifiedBackup("db", "good", false); err != nil {
+		t.Errorf("a verified backup should pass: %v", err)
+	}
+	if err := requireVerifiedBackup("db", "walked", false); err == nil || !strings.Contains(err.Error(), "without PRAGMA integrity_check") {
+		t.Errorf("a backup without PRAGMA integrity_check should be refused, got %v", err)
+	}
+	// --force is always present on the dangerous restore, so it must not
+	// skip the check.
+	if err := requireVerifiedBackup("db", "bad", false); err == nil || !strings.Contains(err.Error(), "3 problem") {
+		t.Errorf("a failed backup should be refused even with --force, got %v", err)
+	}
+	if err := requireVerifiedBackup("db", "bad", true); err != nil {
+		t.Errorf("--unverified should restore anyway: %v", err)
+	}
+	cfg.AgentMode = true
+	if err := requireVerifiedBackup("db", "bad", true); err == nil {
+		t.Error("agents should not be able to pass --unverified")
+	}
+}
+
+func TestCheckBackupFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backup.sqlite3")
+	db, err := sqlitefile.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts, err := db.CreateTable("posts", []string{"id", "body"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5000; i++ {
+		if err := posts.Insert([]any{int64(i), strings.Repeat("spring ", 20)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	check, err := checkBackupFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(check.Problems) != 0 || len(check.Tables) != 1 || check.Tables[0].Rows != 5000 {
+		t.Errorf("sound backup: %+v", check)
+	}
+
+	// Zero a page in the middle of the table.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(data[40*4096:41*4096], make([]byte, 4096))
+	broken := filepath.Join(dir, "broken.sqlite3")
+	if err := os.WriteFile(broken, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if check, err := checkBackupFile(broken); err != nil || len(check.Problems) == 0 {
+		t.Errorf("damaged backup: %+v, %v", check, err)
+	}
+
+	if err := os.WriteFile(broken, []byte("<html>error</html>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if check, err := checkBackupFile(broken); err != nil || len(check.Problems) != 1 {
+		t.Errorf("non-SQLite download: %+v, %v", check, err)
+	}
+}
+
+func TestD1QueryFormat(t *testing.T) {
+	tests := []struct {
+		format, output string
+		json           bool
+		want           string
+		wantErr        bool
+	}{
+		{"", "", false, "table", false},
+		{"", "", true, "json", false},
+		{"csv", "", true, "csv", false},
+		{"", "report.CSV", false, "csv", false},
+		{"", "rows.jsonl", false, "ndjson", false},
+		{"", "posts.db", false, "sqlite", false},
+		{"tsv", "posts.csv", false, "tsv", false},
+		{"", "report.xlsx", false, "", true},
+		{"sqlite", "", false, "", true},
+		{"table", "out.txt", false, "", true},
+		{"yaml", "", false, "", true},
+	}
+	for _, tt := range tests {
+		got, err := d1QueryFormat(tt.format, tt.output, tt.json)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("d1QueryFormat(%q, %q, %v) = %q, %v", tt.format, tt.output, tt.json, got, err)
+		}
+	}
+}
+
+func TestD1ExportImportSQL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dev.sqlite")
+	db, err := sqlitefile.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts, err := db.CreateTable("posts", []string{"id", "title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts.Insert([]any{int64(1), "It's spring"})
+	posts.Insert([]any{int64(2), nil})
+	posts.Close()
+	err = writeD1ExportManifest(db, []d1ExportedTable{{
+		Table:     "posts",
+		Columns:   []string{"id", "title"},
+		CreateSQL: "CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT);\nCREATE INDEX IF NOT EXISTS idx_posts_title ON posts(title);",
+		Rows:      2,
+		Scrubbed:  map[string]string{"title": "name"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := sqlitefile.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	exported, err := readD1ExportManifest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 1 || exported[0].Rows != 2 || exported[0].Scrubbed["title"] != "name" {
+		t.Fatalf("manifest = %+v", exported)
+	}
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "import.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeD1ImportSQL(out, r, exported[0], true); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+	sql, _ := os.ReadFile(out.Name())
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS posts (id INTEGER PRIMARY KEY, title TEXT);",
+		"CREATE INDEX IF NOT EXISTS idx_posts_title ON posts(title);",
+		`DELETE FROM "posts";`,
+		`INSERT OR IGNORE INTO "posts" ("id", "title") VALUES`,
+		"(1, 'It''s spring'),\n(2, NULL);",
+	} {
+		if !strings.Contains(string(sql), want) {
+			t.Errorf("import SQL missing %q:\n%s", want, sql)
+		}
+	}
+}
+
+func TestCheckD1ExportWhere(t *testing.T) {
+	cfg := config.Get()
+	prev := cfg.Safety.ProtectedTables
+	defer func() { cfg.Safety.ProtectedTables = prev }()
+	cfg.Safety.ProtectedTables = []string{"users", "sessions"}
+
+	ok := []string{
+		"tenant_id = 't_123'",
+		"author_id IN (SELECT id FROM tenants WHERE plan = 'oak')",
+	}
+	for _, where := range ok {
+		if err := checkD1ExportWhere("posts", where, 100); err != nil {
+			t.Errorf("checkD1ExportWhere(%q) = %v", where, err)
+		}
+	}
+	bad := []string{
+		"1 UNION SELECT * FROM users",
+		"author_id IN (SELECT id FROM users)",
+		"EXISTS (SELECT 1 FROM sessions)",
+		"1; DELETE FROM posts",
+		"1 --",
+	}
+	for _, where := range bad {
+		if err := checkD1ExportWhere("posts", where, 100); err == nil {
+			t.Errorf("checkD1ExportWhere(%q) should be refused", where)
+		}
+	}
+	if err := checkD1ExportWhere("users", "id IN (SELECT id FROM users WHERE admin = 1)", 100); err != nil {
+		t.Errorf("a filter on the exported protected table itself = %v", err)
+	}
+}
+
+func TestD1ImportDDL(t *testing.T) {
+	for _, ddl := range []string{
+		"CREATE TABLE posts (id INTEGER);\nDROP TABLE users;",
+		"CREATE TABLE posts (id INTEGER);\nCREATE TRIGGER t AFTER INSERT ON posts BEGIN DELETE FROM users; END;",
+		"CREATE TABLE other (id INTEGER);",
+		"CREATE INDEX idx ON users(email);",
+		"CREATE TABLE posts AS SELECT * FROM users;",
+	} {
+		if _, err := d1ImportDDL(d1ExportedTable{Table: "posts", CreateSQL: ddl}); err == nil {
+			t.Errorf("d1ImportDDL(%q) should be refused", ddl)
+		}
+	}
+	got, err := d1ImportDDL(d1ExportedTable{Table: "posts", CreateSQL: "CREATE TABLE posts (id INTEGER);\nCREATE UNIQUE INDEX idx ON posts(id);"})
+	if err != nil || len(got) != 2 || got[1] != "CREATE UNIQUE INDEX IF NOT EXISTS idx ON posts(id)" {
+		t.Errorf("d1ImportDDL = %q, %v", got, err)
+	}
+}
+
+func TestD1QueryPlan(t *testing.T) {
+	res := &d1ShellResult{
+		cols: []string{"id", "parent", "notused", "detail"},
+		rows: [][]any{
+			{json.Number("2"), json.Number("0"), json.Number("0"), "SCAN p"},
+			{json.Number("5"), json.Number("0"), json.Number("0"), "SEARCH t USING INDEX sqlite_autoindex_tenants_1 (id=?)"},
+			{json.Number("9"), json.Number("0"), json.Number("0"), "USE TEMP B-TREE FOR ORDER BY"},
+			{json.Number("12"), json.Number("9"), json.Number("0"), "SCALAR SUBQUERY 1"},
+		},
+	}
+	want := "├── SCAN p\n" +
+		"├── SEARCH t USING INDEX sqlite_autoindex_tenants_1 (id=?)\n" +
+		"└── USE TEMP B-TREE FOR ORDER BY\n" +
+		"    └── SCALAR SUBQUERY 1"
+	if got := d1QueryPlan(res); got != want {
+		t.Errorf("d1QueryPlan =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteD1ShellResult(t *testing.T) {
+	res := &d1ShellResult{
+		cols: []string{"id", "title"},
+		rows: [][]any{{json.Number("1"), "hello, world"}, {json.Number("2"), nil}},
+	}
+	path := filepath.Join(t.TempDir(), "posts.csv")
+	if err := writeD1ShellResult(res, "csv", path, "lattice", "posts"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "id,title\n1,\"hello, world\"\n2,\n"; string(data) != want {
+		t.Errorf("csv = %q, want %q", data, want)
+	}
+}
+
+func TestKVSchemas(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".grove", "schemas")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	schema := `{"type": "object", "required": ["enabled"], "properties": {"enabled": {"type": "boolean"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "flag.json"), []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Get()
+	old := cfg.KV
+	defer func() { cfg.KV = old }()
+	cfg.KV.Schemas = []config.KVSchema{
+		{Namespace: "flags", Keys: "*", Schema: "schemas/flag.json"},
+		{Keys: "config:*"},
+	}
+
+	if rule := kvSchemaFor("cache", "page:/"); rule != nil {
+		t.Errorf("an uncovered key matched %+v", rule)
+	}
+	rule := kvSchemaFor("flags", "new-dashboard")
+	if rule == nil || rule.Schema == "" {
+		t.Fatalf("flag key matched %+v", rule)
+	}
+	if err := validateKVValue(rule, "new-dashboard", `{"enabled": true}`); err != nil {
+		t.Errorf("a valid flag was refused: %v", err)
+	}
+	err := validateKVValue(rule, "new-dashboard", `{"enabled": "yes"}`)
+	if err == nil || !strings.Contains(err.Error(), "/enabled: want boolean") {
+		t.Errorf("an invalid flag should be refused, got %v", err)
+	}
+
+	rule = kvSchemaFor("cache", "config:site")
+	if rule == nil || rule.Schema != "" {
+		t.Fatalf("config key matched %+v", rule)
+	}
+	if err := validateKVValue(rule, "config:site", "not json"); err == nil {
+		t.Error("a JSON-only rule accepted text")
+	}
+}
+
+// --- cache warm and purge selection ---
+
+func TestCacheMatchTags(t *testing.T) {
+	keys := []kvListing{
+		{Name: "cache:autumn:posts", Metadata: json.RawMessage(`{"tags": ["posts", "home"]}`)},
+		{Name: "cache:autumn:feed", Metadata: json.RawMessage(`{"tags": "feeds, posts"}`)},
+		{Name: "cache:autumn:about", Metadata: json.RawMessage(`{"tags": ["pages"]}`)},
+		{Name: "cache:autumn:raw"},
+	}
+	var names []string
+	for _, k := range cacheMatchTags(keys, []string{"posts"}) {
+		names = append(names, k.Name)
+	}
+	if strings.Join(names, " ") != "cache:autumn:posts cache:autumn:feed" {
+		t.Errorf("tag posts matched %v", names)
+	}
+	if got := cacheMatchTags(keys, []string{"pages", "feeds"}); len(got) != 2 {
+		t.Errorf("any-of tags matched %d keys, want 2", len(got))
+	}
+	if got := cacheMatchTags(keys, nil); len(got) != len(keys) {
+		t.Errorf("no tags matched %d keys, want all", len(got))
+	}
+}
+
+func TestCachePurgeCDNBody(t *testing.T) {
+	body := cachePurgeCDNBody("", "https://autumn.grove.place/blog", []string{"posts"})
+	data, _ := json.Marshal(body)
+	if string(data) != `{"prefixes":["autumn.grove.place/blog"],"tags":["posts"]}` {
+		t.Errorf("body = %s", data)
+	}
+	if body := cachePurgeCDNBody("", "", nil); body["purge_everything"] != true {
+		t.Errorf("an empty selection should purge everything, got %v", body)
+	}
+}
+
+func TestCacheWarmRoutes(t *testing.T) {
+	root := t.TempDir()
+	routes := filepath.Join(root, "apps", "aspen", "src", "routes")
+	for _, f := range []string{"(site)/+page.svelte", "(site)/about/+page.svelte", "(site)/[slug]/+page.svelte", "arbor/+page.svelte"} {
+		p := filepath.Join(routes, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		os.WriteFile(p, nil, 0o644)
+	}
+
+	cfg := config.Get()
+	oldCache, oldRoot := cfg.Cache, cfg.GroveRoot
+	defer func() { cfg.Cache, cfg.GroveRoot = oldCache, oldRoot }()
+	cfg.Cache = config.DefaultConfig().Cache
+	cfg.GroveRoot = root
+
+	paths, skipped, _, err := cacheWarmRoutes(cfg, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(paths, " ") != "/ /about" || strings.Join(skipped, " ") != "/[slug]" {
+		t.Errorf("derived routes = %v, skipped %v", paths, skipped)
+	}
+	paths, _, _, _ = cacheWarmRoutes(cfg, nil, []string{"/about"})
+	if strings.Join(paths, " ") != "/" {
+		t.Errorf("--exclude left %v", paths)
+	}
+	paths, _, source, _ := cacheWarmRoutes(cfg, []string{"/arbor"}, nil)
+	if strings.Join(paths, " ") != "/arbor" || source != "--route" {
+		t.Errorf("--route gave %v from %s", paths, source)
+	}
+	if _, _, _, err := cacheWarmRoutes(cfg, []string{"about"}, nil); err == nil {
+		t.Error("a route without a leading / was accepted")
+	}
+
+	if base, err := cacheWarmBaseURL(cfg.Cache.BaseURL, "autumn"); err != nil || base != "https://autumn.grove.place" {
+		t.Errorf("base URL = %q, %v", base, err)
+	}
+	if _, err := cacheWarmBaseURL(cfg.Cache.BaseURL, ""); err == nil {
+		t.Error("a {tenant} base URL without a tenant was accepted")
+	}
+}
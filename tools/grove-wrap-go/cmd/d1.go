@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -12,6 +13,7 @@ import (
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlparse"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
 )
 
@@ -23,6 +25,29 @@ func requireCFSafety(operation string) error {
 	)
 }
 
+// requireCFSafetyTarget checks Cloudflare operation safety for a specific
+// resource so scoped policy rules (database, namespace, worker) apply.
+func requireCFSafetyTarget(operation string, target safety.Target) error {
+	cfg := config.Get()
+	return safety.CheckCloudflareSafetyTarget(
+		operation, target, cfg.WriteFlag, cfg.ForceFlag, cfg.AgentMode, cfg.IsInteractive(),
+	)
+}
+
+// cfCheckOpts describes this run to the safety content checks (SQL
+// validation, row and key limits), which audit their refusals as
+// operation.
+func cfCheckOpts(operation string) safety.CheckOpts {
+	cfg := config.Get()
+	return safety.CheckOpts{
+		Operation:   operation,
+		WriteFlag:   cfg.WriteFlag,
+		ForceFlag:   cfg.ForceFlag,
+		AgentMode:   cfg.AgentMode,
+		Interactive: cfg.IsInteractive(),
+	}
+}
+
 // maxCFNameLen is the maximum length for Cloudflare resource names/aliases.
 const maxCFNameLen = 128
 
@@ -309,90 +334,77 @@ var d1SchemaCmd = &cobra.Command{
 var d1QueryCmd = &cobra.Command{
 	Use:   "query <sql>",
 	Short: "Execute a SQL query",
-	Args:  cobra.ExactArgs(1),
+	Long: `Execute a SQL query against a D1 database.
+
+Columns keep the order the query selects them in. Results print as a
+table, or as JSON with --json; --format picks another rendering:
+
+  csv, tsv, ndjson, markdown, json   streamed to stdout or --output
+  sqlite                             a database file; needs --output
+
+With --output and no --format, the format follows the file extension
+(.csv .tsv .ndjson .jsonl .md .json .db .sqlite). An SQLite file holds one
+table, named after the queried table, or "results" for joins.
+
+  gw d1 query "SELECT * FROM users" --limit 5000 --format csv > users.csv
+  gw d1 query "SELECT * FROM posts" --remote --output posts.db`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := config.Get()
 		sqlStr := args[0]
 		dbAlias, _ := cmd.Flags().GetString("db")
 		limit, _ := cmd.Flags().GetInt("limit")
 		remote, _ := cmd.Flags().GetBool("remote")
+		preview, _ := cmd.Flags().GetBool("preview")
+		format, _ := cmd.Flags().GetString("format")
+		outPath, _ := cmd.Flags().GetString("output")
 		dbName, err := resolveDatabase(dbAlias)
 		if err != nil {
 			return err
 		}
 
 		limit = clampD1Limit(limit)
+		format, err = d1QueryFormat(format, outPath, cfg.JSONMode)
+		if err != nil {
+			return err
+		}
 
-		// Determine if this is a mutation
-		upperSQL := strings.TrimSpace(strings.ToUpper(sqlStr))
-		isMutation := false
-		for _, op := range []string{"INSERT", "UPDATE", "DELETE", "DROP", "CREATE", "ALTER"} {
-			if strings.HasPrefix(upperSQL, op) {
-				isMutation = true
-				break
-			}
+		stmt, isMutation, err := checkD1Query(dbAlias, sqlStr)
+		if err != nil {
+			return err
 		}
 
-		if isMutation {
-			if err := requireCFSafety("d1_query_write"); err != nil {
-				return err
+		// --preview only reads, so it needs no write tier
+		if preview {
+			if stmt == nil || (stmt.Kind != "DELETE" && stmt.Kind != "UPDATE") {
+				return fmt.Errorf("--preview applies to DELETE and UPDATE statements")
 			}
+			counts := countD1AffectedRows(dbName, remote, []*sqlparse.Statement{stmt}, d1PreviewRows)
+			return printD1RowCounts(dbName, counts)
 		}
 
-		// SQL safety validation
-		maxDelete := cfg.EffectiveMaxDeleteRows()
-		maxUpdate := cfg.EffectiveMaxUpdateRows()
-		if err := safety.ValidateSQL(sqlStr, cfg.Safety.ProtectedTables, maxDelete, maxUpdate, cfg.ForceFlag); err != nil {
+		if err := authorizeD1Query(dbAlias, dbName, remote, stmt, isMutation); err != nil {
 			return err
 		}
-
-		// Auto-append LIMIT for SELECT if not present
-		if strings.HasPrefix(upperSQL, "SELECT") && !strings.Contains(upperSQL, "LIMIT") {
-			sqlStr = fmt.Sprintf("%s LIMIT %d", sqlStr, limit)
-		}
+		sqlStr = limitD1Select(stmt, sqlStr, limit)
 
 		wranglerArgs := []string{"d1", "execute", dbName, "--json", "--command", sqlStr}
 		if remote {
 			wranglerArgs = append(wranglerArgs, "--remote")
 		}
-		output, err := exec.WranglerOutput(wranglerArgs...)
-		if err != nil {
-			return fmt.Errorf("wrangler error: %w", err)
-		}
 
-		rows := parseD1Results(output)
-
-		if cfg.JSONMode {
-			result := map[string]interface{}{
-				"database": dbName,
-				"rows":     rows,
-			}
-			data, _ := json.Marshal(result)
-			fmt.Println(string(data))
-			return nil
+		// Rows stream straight through; only the table waits for them all
+		table := "results"
+		if stmt != nil && len(stmt.Tables) == 1 {
+			table = stmt.Tables[0]
 		}
-
-		if len(rows) == 0 {
-			ui.Muted("No results")
-			return nil
+		n, err := streamD1Query(dbName, wranglerArgs, format, outPath, table)
+		if err != nil {
+			return err
 		}
-
-		// Build dynamic columns from the first row
-		var cols []string
-		for k := range rows[0] {
-			cols = append(cols, k)
+		if outPath != "" {
+			return reportD1Output(dbName, format, outPath, n)
 		}
-
-		var tableRows [][]string
-		for _, row := range rows {
-			var cells []string
-			for _, c := range cols {
-				cells = append(cells, formatD1Value(row[c]))
-			}
-			tableRows = append(tableRows, cells)
-		}
-		fmt.Print(ui.RenderTable(fmt.Sprintf("Query Results (%d rows)", len(rows)), cols, tableRows))
-
 		return nil
 	},
 }
@@ -409,6 +421,7 @@ var d1MigrateCmd = &cobra.Command{
 		dbAlias, _ := cmd.Flags().GetString("db")
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 		remote, _ := cmd.Flags().GetBool("remote")
+		preview, _ := cmd.Flags().GetBool("preview")
 		dbName, err := resolveDatabase(dbAlias)
 		if err != nil {
 			return err
@@ -473,10 +486,37 @@ var d1MigrateCmd = &cobra.Command{
 			return nil
 		}
 
-		if err := requireCFSafety("d1_migrate"); err != nil {
+		// Row counts need the statements; a file the parser cannot read can
+		// only be applied with --force, which skips row-limit checks.
+		var stmts []*sqlparse.Statement
+		if preview || !cfg.ForceFlag {
+			content, err := os.ReadFile(absPath)
+			if err != nil {
+				return fmt.Errorf("could not read file: %w", err)
+			}
+			script, err := sqlparse.Parse(string(content))
+			if err != nil {
+				return fmt.Errorf("cannot check row limits, migration did not parse: %w (use --force to skip row-limit checks)", err)
+			}
+			stmts = script.Statements
+		}
+
+		if preview {
+			return printD1RowCounts(dbName, countD1AffectedRows(dbName, remote, stmts, d1PreviewRows))
+		}
+
+		if err := requireCFSafetyTarget("d1_migrate", safety.Target{Database: dbAlias}); err != nil {
 			return err
 		}
 
+		// Counts are taken before anything runs, so a statement that depends
+		// on an earlier one in the same file is measured against today's data.
+		if !cfg.ForceFlag {
+			if err := enforceD1RowLimits("d1_migrate", dbAlias, countD1AffectedRows(dbName, remote, stmts, 0)); err != nil {
+				return err
+			}
+		}
+
 		migrateArgs := []string{"d1", "execute", dbName, "--file", absPath}
 		if remote {
 			migrateArgs = append(migrateArgs, "--remote")
@@ -560,10 +600,13 @@ var d1MigrateAllCmd = &cobra.Command{
 			return nil
 		}
 
-		if err := requireCFSafety("d1_migrate_all"); err != nil {
+		if err := requireCFSafetyTarget("d1_migrate_all", safety.Target{Database: dbAlias}); err != nil {
 			return err
 		}
 
+		// Hash what is about to run, so later edits show as modified
+		pending, pendingErr := pendingD1Migrations(dbAlias, remote)
+
 		// Apply pending migrations
 		applyArgs := []string{"d1", "migrations", "apply", dbCfg.Name}
 		if remote {
@@ -592,6 +635,15 @@ var d1MigrateAllCmd = &cobra.Command{
 			output += "\n" + strings.TrimSpace(result.Stderr)
 		}
 
+		// Record whatever ran, even when a later migration failed
+		recordErr := pendingErr
+		if recordErr == nil {
+			_, recordErr = recordD1MigrationChecksums(dbCfg.Name, remote, pending)
+		}
+		if recordErr != nil && !cfg.JSONMode {
+			ui.Warning(fmt.Sprintf("Could not record migration checksums: %v", recordErr))
+		}
+
 		if !result.OK() {
 			return fmt.Errorf("migration failed (exit %d):\n%s", result.ExitCode, output)
 		}
@@ -681,16 +733,216 @@ func isValidIdentifier(s string) bool {
 	return true
 }
 
+// checkD1Query parses an ad-hoc statement for the database dbAlias and runs
+// it through safety.CheckSQL. SQL that does not parse counts as a mutation
+// and is rejected, so the statement is only nil with an error. Row limits
+// are left to authorizeD1Write, which counts exactly.
+func checkD1Query(dbAlias, sqlStr string) (*sqlparse.Statement, bool, error) {
+	cfg := config.Get()
+	isMutation := true
+	var stmt *sqlparse.Statement
+	if script, err := sqlparse.Parse(sqlStr); err == nil && len(script.Statements) > 0 {
+		stmt = script.Statements[0]
+		isMutation = !stmt.IsRead()
+	}
+	maxDelete := cfg.EffectiveMaxDeleteRows()
+	maxUpdate := cfg.EffectiveMaxUpdateRows()
+	operation := "d1_query_read"
+	if isMutation {
+		operation = "d1_query_write"
+	}
+	target := safety.Target{Database: dbAlias}
+	if err := safety.CheckSQL(cfCheckOpts(operation), target, sqlStr, cfg.Safety.ProtectedTables, maxDelete, maxUpdate, true); err != nil {
+		return nil, false, err
+	}
+	return stmt, isMutation, nil
+}
+
+// authorizeD1Query applies the read tier to a read, so policy rules scoped
+// to the database apply to it, and authorizeD1Write to a mutation.
+func authorizeD1Query(dbAlias, dbName string, remote bool, stmt *sqlparse.Statement, isMutation bool) error {
+	if isMutation {
+		return authorizeD1Write(dbAlias, dbName, remote, stmt)
+	}
+	return requireCFSafetyTarget("d1_query_read", safety.Target{Database: dbAlias})
+}
+
+// authorizeD1Write applies the write tier to a mutating statement and,
+// unless --force is given, the row limits against an exact count.
+func authorizeD1Write(dbAlias, dbName string, remote bool, stmt *sqlparse.Statement) error {
+	if err := requireCFSafetyTarget("d1_query_write", safety.Target{Database: dbAlias}); err != nil {
+		return err
+	}
+	if stmt != nil && !config.Get().ForceFlag {
+		counts := countD1AffectedRows(dbName, remote, []*sqlparse.Statement{stmt}, 0)
+		return enforceD1RowLimits("d1_query_write", dbAlias, counts)
+	}
+	return nil
+}
+
+// limitD1Select appends LIMIT to a SELECT that has none of its own.
+func limitD1Select(stmt *sqlparse.Statement, sqlStr string, limit int) string {
+	if stmt != nil && stmt.Kind == "SELECT" && !stmt.Explain && !stmt.HasLimit {
+		return fmt.Sprintf("%s LIMIT %d", stmt.Text, limit)
+	}
+	return sqlStr
+}
+
+// d1PreviewRows is how many affected rows --preview shows per statement.
+const d1PreviewRows = 10
+
+// d1Execute runs a single read query against a database and returns its rows.
+func d1Execute(dbName string, remote bool, sql string) ([]map[string]interface{}, error) {
+	args := []string{"d1", "execute", dbName, "--json", "--command", sql}
+	if remote {
+		args = append(args, "--remote")
+	}
+	output, err := exec.WranglerOutput(args...)
+	if err != nil {
+		return nil, fmt.Errorf("wrangler error: %w", err)
+	}
+	return parseD1Results(output), nil
+}
+
+// d1RowCount is the number of rows one DELETE or UPDATE would touch.
+type d1RowCount struct {
+	Operation  string                   `json:"operation"`
+	Table      string                   `json:"table"`
+	Rows       int                      `json:"rows"`
+	Exact      bool                     `json:"exact"`
+	CountError string                   `json:"count_error,omitempty"`
+	Sample     []map[string]interface{} `json:"sample,omitempty"`
+}
+
+// countD1AffectedRows runs a derived SELECT COUNT(*) with the same WHERE for
+// every DELETE and UPDATE in stmts, against the database they will run on.
+// When a count cannot be taken — the table may be created earlier in the
+// same migration — the parser's estimate is used and Exact is false. With
+// sample > 0, up to that many affected rows are fetched as well.
+func countD1AffectedRows(dbName string, remote bool, stmts []*sqlparse.Statement, sample int) []d1RowCount {
+	var counts []d1RowCount
+	for _, stmt := range stmts {
+		countSQL, ok := stmt.CountSQL()
+		if !ok {
+			continue
+		}
+		c := d1RowCount{Operation: stmt.Kind, Table: stmt.Target}
+
+		rows, err := d1Execute(dbName, remote, countSQL)
+		if err == nil {
+			err = fmt.Errorf("count query returned no rows")
+			if len(rows) > 0 {
+				if n, isNum := rows[0]["n"].(float64); isNum {
+					c.Rows, c.Exact, err = int(n), true, nil
+					if stmt.Limit >= 0 && stmt.Limit < c.Rows {
+						c.Rows = stmt.Limit
+					}
+				}
+			}
+		}
+		if err != nil {
+			c.Rows = safety.EstimateAffectedRows(stmt)
+			c.CountError = err.Error()
+		}
+
+		if sample > 0 && c.Exact && c.Rows > 0 {
+			if previewSQL, ok := stmt.PreviewSQL(sample); ok {
+				c.Sample, _ = d1Execute(dbName, remote, previewSQL)
+			}
+		}
+		counts = append(counts, c)
+	}
+	return counts
+}
+
+// enforceD1RowLimits checks each count against the configured
+// MaxDeleteRows/MaxUpdateRows; a refusal is audited as operation on the
+// database dbAlias.
+func enforceD1RowLimits(operation, dbAlias string, counts []d1RowCount) error {
+	cfg := config.Get()
+	for _, c := range counts {
+		if !c.Exact && !cfg.JSONMode {
+			ui.Warning(fmt.Sprintf("Could not count rows for %s on %s (%s); using an estimate", c.Operation, c.Table, c.CountError))
+		}
+		err := safety.CheckAffectedRows(cfCheckOpts(operation), safety.Target{Database: dbAlias},
+			c.Operation, c.Rows, cfg.EffectiveMaxDeleteRows(), cfg.EffectiveMaxUpdateRows())
+		if err != nil && !c.Exact {
+			return fmt.Errorf("%w (estimated; the exact count could not be taken)", err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printD1RowCounts renders --preview output: each statement's affected-row
+// count and a sample of those rows.
+func printD1RowCounts(dbName string, counts []d1RowCount) error {
+	cfg := config.Get()
+	if cfg.JSONMode {
+		return printJSON(map[string]interface{}{
+			"preview":    true,
+			"database":   dbName,
+			"statements": counts,
+		})
+	}
+
+	if len(counts) == 0 {
+		ui.Muted("No DELETE or UPDATE statements to preview")
+		return nil
+	}
+	for _, c := range counts {
+		limit := cfg.EffectiveMaxDeleteRows()
+		if c.Operation == "UPDATE" {
+			limit = cfg.EffectiveMaxUpdateRows()
+		}
+		pairs := [][2]string{
+			{"Database", dbName},
+			{"Rows affected", fmt.Sprintf("%d (limit %d)", c.Rows, limit)},
+		}
+		if !c.Exact {
+			pairs[1][1] = fmt.Sprintf("~%d estimated (limit %d)", c.Rows, limit)
+			pairs = append(pairs, [2]string{"Count failed", c.CountError})
+		}
+		fmt.Print(ui.RenderInfoPanel(fmt.Sprintf("%s on %s", c.Operation, c.Table), pairs))
+
+		if len(c.Sample) == 0 {
+			continue
+		}
+		var cols []string
+		for k := range c.Sample[0] {
+			cols = append(cols, k)
+		}
+		sort.Strings(cols)
+		var tableRows [][]string
+		for _, row := range c.Sample {
+			var cells []string
+			for _, col := range cols {
+				cells = append(cells, formatD1Value(row[col]))
+			}
+			tableRows = append(tableRows, cells)
+		}
+		fmt.Print(ui.RenderTable(fmt.Sprintf("Sample (%d of %d rows)", len(c.Sample), c.Rows), cols, tableRows))
+	}
+	return nil
+}
+
 var d1HelpCategories = []ui.HelpCategory{
 	{Title: "Read (Always Safe)", Icon: "📖", Style: ui.SafeReadStyle, Commands: []ui.HelpCommand{
 		{Name: "list", Desc: "List configured databases (--remote for Cloudflare API)"},
 		{Name: "tables", Desc: "List tables in a database (--db <name> --remote)"},
 		{Name: "schema <table>", Desc: "Show table schema (--db <name> --remote)"},
+		{Name: "diff [<from> <to>]", Desc: "Compare schemas: alias:local|remote|migrations (--sql)"},
+		{Name: "migrations status", Desc: "Applied, pending, missing and edited migrations (--remote)"},
+		{Name: "export", Desc: "Export scrubbed rows to SQLite (--table <t> --where <expr> --out <file>)"},
 	}},
 	{Title: "Write (--write)", Icon: "✏️", Style: ui.SafeWriteStyle, Commands: []ui.HelpCommand{
-		{Name: "query <sql>", Desc: "Execute a SQL query (--db <name> --remote)"},
-		{Name: "migrate <file.sql>", Desc: "Execute a SQL migration file (--db <name> --remote)"},
+		{Name: "query <sql>", Desc: "Execute a SQL query (--db <name> --remote --preview --format csv|tsv|ndjson|markdown|sqlite -o <file>)"},
+		{Name: "migrate <file.sql>", Desc: "Execute a SQL migration file (--db <name> --remote --preview)"},
 		{Name: "migrate-all", Desc: "Apply pending migrations via wrangler (--db <name> --remote --dry-run)"},
+		{Name: "import <file>", Desc: "Load a gw d1 export into local D1 (--table <t> --replace)"},
+		{Name: "shell", Desc: "Interactive SQL shell with history and completion (--db <name> --remote)"},
 	}},
 }
 
@@ -720,12 +972,22 @@ func init() {
 	d1QueryCmd.Flags().StringP("db", "d", "lattice", "Database alias or name")
 	d1QueryCmd.Flags().IntP("limit", "n", 100, "Maximum rows to return")
 	d1QueryCmd.Flags().Bool("remote", false, "Execute against remote (production) database")
+	d1QueryCmd.Flags().Bool("preview", false, "Show the rows a DELETE/UPDATE would affect without running it")
+	d1QueryCmd.Flags().StringP("format", "f", "", "Output format: table, csv, tsv, ndjson, markdown, json, sqlite")
+	d1QueryCmd.Flags().StringP("output", "o", "", "Write results to a file instead of stdout")
 	d1Cmd.AddCommand(d1QueryCmd)
 
+	// d1 shell
+	d1ShellCmd.Flags().StringP("db", "d", "lattice", "Database alias or name")
+	d1ShellCmd.Flags().IntP("limit", "n", 100, "Maximum rows a SELECT without LIMIT returns")
+	d1ShellCmd.Flags().Bool("remote", false, "Execute against remote (production) database")
+	d1Cmd.AddCommand(d1ShellCmd)
+
 	// d1 migrate
 	d1MigrateCmd.Flags().StringP("db", "d", "lattice", "Database alias or name")
 	d1MigrateCmd.Flags().Bool("dry-run", false, "Show SQL without executing")
 	d1MigrateCmd.Flags().Bool("remote", false, "Execute against remote (production) database")
+	d1MigrateCmd.Flags().Bool("preview", false, "Show the rows each DELETE/UPDATE would affect without applying")
 	d1Cmd.AddCommand(d1MigrateCmd)
 
 	// d1 migrate-all
@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/d1schema"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// schemaSource is one side of a d1 diff: a database alias and where its
+// schema comes from.
+type schemaSource struct {
+	Alias string
+	Where string // local, remote, migrations
+}
+
+// schemaLocations are the places a schema can be read from.
+var schemaLocations = map[string]bool{"local": true, "remote": true, "migrations": true}
+
+// parseSchemaSource parses "alias[:local|:remote|:migrations]". An alias
+// without a location uses defaultWhere.
+func parseSchemaSource(spec, defaultWhere string) (schemaSource, error) {
+	alias, where, ok := strings.Cut(spec, ":")
+	if !ok {
+		where = defaultWhere
+	}
+	if !schemaLocations[where] {
+		return schemaSource{}, fmt.Errorf("unknown schema location %q in %q (use local, remote or migrations)", where, spec)
+	}
+	if err := validateCFName(alias, "database alias"); err != nil {
+		return schemaSource{}, err
+	}
+	return schemaSource{Alias: alias, Where: where}, nil
+}
+
+func (s schemaSource) String() string {
+	return s.Alias + ":" + s.Where
+}
+
+// loadSchema reads a schema from a live database, or builds it by replaying
+// the alias's migrations into a scratch local database.
+func loadSchema(src schemaSource) (*d1schema.Schema, error) {
+	if src.Where != "migrations" {
+		dbName, err := resolveDatabase(src.Alias)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := d1Execute(dbName, src.Where == "remote", d1schema.Query)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+		return d1schema.FromRows(rows), nil
+	}
+
+	dbCfg, err := resolveDatabaseConfig(src.Alias)
+	if err != nil {
+		return nil, err
+	}
+	if dbCfg.MigrationsDir == "" {
+		return nil, fmt.Errorf("database alias '%s' has no migrations_dir configured", src.Alias)
+	}
+	workDir := filepath.Join(config.Get().GroveRoot, dbCfg.MigrationsDir)
+	if _, err := os.Stat(workDir); err != nil {
+		return nil, fmt.Errorf("migrations directory not found: %s", workDir)
+	}
+
+	scratch, err := os.MkdirTemp("", "gw-d1-diff-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratch)
+
+	result, err := exec.WranglerInDir(workDir, "d1", "migrations", "apply", dbCfg.Name, "--local", "--persist-to", scratch)
+	if err != nil {
+		return nil, fmt.Errorf("wrangler error: %w", err)
+	}
+	if !result.OK() {
+		msg := strings.TrimSpace(result.Stderr + "\n" + result.Stdout)
+		return nil, fmt.Errorf("replaying migrations for %s failed:\n%s", src.Alias, msg)
+	}
+
+	output, err := exec.WranglerInDirOutput(workDir, "d1", "execute", dbCfg.Name,
+		"--local", "--persist-to", scratch, "--json", "--command", d1schema.Query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	return d1schema.FromRows(parseD1Results(output)), nil
+}
+
+// --- d1 diff ---
+
+var d1DiffCmd = &cobra.Command{
+	Use:   "diff [<from> <to>]",
+	Short: "Compare schemas between databases, environments or migrations",
+	Long: `Compare two D1 schemas: tables, columns, indexes, triggers and views.
+
+Each side is alias[:local|:remote|:migrations]. "migrations" builds the
+schema by replaying the alias's migrations_dir into a scratch local
+database. An alias without a location is local, or remote with --remote.
+With no arguments, --db is compared remote → local.
+
+The diff reads as the changes from <from> to <to>; --sql prints the SQL
+that would turn <from> into <to>. Review it before applying: table
+rebuilds copy data and drops discard it.
+
+  gw d1 diff                                   # lattice remote → local
+  gw d1 diff lattice:migrations lattice:remote # drift from migrations
+  gw d1 diff staging:remote lattice:remote --sql`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 && len(args) != 2 {
+			return fmt.Errorf("d1 diff takes no arguments or two (<from> <to>), got %d", len(args))
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		dbAlias, _ := cmd.Flags().GetString("db")
+		remote, _ := cmd.Flags().GetBool("remote")
+		showSQL, _ := cmd.Flags().GetBool("sql")
+		exitCode, _ := cmd.Flags().GetBool("exit-code")
+
+		if err := requireCFSafety("d1_diff"); err != nil {
+			return err
+		}
+
+		specs := args
+		if len(specs) == 0 {
+			specs = []string{dbAlias + ":remote", dbAlias + ":local"}
+		}
+		defaultWhere := "local"
+		if remote {
+			defaultWhere = "remote"
+		}
+		var sources [2]schemaSource
+		var schemas [2]*d1schema.Schema
+		for i, spec := range specs {
+			src, err := parseSchemaSource(spec, defaultWhere)
+			if err != nil {
+				return err
+			}
+			sources[i] = src
+		}
+		if sources[0] == sources[1] {
+			return fmt.Errorf("both sides are %s; nothing to compare", sources[0])
+		}
+		for i, src := range sources {
+			if !cfg.JSONMode && src.Where == "migrations" {
+				ui.Muted(fmt.Sprintf("Replaying %s migrations into a scratch database...", src.Alias))
+			}
+			s, err := loadSchema(src)
+			if err != nil {
+				return err
+			}
+			schemas[i] = s
+		}
+
+		changes := d1schema.Diff(schemas[0], schemas[1])
+		var reconcile string
+		if showSQL {
+			reconcile = d1schema.Reconcile(schemas[0], schemas[1], changes)
+		}
+
+		if cfg.JSONMode {
+			result := map[string]interface{}{
+				"from":      sources[0].String(),
+				"to":        sources[1].String(),
+				"identical": len(changes) == 0,
+				"changes":   changes,
+			}
+			if changes == nil {
+				result["changes"] = []d1schema.Change{}
+			}
+			if showSQL {
+				result["sql"] = reconcile
+			}
+			if err := printJSON(result); err != nil {
+				return err
+			}
+		} else {
+			printSchemaDiff(sources, schemas, changes, reconcile, showSQL)
+		}
+
+		if exitCode && len(changes) > 0 {
+			return exitStatus(1)
+		}
+		return nil
+	},
+}
+
+// printSchemaDiff renders a schema diff for humans.
+func printSchemaDiff(sources [2]schemaSource, schemas [2]*d1schema.Schema, changes []d1schema.Change, reconcile string, showSQL bool) {
+	summary := func(s *d1schema.Schema) string {
+		return fmt.Sprintf("%d tables, %d indexes, %d triggers, %d views",
+			s.Len("table"), s.Len("index"), s.Len("trigger"), s.Len("view"))
+	}
+	fmt.Print(ui.RenderInfoPanel("Schema diff", [][2]string{
+		{"From", sources[0].String() + " (" + summary(schemas[0]) + ")"},
+		{"To", sources[1].String() + " (" + summary(schemas[1]) + ")"},
+	}))
+
+	if len(changes) == 0 {
+		ui.Success("Schemas match")
+		return
+	}
+
+	for _, c := range changes {
+		name := c.Name
+		if c.Kind == "column" || c.Kind == "constraint" {
+			name = c.Table + "." + c.Name
+			if c.Kind == "constraint" {
+				name = c.Table + ": " + c.Name
+			}
+		}
+		line := c.Kind + " " + name
+		if c.Note != "" {
+			line += " (" + c.Note + ")"
+		}
+		switch c.Op {
+		case "add":
+			fmt.Println(ui.SuccessStyle.Render("+ " + line))
+		case "drop":
+			fmt.Println(ui.ErrorStyle.Render("- " + line))
+		default:
+			fmt.Println(ui.WarningStyle.Render("~ " + line))
+			if c.Kind == "column" || c.Kind == "index" || c.Kind == "trigger" || c.Kind == "view" {
+				ui.Muted("    - " + oneLine(c.From))
+				ui.Muted("    + " + oneLine(c.To))
+			}
+		}
+	}
+	fmt.Println()
+	noun := "differences"
+	if len(changes) == 1 {
+		noun = "difference"
+	}
+	ui.Warning(fmt.Sprintf("%d schema %s", len(changes), noun))
+
+	if showSQL {
+		fmt.Println()
+		ui.PrintHeader(fmt.Sprintf("SQL to turn %s into %s", sources[0], sources[1]))
+		if reconcile == "" {
+			ui.Muted("Nothing to apply (column order only)")
+		} else {
+			fmt.Print(reconcile)
+		}
+	} else {
+		ui.Hint("Add --sql for the statements that reconcile them")
+	}
+}
+
+// oneLine collapses whitespace so a definition fits on one line.
+func oneLine(s string) string {
+	return TruncateStr(strings.Join(strings.Fields(s), " "), 100)
+}
+
+func init() {
+	d1DiffCmd.Flags().StringP("db", "d", "lattice", "Database alias compared when no sides are given")
+	d1DiffCmd.Flags().Bool("remote", false, "Read sides without a location from remote")
+	d1DiffCmd.Flags().Bool("sql", false, "Print the SQL that turns <from> into <to>")
+	d1DiffCmd.Flags().Bool("exit-code", false, "Exit with status 1 when the schemas differ")
+	d1Cmd.AddCommand(d1DiffCmd)
+}
@@ -0,0 +1,693 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/scrub"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlitefile"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlparse"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// d1ExportManifest is the table in every export file that describes what
+// was exported, so gw d1 import can recreate tables and knows what was
+// scrubbed.
+const d1ExportManifest = "_gw_export"
+
+var d1ExportManifestColumns = []string{
+	"table_name", "columns", "create_sql", "where_clause", "row_count",
+	"scrubbed", "source_database", "location", "exported_at",
+}
+
+// d1ExportedTable is one manifest row.
+type d1ExportedTable struct {
+	Table     string            `json:"table"`
+	Columns   []string          `json:"columns"`
+	CreateSQL string            `json:"-"`
+	Where     string            `json:"where,omitempty"`
+	Rows      int               `json:"rows"`
+	Scrubbed  map[string]string `json:"scrubbed"`
+	Truncated bool              `json:"truncated,omitempty"`
+	Database  string            `json:"-"`
+	Location  string            `json:"-"`
+}
+
+// isProtectedD1Table reports whether table is in safety.protected_tables.
+func isProtectedD1Table(table string) bool {
+	for _, p := range config.Get().Safety.ProtectedTables {
+		if strings.EqualFold(p, table) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkD1ExportWhere checks that --where, placed in the query that exports
+// table, stays a filter on that table: a single read, not compound, that
+// keeps the LIMIT, and that reads no protected table but the one being
+// exported. Without the last rule a subquery could pull a protected
+// table's rows out at read tier, unscrubbed, since the scrub plan follows
+// the exported table.
+func checkD1ExportWhere(table, where string, limit int) error {
+	probe := fmt.Sprintf("SELECT * FROM %s WHERE %s LIMIT %d", table, where, limit)
+	script, err := sqlparse.Parse(probe)
+	if err != nil || len(script.Statements) != 1 {
+		return fmt.Errorf("--where must be a plain filter expression: %q", where)
+	}
+	stmt := script.Statements[0]
+	if !stmt.IsRead() || stmt.Compound || !stmt.HasLimit || stmt.Limit != limit {
+		return fmt.Errorf("--where must be a plain filter expression: %q", where)
+	}
+	for _, t := range stmt.Tables {
+		if !strings.EqualFold(t, table) && isProtectedD1Table(t) {
+			return fmt.Errorf("--where reads protected table '%s'", t)
+		}
+	}
+	return nil
+}
+
+// d1TableColumns returns a table's columns in declared order.
+func d1TableColumns(dbName string, remote bool, table string) ([]string, error) {
+	rows, err := d1Execute(dbName, remote, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	var cols []string
+	for _, r := range rows {
+		if name, ok := r["name"].(string); ok {
+			cols = append(cols, name)
+		}
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("table '%s' not found or has no columns", table)
+	}
+	return cols, nil
+}
+
+// d1TableDDL returns the CREATE statements for a table and its indexes.
+func d1TableDDL(dbName string, remote bool, table string) (string, error) {
+	rows, err := d1Execute(dbName, remote, fmt.Sprintf(
+		"SELECT sql FROM sqlite_master WHERE tbl_name = '%s' AND sql IS NOT NULL AND type IN ('table', 'index') "+
+			"ORDER BY CASE type WHEN 'table' THEN 0 ELSE 1 END, name", table))
+	if err != nil {
+		return "", err
+	}
+	var stmts []string
+	for _, r := range rows {
+		if sql, ok := r["sql"].(string); ok {
+			stmts = append(stmts, strings.TrimSpace(sql)+";")
+		}
+	}
+	return strings.Join(stmts, "\n"), nil
+}
+
+// d1ExportWriter scrubs rows on their way into an export table.
+type d1ExportWriter struct {
+	table    *sqlitefile.Table
+	plan     []string
+	scrubber *scrub.Scrubber
+}
+
+func (w *d1ExportWriter) Columns(cols []string) error {
+	if len(cols) != len(w.plan) {
+		return fmt.Errorf("query returned %d columns, expected %d", len(cols), len(w.plan))
+	}
+	return nil
+}
+
+func (w *d1ExportWriter) Row(values []any) error {
+	for i, strategy := range w.plan {
+		values[i] = w.scrubber.Apply(strategy, values[i])
+	}
+	return w.table.Insert(values)
+}
+
+func (w *d1ExportWriter) Close() error { return nil }
+
+// --- d1 export ---
+
+var d1ExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export tables to a local SQLite file, scrubbing personal data",
+	Long: `Export rows from D1 tables into an SQLite file for local debugging.
+
+Each --table becomes a table in the file, limited by --where and --limit.
+Columns named in the [scrub.columns] section of ~/.grove/gw.toml are
+masked on the way out:
+
+  [scrub.columns]
+  "users.email"    = "email"   # user-<hash>@example.invalid
+  "*.display_name" = "name"    # Person <hash>
+  "sessions.token" = "token"   # hex of the same length
+  "users.phone"    = "null"    # NULL
+  "users.bio"      = "keep"    # unchanged
+
+Masking is consistent within one export, so joins still line up.
+
+Tables in safety.protected_tables are refused unless --include-protected
+is given, and are scrubbed even where no rule applies: columns that look
+like emails, names, tokens, phone numbers or addresses are masked.
+--no-scrub turns scrubbing off; for protected tables that is a dangerous
+operation and needs --write --force.
+
+Load the file into local D1 with gw d1 import.
+
+  gw d1 export --table posts --where "tenant_id = 't_123'" --remote --out dev.sqlite`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		dbAlias, _ := cmd.Flags().GetString("db")
+		remote, _ := cmd.Flags().GetBool("remote")
+		tables, _ := cmd.Flags().GetStringSlice("table")
+		where, _ := cmd.Flags().GetString("where")
+		limit, _ := cmd.Flags().GetInt("limit")
+		outPath, _ := cmd.Flags().GetString("out")
+		includeProtected, _ := cmd.Flags().GetBool("include-protected")
+		noScrub, _ := cmd.Flags().GetBool("no-scrub")
+
+		if len(tables) == 0 {
+			return fmt.Errorf("pass at least one --table")
+		}
+		if outPath == "" {
+			return fmt.Errorf("pass --out <file.sqlite>")
+		}
+		dbName, err := resolveDatabase(dbAlias)
+		if err != nil {
+			return err
+		}
+		limit = clampD1Limit(limit)
+
+		anyProtected := false
+		for _, t := range tables {
+			if !isValidIdentifier(t) {
+				return fmt.Errorf("invalid table name: %q", t)
+			}
+			if isProtectedD1Table(t) {
+				if !includeProtected {
+					return fmt.Errorf("table '%s' is protected — pass --include-protected to export it (it will be scrubbed)", t)
+				}
+				anyProtected = true
+			}
+		}
+
+		if err := requireCFSafetyTarget("d1_export", safety.Target{Database: dbAlias}); err != nil {
+			return err
+		}
+		if noScrub && anyProtected {
+			if err := requireCFSafetyTarget("d1_export_raw", safety.Target{Database: dbAlias}); err != nil {
+				return err
+			}
+		}
+		if err := scrub.Validate(cfg.Scrub.Columns); err != nil {
+			return err
+		}
+
+		if where != "" {
+			for _, t := range tables {
+				if err := checkD1ExportWhere(t, where, limit); err != nil {
+					return err
+				}
+			}
+		}
+
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		scrubber := scrub.New(cfg.Scrub.Columns, key)
+
+		tmp, err := os.CreateTemp(filepath.Dir(outPath), ".gw-export-*")
+		if err != nil {
+			return fmt.Errorf("cannot write %s: %w", outPath, err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		db, err := sqlitefile.Create(tmp.Name())
+		if err != nil {
+			return fmt.Errorf("cannot write %s: %w", outPath, err)
+		}
+		defer db.Close()
+
+		location := "local"
+		if remote {
+			location = "remote"
+		}
+		var exported []d1ExportedTable
+		for _, t := range tables {
+			cols, err := d1TableColumns(dbName, remote, t)
+			if err != nil {
+				return err
+			}
+			ddl, err := d1TableDDL(dbName, remote, t)
+			if err != nil {
+				return err
+			}
+
+			plan := make([]string, len(cols))
+			if !noScrub {
+				plan = scrubber.Plan(t, cols, isProtectedD1Table(t))
+			}
+			table, err := db.CreateTable(t, cols)
+			if err != nil {
+				return err
+			}
+
+			quoted := make([]string, len(cols))
+			for i, c := range cols {
+				quoted[i] = sqlitefile.QuoteIdent(c)
+			}
+			sql := fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), t)
+			if where != "" {
+				sql += " WHERE " + where
+			}
+			sql += fmt.Sprintf(" LIMIT %d", limit)
+			wranglerArgs := []string{"d1", "execute", dbName, "--json", "--command", sql}
+			if remote {
+				wranglerArgs = append(wranglerArgs, "--remote")
+			}
+
+			n, err := pipeD1Rows(wranglerArgs, &d1ExportWriter{table: table, plan: plan, scrubber: scrubber})
+			if err != nil {
+				return fmt.Errorf("%s: %w", t, err)
+			}
+			if err := table.Close(); err != nil {
+				return err
+			}
+
+			e := d1ExportedTable{
+				Table: t, Columns: cols, CreateSQL: ddl, Where: where, Rows: n,
+				Scrubbed: map[string]string{}, Truncated: n == limit,
+				Database: dbName, Location: location,
+			}
+			for i, strategy := range plan {
+				if strategy != "" {
+					e.Scrubbed[cols[i]] = strategy
+				}
+			}
+			exported = append(exported, e)
+		}
+
+		if err := writeD1ExportManifest(db, exported); err != nil {
+			return err
+		}
+		if err := db.Close(); err != nil {
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), outPath); err != nil {
+			return fmt.Errorf("cannot write %s: %w", outPath, err)
+		}
+
+		if cfg.JSONMode {
+			return printJSON(map[string]interface{}{
+				"database": dbName,
+				"location": location,
+				"output":   outPath,
+				"scrubbed": !noScrub,
+				"tables":   exported,
+			})
+		}
+
+		headers := []string{"Table", "Rows", "Scrubbed columns"}
+		var rows [][]string
+		for _, e := range exported {
+			var scrubbed []string
+			for _, c := range e.Columns {
+				if s, ok := e.Scrubbed[c]; ok {
+					scrubbed = append(scrubbed, c+" ("+s+")")
+				}
+			}
+			rows = append(rows, []string{e.Table, fmt.Sprintf("%d", e.Rows), strings.Join(scrubbed, ", ")})
+		}
+		fmt.Print(ui.RenderTable(fmt.Sprintf("Exported from %s (%s)", dbName, location), headers, rows))
+		for _, e := range exported {
+			if e.Truncated {
+				ui.Warning(fmt.Sprintf("%s stopped at --limit %d; there may be more rows", e.Table, limit))
+			}
+		}
+		if noScrub {
+			ui.Warning("Exported without scrubbing — treat this file as production data")
+		}
+		ui.Success(fmt.Sprintf("Wrote %s", outPath))
+		ui.Hint(fmt.Sprintf("Load it into local D1 with: gw d1 import %s --write", outPath))
+		return nil
+	},
+}
+
+// writeD1ExportManifest adds the _gw_export table describing each table.
+func writeD1ExportManifest(db *sqlitefile.Writer, exported []d1ExportedTable) error {
+	t, err := db.CreateTable(d1ExportManifest, d1ExportManifestColumns)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, e := range exported {
+		cols, _ := json.Marshal(e.Columns)
+		scrubbed, _ := json.Marshal(e.Scrubbed)
+		err := t.Insert([]any{
+			e.Table, string(cols), e.CreateSQL, e.Where, int64(e.Rows),
+			string(scrubbed), e.Database, e.Location, now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return t.Close()
+}
+
+// readD1ExportManifest reads the _gw_export table of an export file.
+func readD1ExportManifest(r *sqlitefile.Reader) ([]d1ExportedTable, error) {
+	var exported []d1ExportedTable
+	err := r.Scan(d1ExportManifest, func(_ int64, v []any) error {
+		if len(v) < len(d1ExportManifestColumns) {
+			return fmt.Errorf("%s has %d columns, expected %d", d1ExportManifest, len(v), len(d1ExportManifestColumns))
+		}
+		e := d1ExportedTable{Scrubbed: map[string]string{}}
+		e.Table, _ = v[0].(string)
+		cols, _ := v[1].(string)
+		e.CreateSQL, _ = v[2].(string)
+		e.Where, _ = v[3].(string)
+		rows, _ := v[4].(int64)
+		e.Rows = int(rows)
+		scrubbed, _ := v[5].(string)
+		e.Database, _ = v[6].(string)
+		e.Location, _ = v[7].(string)
+		if err := json.Unmarshal([]byte(cols), &e.Columns); err != nil {
+			return fmt.Errorf("%s: bad columns for %s", d1ExportManifest, e.Table)
+		}
+		json.Unmarshal([]byte(scrubbed), &e.Scrubbed)
+		exported = append(exported, e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("not a gw d1 export: %w", err)
+	}
+	return exported, nil
+}
+
+// createIfNotExists makes CREATE TABLE and CREATE INDEX statements safe to
+// run against a database that may already have them.
+var createIfNotExists = regexp.MustCompile(`(?i)^\s*CREATE\s+(TABLE|INDEX|UNIQUE\s+INDEX)\s+(IF\s+NOT\s+EXISTS\s+)?`)
+
+// d1ImportBatchBytes caps the size of one INSERT statement.
+const d1ImportBatchBytes = 90 * 1024
+
+// d1ImportDDL returns an exported table's schema statements, each made
+// CREATE ... IF NOT EXISTS. The export file may come from anywhere, so
+// only CREATE TABLE and CREATE INDEX statements for the table itself are
+// accepted.
+func d1ImportDDL(e d1ExportedTable) ([]string, error) {
+	script, err := sqlparse.Parse(e.CreateSQL)
+	if err != nil {
+		return nil, fmt.Errorf("unreadable schema in export: %w", err)
+	}
+	table := strings.ToLower(e.Table)
+	var stmts []string
+	for _, s := range script.Statements {
+		text := strings.TrimSuffix(strings.TrimSpace(s.Text), ";")
+		m := createIfNotExists.FindStringSubmatch(text)
+		if m == nil || s.Kind != "CREATE" || s.Explain || len(s.Tables) != 1 || s.Tables[0] != table ||
+			strings.EqualFold(m[1], "TABLE") && s.Target != table {
+			return nil, fmt.Errorf("export schema may only create the %s table and its indexes, found: %s", e.Table, text)
+		}
+		stmts = append(stmts, createIfNotExists.ReplaceAllString(text, "CREATE $1 IF NOT EXISTS "))
+	}
+	return stmts, nil
+}
+
+// writeD1ImportSQL writes the statements that load one exported table.
+func writeD1ImportSQL(f *os.File, r *sqlitefile.Reader, e d1ExportedTable, replace bool) error {
+	ddl, err := d1ImportDDL(e)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range ddl {
+		fmt.Fprintf(f, "%s;\n", stmt)
+	}
+	if replace {
+		fmt.Fprintf(f, "DELETE FROM %s;\n", sqlitefile.QuoteIdent(e.Table))
+	}
+
+	quoted := make([]string, len(e.Columns))
+	for i, c := range e.Columns {
+		quoted[i] = sqlitefile.QuoteIdent(c)
+	}
+	prefix := fmt.Sprintf("INSERT OR IGNORE INTO %s (%s) VALUES\n", sqlitefile.QuoteIdent(e.Table), strings.Join(quoted, ", "))
+
+	var batch strings.Builder
+	flush := func() error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		_, err := fmt.Fprintf(f, "%s%s;\n", prefix, batch.String())
+		batch.Reset()
+		return err
+	}
+	err = r.Scan(e.Table, func(_ int64, v []any) error {
+		lits := make([]string, len(v))
+		for i, x := range v {
+			lits[i] = sqlitefile.Literal(x)
+		}
+		tuple := "(" + strings.Join(lits, ", ") + ")"
+		if batch.Len() > 0 && batch.Len()+len(tuple) > d1ImportBatchBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if batch.Len() > 0 {
+			batch.WriteString(",\n")
+		}
+		batch.WriteString(tuple)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// d1LocalWorkDir is where wrangler runs for an alias's local state: the
+// alias's migrations_dir, which holds the wrangler.toml gw dev uses, or
+// the current directory when none is configured.
+func d1LocalWorkDir(dbAlias string) (string, error) {
+	cfg := config.Get()
+	db, ok := cfg.Databases[dbAlias]
+	if !ok || db.MigrationsDir == "" {
+		return "", nil
+	}
+	dir := filepath.Join(cfg.GroveRoot, db.MigrationsDir)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("migrations_dir for '%s' not found: %s", dbAlias, dir)
+	}
+	return dir, nil
+}
+
+// d1LocalCount counts the rows of a local table; a missing table has none.
+func d1LocalCount(workDir, dbName, table string) (int, error) {
+	output, err := exec.WranglerInDirOutput(workDir, "d1", "execute", dbName, "--local", "--json",
+		"--command", fmt.Sprintf("SELECT COUNT(*) AS n FROM %s", sqlitefile.QuoteIdent(table)))
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return 0, nil
+		}
+		return 0, err
+	}
+	rows := parseD1Results(output)
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	n, _ := rows[0]["n"].(float64)
+	return int(n), nil
+}
+
+// --- d1 import ---
+
+var d1ImportCmd = &cobra.Command{
+	Use:   "import <file.sqlite>",
+	Short: "Load a gw d1 export into the local D1 database",
+	Long: `Load tables from a gw d1 export file into local D1 state.
+
+Wrangler runs from the alias's migrations_dir, so the rows land where
+wrangler dev and gw dev look. Missing tables and indexes are created from
+the exported schema. Rows whose key already exists are skipped; --replace
+empties each table first, which counts against safety.max_delete_rows
+like any DELETE (--force bypasses the limit).
+
+Protected tables need --include-protected here too. Import is a write and
+needs --write.
+
+  gw d1 import dev.sqlite --write
+  gw d1 import dev.sqlite --table posts --replace --write`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		path := args[0]
+		dbAlias, _ := cmd.Flags().GetString("db")
+		only, _ := cmd.Flags().GetStringSlice("table")
+		replace, _ := cmd.Flags().GetBool("replace")
+		includeProtected, _ := cmd.Flags().GetBool("include-protected")
+
+		dbName, err := resolveDatabase(dbAlias)
+		if err != nil {
+			return err
+		}
+		r, err := sqlitefile.Open(path)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		exported, err := readD1ExportManifest(r)
+		if err != nil {
+			return err
+		}
+
+		var selected []d1ExportedTable
+		for _, e := range exported {
+			if len(only) > 0 && !containsFold(only, e.Table) {
+				continue
+			}
+			if !isValidIdentifier(e.Table) {
+				return fmt.Errorf("invalid table name in export: %q", e.Table)
+			}
+			if _, err := d1ImportDDL(e); err != nil {
+				return fmt.Errorf("%s: %w", e.Table, err)
+			}
+			if isProtectedD1Table(e.Table) {
+				if !includeProtected {
+					return fmt.Errorf("table '%s' is protected — pass --include-protected to import it", e.Table)
+				}
+				if len(e.Scrubbed) == 0 && !cfg.JSONMode {
+					ui.Warning(fmt.Sprintf("%s was exported without scrubbing", e.Table))
+				}
+			}
+			selected = append(selected, e)
+		}
+		for _, t := range only {
+			found := false
+			for _, e := range exported {
+				found = found || strings.EqualFold(e.Table, t)
+			}
+			if !found {
+				return fmt.Errorf("no table '%s' in %s", t, path)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("%s has no tables to import", path)
+		}
+
+		if err := requireCFSafetyTarget("d1_import", safety.Target{Database: dbAlias}); err != nil {
+			return err
+		}
+
+		workDir, err := d1LocalWorkDir(dbAlias)
+		if err != nil {
+			return err
+		}
+		if replace && !cfg.ForceFlag {
+			var counts []d1RowCount
+			for _, e := range selected {
+				n, err := d1LocalCount(workDir, dbName, e.Table)
+				c := d1RowCount{Operation: "DELETE", Table: e.Table, Rows: n, Exact: err == nil}
+				if err != nil {
+					c.Rows, c.CountError = e.Rows, err.Error()
+				}
+				counts = append(counts, c)
+			}
+			if err := enforceD1RowLimits("d1_import", dbAlias, counts); err != nil {
+				return err
+			}
+		}
+
+		sqlFile, err := os.CreateTemp("", "gw-d1-import-*.sql")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(sqlFile.Name())
+		defer sqlFile.Close()
+		total := 0
+		for _, e := range selected {
+			if err := writeD1ImportSQL(sqlFile, r, e, replace); err != nil {
+				return fmt.Errorf("%s: %w", e.Table, err)
+			}
+			total += e.Rows
+		}
+		if err := sqlFile.Close(); err != nil {
+			return err
+		}
+
+		result, err := exec.WranglerInDir(workDir, "d1", "execute", dbName, "--local", "--file", sqlFile.Name())
+		if err != nil {
+			return fmt.Errorf("wrangler error: %w", err)
+		}
+		if !result.OK() {
+			return fmt.Errorf("import failed (exit %d):\n%s", result.ExitCode,
+				strings.TrimSpace(result.Stderr+"\n"+result.Stdout))
+		}
+
+		if cfg.JSONMode {
+			return printJSON(map[string]interface{}{
+				"database": dbName,
+				"file":     path,
+				"replace":  replace,
+				"rows":     total,
+				"tables":   selected,
+			})
+		}
+		headers := []string{"Table", "Rows", "Scrubbed columns"}
+		var rows [][]string
+		for _, e := range selected {
+			var scrubbed []string
+			for _, c := range e.Columns {
+				if s, ok := e.Scrubbed[c]; ok {
+					scrubbed = append(scrubbed, c+" ("+s+")")
+				}
+			}
+			rows = append(rows, []string{e.Table, fmt.Sprintf("%d", e.Rows), strings.Join(scrubbed, ", ")})
+		}
+		fmt.Print(ui.RenderTable(fmt.Sprintf("Imported into %s (local)", dbName), headers, rows))
+		ui.Success(fmt.Sprintf("Loaded %d rows from %s", total, path))
+		return nil
+	},
+}
+
+// containsFold reports whether list holds s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	d1ExportCmd.Flags().StringP("db", "d", "lattice", "Database alias or name")
+	d1ExportCmd.Flags().Bool("remote", false, "Export from the remote (production) database")
+	d1ExportCmd.Flags().StringSliceP("table", "t", nil, "Table to export (repeatable)")
+	d1ExportCmd.Flags().String("where", "", "Filter applied to every table, e.g. \"tenant_id = 't_123'\"")
+	d1ExportCmd.Flags().IntP("limit", "n", 1000, "Maximum rows per table")
+	d1ExportCmd.Flags().StringP("out", "o", "", "SQLite file to write")
+	d1ExportCmd.Flags().Bool("include-protected", false, "Allow tables in safety.protected_tables (scrubbed)")
+	d1ExportCmd.Flags().Bool("no-scrub", false, "Export real values (protected tables need --write --force)")
+	d1Cmd.AddCommand(d1ExportCmd)
+
+	d1ImportCmd.Flags().StringP("db", "d", "lattice", "Database alias or name")
+	d1ImportCmd.Flags().StringSliceP("table", "t", nil, "Only import these tables (repeatable)")
+	d1ImportCmd.Flags().Bool("replace", false, "Empty each table before loading it")
+	d1ImportCmd.Flags().Bool("include-protected", false, "Allow tables in safety.protected_tables")
+	d1Cmd.AddCommand(d1ImportCmd)
+}
@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/migrations"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// d1MigrationsFolder returns the directory holding an alias's .sql
+// migrations: the migrations_dir its wrangler.toml sets for the database,
+// or wrangler's default "migrations", under the alias's migrations_dir.
+func d1MigrationsFolder(dbAlias string) (config.Database, string, error) {
+	dbCfg, err := resolveDatabaseConfig(dbAlias)
+	if err != nil {
+		return dbCfg, "", err
+	}
+	if dbCfg.MigrationsDir == "" {
+		return dbCfg, "", fmt.Errorf("database alias '%s' has no migrations_dir configured", dbAlias)
+	}
+	workDir := filepath.Join(config.Get().GroveRoot, dbCfg.MigrationsDir)
+
+	folder := "migrations"
+	var wcfg wranglerConfig
+	if _, err := toml.DecodeFile(filepath.Join(workDir, "wrangler.toml"), &wcfg); err == nil {
+		for _, db := range wcfg.D1Databases {
+			if name, _ := db["database_name"].(string); name != dbCfg.Name {
+				continue
+			}
+			if dir, ok := db["migrations_dir"].(string); ok && dir != "" {
+				folder = dir
+			}
+		}
+	}
+
+	dir := filepath.Join(workDir, folder)
+	if _, err := os.Stat(dir); err != nil {
+		return dbCfg, "", fmt.Errorf("migrations directory not found: %s", dir)
+	}
+	return dbCfg, dir, nil
+}
+
+// d1AppliedMigrations reads wrangler's d1_migrations table. A database
+// that has never had migrations applied has no such table.
+func d1AppliedMigrations(dbName string, remote bool) ([]migrations.Applied, error) {
+	rows, err := d1Execute(dbName, remote, "SELECT id, name, applied_at FROM d1_migrations ORDER BY id")
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var applied []migrations.Applied
+	for _, row := range rows {
+		a := migrations.Applied{}
+		if id, ok := row["id"].(float64); ok {
+			a.ID = int(id)
+		}
+		a.Name, _ = row["name"].(string)
+		a.AppliedAt, _ = row["applied_at"].(string)
+		if a.Name != "" {
+			applied = append(applied, a)
+		}
+	}
+	return applied, nil
+}
+
+// d1MigrationChecksums reads the hashes gw recorded as it applied
+// migrations. A database gw never migrated has no checksum table.
+func d1MigrationChecksums(dbName string, remote bool) (map[string]migrations.Checksum, error) {
+	recorded := map[string]migrations.Checksum{}
+	rows, err := d1Execute(dbName, remote, migrations.ChecksumQuery)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return recorded, nil
+		}
+		return nil, err
+	}
+	for _, row := range rows {
+		name, _ := row["name"].(string)
+		c := migrations.Checksum{}
+		c.SHA256, _ = row["sha256"].(string)
+		c.RecordedAt, _ = row["recorded_at"].(string)
+		if name != "" {
+			recorded[name] = c
+		}
+	}
+	return recorded, nil
+}
+
+// pendingD1Migrations lists an alias's migrations that are not applied
+// yet, with the hashes they have as they are about to run.
+func pendingD1Migrations(dbAlias string, remote bool) ([]migrations.File, error) {
+	dbCfg, dir, err := d1MigrationsFolder(dbAlias)
+	if err != nil {
+		return nil, err
+	}
+	files, err := migrations.ListFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := d1AppliedMigrations(dbCfg.Name, remote)
+	if err != nil {
+		return nil, err
+	}
+	return migrations.Pending(files, applied), nil
+}
+
+// recordD1MigrationChecksums records, in the database, the hashes of the
+// pending migrations that wrangler has now applied. It returns how many
+// were recorded.
+func recordD1MigrationChecksums(dbName string, remote bool, pending []migrations.File) (int, error) {
+	applied, err := d1AppliedMigrations(dbName, remote)
+	if err != nil {
+		return 0, err
+	}
+	var ran []migrations.File
+	for _, f := range pending {
+		if len(migrations.Pending([]migrations.File{f}, applied)) == 0 {
+			ran = append(ran, f)
+		}
+	}
+	if len(ran) == 0 {
+		return 0, nil
+	}
+	if _, err := d1Execute(dbName, remote, migrations.RecordSQL(ran, time.Now())); err != nil {
+		return 0, err
+	}
+	return len(ran), nil
+}
+
+// migrationReport is the result of checking an alias's migrations.
+type migrationReport struct {
+	Database   string                 `json:"database"`
+	Location   string                 `json:"location"`
+	Directory  string                 `json:"migrations_dir"`
+	Migrations []migrations.Migration `json:"migrations"`
+	Unrecorded int                    `json:"unrecorded"`
+}
+
+// checkD1Migrations compares an alias's migration files with what has been
+// applied and the hashes recorded when it was.
+func checkD1Migrations(dbAlias string, remote bool) (*migrationReport, error) {
+	dbCfg, dir, err := d1MigrationsFolder(dbAlias)
+	if err != nil {
+		return nil, err
+	}
+	files, err := migrations.ListFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := d1AppliedMigrations(dbCfg.Name, remote)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := d1MigrationChecksums(dbCfg.Name, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	location := "local"
+	if remote {
+		location = "remote"
+	}
+	report := &migrationReport{
+		Database:   dbCfg.Name,
+		Location:   location,
+		Directory:  dir,
+		Migrations: migrations.Check(files, applied, recorded),
+	}
+	for _, m := range report.Migrations {
+		if m.Unrecorded {
+			report.Unrecorded++
+		}
+	}
+	return report, nil
+}
+
+// d1MigrationsCmd is the parent for migration inspection.
+var d1MigrationsCmd = &cobra.Command{
+	Use:   "migrations",
+	Short: "Inspect D1 migration state",
+}
+
+// --- d1 migrations status ---
+
+var d1MigrationsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List migrations as applied, pending, missing or modified",
+	Long: `List every migration for a database alias and where it stands.
+
+Files under the alias's migrations directory are matched against
+wrangler's d1_migrations table:
+
+  applied   recorded in d1_migrations
+  pending   not yet applied
+  missing   recorded as applied, but the file is gone
+  modified  applied, but the file has changed since
+
+gw d1 migrate-all records each migration's content hash as it applies
+it, in a gw_migration_checksums table in the database itself, so later
+edits to the file show as modified for everyone. Migrations applied some
+other way have no hash on record and are listed as unrecorded.
+
+With --exit-code, exits 1 unless every migration is applied and
+unchanged, so CI can fail on pending production migrations:
+
+  gw d1 migrations status --db lattice --remote --exit-code`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		dbAlias, _ := cmd.Flags().GetString("db")
+		remote, _ := cmd.Flags().GetBool("remote")
+		exitCode, _ := cmd.Flags().GetBool("exit-code")
+
+		if err := requireCFSafetyTarget("d1_migrations_status", safety.Target{Database: dbAlias}); err != nil {
+			return err
+		}
+
+		report, err := checkD1Migrations(dbAlias, remote)
+		if err != nil {
+			return err
+		}
+		pending := migrations.Count(report.Migrations, migrations.StatusPending)
+		missing := migrations.Count(report.Migrations, migrations.StatusMissing)
+		modified := migrations.Count(report.Migrations, migrations.StatusModified)
+		upToDate := pending+missing+modified == 0
+
+		if cfg.JSONMode {
+			if report.Migrations == nil {
+				report.Migrations = []migrations.Migration{}
+			}
+			if err := printJSON(map[string]interface{}{
+				"database":       report.Database,
+				"location":       report.Location,
+				"migrations_dir": report.Directory,
+				"migrations":     report.Migrations,
+				"applied":        migrations.Count(report.Migrations, migrations.StatusApplied),
+				"pending":        pending,
+				"missing":        missing,
+				"modified":       modified,
+				"unrecorded":     report.Unrecorded,
+				"up_to_date":     upToDate,
+			}); err != nil {
+				return err
+			}
+		} else {
+			printMigrationReport(report, pending, missing, modified)
+		}
+
+		if exitCode && !upToDate {
+			return exitStatus(1)
+		}
+		return nil
+	},
+}
+
+// printMigrationReport renders migration status for humans.
+func printMigrationReport(r *migrationReport, pending, missing, modified int) {
+	fmt.Print(ui.RenderInfoPanel("Migrations", [][2]string{
+		{"Database", r.Database + " (" + r.Location + ")"},
+		{"Directory", r.Directory},
+	}))
+
+	if len(r.Migrations) == 0 {
+		ui.Muted("No migrations found.")
+		return
+	}
+
+	headers := []string{"Migration", "Status", "Applied at"}
+	var rows [][]string
+	for _, m := range r.Migrations {
+		status := strings.ToUpper(m.Status)
+		if m.Unrecorded {
+			status += " (unrecorded)"
+		}
+		rows = append(rows, []string{m.Name, status, m.AppliedAt})
+	}
+	fmt.Print(ui.RenderTable(fmt.Sprintf("%d migrations", len(r.Migrations)), headers, rows))
+
+	if pending+missing+modified == 0 {
+		ui.Success("Up to date")
+	}
+	if pending > 0 {
+		ui.Warning(fmt.Sprintf("%d pending — apply with: gw d1 migrate-all --write", pending))
+	}
+	if missing > 0 {
+		ui.Warning(fmt.Sprintf("%d applied migration(s) have no file in %s", missing, r.Directory))
+	}
+	if modified > 0 {
+		ui.Warning(fmt.Sprintf("%d migration(s) were edited after they were applied; the database did not run the current version", modified))
+	}
+	if r.Unrecorded > 0 {
+		ui.Muted(fmt.Sprintf("%d applied migration(s) have no checksum on record (applied outside gw d1 migrate-all); edits to them cannot be detected", r.Unrecorded))
+	}
+}
+
+func init() {
+	d1MigrationsStatusCmd.Flags().StringP("db", "d", "lattice", "Database alias")
+	d1MigrationsStatusCmd.Flags().Bool("remote", false, "Check the remote (production) database")
+	d1MigrationsStatusCmd.Flags().Bool("exit-code", false, "Exit with status 1 when anything is pending, missing or modified")
+	d1MigrationsCmd.AddCommand(d1MigrationsStatusCmd)
+	d1Cmd.AddCommand(d1MigrationsCmd)
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/d1rows"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// d1FormatExtensions maps --output file extensions to formats.
+var d1FormatExtensions = map[string]string{
+	".csv":      "csv",
+	".tsv":      "tsv",
+	".tab":      "tsv",
+	".ndjson":   "ndjson",
+	".jsonl":    "ndjson",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".json":     "json",
+	".db":       "sqlite",
+	".sqlite":   "sqlite",
+	".sqlite3":  "sqlite",
+}
+
+// d1QueryFormat picks the output format for query results: --format if
+// given, else one implied by the --output file's extension, else json
+// under --json and a table otherwise.
+func d1QueryFormat(format, output string, jsonMode bool) (string, error) {
+	if format == "" && output != "" {
+		format = d1FormatExtensions[strings.ToLower(filepath.Ext(output))]
+		if format == "" {
+			return "", fmt.Errorf("cannot tell the format of %s from its extension — pass --format", output)
+		}
+	}
+	if format == "" {
+		if jsonMode {
+			return "json", nil
+		}
+		return "table", nil
+	}
+
+	known := format == "table"
+	for _, f := range d1rows.Formats {
+		known = known || f == format
+	}
+	if !known {
+		return "", fmt.Errorf("unknown format %q (want table, %s)", format, strings.Join(d1rows.Formats, ", "))
+	}
+	if format == "table" && output != "" {
+		return "", fmt.Errorf("--format table is for the terminal; pick another format for --output")
+	}
+	if format == "sqlite" && output == "" {
+		return "", fmt.Errorf("--format sqlite writes a database file and needs --output")
+	}
+	return format, nil
+}
+
+// d1TableWriter collects rows for ui.RenderTable, which needs them all to
+// size its columns.
+type d1TableWriter struct {
+	cols []string
+	rows [][]string
+}
+
+func (t *d1TableWriter) Columns(cols []string) error {
+	t.cols = cols
+	return nil
+}
+
+func (t *d1TableWriter) Row(values []any) error {
+	cells := make([]string, len(values))
+	for i, v := range values {
+		if n, ok := v.(json.Number); ok {
+			v = n.String()
+		}
+		cells[i] = formatD1Value(v)
+	}
+	t.rows = append(t.rows, cells)
+	return nil
+}
+
+func (t *d1TableWriter) Close() error { return nil }
+
+// streamD1Query runs a wrangler d1 execute --json invocation and streams
+// its rows in format to output, or stdout when output is empty. A file is
+// written next to output and renamed into place once complete, so a failed
+// query never leaves half a file behind. table names the SQLite table.
+func streamD1Query(dbName string, wranglerArgs []string, format, output, table string) (int, error) {
+	if format == "table" {
+		tw := &d1TableWriter{}
+		n, err := pipeD1Rows(wranglerArgs, tw)
+		if err != nil {
+			return n, err
+		}
+		if n == 0 {
+			ui.Muted("No results")
+			return 0, nil
+		}
+		fmt.Print(ui.RenderTable(fmt.Sprintf("Query Results (%d rows)", n), tw.cols, tw.rows))
+		return n, nil
+	}
+
+	var dest io.Writer = os.Stdout
+	var tmp *os.File
+	if output != "" {
+		var err error
+		tmp, err = os.CreateTemp(filepath.Dir(output), ".gw-query-*")
+		if err != nil {
+			return 0, fmt.Errorf("cannot write %s: %w", output, err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		dest = tmp
+	}
+
+	var w d1rows.Writer
+	switch format {
+	case "json":
+		w = d1rows.NewJSONWriter(dest, dbName)
+	case "sqlite":
+		var err error
+		if w, err = d1rows.NewSQLiteWriter(tmp.Name(), table); err != nil {
+			return 0, err
+		}
+	default:
+		var err error
+		if w, err = d1rows.NewWriter(format, dest); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := pipeD1Rows(wranglerArgs, w)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || tmp == nil {
+		return n, err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		return n, err
+	}
+	if err := tmp.Close(); err != nil {
+		return n, err
+	}
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		return n, fmt.Errorf("cannot write %s: %w", output, err)
+	}
+	return n, nil
+}
+
+// pipeD1Rows runs wrangler and decodes its rows into w as they arrive.
+func pipeD1Rows(wranglerArgs []string, w d1rows.Writer) (int, error) {
+	var n int
+	var decodeErr error
+	err := exec.WranglerPipe(func(r io.Reader) error {
+		n, decodeErr = d1rows.Decode(r, w)
+		return decodeErr
+	}, wranglerArgs...)
+	if err != nil && err != decodeErr {
+		return n, fmt.Errorf("wrangler error: %w", err)
+	}
+	return n, err
+}
+
+// reportD1Output confirms a query written to a file. Results streamed to
+// stdout are the output themselves and get no summary.
+func reportD1Output(dbName, format, output string, rows int) error {
+	if config.Get().JSONMode {
+		return printJSON(map[string]interface{}{
+			"database": dbName,
+			"format":   format,
+			"output":   output,
+			"rows":     rows,
+		})
+	}
+	ui.Success(fmt.Sprintf("Wrote %d rows to %s (%s)", rows, output, format))
+	return nil
+}
@@ -0,0 +1,709 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/d1rows"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/d1schema"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/d1shell"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// d1ShellResult is the rows of the last statement, kept for .export.
+type d1ShellResult struct {
+	cols []string
+	rows [][]any
+}
+
+func (r *d1ShellResult) Columns(cols []string) error {
+	r.cols = cols
+	return nil
+}
+
+func (r *d1ShellResult) Row(values []any) error {
+	r.rows = append(r.rows, values)
+	return nil
+}
+
+func (r *d1ShellResult) Close() error { return nil }
+
+// render lays the rows out with ui.RenderTable.
+func (r *d1ShellResult) render(title string) string {
+	tw := &d1TableWriter{cols: r.cols}
+	for _, row := range r.rows {
+		tw.Row(row)
+	}
+	return ui.RenderTable(title, tw.cols, tw.rows)
+}
+
+// d1ShellDoneMsg carries what a statement or dot command printed. A nil
+// result leaves the previous one in place for .export.
+type d1ShellDoneMsg struct {
+	output string
+	result *d1ShellResult
+}
+
+// d1ShellSession is what the shell knows about its database. Statements
+// run in tea.Cmd goroutines, so it is read-only once the shell starts.
+type d1ShellSession struct {
+	dbAlias string
+	dbName  string
+	remote  bool
+	limit   int
+	schema  *d1schema.Schema
+	catalog *d1shell.Catalog
+}
+
+// loadD1ShellSession reads sqlite_master for completion and .schema.
+func loadD1ShellSession(dbAlias, dbName string, remote bool, limit int) (*d1ShellSession, error) {
+	rows, err := d1Execute(dbName, remote, d1schema.Query)
+	if err != nil {
+		return nil, err
+	}
+	s := &d1ShellSession{dbAlias: dbAlias, dbName: dbName, remote: remote, limit: limit}
+	s.schema = d1schema.FromRows(rows)
+	columns := map[string][]string{}
+	for _, o := range s.schema.Objects {
+		switch o.Type {
+		case "table":
+			columns[o.Name] = d1schema.Columns(o.SQL)
+		case "view":
+			columns[o.Name] = nil
+		}
+	}
+	s.catalog = d1shell.NewCatalog(columns)
+	return s, nil
+}
+
+// runSQL runs one statement the way gw d1 query does: ValidateSQL, then
+// for a write the write tier and the row limits, then LIMIT on a SELECT.
+func (s *d1ShellSession) runSQL(sqlStr string) d1ShellDoneMsg {
+	stmt, isMutation, err := checkD1Query(s.dbAlias, sqlStr)
+	if err != nil {
+		return d1ShellError(err)
+	}
+	if err := authorizeD1Query(s.dbAlias, s.dbName, s.remote, stmt, isMutation); err != nil {
+		return d1ShellError(err)
+	}
+	sqlStr = limitD1Select(stmt, sqlStr, s.limit)
+
+	res, err := s.collect(sqlStr)
+	if err != nil {
+		return d1ShellError(err)
+	}
+	switch {
+	case isMutation && config.Get().DryRun:
+		return d1ShellDoneMsg{output: ui.WarningStyle.Render("⊘ dry-run — not applied")}
+	case len(res.rows) > 0:
+		title := fmt.Sprintf("%d rows", len(res.rows))
+		if len(res.rows) == s.limit && stmt != nil && stmt.Kind == "SELECT" && !stmt.HasLimit {
+			title += fmt.Sprintf(" (limit %d)", s.limit)
+		}
+		return d1ShellDoneMsg{output: strings.TrimRight(res.render(title), "\n"), result: res}
+	case isMutation:
+		return d1ShellDoneMsg{output: ui.SuccessStyle.Render("✓ done"), result: res}
+	}
+	return d1ShellDoneMsg{output: browseHintStyle.Render("No results"), result: res}
+}
+
+// collect runs sqlStr, already checked, and gathers its rows.
+func (s *d1ShellSession) collect(sqlStr string) (*d1ShellResult, error) {
+	args := []string{"d1", "execute", s.dbName, "--json", "--command", sqlStr}
+	if s.remote {
+		args = append(args, "--remote")
+	}
+	res := &d1ShellResult{}
+	if _, err := pipeD1Rows(args, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func d1ShellError(err error) d1ShellDoneMsg {
+	return d1ShellDoneMsg{output: ui.ErrorStyle.Render("✗ " + err.Error())}
+}
+
+// --- Dot commands ---
+
+const d1ShellHelp = `  .tables                    list tables and views
+  .schema [table]            CREATE statements, all or for one table
+  .explain <select>          the query plan of a statement
+  .export <format> <file>    write the last result (csv, tsv, ndjson, markdown, json, sqlite)
+  .help                      this help
+  .quit / .exit              leave (or ctrl+d on an empty line)
+
+  Statements run when they end with ';'. enter on an unfinished statement
+  starts a new line; tab completes tables, columns and keywords; up/down
+  walk the history; ctrl+c clears the buffer.`
+
+// meta runs a dot command. quit reports .quit and .exit.
+func (s *d1ShellSession) meta(line string, last *d1ShellResult) (msg d1ShellDoneMsg, quit bool) {
+	line = strings.TrimSuffix(strings.TrimSpace(line), ";")
+	name, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	switch name {
+	case ".quit", ".exit":
+		return d1ShellDoneMsg{}, true
+	case ".help":
+		return d1ShellDoneMsg{output: d1ShellHelp}, false
+	case ".tables":
+		return s.tables(), false
+	case ".schema":
+		return s.showSchema(rest), false
+	case ".explain":
+		if rest == "" {
+			return d1ShellError(fmt.Errorf("usage: .explain <select>")), false
+		}
+		return s.explain(rest), false
+	case ".export":
+		return s.export(rest, last), false
+	}
+	return d1ShellError(fmt.Errorf("unknown command %s (try .help)", name)), false
+}
+
+func (s *d1ShellSession) tables() d1ShellDoneMsg {
+	if len(s.catalog.Tables) == 0 {
+		return d1ShellDoneMsg{output: browseHintStyle.Render("No tables")}
+	}
+	var rows [][]string
+	for _, name := range s.catalog.Tables {
+		kind := "table"
+		if _, ok := s.schema.Objects["view:"+strings.ToLower(name)]; ok {
+			kind = "view"
+		}
+		rows = append(rows, []string{name, kind, fmt.Sprintf("%d", len(s.catalog.Columns(name)))})
+	}
+	out := ui.RenderTable(fmt.Sprintf("Tables in %s", s.dbName), []string{"Name", "Type", "Columns"}, rows)
+	return d1ShellDoneMsg{output: strings.TrimRight(out, "\n")}
+}
+
+// showSchema prints the stored CREATE statements of table and its indexes
+// and triggers, or of everything.
+func (s *d1ShellSession) showSchema(table string) d1ShellDoneMsg {
+	var objs []*d1schema.Object
+	for _, o := range s.schema.Objects {
+		if table == "" || strings.EqualFold(o.Table, table) {
+			objs = append(objs, o)
+		}
+	}
+	if len(objs) == 0 {
+		if table == "" {
+			return d1ShellDoneMsg{output: browseHintStyle.Render("No schema")}
+		}
+		return d1ShellError(fmt.Errorf("no table %s", table))
+	}
+	order := map[string]int{"table": 0, "view": 1, "index": 2, "trigger": 3}
+	sort.Slice(objs, func(i, j int) bool {
+		a, b := objs[i], objs[j]
+		if !strings.EqualFold(a.Table, b.Table) {
+			return strings.ToLower(a.Table) < strings.ToLower(b.Table)
+		}
+		if order[a.Type] != order[b.Type] {
+			return order[a.Type] < order[b.Type]
+		}
+		return a.Name < b.Name
+	})
+	var b strings.Builder
+	for i, o := range objs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(strings.TrimSpace(o.SQL) + ";")
+	}
+	return d1ShellDoneMsg{output: b.String()}
+}
+
+// explain runs EXPLAIN QUERY PLAN through the same checks as any statement
+// and draws the plan as a tree.
+func (s *d1ShellSession) explain(sqlStr string) d1ShellDoneMsg {
+	sqlStr = "EXPLAIN QUERY PLAN " + strings.TrimSpace(sqlStr)
+	if _, _, err := checkD1Query(s.dbAlias, sqlStr); err != nil {
+		return d1ShellError(err)
+	}
+	res, err := s.collect(sqlStr)
+	if err != nil {
+		return d1ShellError(err)
+	}
+	plan := d1QueryPlan(res)
+	if plan == "" {
+		return d1ShellDoneMsg{output: browseHintStyle.Render("No plan")}
+	}
+	return d1ShellDoneMsg{output: browseHeaderStyle.Render("QUERY PLAN") + "\n" + plan}
+}
+
+// d1QueryPlan draws EXPLAIN QUERY PLAN rows (id, parent, detail) as a tree.
+func d1QueryPlan(res *d1ShellResult) string {
+	idx := map[string]int{}
+	for i, c := range res.cols {
+		idx[c] = i
+	}
+	field := func(row []any, col string) string {
+		i, ok := idx[col]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return d1rows.Text(row[i])
+	}
+	children := map[string][]string{}
+	detail := map[string]string{}
+	var order []string
+	for _, row := range res.rows {
+		id := field(row, "id")
+		children[field(row, "parent")] = append(children[field(row, "parent")], id)
+		detail[id] = field(row, "detail")
+		order = append(order, id)
+	}
+	roots := children["0"]
+	if len(roots) == 0 {
+		roots = order
+	}
+
+	var b strings.Builder
+	var walk func(ids []string, indent string)
+	walk = func(ids []string, indent string) {
+		for i, id := range ids {
+			branch, next := "├── ", "│   "
+			if i == len(ids)-1 {
+				branch, next = "└── ", "    "
+			}
+			b.WriteString(indent + branch + detail[id] + "\n")
+			if id != "0" {
+				walk(children[id], indent+next)
+			}
+		}
+	}
+	walk(roots, "")
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// export writes the last result to a file: ".export csv out.csv", or
+// ".export out.csv" to take the format from the extension.
+func (s *d1ShellSession) export(args string, last *d1ShellResult) d1ShellDoneMsg {
+	fields := strings.Fields(args)
+	var format, path string
+	switch len(fields) {
+	case 1:
+		path = fields[0]
+	case 2:
+		format, path = fields[0], fields[1]
+	default:
+		return d1ShellError(fmt.Errorf("usage: .export [format] <file>"))
+	}
+	format, err := d1QueryFormat(format, path, false)
+	if err != nil {
+		return d1ShellError(err)
+	}
+	if last == nil {
+		return d1ShellError(fmt.Errorf("nothing to export yet; run a query first"))
+	}
+	table := "results"
+	if len(fields) == 2 || filepath.Ext(path) != "" {
+		table = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if !isValidIdentifier(table) {
+		table = "results"
+	}
+	if err := writeD1ShellResult(last, format, path, s.dbName, table); err != nil {
+		return d1ShellError(err)
+	}
+	return d1ShellDoneMsg{output: ui.SuccessStyle.Render(fmt.Sprintf("✓ Wrote %d rows to %s (%s)", len(last.rows), path, format))}
+}
+
+// writeD1ShellResult writes res next to path and renames it into place, as
+// gw d1 query --output does.
+func writeD1ShellResult(res *d1ShellResult, format, path, dbName, table string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gw-export-*")
+	if err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var w d1rows.Writer
+	switch format {
+	case "json":
+		w = d1rows.NewJSONWriter(tmp, dbName)
+	case "sqlite":
+		if w, err = d1rows.NewSQLiteWriter(tmp.Name(), table); err != nil {
+			return err
+		}
+	default:
+		if w, err = d1rows.NewWriter(format, tmp); err != nil {
+			return err
+		}
+	}
+	if err := w.Columns(res.cols); err != nil {
+		return err
+	}
+	for _, row := range res.rows {
+		if err := w.Row(row); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	return nil
+}
+
+// --- Model ---
+
+type d1ShellModel struct {
+	session    *d1ShellSession
+	history    *d1shell.History
+	buf        []rune
+	pos        int
+	histIdx    int    // index into history; len(Entries) is the draft
+	draft      []rune // the buffer as it was before walking the history
+	candidates []string
+	last       *d1ShellResult
+	busy       bool
+	quitting   bool
+}
+
+func (m d1ShellModel) Init() tea.Cmd {
+	return tea.Println(browseHeaderStyle.Render("🌿 gw d1 shell — "+m.session.dbName) + " " +
+		browseHintStyle.Render(m.location()+" • .help for commands • ctrl+d to quit"))
+}
+
+func (m d1ShellModel) location() string {
+	if m.session.remote {
+		return "remote"
+	}
+	return "local"
+}
+
+func (m d1ShellModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case d1ShellDoneMsg:
+		m.busy = false
+		if msg.result != nil {
+			m.last = msg.result
+		}
+		if msg.output == "" {
+			return m, nil
+		}
+		return m, tea.Println(msg.output)
+
+	case tea.KeyMsg:
+		if m.busy {
+			return m, nil
+		}
+		m.candidates = nil
+		switch msg.String() {
+		case "ctrl+d":
+			if len(m.buf) == 0 {
+				m.quitting = true
+				return m, tea.Quit
+			}
+			if m.pos < len(m.buf) {
+				m.buf = append(m.buf[:m.pos], m.buf[m.pos+1:]...)
+			}
+		case "ctrl+c":
+			if len(m.buf) == 0 {
+				m.quitting = true
+				return m, tea.Quit
+			}
+			m.setBuffer(nil)
+			m.histIdx = len(m.history.Entries)
+		case "enter":
+			if d1shell.Ready(string(m.buf)) {
+				return m.submit()
+			}
+			m.insert('\n')
+		case "alt+enter", "ctrl+j":
+			m.insert('\n')
+		case "tab":
+			m.complete()
+		case "backspace", "ctrl+h":
+			if m.pos > 0 {
+				m.buf = append(m.buf[:m.pos-1], m.buf[m.pos:]...)
+				m.pos--
+			}
+		case "delete":
+			if m.pos < len(m.buf) {
+				m.buf = append(m.buf[:m.pos], m.buf[m.pos+1:]...)
+			}
+		case "ctrl+w", "alt+backspace":
+			start := m.pos
+			for start > 0 && m.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && m.buf[start-1] != ' ' && m.buf[start-1] != '\n' {
+				start--
+			}
+			m.buf = append(m.buf[:start], m.buf[m.pos:]...)
+			m.pos = start
+		case "ctrl+u":
+			start := m.lineStart(m.pos)
+			m.buf = append(m.buf[:start], m.buf[m.pos:]...)
+			m.pos = start
+		case "left", "ctrl+b":
+			if m.pos > 0 {
+				m.pos--
+			}
+		case "right", "ctrl+f":
+			if m.pos < len(m.buf) {
+				m.pos++
+			}
+		case "home", "ctrl+a":
+			m.pos = m.lineStart(m.pos)
+		case "end", "ctrl+e":
+			m.pos = m.lineEnd(m.pos)
+		case "up", "ctrl+p":
+			if start := m.lineStart(m.pos); start > 0 {
+				m.pos = m.column(m.lineStart(start-1), m.pos-start)
+			} else {
+				m.recall(-1)
+			}
+		case "down", "ctrl+n":
+			if end := m.lineEnd(m.pos); end < len(m.buf) {
+				m.pos = m.column(end+1, m.pos-m.lineStart(m.pos))
+			} else {
+				m.recall(1)
+			}
+		default:
+			if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+				for _, r := range msg.Runes {
+					if r == '\r' {
+						r = '\n'
+					}
+					m.insert(r)
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *d1ShellModel) insert(r rune) {
+	m.buf = append(m.buf[:m.pos], append([]rune{r}, m.buf[m.pos:]...)...)
+	m.pos++
+}
+
+func (m *d1ShellModel) setBuffer(buf []rune) {
+	m.buf = append([]rune(nil), buf...)
+	m.pos = len(m.buf)
+}
+
+func (m d1ShellModel) lineStart(pos int) int {
+	for pos > 0 && m.buf[pos-1] != '\n' {
+		pos--
+	}
+	return pos
+}
+
+func (m d1ShellModel) lineEnd(pos int) int {
+	for pos < len(m.buf) && m.buf[pos] != '\n' {
+		pos++
+	}
+	return pos
+}
+
+// column returns the position col runes into the line starting at start,
+// or the end of that line if it is shorter.
+func (m d1ShellModel) column(start, col int) int {
+	return min(start+col, m.lineEnd(start))
+}
+
+// recall steps through the history; dir -1 is older.
+func (m *d1ShellModel) recall(dir int) {
+	entries := m.history.Entries
+	next := m.histIdx + dir
+	if next < 0 || next > len(entries) {
+		return
+	}
+	if m.histIdx == len(entries) {
+		m.draft = append([]rune(nil), m.buf...)
+	}
+	m.histIdx = next
+	if next == len(entries) {
+		m.setBuffer(m.draft)
+		return
+	}
+	m.setBuffer([]rune(entries[next]))
+}
+
+// complete replaces the word before the cursor with its only candidate, or
+// with the prefix all candidates share and lists them.
+func (m *d1ShellModel) complete() {
+	start, cands := m.session.catalog.Complete(string(m.buf), m.pos)
+	if len(cands) == 0 {
+		return
+	}
+	word := m.buf[start:m.pos]
+	repl := []rune(d1shell.CommonPrefix(cands))
+	if len(cands) == 1 {
+		repl = append(repl, ' ')
+	} else {
+		m.candidates = cands
+	}
+	if len(repl) < len(word) {
+		return
+	}
+	rest := append([]rune(nil), m.buf[m.pos:]...)
+	m.buf = append(append(m.buf[:start], repl...), rest...)
+	m.pos = start + len(repl)
+}
+
+// submit echoes the buffer, records it in the history and runs it.
+func (m d1ShellModel) submit() (tea.Model, tea.Cmd) {
+	input := strings.TrimSpace(string(m.buf))
+	echo := tea.Println(m.renderInput(false))
+	m.setBuffer(nil)
+	m.history.Add(input)
+	m.history.Save()
+	m.histIdx = len(m.history.Entries)
+
+	if strings.HasPrefix(input, ".") {
+		msg, quit := m.session.meta(input, m.last)
+		if quit {
+			m.quitting = true
+			return m, tea.Sequence(echo, tea.Quit)
+		}
+		// .explain reaches the database, so it runs like a statement
+		if !strings.HasPrefix(input, ".explain") {
+			if msg.result != nil {
+				m.last = msg.result
+			}
+			return m, tea.Sequence(echo, tea.Println(msg.output))
+		}
+	}
+
+	m.busy = true
+	session := m.session
+	return m, tea.Sequence(echo, func() tea.Msg {
+		if strings.HasPrefix(input, ".explain") {
+			msg, _ := session.meta(input, nil)
+			return msg
+		}
+		return session.runSQL(input)
+	})
+}
+
+// prompt is the prefix of the first line; later lines are indented to it.
+func (m d1ShellModel) prompt() string {
+	return m.session.dbName + "> "
+}
+
+// renderInput draws the buffer under its prompt, with the cursor if asked.
+func (m d1ShellModel) renderInput(cursor bool) string {
+	prompt := m.prompt()
+	cont := strings.Repeat(" ", lipgloss.Width(prompt)-5) + "...> "
+	var b strings.Builder
+	b.WriteString(browseHeaderStyle.Render(prompt))
+	for i, r := range m.buf {
+		if cursor && i == m.pos {
+			if r == '\n' {
+				b.WriteString(d1ShellCursorStyle.Render(" "))
+			} else {
+				b.WriteString(d1ShellCursorStyle.Render(string(r)))
+				continue
+			}
+		}
+		b.WriteRune(r)
+		if r == '\n' {
+			b.WriteString(browseHintStyle.Render(cont))
+		}
+	}
+	if cursor && m.pos == len(m.buf) {
+		b.WriteString(d1ShellCursorStyle.Render(" "))
+	}
+	return b.String()
+}
+
+func (m d1ShellModel) View() string {
+	if m.quitting {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(m.renderInput(!m.busy))
+	if m.busy {
+		b.WriteString("\n" + browseFilterStyle.Render("  running..."))
+	}
+	if len(m.candidates) > 0 {
+		shown := m.candidates
+		more := ""
+		if len(shown) > 20 {
+			more = fmt.Sprintf(" … %d more", len(shown)-20)
+			shown = shown[:20]
+		}
+		b.WriteString("\n" + browseHintStyle.Render("  "+strings.Join(shown, "  ")+more))
+	}
+	return b.String()
+}
+
+var d1ShellCursorStyle = lipgloss.NewStyle().Reverse(true)
+
+// --- d1 shell ---
+
+var d1ShellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Interactive SQL shell for a D1 database",
+	Long: `Open an interactive SQL shell on a D1 database.
+
+Statements may span lines and run when they end with ';'. Tab completes
+table and column names read from sqlite_master, and keywords. Each
+database keeps its own history in ~/.grove/d1_history/<database>.jsonl.
+
+Every statement is checked exactly as gw d1 query checks it: one
+statement at a time, no DDL, protected tables untouched; a write needs the
+write tier and stays within the row limits unless --force is given.
+SELECTs without a LIMIT get --limit.
+
+  .tables                    list tables and views
+  .schema [table]            CREATE statements
+  .explain <select>          the query plan as a tree
+  .export <format> <file>    write the last result to a file
+  .quit                      leave (or ctrl+d)`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbAlias, _ := cmd.Flags().GetString("db")
+		if err := requireCFSafetyTarget("d1_shell", safety.Target{Database: dbAlias}); err != nil {
+			return err
+		}
+		cfg := config.Get()
+		if !cfg.IsInteractive() || cfg.JSONMode {
+			return fmt.Errorf("gw d1 shell needs a terminal; use gw d1 query instead")
+		}
+		limit, _ := cmd.Flags().GetInt("limit")
+		remote, _ := cmd.Flags().GetBool("remote")
+		dbName, err := resolveDatabase(dbAlias)
+		if err != nil {
+			return err
+		}
+
+		session, err := loadD1ShellSession(dbAlias, dbName, remote, clampD1Limit(limit))
+		if err != nil {
+			return err
+		}
+		history, err := d1shell.LoadHistory(d1shell.HistoryPath(dbName))
+		if err != nil {
+			ui.Warning(fmt.Sprintf("Could not read shell history: %v", err))
+			history = &d1shell.History{}
+		}
+
+		m := d1ShellModel{session: session, history: history, histIdx: len(history.Entries)}
+		_, err = tea.NewProgram(m).Run()
+		return err
+	},
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
 )
 
@@ -38,7 +39,7 @@ var deployCmd = &cobra.Command{
 
 		// Dry-run doesn't need --write
 		if !dryRun {
-			if err := requireCFSafety("deploy"); err != nil {
+			if err := requireCFSafetyTarget("deploy", safety.Target{Worker: worker}); err != nil {
 				return err
 			}
 		}
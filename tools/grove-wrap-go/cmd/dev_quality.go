@@ -504,7 +504,7 @@ Supports --diagnose for structured error output.`,
 		}
 
 		if !allPassed {
-			os.Exit(1)
+			return exitStatus(1)
 		}
 		return nil
 	},
@@ -576,7 +576,7 @@ func runInPackage(cfg *config.Config, label, pkgName, dir string, cmdArgs []stri
 			if result.Stdout != "" {
 				fmt.Println(result.Stdout)
 			}
-			os.Exit(1)
+			return exitStatus(1)
 		}
 	}
 	return nil
@@ -612,7 +612,7 @@ func runMonorepoCommand(cfg *config.Config, label string, cmdArgs []string) erro
 			if result.Stderr != "" {
 				fmt.Println(result.Stderr)
 			}
-			os.Exit(1)
+			return exitStatus(1)
 		}
 	}
 	return nil
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	gwexec "github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/vault"
+)
+
+// dryRunRequested reports whether --dry-run was given. Commands with their
+// own --dry-run flag shadow the global one, so the command's flag set is
+// consulted rather than flagDryRun alone; either way the subprocess layer
+// is put in dry-run mode as a backstop.
+func dryRunRequested(cmd *cobra.Command) bool {
+	if flagDryRun {
+		return true
+	}
+	if f := cmd.Flags().Lookup("dry-run"); f != nil && f.Value.Type() == "bool" {
+		return f.Value.String() == "true"
+	}
+	return false
+}
+
+// setupDryRun switches the subprocess runners and the vault into dry-run
+// mode when it is on.
+func setupDryRun() {
+	cfg := config.Get()
+	gwexec.SetDryRun(cfg.DryRun)
+	vault.SetDryRun(cfg.DryRun)
+	gwexec.SetDryRunReporter(reportPlanned)
+}
+
+// reportPlanned shows a skipped invocation as it happens. JSON mode keeps
+// stdout for the command's own output and reports everything at the end.
+func reportPlanned(p gwexec.Planned) {
+	if config.Get().JSONMode {
+		return
+	}
+	fmt.Println(ui.WarningStyle.Render("⊘ dry-run") + " " + p.String())
+}
+
+// summarizeDryRun closes a dry run: a count for humans, or the full list of
+// skipped invocations as one JSON object on stderr.
+func summarizeDryRun() {
+	if !gwexec.DryRun() {
+		return
+	}
+	planned := gwexec.PlannedCalls()
+
+	if config.Get().JSONMode {
+		list := make([]map[string]any, 0, len(planned))
+		for _, p := range planned {
+			item := map[string]any{"argv": p.Argv()}
+			if p.Dir != "" {
+				item["dir"] = p.Dir
+			}
+			if p.StdinBytes > 0 {
+				item["stdin"] = fmt.Sprintf("[%d bytes redacted]", p.StdinBytes)
+			}
+			list = append(list, item)
+		}
+		data, _ := json.Marshal(map[string]any{"dry_run": true, "planned": list})
+		fmt.Fprintln(os.Stderr, string(data))
+		return
+	}
+
+	switch len(planned) {
+	case 0:
+		ui.Muted("Dry run: no mutating calls were made")
+	case 1:
+		ui.Muted("Dry run: 1 mutating call skipped")
+	default:
+		ui.Muted(fmt.Sprintf("Dry run: %d mutating calls skipped", len(planned)))
+	}
+}
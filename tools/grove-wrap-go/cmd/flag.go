@@ -10,6 +10,7 @@ import (
 
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/flaghistory"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
 )
 
@@ -93,11 +94,15 @@ var flagListCmd = &cobra.Command{
 			Name    string      `json:"name"`
 			Enabled bool        `json:"enabled"`
 			Value   interface{} `json:"value"`
+			Rules   string      `json:"rules"`
 		}
 		var flags []flagInfo
 
 		for _, k := range keys {
 			name := fmt.Sprintf("%v", k["name"])
+			if flaghistory.IsKey(name) {
+				continue
+			}
 			// Fetch each flag value
 			raw, err := exec.WranglerOutput("kv:key", "get", "--namespace-id", nsID, name)
 			if err != nil {
@@ -105,7 +110,7 @@ var flagListCmd = &cobra.Command{
 				continue
 			}
 			enabled, value := parseFlagEnabled(raw)
-			flags = append(flags, flagInfo{Name: name, Enabled: enabled, Value: value})
+			flags = append(flags, flagInfo{Name: name, Enabled: enabled, Value: value, Rules: flagRulesLabel(raw)})
 		}
 
 		if cfg.JSONMode {
@@ -120,14 +125,14 @@ var flagListCmd = &cobra.Command{
 			return nil
 		}
 
-		headers := []string{"Name", "Status"}
+		headers := []string{"Name", "Status", "Rules"}
 		var rows [][]string
 		for _, f := range flags {
 			status := "○ OFF"
 			if f.Enabled {
 				status = "● ON"
 			}
-			rows = append(rows, []string{f.Name, status})
+			rows = append(rows, []string{f.Name, status, f.Rules})
 		}
 		fmt.Print(ui.RenderTable("Feature Flags", headers, rows))
 
@@ -148,8 +153,8 @@ var flagGetCmd = &cobra.Command{
 			return err
 		}
 		name := args[0]
-		if err := validateCFKey(name); err != nil {
-			return fmt.Errorf("invalid flag name: %w", err)
+		if err := validateFlagName(name); err != nil {
+			return err
 		}
 
 		raw, err := exec.WranglerOutput("kv:key", "get", "--namespace-id", nsID, name)
@@ -166,10 +171,11 @@ var flagGetCmd = &cobra.Command{
 		}
 
 		enabled, value := parseFlagEnabled(raw)
+		rules := flagRulesLabel(raw)
 
 		if cfg.JSONMode {
 			result := map[string]interface{}{
-				"name": name, "found": true, "enabled": enabled, "value": value,
+				"name": name, "found": true, "enabled": enabled, "value": value, "rules": rules,
 			}
 			data, _ := json.Marshal(result)
 			fmt.Println(string(data))
@@ -182,6 +188,7 @@ var flagGetCmd = &cobra.Command{
 		}
 		ui.PrintHeader(fmt.Sprintf("Flag: %s", name))
 		ui.PrintKeyValue("  Status", status)
+		ui.PrintKeyValue("  Rules", rules)
 		if value != nil {
 			valStr := fmt.Sprintf("%v", value)
 			if len(valStr) > 40 {
@@ -211,8 +218,8 @@ var flagEnableCmd = &cobra.Command{
 			return err
 		}
 		name := args[0]
-		if err := validateCFKey(name); err != nil {
-			return fmt.Errorf("invalid flag name: %w", err)
+		if err := validateFlagName(name); err != nil {
+			return err
 		}
 		metadata, _ := cmd.Flags().GetString("metadata")
 
@@ -239,23 +246,40 @@ var flagEnableCmd = &cobra.Command{
 			flagValue["updated_at"] = time.Now().UTC().Format(time.RFC3339)
 		}
 
+		before, err := kvSnapshot(nsID, name)
+		if err != nil {
+			return fmt.Errorf("cannot snapshot %s for undo: %w", name, err)
+		}
+		carryFlagRules(before, flagValue)
+
 		valueJSON, _ := json.Marshal(flagValue)
+		value := string(valueJSON)
 
-		result, err := exec.Wrangler("kv:key", "put", "--namespace-id", nsID, name, string(valueJSON))
+		txn, err := journalKVSnapshot("gw flag enable", "flags", nsID, name, before, &value)
 		if err != nil {
+			return err
+		}
+
+		result, err := exec.Wrangler("kv:key", "put", "--namespace-id", nsID, name, value)
+		if err != nil {
+			txn.abort()
 			return fmt.Errorf("wrangler error: %w", err)
 		}
 		if !result.OK() {
+			txn.abort()
 			return fmt.Errorf("wrangler error: %s", result.Stderr)
 		}
+		txn.commit()
+		version := recordFlagChange(nsID, name, "enable", stateValue(before), &value, 0)
 
 		if cfg.JSONMode {
 			data, _ := json.Marshal(map[string]interface{}{
-				"name": name, "enabled": true, "value": flagValue,
+				"name": name, "enabled": true, "value": flagValue, "history_version": version, "undo_id": txn.id(),
 			})
 			fmt.Println(string(data))
 		} else {
 			ui.Success(fmt.Sprintf("Enabled flag: %s", name))
+			txn.printUndoHint()
 		}
 		return nil
 	},
@@ -278,31 +302,48 @@ var flagDisableCmd = &cobra.Command{
 			return err
 		}
 		name := args[0]
-		if err := validateCFKey(name); err != nil {
-			return fmt.Errorf("invalid flag name: %w", err)
+		if err := validateFlagName(name); err != nil {
+			return err
 		}
 
 		flagValue := map[string]interface{}{
 			"enabled":    false,
 			"updated_at": time.Now().UTC().Format(time.RFC3339),
 		}
+		before, err := kvSnapshot(nsID, name)
+		if err != nil {
+			return fmt.Errorf("cannot snapshot %s for undo: %w", name, err)
+		}
+		carryFlagRules(before, flagValue)
+
 		valueJSON, _ := json.Marshal(flagValue)
+		value := string(valueJSON)
 
-		result, err := exec.Wrangler("kv:key", "put", "--namespace-id", nsID, name, string(valueJSON))
+		txn, err := journalKVSnapshot("gw flag disable", "flags", nsID, name, before, &value)
 		if err != nil {
+			return err
+		}
+
+		result, err := exec.Wrangler("kv:key", "put", "--namespace-id", nsID, name, value)
+		if err != nil {
+			txn.abort()
 			return fmt.Errorf("wrangler error: %w", err)
 		}
 		if !result.OK() {
+			txn.abort()
 			return fmt.Errorf("wrangler error: %s", result.Stderr)
 		}
+		txn.commit()
+		version := recordFlagChange(nsID, name, "disable", stateValue(before), &value, 0)
 
 		if cfg.JSONMode {
 			data, _ := json.Marshal(map[string]interface{}{
-				"name": name, "enabled": false,
+				"name": name, "enabled": false, "history_version": version, "undo_id": txn.id(),
 			})
 			fmt.Println(string(data))
 		} else {
 			ui.Success(fmt.Sprintf("Disabled flag: %s", name))
+			txn.printUndoHint()
 		}
 		return nil
 	},
@@ -325,25 +366,42 @@ var flagDeleteCmd = &cobra.Command{
 			return err
 		}
 		name := args[0]
-		if err := validateCFKey(name); err != nil {
-			return fmt.Errorf("invalid flag name: %w", err)
+		if err := validateFlagName(name); err != nil {
+			return err
+		}
+
+		before, err := kvSnapshot(nsID, name)
+		if err != nil {
+			return fmt.Errorf("cannot snapshot %s for undo: %w", name, err)
+		}
+		txn, err := journalKVSnapshot("gw flag delete", "flags", nsID, name, before, nil)
+		if err != nil {
+			return err
 		}
 
 		result, err := exec.Wrangler("kv:key", "delete", "--namespace-id", nsID, name)
 		if err != nil {
+			txn.abort()
 			return fmt.Errorf("wrangler error: %w", err)
 		}
 		if !result.OK() {
+			txn.abort()
 			return fmt.Errorf("wrangler error: %s", result.Stderr)
 		}
+		txn.commit()
+		version := 0
+		if before != nil {
+			version = recordFlagChange(nsID, name, "delete", stateValue(before), nil, 0)
+		}
 
 		if cfg.JSONMode {
 			data, _ := json.Marshal(map[string]interface{}{
-				"name": name, "deleted": true,
+				"name": name, "deleted": true, "history_version": version, "undo_id": txn.id(),
 			})
 			fmt.Println(string(data))
 		} else {
 			ui.Success(fmt.Sprintf("Deleted flag: %s", name))
+			txn.printUndoHint()
 		}
 		return nil
 	},
@@ -353,10 +411,16 @@ var flagHelpCategories = []ui.HelpCategory{
 	{Title: "Read (Always Safe)", Icon: "📖", Style: ui.SafeReadStyle, Commands: []ui.HelpCommand{
 		{Name: "list", Desc: "List feature flags"},
 		{Name: "get", Desc: "Get a flag's status and value"},
+		{Name: "eval", Desc: "Evaluate a flag for a tenant"},
+		{Name: "history", Desc: "Show who changed a flag and how"},
+		{Name: "audit", Desc: "Find unused, missing and stale flags"},
 	}},
 	{Title: "Write (--write)", Icon: "✏️", Style: ui.SafeWriteStyle, Commands: []ui.HelpCommand{
 		{Name: "enable", Desc: "Enable a feature flag"},
 		{Name: "disable", Desc: "Disable a feature flag"},
+		{Name: "rollout", Desc: "Roll a flag out to a percent of tenants"},
+		{Name: "target", Desc: "Target a flag at tenants or plans"},
+		{Name: "revert", Desc: "Put a flag back to an earlier version"},
 	}},
 	{Title: "Danger (--write --force)", Icon: "⚠️", Style: ui.DangerStyle, Commands: []ui.HelpCommand{
 		{Name: "delete", Desc: "Delete a feature flag"},
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/flagaudit"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/flaghistory"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// storedFlags reads every flag in the namespace with when it last changed:
+// the newest record in its history, else the updated_at on its value.
+func storedFlags(nsID string) ([]flagaudit.Flag, error) {
+	entries, err := kvFetch(nsID, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	changed := map[string]time.Time{}
+	for _, e := range entries {
+		if !flaghistory.IsKey(e.Key) {
+			continue
+		}
+		records, err := flaghistory.Parse(e.Value)
+		if err != nil || len(records) == 0 {
+			continue
+		}
+		changed[strings.TrimPrefix(e.Key, flaghistory.KeyPrefix)] = records[len(records)-1].At
+	}
+
+	var flags []flagaudit.Flag
+	for _, e := range entries {
+		if flaghistory.IsKey(e.Key) {
+			continue
+		}
+		enabled, _ := parseFlagEnabled(e.Value)
+		at, ok := changed[e.Key]
+		if !ok {
+			at = flagaudit.UpdatedAt(e.Value)
+		}
+		flags = append(flags, flagaudit.Flag{Name: e.Key, Raw: e.Value, Enabled: enabled, Changed: at})
+	}
+	return flags, nil
+}
+
+// flagAuditWhere shortens a finding's read locations for the table.
+func flagAuditWhere(files []string) string {
+	switch len(files) {
+	case 0:
+		return "—"
+	case 1:
+		return files[0]
+	}
+	return fmt.Sprintf("%s +%d more", files[0], len(files)-1)
+}
+
+var flagAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Reconcile KV flags with the flags the code reads",
+	Long: `Cross-reference the flags in KV with the flag reads in the source, as gf
+flags finds them, and report three kinds of finding:
+
+  missing  read by the code but not in KV, so it falls back to its default
+  unused   in KV but never read by the code
+  stale    fully on or off for longer than --days, ready to remove
+
+Flags whose names are built at runtime cannot be found in the source; list
+them under [flags] ignore in gw.toml. --exit-code makes findings fail CI.`,
+	Example: `  gw flag audit
+  gw flag audit --days 60
+  gw --json flag audit --exit-code`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafety("flag_audit"); err != nil {
+			return err
+		}
+
+		cfg := config.Get()
+		days, _ := cmd.Flags().GetInt("days")
+		if !cmd.Flags().Changed("days") {
+			days = cfg.Flags.StaleDays
+		}
+		if days < 1 {
+			return fmt.Errorf("--days must be at least 1")
+		}
+		dirs, _ := cmd.Flags().GetStringArray("dir")
+		if len(dirs) == 0 {
+			dirs = cfg.Flags.SourceDirs
+		}
+		exitCode, _ := cmd.Flags().GetBool("exit-code")
+		if cfg.GroveRoot == "" {
+			return fmt.Errorf("not in a grove checkout: flag audit reads the source for flag usage")
+		}
+
+		usages, err := flagaudit.Scan(cfg.GroveRoot, dirs)
+		if err != nil {
+			return fmt.Errorf("scanning source: %w", err)
+		}
+		nsID, err := resolveFlagsNamespace()
+		if err != nil {
+			return err
+		}
+		flags, err := storedFlags(nsID)
+		if err != nil {
+			return err
+		}
+		findings := flagaudit.Audit(flags, usages, cfg.Flags.Ignore, days, time.Now())
+
+		counts := map[string]int{flagaudit.Missing: 0, flagaudit.Unused: 0, flagaudit.Stale: 0}
+		for _, f := range findings {
+			counts[f.Kind]++
+		}
+
+		if cfg.JSONMode {
+			if findings == nil {
+				findings = []flagaudit.Finding{}
+			}
+			if err := printJSON(map[string]interface{}{
+				"flags": len(flags), "reads": len(usages), "stale_days": days,
+				"counts": counts, "findings": findings,
+			}); err != nil {
+				return err
+			}
+		} else {
+			ui.PrintHeader("Flag Audit")
+			ui.PrintKeyValue("  Flags in KV", fmt.Sprintf("%d", len(flags)))
+			ui.PrintKeyValue("  Reads in code", fmt.Sprintf("%d in %s", len(usages), strings.Join(dirs, ", ")))
+			fmt.Println()
+
+			if len(findings) == 0 {
+				ui.Success(fmt.Sprintf("All flags accounted for, none settled for %d days", days))
+				return nil
+			}
+			var rows [][]string
+			for _, f := range findings {
+				rows = append(rows, []string{f.Kind, f.Flag, f.Detail, flagAuditWhere(f.Files)})
+			}
+			fmt.Print(ui.RenderTable(fmt.Sprintf("Findings (%d)", len(findings)), []string{"Kind", "Flag", "Detail", "Read In"}, rows))
+			ui.Warning(fmt.Sprintf("%d missing, %d unused, %d stale", counts[flagaudit.Missing], counts[flagaudit.Unused], counts[flagaudit.Stale]))
+			if counts[flagaudit.Stale] > 0 {
+				ui.Hint("Remove a stale flag's reads from the code, then: gw flag delete <name> --write --force")
+			}
+		}
+
+		if exitCode && len(findings) > 0 {
+			return exitStatus(1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	flagAuditCmd.Flags().Int("days", 30, "Days a flag may sit fully on or off before it is stale (default from flags.stale_days)")
+	flagAuditCmd.Flags().StringArray("dir", nil, "Source directory to search, relative to the grove root (repeatable)")
+	flagAuditCmd.Flags().Bool("exit-code", false, "Exit with status 1 when there are findings")
+	flagCmd.AddCommand(flagAuditCmd)
+}
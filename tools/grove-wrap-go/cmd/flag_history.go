@@ -0,0 +1,331 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/flaghistory"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/flagrules"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/heartwood"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/undo"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/vault"
+)
+
+// validateFlagName checks a flag name, which must be a valid key and must
+// not be one of the keys history is kept under.
+func validateFlagName(name string) error {
+	if err := validateCFKey(name); err != nil {
+		return fmt.Errorf("invalid flag name: %w", err)
+	}
+	if flaghistory.IsKey(name) {
+		return fmt.Errorf("invalid flag name: %s is reserved for flag history", flaghistory.KeyPrefix)
+	}
+	return nil
+}
+
+// changeActor names who is making a change, such as a flag edit or a
+// shared secrets bundle: the Heartwood account when its token is at hand
+// without a prompt, else the git identity, else the local user.
+func changeActor() string {
+	cfg := config.Get()
+	token := os.Getenv("GROVE_TOKEN")
+	if token == "" {
+		if v, err := vault.AutoUnlock(); err == nil {
+			token, _ = v.Get(groveTokenKey)
+		}
+	}
+	if token != "" && !cfg.NoCloud {
+		if u, err := heartwood.NewClient(cfg.Grove.AuthBaseURL, token).GetUserInfo(); err == nil && u.Email != "" {
+			return u.Email
+		}
+	}
+	if email, err := exec.GitOutput("config", "user.email"); err == nil && strings.TrimSpace(email) != "" {
+		return strings.TrimSpace(email) + " (git)"
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username + " (local)"
+	}
+	return "unknown"
+}
+
+// stateValue is the raw value of a snapshot, nil when the key was absent.
+func stateValue(s *undo.State) *string {
+	if s == nil {
+		return nil
+	}
+	return &s.Value
+}
+
+// readFlagHistory loads a flag's history.
+func readFlagHistory(nsID, name string) ([]flaghistory.Record, error) {
+	state, err := kvSnapshot(nsID, flaghistory.Key(name))
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, nil
+	}
+	return flaghistory.Parse(state.Value)
+}
+
+// recordFlagChange appends a change to a flag's history and returns its
+// version. The flag has already changed by now, so a failure is reported
+// as a warning rather than undoing the change, and the version is 0.
+func recordFlagChange(nsID, name, action string, old, new *string, to int) int {
+	warn := func(err error) int {
+		fmt.Fprintf(os.Stderr, "warning: %s changed but its history was not recorded: %v\n", name, err)
+		return 0
+	}
+	records, err := readFlagHistory(nsID, name)
+	if err != nil {
+		return warn(err)
+	}
+	records, version := flaghistory.Append(records, flaghistory.Record{
+		Action: action, Old: old, New: new, Actor: changeActor(), At: time.Now().UTC(), To: to,
+	})
+	value, err := flaghistory.Encode(records)
+	if err != nil {
+		return warn(err)
+	}
+	result, err := exec.Wrangler("kv:key", "put", "--namespace-id", nsID, flaghistory.Key(name), value)
+	if err != nil {
+		return warn(err)
+	}
+	if !result.OK() {
+		return warn(fmt.Errorf("%s", strings.TrimSpace(result.Stderr)))
+	}
+	return version
+}
+
+// describeFlagValue summarizes a flag value for the history table, such as
+// "on · 25% rollout".
+func describeFlagValue(raw *string) string {
+	if raw == nil {
+		return "absent"
+	}
+	enabled, _ := parseFlagEnabled(*raw)
+	label := "off"
+	if enabled {
+		label = "on"
+	}
+	if rules := flagRulesLabel(*raw); rules != "none" {
+		label += " · " + rules
+	}
+	return label
+}
+
+// printFlagValueDiff shows how a flag value changes, line by line.
+func printFlagValueDiff(old, new *string) {
+	text := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return kvDiffText(*v)
+	}
+	if !printTextDiff(text(old), text(new)) {
+		ui.Muted("  Value unchanged")
+	}
+}
+
+// --- flag history ---
+
+var flagHistoryCmd = &cobra.Command{
+	Use:   "history <name>",
+	Short: "Show the change history of a flag",
+	Long: `Show every change gw has made to a flag, newest first: the version, when
+and by whom, and what the flag went from and to. --diff adds the value
+change line by line. History is kept in the flags namespace under
+_history:<name>, so it survives the flag being deleted.`,
+	Example: `  gw flag history new-editor
+  gw flag history new-editor --diff --limit 3`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafety("flag_history"); err != nil {
+			return err
+		}
+
+		cfg := config.Get()
+		name := args[0]
+		if err := validateFlagName(name); err != nil {
+			return err
+		}
+		showDiff, _ := cmd.Flags().GetBool("diff")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		nsID, err := resolveFlagsNamespace()
+		if err != nil {
+			return err
+		}
+		records, err := readFlagHistory(nsID, name)
+		if err != nil {
+			return err
+		}
+
+		// Newest first
+		var newest []flaghistory.Record
+		for i := len(records) - 1; i >= 0 && (limit <= 0 || len(newest) < limit); i-- {
+			newest = append(newest, records[i])
+		}
+
+		if cfg.JSONMode {
+			if newest == nil {
+				newest = []flaghistory.Record{}
+			}
+			return printJSON(map[string]interface{}{"name": name, "records": newest})
+		}
+
+		if len(newest) == 0 {
+			ui.Muted(fmt.Sprintf("No history recorded for %s", name))
+			return nil
+		}
+
+		var rows [][]string
+		for _, r := range newest {
+			action := r.Action
+			if r.Action == "revert" {
+				action = fmt.Sprintf("revert to v%d", r.To)
+			}
+			rows = append(rows, []string{
+				fmt.Sprintf("v%d", r.Version),
+				r.At.Local().Format("2006-01-02 15:04"),
+				r.Actor,
+				action,
+				describeFlagValue(r.Old) + " → " + describeFlagValue(r.New),
+			})
+		}
+		fmt.Print(ui.RenderTable(fmt.Sprintf("Flag History: %s", name), []string{"Version", "When", "Who", "Action", "Change"}, rows))
+
+		if showDiff {
+			for _, r := range newest {
+				fmt.Println()
+				ui.PrintHeader(fmt.Sprintf("v%d · %s · %s", r.Version, r.Action, r.Actor))
+				printFlagValueDiff(r.Old, r.New)
+			}
+		}
+		if len(records) > 0 {
+			ui.Hint(fmt.Sprintf("Restore a version with: gw flag revert %s --to <version> --write", name))
+		}
+		return nil
+	},
+}
+
+// --- flag revert ---
+
+var flagRevertCmd = &cobra.Command{
+	Use:   "revert <name>",
+	Short: "Put a flag back to a version from its history",
+	Long: `Put a flag back to its value just after a version in gw flag history.
+--to 0 restores the flag as it was before gw first changed it; when it did
+not exist then, reverting deletes it, which needs --force as a delete
+does. The revert is itself recorded as a new version.`,
+	Example: `  gw flag revert new-editor --to 3 --write
+  gw flag revert new-editor --to 0 --write --force`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafety("flag_revert"); err != nil {
+			return err
+		}
+
+		cfg := config.Get()
+		name := args[0]
+		if err := validateFlagName(name); err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("to") {
+			return fmt.Errorf("--to is required (see gw flag history %s)", name)
+		}
+		to, _ := cmd.Flags().GetInt("to")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		nsID, err := resolveFlagsNamespace()
+		if err != nil {
+			return err
+		}
+		records, err := readFlagHistory(nsID, name)
+		if err != nil {
+			return err
+		}
+		target, err := flaghistory.StateAt(records, to)
+		if err != nil {
+			return fmt.Errorf("cannot revert %s: %w", name, err)
+		}
+		if target == nil {
+			if err := requireCFSafety("flag_delete"); err != nil {
+				return err
+			}
+		} else if _, err := flagrules.Read(*target); err != nil {
+			return fmt.Errorf("version %d of %s: %w", to, name, err)
+		}
+
+		before, err := kvSnapshot(nsID, name)
+		if err != nil {
+			return err
+		}
+		current := stateValue(before)
+		if (current == nil && target == nil) || (current != nil && target != nil && compactJSON(*current) == compactJSON(*target)) {
+			if cfg.JSONMode {
+				return printJSON(map[string]interface{}{"name": name, "to": to, "changed": false})
+			}
+			ui.Muted(fmt.Sprintf("%s already matches version %d", name, to))
+			return nil
+		}
+
+		if !cfg.JSONMode {
+			ui.PrintHeader(fmt.Sprintf("Revert %s to v%d: %s → %s", name, to, describeFlagValue(current), describeFlagValue(target)))
+			printFlagValueDiff(current, target)
+			fmt.Println()
+			if cfg.IsInteractive() && !yes && !cfg.DryRun && !ui.Confirm("Revert this flag?") {
+				ui.Muted("Cancelled")
+				return nil
+			}
+		}
+
+		txn, err := journalKVSnapshot("gw flag revert", "flags", nsID, name, before, target)
+		if err != nil {
+			return err
+		}
+		args = []string{"kv:key", "delete", "--namespace-id", nsID, name}
+		if target != nil {
+			args = []string{"kv:key", "put", "--namespace-id", nsID, name, *target}
+		}
+		result, err := exec.Wrangler(args...)
+		if err != nil {
+			txn.abort()
+			return fmt.Errorf("wrangler error: %w", err)
+		}
+		if !result.OK() {
+			txn.abort()
+			return fmt.Errorf("wrangler error: %s", result.Stderr)
+		}
+		txn.commit()
+		version := recordFlagChange(nsID, name, "revert", current, target, to)
+
+		if cfg.JSONMode {
+			return printJSON(map[string]interface{}{
+				"name": name, "to": to, "changed": true, "deleted": target == nil,
+				"history_version": version, "undo_id": txn.id(),
+			})
+		}
+		ui.Success(fmt.Sprintf("Reverted %s to version %d", name, to))
+		txn.printUndoHint()
+		return nil
+	},
+}
+
+func init() {
+	flagHistoryCmd.Flags().Bool("diff", false, "Show each change line by line")
+	flagHistoryCmd.Flags().IntP("limit", "n", 0, "Show only the newest N changes")
+	flagCmd.AddCommand(flagHistoryCmd)
+
+	flagRevertCmd.Flags().Int("to", 0, "Version to restore (0: before gw first changed the flag)")
+	flagRevertCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+	flagCmd.AddCommand(flagRevertCmd)
+}
@@ -0,0 +1,447 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/flagrules"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/undo"
+)
+
+// flagState is a flag as stored: its raw value (nil when the key does not
+// exist), whether it is enabled and its rules.
+type flagState struct {
+	before  *undo.State
+	enabled bool
+	rules   *flagrules.Rules
+}
+
+// readFlagState loads a flag for editing its rules. Rules that no longer
+// parse are an error, so an edit never silently drops them.
+func readFlagState(nsID, name string) (*flagState, error) {
+	before, err := kvSnapshot(nsID, name)
+	if err != nil {
+		return nil, err
+	}
+	s := &flagState{before: before, rules: &flagrules.Rules{}}
+	if before == nil {
+		return s, nil
+	}
+	s.enabled, _ = parseFlagEnabled(before.Value)
+	rules, err := flagrules.Read(before.Value)
+	if err != nil {
+		return nil, fmt.Errorf("flag %s: %w", name, err)
+	}
+	if rules != nil {
+		s.rules = rules
+	}
+	return s, nil
+}
+
+// writeFlagState stores a flag's new enabled state and rules, journaled
+// for gw undo and recorded in the flag's history under action. It returns
+// the stored value and its history version.
+func writeFlagState(command, action, nsID, name string, s *flagState) (string, int, *undoTxn, error) {
+	raw := ""
+	if s.before != nil {
+		raw = s.before.Value
+	}
+	value, err := flagrules.Write(raw, s.enabled, s.rules, time.Now())
+	if err != nil {
+		return "", 0, nil, err
+	}
+	txn, err := journalKVSnapshot(command, "flags", nsID, name, s.before, &value)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	result, err := exec.Wrangler("kv:key", "put", "--namespace-id", nsID, name, value)
+	if err != nil {
+		txn.abort()
+		return "", 0, nil, fmt.Errorf("wrangler error: %w", err)
+	}
+	if !result.OK() {
+		txn.abort()
+		return "", 0, nil, fmt.Errorf("wrangler error: %s", result.Stderr)
+	}
+	txn.commit()
+	version := recordFlagChange(nsID, name, action, stateValue(s.before), &value, 0)
+	return value, version, txn, nil
+}
+
+// carryFlagRules copies the rules of the stored flag into a new value, so
+// enabling or disabling a flag keeps its targeting.
+func carryFlagRules(before *undo.State, value map[string]interface{}) {
+	if before == nil {
+		return
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal([]byte(before.Value), &obj) != nil {
+		return
+	}
+	if rules, ok := obj["rules"]; ok {
+		value["rules"] = rules
+	}
+}
+
+// flagRulesLabel describes a flag's rules for list and get.
+func flagRulesLabel(raw string) string {
+	rules, err := flagrules.Read(raw)
+	if err != nil {
+		return "invalid rules"
+	}
+	return rules.Summary()
+}
+
+// addUnique appends the values not already in list.
+func addUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// without returns list minus values.
+func without(list []string, values ...string) []string {
+	var out []string
+	for _, v := range list {
+		if !slices.Contains(values, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// --- flag rollout ---
+
+var flagRolloutCmd = &cobra.Command{
+	Use:   "rollout <name>",
+	Short: "Roll a flag out to a percentage of tenants",
+	Long: `Roll a flag out to a percentage of tenants. Each tenant lands in a stable
+bucket from 0 to 99, hashed from the flag name and tenant ID the way the
+engine hashes it, and the flag is on when the bucket is below the percent.
+Raising the percent only adds tenants. Setting a rollout enables the flag;
+--clear removes the rollout and leaves the flag's other rules.`,
+	Example: `  gw flag rollout new-editor --percent 25 --write
+  gw flag rollout new-editor --clear --write`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafety("flag_rollout"); err != nil {
+			return err
+		}
+
+		cfg := config.Get()
+		name := args[0]
+		if err := validateFlagName(name); err != nil {
+			return err
+		}
+		percentSet := cmd.Flags().Changed("percent")
+		percent, _ := cmd.Flags().GetInt("percent")
+		clearAll, _ := cmd.Flags().GetBool("clear")
+		salt, _ := cmd.Flags().GetString("salt")
+		if percentSet == clearAll {
+			return fmt.Errorf("pass --percent or --clear")
+		}
+		if percentSet && (percent < 0 || percent > 100) {
+			return fmt.Errorf("--percent must be between 0 and 100")
+		}
+
+		nsID, err := resolveFlagsNamespace()
+		if err != nil {
+			return err
+		}
+		s, err := readFlagState(nsID, name)
+		if err != nil {
+			return err
+		}
+		from := "none"
+		if s.rules.Percent != nil {
+			from = fmt.Sprintf("%d%%", *s.rules.Percent)
+		}
+		wasEnabled := s.enabled
+		if clearAll {
+			if s.before == nil {
+				return fmt.Errorf("flag not found: %s", name)
+			}
+			s.rules.Percent = nil
+			s.rules.Salt = ""
+		} else {
+			s.rules.Percent = &percent
+			if cmd.Flags().Changed("salt") {
+				s.rules.Salt = salt
+			}
+			s.enabled = true
+		}
+
+		value, version, txn, err := writeFlagState("gw flag rollout", "rollout", nsID, name, s)
+		if err != nil {
+			return err
+		}
+
+		if cfg.JSONMode {
+			return printJSON(map[string]interface{}{
+				"name": name, "created": s.before == nil, "enabled": s.enabled,
+				"rules": s.rules, "value": json.RawMessage(value), "history_version": version, "undo_id": txn.id(),
+			})
+		}
+		to := "none"
+		if s.rules.Percent != nil {
+			to = fmt.Sprintf("%d%%", *s.rules.Percent)
+		}
+		ui.Success(fmt.Sprintf("Rollout for %s: %s → %s", name, from, to))
+		if !wasEnabled && s.enabled {
+			ui.Info(fmt.Sprintf("Enabled %s", name))
+		}
+		ui.PrintKeyValue("  Rules", s.rules.Summary())
+		txn.printUndoHint()
+		return nil
+	},
+}
+
+// --- flag target ---
+
+var flagTargetCmd = &cobra.Command{
+	Use:   "target <name>",
+	Short: "Target a flag at tenants or plans",
+	Long: `Edit a flag's targeting. --tenant puts a tenant on the allow list, where
+the flag is always on; --deny puts one on the deny list, where it is always
+off; --plan limits the flag to tenants on the given plans. Tenants are
+subdomains or tenant IDs. With --remove the named entries are taken off
+their lists instead, and --clear drops all targeting. The rollout percent
+is left alone.`,
+	Example: `  gw flag target new-editor --tenant autumn --write
+  gw flag target new-editor --plan oak --plan evergreen --write
+  gw flag target new-editor --deny spam-site --write
+  gw flag target new-editor --tenant autumn --remove --write`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafety("flag_target"); err != nil {
+			return err
+		}
+
+		cfg := config.Get()
+		name := args[0]
+		if err := validateFlagName(name); err != nil {
+			return err
+		}
+		allow, _ := cmd.Flags().GetStringArray("tenant")
+		deny, _ := cmd.Flags().GetStringArray("deny")
+		plans, _ := cmd.Flags().GetStringArray("plan")
+		remove, _ := cmd.Flags().GetBool("remove")
+		clearAll, _ := cmd.Flags().GetBool("clear")
+
+		if !clearAll && len(allow)+len(deny)+len(plans) == 0 {
+			return fmt.Errorf("pass --tenant, --deny, --plan or --clear")
+		}
+		for _, t := range append(append([]string{}, allow...), deny...) {
+			if err := validateCFName(t, "tenant"); err != nil {
+				return err
+			}
+		}
+		for _, p := range plans {
+			if !validTenantPlans[p] {
+				return fmt.Errorf("invalid plan: %s (valid: seedling, sapling, oak, evergreen)", p)
+			}
+		}
+
+		nsID, err := resolveFlagsNamespace()
+		if err != nil {
+			return err
+		}
+		s, err := readFlagState(nsID, name)
+		if err != nil {
+			return err
+		}
+		if s.before == nil {
+			return fmt.Errorf("flag not found: %s (create it with gw flag enable or gw flag rollout)", name)
+		}
+		from := s.rules.Summary()
+
+		r := s.rules
+		switch {
+		case clearAll:
+			r.Allow, r.Deny, r.Plans = nil, nil, nil
+		case remove:
+			r.Allow = without(r.Allow, allow...)
+			r.Deny = without(r.Deny, deny...)
+			r.Plans = without(r.Plans, plans...)
+		default:
+			// A tenant moves between the lists rather than sitting on both
+			r.Allow = addUnique(without(r.Allow, deny...), allow...)
+			r.Deny = addUnique(without(r.Deny, allow...), deny...)
+			r.Plans = addUnique(r.Plans, plans...)
+		}
+
+		value, version, txn, err := writeFlagState("gw flag target", "target", nsID, name, s)
+		if err != nil {
+			return err
+		}
+
+		if cfg.JSONMode {
+			return printJSON(map[string]interface{}{
+				"name": name, "enabled": s.enabled, "rules": r,
+				"value": json.RawMessage(value), "history_version": version, "undo_id": txn.id(),
+			})
+		}
+		ui.Success(fmt.Sprintf("Updated targeting for %s", name))
+		ui.PrintKeyValue("  Before", from)
+		ui.PrintKeyValue("  After", r.Summary())
+		if !s.enabled {
+			ui.Warning(fmt.Sprintf("%s is disabled, so its rules have no effect until gw flag enable", name))
+		}
+		txn.printUndoHint()
+		return nil
+	},
+}
+
+// --- flag eval ---
+
+// lookupFlagTenant reads a tenant's ID and plan from D1.
+func lookupFlagTenant(dbAlias, subdomain string) (id, plan string, err error) {
+	dbName, err := resolveDatabase(dbAlias)
+	if err != nil {
+		return "", "", err
+	}
+	escaped := strings.ReplaceAll(subdomain, "'", "''")
+	sql := fmt.Sprintf("SELECT id, plan FROM tenants WHERE subdomain = '%s' LIMIT 1", escaped)
+	output, err := exec.WranglerOutput("d1", "execute", dbName, "--remote", "--json", "--command", sql)
+	if err != nil {
+		return "", "", fmt.Errorf("wrangler error: %w", err)
+	}
+	rows := parseD1Results(output)
+	if len(rows) == 0 {
+		return "", "", fmt.Errorf("tenant not found: %s (pass --id and --plan to evaluate without a lookup)", subdomain)
+	}
+	id = fmt.Sprintf("%v", rows[0]["id"])
+	if p, ok := rows[0]["plan"].(string); ok {
+		plan = p
+	}
+	return id, plan, nil
+}
+
+var flagEvalCmd = &cobra.Command{
+	Use:   "eval <name>",
+	Short: "Evaluate a flag for a tenant and show the deciding rule",
+	Long: `Evaluate a flag for one tenant locally, the way the rules say it should
+resolve, and show which rule decided it. The tenant's ID and plan are read
+from the tenants table when the rules need them; --id and --plan supply
+them instead, which also works with --no-cloud.`,
+	Example: `  gw flag eval new-editor --tenant autumn
+  gw flag eval new-editor --tenant autumn --plan oak --id 3f2a...`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafety("flag_eval"); err != nil {
+			return err
+		}
+
+		cfg := config.Get()
+		name := args[0]
+		if err := validateFlagName(name); err != nil {
+			return err
+		}
+		subdomain, _ := cmd.Flags().GetString("tenant")
+		dbAlias, _ := cmd.Flags().GetString("db")
+		tenant := flagrules.Tenant{Subdomain: subdomain}
+		tenant.ID, _ = cmd.Flags().GetString("id")
+		tenant.Plan, _ = cmd.Flags().GetString("plan")
+		if subdomain == "" {
+			return fmt.Errorf("--tenant is required")
+		}
+		if err := validateCFName(subdomain, "tenant"); err != nil {
+			return err
+		}
+
+		nsID, err := resolveFlagsNamespace()
+		if err != nil {
+			return err
+		}
+		state, err := kvSnapshot(nsID, name)
+		if err != nil {
+			return err
+		}
+		if state == nil {
+			return fmt.Errorf("flag not found: %s", name)
+		}
+		enabled, _ := parseFlagEnabled(state.Value)
+		rules, err := flagrules.Read(state.Value)
+		if err != nil {
+			return fmt.Errorf("flag %s: %w", name, err)
+		}
+
+		// Look the tenant up only when a rule could need what is missing
+		lookedUp := false
+		if enabled && !rules.Empty() && (tenant.ID == "" || tenant.Plan == "") && !cfg.NoCloud {
+			id, plan, err := lookupFlagTenant(dbAlias, subdomain)
+			if err != nil {
+				return err
+			}
+			if tenant.ID == "" {
+				tenant.ID = id
+			}
+			if tenant.Plan == "" {
+				tenant.Plan = plan
+			}
+			lookedUp = true
+		}
+
+		result := flagrules.Evaluate(name, enabled, rules, tenant)
+
+		if cfg.JSONMode {
+			return printJSON(map[string]interface{}{
+				"name": name, "tenant": tenant, "looked_up": lookedUp,
+				"flag_enabled": enabled, "rules": rules, "result": result,
+			})
+		}
+
+		status := "○ OFF"
+		if result.Enabled {
+			status = "● ON"
+		}
+		who := subdomain
+		var details []string
+		if tenant.ID != "" {
+			details = append(details, "id "+tenant.ID)
+		}
+		if tenant.Plan != "" {
+			details = append(details, "plan "+tenant.Plan)
+		}
+		if len(details) > 0 {
+			who += " (" + strings.Join(details, ", ") + ")"
+		}
+		ui.PrintHeader(fmt.Sprintf("Flag: %s", name))
+		ui.PrintKeyValue("  Tenant", who)
+		ui.PrintKeyValue("  Rules", rules.Summary())
+		ui.PrintKeyValue("  Result", status)
+		ui.PrintKeyValue("  Rule", result.Rule+" — "+result.Reason)
+		return nil
+	},
+}
+
+func init() {
+	flagRolloutCmd.Flags().Int("percent", 0, "Percent of tenants the flag is on for (0-100)")
+	flagRolloutCmd.Flags().Bool("clear", false, "Remove the rollout")
+	flagRolloutCmd.Flags().String("salt", "", "Reshuffle which tenants fall in the rollout")
+	flagCmd.AddCommand(flagRolloutCmd)
+
+	flagTargetCmd.Flags().StringArray("tenant", nil, "Tenant the flag is always on for, repeatable")
+	flagTargetCmd.Flags().StringArray("deny", nil, "Tenant the flag is always off for, repeatable")
+	flagTargetCmd.Flags().StringArray("plan", nil, "Plan the flag is limited to, repeatable")
+	flagTargetCmd.Flags().Bool("remove", false, "Remove the given tenants and plans instead of adding them")
+	flagTargetCmd.Flags().Bool("clear", false, "Remove all tenant and plan targeting")
+	flagCmd.AddCommand(flagTargetCmd)
+
+	flagEvalCmd.Flags().String("tenant", "", "Tenant subdomain to evaluate for")
+	flagEvalCmd.Flags().String("id", "", "Tenant ID (skips the lookup)")
+	flagEvalCmd.Flags().String("plan", "", "Tenant plan (skips the lookup)")
+	flagEvalCmd.Flags().StringP("db", "d", "lattice", "Database holding the tenants table")
+	flagCmd.AddCommand(flagEvalCmd)
+}
@@ -250,10 +250,10 @@ var gitPushCmd = &cobra.Command{
 
 		if cfg.JSONMode {
 			return printJSON(map[string]any{
-				"pushed":  true,
-				"remote":  remote,
-				"branch":  pushBranch,
-				"forced":  gitPushForce,
+				"pushed": true,
+				"remote": remote,
+				"branch": pushBranch,
+				"forced": gitPushForce,
 			})
 		}
 
@@ -939,7 +939,10 @@ var gitMergeCmd = &cobra.Command{
 			return nil
 		}
 
-		if err := requireSafety("merge"); err != nil {
+		// Scope the check to the branch being merged into, so policy rules
+		// like "merge into release/* is PROTECTED" apply.
+		currentBranch, _ := gwexec.CurrentBranch()
+		if err := requireSafetyBranch("merge", currentBranch); err != nil {
 			return err
 		}
 		cfg := config.Get()
@@ -975,7 +978,6 @@ var gitMergeCmd = &cobra.Command{
 			})
 		}
 
-		currentBranch, _ := gwexec.CurrentBranch()
 		ui.Action("Merged", branch+" → "+currentBranch)
 		return nil
 	},
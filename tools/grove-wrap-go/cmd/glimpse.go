@@ -71,7 +71,7 @@ Examples:
 			return err
 		}
 		if exitCode != 0 {
-			os.Exit(exitCode)
+			return exitStatus(exitCode)
 		}
 		return nil
 	},
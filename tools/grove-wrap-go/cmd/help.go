@@ -32,6 +32,8 @@ var rootHelpCategories = []ui.HelpCategory{
 		Style: ui.SafeWriteStyle,
 		Commands: []ui.HelpCommand{
 			{Name: "dev", Desc: "Development server, testing, building"},
+			{Name: "mcp", Desc: "Serve gw commands as MCP tools for agents"},
+			{Name: "plugin", Desc: "List external gw-<name> plugins and their tiers"},
 		},
 	},
 	{
@@ -73,6 +75,7 @@ var rootHelpCategories = []ui.HelpCategory{
 			{Name: "do", Desc: "Durable Object operations"},
 			{Name: "email", Desc: "Email routing operations"},
 			{Name: "secret", Desc: "Encrypted secrets vault"},
+			{Name: "undo", Desc: "Reverse journaled KV, flag, R2 and secret writes"},
 			{Name: "cache", Desc: "Cache management and CDN purge"},
 			{Name: "bindings", Desc: "Scan wrangler.toml bindings"},
 			{Name: "export", Desc: "Storage export management"},
@@ -105,6 +108,8 @@ var rootHelpCategories = []ui.HelpCategory{
 			{Name: "history", Desc: "Command history tracking"},
 			{Name: "metrics", Desc: "Performance diagnostics"},
 			{Name: "config-validate", Desc: "Validate gw.toml config"},
+			{Name: "policy", Desc: "Safety policy rules and explain"},
+			{Name: "audit", Desc: "Tamper-evident safety decision log"},
 			{Name: "env-audit", Desc: "Check environment variables"},
 			{Name: "monorepo-size", Desc: "Monorepo filesystem stats"},
 		},
@@ -134,7 +139,7 @@ func setupCozyHelp() {
 		output := ui.RenderCozyHelp(
 			"gw",
 			"tend the grove with safety and warmth",
-			rootHelpCategories,
+			append(rootHelpCategories, pluginHelpCategory()...),
 			true,
 		)
 		fmt.Print(output)
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -100,3 +101,36 @@ func TestBlameLineRegex(t *testing.T) {
 		}
 	}
 }
+
+func TestShellJoinSplitRoundTrip(t *testing.T) {
+	tests := [][]string{
+		{"kv", "put", "ns", "key", "value"},
+		{"d1", "query", "SELECT * FROM posts WHERE title = 'it''s'"},
+		{"commit", "-m", `say "hi" $HOME`},
+		{"x", ""},
+	}
+	for _, argv := range tests {
+		line := shellJoin(argv)
+		got, err := shellSplit(line)
+		if err != nil {
+			t.Fatalf("shellSplit(%q): %v", line, err)
+		}
+		if strings.Join(got, "\x00") != strings.Join(argv, "\x00") {
+			t.Errorf("round trip of %q gave %q via %q", argv, got, line)
+		}
+	}
+}
+
+func TestShellSplit(t *testing.T) {
+	got, err := shellSplit(`kv put "a b" c\ d 'e f'`)
+	if err != nil {
+		t.Fatalf("shellSplit: %v", err)
+	}
+	want := []string{"kv", "put", "a b", "c d", "e f"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("shellSplit = %q, want %q", got, want)
+	}
+	if _, err := shellSplit(`kv put "open`); err == nil {
+		t.Error("unterminated quote should be an error")
+	}
+}
@@ -1,16 +1,73 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	gwexec "github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/historydb"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
 )
 
+// recordHistory stores a finished invocation in history, successful or not.
+// It is best-effort and never changes the command's outcome.
+func recordHistory(cmd *cobra.Command, argv []string, runErr error, exitCode int, elapsed time.Duration) {
+	// Shell completion requests (__complete) are not user commands
+	if cmd == nil || strings.HasPrefix(cmd.Name(), "__") {
+		return
+	}
+	db, err := historydb.Open()
+	if err != nil {
+		return
+	}
+
+	entry := historydb.Entry{
+		Command:    cmd.CommandPath(),
+		Args:       strings.Join(cmd.Flags().Args(), " "),
+		Argv:       argv,
+		IsWrite:    argvHasFlag(argv, "write"),
+		ExitCode:   exitCode,
+		DurationMS: elapsed.Milliseconds(),
+	}
+	if runErr != nil {
+		var status *exitStatusError
+		if !errors.As(runErr, &status) {
+			entry.Error = runErr.Error()
+		}
+		entry.SafetyBlock = safetyBlockReason(runErr)
+		entry.StderrTail = gwexec.LastFailureStderr()
+	}
+	if t := lastSafetyTarget; t != nil {
+		entry.Target = &historydb.Target{Branch: t.Branch, Database: t.Database, Namespace: t.Namespace, Worker: t.Worker}
+	}
+	_ = db.Record(entry)
+}
+
+// safetyBlockReason names the safety rule behind a failed command, or ""
+// if the failure was not a safety refusal.
+func safetyBlockReason(err error) string {
+	var tierErr *safety.SafetyError
+	if errors.As(err, &tierErr) {
+		return fmt.Sprintf("%s (%s)", tierErr.Operation, tierErr.Tier)
+	}
+	var sqlErr *safety.SQLSafetyError
+	if errors.As(err, &sqlErr) {
+		return string(sqlErr.Code)
+	}
+	if lastSafetyBlock != nil {
+		return fmt.Sprintf("%s (%s)", lastSafetyBlock.Operation, lastSafetyBlock.Tier)
+	}
+	return ""
+}
+
 // historyCmd is the parent command for gw history.
 var historyCmd = &cobra.Command{
 	Use:   "history",
@@ -26,6 +83,7 @@ var historyListCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := config.Get()
 		limit, _ := cmd.Flags().GetInt("limit")
+		failedOnly, _ := cmd.Flags().GetBool("failed")
 
 		db, err := historydb.Open()
 		if err != nil {
@@ -33,6 +91,9 @@ var historyListCmd = &cobra.Command{
 		}
 
 		entries := db.List(limit)
+		if failedOnly {
+			entries = db.ListFailed(limit)
+		}
 
 		if cfg.JSONMode {
 			return printJSON(map[string]any{
@@ -42,7 +103,36 @@ var historyListCmd = &cobra.Command{
 		}
 
 		if len(entries) == 0 {
-			ui.Muted("No history recorded yet.")
+			if failedOnly {
+				ui.Muted("No failed commands in history.")
+			} else {
+				ui.Muted("No history recorded yet.")
+			}
+			return nil
+		}
+
+		if failedOnly {
+			headers := []string{"", "#", "Command", "Error", "Time"}
+			var rows [][]string
+			for _, e := range entries {
+				icon := "✗"
+				if e.SafetyBlock != "" {
+					icon = "⛔"
+				}
+				ts := e.Timestamp
+				if len(ts) > 16 {
+					ts = ts[:16]
+				}
+				rows = append(rows, []string{
+					icon,
+					fmt.Sprintf("%d", e.ID),
+					e.Command,
+					TruncateStr(historyFailureSummary(e), 40),
+					ts,
+				})
+			}
+			fmt.Print(ui.RenderTable(fmt.Sprintf("Failed Commands (%d entries)", len(entries)), headers, rows))
+			ui.Hint("gw history show <id> for the full error and stderr")
 			return nil
 		}
 
@@ -159,20 +249,24 @@ var historyShowCmd = &cobra.Command{
 
 		if cfg.JSONMode {
 			return printJSON(map[string]any{
-				"id":          entry.ID,
-				"command":     entry.Command,
-				"args":        entry.Args,
-				"is_write":    entry.IsWrite,
-				"exit_code":   entry.ExitCode,
-				"duration_ms": entry.DurationMS,
-				"timestamp":   entry.Timestamp,
-				"found":       true,
+				"id":           entry.ID,
+				"command":      entry.Command,
+				"args":         entry.Args,
+				"argv":         entry.Argv,
+				"is_write":     entry.IsWrite,
+				"exit_code":    entry.ExitCode,
+				"duration_ms":  entry.DurationMS,
+				"timestamp":    entry.Timestamp,
+				"error":        entry.Error,
+				"safety_block": entry.SafetyBlock,
+				"stderr_tail":  entry.StderrTail,
+				"found":        true,
 			})
 		}
 
-		exitStatus := "0 (success)"
-		if entry.ExitCode != 0 {
-			exitStatus = fmt.Sprintf("%d (failed)", entry.ExitCode)
+		status := "0 (success)"
+		if entry.Failed() {
+			status = fmt.Sprintf("%d (failed)", entry.ExitCode)
 		}
 		writeStr := "no"
 		if entry.IsWrite {
@@ -185,23 +279,56 @@ var historyShowCmd = &cobra.Command{
 		if entry.Args != "" {
 			pairs = append(pairs, [2]string{"Args", entry.Args})
 		}
+		if len(entry.Argv) > 0 {
+			pairs = append(pairs, [2]string{"Invocation", "gw " + strings.Join(entry.Argv, " ")})
+		}
 		pairs = append(pairs,
 			[2]string{"Timestamp", entry.Timestamp},
 			[2]string{"Duration", fmt.Sprintf("%dms", entry.DurationMS)},
-			[2]string{"Exit Code", exitStatus},
+			[2]string{"Exit Code", status},
 			[2]string{"Write Op", writeStr},
 		)
+		if entry.SafetyBlock != "" {
+			pairs = append(pairs, [2]string{"Blocked By", entry.SafetyBlock})
+		}
+		if entry.Error != "" {
+			pairs = append(pairs, [2]string{"Error", entry.Error})
+		}
 		fmt.Print(ui.RenderInfoPanel(fmt.Sprintf("History Entry #%d", entry.ID), pairs))
+		if entry.StderrTail != "" {
+			fmt.Print(ui.RenderPanel("Stderr (tail)", entry.StderrTail))
+		}
 		return nil
 	},
 }
 
+// historyFailureSummary is the one-line reason an entry failed.
+func historyFailureSummary(e historydb.Entry) string {
+	msg := e.Error
+	if msg == "" {
+		msg = e.StderrTail
+	}
+	if msg == "" {
+		msg = fmt.Sprintf("exit status %d", e.ExitCode)
+	}
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		msg = msg[:i]
+	}
+	return msg
+}
+
 // --- history run ---
 
 var historyRunCmd = &cobra.Command{
 	Use:   "run <id>",
-	Short: "Show the command to replay (does not execute it)",
-	Args:  cobra.ExactArgs(1),
+	Short: "Re-run a recorded command",
+	Long: `Re-run a recorded command in-process with its original arguments and flags.
+
+Safety is evaluated as it stands today, not as it was when the command was
+recorded: current tiers and policy apply, and WRITE or DANGEROUS commands
+ask for confirmation first (--yes skips the prompt). --edit opens the
+command line in $EDITOR before running it.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := config.Get()
 
@@ -223,30 +350,295 @@ var historyRunCmd = &cobra.Command{
 			ui.Warning(fmt.Sprintf("No history entry with id %d.", id))
 			return nil
 		}
+		return replayHistoryEntry(cmd, *entry)
+	},
+}
+
+// --- history rerun-last ---
+
+var historyRerunLastCmd = &cobra.Command{
+	Use:   "rerun-last",
+	Short: "Re-run the most recent command (--failed for the last failure)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		failedOnly, _ := cmd.Flags().GetBool("failed")
+
+		db, err := historydb.Open()
+		if err != nil {
+			return fmt.Errorf("cannot open history: %w", err)
+		}
 
-		parts := []string{"gw", entry.Command}
-		if entry.Args != "" {
-			parts = append(parts, entry.Args)
+		candidates := db.List(0)
+		if failedOnly {
+			candidates = db.ListFailed(0)
 		}
-		replayCmd := strings.Join(parts, " ")
+		for _, e := range candidates {
+			if !isHistoryCommand(e.Command) {
+				return replayHistoryEntry(cmd, e)
+			}
+		}
+
+		if failedOnly {
+			return fmt.Errorf("no failed commands in history")
+		}
+		return fmt.Errorf("no commands in history")
+	},
+}
+
+// isHistoryCommand reports whether a recorded command is itself a history
+// command; those are never replayed.
+func isHistoryCommand(command string) bool {
+	return strings.HasPrefix(command+" ", "gw history ")
+}
+
+// historyEntryArgv returns the argv to replay for an entry. Entries recorded
+// before full invocations were stored are rebuilt from the command path and
+// positional args; their flags were not recorded and are lost.
+func historyEntryArgv(e historydb.Entry) ([]string, bool) {
+	if len(e.Argv) > 0 {
+		return e.Argv, true
+	}
+	argv := strings.Fields(e.Command)
+	if len(argv) > 0 {
+		argv = argv[1:] // drop the program name
+	}
+	return append(argv, strings.Fields(e.Args)...), false
+}
 
+// replayHistoryEntry re-runs a recorded command through the Cobra tree,
+// honouring the --print, --edit, and --yes flags of cmd.
+func replayHistoryEntry(cmd *cobra.Command, entry historydb.Entry) error {
+	cfg := config.Get()
+	printOnly, _ := cmd.Flags().GetBool("print")
+	edit, _ := cmd.Flags().GetBool("edit")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	if isHistoryCommand(entry.Command) {
+		return fmt.Errorf("entry #%d is a history command and cannot be replayed", entry.ID)
+	}
+
+	argv, complete := historyEntryArgv(entry)
+	if !complete && !cfg.JSONMode {
+		ui.Warning(fmt.Sprintf("Entry #%d predates full recording; its flags were not saved.", entry.ID))
+	}
+
+	if edit {
+		edited, err := editCommandLine(argv)
+		if err != nil {
+			return err
+		}
+		if len(edited) == 0 {
+			ui.Muted("Empty command line — nothing to run.")
+			return nil
+		}
+		argv = edited
+	}
+
+	line := "gw " + shellJoin(argv)
+	target, _, err := rootCmd.Find(argv)
+	if err != nil || target == rootCmd {
+		return fmt.Errorf("cannot replay %q: no such command", line)
+	}
+	if isHistoryCommand(target.CommandPath()) {
+		return fmt.Errorf("history commands cannot be replayed")
+	}
+	tier := commandTier(target, argv, entrySafetyTarget(entry))
+
+	if printOnly {
 		if cfg.JSONMode {
 			return printJSON(map[string]any{
-				"id":      entry.ID,
-				"replay":  replayCmd,
-				"command": entry.Command,
-				"args":    entry.Args,
+				"id":     entry.ID,
+				"replay": line,
+				"argv":   argv,
+				"tier":   tier.String(),
 			})
 		}
-
 		ui.PrintHeader(fmt.Sprintf("Replay for entry #%d", entry.ID))
 		fmt.Println()
-		fmt.Printf("  %s\n", ui.CommandStyle.Render(replayCmd))
+		fmt.Printf("  %s\n", ui.CommandStyle.Render(line))
 		fmt.Println()
-		ui.Muted("Copy the line above and run it to replay this command.")
-
 		return nil
-	},
+	}
+
+	if tier >= safety.TierWrite && !yes {
+		if !cfg.IsInteractive() {
+			return fmt.Errorf("entry #%d is a %s command; pass --yes to replay it non-interactively", entry.ID, tier)
+		}
+		fmt.Printf("  %s\n", ui.CommandStyle.Render(line))
+		if !ui.Confirm(fmt.Sprintf("  Replay this %s command?", tier)) {
+			ui.Muted("Cancelled.")
+			return nil
+		}
+	} else if cfg.IsHumanMode() {
+		ui.Muted(fmt.Sprintf("Replaying #%d: %s", entry.ID, line))
+	}
+
+	return replayArgv(argv)
+}
+
+// entrySafetyTarget is the resource a history entry acted on, empty for
+// entries recorded before targets were.
+func entrySafetyTarget(e historydb.Entry) safety.Target {
+	if e.Target == nil {
+		return safety.Target{}
+	}
+	return safety.Target{Branch: e.Target.Branch, Database: e.Target.Database, Namespace: e.Target.Namespace, Worker: e.Target.Worker}
+}
+
+// commandTier is the tier a command runs at under today's tiers and
+// policy, for the resource rt. Recorded --write/--force raise the floor
+// for commands whose tier depends on their input, such as d1 query.
+func commandTier(target *cobra.Command, argv []string, rt safety.Target) safety.Tier {
+	tier := safety.TierRead
+	if op, base, ok := commandOperation(target); ok {
+		tier = safety.ActivePolicy().Resolve(op, base, rt).Tier
+	}
+
+	if argvHasFlag(argv, "force") && tier < safety.TierDangerous {
+		tier = safety.TierDangerous
+	} else if argvHasFlag(argv, "write") && tier < safety.TierWrite {
+		tier = safety.TierWrite
+	}
+	return tier
+}
+
+// commandOperation maps a command path to its safety operation and
+// built-in tier: "gw kv put" → kv_put, or without the group, "gw git
+// push" → push. ok is false when neither name is a known operation.
+func commandOperation(target *cobra.Command) (op string, base safety.Tier, ok bool) {
+	parts := strings.Fields(target.CommandPath())[1:]
+	candidates := []string{strings.Join(parts, "_")}
+	if len(parts) > 1 {
+		candidates = append(candidates, strings.Join(parts[1:], "_"))
+	}
+	for _, op := range candidates {
+		if base, _, ok := safety.LookupOperation(op); ok {
+			return op, base, true
+		}
+	}
+	return "", safety.TierRead, false
+}
+
+// argvHasFlag reports whether a boolean flag is switched on in argv, as
+// --name or --name=true. Arguments after "--" are not flags.
+func argvHasFlag(argv []string, name string) bool {
+	on := false
+	for _, a := range argv {
+		switch a {
+		case "--":
+			return on
+		case "--" + name, "--" + name + "=true":
+			on = true
+		case "--" + name + "=false":
+			on = false
+		}
+	}
+	return on
+}
+
+// editCommandLine opens argv in $EDITOR as a single command line and
+// returns the edited arguments.
+func editCommandLine(argv []string) ([]string, error) {
+	f, err := os.CreateTemp("", "gw-replay-*.sh")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	content := "# Edit the command to replay. Lines starting with # are ignored.\n# Save and exit to run it; empty the line to cancel.\n" + shellJoin(argv) + "\n"
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot write temp file: %w", err)
+	}
+	f.Close()
+
+	if err := gwexec.RunEditor(f.Name()); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("cannot read edited command: %w", err)
+	}
+	var lines []string
+	for _, l := range strings.Split(string(data), "\n") {
+		if t := strings.TrimSpace(l); t != "" && !strings.HasPrefix(t, "#") {
+			lines = append(lines, t)
+		}
+	}
+	edited, err := shellSplit(strings.Join(lines, " "))
+	if err != nil {
+		return nil, err
+	}
+	// Accept a leading "gw" (or alias) if the user kept it
+	if len(edited) > 0 && (edited[0] == "gw" || edited[0] == rootCmd.Use) {
+		edited = edited[1:]
+	}
+	return edited, nil
+}
+
+// replayModes holds the agent, JSON, dry-run and no-cloud modes of the
+// invocation replaying a command. The replayed command runs with at least
+// these on, whatever its recorded argv says, so replay can never lift a
+// restriction the caller is under.
+var replayModes invocationModes
+
+// invocationModes are the global modes a replay inherits.
+type invocationModes struct {
+	agent, json, dryRun, noCloud bool
+}
+
+// replayArgv executes argv through the Cobra tree in this process and
+// records it in history as its own entry. Flags are reset to their
+// defaults first so nothing leaks in from the history command itself; the
+// caller's agent, JSON, dry-run and no-cloud modes carry over through
+// replayModes.
+func replayArgv(argv []string) error {
+	target, _, err := rootCmd.Find(argv)
+	if err != nil {
+		return err
+	}
+	cfg := config.Get()
+	replayModes = invocationModes{agent: cfg.AgentMode, json: cfg.JSONMode, dryRun: cfg.DryRun, noCloud: cfg.NoCloud}
+	defer func() { replayModes = invocationModes{} }()
+
+	for c := target; c != nil; c = c.Parent() {
+		resetFlags(c.Flags())
+		resetFlags(c.PersistentFlags())
+	}
+	lastSafetyBlock = nil
+	lastSafetyTarget = nil
+
+	rootCmd.SetArgs(argv)
+	defer rootCmd.SetArgs(nil)
+
+	start := time.Now()
+	ran, err := rootCmd.ExecuteC()
+	code := 0
+	if err != nil {
+		code = 1
+		var status *exitStatusError
+		if errors.As(err, &status) {
+			code = status.code
+		}
+	}
+	recordHistory(ran, argv, err, code, time.Since(start))
+	return err
+}
+
+// resetFlags restores every flag in fs to its default and clears Changed.
+func resetFlags(fs *pflag.FlagSet) {
+	fs.VisitAll(func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			var def []string
+			if d := strings.Trim(f.DefValue, "[]"); d != "" {
+				def = strings.Split(d, ",")
+			}
+			_ = sv.Replace(def)
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
 }
 
 // --- history clear ---
@@ -300,6 +692,7 @@ func init() {
 
 	// history list
 	historyListCmd.Flags().IntP("limit", "n", 20, "Maximum number of entries to show")
+	historyListCmd.Flags().Bool("failed", false, "Only show commands that failed or were blocked")
 	historyCmd.AddCommand(historyListCmd)
 
 	// history search
@@ -309,8 +702,18 @@ func init() {
 	historyCmd.AddCommand(historyShowCmd)
 
 	// history run
+	historyRunCmd.Flags().Bool("edit", false, "Edit the command line in $EDITOR before running")
+	historyRunCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation for WRITE and DANGEROUS commands")
+	historyRunCmd.Flags().Bool("print", false, "Only print the command line; do not run it")
 	historyCmd.AddCommand(historyRunCmd)
 
+	// history rerun-last
+	historyRerunLastCmd.Flags().Bool("failed", false, "Re-run the most recent failed command")
+	historyRerunLastCmd.Flags().Bool("edit", false, "Edit the command line in $EDITOR before running")
+	historyRerunLastCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation for WRITE and DANGEROUS commands")
+	historyRerunLastCmd.Flags().Bool("print", false, "Only print the command line; do not run it")
+	historyCmd.AddCommand(historyRerunLastCmd)
+
 	// history clear
 	historyClearCmd.Flags().StringP("older-than", "o", "", "Remove entries older than this duration (e.g. 30d, 1w, 6m, 1y)")
 	historyCmd.AddCommand(historyClearCmd)
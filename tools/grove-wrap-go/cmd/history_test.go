@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/historydb"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+)
+
+func TestSafetyBlockReason(t *testing.T) {
+	lastSafetyBlock = nil
+
+	tierErr := &safety.SafetyError{Operation: "kv_delete", Tier: safety.TierWrite}
+	if got := safetyBlockReason(fmt.Errorf("wrapped: %w", tierErr)); got != "kv_delete (WRITE)" {
+		t.Errorf("tier refusal = %q", got)
+	}
+
+	sqlErr := &safety.SQLSafetyError{Code: safety.ErrProtectedTable}
+	if got := safetyBlockReason(sqlErr); got != "PROTECTED_TABLE" {
+		t.Errorf("SQL refusal = %q", got)
+	}
+
+	if got := safetyBlockReason(errors.New("network down")); got != "" {
+		t.Errorf("ordinary failure should not be a safety block, got %q", got)
+	}
+}
+
+func TestHistoryFailureSummary(t *testing.T) {
+	tests := []struct {
+		entry historydb.Entry
+		want  string
+	}{
+		{historydb.Entry{ExitCode: 1, Error: "first line\nsecond"}, "first line"},
+		{historydb.Entry{ExitCode: 1, StderrTail: "wrangler: boom"}, "wrangler: boom"},
+		{historydb.Entry{ExitCode: 3}, "exit status 3"},
+	}
+	for _, tt := range tests {
+		if got := historyFailureSummary(tt.entry); got != tt.want {
+			t.Errorf("historyFailureSummary(%+v) = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestArgvHasFlag(t *testing.T) {
+	tests := []struct {
+		argv []string
+		want bool
+	}{
+		{[]string{"kv", "put", "--write"}, true},
+		{[]string{"kv", "put", "--write=true"}, true},
+		{[]string{"kv", "put", "--write", "--write=false"}, false},
+		{[]string{"kv", "put", "--", "--write"}, false},
+		{[]string{"kv", "put", "--writer"}, false},
+	}
+	for _, tt := range tests {
+		if got := argvHasFlag(tt.argv, "write"); got != tt.want {
+			t.Errorf("argvHasFlag(%v) = %v, want %v", tt.argv, got, tt.want)
+		}
+	}
+}
+
+func TestReplayTier(t *testing.T) {
+	tests := []struct {
+		argv []string
+		want safety.Tier
+	}{
+		{[]string{"history", "list"}, safety.TierRead},
+		{[]string{"kv", "put", "ns", "k", "v", "--write"}, safety.TierWrite},
+		{[]string{"d1", "query", "SELECT 1"}, safety.TierRead},
+		{[]string{"d1", "query", "DELETE FROM posts WHERE id = 1", "--write"}, safety.TierWrite},
+		{[]string{"d1", "query", "DELETE FROM posts", "--write", "--force"}, safety.TierDangerous},
+	}
+	for _, tt := range tests {
+		target, _, err := rootCmd.Find(tt.argv)
+		if err != nil {
+			t.Fatalf("Find(%v): %v", tt.argv, err)
+		}
+		if got := commandTier(target, tt.argv, safety.Target{}); got != tt.want {
+			t.Errorf("commandTier(%v) = %s, want %s", tt.argv, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryEntryArgv(t *testing.T) {
+	argv, complete := historyEntryArgv(historydb.Entry{Command: "gw kv get", Args: "ns key"})
+	if complete || shellJoin(argv) != "kv get ns key" {
+		t.Errorf("legacy entry argv = %v (complete=%v)", argv, complete)
+	}
+
+	recorded := []string{"d1", "query", "SELECT 1", "--db", "auth"}
+	argv, complete = historyEntryArgv(historydb.Entry{Command: "gw d1 query", Argv: recorded})
+	if !complete || len(argv) != len(recorded) {
+		t.Errorf("recorded argv = %v (complete=%v)", argv, complete)
+	}
+}
+
+func TestReplayKeepsCallerModes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("NO_INTERACTIVE", "1")
+	defer config.Init(false, false, false, false, false, false, false, false)
+
+	// A recorded human --write --force delete stays refused when an agent
+	// replays it, even if the recording switched agent mode off.
+	config.Init(false, false, false, true, false, false, false, false)
+	err := replayArgv([]string{"r2", "rm", "bucket", "key", "--write", "--force", "--agent=false"})
+	var tierErr *safety.SafetyError
+	if !errors.As(err, &tierErr) || tierErr.Operation != "r2_rm" {
+		t.Fatalf("agent replay of r2 rm = %v, want a safety refusal", err)
+	}
+
+	config.Init(false, false, false, false, false, false, true, false)
+	if err := replayArgv([]string{"version"}); err != nil {
+		t.Fatal(err)
+	}
+	if !config.Get().DryRun {
+		t.Error("a replay under --dry-run should run in dry-run mode")
+	}
+}
+
+func TestReplayTierUsesRecordedTarget(t *testing.T) {
+	prev := safety.ActivePolicy()
+	defer safety.SetPolicy(prev)
+	safety.SetPolicy(&safety.Policy{Rules: []safety.Rule{{Operation: "kv_put", Namespace: "prod", Tier: "dangerous"}}})
+
+	argv := []string{"kv", "put", "prod", "k", "v", "--write"}
+	target, _, err := rootCmd.Find(argv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := entrySafetyTarget(historydb.Entry{Target: &historydb.Target{Namespace: "prod"}})
+	if got := commandTier(target, argv, rt); got != safety.TierDangerous {
+		t.Errorf("commandTier for the prod namespace = %s, want DANGEROUS", got)
+	}
+	if got := commandTier(target, argv, safety.Target{Namespace: "dev"}); got != safety.TierWrite {
+		t.Errorf("commandTier for another namespace = %s, want WRITE", got)
+	}
+}
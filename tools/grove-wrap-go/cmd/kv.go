@@ -1,15 +1,27 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/jsonschema"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/textdiff"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/undo"
 )
 
 // maxKVLimit is the maximum limit for KV key listings.
@@ -181,7 +193,11 @@ var kvKeysCmd = &cobra.Command{
 var kvGetCmd = &cobra.Command{
 	Use:   "get <namespace> <key>",
 	Short: "Get a value from KV",
-	Args:  cobra.ExactArgs(2),
+	Long: `Print a KV value with its metadata and expiration.
+
+JSON values are pretty-printed. --raw prints the value exactly as stored
+and nothing else, for piping into a file or gw kv put --file.`,
+	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := config.Get()
 		nsID, err := resolveNamespace(args[0])
@@ -192,67 +208,287 @@ var kvGetCmd = &cobra.Command{
 		if err := validateCFKey(key); err != nil {
 			return err
 		}
+		raw, _ := cmd.Flags().GetBool("raw")
 
-		output, err := exec.WranglerOutput("kv:key", "get", "--namespace-id", nsID, key)
+		state, err := kvSnapshot(nsID, key)
 		if err != nil {
-			errStr := err.Error()
-			if strings.Contains(errStr, "key not found") || strings.Contains(errStr, "could not find") {
-				if cfg.JSONMode {
-					data, _ := json.Marshal(map[string]interface{}{
-						"key": key, "value": nil, "found": false,
-					})
-					fmt.Println(string(data))
-					return nil
-				}
-				ui.Warning(fmt.Sprintf("Key not found: %s", key))
+			return err
+		}
+		if state == nil {
+			if cfg.JSONMode {
+				data, _ := json.Marshal(map[string]interface{}{
+					"key": key, "value": nil, "found": false,
+				})
+				fmt.Println(string(data))
 				return nil
 			}
-			return fmt.Errorf("wrangler error: %w", err)
+			ui.Warning(fmt.Sprintf("Key not found: %s", key))
+			return nil
 		}
 
-		output = strings.TrimSpace(output)
+		if raw && !cfg.JSONMode {
+			fmt.Print(state.Value)
+			return nil
+		}
+		output := strings.TrimSpace(state.Value)
 
 		if cfg.JSONMode {
+			result := map[string]interface{}{"key": key, "value": output, "found": true}
 			// Try to parse as JSON value
 			var parsed interface{}
 			if json.Unmarshal([]byte(output), &parsed) == nil {
-				result := map[string]interface{}{
-					"key": key, "value": parsed, "found": true,
-				}
-				data, _ := json.Marshal(result)
-				fmt.Println(string(data))
-			} else {
-				result := map[string]interface{}{
-					"key": key, "value": output, "found": true,
-				}
-				data, _ := json.Marshal(result)
-				fmt.Println(string(data))
+				result["value"] = parsed
+			}
+			if len(state.Metadata) > 0 {
+				result["metadata"] = state.Metadata
 			}
+			if state.Expiration > 0 {
+				result["expiration"] = state.Expiration
+			}
+			data, _ := json.Marshal(result)
+			fmt.Println(string(data))
 			return nil
 		}
 
 		ui.PrintHeader(fmt.Sprintf("KV: %s", key))
-		// Pretty-print JSON if possible
-		var jsonVal interface{}
-		if json.Unmarshal([]byte(output), &jsonVal) == nil {
-			pretty, _ := json.MarshalIndent(jsonVal, "  ", "  ")
-			fmt.Printf("  %s\n", string(pretty))
+		// Pretty-print JSON if possible, keeping its key order
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, []byte(output), "  ", "  ") == nil {
+			fmt.Printf("  %s\n", pretty.String())
 		} else {
 			fmt.Printf("  %s\n", output)
 		}
+		if len(state.Metadata) > 0 || state.Expiration > 0 {
+			fmt.Println()
+		}
+		if len(state.Metadata) > 0 {
+			ui.PrintKeyValue("metadata  ", string(state.Metadata))
+		}
+		if state.Expiration > 0 {
+			ui.PrintKeyValue("expires   ", kvExpirationLabel(state.Expiration))
+		}
 
 		return nil
 	},
 }
 
+// kvExpirationLabel renders a Unix expiration with how far away it is.
+func kvExpirationLabel(expiration int64) string {
+	if expiration <= 0 {
+		return "never"
+	}
+	at := time.Unix(expiration, 0).UTC()
+	label := at.Format("2006-01-02 15:04:05 UTC")
+	if d := time.Until(at).Round(time.Second); d > 0 {
+		return label + " (in " + d.String() + ")"
+	}
+	return label + " (passed)"
+}
+
 // --- kv put ---
 
+// maxKVValueSize is the largest value KV stores.
+const maxKVValueSize = 25 << 20
+
+// readKVValue returns the value to write and whether it came from --file
+// or stdin rather than the command line. With neither a value argument nor
+// --file, a piped stdin is read.
+func readKVValue(args []string, file string) (string, bool, error) {
+	if len(args) > 0 {
+		if file != "" {
+			return "", false, fmt.Errorf("give the value as an argument or with --file, not both")
+		}
+		return args[0], false, nil
+	}
+	if file == "" {
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", false, fmt.Errorf("no value: pass it as an argument, with --file, or on stdin")
+		}
+		file = "-"
+	}
+
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", false, err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxKVValueSize+1))
+	if err != nil {
+		return "", false, err
+	}
+	if len(data) > maxKVValueSize {
+		return "", false, fmt.Errorf("value too large (KV stores at most %d MiB)", maxKVValueSize>>20)
+	}
+	return string(data), true, nil
+}
+
+// kvSchemaFor returns the first [[kv.schemas]] rule covering key in the
+// namespace alias, or nil.
+func kvSchemaFor(alias, key string) *config.KVSchema {
+	for i, rule := range config.Get().KV.Schemas {
+		if rule.Namespace != "" && rule.Namespace != alias {
+			continue
+		}
+		if rule.Keys != "" {
+			if ok, err := path.Match(rule.Keys, key); err != nil || !ok {
+				continue
+			}
+		}
+		return &config.Get().KV.Schemas[i]
+	}
+	return nil
+}
+
+// validateKVValue checks a value against a schema rule: it must be JSON,
+// and match the rule's JSON Schema if it names one.
+func validateKVValue(rule *config.KVSchema, key, value string) error {
+	if rule.Schema == "" {
+		if !json.Valid([]byte(value)) {
+			return fmt.Errorf("the value of %s must be JSON (kv schema for %q)", key, rule.Keys)
+		}
+		return nil
+	}
+	schemaPath := rule.Schema
+	if strings.HasPrefix(schemaPath, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			schemaPath = filepath.Join(home, schemaPath[2:])
+		}
+	} else if !filepath.IsAbs(schemaPath) {
+		schemaPath = filepath.Join(filepath.Dir(config.ConfigPath()), schemaPath)
+	}
+	schema, err := jsonschema.Load(schemaPath)
+	if err != nil {
+		return fmt.Errorf("cannot load kv schema: %w", err)
+	}
+	if problems := schema.Validate([]byte(value)); len(problems) > 0 {
+		return fmt.Errorf("the value of %s does not match %s:\n  %s", key, rule.Schema, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// compactJSON normalizes a JSON document for comparison; anything else is
+// returned trimmed.
+func compactJSON(s string) string {
+	var b bytes.Buffer
+	if json.Compact(&b, []byte(s)) == nil {
+		return b.String()
+	}
+	return strings.TrimSpace(s)
+}
+
+// kvDiffText is a value as it is diffed: JSON indented so a one-line
+// document diffs field by field, and binary data summarized.
+func kvDiffText(s string) string {
+	if !utf8.ValidString(s) {
+		return fmt.Sprintf("<binary, %d bytes, sha256 %s>", len(s), undo.Digest([]byte(s))[:12])
+	}
+	var b bytes.Buffer
+	if json.Indent(&b, []byte(s), "", "  ") == nil {
+		return b.String()
+	}
+	return s
+}
+
+// kvPutExpiration is the expiration a put leaves: absolute, from --ttl,
+// or none, since a put without either clears the old one.
+func kvPutExpiration(ttl, expiration int) int64 {
+	switch {
+	case expiration > 0:
+		return int64(expiration)
+	case ttl > 0:
+		return time.Now().Unix() + int64(ttl)
+	}
+	return 0
+}
+
+// printKVPutDiff shows what a put changes: the value line by line, then
+// metadata and expiration, which a put without them clears.
+func printKVPutDiff(alias, key string, before *undo.State, value, metadata string, expiration int64) {
+	ui.PrintHeader(fmt.Sprintf("KV put: %s → %s", key, alias))
+	if before == nil {
+		ui.Info("New key")
+		for _, line := range strings.Split(strings.TrimSuffix(kvDiffText(value), "\n"), "\n") {
+			fmt.Println(ui.SuccessStyle.Render("+ " + line))
+		}
+	} else if !printTextDiff(kvDiffText(before.Value), kvDiffText(value)) {
+		ui.Muted("  Value unchanged")
+	}
+
+	var oldMeta string
+	var oldExp int64
+	if before != nil {
+		oldMeta, oldExp = compactJSON(string(before.Metadata)), before.Expiration
+	}
+	if newMeta := compactJSON(metadata); newMeta != oldMeta {
+		fmt.Println()
+		ui.PrintKeyValue("metadata  ", orNone(oldMeta)+" → "+orNone(newMeta))
+		if newMeta == "" {
+			ui.Warning("The existing metadata will be removed; pass --metadata to keep it")
+		}
+	}
+	if expiration != oldExp {
+		ui.PrintKeyValue("expires   ", kvExpirationLabel(oldExp)+" → "+kvExpirationLabel(expiration))
+	}
+	fmt.Println()
+}
+
+// printTextDiff prints the changed hunks between two texts and reports
+// whether there were any.
+func printTextDiff(a, b string) bool {
+	lines := textdiff.Lines(a, b)
+	for i, hunk := range textdiff.Hunks(lines, 3) {
+		if i > 0 {
+			ui.Muted("  ⋯")
+		}
+		for _, l := range hunk {
+			switch l.Op {
+			case textdiff.Insert:
+				fmt.Println(ui.SuccessStyle.Render("+ " + l.Text))
+			case textdiff.Delete:
+				fmt.Println(ui.ErrorStyle.Render("- " + l.Text))
+			default:
+				fmt.Println("  " + l.Text)
+			}
+		}
+	}
+	return textdiff.Changed(lines)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
 var kvPutCmd = &cobra.Command{
-	Use:   "put <namespace> <key> <value>",
+	Use:   "put <namespace> <key> [value]",
 	Short: "Write a value to KV",
-	Args:  cobra.ExactArgs(3),
+	Long: `Write a value to KV after showing what it replaces.
+
+The current value is fetched first and the change shown as a diff, JSON
+pretty-printed so fields line up; a terminal then asks before writing
+(--yes skips the question). Writing the same value again is skipped.
+A put replaces metadata and expiration too: without --metadata, --ttl or
+--expiration they are cleared, and the diff says so.
+
+The value is the third argument, or read from --file (- for stdin), or
+from stdin when it is piped.
+
+Keys covered by a [[kv.schemas]] rule in gw.toml must hold JSON, and
+match the rule's JSON Schema when it names one:
+
+  [[kv.schemas]]
+  namespace = "flags"            # optional; any namespace when empty
+  keys = "*"                     # glob on the key
+  schema = "schemas/flag.json"   # optional; relative to ~/.grove`,
+	Args: cobra.RangeArgs(2, 3),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := requireCFSafety("kv_put"); err != nil {
+		if err := requireCFSafetyTarget("kv_put", safety.Target{Namespace: args[0]}); err != nil {
 			return err
 		}
 
@@ -265,7 +501,12 @@ var kvPutCmd = &cobra.Command{
 		if err := validateCFKey(key); err != nil {
 			return err
 		}
-		value := args[2]
+		file, _ := cmd.Flags().GetString("file")
+		yes, _ := cmd.Flags().GetBool("yes")
+		value, fromInput, err := readKVValue(args[2:], file)
+		if err != nil {
+			return err
+		}
 
 		ttl, _ := cmd.Flags().GetInt("ttl")
 		expiration, _ := cmd.Flags().GetInt("expiration")
@@ -282,8 +523,66 @@ var kvPutCmd = &cobra.Command{
 		if expiration < 0 {
 			return fmt.Errorf("expiration must be non-negative, got %d", expiration)
 		}
+		if metadata != "" {
+			if len(metadata) > maxCFMetadataLen {
+				return fmt.Errorf("metadata too large (max %d bytes)", maxCFMetadataLen)
+			}
+			if !json.Valid([]byte(metadata)) {
+				return fmt.Errorf("invalid JSON metadata: %s", metadata)
+			}
+		}
+		if rule := kvSchemaFor(args[0], key); rule != nil {
+			if err := validateKVValue(rule, key, value); err != nil {
+				return err
+			}
+		}
 
-		wranglerArgs := []string{"kv:key", "put", "--namespace-id", nsID, key, value}
+		before, err := kvSnapshot(nsID, key)
+		if err != nil {
+			return fmt.Errorf("cannot read the current value of %s: %w", key, err)
+		}
+		newExp := kvPutExpiration(ttl, expiration)
+		if before != nil && before.Value == value && compactJSON(string(before.Metadata)) == compactJSON(metadata) &&
+			before.Expiration == 0 && newExp == 0 {
+			if cfg.JSONMode {
+				data, _ := json.Marshal(map[string]interface{}{
+					"key": key, "namespace": args[0], "written": false, "unchanged": true,
+				})
+				fmt.Println(string(data))
+			} else {
+				ui.Muted(fmt.Sprintf("%s already holds this value; nothing written", key))
+			}
+			return nil
+		}
+
+		if !cfg.JSONMode {
+			printKVPutDiff(args[0], key, before, value, metadata, newExp)
+			if cfg.IsInteractive() && !yes && !cfg.DryRun && !ui.Confirm("Write this value?") {
+				ui.Muted("Cancelled")
+				return nil
+			}
+		}
+
+		wranglerArgs := []string{"kv:key", "put", "--namespace-id", nsID, key}
+		if fromInput {
+			// Values from a file or stdin go through a file of their own, so
+			// binary data and large values never pass through argv
+			tmp, err := os.CreateTemp("", "gw-kv-*")
+			if err != nil {
+				return err
+			}
+			defer os.Remove(tmp.Name())
+			_, err = tmp.WriteString(value)
+			if closeErr := tmp.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+			wranglerArgs = append(wranglerArgs, "--path", tmp.Name())
+		} else {
+			wranglerArgs = append(wranglerArgs, value)
+		}
 		if ttl > 0 {
 			wranglerArgs = append(wranglerArgs, "--ttl", fmt.Sprintf("%d", ttl))
 		}
@@ -291,30 +590,33 @@ var kvPutCmd = &cobra.Command{
 			wranglerArgs = append(wranglerArgs, "--expiration", fmt.Sprintf("%d", expiration))
 		}
 		if metadata != "" {
-			if len(metadata) > maxCFMetadataLen {
-				return fmt.Errorf("metadata too large (max %d bytes)", maxCFMetadataLen)
-			}
-			if !json.Valid([]byte(metadata)) {
-				return fmt.Errorf("invalid JSON metadata: %s", metadata)
-			}
 			wranglerArgs = append(wranglerArgs, "--metadata", metadata)
 		}
 
+		txn, err := journalKVSnapshot("gw kv put", args[0], nsID, key, before, &value)
+		if err != nil {
+			return err
+		}
+
 		result, err := exec.Wrangler(wranglerArgs...)
 		if err != nil {
+			txn.abort()
 			return fmt.Errorf("wrangler error: %w", err)
 		}
 		if !result.OK() {
+			txn.abort()
 			return fmt.Errorf("wrangler error: %s", result.Stderr)
 		}
+		txn.commit()
 
 		if cfg.JSONMode {
 			data, _ := json.Marshal(map[string]interface{}{
-				"key": key, "namespace": args[0], "written": true,
+				"key": key, "namespace": args[0], "written": true, "created": before == nil, "undo_id": txn.id(),
 			})
 			fmt.Println(string(data))
 		} else {
 			ui.Success(fmt.Sprintf("Written: %s → %s", key, args[0]))
+			txn.printUndoHint()
 		}
 		return nil
 	},
@@ -325,9 +627,30 @@ var kvPutCmd = &cobra.Command{
 var kvDeleteCmd = &cobra.Command{
 	Use:   "delete <namespace> <key>",
 	Short: "Delete a key from KV",
-	Args:  cobra.ExactArgs(2),
+	Long: `Delete one key, or with --prefix every key under a prefix.
+
+A prefix delete is a bulk delete: it needs --write --force and is capped
+at safety.max_kv_delete_keys keys (default 100); --max-keys raises the
+cap for one run. The deleted keys are saved to ~/.grove/kv-backups/ first.
+
+  gw kv delete cache session:abc --write
+  gw kv delete cache --prefix session: --write --force`,
+	Args: cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := requireCFSafety("kv_delete"); err != nil {
+		prefix, _ := cmd.Flags().GetString("prefix")
+		if (prefix == "") == (len(args) == 1) {
+			return fmt.Errorf("pass either a key or --prefix")
+		}
+		if prefix != "" {
+			nsID, err := resolveNamespace(args[0])
+			if err != nil {
+				return err
+			}
+			maxKeys, _ := cmd.Flags().GetInt("max-keys")
+			return kvDeletePrefix(args[0], nsID, prefix, maxKeys)
+		}
+
+		if err := requireCFSafetyTarget("kv_delete", safety.Target{Namespace: args[0]}); err != nil {
 			return err
 		}
 
@@ -341,21 +664,30 @@ var kvDeleteCmd = &cobra.Command{
 			return err
 		}
 
+		txn, err := journalKVChange("gw kv delete", args[0], nsID, key, nil)
+		if err != nil {
+			return err
+		}
+
 		result, err := exec.Wrangler("kv:key", "delete", "--namespace-id", nsID, key)
 		if err != nil {
+			txn.abort()
 			return fmt.Errorf("wrangler error: %w", err)
 		}
 		if !result.OK() {
+			txn.abort()
 			return fmt.Errorf("wrangler error: %s", result.Stderr)
 		}
+		txn.commit()
 
 		if cfg.JSONMode {
 			data, _ := json.Marshal(map[string]interface{}{
-				"key": key, "namespace": args[0], "deleted": true,
+				"key": key, "namespace": args[0], "deleted": true, "undo_id": txn.id(),
 			})
 			fmt.Println(string(data))
 		} else {
 			ui.Success(fmt.Sprintf("Deleted: %s from %s", key, args[0]))
+			txn.printUndoHint()
 		}
 		return nil
 	},
@@ -365,11 +697,18 @@ var kvHelpCategories = []ui.HelpCategory{
 	{Title: "Read (Always Safe)", Icon: "📖", Style: ui.SafeReadStyle, Commands: []ui.HelpCommand{
 		{Name: "list", Desc: "List KV namespaces"},
 		{Name: "keys", Desc: "List keys in a namespace"},
-		{Name: "get", Desc: "Get a value from KV"},
+		{Name: "get", Desc: "Get a value with metadata and expiration (--raw)"},
+		{Name: "export <ns>", Desc: "Dump keys, values and metadata to JSON (--prefix --out)"},
+		{Name: "diff <ns-a> <ns-b>", Desc: "Compare two namespaces (--prefix --keys-only)"},
 	}},
 	{Title: "Write (--write)", Icon: "✏️", Style: ui.SafeWriteStyle, Commands: []ui.HelpCommand{
-		{Name: "put", Desc: "Write a value to KV"},
+		{Name: "put", Desc: "Write a value after a diff of what it replaces (--file --ttl --metadata --yes)"},
 		{Name: "delete", Desc: "Delete a key from KV"},
+		{Name: "import <ns> <file>", Desc: "Apply an export after a diff preview (--prefix --preview)"},
+	}},
+	{Title: "Dangerous (--write --force)", Icon: "⚠️", Style: ui.DangerStyle, Commands: []ui.HelpCommand{
+		{Name: "delete <ns> --prefix <p>", Desc: "Bulk delete keys under a prefix (capped by max_kv_delete_keys)"},
+		{Name: "import --delete-missing", Desc: "Also delete keys the file lacks"},
 	}},
 }
 
@@ -390,14 +729,19 @@ func init() {
 	kvCmd.AddCommand(kvKeysCmd)
 
 	// kv get
+	kvGetCmd.Flags().Bool("raw", false, "Print only the value, exactly as stored")
 	kvCmd.AddCommand(kvGetCmd)
 
 	// kv put
 	kvPutCmd.Flags().Int("ttl", 0, "TTL in seconds")
 	kvPutCmd.Flags().Int("expiration", 0, "Expiration timestamp (Unix)")
 	kvPutCmd.Flags().StringP("metadata", "m", "", "JSON metadata")
+	kvPutCmd.Flags().String("file", "", "Read the value from a file (- for stdin)")
+	kvPutCmd.Flags().BoolP("yes", "y", false, "Write without asking after the diff")
 	kvCmd.AddCommand(kvPutCmd)
 
 	// kv delete
+	kvDeleteCmd.Flags().StringP("prefix", "p", "", "Delete every key with this prefix (bulk, needs --force)")
+	kvDeleteCmd.Flags().Int("max-keys", 0, "Raise the bulk delete ceiling for this run")
 	kvCmd.AddCommand(kvDeleteCmd)
 }
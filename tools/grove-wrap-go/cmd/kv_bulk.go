@@ -0,0 +1,613 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/kvbulk"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// kvFetchWorkers is how many values are read at once. Wrangler has no
+// bulk get, so every value is its own request.
+const kvFetchWorkers = 8
+
+// kvListing is one key as wrangler's key list reports it.
+type kvListing struct {
+	Name       string          `json:"name"`
+	Expiration int64           `json:"expiration"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+// kvList lists every key under prefix, sorted.
+func kvList(nsID, prefix string) ([]kvListing, error) {
+	args := []string{"kv:key", "list", "--namespace-id", nsID}
+	if prefix != "" {
+		args = append(args, "--prefix", prefix)
+	}
+	output, err := exec.WranglerOutput(args...)
+	if err != nil {
+		return nil, fmt.Errorf("wrangler error: %w", err)
+	}
+	var keys []kvListing
+	if err := json.Unmarshal([]byte(output), &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse keys: %w", err)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
+
+// kvFetch lists the keys under prefix and reads the values of those
+// withValue accepts (nil: all of them). Keys deleted between the listing
+// and the read are left out.
+func kvFetch(nsID, prefix string, withValue func(key string) bool) ([]kvbulk.Entry, error) {
+	keys, err := kvList(nsID, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]kvbulk.Entry, len(keys))
+	missing := make([]bool, len(keys))
+	errs := make([]error, len(keys))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < kvFetchWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				k := keys[i]
+				result, err := exec.Wrangler("kv:key", "get", "--namespace-id", nsID, k.Name)
+				switch {
+				case err != nil:
+					errs[i] = err
+				case !result.OK() && isNotFound(result.Stderr+result.Stdout):
+					missing[i] = true
+				case !result.OK():
+					errs[i] = fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+				default:
+					entries[i] = kvbulk.NewEntry(k.Name, []byte(result.Stdout), k.Expiration, k.Metadata)
+				}
+			}
+		}()
+	}
+	for i, k := range keys {
+		if withValue == nil || withValue(k.Name) {
+			jobs <- i
+		} else {
+			entries[i] = kvbulk.NewEntry(k.Name, nil, k.Expiration, k.Metadata)
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	var out []kvbulk.Entry
+	for i := range keys {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("wrangler error reading %s: %w", keys[i].Name, errs[i])
+		}
+		if !missing[i] {
+			out = append(out, entries[i])
+		}
+	}
+	return out, nil
+}
+
+// writeKVDump writes d as indented JSON. The file is private, since KV
+// values are often tokens and sessions.
+func writeKVDump(path string, d *kvbulk.Dump) error {
+	if d.Keys == nil {
+		d.Keys = []kvbulk.Entry{}
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// kvBackupPath is where the keys a bulk import or delete overwrites are
+// saved first.
+func kvBackupPath(alias string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".grove", "kv-backups")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%s.json", alias, time.Now().UTC().Format("20060102-150405.000"))
+	return filepath.Join(dir, name), nil
+}
+
+// backupKVEntries saves entries before a bulk change replaces or removes
+// them. Nothing is saved in a dry run or when there is nothing to lose.
+func backupKVEntries(alias, nsID string, entries []kvbulk.Entry) (string, error) {
+	if len(entries) == 0 || config.Get().DryRun {
+		return "", nil
+	}
+	path, err := kvBackupPath(alias)
+	if err != nil {
+		return "", fmt.Errorf("cannot back up keys: %w", err)
+	}
+	d := &kvbulk.Dump{
+		Namespace:   alias,
+		NamespaceID: nsID,
+		ExportedAt:  time.Now().UTC().Format(time.RFC3339),
+		Keys:        entries,
+	}
+	if err := writeKVDump(path, d); err != nil {
+		return "", fmt.Errorf("cannot back up keys: %w", err)
+	}
+	return path, nil
+}
+
+// kvBulkFile writes v to a temporary JSON file for wrangler's bulk
+// commands. The caller removes it.
+func kvBulkFile(v any) (string, error) {
+	f, err := os.CreateTemp("", "gw-kv-bulk-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := os.Chmod(f.Name(), 0600); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := json.NewEncoder(f).Encode(v); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// kvBulk runs `wrangler kv:bulk <verb>` over v.
+func kvBulk(verb, nsID string, v any) error {
+	path, err := kvBulkFile(v)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	args := []string{"kv:bulk", verb, "--namespace-id", nsID, path}
+	if verb == "delete" {
+		// gw has already run its own checks; skip wrangler's prompt
+		args = append(args, "--force")
+	}
+	result, err := exec.Wrangler(args...)
+	if err != nil {
+		return fmt.Errorf("wrangler error: %w", err)
+	}
+	if !result.OK() {
+		return fmt.Errorf("wrangler error: %s", strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+// requireKVBulkDelete applies the dangerous tier and the key ceiling to a
+// bulk delete of count keys. maxKeys, when set, raises the ceiling for one
+// run; agents cannot use it.
+func requireKVBulkDelete(alias string, count, maxKeys int) error {
+	if err := requireCFSafetyTarget("kv_bulk_delete", safety.Target{Namespace: alias}); err != nil {
+		return err
+	}
+	cfg := config.Get()
+	limit := cfg.EffectiveMaxKVDeleteKeys()
+	if maxKeys > 0 {
+		if cfg.AgentMode {
+			return fmt.Errorf("--max-keys is not available in agent mode")
+		}
+		limit = maxKeys
+	}
+	return safety.CheckKVBulkDelete(cfCheckOpts("kv_bulk_delete"), safety.Target{Namespace: alias}, count, limit)
+}
+
+// printKVChanges renders a KV diff for humans, at most limit lines.
+func printKVChanges(changes []kvbulk.Change, limit int) {
+	for i, c := range changes {
+		if i == limit {
+			ui.Muted(fmt.Sprintf("  ... and %d more", len(changes)-limit))
+			break
+		}
+		switch c.Op {
+		case "add":
+			fmt.Println(ui.SuccessStyle.Render("+ " + c.Key))
+		case "delete":
+			fmt.Println(ui.ErrorStyle.Render("- " + c.Key))
+		default:
+			fmt.Println(ui.WarningStyle.Render("~ " + c.Key + " (" + strings.Join(c.Fields, ", ") + ")"))
+		}
+	}
+}
+
+// kvChangeSummary reads like "2 added, 1 changed, 0 deleted".
+func kvChangeSummary(changes []kvbulk.Change) string {
+	add, change, del := kvbulk.Count(changes)
+	return fmt.Sprintf("%d added, %d changed, %d deleted", add, change, del)
+}
+
+// --- kv export ---
+
+var kvExportCmd = &cobra.Command{
+	Use:   "export <namespace>",
+	Short: "Dump keys, values, metadata and expirations to JSON",
+	Long: `Dump a namespace to JSON: every key under --prefix with its value,
+metadata and expiration. Values that are not UTF-8 are stored as base64.
+
+The file is written with --out (mode 0600) or to stdout, and is what
+gw kv import reads.
+
+  gw kv export cache --prefix session: --out sessions.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafetyTarget("kv_export", safety.Target{Namespace: args[0]}); err != nil {
+			return err
+		}
+		cfg := config.Get()
+		nsID, err := resolveNamespace(args[0])
+		if err != nil {
+			return err
+		}
+		prefix, _ := cmd.Flags().GetString("prefix")
+		outPath, _ := cmd.Flags().GetString("out")
+
+		entries, err := kvFetch(nsID, prefix, nil)
+		if err != nil {
+			return err
+		}
+		d := &kvbulk.Dump{
+			Namespace:   args[0],
+			NamespaceID: nsID,
+			Prefix:      prefix,
+			ExportedAt:  time.Now().UTC().Format(time.RFC3339),
+			Keys:        entries,
+		}
+		if outPath == "" {
+			return printJSON(d)
+		}
+		if err := writeKVDump(outPath, d); err != nil {
+			return err
+		}
+
+		if cfg.JSONMode {
+			return printJSON(map[string]interface{}{
+				"namespace": args[0],
+				"prefix":    prefix,
+				"keys":      len(entries),
+				"output":    outPath,
+			})
+		}
+		ui.Success(fmt.Sprintf("Exported %d keys from %s → %s", len(entries), args[0], outPath))
+		return nil
+	},
+}
+
+// --- kv import ---
+
+var kvImportCmd = &cobra.Command{
+	Use:   "import <namespace> <file.json>",
+	Short: "Apply an export to a namespace, showing the diff first",
+	Long: `Write the keys in a gw kv export file (or a wrangler bulk put file) to a
+namespace through wrangler's bulk endpoints.
+
+The diff against the namespace is shown first; --preview stops there.
+Only keys that are new or differ are written. Keys that have already
+expired are skipped.
+
+--delete-missing also deletes keys under the prefix that the file does
+not have. That is a bulk delete: it needs --write --force and is capped
+at safety.max_kv_delete_keys keys (default 100), which --max-keys raises
+for one run.
+
+Keys about to be overwritten or deleted are saved to ~/.grove/kv-backups/
+first; importing that file restores them.
+
+  gw kv import cache sessions.json --preview
+  gw kv import cache sessions.json --write
+  gw kv import cache sessions.json --prefix session: --delete-missing --write --force`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		alias, path := args[0], args[1]
+		nsID, err := resolveNamespace(alias)
+		if err != nil {
+			return err
+		}
+		prefix, _ := cmd.Flags().GetString("prefix")
+		deleteMissing, _ := cmd.Flags().GetBool("delete-missing")
+		preview, _ := cmd.Flags().GetBool("preview")
+		maxKeys, _ := cmd.Flags().GetInt("max-keys")
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		d, err := kvbulk.Read(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if prefix == "" {
+			prefix = d.Prefix
+		}
+
+		// Wrangler rejects expirations less than a minute away
+		soon := time.Now().Add(time.Minute).Unix()
+		var want []kvbulk.Entry
+		expired := 0
+		for _, e := range kvbulk.Filter(d.Keys, prefix) {
+			if e.Expiration > 0 && e.Expiration < soon {
+				expired++
+				continue
+			}
+			want = append(want, e)
+		}
+
+		inFile := make(map[string]bool, len(want))
+		for _, e := range want {
+			inFile[e.Key] = true
+		}
+		current, err := kvFetch(nsID, prefix, func(key string) bool { return inFile[key] || deleteMissing })
+		if err != nil {
+			return err
+		}
+
+		var changes []kvbulk.Change
+		for _, c := range kvbulk.Diff(current, want) {
+			if c.Op != "delete" || deleteMissing {
+				changes = append(changes, c)
+			}
+		}
+		var puts []kvbulk.Entry
+		var deletes []string
+		var overwritten []kvbulk.Entry
+		for _, c := range changes {
+			if c.To != nil {
+				puts = append(puts, *c.To)
+			} else {
+				deletes = append(deletes, c.Key)
+			}
+			if c.From != nil {
+				overwritten = append(overwritten, *c.From)
+			}
+		}
+
+		if cfg.JSONMode && (preview || len(changes) == 0) {
+			return printJSON(map[string]interface{}{
+				"namespace": alias,
+				"prefix":    prefix,
+				"preview":   true,
+				"expired":   expired,
+				"changes":   changesOrEmpty(changes),
+			})
+		}
+		if !cfg.JSONMode {
+			fmt.Print(ui.RenderInfoPanel(fmt.Sprintf("Import %s → %s", path, alias), [][2]string{
+				{"Prefix", prefixLabel(prefix)},
+				{"Keys in file", fmt.Sprintf("%d", len(want))},
+				{"Changes", kvChangeSummary(changes)},
+			}))
+			printKVChanges(changes, 50)
+			if expired > 0 {
+				ui.Warning(fmt.Sprintf("Skipping %d keys that have expired", expired))
+			}
+			if len(changes) == 0 {
+				ui.Success("Namespace already matches the file")
+				return nil
+			}
+			if preview {
+				return nil
+			}
+		}
+
+		if len(puts) > 0 {
+			if err := requireCFSafetyTarget("kv_import", safety.Target{Namespace: alias}); err != nil {
+				return err
+			}
+		}
+		if len(deletes) > 0 {
+			if err := requireKVBulkDelete(alias, len(deletes), maxKeys); err != nil {
+				return err
+			}
+		}
+
+		backup, err := backupKVEntries(alias, nsID, overwritten)
+		if err != nil {
+			return err
+		}
+		if len(puts) > 0 {
+			if err := kvBulk("put", nsID, puts); err != nil {
+				return err
+			}
+		}
+		if len(deletes) > 0 {
+			if err := kvBulk("delete", nsID, deletes); err != nil {
+				return err
+			}
+		}
+
+		if cfg.JSONMode {
+			return printJSON(map[string]interface{}{
+				"namespace": alias,
+				"prefix":    prefix,
+				"written":   len(puts),
+				"deleted":   len(deletes),
+				"expired":   expired,
+				"backup":    backup,
+				"changes":   changes,
+			})
+		}
+		ui.Success(fmt.Sprintf("Imported into %s: %s", alias, kvChangeSummary(changes)))
+		if backup != "" {
+			ui.Hint(fmt.Sprintf("Previous values saved; restore with: gw kv import %s %s --write", alias, backup))
+		}
+		return nil
+	},
+}
+
+// changesOrEmpty keeps JSON output an array when nothing changed.
+func changesOrEmpty(changes []kvbulk.Change) []kvbulk.Change {
+	if changes == nil {
+		return []kvbulk.Change{}
+	}
+	return changes
+}
+
+// prefixLabel shows an empty prefix as the whole namespace.
+func prefixLabel(prefix string) string {
+	if prefix == "" {
+		return "(all keys)"
+	}
+	return prefix
+}
+
+// --- kv diff ---
+
+var kvDiffCmd = &cobra.Command{
+	Use:   "diff <namespace-a> <namespace-b>",
+	Short: "Compare the keys and values of two namespaces",
+	Long: `Compare two namespaces key by key under --prefix: keys only in the
+second are shown as added (+), keys only in the first as deleted (-), and
+keys whose value, metadata or expiration differ as changed (~).
+
+--keys-only skips reading values, which is much faster on large
+namespaces but only notices metadata and expiration changes.
+
+  gw kv diff cache cache-staging --prefix config:`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		prefix, _ := cmd.Flags().GetString("prefix")
+		keysOnly, _ := cmd.Flags().GetBool("keys-only")
+		exitCode, _ := cmd.Flags().GetBool("exit-code")
+
+		if err := requireCFSafety("kv_diff"); err != nil {
+			return err
+		}
+		var sides [2][]kvbulk.Entry
+		for i, alias := range args {
+			nsID, err := resolveNamespace(alias)
+			if err != nil {
+				return err
+			}
+			var withValue func(string) bool
+			if keysOnly {
+				withValue = func(string) bool { return false }
+			}
+			entries, err := kvFetch(nsID, prefix, withValue)
+			if err != nil {
+				return fmt.Errorf("%s: %w", alias, err)
+			}
+			sides[i] = entries
+		}
+
+		changes := kvbulk.Diff(sides[0], sides[1])
+		if cfg.JSONMode {
+			if err := printJSON(map[string]interface{}{
+				"from":      args[0],
+				"to":        args[1],
+				"prefix":    prefix,
+				"keys_only": keysOnly,
+				"identical": len(changes) == 0,
+				"changes":   changesOrEmpty(changes),
+			}); err != nil {
+				return err
+			}
+		} else {
+			fmt.Print(ui.RenderInfoPanel("KV diff", [][2]string{
+				{"From", fmt.Sprintf("%s (%d keys)", args[0], len(sides[0]))},
+				{"To", fmt.Sprintf("%s (%d keys)", args[1], len(sides[1]))},
+				{"Prefix", prefixLabel(prefix)},
+			}))
+			if len(changes) == 0 {
+				ui.Success("Namespaces match")
+			} else {
+				printKVChanges(changes, 200)
+				fmt.Println()
+				ui.Warning(kvChangeSummary(changes))
+			}
+		}
+
+		if exitCode && len(changes) > 0 {
+			return exitStatus(1)
+		}
+		return nil
+	},
+}
+
+// kvDeletePrefix is gw kv delete --prefix: a bulk delete of every key
+// under prefix.
+func kvDeletePrefix(alias, nsID, prefix string, maxKeys int) error {
+	cfg := config.Get()
+	entries, err := kvFetch(nsID, prefix, func(string) bool { return false })
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		if cfg.JSONMode {
+			return printJSON(map[string]interface{}{"namespace": alias, "prefix": prefix, "deleted": 0})
+		}
+		ui.Muted(fmt.Sprintf("No keys under %s", prefix))
+		return nil
+	}
+	if err := requireKVBulkDelete(alias, len(entries), maxKeys); err != nil {
+		return err
+	}
+
+	// Read the values only once the delete is allowed, for the backup
+	entries, err = kvFetch(nsID, prefix, nil)
+	if err != nil {
+		return err
+	}
+	backup, err := backupKVEntries(alias, nsID, entries)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+	if err := kvBulk("delete", nsID, keys); err != nil {
+		return err
+	}
+
+	if cfg.JSONMode {
+		return printJSON(map[string]interface{}{
+			"namespace": alias, "prefix": prefix, "deleted": len(keys), "backup": backup,
+		})
+	}
+	ui.Success(fmt.Sprintf("Deleted %d keys under %s from %s", len(keys), prefix, alias))
+	if backup != "" {
+		ui.Hint(fmt.Sprintf("Restore with: gw kv import %s %s --write", alias, backup))
+	}
+	return nil
+}
+
+func init() {
+	kvExportCmd.Flags().StringP("prefix", "p", "", "Only keys with this prefix")
+	kvExportCmd.Flags().StringP("out", "o", "", "Write to a file instead of stdout")
+	kvCmd.AddCommand(kvExportCmd)
+
+	kvImportCmd.Flags().StringP("prefix", "p", "", "Only keys with this prefix (default: the export's prefix)")
+	kvImportCmd.Flags().Bool("delete-missing", false, "Delete keys under the prefix that the file lacks (needs --force)")
+	kvImportCmd.Flags().Bool("preview", false, "Show the diff without writing")
+	kvImportCmd.Flags().Int("max-keys", 0, "Raise the bulk delete ceiling for this run")
+	kvCmd.AddCommand(kvImportCmd)
+
+	kvDiffCmd.Flags().StringP("prefix", "p", "", "Only keys with this prefix")
+	kvDiffCmd.Flags().Bool("keys-only", false, "Compare keys, metadata and expirations without reading values")
+	kvDiffCmd.Flags().Bool("exit-code", false, "Exit 1 when the namespaces differ")
+	kvCmd.AddCommand(kvDiffCmd)
+}
@@ -0,0 +1,484 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	gwexec "github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/mcp"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// mcpCallTimeout bounds a single tool call. Deploys and migrations are the
+// slowest commands gw wraps.
+const mcpCallTimeout = 10 * time.Minute
+
+// mcpArgsProperty is the schema property holding positional arguments.
+const mcpArgsProperty = "args"
+
+// mcpExcluded lists command paths (without "gw") that are not published as
+// tools even though their tier is known: replay commands that would let a
+// tool call run a different command than the one it names, and commands
+// that need a terminal or a person at the keyboard.
+var mcpExcluded = map[string]bool{
+	"history run":        true,
+	"history rerun-last": true,
+	"d1 shell":           true,
+	"login":              true,
+	"auth login":         true,
+}
+
+// mcpOperations names the safety operation of commands whose path does not
+// spell it. A command that runs one of several operations depending on its
+// arguments maps to the strictest; each call is still checked against the
+// operation it actually runs.
+var mcpOperations = map[string]string{
+	"d1 query":              "d1_query_write",
+	"git bisect":            "bisect_status", // the guided loop needs a terminal
+	"git branch":            "branch_delete",
+	"git stash":             "stash_drop",
+	"git cherry-pick":       "cherry_pick",
+	"gh api":                "api_delete",
+	"gh rate-limit":         "rate_limit",
+	"gh issue comments":     "issue_view",
+	"gh pr comments":        "pr_view",
+	"gh pr diff":            "pr_view",
+	"gh project set":        "project_field",
+	"gh project batch-move": "project_bulk",
+	"config tenant":         "config_tenant_set",
+	"loft ssh-key show":     "loft_ssh_key_show",
+	"loft ssh-key set":      "loft_ssh_key_set",
+	"logout":                "grove_logout",
+	"whoami":                "grove_whoami",
+	"warden agent enroll":   "warden_agent_register",
+	"todo list-projects":    "todoist_list_projects",
+	"todo list-sections":    "todoist_list_sections",
+	"todo list-tasks":       "todoist_list_tasks",
+	"todo create-task":      "todoist_create_task",
+	"todo create-section":   "todoist_create_section",
+	"todo update-task":      "todoist_update_task",
+	"todo complete-task":    "todoist_complete_task",
+	"todo batch":            "todoist_batch",
+	"todo delete-task":      "todoist_delete_task",
+	"todo clear-section":    "todoist_clear_section",
+}
+
+// mcpReadOnly lists commands with no safety operation that only read local
+// state, published at the read tier.
+var mcpReadOnly = map[string]bool{
+	"audit list":     true,
+	"audit verify":   true,
+	"history list":   true,
+	"history search": true,
+	"policy explain": true,
+	"plugin list":    true,
+	"doctor":         true,
+	"health":         true,
+	"version":        true,
+}
+
+// mcpHiddenFlags are global flags the server sets itself. --write and
+// --force stay visible so tools can request them, and are checked by the
+// same safety tiers as on the command line.
+var mcpHiddenFlags = map[string]bool{
+	"help":        true,
+	"json":        true,
+	"agent":       true,
+	"verbose":     true,
+	"interactive": true,
+	"no-cloud":    true,
+}
+
+const mcpInstructions = `Each tool runs one gw command in agent mode with JSON output.
+
+Safety tiers apply exactly as on the command line: WRITE tools need
+"write": true, and DANGEROUS or PROTECTED operations are always refused
+for agents. Positional arguments go in "args" in the order shown in the
+tool description.`
+
+// mcpCmd is the parent command for the Model Context Protocol server.
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve gw commands as Model Context Protocol tools",
+	Long: `Serve gw commands to MCP clients.
+
+Every command with a known safety tier is published as a tool named after
+its path (gw d1 query → gw_d1_query) with a JSON schema built from its
+flags. Commands without a tier, and interactive ones such as gw d1 shell,
+are not published. Tool calls run in agent mode, so the safety tiers
+refuse dangerous operations exactly as they do for an agent in the shell.`,
+}
+
+// --- mcp serve ---
+
+var mcpServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run an MCP server on stdin/stdout",
+	Long: `Run a Model Context Protocol server over stdio.
+
+Register it with an MCP client as:
+
+  { "command": "gw", "args": ["mcp", "serve"] }
+
+Each tool call starts gw again with --agent --json, so every call is
+checked, audited, and recorded in history on its own.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tools, _ := mcpTools(rootCmd)
+		server := &mcp.Server{
+			Name:         "gw",
+			Version:      Version,
+			Instructions: mcpInstructions,
+			Tools:        tools,
+			Call:         mcpCall,
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// stdout carries the protocol; anything for humans goes to stderr
+		fmt.Fprintf(os.Stderr, "gw MCP server ready (%d tools)\n", len(tools))
+		return server.Serve(ctx, os.Stdin, os.Stdout)
+	},
+}
+
+// --- mcp tools ---
+
+var mcpToolsCmd = &cobra.Command{
+	Use:   "tools",
+	Short: "List the tools gw mcp serve publishes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		tools, tiers := mcpTools(rootCmd)
+
+		if cfg.JSONMode {
+			return printJSON(map[string]any{"tools": tools})
+		}
+
+		headers := []string{"Tool", "Tier", "Description"}
+		rows := make([][]string, 0, len(tools))
+		for _, t := range tools {
+			rows = append(rows, []string{t.Name, tiers[t.Name].String(), TruncateStr(t.Title, 60)})
+		}
+		fmt.Print(ui.RenderTable(fmt.Sprintf("MCP Tools (%d)", len(tools)), headers, rows))
+		return nil
+	},
+}
+
+// mcpTools builds a tool for every published command under root, sorted
+// by name, along with each tool's safety tier.
+func mcpTools(root *cobra.Command) ([]mcp.Tool, map[string]safety.Tier) {
+	var tools []mcp.Tool
+	tiers := map[string]safety.Tier{}
+	for _, c := range mcpCommands(root) {
+		tier, _ := mcpToolTier(c)
+		tool := mcpToolFor(c, tier)
+		tools = append(tools, tool)
+		tiers[tool.Name] = tier
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools, tiers
+}
+
+// mcpCommands returns the commands under root that are published as tools:
+// runnable, visible, not excluded, and with a known tier.
+func mcpCommands(root *cobra.Command) []*cobra.Command {
+	var out []*cobra.Command
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		for _, sub := range c.Commands() {
+			if sub.Hidden || sub.Deprecated != "" || sub.Name() == "mcp" || sub.Name() == "help" || sub.Name() == "completion" {
+				continue
+			}
+			if _, known := mcpToolTier(sub); sub.Runnable() && known && !mcpExcluded[mcpCommandPath(sub)] {
+				out = append(out, sub)
+			}
+			walk(sub)
+		}
+	}
+	walk(root)
+	return out
+}
+
+// mcpToolTier returns a command's tier under the active policy, and false
+// when the command has no known safety operation.
+func mcpToolTier(c *cobra.Command) (safety.Tier, bool) {
+	path := mcpCommandPath(c)
+	if mcpReadOnly[path] {
+		return safety.TierRead, true
+	}
+	op, ok := mcpOperations[path]
+	var base safety.Tier
+	if ok {
+		base, _, ok = safety.LookupOperation(op)
+	} else {
+		op, base, ok = commandOperation(c)
+	}
+	if !ok {
+		return safety.TierRead, false
+	}
+	return safety.ActivePolicy().Resolve(op, base, safety.Target{}).Tier, true
+}
+
+// mcpCommandPath is the command path without the root name, which changes
+// when gw is invoked through an alias.
+func mcpCommandPath(c *cobra.Command) string {
+	parts := strings.Fields(c.CommandPath())
+	return strings.Join(parts[1:], " ")
+}
+
+// mcpToolName maps a command to its tool name: gw d1 query → gw_d1_query.
+func mcpToolName(c *cobra.Command) string {
+	return "gw_" + strings.ReplaceAll(mcpCommandPath(c), " ", "_")
+}
+
+// mcpToolFor describes one command of the given tier as a tool.
+func mcpToolFor(c *cobra.Command, tier safety.Tier) mcp.Tool {
+
+	properties := map[string]any{}
+	var required []string
+	addFlag := func(f *pflag.Flag) {
+		if f.Hidden || mcpHiddenFlags[f.Name] {
+			return
+		}
+		if _, seen := properties[f.Name]; seen {
+			return
+		}
+		properties[f.Name] = mcpFlagSchema(f)
+		if req, ok := f.Annotations[cobra.BashCompOneRequiredFlag]; ok && len(req) > 0 && req[0] == "true" {
+			required = append(required, f.Name)
+		}
+	}
+	c.LocalFlags().VisitAll(addFlag)
+	c.InheritedFlags().VisitAll(addFlag)
+
+	usage := strings.TrimSpace(strings.TrimPrefix(c.Use, c.Name()))
+	if usage != "" {
+		properties[mcpArgsProperty] = map[string]any{
+			"type":        "array",
+			"items":       map[string]any{"type": "string"},
+			"description": "Positional arguments: " + usage,
+		}
+	}
+	sort.Strings(required)
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	description := strings.TrimSpace(c.Long)
+	if description == "" {
+		description = c.Short
+	}
+	description = fmt.Sprintf("gw %s — safety tier %s.\n\n%s", mcpCommandPath(c), tier, description)
+
+	return mcp.Tool{
+		Name:        mcpToolName(c),
+		Title:       c.Short,
+		Description: description,
+		InputSchema: schema,
+		Annotations: &mcp.ToolAnnotations{
+			Title:           c.Short,
+			ReadOnlyHint:    tier == safety.TierRead,
+			DestructiveHint: tier >= safety.TierDangerous,
+			OpenWorldHint:   true,
+		},
+	}
+}
+
+// mcpFlagSchema maps a pflag type to a JSON schema.
+func mcpFlagSchema(f *pflag.Flag) map[string]any {
+	schema := map[string]any{"description": f.Usage}
+	switch typ := f.Value.Type(); {
+	case typ == "bool":
+		schema["type"] = "boolean"
+		if f.DefValue == "true" {
+			schema["default"] = true
+		}
+	case typ == "count" || strings.HasPrefix(typ, "int") && !strings.HasSuffix(typ, "Slice") ||
+		strings.HasPrefix(typ, "uint") && !strings.HasSuffix(typ, "Slice"):
+		schema["type"] = "integer"
+		if n, err := strconv.ParseInt(f.DefValue, 10, 64); err == nil && n != 0 {
+			schema["default"] = n
+		}
+	case strings.HasPrefix(typ, "float"):
+		schema["type"] = "number"
+	case strings.HasSuffix(typ, "Slice") || strings.HasSuffix(typ, "Array"):
+		schema["type"] = "array"
+		schema["items"] = map[string]any{"type": "string"}
+	default:
+		schema["type"] = "string"
+		if f.DefValue != "" {
+			schema["default"] = f.DefValue
+		}
+	}
+	return schema
+}
+
+// mcpArgv turns tool arguments into a gw command line. Flags are passed as
+// --name=value so values starting with "-" are never read as flags, and
+// positional arguments follow "--" for the same reason.
+func mcpArgv(c *cobra.Command, args map[string]any) ([]string, error) {
+	argv := strings.Fields(mcpCommandPath(c))
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var positional []string
+	for _, name := range names {
+		value := args[name]
+		if name == mcpArgsProperty {
+			list, ok := value.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%q must be an array", name)
+			}
+			for _, v := range list {
+				s, err := mcpScalar(v)
+				if err != nil {
+					return nil, fmt.Errorf("%q: %w", name, err)
+				}
+				positional = append(positional, s)
+			}
+			continue
+		}
+
+		f := c.Flags().Lookup(name)
+		if f == nil {
+			f = c.InheritedFlags().Lookup(name)
+		}
+		if f == nil || f.Hidden || mcpHiddenFlags[name] {
+			return nil, fmt.Errorf("unknown argument %q", name)
+		}
+
+		if list, ok := value.([]any); ok {
+			for _, v := range list {
+				s, err := mcpScalar(v)
+				if err != nil {
+					return nil, fmt.Errorf("%q: %w", name, err)
+				}
+				argv = append(argv, "--"+name+"="+s)
+			}
+			continue
+		}
+		s, err := mcpScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", name, err)
+		}
+		argv = append(argv, "--"+name+"="+s)
+	}
+
+	argv = append(argv, "--agent", "--json")
+	if len(positional) > 0 {
+		if !c.DisableFlagParsing {
+			argv = append(argv, "--")
+		}
+		argv = append(argv, positional...)
+	}
+	return argv, nil
+}
+
+// mcpScalar formats one JSON value as a command-line string.
+func mcpScalar(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+// mcpCall runs one tool call as a child gw process in agent mode and turns
+// its output into a tool result.
+func mcpCall(ctx context.Context, name string, args map[string]any) (*mcp.ToolResult, error) {
+	var target *cobra.Command
+	for _, c := range mcpCommands(rootCmd) {
+		if mcpToolName(c) == name {
+			target = c
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("unknown tool %q", name)
+	}
+
+	argv, err := mcpArgv(target, args)
+	if err != nil {
+		return mcp.TextResult(err.Error(), true), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mcpCallTimeout)
+	defer cancel()
+
+	result, err := gwexec.RunSelf(ctx, []string{"MCP_SERVER=1", "GW_AGENT_MODE=1"}, argv...)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return mcp.TextResult(fmt.Sprintf("gw %s timed out after %s", mcpCommandPath(target), mcpCallTimeout), true), nil
+		}
+		return mcp.TextResult("cancelled", true), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mcpResult(result), nil
+}
+
+// mcpResult converts a finished command into a tool result. JSON output is
+// returned as structured content as well as text; a failed command's stderr
+// is included so the model sees why it was refused.
+func mcpResult(r *gwexec.Result) *mcp.ToolResult {
+	stdout := strings.TrimSpace(r.Stdout)
+	stderr := strings.TrimSpace(r.Stderr)
+
+	text := stdout
+	if !r.OK() {
+		text = strings.TrimSpace(strings.Join([]string{stdout, stderr}, "\n"))
+		if text == "" {
+			text = fmt.Sprintf("exit status %d", r.ExitCode)
+		}
+	}
+
+	result := mcp.TextResult(text, !r.OK())
+	var structured any
+	if stdout != "" && json.Unmarshal([]byte(stdout), &structured) == nil {
+		if obj, ok := structured.(map[string]any); ok {
+			result.StructuredContent = obj
+		} else {
+			result.StructuredContent = map[string]any{"result": structured}
+		}
+	}
+	return result
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+	mcpCmd.AddCommand(mcpServeCmd)
+	mcpCmd.AddCommand(mcpToolsCmd)
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	gwexec "github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+)
+
+func findTool(t *testing.T, path ...string) *cobra.Command {
+	t.Helper()
+	target, _, err := rootCmd.Find(path)
+	if err != nil {
+		t.Fatalf("Find(%v): %v", path, err)
+	}
+	return target
+}
+
+func TestMCPToolsSkipExcludedCommands(t *testing.T) {
+	tools, tiers := mcpTools(rootCmd)
+	names := map[string]bool{}
+	for _, tool := range tools {
+		names[tool.Name] = true
+	}
+	for _, want := range []string{"gw_d1_query", "gw_kv_put", "gw_history_list"} {
+		if !names[want] {
+			t.Errorf("tool %s missing", want)
+		}
+	}
+	// Untiered commands such as history clear, and interactive ones, are
+	// not published.
+	for _, skip := range []string{"gw_mcp_serve", "gw_history_run", "gw_history_rerun-last",
+		"gw_history_clear", "gw_d1_shell", "gw_login", "gw_auth_login", "gw_update"} {
+		if names[skip] {
+			t.Errorf("tool %s should not be published", skip)
+		}
+	}
+	if tiers["gw_kv_put"] != safety.TierWrite {
+		t.Errorf("gw_kv_put tier = %s, want WRITE", tiers["gw_kv_put"])
+	}
+	if tiers["gw_d1_query"] != safety.TierWrite || tiers["gw_todo_delete-task"] != safety.TierDangerous {
+		t.Errorf("mapped tiers: d1 query %s, todo delete-task %s", tiers["gw_d1_query"], tiers["gw_todo_delete-task"])
+	}
+}
+
+func TestMCPReadOnlyHint(t *testing.T) {
+	tools, _ := mcpTools(rootCmd)
+	hints := map[string]bool{}
+	for _, tool := range tools {
+		hints[tool.Name] = tool.Annotations.ReadOnlyHint
+	}
+	for name, want := range map[string]bool{"gw_kv_get": true, "gw_history_list": true, "gw_kv_put": false, "gw_d1_query": false} {
+		if got, ok := hints[name]; !ok || got != want {
+			t.Errorf("%s ReadOnlyHint = %v (published %v), want %v", name, got, ok, want)
+		}
+	}
+}
+
+func TestMCPCallRefusesUnpublishedTools(t *testing.T) {
+	if _, err := mcpCall(context.Background(), "gw_history_clear", nil); err == nil || !strings.Contains(err.Error(), "unknown tool") {
+		t.Errorf("calling an unpublished command should fail, got %v", err)
+	}
+}
+
+func TestMCPToolSchemaFromFlags(t *testing.T) {
+	tool := mcpToolFor(findTool(t, "d1", "query"), safety.TierWrite)
+	props := tool.InputSchema["properties"].(map[string]any)
+
+	if typ := props["limit"].(map[string]any)["type"]; typ != "integer" {
+		t.Errorf("limit type = %v, want integer", typ)
+	}
+	if typ := props["remote"].(map[string]any)["type"]; typ != "boolean" {
+		t.Errorf("remote type = %v, want boolean", typ)
+	}
+	if _, ok := props["write"]; !ok {
+		t.Error("write should be exposed so tools can request it")
+	}
+	for _, hidden := range []string{"json", "agent", "interactive", "help"} {
+		if _, ok := props[hidden]; ok {
+			t.Errorf("%s should not be exposed", hidden)
+		}
+	}
+	if _, ok := props[mcpArgsProperty]; !ok {
+		t.Error("positional args missing")
+	}
+}
+
+func TestMCPArgv(t *testing.T) {
+	argv, err := mcpArgv(findTool(t, "d1", "query"), map[string]any{
+		"args":   []any{"-- SELECT 1"},
+		"limit":  float64(5),
+		"remote": true,
+	})
+	if err != nil {
+		t.Fatalf("mcpArgv: %v", err)
+	}
+	want := "d1 query --limit=5 --remote=true --agent --json -- -- SELECT 1"
+	if got := strings.Join(argv, " "); got != want {
+		t.Errorf("argv = %q, want %q", got, want)
+	}
+
+	for _, bad := range []map[string]any{
+		{"agent": false},
+		{"bogus": "x"},
+		{"args": "not a list"},
+		{"limit": map[string]any{}},
+	} {
+		if _, err := mcpArgv(findTool(t, "d1", "query"), bad); err == nil {
+			t.Errorf("mcpArgv(%v) should fail", bad)
+		}
+	}
+}
+
+func TestMCPResult(t *testing.T) {
+	ok := mcpResult(&gwexec.Result{Stdout: `{"rows": [1]}` + "\n"})
+	if ok.IsError || ok.StructuredContent["rows"] == nil {
+		t.Errorf("JSON output should be structured: %+v", ok)
+	}
+
+	list := mcpResult(&gwexec.Result{Stdout: `[1, 2]`})
+	if list.StructuredContent["result"] == nil {
+		t.Errorf("non-object JSON should be wrapped: %+v", list)
+	}
+
+	blocked := mcpResult(&gwexec.Result{Stderr: "operation 'kv_put' requires --write flag\n", ExitCode: 1})
+	if !blocked.IsError || blocked.Content[0].Text != "operation 'kv_put' requires --write flag" {
+		t.Errorf("blocked result = %+v", blocked)
+	}
+}
+
+func TestMCPOperationsNameRealCommands(t *testing.T) {
+	check := func(path string) {
+		c, _, err := rootCmd.Find(strings.Fields(path))
+		if err != nil || mcpCommandPath(c) != path {
+			t.Errorf("%q is not a command", path)
+		}
+	}
+	for path, op := range mcpOperations {
+		check(path)
+		if _, _, ok := safety.LookupOperation(op); !ok {
+			t.Errorf("%q maps to unknown operation %s", path, op)
+		}
+	}
+	for path := range mcpReadOnly {
+		check(path)
+	}
+	for path := range mcpExcluded {
+		check(path)
+	}
+}
@@ -0,0 +1,404 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	gwexec "github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/plugin"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// discoveredPlugins are the plugins found at startup, runnable or not.
+var discoveredPlugins []*plugin.Plugin
+
+// reservedCommandNames are created by Cobra on demand and so are not yet
+// in rootCmd.Commands() when plugins are registered.
+var reservedCommandNames = map[string]bool{"help": true, "completion": true}
+
+// registerPlugins discovers gw-<name> plugins and adds a command for each.
+// Built-in commands always win over a plugin of the same name.
+func registerPlugins() {
+	discoveredPlugins = plugin.Discover(config.PluginDir(), filepath.SplitList(os.Getenv("PATH")))
+	for _, p := range discoveredPlugins {
+		if builtinCommand(p.Name) {
+			p.Err = fmt.Errorf("shadowed by the built-in gw %s command", p.Name)
+			continue
+		}
+		if p.Err == nil {
+			if err := p.Register(); err != nil {
+				p.Err = err
+			}
+		}
+		rootCmd.AddCommand(pluginCommand(p))
+	}
+}
+
+// builtinCommand reports whether name is taken by a compiled-in command.
+func builtinCommand(name string) bool {
+	if reservedCommandNames[name] {
+		return true
+	}
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// pluginCommand wraps a plugin in a Cobra command. Flag parsing is left to
+// the plugin; gw's own global flags are picked out of the arguments first.
+func pluginCommand(p *plugin.Plugin) *cobra.Command {
+	return &cobra.Command{
+		Use:                p.Name + " [args...]",
+		Short:              p.Description(),
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rest, err := extractGlobalFlags(args)
+			if err != nil {
+				return err
+			}
+			// Global flags only just got parsed; refresh config and audit
+			rootCmd.PersistentPreRun(cmd, rest)
+			return runPlugin(p, rest)
+		},
+	}
+}
+
+// extractGlobalFlags removes gw's persistent flags (--write, --json, ...)
+// from a plugin's arguments and applies them. Everything after "--" is
+// passed through untouched, without the "--" itself.
+func extractGlobalFlags(args []string) ([]string, error) {
+	flags := rootCmd.PersistentFlags()
+	var rest []string
+	for i, a := range args {
+		if a == "--" {
+			return append(rest, args[i+1:]...), nil
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		var f *pflag.Flag
+		if strings.HasPrefix(a, "--") {
+			f = flags.Lookup(name)
+		} else if strings.HasPrefix(a, "-") && len(name) == 1 {
+			f = flags.ShorthandLookup(name)
+		}
+		if f == nil || f.Value.Type() != "bool" {
+			rest = append(rest, a)
+			continue
+		}
+		if !hasValue {
+			value = "true"
+		}
+		if err := flags.Set(f.Name, value); err != nil {
+			return nil, fmt.Errorf("invalid value for --%s: %w", f.Name, err)
+		}
+	}
+	return rest, nil
+}
+
+// runPlugin checks the plugin's declared tier and runs it.
+func runPlugin(p *plugin.Plugin, args []string) error {
+	inv, err := p.Resolve(args)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.Get()
+	if err := safety.CheckPluginSafety(inv.Operation, inv.Tier, cfg.WriteFlag, cfg.ForceFlag, cfg.AgentMode, cfg.IsInteractive()); err != nil {
+		return err
+	}
+
+	// Only read-tier plugins run in a dry run; the rest are planned
+	if cfg.DryRun && inv.Tier > safety.TierRead {
+		gwexec.Plan(gwexec.Planned{Binary: p.Path, Args: args})
+		return nil
+	}
+
+	ctx := pluginContext(p, inv, args)
+	var stdin []byte
+	if p.Manifest.StdinConfig {
+		if stdin, err = json.Marshal(ctx); err != nil {
+			return err
+		}
+	}
+
+	code, err := gwexec.RunPlugin(p.Path, args, pluginEnv(ctx), stdin)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return exitStatus(code)
+	}
+	return nil
+}
+
+// pluginInvocation is the resolved context handed to a plugin, as JSON on
+// stdin (stdin_config = true) and as GW_* environment variables.
+type pluginInvocation struct {
+	Version     string   `json:"gw_version"`
+	Plugin      string   `json:"plugin"`
+	Command     string   `json:"command"`
+	Operation   string   `json:"operation"`
+	Tier        string   `json:"tier"`
+	Args        []string `json:"args"`
+	GroveRoot   string   `json:"grove_root"`
+	Tenant      string   `json:"tenant"`
+	ConfigFile  string   `json:"config_file"`
+	AgentMode   bool     `json:"agent_mode"`
+	JSONMode    bool     `json:"json_mode"`
+	Write       bool     `json:"write"`
+	Force       bool     `json:"force"`
+	Interactive bool     `json:"interactive"`
+	Verbose     bool     `json:"verbose"`
+	NoCloud     bool     `json:"no_cloud"`
+	DryRun      bool     `json:"dry_run"`
+	// Config is the non-secret part of gw.toml: resource aliases and
+	// Grove URLs. Tokens are never passed on.
+	Config map[string]any `json:"config"`
+}
+
+// pluginContext gathers what a plugin is told about the invocation.
+func pluginContext(p *plugin.Plugin, inv *plugin.Invocation, args []string) pluginInvocation {
+	cfg := config.Get()
+	if args == nil {
+		args = []string{}
+	}
+	return pluginInvocation{
+		Version:     Version,
+		Plugin:      p.Name,
+		Command:     inv.Command,
+		Operation:   inv.Operation,
+		Tier:        inv.Tier.String(),
+		Args:        args,
+		GroveRoot:   cfg.GroveRoot,
+		Tenant:      cfg.Grove.Tenant,
+		ConfigFile:  config.ConfigPath(),
+		AgentMode:   cfg.AgentMode,
+		JSONMode:    cfg.JSONMode,
+		Write:       cfg.WriteFlag,
+		Force:       cfg.ForceFlag,
+		Interactive: cfg.IsInteractive(),
+		Verbose:     cfg.Verbose,
+		NoCloud:     cfg.NoCloud,
+		DryRun:      cfg.DryRun,
+		Config: map[string]any{
+			"databases":     cfg.Databases,
+			"kv_namespaces": cfg.KVNamespaces,
+			"r2_buckets":    cfg.R2Buckets,
+			"github":        map[string]any{"owner": cfg.GitHub.Owner, "repo": cfg.GitHub.Repo},
+			"grove": map[string]any{
+				"tenant":           cfg.Grove.Tenant,
+				"default_region":   cfg.Grove.DefaultRegion,
+				"auth_base_url":    cfg.Grove.AuthBaseURL,
+				"lattice_base_url": cfg.Grove.LatticeBaseURL,
+			},
+		},
+	}
+}
+
+// pluginEnv renders the context as GW_* environment variables.
+func pluginEnv(ctx pluginInvocation) []string {
+	flag := func(b bool) string {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+	return []string{
+		"GW_VERSION=" + ctx.Version,
+		"GW_PLUGIN=" + ctx.Plugin,
+		"GW_PLUGIN_COMMAND=" + ctx.Command,
+		"GW_OPERATION=" + ctx.Operation,
+		"GW_TIER=" + ctx.Tier,
+		"GW_GROVE_ROOT=" + ctx.GroveRoot,
+		"GW_TENANT=" + ctx.Tenant,
+		"GW_CONFIG_FILE=" + ctx.ConfigFile,
+		"GW_AGENT_MODE=" + flag(ctx.AgentMode),
+		"GW_JSON=" + flag(ctx.JSONMode),
+		"GW_WRITE=" + flag(ctx.Write),
+		"GW_FORCE=" + flag(ctx.Force),
+		"GW_INTERACTIVE=" + flag(ctx.Interactive),
+		"GW_VERBOSE=" + flag(ctx.Verbose),
+		"GW_NO_CLOUD=" + flag(ctx.NoCloud),
+		"GW_DRY_RUN=" + flag(ctx.DryRun),
+	}
+}
+
+// pluginHelpCategory lists runnable plugins for the root help screen.
+func pluginHelpCategory() []ui.HelpCategory {
+	var commands []ui.HelpCommand
+	for _, p := range discoveredPlugins {
+		if p.Err == nil {
+			commands = append(commands, ui.HelpCommand{Name: p.Name, Desc: p.Description()})
+		}
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	return []ui.HelpCategory{{
+		Title:    "Plugins",
+		Icon:     "🧩",
+		Style:    ui.SafeWriteStyle,
+		Commands: commands,
+	}}
+}
+
+// pluginCmd is the parent command for plugin inspection.
+var pluginCmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "Inspect external gw-<name> plugins",
+	Long: `Inspect external plugins.
+
+gw runs any gw-<name> executable in ~/.grove/plugins/ or on PATH as
+gw <name>. A manifest beside it (gw-<name>.toml, or
+~/.grove/plugins/<name>.toml) declares the safety tier of each
+subcommand; gw checks that tier before running the plugin, exactly as
+for built-in commands.
+
+  description  = "Preview environments"
+  default_tier = "read"        # undeclared subcommands; omit to refuse them
+  stdin_config = false         # true: context as JSON on stdin
+
+  [[commands]]
+  name = "create"
+  tier = "write"
+
+Plugins receive the invocation and resolved config as GW_* environment
+variables (GW_GROVE_ROOT, GW_TENANT, GW_AGENT_MODE, GW_JSON, GW_WRITE,
+GW_TIER, GW_DRY_RUN, ...). In a dry run only read-tier subcommands
+run; the rest are listed with the other skipped calls.
+
+gw's global flags are consumed by gw; put "--" before arguments meant
+for the plugin that look like them.`,
+}
+
+// --- plugin list ---
+
+var pluginListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List discovered plugins",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+
+		if cfg.JSONMode {
+			list := make([]map[string]any, 0, len(discoveredPlugins))
+			for _, p := range discoveredPlugins {
+				item := map[string]any{
+					"name":     p.Name,
+					"path":     p.Path,
+					"manifest": p.ManifestPath,
+					"runnable": p.Err == nil,
+				}
+				if p.Err != nil {
+					item["error"] = p.Err.Error()
+				}
+				if p.Manifest != nil {
+					item["commands"] = p.Manifest.Commands
+					item["default_tier"] = p.Manifest.DefaultTier
+				}
+				list = append(list, item)
+			}
+			return printJSON(map[string]any{"plugins": list, "count": len(list)})
+		}
+
+		if len(discoveredPlugins) == 0 {
+			ui.Muted("No plugins found.")
+			ui.Hint("Install gw-<name> executables in " + config.PluginDir() + " or on PATH")
+			return nil
+		}
+
+		headers := []string{"Name", "Commands", "Status", "Path"}
+		var rows [][]string
+		for _, p := range discoveredPlugins {
+			status := "ok"
+			if p.Err != nil {
+				status = TruncateStr(p.Err.Error(), 40)
+			}
+			count := "—"
+			if p.Manifest != nil {
+				count = fmt.Sprintf("%d", len(p.Manifest.Commands))
+			}
+			rows = append(rows, []string{p.Name, count, status, p.Path})
+		}
+		fmt.Print(ui.RenderTable(fmt.Sprintf("Plugins (%d)", len(discoveredPlugins)), headers, rows))
+		ui.Hint("gw plugin show <name> for declared subcommands and tiers")
+		return nil
+	},
+}
+
+// --- plugin show ---
+
+var pluginShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a plugin's declared subcommands and tiers",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		var p *plugin.Plugin
+		for _, candidate := range discoveredPlugins {
+			if candidate.Name == args[0] {
+				p = candidate
+			}
+		}
+		if p == nil {
+			return fmt.Errorf("no plugin named %q (see gw plugin list)", args[0])
+		}
+
+		if cfg.JSONMode {
+			result := map[string]any{
+				"name":     p.Name,
+				"path":     p.Path,
+				"manifest": p.ManifestPath,
+				"runnable": p.Err == nil,
+			}
+			if p.Err != nil {
+				result["error"] = p.Err.Error()
+			}
+			if p.Manifest != nil {
+				result["description"] = p.Manifest.Description
+				result["commands"] = p.Manifest.Commands
+				result["default_tier"] = p.Manifest.DefaultTier
+				result["stdin_config"] = p.Manifest.StdinConfig
+			}
+			return printJSON(result)
+		}
+
+		pairs := [][2]string{
+			{"Path", p.Path},
+			{"Manifest", p.ManifestPath},
+		}
+		if p.Err != nil {
+			pairs = append(pairs, [2]string{"Error", p.Err.Error()})
+		}
+		if p.Manifest != nil && p.Manifest.DefaultTier != "" {
+			pairs = append(pairs, [2]string{"Default tier", strings.ToUpper(p.Manifest.DefaultTier)})
+		}
+		fmt.Print(ui.RenderInfoPanel("gw "+p.Name, pairs))
+
+		if p.Manifest != nil && len(p.Manifest.Commands) > 0 {
+			headers := []string{"Subcommand", "Tier", "Operation", "Description"}
+			var rows [][]string
+			for _, c := range p.Manifest.Commands {
+				rows = append(rows, []string{c.Name, strings.ToUpper(c.Tier), c.Operation, c.Description})
+			}
+			fmt.Print(ui.RenderTable("Declared subcommands", headers, rows))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pluginCmd)
+	pluginCmd.AddCommand(pluginListCmd)
+	pluginCmd.AddCommand(pluginShowCmd)
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractGlobalFlags(t *testing.T) {
+	defer func() {
+		flagWrite, flagForce, flagJSON = false, false, false
+	}()
+
+	rest, err := extractGlobalFlags([]string{"create", "--write", "-j", "--name", "x", "--force=false", "-v=false", "--", "--write"})
+	if err != nil {
+		t.Fatalf("extractGlobalFlags() error: %v", err)
+	}
+	want := []string{"create", "--name", "x", "--write"}
+	if !reflect.DeepEqual(rest, want) {
+		t.Errorf("rest = %q, want %q", rest, want)
+	}
+	if !flagWrite || !flagJSON || flagForce {
+		t.Errorf("flags write=%v json=%v force=%v, want true/true/false", flagWrite, flagJSON, flagForce)
+	}
+
+	if _, err := extractGlobalFlags([]string{"--write=maybe"}); err == nil {
+		t.Error("invalid boolean value should be an error")
+	}
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// policyPaths returns the policy files in load order: user, then repo.
+func policyPaths() []string {
+	cfg := config.Get()
+	return []string{config.PolicyPath(), config.RepoPolicyPath(cfg.GroveRoot)}
+}
+
+// loadPolicyFiles loads the user and repo policy files.
+func loadPolicyFiles() (*safety.Policy, error) {
+	paths := policyPaths()
+	return safety.LoadUserAndRepoPolicy(paths[0], paths[1])
+}
+
+// loadSafetyPolicy loads the policy files and installs them for all safety
+// checks. Broken files are reported on stderr and skipped so a typo never
+// takes gw down; `gw policy show` surfaces the same error.
+func loadSafetyPolicy() {
+	policy, err := loadPolicyFiles()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: ignoring invalid safety policy: %v\n", err)
+	}
+	safety.SetPolicy(policy)
+}
+
+// policyCmd is the parent command for safety policy inspection.
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Inspect the safety policy that overrides built-in tiers",
+	Long: `Inspect the declarative safety policy.
+
+Rules are read from ~/.grove/policy.toml and then <grove root>/.grove/policy.toml.
+The last matching rule wins, except that repo rules can only raise a tier:
+the repo file comes with whatever was cloned. Set trust_repo = true at the
+top of ~/.grove/policy.toml to let repo rules lower tiers too.
+
+  [[rules]]
+  operation = "merge"
+  branch    = "release/*"
+  tier      = "protected"
+  reason    = "release branches only move through the release workflow"
+
+The scope fields only match operations that name that resource: branch
+for git operations given a branch, database for D1 queries, migrations,
+exports, imports, the shell and backups, namespace for KV, and worker for
+deploy and secret apply/sync/unapply. Operations without a target, such
+as r2_* and flag_*, are never matched by a scoped rule.`,
+}
+
+// --- policy show ---
+
+var policyShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "List loaded policy rules",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		policy, loadErr := loadPolicyFiles()
+
+		if cfg.JSONMode {
+			result := map[string]any{
+				"files": policyPaths(),
+				"rules": policy.Rules,
+			}
+			if loadErr != nil {
+				result["error"] = loadErr.Error()
+			}
+			return printJSON(result)
+		}
+
+		if loadErr != nil {
+			ui.Warning(loadErr.Error())
+		}
+		if len(policy.Rules) == 0 {
+			ui.Muted("No policy rules — built-in tiers apply.")
+			for _, p := range policyPaths() {
+				if p != "" {
+					ui.Hint("  " + p)
+				}
+			}
+			return nil
+		}
+
+		headers := []string{"#", "Operation", "Scope", "Tier", "Source"}
+		var rows [][]string
+		for i, r := range policy.Rules {
+			rows = append(rows, []string{
+				fmt.Sprintf("%d", i+1),
+				r.Operation,
+				ruleScope(r),
+				ruleTier(r),
+				r.Describe(),
+			})
+		}
+		fmt.Print(ui.RenderTable(fmt.Sprintf("Safety Policy (%d rules)", len(policy.Rules)), headers, rows))
+		return nil
+	},
+}
+
+// --- policy explain ---
+
+var policyExplainCmd = &cobra.Command{
+	Use:   "explain <operation>",
+	Short: "Show which rule decides an operation's tier",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		operation := args[0]
+
+		var target safety.Target
+		target.Branch, _ = cmd.Flags().GetString("branch")
+		target.Database, _ = cmd.Flags().GetString("db")
+		target.Namespace, _ = cmd.Flags().GetString("namespace")
+		target.Worker, _ = cmd.Flags().GetString("worker")
+
+		policy, loadErr := loadPolicyFiles()
+		base, domain, known := safety.LookupOperation(operation)
+		decision := policy.Resolve(operation, base, target)
+
+		decidedBy := "built-in"
+		if !known {
+			decidedBy = "default (unknown operation)"
+		}
+		if decision.Rule != nil {
+			decidedBy = decision.Rule.Describe()
+		}
+		// Force-pushing to a protected branch is refused before the policy
+		// is consulted; mirror that here so explain never disagrees with gw.
+		if operation == "push_force" && safety.IsProtectedBranch(target.Branch, cfg.Git.ProtectedBranches) {
+			decision.Tier = safety.TierProtected
+			decidedBy = "built-in (protected branch)"
+		}
+
+		if cfg.JSONMode {
+			result := map[string]any{
+				"operation":  operation,
+				"domain":     domain,
+				"known":      known,
+				"target":     target,
+				"base_tier":  decision.BaseTier.String(),
+				"tier":       decision.Tier.String(),
+				"decided_by": decidedBy,
+				"matched":    decision.Matched,
+			}
+			if loadErr != nil {
+				result["error"] = loadErr.Error()
+			}
+			return printJSON(result)
+		}
+
+		if loadErr != nil {
+			ui.Warning(loadErr.Error())
+		}
+
+		if domain == "" {
+			domain = "unknown"
+		}
+		pairs := [][2]string{
+			{"Operation", operation},
+			{"Domain", domain},
+			{"Built-in", decision.BaseTier.String()},
+			{"Effective", decision.Tier.String()},
+			{"Decided by", decidedBy},
+		}
+		if decision.Rule != nil && decision.Rule.Reason != "" {
+			pairs = append(pairs, [2]string{"Reason", decision.Rule.Reason})
+		}
+		fmt.Print(ui.RenderInfoPanel("Policy: "+operation, pairs))
+
+		if len(decision.Matched) > 1 {
+			headers := []string{"Rule", "Scope", "Tier"}
+			var rows [][]string
+			for _, r := range decision.Matched {
+				rows = append(rows, []string{r.Describe(), ruleScope(r), ruleTier(r)})
+			}
+			fmt.Print(ui.RenderTable("Matching rules (last wins)", headers, rows))
+		}
+		ui.Muted(decision.Tier.Description())
+		return nil
+	},
+}
+
+// ruleTier renders a rule's tier, marking untrusted repo rules.
+func ruleTier(r safety.Rule) string {
+	if r.RaiseOnly {
+		return r.Tier + " (raise only)"
+	}
+	return r.Tier
+}
+
+// ruleScope renders a rule's scope fields as "branch=release/* db=amber".
+func ruleScope(r safety.Rule) string {
+	scope := ""
+	for _, kv := range [][2]string{
+		{"branch", r.Branch},
+		{"db", r.Database},
+		{"namespace", r.Namespace},
+		{"worker", r.Worker},
+	} {
+		if kv[1] == "" {
+			continue
+		}
+		if scope != "" {
+			scope += " "
+		}
+		scope += kv[0] + "=" + kv[1]
+	}
+	if scope == "" {
+		return "*"
+	}
+	return scope
+}
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+
+	// policy show
+	policyCmd.AddCommand(policyShowCmd)
+
+	// policy explain
+	policyExplainCmd.Flags().String("branch", "", "Target branch")
+	policyExplainCmd.Flags().StringP("db", "d", "", "Target database alias")
+	policyExplainCmd.Flags().String("namespace", "", "Target KV namespace alias")
+	policyExplainCmd.Flags().String("worker", "", "Target worker name")
+	policyCmd.AddCommand(policyExplainCmd)
+}
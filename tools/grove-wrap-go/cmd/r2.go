@@ -172,16 +172,7 @@ var r2LsCmd = &cobra.Command{
 			return fmt.Errorf("wrangler error: %w", err)
 		}
 
-		// Parse response — could be {"objects": [...]} or raw array
-		var objects []map[string]interface{}
-		var wrapper map[string]interface{}
-		if json.Unmarshal([]byte(output), &wrapper) == nil {
-			if objs, ok := wrapper["objects"].([]interface{}); ok {
-				objects = interfaceToMaps(objs)
-			}
-		} else {
-			json.Unmarshal([]byte(output), &objects)
-		}
+		objects := parseR2Objects(output)
 
 		// Apply limit
 		if len(objects) > limit {
@@ -348,24 +339,33 @@ var r2RmCmd = &cobra.Command{
 			return err
 		}
 
+		txn, err := journalR2Delete("gw r2 rm", bucket, key)
+		if err != nil {
+			return err
+		}
+
 		result, err := exec.Wrangler("r2", "object", "delete", bucket+"/"+key, "--remote")
 		if err != nil {
+			txn.abort()
 			return fmt.Errorf("wrangler error: %w", err)
 		}
 		if !result.OK() {
 			// Ignore "key not found" type messages
 			if !strings.Contains(result.Stderr, "not found") {
+				txn.abort()
 				return fmt.Errorf("wrangler error: %s", result.Stderr)
 			}
 		}
+		txn.commit()
 
 		if cfg.JSONMode {
 			data, _ := json.Marshal(map[string]interface{}{
-				"bucket": bucket, "key": key, "deleted": true,
+				"bucket": bucket, "key": key, "deleted": true, "undo_id": txn.id(),
 			})
 			fmt.Println(string(data))
 		} else {
 			ui.Success(fmt.Sprintf("Deleted: %s/%s", bucket, key))
+			txn.printUndoHint()
 		}
 		return nil
 	},
@@ -381,6 +381,10 @@ var r2HelpCategories = []ui.HelpCategory{
 		{Name: "get", Desc: "Download an object"},
 		{Name: "put", Desc: "Upload an object"},
 		{Name: "rm", Desc: "Delete an object"},
+		{Name: "sync <src> <dst>", Desc: "Sync a directory with bucket/prefix (--include --exclude --jobs --preview)"},
+	}},
+	{Title: "Dangerous (--write --force)", Icon: "⚠️", Style: ui.DangerStyle, Commands: []ui.HelpCommand{
+		{Name: "sync --delete", Desc: "Also remove destination files the source lacks"},
 	}},
 }
 
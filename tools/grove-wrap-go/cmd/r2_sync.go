@@ -0,0 +1,472 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/r2sync"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+)
+
+// r2TransferTimeout bounds one upload or download.
+const r2TransferTimeout = 10 * time.Minute
+
+// maxR2SyncJobs caps --jobs.
+const maxR2SyncJobs = 32
+
+// parseR2Objects reads an object listing, which wrangler prints either as
+// {"objects": [...]} or as a bare array.
+func parseR2Objects(output string) []map[string]interface{} {
+	var objects []map[string]interface{}
+	var wrapper map[string]interface{}
+	if json.Unmarshal([]byte(output), &wrapper) == nil {
+		if objs, ok := wrapper["objects"].([]interface{}); ok {
+			objects = interfaceToMaps(objs)
+		}
+	} else {
+		json.Unmarshal([]byte(output), &objects)
+	}
+	return objects
+}
+
+// r2SyncTarget is the bucket side of a sync.
+type r2SyncTarget struct {
+	Bucket string
+	Prefix string // without a trailing slash; "" for the whole bucket
+}
+
+func (t r2SyncTarget) key(rel string) string {
+	if t.Prefix == "" {
+		return rel
+	}
+	return t.Prefix + "/" + rel
+}
+
+func (t r2SyncTarget) String() string {
+	if t.Prefix == "" {
+		return "r2://" + t.Bucket
+	}
+	return "r2://" + t.Bucket + "/" + t.Prefix
+}
+
+// parseR2SyncArgs works out which argument is the directory and which the
+// bucket. A bucket may be written r2://bucket/prefix; otherwise the
+// argument that is an existing directory is the local side.
+func parseR2SyncArgs(src, dst string) (dir string, target r2SyncTarget, upload bool, err error) {
+	isRemote := func(s string) bool { return strings.HasPrefix(s, "r2://") }
+	isDir := func(s string) bool {
+		info, err := os.Stat(s)
+		return err == nil && info.IsDir()
+	}
+
+	var remote string
+	switch {
+	case isRemote(src) && isRemote(dst):
+		return "", target, false, fmt.Errorf("both sides are buckets; one must be a local directory")
+	case isRemote(dst):
+		dir, remote, upload = src, dst, true
+	case isRemote(src):
+		dir, remote, upload = dst, src, false
+	case isDir(src) && !isDir(dst):
+		dir, remote, upload = src, dst, true
+	case isDir(dst) && !isDir(src):
+		dir, remote, upload = dst, src, false
+	default:
+		return "", target, false, fmt.Errorf("cannot tell which of %q and %q is the bucket; write it as r2://<bucket>/<prefix>", src, dst)
+	}
+
+	remote = strings.TrimPrefix(remote, "r2://")
+	bucket, prefix, _ := strings.Cut(remote, "/")
+	if err := validateCFName(bucket, "bucket"); err != nil {
+		return "", target, false, err
+	}
+	prefix = strings.Trim(prefix, "/")
+	if strings.Contains("/"+prefix+"/", "/../") {
+		return "", target, false, fmt.Errorf("prefix must not contain '..': %s", prefix)
+	}
+	return dir, r2SyncTarget{Bucket: bucket, Prefix: prefix}, upload, nil
+}
+
+// r2SyncList lists the objects under a target, keyed relative to it.
+func r2SyncList(t r2SyncTarget, filter *r2sync.Filter) ([]r2sync.Object, error) {
+	args := []string{"r2", "object", "list", t.Bucket}
+	if t.Prefix != "" {
+		args = append(args, "--prefix", t.Prefix+"/")
+	}
+	output, err := exec.WranglerOutput(args...)
+	if err != nil {
+		return nil, fmt.Errorf("wrangler error: %w", err)
+	}
+
+	var objects []r2sync.Object
+	for _, obj := range parseR2Objects(output) {
+		key, _ := obj["key"].(string)
+		rel := key
+		if t.Prefix != "" {
+			if !strings.HasPrefix(key, t.Prefix+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(key, t.Prefix+"/")
+		}
+		if rel == "" || strings.HasSuffix(rel, "/") || !filter.Match(rel) {
+			continue
+		}
+		o := r2sync.Object{Rel: rel}
+		if s, ok := obj["size"].(float64); ok {
+			o.Size = int64(s)
+		}
+		for _, field := range []string{"etag", "httpEtag", "http_etag"} {
+			if e, ok := obj[field].(string); ok && e != "" {
+				o.ETag = e
+				break
+			}
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// r2SyncTransfer uploads or downloads one file.
+func r2SyncTransfer(a r2sync.Action, dir string, t r2SyncTarget) error {
+	local, err := r2sync.LocalPath(dir, a.Rel)
+	if err != nil {
+		return err
+	}
+	key := t.key(a.Rel)
+	if err := validateCFKey(key); err != nil {
+		return err
+	}
+
+	var args []string
+	var tmp string
+	switch a.Op {
+	case "upload":
+		args = []string{"r2", "object", "put", t.Bucket + "/" + key, "--file", local, "--remote"}
+		if ct := mime.TypeByExtension(path.Ext(a.Rel)); ct != "" {
+			args = append(args, "--content-type", ct)
+		}
+	case "download":
+		// A download only writes locally, so dry-run would not catch it
+		if config.Get().DryRun {
+			exec.Plan(exec.Planned{Binary: "wrangler", Args: []string{"r2", "object", "get", t.Bucket + "/" + key, "--file", local, "--remote"}})
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+			return err
+		}
+		f, err := os.CreateTemp(filepath.Dir(local), ".gw-sync-*")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		tmp = f.Name()
+		defer os.Remove(tmp)
+		args = []string{"r2", "object", "get", t.Bucket + "/" + key, "--file", tmp, "--remote"}
+	default:
+		return fmt.Errorf("not a transfer: %s", a.Op)
+	}
+
+	result, err := exec.WranglerWithTimeout(r2TransferTimeout, args...)
+	if err != nil {
+		return err
+	}
+	if !result.OK() {
+		return fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+	}
+	if tmp != "" {
+		if err := os.Chmod(tmp, 0644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, local)
+	}
+	return nil
+}
+
+// r2SyncDelete removes one extra: an object after journaling it for undo,
+// or a local file.
+func r2SyncDelete(a r2sync.Action, dir string, t r2SyncTarget, upload bool) (int, error) {
+	if !upload {
+		if config.Get().DryRun {
+			return 0, nil
+		}
+		local, err := r2sync.LocalPath(dir, a.Rel)
+		if err != nil {
+			return 0, err
+		}
+		return 0, os.Remove(local)
+	}
+	key := t.key(a.Rel)
+	txn, err := journalR2Delete("gw r2 sync --delete", t.Bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	result, err := exec.Wrangler("r2", "object", "delete", t.Bucket+"/"+key, "--remote")
+	if err == nil && !result.OK() && !isNotFound(result.Stderr) {
+		err = fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+	}
+	if err != nil {
+		txn.abort()
+		return 0, err
+	}
+	txn.commit()
+	return txn.id(), nil
+}
+
+// r2SyncFailure is a path that could not be synced.
+type r2SyncFailure struct {
+	Path  string `json:"path"`
+	Op    string `json:"op"`
+	Error string `json:"error"`
+}
+
+// --- r2 sync ---
+
+var r2SyncCmd = &cobra.Command{
+	Use:   "sync <source> <destination>",
+	Short: "Sync a directory with a bucket prefix",
+	Long: `Make the destination a copy of the source, rsync style. One side is a
+local directory, the other a bucket written bucket/prefix or
+r2://bucket/prefix (the r2:// form is needed when the directory does not
+exist yet, or both arguments could be directories).
+
+Files are compared by size, then by MD5 against the object's ETag, and
+only what differs is transferred, --jobs at a time. Uploads get a
+Content-Type from the file extension.
+
+--include and --exclude take globs: * and ? within a path segment, **
+across segments. A pattern without a slash matches at any depth, and a
+pattern that matches a directory covers everything in it.
+
+The plan is shown before anything runs; --preview stops there. Transfers
+need --write. --delete also removes destination files the source lacks;
+that is a dangerous operation and needs --write --force. Objects deleted
+this way can be restored with gw undo.
+
+  gw r2 sync ./static grove-assets/static --exclude "*.map" --write
+  gw r2 sync r2://grove-media/tenants/demo ./seed/demo --write
+  gw r2 sync ./dist grove-assets/app --delete --preview`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		include, _ := cmd.Flags().GetStringSlice("include")
+		exclude, _ := cmd.Flags().GetStringSlice("exclude")
+		del, _ := cmd.Flags().GetBool("delete")
+		preview, _ := cmd.Flags().GetBool("preview")
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		if jobs < 1 {
+			jobs = 1
+		}
+		if jobs > maxR2SyncJobs {
+			jobs = maxR2SyncJobs
+		}
+
+		dir, target, upload, err := parseR2SyncArgs(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		filter, err := r2sync.NewFilter(include, exclude)
+		if err != nil {
+			return err
+		}
+
+		var files []r2sync.File
+		if _, statErr := os.Stat(dir); statErr == nil || upload {
+			if files, err = r2sync.Walk(dir, filter); err != nil {
+				return err
+			}
+		}
+		objects, err := r2SyncList(target, filter)
+		if err != nil {
+			return err
+		}
+		actions, unchanged, err := r2sync.Plan(files, objects, upload, del, func(f r2sync.File) (string, error) {
+			return r2sync.FileMD5(f.Path)
+		})
+		if err != nil {
+			return err
+		}
+
+		var transfers, deletes []r2sync.Action
+		var bytes int64
+		for _, a := range actions {
+			if a.Op == "delete" {
+				deletes = append(deletes, a)
+			} else {
+				transfers = append(transfers, a)
+				bytes += a.Size
+			}
+		}
+		from, to := dir, target.String()
+		if !upload {
+			from, to = to, dir
+		}
+
+		if cfg.JSONMode && (preview || len(actions) == 0) {
+			if actions == nil {
+				actions = []r2sync.Action{}
+			}
+			return printJSON(map[string]interface{}{
+				"from": from, "to": to, "preview": true,
+				"unchanged": unchanged, "actions": actions,
+			})
+		}
+		if !cfg.JSONMode {
+			verb := "upload"
+			if !upload {
+				verb = "download"
+			}
+			fmt.Print(ui.RenderInfoPanel(fmt.Sprintf("Sync %s → %s", from, to), [][2]string{
+				{"Unchanged", fmt.Sprintf("%d", unchanged)},
+				{"To " + verb, fmt.Sprintf("%d (%s)", len(transfers), formatSize(float64(bytes)))},
+				{"To delete", fmt.Sprintf("%d", len(deletes))},
+			}))
+			printR2SyncPlan(actions, 50)
+			if len(actions) == 0 {
+				ui.Success("Already in sync")
+				return nil
+			}
+			if preview {
+				return nil
+			}
+		}
+
+		if len(transfers) > 0 {
+			if err := requireCFSafety("r2_sync"); err != nil {
+				return err
+			}
+		}
+		if len(deletes) > 0 {
+			if err := requireCFSafety("r2_sync_delete"); err != nil {
+				return err
+			}
+		}
+
+		var failures []r2SyncFailure
+		var mu sync.Mutex
+		fail := func(a r2sync.Action, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failures = append(failures, r2SyncFailure{Path: a.Rel, Op: a.Op, Error: err.Error()})
+			if !cfg.JSONMode {
+				fmt.Println(ui.ErrorStyle.Render(fmt.Sprintf("✗ %s: %v", a.Rel, err)))
+			}
+		}
+
+		queue := make(chan r2sync.Action)
+		var wg sync.WaitGroup
+		for w := 0; w < jobs; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for a := range queue {
+					if err := r2SyncTransfer(a, dir, target); err != nil {
+						fail(a, err)
+						continue
+					}
+					if !cfg.JSONMode {
+						mu.Lock()
+						fmt.Printf("  %s %s (%s)\n", r2SyncArrow(a.Op), a.Rel, formatSize(float64(a.Size)))
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+		for _, a := range transfers {
+			queue <- a
+		}
+		close(queue)
+		wg.Wait()
+
+		// Deletes run after every transfer, one at a time, so a failed
+		// upload never leaves the destination with less than it had
+		var undoIDs []int
+		if len(failures) == 0 {
+			for _, a := range deletes {
+				id, err := r2SyncDelete(a, dir, target, upload)
+				if err != nil {
+					fail(a, err)
+					continue
+				}
+				if id != 0 {
+					undoIDs = append(undoIDs, id)
+				}
+				if !cfg.JSONMode {
+					fmt.Printf("  %s %s\n", r2SyncArrow(a.Op), a.Rel)
+				}
+			}
+		} else if len(deletes) > 0 && !cfg.JSONMode {
+			ui.Warning(fmt.Sprintf("Skipped %d deletes because transfers failed", len(deletes)))
+		}
+
+		if cfg.JSONMode {
+			if failures == nil {
+				failures = []r2SyncFailure{}
+			}
+			if err := printJSON(map[string]interface{}{
+				"from": from, "to": to, "unchanged": unchanged,
+				"actions": actions, "failures": failures, "undo_ids": undoIDs,
+			}); err != nil {
+				return err
+			}
+		} else if len(failures) == 0 {
+			ui.Success(fmt.Sprintf("Synced %s → %s: %d transferred, %d deleted", from, to, len(transfers), len(deletes)))
+			if len(undoIDs) > 0 {
+				ui.Hint(fmt.Sprintf("Deleted objects are journaled; gw undo %d (and earlier) restores them", undoIDs[len(undoIDs)-1]))
+			}
+		}
+		if len(failures) > 0 {
+			return fmt.Errorf("%d of %d operations failed", len(failures), len(actions))
+		}
+		return nil
+	},
+}
+
+// r2SyncArrow marks an action in progress output.
+func r2SyncArrow(op string) string {
+	switch op {
+	case "upload":
+		return ui.SuccessStyle.Render("↑")
+	case "download":
+		return ui.SuccessStyle.Render("↓")
+	}
+	return ui.ErrorStyle.Render("✗")
+}
+
+// printR2SyncPlan lists planned actions, at most limit lines.
+func printR2SyncPlan(actions []r2sync.Action, limit int) {
+	for i, a := range actions {
+		if i == limit {
+			ui.Muted(fmt.Sprintf("  ... and %d more", len(actions)-limit))
+			break
+		}
+		line := fmt.Sprintf("%s (%s, %s)", a.Rel, a.Reason, formatSize(float64(a.Size)))
+		switch {
+		case a.Op == "delete":
+			fmt.Println(ui.ErrorStyle.Render("- " + line))
+		case a.Reason == "new":
+			fmt.Println(ui.SuccessStyle.Render("+ " + line))
+		default:
+			fmt.Println(ui.WarningStyle.Render("~ " + line))
+		}
+	}
+}
+
+func init() {
+	r2SyncCmd.Flags().StringSlice("include", nil, "Only sync paths matching this glob (repeatable)")
+	r2SyncCmd.Flags().StringSlice("exclude", nil, "Skip paths matching this glob (repeatable)")
+	r2SyncCmd.Flags().Bool("delete", false, "Delete destination files the source lacks (needs --force)")
+	r2SyncCmd.Flags().Bool("preview", false, "Show the plan without transferring")
+	r2SyncCmd.Flags().Int("jobs", 4, "Transfers to run at once")
+	r2Cmd.AddCommand(r2SyncCmd)
+}
@@ -1,16 +1,15 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
-	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/historydb"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
 )
 
@@ -19,9 +18,6 @@ var Version = "dev"
 var CommitHash = ""
 var BuildTime = ""
 
-// cmdStartTime records when the command began executing.
-var cmdStartTime time.Time
-
 var (
 	flagWrite       bool
 	flagForce       bool
@@ -29,6 +25,7 @@ var (
 	flagAgent       bool
 	flagVerbose     bool
 	flagNoCloud     bool
+	flagDryRun      bool
 	flagInteractive bool
 )
 
@@ -40,11 +37,14 @@ and the dev toolchain behind a safety-tiered interface.
 
 Every tool in the grove was shaped by fire and patience.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		cmdStartTime = time.Now()
-		config.Init(flagWrite, flagForce, flagJSON, flagAgent, flagVerbose, flagNoCloud, flagInteractive)
+		config.Init(flagWrite, flagForce, flagJSON || replayModes.json, flagAgent || replayModes.agent, flagVerbose,
+			flagNoCloud || replayModes.noCloud, dryRunRequested(cmd) || replayModes.dryRun, flagInteractive)
 		ui.SetVerbose(flagVerbose)
 		cfg := config.Get()
 		ui.SetPlain(!cfg.IsHumanMode())
+		loadSafetyPolicy()
+		setupDryRun()
+		installAuditObserver(cmd.CommandPath())
 
 		// Detect alias invocation (grove, mycel, mycelium → gw)
 		if len(os.Args) > 0 {
@@ -55,19 +55,6 @@ Every tool in the grove was shaped by fire and patience.`,
 			}
 		}
 	},
-	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		// Record command in history (best-effort, never fail the command)
-		db, err := historydb.Open()
-		if err != nil {
-			return
-		}
-		cmdName := cmd.CommandPath()
-		cmdArgs := strings.Join(args, " ")
-		isWrite := flagWrite
-		durationMS := time.Since(cmdStartTime).Milliseconds()
-		// Exit code 0 since PersistentPostRun only runs on success
-		_ = db.RecordCommand(cmdName, cmdArgs, isWrite, 0, durationMS)
-	},
 	SilenceUsage:  true,
 	SilenceErrors: true,
 }
@@ -79,6 +66,7 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&flagAgent, "agent", false, "Agent mode: stricter safety, no colors")
 	rootCmd.PersistentFlags().BoolVarP(&flagVerbose, "verbose", "v", false, "Verbose output")
 	rootCmd.PersistentFlags().BoolVar(&flagNoCloud, "no-cloud", false, "Skip cloud/wrangler calls (faster, offline-safe)")
+	rootCmd.PersistentFlags().BoolVar(&flagDryRun, "dry-run", false, "Print mutating git/gh/wrangler calls instead of running them")
 	rootCmd.PersistentFlags().BoolVarP(&flagInteractive, "interactive", "i", true, "Interactive TUI mode (auto-disabled for --agent/--json)")
 
 	rootCmd.AddCommand(versionCmd)
@@ -111,10 +99,46 @@ var versionCmd = &cobra.Command{
 	},
 }
 
-// Execute runs the root command.
+// exitStatusError ends a command with a specific exit status after the
+// command has already reported the failure itself.
+type exitStatusError struct {
+	code int
+}
+
+func (e *exitStatusError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// exitStatus returns an error that makes gw exit with code without printing
+// anything further. Commands return it instead of calling os.Exit so the
+// failure still reaches history.
+func exitStatus(code int) error {
+	return &exitStatusError{code: code}
+}
+
+// Execute runs the root command and records the invocation in history.
+// Recording happens here rather than in a post-run hook so failures and
+// safety-blocked attempts are kept along with their exit codes.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	start := time.Now()
+	registerPlugins()
+	cmd, err := rootCmd.ExecuteC()
+
+	code := 0
+	if err != nil {
+		code = 1
+		var status *exitStatusError
+		if errors.As(err, &status) {
+			code = status.code
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	recordHistory(cmd, os.Args[1:], err, code, time.Since(start))
+	summarizeDryRun()
+
+	if code != 0 {
+		os.Exit(code)
 	}
 }
@@ -15,6 +15,7 @@ import (
 
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
 	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/vault"
 )
@@ -24,7 +25,85 @@ var secretCmd = &cobra.Command{
 	Aliases: []string{"secrets"},
 	Short:   "Encrypted secrets vault for Cloudflare Workers",
 	Long: `Manage secrets safely with an encrypted local vault.
-Values are never displayed in normal output — safe for agent use.`,
+Values are never displayed in normal output — safe for agent use.
+
+A secret can hold a value per wrangler environment (--env production).
+apply and sync with --env deploy each secret's value for that
+environment, falling back to its default value.
+
+share encrypts chosen secrets to a teammate's public key (from their
+gw secret pubkey) and receive imports such a bundle, so values never
+travel in plaintext.`,
+}
+
+// secretEnv reads a command's --env flag. An empty environment means the
+// default value.
+func secretEnv(cmd *cobra.Command) (string, error) {
+	env, _ := cmd.Flags().GetString("env")
+	if env == "" {
+		return "", nil
+	}
+	return env, vault.ValidateEnv(env)
+}
+
+// secretEnvLabel names an environment in messages, "" being the default.
+func secretEnvLabel(env string) string {
+	if env == "" {
+		return vault.DefaultEnv
+	}
+	return env
+}
+
+// secretEnvSuffix qualifies a message with an environment when one was
+// named.
+func secretEnvSuffix(env string) string {
+	if env == "" {
+		return ""
+	}
+	return " in " + env
+}
+
+// unlockSecrets opens the vault for a secret command, noting on stderr when
+// a version 1 vault was just migrated.
+func unlockSecrets(password string, create bool) (*vault.SecretsVault, error) {
+	unlock := vault.Unlock
+	if create {
+		unlock = vault.UnlockOrCreate
+	}
+	v, err := unlock(password)
+	if err != nil {
+		return nil, err
+	}
+	if v.Migrated() {
+		fmt.Fprintf(os.Stderr, "Migrated the vault to per-environment secrets; existing values are now the %s environment (old file kept as %s.v1.bak)\n", vault.DefaultEnv, vault.DefaultVaultPath())
+		fmt.Fprintln(os.Stderr, "Python gw cannot read the new format; point it at the .v1.bak copy, which will not see changes made from now on")
+	}
+	return v, nil
+}
+
+// secretTarget builds the wrangler arguments that put or delete a secret
+// on a Worker, in env when one is given, or on a Pages project, with the
+// target label deployments are recorded under.
+func secretTarget(verb, name, worker, pages, env string) ([]string, string) {
+	if worker != "" {
+		args := []string{"secret", verb, name, "--name", worker}
+		if env != "" {
+			return append(args, "--env", env), fmt.Sprintf("%s (%s)", worker, env)
+		}
+		return args, worker
+	}
+	return []string{"pages", "secret", verb, name, "--project", pages}, "Pages:" + pages
+}
+
+// checkSecretTarget validates the --worker, --pages and --env combination.
+func checkSecretTarget(worker, pages, env string) error {
+	if worker == "" && pages == "" {
+		return fmt.Errorf("specify --worker or --pages target")
+	}
+	if env != "" && worker == "" {
+		return fmt.Errorf("--env selects a wrangler environment of a --worker")
+	}
+	return nil
 }
 
 // --- secret init ---
@@ -72,42 +151,176 @@ var secretInitCmd = &cobra.Command{
 	},
 }
 
+// --- secret rekey ---
+
+var secretRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Change the vault password",
+	Long: `Re-encrypt the vault under a new password.
+
+The current password is read as usual (GW_VAULT_PASSWORD or a prompt), the
+new one from GW_VAULT_NEW_PASSWORD or a prompt. The old vault file is kept
+as secrets.enc.<timestamp>.bak, and the new one replaces it in a single
+rename, so an interrupted rekey never leaves a half-written vault.
+
+Undo entries for earlier secret changes are sealed with the old password
+and can no longer be undone.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafety("secret_rekey"); err != nil {
+			return err
+		}
+		cfg := config.Get()
+
+		if !vault.VaultExists() {
+			return fmt.Errorf("no vault at %s (run gw secret init)", vault.DefaultVaultPath())
+		}
+
+		password, err := vault.GetVaultPassword()
+		if err != nil {
+			return err
+		}
+
+		v, err := unlockSecrets(password, false)
+		if err != nil {
+			return err
+		}
+
+		newPassword, err := vault.GetReplacementVaultPassword()
+		if err != nil {
+			return err
+		}
+		if newPassword == password {
+			return fmt.Errorf("the new password is the same as the current one")
+		}
+
+		backup, err := v.Rekey(newPassword)
+		if err != nil {
+			return err
+		}
+
+		if cfg.JSONMode {
+			data, _ := json.Marshal(map[string]interface{}{
+				"rekeyed": !cfg.DryRun,
+				"path":    vault.DefaultVaultPath(),
+				"backup":  backup,
+				"secrets": v.Count(),
+			})
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if cfg.DryRun {
+			ui.Info(fmt.Sprintf("Would rekey the vault (%d secrets), keeping the old file as %s", v.Count(), backup))
+			return nil
+		}
+		ui.Success(fmt.Sprintf("Vault rekeyed (%d secrets)", v.Count()))
+		ui.Muted("Old vault kept as " + backup + " — delete it once the new password is safely stored")
+		for _, name := range []string{"GROVE_VAULT_PASSWORD", "GW_VAULT_PASSWORD"} {
+			if os.Getenv(name) != "" {
+				ui.Warning(name + " still holds the old password — update it wherever it is set")
+			}
+		}
+		return nil
+	},
+}
+
 // --- secret list ---
 
+// secretSummary folds a secret's environments into one row: when it was
+// first created and last updated, and every target a value was deployed
+// to.
+func secretSummary(secret vault.Secret) (created, updated string, targets []string) {
+	seen := map[string]bool{}
+	for _, entry := range secret {
+		if created == "" || entry.CreatedAt < created {
+			created = entry.CreatedAt
+		}
+		if entry.UpdatedAt > updated {
+			updated = entry.UpdatedAt
+		}
+		for t := range entry.DeployedTo {
+			if !seen[t] {
+				seen[t] = true
+				targets = append(targets, t)
+			}
+		}
+	}
+	sort.Strings(targets)
+	return created, updated, targets
+}
+
+// secretMatrixCell says how a secret is defined in env: its own value
+// ("set"), the default it falls back to ("default") or nothing
+// ("missing").
+func secretMatrixCell(secret vault.Secret, env string) string {
+	switch {
+	case secret[env] != nil:
+		return "set"
+	case secret[vault.DefaultEnv] != nil:
+		return "default"
+	}
+	return "missing"
+}
+
 var secretListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List secrets (values are never shown)",
+	Long: `List the secrets in the vault. Values are never shown.
+
+--matrix shows which environments define each secret: ✓ for a value of
+its own, ↳ where the environment falls back to the default value, and ✗
+where the secret has no value that environment could use.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := config.Get()
+		matrix, _ := cmd.Flags().GetBool("matrix")
 
 		password, err := vault.GetVaultPassword()
 		if err != nil {
 			return err
 		}
 
-		v, err := vault.Unlock(password)
+		v, err := unlockSecrets(password, false)
 		if err != nil {
 			return err
 		}
 
 		secrets := v.List()
+		envs := v.Envs()
+
+		// Sort by name
+		names := make([]string, 0, len(secrets))
+		for name := range secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
 
 		if cfg.JSONMode {
 			// Build JSON-safe list
 			items := make([]map[string]interface{}, 0, len(secrets))
-			for name, entry := range secrets {
+			for _, name := range names {
+				secret := secrets[name]
+				created, updated, targets := secretSummary(secret)
 				item := map[string]interface{}{
 					"name":       name,
-					"created_at": entry.CreatedAt,
-					"updated_at": entry.UpdatedAt,
+					"created_at": created,
+					"updated_at": updated,
+					"envs":       secret.Envs(),
+				}
+				if len(targets) > 0 {
+					item["deployed_to"] = targets
 				}
-				if len(entry.DeployedTo) > 0 {
-					item["deployed_to"] = entry.DeployedTo
+				if matrix {
+					cells := make(map[string]string, len(envs))
+					for _, env := range envs {
+						cells[env] = secretMatrixCell(secret, env)
+					}
+					item["matrix"] = cells
 				}
 				items = append(items, item)
 			}
 			data, _ := json.Marshal(map[string]interface{}{
 				"count":   len(secrets),
+				"envs":    envs,
 				"secrets": items,
 			})
 			fmt.Println(string(data))
@@ -119,27 +332,32 @@ var secretListCmd = &cobra.Command{
 			return nil
 		}
 
-		// Sort by name
-		names := make([]string, 0, len(secrets))
-		for name := range secrets {
-			names = append(names, name)
+		if matrix {
+			headers := append([]string{"Name"}, envs...)
+			marks := map[string]string{"set": "✓", "default": "↳", "missing": "✗"}
+			var rows [][]string
+			for _, name := range names {
+				row := []string{name}
+				for _, env := range envs {
+					row = append(row, marks[secretMatrixCell(secrets[name], env)])
+				}
+				rows = append(rows, row)
+			}
+			fmt.Print(ui.RenderTable(fmt.Sprintf("Secrets by Environment (%d secrets, %d environments)", len(secrets), len(envs)), headers, rows))
+			ui.Muted("✓ own value   ↳ falls back to default   ✗ no value")
+			return nil
 		}
-		sort.Strings(names)
 
-		headers := []string{"Name", "Updated", "Deployed To"}
+		headers := []string{"Name", "Environments", "Updated", "Deployed To"}
 		var rows [][]string
 		for _, name := range names {
-			entry := secrets[name]
+			secret := secrets[name]
+			_, updated, targetNames := secretSummary(secret)
 			targets := "—"
-			if len(entry.DeployedTo) > 0 {
-				targetNames := make([]string, 0, len(entry.DeployedTo))
-				for t := range entry.DeployedTo {
-					targetNames = append(targetNames, t)
-				}
-				sort.Strings(targetNames)
+			if len(targetNames) > 0 {
 				targets = strings.Join(targetNames, ", ")
 			}
-			rows = append(rows, []string{name, entry.UpdatedAt, targets})
+			rows = append(rows, []string{name, strings.Join(secret.Envs(), ", "), updated, targets})
 		}
 		fmt.Print(ui.RenderTable(fmt.Sprintf("Secrets Vault (%d entries)", len(secrets)), headers, rows))
 		return nil
@@ -158,13 +376,17 @@ var secretSetCmd = &cobra.Command{
 		}
 		cfg := config.Get()
 		name := args[0]
+		env, err := secretEnv(cmd)
+		if err != nil {
+			return err
+		}
 
 		password, err := vault.GetVaultPassword()
 		if err != nil {
 			return err
 		}
 
-		v, err := vault.UnlockOrCreate(password)
+		v, err := unlockSecrets(password, true)
 		if err != nil {
 			return err
 		}
@@ -193,17 +415,17 @@ var secretSetCmd = &cobra.Command{
 			return fmt.Errorf("secret value must not be empty")
 		}
 
-		if err := v.Set(name, value); err != nil {
+		if err := v.SetEnv(name, env, value); err != nil {
 			return err
 		}
 
 		if cfg.JSONMode {
 			data, _ := json.Marshal(map[string]interface{}{
-				"name": name, "set": true,
+				"name": name, "env": secretEnvLabel(env), "set": true,
 			})
 			fmt.Println(string(data))
 		} else {
-			ui.Success(fmt.Sprintf("Secret '%s' saved", name))
+			ui.Success(fmt.Sprintf("Secret '%s' saved (%s)", name, secretEnvLabel(env)))
 		}
 		return nil
 	},
@@ -224,6 +446,10 @@ var secretGenerateCmd = &cobra.Command{
 		length, _ := cmd.Flags().GetInt("length")
 		format, _ := cmd.Flags().GetString("format")
 		force, _ := cmd.Flags().GetBool("force")
+		env, err := secretEnv(cmd)
+		if err != nil {
+			return err
+		}
 
 		if length < 8 || length > 256 {
 			return fmt.Errorf("length must be between 8 and 256, got %d", length)
@@ -234,13 +460,13 @@ var secretGenerateCmd = &cobra.Command{
 			return err
 		}
 
-		v, err := vault.UnlockOrCreate(password)
+		v, err := unlockSecrets(password, true)
 		if err != nil {
 			return err
 		}
 
-		if v.Exists(name) && !force {
-			return fmt.Errorf("secret '%s' already exists (use --force to overwrite)", name)
+		if v.HasEnv(name, env) && !force {
+			return fmt.Errorf("secret '%s' already has a %s value (use --force to overwrite)", name, secretEnvLabel(env))
 		}
 
 		// Generate random bytes
@@ -259,20 +485,21 @@ var secretGenerateCmd = &cobra.Command{
 			return fmt.Errorf("unsupported format: %s (use 'urlsafe' or 'hex')", format)
 		}
 
-		if err := v.Set(name, value); err != nil {
+		if err := v.SetEnv(name, env, value); err != nil {
 			return err
 		}
 
 		if cfg.JSONMode {
 			data, _ := json.Marshal(map[string]interface{}{
 				"name":      name,
+				"env":       secretEnvLabel(env),
 				"generated": true,
 				"length":    length,
 				"format":    format,
 			})
 			fmt.Println(string(data))
 		} else {
-			ui.Success(fmt.Sprintf("Secret '%s' generated (%d bytes, %s)", name, length, format))
+			ui.Success(fmt.Sprintf("Secret '%s' generated (%d bytes, %s, %s)", name, length, format, secretEnvLabel(env)))
 		}
 		return nil
 	},
@@ -287,35 +514,43 @@ var secretExistsCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := config.Get()
 		name := args[0]
+		env, err := secretEnv(cmd)
+		if err != nil {
+			return err
+		}
 
 		password, err := vault.GetVaultPassword()
 		if err != nil {
 			return err
 		}
 
-		v, err := vault.Unlock(password)
+		v, err := unlockSecrets(password, false)
 		if err != nil {
 			return err
 		}
 
+		// With --env, the secret must have a value of its own there
 		exists := v.Exists(name)
+		if env != "" {
+			exists = v.HasEnv(name, env)
+		}
 
 		if cfg.JSONMode {
 			data, _ := json.Marshal(map[string]interface{}{
-				"name": name, "exists": exists,
+				"name": name, "env": env, "exists": exists,
 			})
 			fmt.Println(string(data))
 			if !exists {
-				os.Exit(1)
+				return exitStatus(1)
 			}
 			return nil
 		}
 
 		if exists {
-			ui.Success(fmt.Sprintf("Secret '%s' exists", name))
+			ui.Success(fmt.Sprintf("Secret '%s' exists%s", name, secretEnvSuffix(env)))
 		} else {
-			ui.Muted(fmt.Sprintf("Secret '%s' not found", name))
-			os.Exit(1)
+			ui.Muted(fmt.Sprintf("Secret '%s' not found%s", name, secretEnvSuffix(env)))
+			return exitStatus(1)
 		}
 		return nil
 	},
@@ -333,25 +568,29 @@ var secretRevealCmd = &cobra.Command{
 		}
 		cfg := config.Get()
 		name := args[0]
+		env, err := secretEnv(cmd)
+		if err != nil {
+			return err
+		}
 
 		password, err := vault.GetVaultPassword()
 		if err != nil {
 			return err
 		}
 
-		v, err := vault.Unlock(password)
+		v, err := unlockSecrets(password, false)
 		if err != nil {
 			return err
 		}
 
-		value, ok := v.Get(name)
+		value, from, ok := v.Resolve(name, env)
 		if !ok {
-			return fmt.Errorf("secret '%s' not found", name)
+			return fmt.Errorf("secret '%s' has no %s value", name, secretEnvLabel(env))
 		}
 
 		if cfg.JSONMode {
 			data, _ := json.Marshal(map[string]interface{}{
-				"name": name, "value": value,
+				"name": name, "env": from, "value": value,
 			})
 			fmt.Println(string(data))
 		} else {
@@ -373,28 +612,49 @@ var secretDeleteCmd = &cobra.Command{
 		}
 		cfg := config.Get()
 		name := args[0]
+		env, err := secretEnv(cmd)
+		if err != nil {
+			return err
+		}
 
 		password, err := vault.GetVaultPassword()
 		if err != nil {
 			return err
 		}
 
-		v, err := vault.Unlock(password)
+		v, err := unlockSecrets(password, false)
 		if err != nil {
 			return err
 		}
 
-		if err := v.Delete(name); err != nil {
+		txn, err := journalSecretDelete("gw secret delete", v, name, env)
+		if err != nil {
 			return err
 		}
 
+		// Without --env the secret goes in every environment
+		if env != "" {
+			err = v.DeleteEnv(name, env)
+		} else {
+			err = v.Delete(name)
+		}
+		if err != nil {
+			txn.abort()
+			return err
+		}
+		txn.commit()
+
 		if cfg.JSONMode {
 			data, _ := json.Marshal(map[string]interface{}{
-				"name": name, "deleted": true,
+				"name": name, "env": env, "deleted": true, "undo_id": txn.id(),
 			})
 			fmt.Println(string(data))
+		} else if env != "" {
+			ui.Success(fmt.Sprintf("Secret '%s' deleted from %s", name, env))
+			txn.printUndoHint()
 		} else {
 			ui.Success(fmt.Sprintf("Secret '%s' deleted", name))
+			txn.printUndoHint()
 		}
 		return nil
 	},
@@ -407,15 +667,18 @@ var secretApplyCmd = &cobra.Command{
 	Short: "Deploy secrets to a Cloudflare Worker via wrangler",
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := requireCFSafety("secret_apply"); err != nil {
-			return err
-		}
 		cfg := config.Get()
 		worker, _ := cmd.Flags().GetString("worker")
 		pages, _ := cmd.Flags().GetString("pages")
-
-		if worker == "" && pages == "" {
-			return fmt.Errorf("specify --worker or --pages target")
+		env, err := secretEnv(cmd)
+		if err != nil {
+			return err
+		}
+		if err := requireCFSafetyTarget("secret_apply", safety.Target{Worker: worker}); err != nil {
+			return err
+		}
+		if err := checkSecretTarget(worker, pages, env); err != nil {
+			return err
 		}
 
 		password, err := vault.GetVaultPassword()
@@ -423,7 +686,7 @@ var secretApplyCmd = &cobra.Command{
 			return err
 		}
 
-		v, err := vault.Unlock(password)
+		v, err := unlockSecrets(password, false)
 		if err != nil {
 			return err
 		}
@@ -432,28 +695,21 @@ var secretApplyCmd = &cobra.Command{
 		allOK := true
 
 		for _, name := range args {
-			value, ok := v.Get(name)
+			// The worker's environment gets its own value, else the default
+			value, from, ok := v.Resolve(name, env)
 			if !ok {
 				allOK = false
 				results = append(results, map[string]interface{}{
-					"name": name, "applied": false, "error": "not found in vault",
+					"name": name, "applied": false, "error": "no " + secretEnvLabel(env) + " value in vault",
 				})
 				if !cfg.JSONMode {
-					ui.Error(fmt.Sprintf("Secret '%s' not found in vault", name))
+					ui.Error(fmt.Sprintf("Secret '%s' has no %s value in the vault", name, secretEnvLabel(env)))
 				}
 				continue
 			}
 
 			// Build wrangler command
-			var wranglerArgs []string
-			var target string
-			if worker != "" {
-				wranglerArgs = []string{"secret", "put", name, "--name", worker}
-				target = worker
-			} else {
-				wranglerArgs = []string{"pages", "secret", "put", name, "--project", pages}
-				target = "Pages:" + pages
-			}
+			wranglerArgs, target := secretTarget("put", name, worker, pages, env)
 
 			// Pipe value via stdin — never appears in process args
 			result, err := exec.WranglerWithStdin(value, wranglerArgs...)
@@ -486,13 +742,13 @@ var secretApplyCmd = &cobra.Command{
 			}
 
 			// Record deployment in vault
-			_ = v.RecordDeployment(name, target)
+			_ = v.RecordDeploymentEnv(name, from, target)
 
 			results = append(results, map[string]interface{}{
-				"name": name, "applied": true, "target": target,
+				"name": name, "applied": true, "target": target, "env": from,
 			})
 			if !cfg.JSONMode {
-				ui.Success(fmt.Sprintf("Applied '%s' → %s", name, target))
+				ui.Success(fmt.Sprintf("Applied '%s' (%s) → %s", name, from, target))
 			}
 		}
 
@@ -505,7 +761,7 @@ var secretApplyCmd = &cobra.Command{
 		}
 
 		if !allOK {
-			os.Exit(1)
+			return exitStatus(1)
 		}
 		return nil
 	},
@@ -518,15 +774,18 @@ var secretUnapplyCmd = &cobra.Command{
 	Short: "Remove secrets from a Cloudflare Worker (without deleting from vault)",
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := requireCFSafety("secret_unapply"); err != nil {
-			return err
-		}
 		cfg := config.Get()
 		worker, _ := cmd.Flags().GetString("worker")
 		pages, _ := cmd.Flags().GetString("pages")
-
-		if worker == "" && pages == "" {
-			return fmt.Errorf("specify --worker or --pages target")
+		env, err := secretEnv(cmd)
+		if err != nil {
+			return err
+		}
+		if err := requireCFSafetyTarget("secret_unapply", safety.Target{Worker: worker}); err != nil {
+			return err
+		}
+		if err := checkSecretTarget(worker, pages, env); err != nil {
+			return err
 		}
 
 		password, err := vault.GetVaultPassword()
@@ -534,7 +793,7 @@ var secretUnapplyCmd = &cobra.Command{
 			return err
 		}
 
-		v, err := vault.Unlock(password)
+		v, err := unlockSecrets(password, false)
 		if err != nil {
 			return err
 		}
@@ -544,15 +803,7 @@ var secretUnapplyCmd = &cobra.Command{
 
 		for _, name := range args {
 			// Build wrangler command
-			var wranglerArgs []string
-			var target string
-			if worker != "" {
-				wranglerArgs = []string{"secret", "delete", name, "--name", worker}
-				target = worker
-			} else {
-				wranglerArgs = []string{"pages", "secret", "delete", name, "--project", pages}
-				target = "Pages:" + pages
-			}
+			wranglerArgs, target := secretTarget("delete", name, worker, pages, env)
 
 			// wrangler secret delete prompts for confirmation — pipe "y" to accept
 			result, err := exec.WranglerWithStdin("y", wranglerArgs...)
@@ -601,7 +852,7 @@ var secretUnapplyCmd = &cobra.Command{
 		}
 
 		if !allOK {
-			os.Exit(1)
+			return exitStatus(1)
 		}
 		return nil
 	},
@@ -613,15 +864,18 @@ var secretSyncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Deploy all secrets to a Cloudflare Worker",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := requireCFSafety("secret_sync"); err != nil {
-			return err
-		}
 		cfg := config.Get()
 		worker, _ := cmd.Flags().GetString("worker")
 		pages, _ := cmd.Flags().GetString("pages")
-
-		if worker == "" && pages == "" {
-			return fmt.Errorf("specify --worker or --pages target")
+		env, err := secretEnv(cmd)
+		if err != nil {
+			return err
+		}
+		if err := requireCFSafetyTarget("secret_sync", safety.Target{Worker: worker}); err != nil {
+			return err
+		}
+		if err := checkSecretTarget(worker, pages, env); err != nil {
+			return err
 		}
 
 		password, err := vault.GetVaultPassword()
@@ -629,12 +883,18 @@ var secretSyncCmd = &cobra.Command{
 			return err
 		}
 
-		v, err := vault.Unlock(password)
+		v, err := unlockSecrets(password, false)
 		if err != nil {
 			return err
 		}
 
-		names := v.Names()
+		// Only the secrets with a value for the worker's environment
+		var names []string
+		for _, name := range v.Names() {
+			if _, _, ok := v.Resolve(name, env); ok {
+				names = append(names, name)
+			}
+		}
 		sort.Strings(names)
 
 		if len(names) == 0 {
@@ -650,24 +910,15 @@ var secretSyncCmd = &cobra.Command{
 		}
 
 		if !cfg.JSONMode {
-			ui.Info(fmt.Sprintf("Syncing %d secrets...", len(names)))
+			ui.Info(fmt.Sprintf("Syncing %d secrets for %s...", len(names), secretEnvLabel(env)))
 		}
 
 		// Reuse apply logic by building the same args
 		var results []map[string]interface{}
 		allOK := true
 		for _, name := range names {
-			value, _ := v.Get(name)
-
-			var wranglerArgs []string
-			var target string
-			if worker != "" {
-				wranglerArgs = []string{"secret", "put", name, "--name", worker}
-				target = worker
-			} else {
-				wranglerArgs = []string{"pages", "secret", "put", name, "--project", pages}
-				target = "Pages:" + pages
-			}
+			value, from, _ := v.Resolve(name, env)
+			wranglerArgs, target := secretTarget("put", name, worker, pages, env)
 
 			result, err := exec.WranglerWithStdin(value, wranglerArgs...)
 			if err != nil {
@@ -692,12 +943,12 @@ var secretSyncCmd = &cobra.Command{
 				continue
 			}
 
-			_ = v.RecordDeployment(name, target)
+			_ = v.RecordDeploymentEnv(name, from, target)
 			results = append(results, map[string]interface{}{
-				"name": name, "applied": true, "target": target,
+				"name": name, "applied": true, "target": target, "env": from,
 			})
 			if !cfg.JSONMode {
-				ui.Step(true, name)
+				ui.Step(true, fmt.Sprintf("%s (%s)", name, from))
 			}
 		}
 
@@ -713,7 +964,7 @@ var secretSyncCmd = &cobra.Command{
 		}
 
 		if !allOK {
-			os.Exit(1)
+			return exitStatus(1)
 		}
 		return nil
 	},
@@ -729,6 +980,7 @@ var secretHelpCategories = []ui.HelpCategory{
 		Commands: []ui.HelpCommand{
 			{Name: "list", Desc: "List secrets (values are never shown)"},
 			{Name: "exists", Desc: "Check if a secret exists (exit code 0/1)"},
+			{Name: "pubkey", Desc: "Show your public key for others to share to"},
 		},
 	},
 	{
@@ -740,6 +992,8 @@ var secretHelpCategories = []ui.HelpCategory{
 			{Name: "set", Desc: "Set a secret value (prompted or piped)"},
 			{Name: "generate", Desc: "Generate and store a random secret"},
 			{Name: "delete", Desc: "Delete a secret from the vault"},
+			{Name: "rekey", Desc: "Change the vault password (keeps a backup)"},
+			{Name: "receive", Desc: "Import a bundle shared to your key"},
 		},
 	},
 	{
@@ -758,6 +1012,7 @@ var secretHelpCategories = []ui.HelpCategory{
 		Style: ui.DangerStyle,
 		Commands: []ui.HelpCommand{
 			{Name: "reveal", Desc: "Show a secret's plaintext value"},
+			{Name: "share", Desc: "Encrypt secrets to a teammate's public key"},
 		},
 	},
 }
@@ -784,30 +1039,40 @@ func init() {
 	})
 
 	secretCmd.AddCommand(secretInitCmd)
+	secretCmd.AddCommand(secretRekeyCmd)
+	secretListCmd.Flags().Bool("matrix", false, "Show which environments define each secret")
 	secretCmd.AddCommand(secretListCmd)
+	secretSetCmd.Flags().StringP("env", "e", "", "Environment to set the value for (default: the default value)")
 	secretCmd.AddCommand(secretSetCmd)
+	secretExistsCmd.Flags().StringP("env", "e", "", "Require a value of the secret's own in this environment")
 	secretCmd.AddCommand(secretExistsCmd)
+	secretRevealCmd.Flags().StringP("env", "e", "", "Environment whose value to show (falls back to the default)")
 	secretCmd.AddCommand(secretRevealCmd)
+	secretDeleteCmd.Flags().StringP("env", "e", "", "Delete only this environment's value")
 	secretCmd.AddCommand(secretDeleteCmd)
 
 	// generate
 	secretGenerateCmd.Flags().IntP("length", "l", 32, "Length of generated secret in bytes")
 	secretGenerateCmd.Flags().StringP("format", "f", "urlsafe", "Output format: urlsafe or hex")
 	secretGenerateCmd.Flags().Bool("force", false, "Overwrite existing secret")
+	secretGenerateCmd.Flags().StringP("env", "e", "", "Environment to generate the value for")
 	secretCmd.AddCommand(secretGenerateCmd)
 
 	// apply
 	secretApplyCmd.Flags().StringP("worker", "w", "", "Cloudflare Worker name")
 	secretApplyCmd.Flags().StringP("pages", "p", "", "Cloudflare Pages project name")
+	secretApplyCmd.Flags().StringP("env", "e", "", "Wrangler environment of the Worker; its values are used, else the defaults")
 	secretCmd.AddCommand(secretApplyCmd)
 
 	// unapply
 	secretUnapplyCmd.Flags().StringP("worker", "w", "", "Cloudflare Worker name")
 	secretUnapplyCmd.Flags().StringP("pages", "p", "", "Cloudflare Pages project name")
+	secretUnapplyCmd.Flags().StringP("env", "e", "", "Wrangler environment of the Worker; its values are used, else the defaults")
 	secretCmd.AddCommand(secretUnapplyCmd)
 
 	// sync
 	secretSyncCmd.Flags().StringP("worker", "w", "", "Cloudflare Worker name")
 	secretSyncCmd.Flags().StringP("pages", "p", "", "Cloudflare Pages project name")
+	secretSyncCmd.Flags().StringP("env", "e", "", "Wrangler environment of the Worker; its values are used, else the defaults")
 	secretCmd.AddCommand(secretSyncCmd)
 }
@@ -0,0 +1,430 @@
+package cmd
+
+import (
+	"crypto/ecdh"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/vault"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/vaultshare"
+)
+
+// vaultIdentity returns the vault's sharing identity. With create set, a
+// vault without one is given a new identity (not saved in dry-run mode).
+func vaultIdentity(v *vault.SecretsVault, create bool) (id *vaultshare.Identity, created bool, err error) {
+	if stored := v.Identity(); stored != "" {
+		id, err := vaultshare.ParseIdentity(stored)
+		return id, false, err
+	}
+	if !create {
+		return nil, false, fmt.Errorf("this vault has no sharing key yet: run gw secret pubkey and send the key it prints to whoever is sharing with you")
+	}
+	if id, err = vaultshare.GenerateIdentity(); err != nil {
+		return nil, false, err
+	}
+	if err := v.SetIdentity(id.String()); err != nil {
+		return nil, false, err
+	}
+	return id, true, nil
+}
+
+// shareSecret picks the entries of secret to share: every environment, or
+// only those in envs, each taking the default value when it has none of
+// its own so the recipient resolves it the same way. Deployment records
+// stay behind; they describe the sender's Workers.
+func shareSecret(secret vault.Secret, envs []string) vault.Secret {
+	picked := vault.Secret{}
+	add := func(env string) {
+		if entry := secret[env]; entry != nil {
+			picked[env] = &vault.SecretEntry{Value: entry.Value, CreatedAt: entry.CreatedAt, UpdatedAt: entry.UpdatedAt}
+		}
+	}
+	if len(envs) == 0 {
+		for env := range secret {
+			add(env)
+		}
+		return picked
+	}
+	for _, env := range envs {
+		if secret[env] == nil {
+			env = vault.DefaultEnv
+		}
+		add(env)
+	}
+	return picked
+}
+
+// receivePlan says what receiving entry would do to existing, the
+// secret's current entries: "new", "same" (nothing to do), "conflict"
+// (kept) or, with overwrite, "replace".
+func receivePlan(existing vault.Secret, env string, entry *vault.SecretEntry, overwrite bool) string {
+	current := existing[env]
+	switch {
+	case current == nil:
+		return "new"
+	case current.Value == entry.Value:
+		return "same"
+	case overwrite:
+		return "replace"
+	}
+	return "conflict"
+}
+
+// --- secret pubkey ---
+
+var secretPubkeyCmd = &cobra.Command{
+	Use:   "pubkey",
+	Short: "Show your public key for others to share secrets to",
+	Long: `Print this vault's public sharing key, creating the key pair the first
+time. The public key is safe to post anywhere: send it to a teammate, who
+runs gw secret share --to <key>, then import what they send with
+gw secret receive. The private half stays encrypted inside the vault.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafety("secret_pubkey"); err != nil {
+			return err
+		}
+		cfg := config.Get()
+
+		password, err := vault.GetVaultPassword()
+		if err != nil {
+			return err
+		}
+
+		v, err := unlockSecrets(password, false)
+		if err != nil {
+			return err
+		}
+
+		id, created, err := vaultIdentity(v, true)
+		if err != nil {
+			return err
+		}
+
+		if cfg.JSONMode {
+			data, _ := json.Marshal(map[string]interface{}{
+				"public_key": id.PublicKey(), "created": created && !cfg.DryRun,
+			})
+			fmt.Println(string(data))
+			return nil
+		}
+
+		fmt.Println(id.PublicKey())
+		if created && cfg.DryRun {
+			ui.Warning("Dry run: this key was not saved and will change on the next run")
+		} else if created {
+			ui.Muted("New sharing key created and stored in the vault")
+		}
+		return nil
+	},
+}
+
+// --- secret share ---
+
+var secretShareCmd = &cobra.Command{
+	Use:   "share <name> [name...] --to <pubkey>",
+	Short: "Encrypt secrets to teammates' public keys (DANGEROUS)",
+	Long: `Export secrets as a bundle only the holders of the given public keys
+can open, for onboarding a teammate without sending values over chat.
+
+The recipient runs gw secret pubkey and sends you the key it prints. The
+bundle is plain text, safe to paste into chat or email; it is written to
+--out, or printed. Repeat --to for several recipients and --env to share
+only some environments' values (an environment without its own value
+brings the default with it). Deployment records are not shared.
+
+Bundles are not signed: anyone with a recipient's public key can make one.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafety("secret_share"); err != nil {
+			return err
+		}
+		cfg := config.Get()
+		to, _ := cmd.Flags().GetStringArray("to")
+		envs, _ := cmd.Flags().GetStringArray("env")
+		out, _ := cmd.Flags().GetString("out")
+
+		if len(to) == 0 {
+			return fmt.Errorf("specify at least one recipient with --to (they get their key from gw secret pubkey)")
+		}
+		recipients := make([]*ecdh.PublicKey, 0, len(to))
+		for _, key := range to {
+			pub, err := vaultshare.ParsePublicKey(key)
+			if err != nil {
+				return err
+			}
+			recipients = append(recipients, pub)
+		}
+		for _, env := range envs {
+			if err := vault.ValidateEnv(env); err != nil {
+				return err
+			}
+		}
+
+		password, err := vault.GetVaultPassword()
+		if err != nil {
+			return err
+		}
+
+		v, err := unlockSecrets(password, false)
+		if err != nil {
+			return err
+		}
+
+		contents := vaultshare.Contents{
+			From:    changeActor(),
+			Created: time.Now().UTC(),
+			Secrets: make(map[string]vault.Secret, len(args)),
+		}
+		for _, name := range args {
+			secret, ok := v.Secret(name)
+			if !ok {
+				return fmt.Errorf("secret '%s' not found in vault", name)
+			}
+			picked := shareSecret(secret, envs)
+			if len(picked) == 0 {
+				return fmt.Errorf("secret '%s' has no value in the environments being shared", name)
+			}
+			contents.Secrets[name] = picked
+		}
+
+		bundle, err := vaultshare.Seal(contents, recipients)
+		if err != nil {
+			return fmt.Errorf("failed to seal bundle: %w", err)
+		}
+
+		names := make([]string, 0, len(contents.Secrets))
+		for name := range contents.Secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		if out != "" && !cfg.DryRun {
+			if err := os.WriteFile(out, bundle, 0o600); err != nil {
+				return fmt.Errorf("failed to write bundle: %w", err)
+			}
+		}
+
+		if cfg.JSONMode {
+			result := map[string]interface{}{
+				"secrets":    names,
+				"recipients": len(recipients),
+				"from":       contents.From,
+			}
+			if out != "" {
+				result["path"] = out
+				result["written"] = !cfg.DryRun
+			} else {
+				result["bundle"] = string(bundle)
+			}
+			data, _ := json.Marshal(result)
+			fmt.Println(string(data))
+			return nil
+		}
+
+		summary := fmt.Sprintf("%d secret(s) for %d recipient(s)", len(names), len(recipients))
+		switch {
+		case out == "":
+			// The bundle alone goes to stdout so it can be redirected.
+			fmt.Print(string(bundle))
+			fmt.Fprintf(os.Stderr, "Sealed %s; they import it with gw secret receive\n", summary)
+		case cfg.DryRun:
+			ui.Info(fmt.Sprintf("Would write %s to %s", summary, out))
+		default:
+			ui.Success(fmt.Sprintf("Sealed %s into %s", summary, out))
+			ui.Hint("They import it with: gw secret receive " + out)
+		}
+		return nil
+	},
+}
+
+// --- secret receive ---
+
+var secretReceiveCmd = &cobra.Command{
+	Use:   "receive [file]",
+	Short: "Import secrets from a bundle shared to your key",
+	Long: `Open a bundle made with gw secret share and add its secrets to the
+vault. The bundle is read from file, or stdin when none is given (set
+GW_VAULT_PASSWORD when piping, since the prompt needs the terminal).
+
+Values the vault already holds are kept unless --overwrite is given;
+overwritten secrets can be put back with gw undo.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireCFSafety("secret_receive"); err != nil {
+			return err
+		}
+		cfg := config.Get()
+		overwrite, _ := cmd.Flags().GetBool("overwrite")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		var raw []byte
+		var err error
+		if len(args) == 0 || args[0] == "-" {
+			raw, err = io.ReadAll(os.Stdin)
+		} else {
+			raw, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+
+		password, err := vault.GetVaultPassword()
+		if err != nil {
+			return err
+		}
+
+		v, err := unlockSecrets(password, false)
+		if err != nil {
+			return err
+		}
+
+		id, _, err := vaultIdentity(v, false)
+		if err != nil {
+			return err
+		}
+		contents, err := vaultshare.Open(raw, id)
+		if errors.Is(err, vaultshare.ErrNotRecipient) {
+			return fmt.Errorf("%w: ask the sender to share to %s", err, id.PublicKey())
+		}
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(contents.Secrets))
+		for name := range contents.Secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		type change struct {
+			Name   string `json:"name"`
+			Env    string `json:"env"`
+			Action string `json:"action"`
+		}
+		var changes []change
+		counts := map[string]int{}
+		for _, name := range names {
+			existing, _ := v.Secret(name)
+			secret := contents.Secrets[name]
+			for _, env := range secret.Envs() {
+				if vault.ValidateEnv(env) != nil || secret[env] == nil {
+					return fmt.Errorf("bundle holds an invalid entry for '%s'", name)
+				}
+				action := receivePlan(existing, env, secret[env], overwrite)
+				changes = append(changes, change{name, env, action})
+				counts[action]++
+			}
+		}
+		writes := counts["new"] + counts["replace"]
+
+		if !cfg.JSONMode {
+			marks := map[string]string{"new": "+ new", "same": "= unchanged", "conflict": "! kept (differs)", "replace": "~ replace"}
+			var rows [][]string
+			for _, c := range changes {
+				rows = append(rows, []string{c.Name, c.Env, marks[c.Action]})
+			}
+			ui.PrintKeyValue("From", contents.From+" (unverified)")
+			ui.PrintKeyValue("Sealed", contents.Created.Local().Format("2006-01-02 15:04"))
+			fmt.Print(ui.RenderTable(fmt.Sprintf("Bundle (%d secrets)", len(names)), []string{"Name", "Environment", "Action"}, rows))
+			if counts["conflict"] > 0 {
+				ui.Hint(fmt.Sprintf("%d value(s) differ from the vault's; pass --overwrite to take the bundle's", counts["conflict"]))
+			}
+		}
+
+		if writes > 0 && !cfg.JSONMode && cfg.IsInteractive() && !yes && !cfg.DryRun &&
+			!ui.Confirm(fmt.Sprintf("Import %d value(s) into the vault?", writes)) {
+			ui.Muted("Cancelled")
+			return nil
+		}
+
+		undoIDs := []int{}
+		if writes > 0 {
+			now := time.Now().UTC().Format(time.RFC3339)
+			for _, name := range names {
+				existing, _ := v.Secret(name)
+				merged := vault.Secret{}
+				for env, entry := range existing {
+					merged[env] = entry
+				}
+				replaced, changed := false, false
+				for env, entry := range contents.Secrets[name] {
+					switch receivePlan(existing, env, entry, overwrite) {
+					case "new":
+						merged[env] = &vault.SecretEntry{Value: entry.Value, CreatedAt: now, UpdatedAt: now}
+						changed = true
+					case "replace":
+						merged[env].Value = entry.Value
+						merged[env].UpdatedAt = now
+						replaced, changed = true, true
+					}
+				}
+				if !changed {
+					continue
+				}
+
+				var txn *undoTxn
+				if replaced {
+					if txn, err = journalSecretWrite("gw secret receive", v, name, merged); err != nil {
+						return err
+					}
+				}
+				if err := v.RestoreSecret(name, merged); err != nil {
+					txn.abort()
+					return err
+				}
+				txn.commit()
+				if txn != nil {
+					undoIDs = append(undoIDs, txn.id())
+				}
+			}
+		}
+
+		if cfg.JSONMode {
+			data, _ := json.Marshal(map[string]interface{}{
+				"from":     contents.From,
+				"created":  contents.Created,
+				"changes":  changes,
+				"imported": writes,
+				"applied":  !cfg.DryRun,
+				"undo_ids": undoIDs,
+			})
+			fmt.Println(string(data))
+			return nil
+		}
+
+		switch {
+		case writes == 0:
+			ui.Muted("Nothing to import")
+		case cfg.DryRun:
+			ui.Info(fmt.Sprintf("Would import %d value(s)", writes))
+		default:
+			ui.Success(fmt.Sprintf("Imported %d value(s)", writes))
+			for _, id := range undoIDs {
+				ui.Hint(fmt.Sprintf("gw undo %d reverts an overwrite", id))
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	secretCmd.AddCommand(secretPubkeyCmd)
+
+	secretShareCmd.Flags().StringArray("to", nil, "Recipient public key from gw secret pubkey (repeatable)")
+	secretShareCmd.Flags().StringArrayP("env", "e", nil, "Only share this environment's values (repeatable)")
+	secretShareCmd.Flags().StringP("out", "o", "", "Write the bundle to a file instead of stdout")
+	secretCmd.AddCommand(secretShareCmd)
+
+	secretReceiveCmd.Flags().Bool("overwrite", false, "Replace values the vault already holds")
+	secretReceiveCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+	secretCmd.AddCommand(secretReceiveCmd)
+}
@@ -0,0 +1,669 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/config"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/exec"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/ui"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/undo"
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/vault"
+)
+
+// undoTxn is an undo entry written ahead of the change it describes. A nil
+// txn (nothing to journal) is valid and does nothing.
+type undoTxn struct {
+	journal *undo.Journal
+	entry   *undo.Entry
+}
+
+// commit marks the change as made. A failure here only loses the pending
+// flag, so it is reported rather than returned.
+func (t *undoTxn) commit() {
+	if t == nil {
+		return
+	}
+	if err := t.journal.Complete(t.entry); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: undo entry #%d not finalised: %v\n", t.entry.ID, err)
+	}
+}
+
+// abort drops the entry after the change failed.
+func (t *undoTxn) abort() {
+	if t == nil {
+		return
+	}
+	_ = t.journal.Remove(t.entry)
+}
+
+// printUndoHint tells the user how to reverse the change just made.
+func (t *undoTxn) printUndoHint() {
+	if t == nil || config.Get().JSONMode {
+		return
+	}
+	ui.Hint(fmt.Sprintf("gw undo %d reverts this", t.entry.ID))
+}
+
+// id returns the entry ID, or 0 when nothing was journaled.
+func (t *undoTxn) id() int {
+	if t == nil {
+		return 0
+	}
+	return t.entry.ID
+}
+
+// beginUndo writes e to the journal as pending. Nothing is journaled in a
+// dry run, since the change is never made.
+func beginUndo(e *undo.Entry) (*undoTxn, error) {
+	if config.Get().DryRun {
+		return nil, nil
+	}
+	j, err := undo.Open()
+	if err != nil {
+		return nil, err
+	}
+	if err := j.Add(e); err != nil {
+		return nil, fmt.Errorf("cannot journal change for undo: %w", err)
+	}
+	return &undoTxn{journal: j, entry: e}, nil
+}
+
+// isNotFound reports whether wrangler output says the key or object is
+// missing.
+func isNotFound(output string) bool {
+	lower := strings.ToLower(output)
+	return strings.Contains(lower, "not found") || strings.Contains(lower, "could not find") ||
+		strings.Contains(lower, "does not exist")
+}
+
+// --- KV ---
+
+// kvState is the state a KV write leaves behind; nil for a delete.
+func kvState(value *string) *undo.State {
+	if value == nil {
+		return nil
+	}
+	return &undo.State{Digest: undo.Digest([]byte(*value)), Size: int64(len(*value))}
+}
+
+// kvSnapshot reads a key's current value, metadata and expiration. It
+// returns nil when the key does not exist.
+func kvSnapshot(nsID, key string) (*undo.State, error) {
+	result, err := exec.Wrangler("kv:key", "get", "--namespace-id", nsID, key)
+	if err != nil {
+		return nil, err
+	}
+	if !result.OK() {
+		if isNotFound(result.Stderr + result.Stdout) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("wrangler error: %s", strings.TrimSpace(result.Stderr))
+	}
+
+	value := result.Stdout
+	state := &undo.State{Value: value, Digest: undo.Digest([]byte(value)), Size: int64(len(value))}
+
+	// Metadata and expiry only come back from a listing; without them the
+	// value alone is still restorable.
+	listing, err := exec.WranglerOutput("kv:key", "list", "--namespace-id", nsID, "--prefix", key)
+	if err != nil {
+		return state, nil
+	}
+	var keys []struct {
+		Name       string          `json:"name"`
+		Expiration int64           `json:"expiration"`
+		Metadata   json.RawMessage `json:"metadata"`
+	}
+	if json.Unmarshal([]byte(listing), &keys) == nil {
+		for _, k := range keys {
+			if k.Name == key {
+				state.Expiration = k.Expiration
+				if len(k.Metadata) > 0 && string(k.Metadata) != "null" {
+					state.Metadata = k.Metadata
+				}
+				break
+			}
+		}
+	}
+	return state, nil
+}
+
+// journalKVChange snapshots a KV key before command writes value to it
+// (nil value: deletes it).
+func journalKVChange(command, alias, nsID, key string, value *string) (*undoTxn, error) {
+	before, err := kvSnapshot(nsID, key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot snapshot %s for undo: %w", key, err)
+	}
+	return journalKVSnapshot(command, alias, nsID, key, before, value)
+}
+
+// journalKVSnapshot journals a KV write whose before state the caller has
+// already read.
+func journalKVSnapshot(command, alias, nsID, key string, before *undo.State, value *string) (*undoTxn, error) {
+	return beginUndo(&undo.Entry{
+		Command:     command,
+		Kind:        undo.KindKV,
+		Namespace:   alias,
+		NamespaceID: nsID,
+		Key:         key,
+		Before:      before,
+		After:       kvState(value),
+	})
+}
+
+// kvRestore puts a KV key back to state, deleting it when state is nil.
+func kvRestore(nsID, key string, state *undo.State) error {
+	var args []string
+	if state == nil {
+		args = []string{"kv:key", "delete", "--namespace-id", nsID, key}
+	} else {
+		args = []string{"kv:key", "put", "--namespace-id", nsID, key, state.Value}
+		if len(state.Metadata) > 0 {
+			args = append(args, "--metadata", string(state.Metadata))
+		}
+		if state.Expiration > 0 {
+			if state.Expiration <= time.Now().Unix() {
+				ui.Warning("The original expiry has passed; restoring without one")
+			} else {
+				args = append(args, "--expiration", strconv.FormatInt(state.Expiration, 10))
+			}
+		}
+	}
+	result, err := exec.Wrangler(args...)
+	if err != nil {
+		return fmt.Errorf("wrangler error: %w", err)
+	}
+	if !result.OK() {
+		return fmt.Errorf("wrangler error: %s", result.Stderr)
+	}
+	return nil
+}
+
+// --- R2 ---
+
+// r2Snapshot downloads an object into the journal as blob. It returns nil
+// when the object does not exist.
+func r2Snapshot(j *undo.Journal, bucket, key, blob string) (*undo.State, error) {
+	path := j.BlobPath(blob)
+	result, err := exec.Wrangler("r2", "object", "get", bucket+"/"+key, "--file", path, "--remote")
+	if err != nil {
+		return nil, err
+	}
+	if !result.OK() {
+		os.Remove(path)
+		if isNotFound(result.Stderr + result.Stdout) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("wrangler error: %s", strings.TrimSpace(result.Stderr))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read downloaded object: %w", err)
+	}
+	return &undo.State{Digest: undo.Digest(data), Size: int64(len(data)), Blob: blob}, nil
+}
+
+// journalR2Delete downloads an object before command deletes it. It
+// returns a nil txn when the object is already gone.
+func journalR2Delete(command, bucket, key string) (*undoTxn, error) {
+	if config.Get().DryRun {
+		return nil, nil
+	}
+	j, err := undo.Open()
+	if err != nil {
+		return nil, err
+	}
+	e := &undo.Entry{Command: command, Kind: undo.KindR2, Bucket: bucket, Key: key}
+	if err := j.Reserve(e); err != nil {
+		return nil, fmt.Errorf("cannot journal change for undo: %w", err)
+	}
+
+	before, err := r2Snapshot(j, bucket, key, fmt.Sprintf("%06d-before.blob", e.ID))
+	if err != nil || before == nil {
+		_ = j.Remove(e)
+		if err != nil {
+			return nil, fmt.Errorf("cannot snapshot %s/%s for undo: %w", bucket, key, err)
+		}
+		return nil, nil
+	}
+	e.Before = before
+	if err := j.Save(e); err != nil {
+		_ = j.Remove(e)
+		return nil, err
+	}
+	j.Prune()
+	return &undoTxn{journal: j, entry: e}, nil
+}
+
+// r2Restore uploads the journaled object, or deletes the object when state
+// is nil.
+func r2Restore(j *undo.Journal, bucket, key string, state *undo.State) error {
+	args := []string{"r2", "object", "delete", bucket + "/" + key, "--remote"}
+	if state != nil {
+		path := j.BlobPath(state.Blob)
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("journaled copy of %s/%s is missing: %w", bucket, key, err)
+		}
+		args = []string{"r2", "object", "put", bucket + "/" + key, "--file", path, "--remote"}
+	}
+	result, err := exec.Wrangler(args...)
+	if err != nil {
+		return fmt.Errorf("wrangler error: %w", err)
+	}
+	if !result.OK() {
+		return fmt.Errorf("wrangler error: %s", result.Stderr)
+	}
+	return nil
+}
+
+// --- secrets ---
+
+// secretSnapshot seals a secret's vault entries, every environment's, with
+// the vault key. It returns nil when the secret does not exist. Secret
+// states carry a vault-keyed HMAC rather than a SHA-256, so nothing about
+// the value is stored in the clear.
+func secretSnapshot(v *vault.SecretsVault, name string) (*undo.State, error) {
+	secret, ok := v.Secret(name)
+	if !ok {
+		return nil, nil
+	}
+	return sealSecret(v, secret)
+}
+
+// sealSecret seals a secret's entries into an undo state.
+func sealSecret(v *vault.SecretsVault, secret vault.Secret) (*undo.State, error) {
+	data, err := json.Marshal(secret)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := v.Seal(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot seal secret for undo: %w", err)
+	}
+	digest, err := secretDigest(v, secret)
+	if err != nil {
+		return nil, err
+	}
+	size := 0
+	for _, entry := range secret {
+		size += len(entry.Value)
+	}
+	return &undo.State{Value: sealed, Digest: digest, Size: int64(size)}, nil
+}
+
+// secretDigest keys a digest of a secret's values by environment, leaving
+// out timestamps and deployments so only a changed value counts as drift.
+func secretDigest(v *vault.SecretsVault, secret vault.Secret) (string, error) {
+	envs := make([]string, 0, len(secret))
+	for env := range secret {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	var b strings.Builder
+	for _, env := range envs {
+		b.WriteString(env + "\x00" + secret[env].Value + "\x00")
+	}
+	mac, err := v.MAC([]byte(b.String()))
+	if err != nil {
+		return "", err
+	}
+	return undo.HMACPrefix + mac, nil
+}
+
+// journalSecretDelete snapshots a secret before command deletes it, in
+// every environment or, when env is set, only there; the secret survives
+// the latter while it has other values.
+func journalSecretDelete(command string, v *vault.SecretsVault, name, env string) (*undoTxn, error) {
+	secret, ok := v.Secret(name)
+	if !ok {
+		return nil, nil
+	}
+	before, err := sealSecret(v, secret)
+	if err != nil {
+		return nil, err
+	}
+	var after *undo.State
+	if env != "" {
+		delete(secret, env)
+		if len(secret) > 0 {
+			if after, err = sealSecret(v, secret); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return beginUndo(&undo.Entry{Command: command, Kind: undo.KindSecret, Key: name, Before: before, After: after})
+}
+
+// journalSecretWrite snapshots a secret before command replaces its
+// entries with after.
+func journalSecretWrite(command string, v *vault.SecretsVault, name string, after vault.Secret) (*undoTxn, error) {
+	before, err := secretSnapshot(v, name)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealSecret(v, after)
+	if err != nil {
+		return nil, err
+	}
+	return beginUndo(&undo.Entry{Command: command, Kind: undo.KindSecret, Key: name, Before: before, After: sealed})
+}
+
+// secretRestore puts sealed vault entries back, or deletes the secret when
+// state is nil. Journals written before secrets had environments hold a
+// single entry, which is restored as the default.
+func secretRestore(v *vault.SecretsVault, name string, state *undo.State) error {
+	if state == nil {
+		return v.Delete(name)
+	}
+	data, err := v.Unseal(state.Value)
+	if err != nil {
+		return fmt.Errorf("cannot unseal journaled secret (was the vault password changed?): %w", err)
+	}
+	var secret vault.Secret
+	if err := json.Unmarshal(data, &secret); err != nil {
+		var entry vault.SecretEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("journaled secret is corrupt: %w", err)
+		}
+		secret = vault.Secret{vault.DefaultEnv: &entry}
+	}
+	return v.RestoreSecret(name, secret)
+}
+
+// --- undo ---
+
+var undoCmd = &cobra.Command{
+	Use:   "undo <id>",
+	Short: "Reverse a journaled KV, flag, R2 or secret write",
+	Long: `Reverse a journaled write.
+
+gw kv put/delete, gw flag enable/disable/delete, gw r2 rm and
+gw secret delete save what they overwrite to ~/.grove/undo/ first.
+gw undo <id> puts it back.
+
+An undo needs the same safety flags as the write it makes: removing a
+flag that the journaled write created is a flag delete, restoring a
+deleted object an R2 put.
+
+If the resource changed after the journaled write, gw undo says so and
+asks before restoring (or needs --force when not interactive). The undo
+is itself journaled, so it can be undone too.
+
+The most recent 200 entries are kept.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		if err != nil {
+			return fmt.Errorf("invalid undo entry ID: %q", args[0])
+		}
+
+		j, err := undo.Open()
+		if err != nil {
+			return err
+		}
+		e, err := j.Get(id)
+		if err != nil {
+			if errors.Is(err, undo.ErrNotFound) {
+				return fmt.Errorf("no undo entry #%d (see gw undo list)", id)
+			}
+			return err
+		}
+
+		// Undo is as risky as the write it makes: restoring a deleted
+		// object is a put, removing a created flag a flag delete
+		if err := requireCFSafetyTarget(undoOperation(e), safety.Target{Namespace: e.Namespace}); err != nil {
+			return err
+		}
+		if e.UndoneAt != "" {
+			return fmt.Errorf("entry #%d was already undone at %s (undo #%d to reverse that)", id, e.UndoneAt, e.UndoneBy)
+		}
+
+		var v *vault.SecretsVault
+		if e.Kind == undo.KindSecret {
+			password, err := vault.GetVaultPassword()
+			if err != nil {
+				return err
+			}
+			if v, err = vault.Unlock(password); err != nil {
+				return err
+			}
+		}
+
+		// Journal the undo itself, capturing the live state it replaces
+		redo := &undo.Entry{
+			Command:     fmt.Sprintf("gw undo %d", id),
+			Kind:        e.Kind,
+			Namespace:   e.Namespace,
+			NamespaceID: e.NamespaceID,
+			Bucket:      e.Bucket,
+			Key:         e.Key,
+		}
+		if e.Before != nil {
+			after := *e.Before
+			after.Blob = ""
+			redo.After = &after
+		}
+		if err := j.Reserve(redo); err != nil {
+			return fmt.Errorf("cannot journal undo: %w", err)
+		}
+		txn := &undoTxn{journal: j, entry: redo}
+
+		var current *undo.State
+		switch e.Kind {
+		case undo.KindKV:
+			current, err = kvSnapshot(e.NamespaceID, e.Key)
+		case undo.KindR2:
+			current, err = r2Snapshot(j, e.Bucket, e.Key, fmt.Sprintf("%06d-before.blob", redo.ID))
+		case undo.KindSecret:
+			current, err = secretSnapshot(v, e.Key)
+		default:
+			err = fmt.Errorf("unknown undo entry kind %q", e.Kind)
+		}
+		if err != nil {
+			txn.abort()
+			return fmt.Errorf("cannot read current state of %s: %w", e.Resource(), err)
+		}
+		redo.Before = current
+		if err := j.Save(redo); err != nil {
+			txn.abort()
+			return err
+		}
+
+		if !cfg.JSONMode {
+			ui.PrintHeader(fmt.Sprintf("Undo #%d: %s %s", e.ID, e.Command, e.Resource()))
+			if e.Pending {
+				ui.Warning("This write was never confirmed; it may not have happened")
+			}
+		}
+
+		drifted := !undo.Same(current, e.After)
+		if drifted {
+			msg := fmt.Sprintf("%s changed after entry #%d was journaled (now %s, expected %s)",
+				e.Resource(), e.ID, undoStateLabel(current), undoStateLabel(e.After))
+			if !cfg.ForceFlag {
+				if !cfg.IsInteractive() || cfg.JSONMode {
+					txn.abort()
+					return fmt.Errorf("%s; pass --force to restore anyway", msg)
+				}
+				ui.Warning(msg)
+				if !ui.Confirm("Restore the journaled state anyway?") {
+					txn.abort()
+					ui.Muted("Cancelled")
+					return nil
+				}
+			} else if !cfg.JSONMode {
+				ui.Warning(msg)
+			}
+		}
+
+		switch e.Kind {
+		case undo.KindKV:
+			err = kvRestore(e.NamespaceID, e.Key, e.Before)
+		case undo.KindR2:
+			err = r2Restore(j, e.Bucket, e.Key, e.Before)
+		case undo.KindSecret:
+			err = secretRestore(v, e.Key, e.Before)
+		}
+		if err != nil {
+			txn.abort()
+			return err
+		}
+		txn.commit()
+
+		e.UndoneAt = time.Now().UTC().Format(time.RFC3339)
+		e.UndoneBy = redo.ID
+		if err := j.Save(e); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not mark #%d as undone: %v\n", e.ID, err)
+		}
+
+		if cfg.JSONMode {
+			return printJSON(map[string]any{
+				"id":       e.ID,
+				"resource": e.Resource(),
+				"restored": undoStateLabel(e.Before),
+				"drifted":  drifted,
+				"undo_id":  redo.ID,
+			})
+		}
+		ui.Success(fmt.Sprintf("Restored %s to %s", e.Resource(), undoStateLabel(e.Before)))
+		ui.Hint(fmt.Sprintf("gw undo %d reverses this", redo.ID))
+		return nil
+	},
+}
+
+// undoOperation names the safety operation that undoing e performs: a
+// delete when the resource did not exist before, a write otherwise. Flag
+// entries are KV entries journaled under the "flags" alias.
+func undoOperation(e *undo.Entry) string {
+	remove := e.Before == nil
+	switch {
+	case e.Kind == undo.KindKV && e.Namespace == "flags":
+		if remove {
+			return "flag_delete"
+		}
+		return "flag_revert"
+	case e.Kind == undo.KindKV:
+		if remove {
+			return "kv_delete"
+		}
+		return "kv_put"
+	case e.Kind == undo.KindR2:
+		if remove {
+			return "r2_rm"
+		}
+		return "r2_put"
+	case e.Kind == undo.KindSecret:
+		if remove {
+			return "secret_delete"
+		}
+		return "secret_set"
+	}
+	return "undo"
+}
+
+// --- undo list ---
+
+var undoListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show journaled writes that can be undone",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Get()
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		j, err := undo.Open()
+		if err != nil {
+			return err
+		}
+		entries, err := j.List(limit)
+		if err != nil {
+			return err
+		}
+
+		if cfg.JSONMode {
+			return printJSON(map[string]any{
+				"entries": entries,
+				"count":   len(entries),
+			})
+		}
+
+		if len(entries) == 0 {
+			ui.Muted("Nothing to undo.")
+			return nil
+		}
+
+		headers := []string{"#", "Command", "Resource", "Change", "Status", "Time"}
+		var rows [][]string
+		for _, e := range entries {
+			status := ""
+			switch {
+			case e.UndoneAt != "":
+				status = fmt.Sprintf("undone (#%d)", e.UndoneBy)
+			case e.Pending:
+				status = "unconfirmed"
+			}
+			ts := e.Timestamp
+			if len(ts) > 16 {
+				ts = ts[:16]
+			}
+			rows = append(rows, []string{
+				fmt.Sprintf("%d", e.ID),
+				e.Command,
+				TruncateStr(e.Resource(), 36),
+				undoChange(e),
+				status,
+				ts,
+			})
+		}
+		fmt.Print(ui.RenderTable(fmt.Sprintf("Undo Journal (%d entries)", len(entries)), headers, rows))
+		ui.Hint("gw undo <id> to restore the prior state")
+		return nil
+	},
+}
+
+// undoChange summarises what an entry's write did.
+func undoChange(e undo.Entry) string {
+	switch {
+	case e.Before == nil && e.After != nil:
+		return "created"
+	case e.Before != nil && e.After == nil:
+		return "deleted " + formatSize(float64(e.Before.Size))
+	case e.Before != nil:
+		return "overwrote " + formatSize(float64(e.Before.Size))
+	}
+	return "no-op"
+}
+
+// undoStateLabel describes a state for messages.
+func undoStateLabel(s *undo.State) string {
+	switch {
+	case s == nil:
+		return "absent"
+	case s.Digest == "":
+		return "present"
+	}
+	if mac, ok := strings.CutPrefix(s.Digest, undo.HMACPrefix); ok {
+		return fmt.Sprintf("%s, hmac %s", formatSize(float64(s.Size)), mac[:12])
+	}
+	return fmt.Sprintf("%s, sha256 %s", formatSize(float64(s.Size)), s.Digest[:12])
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+
+	undoListCmd.Flags().IntP("limit", "n", 20, "Number of entries to show")
+	undoCmd.AddCommand(undoListCmd)
+}
@@ -0,0 +1,375 @@
+// Package audit keeps a tamper-evident log of every gw safety decision
+// at ~/.grove/gw_audit.jsonl.
+//
+// The log is append-only JSON Lines. Each entry carries the SHA-256 hash of
+// the previous entry and its own hash over its contents, forming a chain:
+// editing, reordering, or deleting any line breaks every hash after it,
+// which Verify reports. Removing lines from the end leaves a valid chain,
+// so the sequence number and hash of the newest entry are also kept in a
+// head file next to the log and Verify checks the log against it.
+//
+// The hashes are unkeyed: anyone who can rewrite both files can forge a
+// consistent history. The log guards against accidents and casual edits,
+// not against a determined local attacker.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	auditFile = "gw_audit.jsonl"
+	groveDir  = ".grove"
+
+	// headSuffix names the head file: the log path plus this suffix.
+	headSuffix = ".head"
+
+	// genesisHash is the PrevHash of the first entry in a log.
+	genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+	// tailChunk is how much of the file end is read to find the last entry.
+	tailChunk = 64 * 1024
+
+	// lockWait is how long Append waits for another gw process to finish.
+	lockWait = 2 * time.Second
+
+	// lockStale is the age after which an abandoned lock file is removed.
+	lockStale = 30 * time.Second
+)
+
+// Outcome values recorded on each entry.
+const (
+	OutcomeAllowed = "allowed"
+	OutcomeBlocked = "blocked"
+)
+
+// Target identifies the resource a decision applied to.
+type Target struct {
+	Branch    string `json:"branch,omitempty"`
+	Database  string `json:"database,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Worker    string `json:"worker,omitempty"`
+}
+
+// Entry is a single recorded safety decision.
+type Entry struct {
+	Seq         int64  `json:"seq"`
+	Timestamp   string `json:"timestamp"`
+	Command     string `json:"command,omitempty"`
+	Operation   string `json:"operation"`
+	Tier        string `json:"tier"`
+	BaseTier    string `json:"base_tier,omitempty"`
+	PolicyRule  string `json:"policy_rule,omitempty"`
+	Write       bool   `json:"write"`
+	Force       bool   `json:"force"`
+	AgentMode   bool   `json:"agent_mode"`
+	Interactive bool   `json:"interactive"`
+	Target      Target `json:"target"`
+	Outcome     string `json:"outcome"`
+	Reason      string `json:"reason,omitempty"`
+	PrevHash    string `json:"prev_hash"`
+	Hash        string `json:"hash"`
+}
+
+// head is the newest entry as of the last Append, stored in the head file.
+type head struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// Log is an append-only audit log file.
+type Log struct {
+	path string
+}
+
+// Open returns the audit log at ~/.grove/gw_audit.jsonl, creating the
+// directory if needed.
+func Open() (*Log, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("audit: cannot determine home directory: %w", err)
+	}
+	dir := filepath.Join(home, groveDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("audit: cannot create %s: %w", dir, err)
+	}
+	return OpenPath(filepath.Join(dir, auditFile)), nil
+}
+
+// OpenPath returns an audit log backed by the given file.
+func OpenPath(path string) *Log {
+	return &Log{path: path}
+}
+
+// Path returns the file backing the log.
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append chains e onto the end of the log. Seq, Timestamp, PrevHash, and
+// Hash are filled in; any values set by the caller are overwritten.
+func (l *Log) Append(e Entry) (Entry, error) {
+	release, err := l.lock()
+	if err != nil {
+		return e, err
+	}
+	defer release()
+
+	last, err := l.last()
+	if err != nil {
+		return e, err
+	}
+
+	e.Seq = 1
+	e.PrevHash = genesisHash
+	if last != nil {
+		e.Seq = last.Seq + 1
+		e.PrevHash = last.Hash
+	}
+	e.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	e.Hash = hashEntry(e)
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return e, fmt.Errorf("audit: marshal: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return e, fmt.Errorf("audit: open %s: %w", l.path, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return e, fmt.Errorf("audit: write %s: %w", l.path, err)
+	}
+	return e, l.writeHead(head{Seq: e.Seq, Hash: e.Hash})
+}
+
+// writeHead replaces the head file, through a temporary file so a crash
+// never leaves it half-written.
+func (l *Log) writeHead(h head) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("audit: marshal head: %w", err)
+	}
+	path := l.path + headSuffix
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("audit: write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("audit: write %s: %w", path, err)
+	}
+	return nil
+}
+
+// readHead returns the recorded head, or nil when there is no head file.
+func (l *Log) readHead() (*head, error) {
+	path := l.path + headSuffix
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("audit: read %s: %w", path, err)
+	}
+	var h head
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("audit: %s is corrupt: %w", path, err)
+	}
+	return &h, nil
+}
+
+// Entries reads every entry in file order. Lines that fail to parse are
+// returned as errors so callers never silently skip tampered data.
+func (l *Log) Entries() ([]Entry, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("audit: read %s: %w", l.path, err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return entries, fmt.Errorf("audit: line %d is not valid JSON: %w", lineNo, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, fmt.Errorf("audit: read %s: %w", l.path, err)
+	}
+	return entries, nil
+}
+
+// VerifyResult describes the outcome of a chain verification.
+type VerifyResult struct {
+	OK      bool   `json:"ok"`
+	Entries int    `json:"entries"`
+	BadSeq  int64  `json:"bad_seq,omitempty"`
+	Problem string `json:"problem,omitempty"`
+}
+
+// Verify walks the chain and reports the first entry whose hash, sequence,
+// or link to its predecessor does not check out, then checks the log
+// against the head file so entries cut from the end are reported too.
+func (l *Log) Verify() (VerifyResult, error) {
+	entries, err := l.Entries()
+	if err != nil {
+		return VerifyResult{Entries: len(entries), BadSeq: int64(len(entries) + 1), Problem: err.Error()}, nil
+	}
+	res := VerifyEntries(entries)
+	if !res.OK {
+		return res, nil
+	}
+	h, err := l.readHead()
+	if err != nil {
+		return VerifyResult{Entries: len(entries), Problem: err.Error()}, nil
+	}
+	return verifyHead(entries, h), nil
+}
+
+// verifyHead checks an intact chain against the recorded head. The head
+// may trail the log by the entries of an Append that crashed before
+// updating it, but it must never be ahead of it or disagree with it.
+func verifyHead(entries []Entry, h *head) VerifyResult {
+	n := len(entries)
+	if h == nil {
+		if n == 0 {
+			return VerifyResult{OK: true}
+		}
+		return VerifyResult{Entries: n, BadSeq: entries[n-1].Seq,
+			Problem: "head file is missing (deleted along with entries?)"}
+	}
+	if h.Seq > int64(n) {
+		return VerifyResult{Entries: n, BadSeq: int64(n) + 1,
+			Problem: fmt.Sprintf("log ends at entry %d but entry %d was written (entries removed from the end)", n, h.Seq)}
+	}
+	if h.Seq > 0 && entries[h.Seq-1].Hash != h.Hash {
+		return VerifyResult{Entries: n, BadSeq: h.Seq,
+			Problem: "entry does not match the recorded head (log replaced)"}
+	}
+	return VerifyResult{OK: true, Entries: n}
+}
+
+// VerifyEntries checks a sequence of entries for chain integrity.
+func VerifyEntries(entries []Entry) VerifyResult {
+	prev := genesisHash
+	var prevSeq int64
+	for _, e := range entries {
+		if e.Seq != prevSeq+1 {
+			return VerifyResult{Entries: len(entries), BadSeq: e.Seq,
+				Problem: fmt.Sprintf("sequence jumps from %d to %d (entries removed or reordered)", prevSeq, e.Seq)}
+		}
+		if e.PrevHash != prev {
+			return VerifyResult{Entries: len(entries), BadSeq: e.Seq,
+				Problem: "prev_hash does not match the preceding entry"}
+		}
+		if hashEntry(e) != e.Hash {
+			return VerifyResult{Entries: len(entries), BadSeq: e.Seq,
+				Problem: "entry contents do not match its hash (edited)"}
+		}
+		prev = e.Hash
+		prevSeq = e.Seq
+	}
+	return VerifyResult{OK: true, Entries: len(entries)}
+}
+
+// hashEntry computes the chain hash of e: SHA-256 over the entry's JSON
+// encoding with the Hash field cleared. PrevHash is part of that encoding,
+// which is what links each entry to the one before it.
+func hashEntry(e Entry) string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// last returns the final entry in the log, or nil if the log is empty.
+// Only the tail of the file is read, so appends stay cheap as the log grows.
+func (l *Log) last() (*Entry, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("audit: read %s: %w", l.path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("audit: stat %s: %w", l.path, err)
+	}
+	offset := info.Size() - tailChunk
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("audit: seek %s: %w", l.path, err)
+	}
+	tail, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("audit: read %s: %w", l.path, err)
+	}
+
+	lines := bytes.Split(bytes.TrimRight(tail, "\n"), []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		if len(bytes.TrimSpace(lines[i])) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(lines[i], &e); err != nil {
+			return nil, fmt.Errorf("audit: last entry in %s is corrupt: %w", l.path, err)
+		}
+		return &e, nil
+	}
+	return nil, nil
+}
+
+// lock serialises appends across concurrent gw processes with an exclusive
+// lock file next to the log. Locks older than lockStale are assumed to be
+// left over from a crashed process and are removed.
+func (l *Log) lock() (func(), error) {
+	lockPath := l.path + ".lock"
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("audit: lock %s: %w", lockPath, err)
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("audit: timed out waiting for %s", lockPath)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
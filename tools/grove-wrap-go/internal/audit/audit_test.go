@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestLog(t *testing.T) *Log {
+	t.Helper()
+	return OpenPath(filepath.Join(t.TempDir(), "audit.jsonl"))
+}
+
+func appendN(t *testing.T, l *Log, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		outcome := OutcomeAllowed
+		if i%2 == 1 {
+			outcome = OutcomeBlocked
+		}
+		if _, err := l.Append(Entry{Operation: "kv_put", Tier: "WRITE", Outcome: outcome}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+}
+
+func TestAppendChainsEntries(t *testing.T) {
+	l := newTestLog(t)
+	appendN(t, l, 3)
+
+	entries, err := l.Entries()
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	if entries[0].PrevHash != genesisHash {
+		t.Errorf("first entry should link to genesis, got %s", entries[0].PrevHash)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].PrevHash != entries[i-1].Hash {
+			t.Errorf("entry %d does not link to entry %d", i+1, i)
+		}
+		if entries[i].Seq != int64(i+1) {
+			t.Errorf("entry %d has seq %d", i+1, entries[i].Seq)
+		}
+	}
+}
+
+func TestVerifyIntactLog(t *testing.T) {
+	l := newTestLog(t)
+	appendN(t, l, 5)
+
+	res, err := l.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !res.OK || res.Entries != 5 {
+		t.Errorf("intact log should verify, got %+v", res)
+	}
+}
+
+func TestVerifyEmptyLog(t *testing.T) {
+	res, err := newTestLog(t).Verify()
+	if err != nil || !res.OK || res.Entries != 0 {
+		t.Errorf("empty log should verify, got %+v %v", res, err)
+	}
+}
+
+func TestVerifyDetectsEdit(t *testing.T) {
+	l := newTestLog(t)
+	appendN(t, l, 4)
+
+	data, _ := os.ReadFile(l.Path())
+	lines := strings.Split(string(data), "\n")
+	lines[1] = strings.Replace(lines[1], `"outcome":"blocked"`, `"outcome":"allowed"`, 1)
+	os.WriteFile(l.Path(), []byte(strings.Join(lines, "\n")), 0600)
+
+	res, _ := l.Verify()
+	if res.OK {
+		t.Fatal("edited log should fail verification")
+	}
+	if res.BadSeq != 2 {
+		t.Errorf("expected failure at seq 2, got %d (%s)", res.BadSeq, res.Problem)
+	}
+}
+
+func TestVerifyDetectsDeletion(t *testing.T) {
+	l := newTestLog(t)
+	appendN(t, l, 4)
+
+	data, _ := os.ReadFile(l.Path())
+	lines := strings.Split(string(data), "\n")
+	lines = append(lines[:2], lines[3:]...)
+	os.WriteFile(l.Path(), []byte(strings.Join(lines, "\n")), 0600)
+
+	res, _ := l.Verify()
+	if res.OK {
+		t.Fatal("log with a removed line should fail verification")
+	}
+}
+
+func TestVerifyDetectsRehashedEdit(t *testing.T) {
+	l := newTestLog(t)
+	appendN(t, l, 3)
+
+	// An attacker who recomputes the edited entry's own hash still breaks
+	// the link from the entry after it.
+	entries, _ := l.Entries()
+	entries[0].Outcome = OutcomeBlocked
+	entries[0].Hash = hashEntry(entries[0])
+
+	if res := VerifyEntries(entries); res.OK || res.BadSeq != 2 {
+		t.Errorf("rehashed edit should break the next link, got %+v", res)
+	}
+}
+
+func TestAppendContinuesAfterReopen(t *testing.T) {
+	l := newTestLog(t)
+	appendN(t, l, 2)
+
+	reopened := OpenPath(l.Path())
+	e, err := reopened.Append(Entry{Operation: "merge", Tier: "DANGEROUS", Outcome: OutcomeBlocked})
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if e.Seq != 3 {
+		t.Errorf("seq after reopen = %d, want 3", e.Seq)
+	}
+	if res, _ := reopened.Verify(); !res.OK {
+		t.Errorf("log should still verify: %+v", res)
+	}
+}
+
+func TestVerifyDetectsTruncatedTail(t *testing.T) {
+	l := newTestLog(t)
+	appendN(t, l, 4)
+
+	// Dropping the newest entries leaves a valid chain; only the head
+	// file shows that they were written.
+	data, _ := os.ReadFile(l.Path())
+	lines := strings.SplitAfter(string(data), "\n")
+	os.WriteFile(l.Path(), []byte(strings.Join(lines[:2], "")), 0600)
+
+	res, _ := l.Verify()
+	if res.OK || res.BadSeq != 3 || !strings.Contains(res.Problem, "removed from the end") {
+		t.Errorf("truncated log should fail verification, got %+v", res)
+	}
+
+	os.Remove(l.Path())
+	if res, _ := l.Verify(); res.OK {
+		t.Error("a deleted log with a head should fail verification")
+	}
+}
+
+func TestVerifyNeedsHead(t *testing.T) {
+	l := newTestLog(t)
+	appendN(t, l, 2)
+	os.Remove(l.Path() + headSuffix)
+
+	if res, _ := l.Verify(); res.OK || !strings.Contains(res.Problem, "head file is missing") {
+		t.Errorf("log without its head should fail verification, got %+v", res)
+	}
+}
+
+func TestVerifyAllowsLaggingHead(t *testing.T) {
+	l := newTestLog(t)
+	appendN(t, l, 2)
+	saved, _ := os.ReadFile(l.Path() + headSuffix)
+	appendN(t, l, 1)
+
+	// An Append that crashed after writing its entry leaves the head one
+	// behind, which is not tampering.
+	os.WriteFile(l.Path()+headSuffix, saved, 0600)
+	if res, _ := l.Verify(); !res.OK {
+		t.Errorf("a head behind the log should verify, got %+v", res)
+	}
+
+	os.WriteFile(l.Path()+headSuffix, []byte(`{"seq":2,"hash":"beef"}`), 0600)
+	if res, _ := l.Verify(); res.OK || res.BadSeq != 2 {
+		t.Errorf("a head that disagrees with the log should fail, got %+v", res)
+	}
+}
@@ -0,0 +1,227 @@
+// Package backups keeps the record of verified D1 backups and works out
+// which backups a retention policy keeps.
+//
+// Verifications live in ~/.grove/backup_verifications.json, keyed by
+// database name and backup ID, so gw backup restore can tell a backup that
+// has been checked from one that never was.
+package backups
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Verification is the outcome of one gw backup verify.
+type Verification struct {
+	VerifiedAt string `json:"verified_at"`
+	OK         bool   `json:"ok"`
+	Problems   int    `json:"problems"`
+	Tables     int    `json:"tables"`
+	Rows       int64  `json:"rows"`
+	// IntegrityCheck is set when SQLite's own PRAGMA integrity_check ran.
+	// Verifications from gw versions that only walked the file's structure
+	// leave it unset.
+	IntegrityCheck bool `json:"integrity_check,omitempty"`
+}
+
+// Verified reports whether the backup passed a full check: no problems,
+// with PRAGMA integrity_check among the checks run.
+func (v Verification) Verified() bool {
+	return v.OK && v.IntegrityCheck
+}
+
+// Ledger holds verifications for every database.
+type Ledger struct {
+	path      string
+	Databases map[string]map[string]Verification
+}
+
+// LedgerPath returns ~/.grove/backup_verifications.json, or "" without a
+// home directory.
+func LedgerPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".grove", "backup_verifications.json")
+}
+
+// LoadLedger reads the ledger at path; a missing file is an empty ledger.
+func LoadLedger(path string) (*Ledger, error) {
+	l := &Ledger{path: path, Databases: map[string]map[string]Verification{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &l.Databases); err != nil {
+		return nil, fmt.Errorf("corrupt verification ledger %s: %w", path, err)
+	}
+	if l.Databases == nil {
+		l.Databases = map[string]map[string]Verification{}
+	}
+	return l, nil
+}
+
+// Save writes the ledger atomically.
+func (l *Ledger) Save() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(l.Databases, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// Get returns the verification of a backup, if there is one.
+func (l *Ledger) Get(db, id string) (Verification, bool) {
+	v, ok := l.Databases[db][id]
+	return v, ok
+}
+
+// Record stores the verification of a backup, replacing any earlier one.
+func (l *Ledger) Record(db, id string, v Verification) {
+	if l.Databases[db] == nil {
+		l.Databases[db] = map[string]Verification{}
+	}
+	l.Databases[db][id] = v
+}
+
+// Forget drops the verification of a backup that no longer exists.
+func (l *Ledger) Forget(db, id string) {
+	delete(l.Databases[db], id)
+	if len(l.Databases[db]) == 0 {
+		delete(l.Databases, db)
+	}
+}
+
+// Backup is a backup as wrangler lists it.
+type Backup struct {
+	ID        string    `json:"id"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Policy is how many backups to keep: the newest Last, plus the newest
+// backup of each of the last Daily days, Weekly ISO weeks and Monthly
+// months that have one. Periods are in UTC.
+type Policy struct {
+	Last    int
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// Empty reports whether the policy keeps nothing, which prune refuses.
+func (p Policy) Empty() bool {
+	return p.Last <= 0 && p.Daily <= 0 && p.Weekly <= 0 && p.Monthly <= 0
+}
+
+func (p Policy) String() string {
+	var parts []string
+	for _, r := range []struct {
+		n    int
+		name string
+	}{{p.Last, "last"}, {p.Daily, "daily"}, {p.Weekly, "weekly"}, {p.Monthly, "monthly"}} {
+		if r.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", r.n, r.name))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return "keep " + strings.Join(parts, ", ")
+}
+
+// Decision is what a policy does with one backup.
+type Decision struct {
+	Backup
+	Keep    bool     `json:"keep"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Plan applies a policy to backups, newest first. Whatever the policy
+// says, the newest backup, backups that are not finished and the newest
+// verified backup (verified reports whether one passed gw backup verify)
+// are kept, so pruning never leaves nothing to restore.
+func Plan(list []Backup, p Policy, verified func(id string) bool) []Decision {
+	out := make([]Decision, len(list))
+	for i, b := range list {
+		out[i] = Decision{Backup: b}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+
+	keep := func(i int, reason string) {
+		out[i].Keep = true
+		out[i].Reasons = append(out[i].Reasons, reason)
+	}
+	bucket := func(n int, name string, period func(time.Time) string) {
+		seen := map[string]bool{}
+		for i := range out {
+			if len(seen) == n {
+				return
+			}
+			if !finished(out[i].State) {
+				continue
+			}
+			if k := period(out[i].CreatedAt.UTC()); !seen[k] {
+				seen[k] = true
+				keep(i, name)
+			}
+		}
+	}
+
+	last := 0
+	for i := range out {
+		if !finished(out[i].State) {
+			keep(i, "in progress")
+			continue
+		}
+		if last < p.Last {
+			last++
+			keep(i, "last")
+		}
+	}
+	bucket(p.Daily, "daily", func(t time.Time) string { return t.Format("2006-01-02") })
+	bucket(p.Weekly, "weekly", func(t time.Time) string {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	})
+	bucket(p.Monthly, "monthly", func(t time.Time) string { return t.Format("2006-01") })
+
+	if len(out) > 0 && !out[0].Keep {
+		keep(0, "newest")
+	}
+	for i := range out {
+		if verified != nil && finished(out[i].State) && verified(out[i].ID) {
+			if !out[i].Keep {
+				keep(i, "newest verified")
+			}
+			break
+		}
+	}
+	return out
+}
+
+// finished reports whether a backup state is a completed one. wrangler
+// has reported "done" and "complete"; an empty state counts as finished.
+func finished(state string) bool {
+	switch strings.ToLower(state) {
+	case "", "done", "complete", "completed", "success":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package backups
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlan(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	var list []Backup
+	// Two backups a day for 40 days, the newest still running.
+	for d := 0; d < 40; d++ {
+		for _, h := range []int{9, 3} {
+			at := now.AddDate(0, 0, -d).Add(time.Duration(h-12) * time.Hour)
+			list = append(list, Backup{ID: at.Format("0102-15"), State: "done", CreatedAt: at})
+		}
+	}
+	list[0].State = "active"
+
+	decisions := Plan(list, Policy{Daily: 7, Weekly: 4}, func(id string) bool { return id == "0301-03" })
+	kept := map[string]string{}
+	for _, d := range decisions {
+		if d.Keep {
+			kept[d.ID] = strings.Join(d.Reasons, "+")
+		}
+	}
+	want := map[string]string{
+		"0331-09": "in progress",
+		"0331-03": "daily+weekly", // newest finished: also the week of 3-30
+		"0330-09": "daily",
+		"0329-09": "daily+weekly",
+		"0328-09": "daily",
+		"0327-09": "daily",
+		"0326-09": "daily",
+		"0325-09": "daily",
+		"0322-09": "weekly",
+		"0315-09": "weekly",
+		"0301-03": "newest verified",
+	}
+	if len(kept) != len(want) {
+		t.Errorf("kept %d backups, want %d: %v", len(kept), len(want), kept)
+	}
+	for id, reasons := range want {
+		if kept[id] != reasons {
+			t.Errorf("%s kept for %q, want %q", id, kept[id], reasons)
+		}
+	}
+	if decisions[0].ID != "0331-09" {
+		t.Errorf("plan should be newest first, starts with %s", decisions[0].ID)
+	}
+}
+
+func TestPlanKeepsNewest(t *testing.T) {
+	list := []Backup{
+		{ID: "old", CreatedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: "new", CreatedAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	d := Plan(list, Policy{Monthly: 0, Last: 0, Daily: 0}, nil)
+	if !d[0].Keep || d[0].ID != "new" || d[1].Keep {
+		t.Errorf("Plan = %+v", d)
+	}
+	if !(Policy{}).Empty() || (Policy{Weekly: 1}).Empty() {
+		t.Error("Empty is wrong")
+	}
+	if got := (Policy{Daily: 7, Weekly: 4}).String(); got != "keep 7 daily, 4 weekly" {
+		t.Errorf("String = %q", got)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v.json")
+	l, err := LoadLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record("db", "a", Verification{OK: true, Tables: 3})
+	l.Record("db", "b", Verification{OK: false, Problems: 2})
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = LoadLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := l.Get("db", "a"); !ok || !v.OK || v.Tables != 3 {
+		t.Errorf("Get(a) = %+v, %v", v, ok)
+	}
+	l.Forget("db", "a")
+	l.Forget("db", "b")
+	if _, ok := l.Get("db", "b"); ok || len(l.Databases) != 0 {
+		t.Errorf("Forget left %v", l.Databases)
+	}
+}
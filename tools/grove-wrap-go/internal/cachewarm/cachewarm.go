@@ -0,0 +1,299 @@
+// Package cachewarm requests a site's routes so its caches fill before
+// visitors arrive, and reports how each response was served.
+//
+// Routes come from a list or from a SvelteKit src/routes directory, read
+// the way gf routes reads it: every +page.svelte is a page, and +server
+// endpoints outside /api that export GET are fetchable files such as /rss.xml. Route
+// groups like (site) add nothing to the path; routes with parameters need
+// values gw does not have and are skipped.
+package cachewarm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverFiles are the endpoint modules that make a directory a route.
+var serverFiles = map[string]bool{"+server.ts": true, "+server.js": true}
+
+// Routes walks a SvelteKit routes directory and returns the paths it can
+// request, sorted, and the parameterized routes it had to skip.
+func Routes(dir string) (paths, skipped []string, err error) {
+	seen := map[string]bool{}
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "node_modules" || (strings.HasPrefix(d.Name(), ".") && p != dir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		page := d.Name() == "+page.svelte"
+		if !page && !serverFiles[d.Name()] {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		route, dynamic := routePath(filepath.ToSlash(rel))
+		if !page && (route == "/api" || strings.HasPrefix(route, "/api/") || !exportsGET(p)) {
+			return nil
+		}
+		if seen[route] {
+			return nil
+		}
+		seen[route] = true
+		if dynamic {
+			skipped = append(skipped, route)
+		} else {
+			paths = append(paths, route)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(paths)
+	sort.Strings(skipped)
+	return paths, skipped, nil
+}
+
+// getExport finds a GET handler in an endpoint module.
+var getExport = regexp.MustCompile(`export\s+(?:const\s+GET\b|(?:async\s+)?function\s+GET\b|\{[^}]*\bGET\b)`)
+
+// exportsGET reports whether an endpoint answers GET requests.
+func exportsGET(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && getExport.Match(data)
+}
+
+// routePath turns a route directory into its URL path. Groups vanish and
+// optional parameters are left out; any other parameter makes the route
+// dynamic, and the path keeps it as written.
+func routePath(rel string) (string, bool) {
+	var parts []string
+	dynamic := false
+	for _, seg := range strings.Split(rel, "/") {
+		switch {
+		case seg == "." || seg == "":
+		case strings.HasPrefix(seg, "(") && strings.HasSuffix(seg, ")"):
+		case strings.HasPrefix(seg, "[[") && strings.HasSuffix(seg, "]]"):
+		case strings.Contains(seg, "["):
+			dynamic = true
+			parts = append(parts, seg)
+		default:
+			parts = append(parts, seg)
+		}
+	}
+	return "/" + strings.Join(parts, "/"), dynamic
+}
+
+// Exclude drops the paths matching any pattern. In a pattern * stands for
+// part of one path segment, ** for anything, and a trailing /** also
+// matches the path it follows, so "/arbor/**" covers /arbor itself.
+func Exclude(paths, patterns []string) ([]string, error) {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := compilePattern(p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	var out []string
+	for _, p := range paths {
+		excluded := false
+		for _, re := range res {
+			excluded = excluded || re.MatchString(p)
+		}
+		if !excluded {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("route pattern %q must start with /", pattern)
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			b.WriteString("(?:/.*)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// Result is how one request went.
+type Result struct {
+	Path      string  `json:"path"`
+	Status    int     `json:"status"`
+	Cache     string  `json:"cache"` // the cache status header, upper-cased; "" without one
+	LatencyMS float64 `json:"latency_ms"`
+	Bytes     int64   `json:"bytes"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Failed reports whether the request did not get a usable response.
+func (r Result) Failed() bool {
+	return r.Error != "" || r.Status >= 500
+}
+
+// cacheHeaders are checked in order for how a response was served.
+var cacheHeaders = []string{"Cf-Cache-Status", "X-Cache-Status", "X-Cache"}
+
+// CacheStatus reads how a response was served: HIT, MISS, EXPIRED,
+// DYNAMIC and so on, from Cloudflare's header or the common alternatives.
+func CacheStatus(h http.Header) string {
+	for _, name := range cacheHeaders {
+		if v := strings.TrimSpace(h.Get(name)); v != "" {
+			// "HIT from edge-1" → HIT
+			return strings.ToUpper(strings.Fields(v)[0])
+		}
+	}
+	return ""
+}
+
+// Options tune a crawl.
+type Options struct {
+	Concurrency int
+	Timeout     time.Duration // per request
+	UserAgent   string
+	Client      *http.Client // nil: a client that does not follow redirects
+}
+
+// Crawl requests base+path for every path and returns the results in the
+// order of paths. Bodies are read in full, since a cache fills only with
+// complete responses. Redirects are reported rather than followed.
+func Crawl(ctx context.Context, base string, paths []string, opts Options) []Result {
+	base = strings.TrimSuffix(base, "/")
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+	workers := max(opts.Concurrency, 1)
+
+	results := make([]Result, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = fetch(ctx, client, base, paths[i], opts)
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func fetch(ctx context.Context, client *http.Client, base, path string, opts Options) Result {
+	r := Result{Path: path}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path, nil)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if opts.UserAgent != "" {
+		req.Header.Set("User-Agent", opts.UserAgent)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	defer resp.Body.Close()
+	r.Bytes, err = io.Copy(io.Discard, resp.Body)
+	r.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	r.Status = resp.StatusCode
+	r.Cache = CacheStatus(resp.Header)
+	if err != nil {
+		r.Error = "reading body: " + err.Error()
+	}
+	return r
+}
+
+// Summary totals a crawl.
+type Summary struct {
+	Requests int            `json:"requests"`
+	Failed   int            `json:"failed"`
+	Cache    map[string]int `json:"cache"` // cache status → responses; "" counted as NONE
+	P50MS    float64        `json:"p50_ms"`
+	P95MS    float64        `json:"p95_ms"`
+	MaxMS    float64        `json:"max_ms"`
+}
+
+// Summarize totals results. Latency percentiles cover the requests that
+// got a response.
+func Summarize(results []Result) Summary {
+	s := Summary{Requests: len(results), Cache: map[string]int{}}
+	var lat []float64
+	for _, r := range results {
+		if r.Failed() {
+			s.Failed++
+		}
+		if r.Error != "" {
+			continue
+		}
+		status := r.Cache
+		if status == "" {
+			status = "NONE"
+		}
+		s.Cache[status]++
+		lat = append(lat, r.LatencyMS)
+	}
+	if len(lat) > 0 {
+		sort.Float64s(lat)
+		s.P50MS = percentile(lat, 50)
+		s.P95MS = percentile(lat, 95)
+		s.MaxMS = lat[len(lat)-1]
+	}
+	return s
+}
+
+// percentile picks the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p int) float64 {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank-1, 0)]
+}
@@ -0,0 +1,141 @@
+package cachewarm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	dir := t.TempDir()
+	get := "export const GET: RequestHandler = () => new Response('ok');"
+	files := map[string]string{
+		"(tenant)/rss.xml/+server.ts": get,
+		"api/posts/+server.ts":        get,
+		"webhook/+server.ts":          "export async function POST() {}",
+		"feed.json/+server.js":        "export async function GET() {}",
+	}
+	for _, f := range []string{
+		"(site)/+page.svelte",
+		"(site)/about/+page.svelte",
+		"(site)/[slug]/+page.svelte",
+		"(apps)/garden/[[page]]/+page.svelte",
+		"arbor/+page.svelte",
+		"blog/[...rest]/+page.svelte",
+		"blog/+layout.svelte",
+		".svelte-kit/+page.svelte",
+	} {
+		files[f] = ""
+	}
+	for f, body := range files {
+		p := filepath.Join(dir, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte(body), 0644)
+	}
+
+	paths, skipped, err := Routes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPaths := []string{"/", "/about", "/arbor", "/feed.json", "/garden", "/rss.xml"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("paths = %v, want %v", paths, wantPaths)
+	}
+	wantSkipped := []string{"/[slug]", "/blog/[...rest]"}
+	if !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Errorf("skipped = %v, want %v", skipped, wantSkipped)
+	}
+}
+
+func TestExclude(t *testing.T) {
+	paths := []string{"/", "/arbor", "/arbor/settings", "/arboretum", "/auth/login", "/blog/a/b", "/rss.xml"}
+	got, err := Exclude(paths, []string{"/arbor/**", "/auth/*", "/blog/**/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/", "/arboretum", "/rss.xml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Exclude = %v, want %v", got, want)
+	}
+	if _, err := Exclude(paths, []string{"arbor"}); err == nil {
+		t.Error("Exclude accepted a pattern without a leading /")
+	}
+}
+
+func TestCrawl(t *testing.T) {
+	var mu sync.Mutex
+	warm := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/", http.StatusMovedPermanently)
+			return
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		case "/plain":
+			w.Write([]byte("no cache header"))
+			return
+		}
+		mu.Lock()
+		status := "MISS"
+		if warm[r.URL.Path] {
+			status = "HIT"
+		}
+		warm[r.URL.Path] = true
+		mu.Unlock()
+		w.Header().Set("Cf-Cache-Status", status)
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	paths := []string{"/", "/about", "/old", "/broken", "/plain"}
+	opts := Options{Concurrency: 3}
+	first := Crawl(context.Background(), srv.URL+"/", paths, opts)
+	second := Crawl(context.Background(), srv.URL, paths, opts)
+
+	for i, r := range first {
+		if r.Path != paths[i] {
+			t.Fatalf("result %d is for %s, want %s", i, r.Path, paths[i])
+		}
+	}
+	if first[0].Cache != "MISS" || second[0].Cache != "HIT" || first[0].Bytes != 5 {
+		t.Errorf("/ = %+v then %+v", first[0], second[0])
+	}
+	if first[2].Status != http.StatusMovedPermanently {
+		t.Errorf("/old status = %d, want the redirect itself", first[2].Status)
+	}
+	if !first[3].Failed() || first[1].Failed() {
+		t.Errorf("Failed: /broken %v, /about %v", first[3].Failed(), first[1].Failed())
+	}
+
+	s := Summarize(second)
+	if s.Requests != 5 || s.Failed != 1 || s.Cache["HIT"] != 2 || s.Cache["NONE"] != 3 {
+		t.Errorf("Summarize = %+v", s)
+	}
+	if s.MaxMS < s.P95MS || s.P95MS < s.P50MS {
+		t.Errorf("latencies out of order: %+v", s)
+	}
+
+	down := Crawl(context.Background(), "http://127.0.0.1:1", []string{"/"}, opts)
+	if down[0].Error == "" || !down[0].Failed() {
+		t.Errorf("unreachable host = %+v", down[0])
+	}
+}
+
+func TestCacheStatus(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Cache", "Hit from cloudfront")
+	if got := CacheStatus(h); got != "HIT" {
+		t.Errorf("CacheStatus = %q", got)
+	}
+	h.Set("Cf-Cache-Status", "dynamic")
+	if got := CacheStatus(h); got != "DYNAMIC" {
+		t.Errorf("CacheStatus = %q", got)
+	}
+}
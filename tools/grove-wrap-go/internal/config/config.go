@@ -18,15 +18,20 @@ import (
 
 // Config is the top-level gw configuration.
 type Config struct {
-	Databases    map[string]Database `toml:"databases"`
+	Databases    map[string]Database  `toml:"databases"`
 	KVNamespaces map[string]Namespace `toml:"kv_namespaces"`
-	R2Buckets    []Bucket            `toml:"r2_buckets"`
-	Safety       SafetyConfig        `toml:"safety"`
-	Git          GitConfig           `toml:"git"`
-	GitHub       GitHubConfig        `toml:"github"`
-	Grove        GroveConfig         `toml:"grove"`
-	Todoist      TodoistConfig       `toml:"todoist"`
-	TUI          TUIConfig           `toml:"tui"`
+	KV           KVConfig             `toml:"kv"`
+	Cache        CacheConfig          `toml:"cache"`
+	Flags        FlagsConfig          `toml:"flags"`
+	R2Buckets    []Bucket             `toml:"r2_buckets"`
+	Safety       SafetyConfig         `toml:"safety"`
+	Scrub        ScrubConfig          `toml:"scrub"`
+	Backup       BackupConfig         `toml:"backup"`
+	Git          GitConfig            `toml:"git"`
+	GitHub       GitHubConfig         `toml:"github"`
+	Grove        GroveConfig          `toml:"grove"`
+	Todoist      TodoistConfig        `toml:"todoist"`
+	TUI          TUIConfig            `toml:"tui"`
 
 	// Runtime state (not from TOML)
 	AgentMode       bool   `toml:"-"`
@@ -36,12 +41,13 @@ type Config struct {
 	ForceFlag       bool   `toml:"-"`
 	GroveRoot       string `toml:"-"`
 	NoCloud         bool   `toml:"-"` // skip wrangler/cloud calls (offline mode)
+	DryRun          bool   `toml:"-"` // print mutating subprocess calls instead of running them
 	InteractiveMode bool   `toml:"-"` // enable Bubble Tea TUI (opt-in, humans only)
 }
 
 // TUIConfig controls interactive TUI browser behavior.
 type TUIConfig struct {
-	AutoWorktree bool `toml:"auto_worktree"` // auto-create worktrees when launching skills
+	AutoWorktree bool `toml:"auto_worktree"`  // auto-create worktrees when launching skills
 	ItemsPerPage int  `toml:"items_per_page"` // number of items to fetch per page
 	ViewportRows int  `toml:"viewport_rows"`  // visible rows in the TUI browser
 	YoloMode     bool `toml:"yolo_mode"`      // launch all skills with --dangerously-skip-permissions
@@ -54,12 +60,48 @@ type Database struct {
 	MigrationsDir string `toml:"migrations_dir"` // path to dir containing wrangler.toml (relative to grove root)
 }
 
+// CacheConfig controls gw cache warm. BaseURL may contain {tenant}. When
+// Routes is empty the routes are read from RoutesDir, a SvelteKit routes
+// directory relative to the grove root. Exclude patterns drop routes that
+// are never cached, with * for part of a segment and ** for any depth.
+type CacheConfig struct {
+	BaseURL   string   `toml:"base_url"`
+	Routes    []string `toml:"routes"`
+	RoutesDir string   `toml:"routes_dir"`
+	Exclude   []string `toml:"exclude"`
+}
+
+// FlagsConfig controls gw flag audit. SourceDirs, relative to the grove
+// root, are searched for flag reads. A flag fully on or off for StaleDays
+// is reported as ready to remove. Ignore lists flags the audit should not
+// report, such as those whose names are built at runtime.
+type FlagsConfig struct {
+	SourceDirs []string `toml:"source_dirs"`
+	StaleDays  int      `toml:"stale_days"`
+	Ignore     []string `toml:"ignore"`
+}
+
 // Namespace represents a KV namespace.
 type Namespace struct {
 	Name string `toml:"name"`
 	ID   string `toml:"id"`
 }
 
+// KVConfig controls KV writes.
+type KVConfig struct {
+	Schemas []KVSchema `toml:"schemas"`
+}
+
+// KVSchema requires the values of matching keys to be JSON and, when
+// Schema names a JSON Schema file, to satisfy it. Keys is a glob such as
+// "flag:*"; Namespace limits the rule to one namespace alias. A relative
+// Schema path is resolved against the directory of gw.toml.
+type KVSchema struct {
+	Namespace string `toml:"namespace"`
+	Keys      string `toml:"keys"`
+	Schema    string `toml:"schema"`
+}
+
 // Bucket represents an R2 bucket.
 type Bucket struct {
 	Name string `toml:"name"`
@@ -70,6 +112,30 @@ type SafetyConfig struct {
 	MaxDeleteRows   int      `toml:"max_delete_rows"`
 	MaxUpdateRows   int      `toml:"max_update_rows"`
 	ProtectedTables []string `toml:"protected_tables"`
+	// MaxKVDeleteKeys caps how many keys one bulk KV delete may remove.
+	MaxKVDeleteKeys int `toml:"max_kv_delete_keys"`
+}
+
+// ScrubConfig masks personal data in gw d1 export. Columns maps
+// "table.column" or "*.column" to a strategy: email, name, token, null or
+// keep.
+type ScrubConfig struct {
+	Columns map[string]string `toml:"columns"`
+}
+
+// BackupConfig controls D1 backups.
+type BackupConfig struct {
+	Retention RetentionConfig `toml:"retention"`
+}
+
+// RetentionConfig is the policy gw backup prune applies: keep the newest
+// KeepLast backups plus the newest of each of the last Daily days, Weekly
+// weeks and Monthly months. All zero means no policy.
+type RetentionConfig struct {
+	KeepLast int `toml:"keep_last"`
+	Daily    int `toml:"daily"`
+	Weekly   int `toml:"weekly"`
+	Monthly  int `toml:"monthly"`
 }
 
 // GitConfig controls git behavior.
@@ -84,15 +150,15 @@ type GitConfig struct {
 
 // GitHubConfig controls GitHub integration.
 type GitHubConfig struct {
-	Owner                  string            `toml:"owner"`
-	Repo                   string            `toml:"repo"`
-	DefaultPRLabels        []string          `toml:"default_pr_labels"`
-	DefaultIssueLabels     []string          `toml:"default_issue_labels"`
-	RateLimitWarnThreshold int               `toml:"rate_limit_warn_threshold"`
-	RateLimitBlockThreshold int              `toml:"rate_limit_block_threshold"`
-	ProjectNumber          *int              `toml:"project_number"`
-	ProjectFields          map[string]string `toml:"project_fields"`
-	ProjectValues          map[string]string `toml:"project_values"`
+	Owner                   string            `toml:"owner"`
+	Repo                    string            `toml:"repo"`
+	DefaultPRLabels         []string          `toml:"default_pr_labels"`
+	DefaultIssueLabels      []string          `toml:"default_issue_labels"`
+	RateLimitWarnThreshold  int               `toml:"rate_limit_warn_threshold"`
+	RateLimitBlockThreshold int               `toml:"rate_limit_block_threshold"`
+	ProjectNumber           *int              `toml:"project_number"`
+	ProjectFields           map[string]string `toml:"project_fields"`
+	ProjectValues           map[string]string `toml:"project_values"`
 }
 
 // TodoistConfig holds Todoist integration settings for gw todo.
@@ -124,7 +190,7 @@ func Get() *Config {
 }
 
 // Init initializes the config from flags, environment, and the TOML file.
-func Init(writeFlag, forceFlag, jsonMode, agentMode, verbose, noCloud, interactiveMode bool) *Config {
+func Init(writeFlag, forceFlag, jsonMode, agentMode, verbose, noCloud, dryRun, interactiveMode bool) *Config {
 	cfg := Get()
 
 	// Load TOML file (merges over defaults)
@@ -141,6 +207,9 @@ func Init(writeFlag, forceFlag, jsonMode, agentMode, verbose, noCloud, interacti
 
 	// Cloud and interactive flags (interactive disabled in agent/json mode)
 	cfg.NoCloud = noCloud
+
+	// Dry-run from flag or inherited from a parent gw
+	cfg.DryRun = dryRun || envEnabled("GW_DRY_RUN")
 	cfg.InteractiveMode = interactiveMode && !cfg.AgentMode && !jsonMode
 
 	// Detect grove root
@@ -175,13 +244,26 @@ func DefaultConfig() *Config {
 		R2Buckets: []Bucket{
 			{Name: "grove-media"},
 		},
+		Cache: CacheConfig{
+			BaseURL:   "https://{tenant}.grove.place",
+			RoutesDir: "apps/aspen/src/routes",
+			Exclude:   []string{"/arbor/**", "/auth/**", "/api/**", "/logout"},
+		},
+		Flags: FlagsConfig{
+			SourceDirs: []string{"apps", "libs", "services", "workers"},
+			StaleDays:  30,
+		},
 		Safety: SafetyConfig{
-			MaxDeleteRows: 100,
-			MaxUpdateRows: 500,
+			MaxDeleteRows:   100,
+			MaxUpdateRows:   500,
+			MaxKVDeleteKeys: 100,
 			ProtectedTables: []string{
 				"users", "tenants", "subscriptions", "payments", "sessions",
 			},
 		},
+		Scrub: ScrubConfig{
+			Columns: map[string]string{},
+		},
 		Git: GitConfig{
 			CommitFormat: "conventional",
 			ConventionalTypes: []string{
@@ -222,6 +304,33 @@ func ConfigPath() string {
 	return filepath.Join(home, ".grove", "gw.toml")
 }
 
+// PolicyPath returns the path to the user-level safety policy file.
+func PolicyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".grove", "policy.toml")
+}
+
+// PluginDir returns the directory searched for gw-<name> plugins before PATH.
+func PluginDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".grove", "plugins")
+}
+
+// RepoPolicyPath returns the path to the repo-level safety policy file,
+// which lives at .grove/policy.toml under the grove root.
+func RepoPolicyPath(groveRoot string) string {
+	if groveRoot == "" {
+		return ""
+	}
+	return filepath.Join(groveRoot, ".grove", "policy.toml")
+}
+
 // loadFromFile loads configuration from ~/.grove/gw.toml, merging over defaults.
 func loadFromFile(cfg *Config) {
 	path := ConfigPath()
@@ -266,6 +375,11 @@ func (c *Config) Save() error {
 	// Overlay the sections we manage
 	diskCfg.TUI = c.TUI
 	diskCfg.Safety = c.Safety
+	diskCfg.Scrub = c.Scrub
+	diskCfg.Backup = c.Backup
+	diskCfg.KV = c.KV
+	diskCfg.Cache = c.Cache
+	diskCfg.Flags = c.Flags
 	diskCfg.Git = c.Git
 	diskCfg.GitHub = c.GitHub
 	diskCfg.Grove = c.Grove
@@ -347,17 +461,32 @@ func (c *Config) EffectiveMaxUpdateRows() int {
 	return c.Safety.MaxUpdateRows
 }
 
+// EffectiveMaxKVDeleteKeys returns the bulk KV delete ceiling, stricter in
+// agent mode.
+func (c *Config) EffectiveMaxKVDeleteKeys() int {
+	if c.AgentMode {
+		return 50
+	}
+	return c.Safety.MaxKVDeleteKeys
+}
+
 // isAgentEnv checks environment variables for agent mode indicators.
 func isAgentEnv() bool {
 	for _, key := range []string{"GW_AGENT_MODE", "CLAUDE_CODE", "MCP_SERVER"} {
-		val := os.Getenv(key)
-		if val != "" && val != "0" && val != "false" {
+		if envEnabled(key) {
 			return true
 		}
 	}
 	return false
 }
 
+// envEnabled reports whether an environment variable is set to anything
+// other than "", "0" or "false".
+func envEnabled(key string) bool {
+	val := os.Getenv(key)
+	return val != "" && val != "0" && val != "false"
+}
+
 // migrateMyceliumTenant reads tenant from ~/.grove/config.json (Mycelium's format).
 // Returns empty string if the file doesn't exist or doesn't contain a tenant.
 func migrateMyceliumTenant() string {
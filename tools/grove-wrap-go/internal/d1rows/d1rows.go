@@ -0,0 +1,386 @@
+// Package d1rows streams the rows of `wrangler d1 execute --json` output
+// into CSV, TSV, NDJSON, Markdown, JSON or SQLite, keeping columns in the
+// order wrangler returns them.
+//
+// Wrangler prints one result object per statement:
+//
+//	[{"results": [{"id": 1, "title": "…"}, …], "success": true, "meta": {…}}]
+//
+// Rows are decoded one at a time and handed to a Writer, so a large result
+// is never held in memory as a whole.
+package d1rows
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formats accepted by NewWriter, plus "json" and "sqlite", which have
+// their own constructors.
+var Formats = []string{"csv", "tsv", "ndjson", "markdown", "json", "sqlite"}
+
+// Writer receives a result set: Columns once before the first row, then
+// each row's values in column order, then Close. A result with no rows
+// gets no Columns call.
+type Writer interface {
+	Columns(cols []string) error
+	Row(values []any) error
+	Close() error
+}
+
+// Decode reads wrangler's JSON output from r and writes every row to w,
+// returning how many rows were written. Columns come from the first row;
+// numbers are json.Number so large integer IDs survive intact. Decode
+// does not close w.
+func Decode(r io.Reader, w Writer) (int, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	d := &decoder{dec: dec, w: w}
+
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse wrangler output: %w", err)
+	}
+	switch tok {
+	case json.Delim('['):
+		for dec.More() {
+			if err := d.expect('{'); err != nil {
+				return d.rows, err
+			}
+			if err := d.result(); err != nil {
+				return d.rows, err
+			}
+		}
+	case json.Delim('{'):
+		if err := d.result(); err != nil {
+			return d.rows, err
+		}
+	default:
+		return 0, fmt.Errorf("cannot parse wrangler output: unexpected %v", tok)
+	}
+	return d.rows, nil
+}
+
+type decoder struct {
+	dec  *json.Decoder
+	w    Writer
+	cols []string
+	rows int
+}
+
+func (d *decoder) expect(delim json.Delim) error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return fmt.Errorf("cannot parse wrangler output: %w", err)
+	}
+	if tok != delim {
+		return fmt.Errorf("cannot parse wrangler output: expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+// result reads one statement's result object after its opening brace.
+func (d *decoder) result() error {
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return fmt.Errorf("cannot parse wrangler output: %w", err)
+		}
+		if key, _ := tok.(string); key != "results" {
+			var skip json.RawMessage
+			if err := d.dec.Decode(&skip); err != nil {
+				return fmt.Errorf("cannot parse wrangler output: %w", err)
+			}
+			continue
+		}
+		if err := d.expect('['); err != nil {
+			return err
+		}
+		for d.dec.More() {
+			if err := d.row(); err != nil {
+				return err
+			}
+		}
+		if err := d.expect(']'); err != nil {
+			return err
+		}
+	}
+	return d.expect('}')
+}
+
+// row reads one row object, keeping its keys in order.
+func (d *decoder) row() error {
+	if err := d.expect('{'); err != nil {
+		return err
+	}
+	var keys []string
+	var values []any
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return fmt.Errorf("cannot parse wrangler output: %w", err)
+		}
+		key, _ := tok.(string)
+		var v any
+		if err := d.dec.Decode(&v); err != nil {
+			return fmt.Errorf("cannot parse wrangler output: %w", err)
+		}
+		keys, values = append(keys, key), append(values, v)
+	}
+	if err := d.expect('}'); err != nil {
+		return err
+	}
+
+	if d.cols == nil {
+		d.cols = keys
+		if err := d.w.Columns(keys); err != nil {
+			return err
+		}
+	} else if !sameColumns(d.cols, keys) {
+		values = alignRow(d.cols, keys, values)
+	}
+	d.rows++
+	return d.w.Row(values)
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// alignRow reorders a row whose keys differ from the first row's.
+func alignRow(cols, keys []string, values []any) []any {
+	byKey := make(map[string]any, len(keys))
+	for i, k := range keys {
+		if _, seen := byKey[k]; !seen {
+			byKey[k] = values[i]
+		}
+	}
+	out := make([]any, len(cols))
+	for i, c := range cols {
+		out[i] = byKey[c]
+	}
+	return out
+}
+
+// Text renders a value as plain text; NULL is the empty string and nested
+// JSON is compacted.
+func Text(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool, float64:
+		return fmt.Sprint(t)
+	default:
+		return string(marshal(t))
+	}
+}
+
+// marshal encodes v as JSON without escaping HTML characters.
+func marshal(v any) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return []byte("null")
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// object encodes a row as a JSON object with keys in column order.
+func object(cols []string, values []any) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, c := range cols {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(marshal(c))
+		buf.WriteByte(':')
+		buf.Write(marshal(values[i]))
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// NewWriter returns a streaming writer for csv, tsv, ndjson or markdown.
+func NewWriter(format string, out io.Writer) (Writer, error) {
+	switch format {
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(out)}, nil
+	case "tsv":
+		return &tsvWriter{w: bufio.NewWriter(out)}, nil
+	case "ndjson":
+		return &ndjsonWriter{w: bufio.NewWriter(out)}, nil
+	case "markdown":
+		return &markdownWriter{w: bufio.NewWriter(out)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (want %s)", format, strings.Join(Formats, ", "))
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Columns(cols []string) error { return c.w.Write(cols) }
+
+func (c *csvWriter) Row(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = Text(v)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// tsvWriter writes tab-separated values, escaping tabs, newlines and
+// backslashes inside fields as \t, \n, \r and \\.
+type tsvWriter struct {
+	w *bufio.Writer
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func (t *tsvWriter) line(fields []string) error {
+	for i, f := range fields {
+		if i > 0 {
+			t.w.WriteByte('\t')
+		}
+		tsvEscaper.WriteString(t.w, f)
+	}
+	return t.w.WriteByte('\n')
+}
+
+func (t *tsvWriter) Columns(cols []string) error { return t.line(cols) }
+
+func (t *tsvWriter) Row(values []any) error {
+	fields := make([]string, len(values))
+	for i, v := range values {
+		fields[i] = Text(v)
+	}
+	return t.line(fields)
+}
+
+func (t *tsvWriter) Close() error { return t.w.Flush() }
+
+type ndjsonWriter struct {
+	w    *bufio.Writer
+	cols []string
+}
+
+func (n *ndjsonWriter) Columns(cols []string) error {
+	n.cols = cols
+	return nil
+}
+
+func (n *ndjsonWriter) Row(values []any) error {
+	n.w.Write(object(n.cols, values))
+	return n.w.WriteByte('\n')
+}
+
+func (n *ndjsonWriter) Close() error { return n.w.Flush() }
+
+// markdownWriter writes a GitHub-flavored Markdown table. NULL renders as
+// an empty cell.
+type markdownWriter struct {
+	w *bufio.Writer
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+func (m *markdownWriter) line(cells []string) error {
+	m.w.WriteString("|")
+	for _, c := range cells {
+		m.w.WriteString(" ")
+		markdownEscaper.WriteString(m.w, c)
+		m.w.WriteString(" |")
+	}
+	return m.w.WriteByte('\n')
+}
+
+func (m *markdownWriter) Columns(cols []string) error {
+	if err := m.line(cols); err != nil {
+		return err
+	}
+	rule := make([]string, len(cols))
+	for i := range rule {
+		rule[i] = "---"
+	}
+	return m.line(rule)
+}
+
+func (m *markdownWriter) Row(values []any) error {
+	cells := make([]string, len(values))
+	for i, v := range values {
+		cells[i] = Text(v)
+	}
+	return m.line(cells)
+}
+
+func (m *markdownWriter) Close() error { return m.w.Flush() }
+
+// NewJSONWriter returns a writer for gw's JSON envelope,
+//
+//	{"database": "…", "columns": [...], "rows": [{…}, …]}
+//
+// streamed row by row, with each row's keys in column order.
+func NewJSONWriter(out io.Writer, database string) Writer {
+	return &jsonWriter{w: bufio.NewWriter(out), database: database}
+}
+
+type jsonWriter struct {
+	w        *bufio.Writer
+	database string
+	cols     []string
+	rows     int
+}
+
+func (j *jsonWriter) Columns(cols []string) error {
+	j.cols = cols
+	j.w.WriteString(`{"database":`)
+	j.w.Write(marshal(j.database))
+	j.w.WriteString(`,"columns":`)
+	j.w.Write(marshal(cols))
+	_, err := j.w.WriteString(`,"rows":[`)
+	return err
+}
+
+func (j *jsonWriter) Row(values []any) error {
+	if j.rows > 0 {
+		j.w.WriteByte(',')
+	}
+	j.rows++
+	_, err := j.w.Write(object(j.cols, values))
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	if j.cols == nil {
+		j.Columns([]string{})
+	}
+	j.w.WriteString("]}\n")
+	return j.w.Flush()
+}
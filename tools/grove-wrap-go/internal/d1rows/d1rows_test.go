@@ -0,0 +1,134 @@
+package d1rows
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlitefile"
+)
+
+const wranglerOutput = `[
+  {
+    "results": [
+      {"zeta": 1, "alpha": "a|b", "id": 9007199254740993, "note": null},
+      {"zeta": 2, "alpha": "line\nbreak", "id": 2, "note": {"k": [1, 2]}}
+    ],
+    "success": true,
+    "meta": {"duration": 0.2}
+  }
+]`
+
+func render(t *testing.T, format string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := Decode(strings.NewReader(wranglerOutput), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Decode() = %d rows, want 2", n)
+	}
+	return buf.String()
+}
+
+func TestDecodeKeepsColumnOrder(t *testing.T) {
+	// Run several times: map iteration would shuffle the columns
+	for i := 0; i < 5; i++ {
+		got := render(t, "csv")
+		if !strings.HasPrefix(got, "zeta,alpha,id,note\n") {
+			t.Fatalf("csv header = %q", strings.SplitN(got, "\n", 2)[0])
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", "zeta,alpha,id,note\n1,a|b,9007199254740993,\n2,\"line\nbreak\",2,\"{\"\"k\"\":[1,2]}\"\n"},
+		{"tsv", "zeta\talpha\tid\tnote\n1\ta|b\t9007199254740993\t\n2\tline\\nbreak\t2\t{\"k\":[1,2]}\n"},
+		{"ndjson", `{"zeta":1,"alpha":"a|b","id":9007199254740993,"note":null}` + "\n" +
+			`{"zeta":2,"alpha":"line\nbreak","id":2,"note":{"k":[1,2]}}` + "\n"},
+		{"markdown", "| zeta | alpha | id | note |\n| --- | --- | --- | --- |\n" +
+			"| 1 | a\\|b | 9007199254740993 |  |\n| 2 | line<br>break | 2 | {\"k\":[1,2]} |\n"},
+	}
+	for _, tt := range tests {
+		if got := render(t, tt.format); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.format, got, tt.want)
+		}
+	}
+	if _, err := NewWriter("xml", &bytes.Buffer{}); err == nil {
+		t.Error("unknown format should be an error")
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONWriter(&buf, "grove-engine-db")
+	if _, err := Decode(strings.NewReader(wranglerOutput), w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	want := `{"database":"grove-engine-db","columns":["zeta","alpha","id","note"],"rows":[` +
+		`{"zeta":1,"alpha":"a|b","id":9007199254740993,"note":null},` +
+		`{"zeta":2,"alpha":"line\nbreak","id":2,"note":{"k":[1,2]}}]}` + "\n"
+	if buf.String() != want {
+		t.Errorf("json:\n got %s\nwant %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	w = NewJSONWriter(&buf, "db")
+	Decode(strings.NewReader(`[{"results": [], "success": true}]`), w)
+	w.Close()
+	if buf.String() != `{"database":"db","columns":[],"rows":[]}`+"\n" {
+		t.Errorf("empty json = %s", buf.String())
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	w, _ := NewWriter("csv", &bytes.Buffer{})
+	if _, err := Decode(strings.NewReader(`✘ [ERROR] no such table: nope`), w); err == nil {
+		t.Error("non-JSON output should be an error")
+	}
+	if n, err := Decode(strings.NewReader(""), w); n != 0 || err != nil {
+		t.Errorf("empty output = %d, %v", n, err)
+	}
+}
+
+func TestSQLiteWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.db")
+	w, err := NewSQLiteWriter(path, "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(strings.NewReader(wranglerOutput), w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := sqlitefile.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var got [][]any
+	r.Scan("posts", func(_ int64, v []any) error {
+		got = append(got, v)
+		return nil
+	})
+	if len(got) != 2 || got[0][2] != int64(9007199254740993) || got[1][3] != `{"k":[1,2]}` {
+		t.Errorf("rows = %v", got)
+	}
+}
@@ -0,0 +1,32 @@
+package d1rows
+
+import (
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlitefile"
+)
+
+// NewSQLiteWriter returns a writer that builds an SQLite database at path
+// holding the rows as table. path must not exist or be empty.
+func NewSQLiteWriter(path, table string) (Writer, error) {
+	db, err := sqlitefile.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteWriter{db: db, table: table}, nil
+}
+
+type sqliteWriter struct {
+	db    *sqlitefile.Writer
+	table string
+	t     *sqlitefile.Table
+}
+
+func (s *sqliteWriter) Columns(cols []string) error {
+	var err error
+	s.t, err = s.db.CreateTable(s.table, cols)
+	return err
+}
+
+func (s *sqliteWriter) Row(values []any) error { return s.t.Insert(values) }
+
+// Close writes the database; a result with no rows leaves it without tables.
+func (s *sqliteWriter) Close() error { return s.db.Close() }
@@ -0,0 +1,349 @@
+// Package d1schema compares D1 database schemas and writes the SQL that
+// turns one into the other.
+//
+// A schema is read from sqlite_master: every table, index, trigger and view
+// with the CREATE statement SQLite stored for it. Tables are compared
+// column by column using the column definitions in that statement, so a
+// changed default or a new NOT NULL shows up as a changed column rather
+// than a changed table. Everything else is compared by its normalized
+// CREATE statement, which ignores case, whitespace, comments, quoting and
+// IF NOT EXISTS.
+package d1schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlparse"
+)
+
+// Query selects the sqlite_master rows a Schema is built from.
+const Query = "SELECT type, name, tbl_name, sql FROM sqlite_master " +
+	"WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' AND name NOT LIKE '_cf_%' " +
+	"AND name NOT IN ('d1_migrations', 'gw_migration_checksums') ORDER BY type, name"
+
+// Object is one schema object.
+type Object struct {
+	Type  string `json:"type"` // table, index, trigger, view
+	Name  string `json:"name"`
+	Table string `json:"table"` // owning table; the object itself for tables
+	SQL   string `json:"sql"`
+}
+
+// Schema is the set of objects in a database, keyed by type and lower-cased
+// name.
+type Schema struct {
+	Objects map[string]*Object
+}
+
+// key identifies an object independently of identifier case.
+func key(typ, name string) string {
+	return typ + ":" + strings.ToLower(name)
+}
+
+// shadowSuffixes name the tables FTS virtual tables create for themselves.
+var shadowSuffixes = []string{"_data", "_idx", "_content", "_docsize", "_config", "_segments", "_segdir", "_stat"}
+
+// FromRows builds a Schema from the result of Query.
+func FromRows(rows []map[string]interface{}) *Schema {
+	s := &Schema{Objects: map[string]*Object{}}
+	for _, row := range rows {
+		o := &Object{}
+		o.Type, _ = row["type"].(string)
+		o.Name, _ = row["name"].(string)
+		o.Table, _ = row["tbl_name"].(string)
+		o.SQL, _ = row["sql"].(string)
+		if o.Name == "" || o.SQL == "" || skipObject(o.Name) {
+			continue
+		}
+		s.Objects[key(o.Type, o.Name)] = o
+	}
+
+	// Shadow tables follow their virtual table; diffing them separately
+	// would only repeat its change
+	for _, o := range s.Objects {
+		if o.Type != "table" || !isVirtual(o.SQL) {
+			continue
+		}
+		for _, suffix := range shadowSuffixes {
+			delete(s.Objects, key("table", o.Name+suffix))
+		}
+	}
+	return s
+}
+
+// skipObject reports whether an object belongs to SQLite, D1 or the
+// migration bookkeeping of wrangler and gw rather than the application.
+func skipObject(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "sqlite_") || strings.HasPrefix(lower, "_cf_") ||
+		lower == "d1_migrations" || lower == "gw_migration_checksums"
+}
+
+// Len returns the number of objects of type typ, or of all types for "".
+func (s *Schema) Len(typ string) int {
+	n := 0
+	for _, o := range s.Objects {
+		if typ == "" || o.Type == typ {
+			n++
+		}
+	}
+	return n
+}
+
+// sorted returns the objects of type typ ordered by name.
+func (s *Schema) sorted(typ string) []*Object {
+	var out []*Object
+	for _, o := range s.Objects {
+		if o.Type == typ {
+			out = append(out, o)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name) })
+	return out
+}
+
+// Normalize renders a statement in a canonical form: comments dropped,
+// words and identifiers lower-cased and unquoted, single spaces between
+// tokens, and IF NOT EXISTS removed. Unparseable SQL is only trimmed.
+func Normalize(sql string) string {
+	toks, err := sqlparse.Tokenize(sql)
+	if err != nil {
+		return strings.Join(strings.Fields(sql), " ")
+	}
+	var parts []string
+	for _, t := range toks {
+		switch t.Kind {
+		case sqlparse.TokComment, sqlparse.TokSemicolon:
+			continue
+		case sqlparse.TokWord, sqlparse.TokQuotedIdent:
+			parts = append(parts, t.Ident())
+		default:
+			parts = append(parts, t.Text)
+		}
+	}
+	out := strings.Join(parts, " ")
+	for _, kind := range []string{"table", "index", "unique index", "trigger", "view", "temp trigger", "virtual table"} {
+		prefix := "create " + kind + " if not exists "
+		if strings.HasPrefix(out, prefix) {
+			return "create " + kind + " " + strings.TrimPrefix(out, prefix)
+		}
+	}
+	return out
+}
+
+// isVirtual reports whether a CREATE statement makes a virtual table.
+func isVirtual(sql string) bool {
+	return strings.HasPrefix(Normalize(sql), "create virtual table ")
+}
+
+// tableDef is a CREATE TABLE statement split into its parts.
+type tableDef struct {
+	bodyPos     int               // offset of the "(" that opens the definition
+	columns     []string          // lower-cased column names in order
+	names       map[string]string // lower-cased name → name as written
+	defs        map[string]string // lower-cased name → column definition
+	constraints []string          // table constraints and options, normalized
+}
+
+// tableConstraintWords start a table constraint rather than a column.
+var tableConstraintWords = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "UNIQUE": true, "CHECK": true, "FOREIGN": true,
+}
+
+// parseTable splits a CREATE TABLE statement into columns and constraints.
+func parseTable(sql string) (*tableDef, error) {
+	toks, err := sqlparse.Tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	def := &tableDef{bodyPos: -1, names: map[string]string{}, defs: map[string]string{}}
+
+	depth := 0
+	var element []sqlparse.Token
+	flush := func() {
+		if len(element) == 0 {
+			return
+		}
+		text := sql[element[0].Pos:element[len(element)-1].End()]
+		first := element[0]
+		if tableConstraintWords[first.Upper()] {
+			def.constraints = append(def.constraints, Normalize(text))
+		} else {
+			name := first.Ident()
+			def.columns = append(def.columns, name)
+			def.names[name] = strings.Trim(first.Text, "\"`[]")
+			def.defs[name] = text
+		}
+		element = nil
+	}
+
+	for i, t := range toks {
+		if t.Kind == sqlparse.TokComment {
+			continue
+		}
+		if t.Kind == sqlparse.TokOp && t.Text == "(" {
+			depth++
+			if depth == 1 {
+				if def.bodyPos < 0 {
+					def.bodyPos = t.Pos
+				}
+				continue
+			}
+		}
+		if t.Kind == sqlparse.TokOp && t.Text == ")" {
+			depth--
+			if depth == 0 {
+				flush()
+				// Table options after the body: WITHOUT ROWID, STRICT
+				for _, rest := range toks[i+1:] {
+					if rest.Kind == sqlparse.TokWord {
+						def.constraints = append(def.constraints, "option "+strings.ToLower(rest.Text))
+					}
+				}
+				break
+			}
+		}
+		if depth == 1 && t.Kind == sqlparse.TokOp && t.Text == "," {
+			flush()
+			continue
+		}
+		if depth >= 1 {
+			element = append(element, t)
+		}
+	}
+	if def.bodyPos < 0 || len(def.columns) == 0 {
+		return nil, fmt.Errorf("cannot parse table definition")
+	}
+	return def, nil
+}
+
+// Columns returns the column names of a CREATE TABLE statement as written,
+// in order, or nil when it cannot be parsed.
+func Columns(sql string) []string {
+	def, err := parseTable(sql)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(def.columns))
+	for i, c := range def.columns {
+		names[i] = def.names[c]
+	}
+	return names
+}
+
+// Change is one difference between two schemas.
+type Change struct {
+	Op    string `json:"op"`   // add, drop, change
+	Kind  string `json:"kind"` // table, column, constraint, index, trigger, view
+	Table string `json:"table,omitempty"`
+	Name  string `json:"name"`
+	From  string `json:"from,omitempty"` // definition in the first schema
+	To    string `json:"to,omitempty"`   // definition in the second schema
+	Note  string `json:"note,omitempty"`
+}
+
+// noteColumnOrder marks a table whose only difference is column order.
+// Reconcile leaves it alone: order matters only to SELECT *.
+const noteColumnOrder = "column order differs"
+
+// kindOrder sorts a table's changes so the table comes before what hangs
+// off it.
+var kindOrder = map[string]int{"table": 0, "column": 1, "constraint": 2, "index": 3, "view": 4, "trigger": 5}
+
+// Diff lists what changes going from one schema to the other.
+func Diff(from, to *Schema) []Change {
+	var changes []Change
+
+	keys := map[string]bool{}
+	for k := range from.Objects {
+		keys[k] = true
+	}
+	for k := range to.Objects {
+		keys[k] = true
+	}
+
+	for k := range keys {
+		a, b := from.Objects[k], to.Objects[k]
+		switch {
+		case a == nil:
+			changes = append(changes, Change{Op: "add", Kind: b.Type, Table: b.Table, Name: b.Name, To: b.SQL})
+		case b == nil:
+			changes = append(changes, Change{Op: "drop", Kind: a.Type, Table: a.Table, Name: a.Name, From: a.SQL})
+		case Normalize(a.SQL) == Normalize(b.SQL):
+			// identical
+		case a.Type == "table":
+			changes = append(changes, diffTable(a, b)...)
+		default:
+			changes = append(changes, Change{Op: "change", Kind: a.Type, Table: b.Table, Name: b.Name, From: a.SQL, To: b.SQL})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		ci, cj := changes[i], changes[j]
+		if ti, tj := strings.ToLower(ci.Table), strings.ToLower(cj.Table); ti != tj {
+			return ti < tj
+		}
+		if kindOrder[ci.Kind] != kindOrder[cj.Kind] {
+			return kindOrder[ci.Kind] < kindOrder[cj.Kind]
+		}
+		return strings.ToLower(ci.Name) < strings.ToLower(cj.Name)
+	})
+	return changes
+}
+
+// diffTable compares two definitions of the same table column by column.
+func diffTable(a, b *Object) []Change {
+	whole := []Change{{Op: "change", Kind: "table", Table: b.Name, Name: b.Name, From: a.SQL, To: b.SQL,
+		Note: "definition changed"}}
+	if isVirtual(a.SQL) || isVirtual(b.SQL) {
+		return whole
+	}
+	ta, errA := parseTable(a.SQL)
+	tb, errB := parseTable(b.SQL)
+	if errA != nil || errB != nil {
+		return whole
+	}
+
+	var changes []Change
+	for _, col := range tb.columns {
+		defA, ok := ta.defs[col]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Op: "add", Kind: "column", Table: b.Name, Name: tb.names[col], To: tb.defs[col]})
+		case Normalize(defA) != Normalize(tb.defs[col]):
+			changes = append(changes, Change{Op: "change", Kind: "column", Table: b.Name, Name: tb.names[col], From: defA, To: tb.defs[col]})
+		}
+	}
+	for _, col := range ta.columns {
+		if _, ok := tb.defs[col]; !ok {
+			changes = append(changes, Change{Op: "drop", Kind: "column", Table: b.Name, Name: ta.names[col], From: ta.defs[col]})
+		}
+	}
+
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, c := range ta.constraints {
+		inA[c] = true
+	}
+	for _, c := range tb.constraints {
+		inB[c] = true
+	}
+	for _, c := range tb.constraints {
+		if !inA[c] {
+			changes = append(changes, Change{Op: "add", Kind: "constraint", Table: b.Name, Name: c, To: c})
+		}
+	}
+	for _, c := range ta.constraints {
+		if !inB[c] {
+			changes = append(changes, Change{Op: "drop", Kind: "constraint", Table: b.Name, Name: c, From: c})
+		}
+	}
+
+	if len(changes) == 0 {
+		// Same columns and constraints in a different order
+		whole[0].Note = noteColumnOrder
+		return whole
+	}
+	return changes
+}
@@ -0,0 +1,199 @@
+package d1schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func schemaOf(objects ...[4]string) *Schema {
+	var rows []map[string]interface{}
+	for _, o := range objects {
+		rows = append(rows, map[string]interface{}{"type": o[0], "name": o[1], "tbl_name": o[2], "sql": o[3]})
+	}
+	return FromRows(rows)
+}
+
+func TestNormalize(t *testing.T) {
+	a := "CREATE TABLE IF NOT EXISTS \"Posts\" (\n  id INTEGER PRIMARY KEY, -- row id\n  title TEXT\n)"
+	b := "create table posts (id integer primary key, title text)"
+	if Normalize(a) != Normalize(b) {
+		t.Errorf("Normalize differs:\n%q\n%q", Normalize(a), Normalize(b))
+	}
+	if Normalize("CREATE INDEX i ON t(a)") == Normalize("CREATE INDEX i ON t(b)") {
+		t.Error("different columns should not normalize equal")
+	}
+	if Normalize("SELECT 'A'") == Normalize("SELECT 'a'") {
+		t.Error("string literals keep their case")
+	}
+}
+
+func TestFromRowsSkipsInternalObjects(t *testing.T) {
+	s := schemaOf(
+		[4]string{"table", "posts", "posts", "CREATE TABLE posts (id INTEGER)"},
+		[4]string{"table", "_cf_KV", "_cf_KV", "CREATE TABLE _cf_KV (k, v)"},
+		[4]string{"table", "d1_migrations", "d1_migrations", "CREATE TABLE d1_migrations (id)"},
+		[4]string{"table", "sqlite_sequence", "sqlite_sequence", "CREATE TABLE sqlite_sequence(name,seq)"},
+		[4]string{"table", "posts_fts", "posts_fts", "CREATE VIRTUAL TABLE posts_fts USING fts5(title)"},
+		[4]string{"table", "posts_fts_data", "posts_fts_data", "CREATE TABLE 'posts_fts_data'(id INTEGER PRIMARY KEY, block BLOB)"},
+	)
+	if got := s.Len("table"); got != 2 {
+		t.Errorf("Len(table) = %d, want 2 (posts, posts_fts)", got)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	sql := "CREATE TABLE \"Posts\" (\n  id INTEGER PRIMARY KEY,\n  \"Title\" TEXT DEFAULT (lower('x')),\n  [body] TEXT,\n  UNIQUE (id, body)\n)"
+	if got := strings.Join(Columns(sql), ","); got != "id,Title,body" {
+		t.Errorf("Columns = %s", got)
+	}
+	if Columns("CREATE VIEW v AS SELECT 1") != nil {
+		t.Error("a view has no column definitions")
+	}
+}
+
+func TestDiffColumns(t *testing.T) {
+	from := schemaOf(
+		[4]string{"table", "posts", "posts", "CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT, legacy TEXT)"},
+		[4]string{"index", "idx_title", "posts", "CREATE INDEX idx_title ON posts(title)"},
+	)
+	to := schemaOf(
+		[4]string{"table", "posts", "posts", "CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT NOT NULL, slug TEXT)"},
+		[4]string{"index", "idx_title", "posts", "CREATE INDEX idx_title ON posts(title, id)"},
+		[4]string{"table", "tags", "tags", "CREATE TABLE tags (name TEXT)"},
+	)
+
+	got := map[string]string{}
+	for _, c := range Diff(from, to) {
+		got[c.Kind+" "+c.Name] = c.Op
+	}
+	want := map[string]string{
+		"column title":    "change",
+		"column slug":     "add",
+		"column legacy":   "drop",
+		"index idx_title": "change",
+		"table tags":      "add",
+	}
+	if len(got) != len(want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+	for k, op := range want {
+		if got[k] != op {
+			t.Errorf("Diff()[%s] = %q, want %q", k, got[k], op)
+		}
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	a := schemaOf([4]string{"table", "posts", "posts", "CREATE TABLE posts (id INTEGER, title TEXT)"})
+	b := schemaOf([4]string{"table", "Posts", "Posts", "CREATE TABLE IF NOT EXISTS \"posts\" (\n\tid INTEGER,\n\ttitle TEXT\n)"})
+	if changes := Diff(a, b); len(changes) != 0 {
+		t.Errorf("Diff() = %+v, want none", changes)
+	}
+}
+
+func TestDiffColumnOrderOnly(t *testing.T) {
+	a := schemaOf([4]string{"table", "t", "t", "CREATE TABLE t (a INTEGER, b TEXT)"})
+	b := schemaOf([4]string{"table", "t", "t", "CREATE TABLE t (b TEXT, a INTEGER)"})
+	changes := Diff(a, b)
+	if len(changes) != 1 || changes[0].Note != noteColumnOrder {
+		t.Fatalf("Diff() = %+v, want one column-order change", changes)
+	}
+	if sql := Reconcile(a, b, changes); sql != "" {
+		t.Errorf("Reconcile() = %q, want nothing for column order", sql)
+	}
+}
+
+func TestReconcileAlterTable(t *testing.T) {
+	from := schemaOf([4]string{"table", "posts", "posts", "CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT, legacy TEXT)"})
+	to := schemaOf([4]string{"table", "posts", "posts", "CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT, slug TEXT DEFAULT '')"})
+
+	sql := Reconcile(from, to, Diff(from, to))
+	for _, want := range []string{
+		`ALTER TABLE "posts" ADD COLUMN slug TEXT DEFAULT '';`,
+		`ALTER TABLE "posts" DROP COLUMN "legacy";`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Reconcile() missing %q in:\n%s", want, sql)
+		}
+	}
+	if strings.Contains(sql, rebuildPrefix) {
+		t.Errorf("simple add/drop should not rebuild:\n%s", sql)
+	}
+}
+
+func TestReconcileRebuild(t *testing.T) {
+	from := schemaOf(
+		[4]string{"table", "posts", "posts", "CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT)"},
+		[4]string{"index", "idx_title", "posts", "CREATE INDEX idx_title ON posts(title)"},
+	)
+	to := schemaOf(
+		[4]string{"table", "posts", "posts", "CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT NOT NULL, created_at TEXT DEFAULT CURRENT_TIMESTAMP)"},
+		[4]string{"index", "idx_title", "posts", "CREATE INDEX idx_title ON posts(title)"},
+	)
+
+	sql := Reconcile(from, to, Diff(from, to))
+	want := []string{
+		"PRAGMA defer_foreign_keys = on;",
+		`CREATE TABLE "_gw_new_posts" (id INTEGER PRIMARY KEY, title TEXT NOT NULL, created_at TEXT DEFAULT CURRENT_TIMESTAMP);`,
+		`INSERT INTO "_gw_new_posts" ("id", "title") SELECT "id", "title" FROM "posts";`,
+		`DROP TABLE "posts";`,
+		`ALTER TABLE "_gw_new_posts" RENAME TO "posts";`,
+		"CREATE INDEX idx_title ON posts(title);",
+	}
+	last := -1
+	for _, w := range want {
+		i := strings.Index(sql, w)
+		if i < 0 {
+			t.Fatalf("Reconcile() missing %q in:\n%s", w, sql)
+		}
+		if i < last {
+			t.Errorf("%q is out of order in:\n%s", w, sql)
+		}
+		last = i
+	}
+}
+
+func TestReconcileObjects(t *testing.T) {
+	from := schemaOf(
+		[4]string{"table", "t", "t", "CREATE TABLE t (a INTEGER)"},
+		[4]string{"table", "old", "old", "CREATE TABLE old (a INTEGER)"},
+		[4]string{"view", "v", "v", "CREATE VIEW v AS SELECT a FROM t"},
+	)
+	to := schemaOf(
+		[4]string{"table", "t", "t", "CREATE TABLE t (a INTEGER)"},
+		[4]string{"view", "v", "v", "CREATE VIEW v AS SELECT a + 1 AS a FROM t"},
+		[4]string{"trigger", "tr", "t", "CREATE TRIGGER tr AFTER INSERT ON t BEGIN SELECT 1; END"},
+	)
+	sql := Reconcile(from, to, Diff(from, to))
+	for _, want := range []string{
+		`DROP VIEW IF EXISTS "v";`,
+		`DROP TABLE IF EXISTS "old";`,
+		"CREATE VIEW v AS SELECT a + 1 AS a FROM t;",
+		"CREATE TRIGGER tr AFTER INSERT ON t BEGIN SELECT 1; END;",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Reconcile() missing %q in:\n%s", want, sql)
+		}
+	}
+}
+
+func TestCanAddColumn(t *testing.T) {
+	tests := []struct {
+		def  string
+		want bool
+	}{
+		{"slug TEXT", true},
+		{"n INTEGER NOT NULL DEFAULT 0", true},
+		{"n INTEGER NOT NULL", false},
+		{"id INTEGER PRIMARY KEY", false},
+		{"email TEXT UNIQUE", false},
+		{"at TEXT DEFAULT CURRENT_TIMESTAMP", false},
+		{"at TEXT DEFAULT (datetime('now'))", false},
+		{"author_id INTEGER REFERENCES users(id)", true},
+	}
+	for _, tt := range tests {
+		if got := canAddColumn(tt.def); got != tt.want {
+			t.Errorf("canAddColumn(%q) = %v, want %v", tt.def, got, tt.want)
+		}
+	}
+}
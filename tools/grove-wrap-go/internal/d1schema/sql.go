@@ -0,0 +1,201 @@
+package d1schema
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlparse"
+)
+
+// quoteIdent double-quotes an identifier for SQLite.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// terminate ends a statement with exactly one semicolon.
+func terminate(sql string) string {
+	return strings.TrimRight(strings.TrimSpace(sql), ";") + ";"
+}
+
+// rebuildPrefix names the temporary table a rebuild copies rows into.
+const rebuildPrefix = "_gw_new_"
+
+// nonConstantDefault matches defaults ALTER TABLE ADD COLUMN refuses.
+var nonConstantDefault = regexp.MustCompile(`(?i)\bdefault\s*(\(|current_time|current_date|current_timestamp)`)
+
+// canAddColumn reports whether SQLite's ALTER TABLE ADD COLUMN accepts def:
+// no PRIMARY KEY or UNIQUE, no NOT NULL without a default, no expression
+// or CURRENT_* default, and no stored generated column.
+func canAddColumn(def string) bool {
+	n := Normalize(def)
+	if strings.Contains(n, " primary key") || strings.Contains(n, " unique") || strings.Contains(n, " stored") {
+		return false
+	}
+	if strings.Contains(n, " not null") && !strings.Contains(n, " default ") {
+		return false
+	}
+	return !nonConstantDefault.MatchString(def)
+}
+
+// canDropColumn reports whether SQLite's ALTER TABLE DROP COLUMN accepts
+// dropping col from table: it must not be a key, unique, or used by an
+// index, trigger or view in the schema being changed.
+func canDropColumn(s *Schema, table, col, def string) bool {
+	n := Normalize(def)
+	if strings.Contains(n, " primary key") || strings.Contains(n, " unique") || strings.Contains(n, " references ") {
+		return false
+	}
+	for _, o := range s.Objects {
+		switch {
+		case o.Type == "table":
+			continue
+		case o.Type == "index" && !strings.EqualFold(o.Table, table):
+			continue
+		}
+		if mentions(o.SQL, col) {
+			return false
+		}
+	}
+	return true
+}
+
+// mentions reports whether sql refers to an identifier named ident.
+func mentions(sql, ident string) bool {
+	toks, err := sqlparse.Tokenize(sql)
+	if err != nil {
+		return true
+	}
+	ident = strings.ToLower(ident)
+	for _, t := range toks {
+		if (t.Kind == sqlparse.TokWord || t.Kind == sqlparse.TokQuotedIdent) && t.Ident() == ident {
+			return true
+		}
+	}
+	return false
+}
+
+// Reconcile returns the SQL that turns from into to, given their Diff.
+//
+// Columns are added and dropped with ALTER TABLE where SQLite allows it;
+// any other change to a table rebuilds it with SQLite's recommended
+// sequence: create the new definition under a temporary name, copy the
+// shared columns, drop the old table and rename. The rebuilt table's
+// indexes and triggers are then recreated. Review the output before
+// applying it: rebuilds copy data, and dropped objects lose theirs.
+func Reconcile(from, to *Schema, changes []Change) string {
+	var (
+		drops, creates, tables, alters []string
+		rebuild                        = map[string]bool{}
+		recreate                       = map[string]bool{} // index/trigger keys to create
+	)
+
+	// First pass: which tables need a rebuild
+	for _, c := range changes {
+		if c.Kind == "table" && c.Op == "change" && c.Note != noteColumnOrder {
+			rebuild[strings.ToLower(c.Table)] = true
+		}
+		if c.Kind == "constraint" || (c.Kind == "column" && c.Op == "change") ||
+			(c.Kind == "column" && c.Op == "add" && !canAddColumn(c.To)) ||
+			(c.Kind == "column" && c.Op == "drop" && !canDropColumn(from, c.Table, c.Name, c.From)) {
+			rebuild[strings.ToLower(c.Table)] = true
+		}
+	}
+
+	for _, c := range changes {
+		k := key(c.Kind, c.Name)
+		switch c.Kind {
+		case "table":
+			switch c.Op {
+			case "add":
+				tables = append(tables, terminate(c.To))
+			case "drop":
+				tables = append(tables, "DROP TABLE IF EXISTS "+quoteIdent(c.Name)+";")
+			}
+		case "column":
+			if rebuild[strings.ToLower(c.Table)] {
+				continue
+			}
+			if c.Op == "add" {
+				alters = append(alters, "ALTER TABLE "+quoteIdent(c.Table)+" ADD COLUMN "+strings.TrimSpace(c.To)+";")
+			} else {
+				alters = append(alters, "ALTER TABLE "+quoteIdent(c.Table)+" DROP COLUMN "+quoteIdent(c.Name)+";")
+			}
+		case "index", "trigger", "view":
+			if c.Op != "add" {
+				drops = append(drops, "DROP "+strings.ToUpper(c.Kind)+" IF EXISTS "+quoteIdent(c.Name)+";")
+			}
+			if c.Op != "drop" {
+				recreate[k] = true
+			}
+		}
+	}
+
+	for _, t := range to.sorted("table") {
+		if !rebuild[strings.ToLower(t.Name)] {
+			continue
+		}
+		alters = append(alters, rebuildTable(from.Objects[key("table", t.Name)], t)...)
+		// DROP TABLE takes the table's indexes and triggers with it
+		for _, o := range to.Objects {
+			if (o.Type == "index" || o.Type == "trigger") && strings.EqualFold(o.Table, t.Name) {
+				recreate[key(o.Type, o.Name)] = true
+			}
+		}
+	}
+
+	for _, typ := range []string{"index", "view", "trigger"} {
+		for _, o := range to.sorted(typ) {
+			if recreate[key(typ, o.Name)] {
+				creates = append(creates, terminate(o.SQL))
+			}
+		}
+	}
+
+	var out []string
+	if len(rebuild) > 0 {
+		out = append(out, "PRAGMA defer_foreign_keys = on;")
+	}
+	out = append(out, drops...)
+	out = append(out, tables...)
+	out = append(out, alters...)
+	out = append(out, creates...)
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// rebuildTable recreates a table with a new definition, keeping the rows
+// of the columns both definitions share.
+func rebuildTable(old, updated *Object) []string {
+	def, err := parseTable(updated.SQL)
+	if err != nil || isVirtual(updated.SQL) {
+		// Virtual or unparseable: replace outright
+		return []string{
+			"-- " + updated.Name + " is recreated empty; repopulate it after applying",
+			"DROP TABLE IF EXISTS " + quoteIdent(updated.Name) + ";",
+			terminate(updated.SQL),
+		}
+	}
+	tmp := quoteIdent(rebuildPrefix + updated.Name)
+	stmts := []string{"CREATE TABLE " + tmp + " " + terminate(updated.SQL[def.bodyPos:])}
+
+	if old != nil {
+		if oldDef, err := parseTable(old.SQL); err == nil {
+			var shared []string
+			for _, col := range def.columns {
+				if _, ok := oldDef.defs[col]; ok {
+					shared = append(shared, quoteIdent(def.names[col]))
+				}
+			}
+			if len(shared) > 0 {
+				cols := strings.Join(shared, ", ")
+				stmts = append(stmts, "INSERT INTO "+tmp+" ("+cols+") SELECT "+cols+" FROM "+quoteIdent(old.Name)+";")
+			}
+		}
+	}
+	return append(stmts,
+		"DROP TABLE "+quoteIdent(updated.Name)+";",
+		"ALTER TABLE "+tmp+" RENAME TO "+quoteIdent(updated.Name)+";",
+	)
+}
@@ -0,0 +1,310 @@
+// Package d1shell is the editing logic behind gw d1 shell: when a buffer
+// holds a statement ready to run, tab completion against the database's
+// tables and columns, and the per-database history file.
+package d1shell
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlparse"
+)
+
+// MetaCommands are the built-in dot commands, for completion.
+var MetaCommands = []string{".exit", ".explain", ".export", ".help", ".quit", ".schema", ".tables"}
+
+// Ready reports whether buf should run on enter: a dot command, or SQL
+// whose last token outside comments and quotes is a semicolon.
+func Ready(buf string) bool {
+	trimmed := strings.TrimSpace(buf)
+	if trimmed == "" {
+		return false
+	}
+	if strings.HasPrefix(trimmed, ".") {
+		return true
+	}
+	toks, err := sqlparse.Tokenize(trimmed)
+	if err != nil {
+		return false // an unterminated quote or comment continues
+	}
+	for i := len(toks) - 1; i >= 0; i-- {
+		if toks[i].Kind == sqlparse.TokComment {
+			continue
+		}
+		return toks[i].Kind == sqlparse.TokSemicolon
+	}
+	return false
+}
+
+// Catalog is what completion knows about a database.
+type Catalog struct {
+	Tables  []string
+	columns map[string][]string // lower-cased table → columns
+}
+
+// NewCatalog builds a catalog from table names and their columns.
+func NewCatalog(columns map[string][]string) *Catalog {
+	c := &Catalog{columns: map[string][]string{}}
+	for table, cols := range columns {
+		c.Tables = append(c.Tables, table)
+		c.columns[strings.ToLower(table)] = cols
+	}
+	sort.Strings(c.Tables)
+	return c
+}
+
+// Columns returns a table's columns.
+func (c *Catalog) Columns(table string) []string {
+	return c.columns[strings.ToLower(table)]
+}
+
+// keywords are offered alongside names when they match.
+var keywords = []string{
+	"SELECT", "FROM", "WHERE", "AND", "OR", "NOT", "NULL", "IS", "IN", "LIKE",
+	"GROUP BY", "ORDER BY", "HAVING", "LIMIT", "OFFSET", "JOIN", "LEFT JOIN",
+	"ON", "AS", "DISTINCT", "COUNT", "INSERT INTO", "VALUES", "UPDATE", "SET",
+	"DELETE FROM", "EXPLAIN QUERY PLAN", "BETWEEN", "CASE", "WHEN", "THEN",
+	"ELSE", "END", "DESC", "ASC", "EXISTS", "UNION",
+}
+
+// tableContext are the words after which only a table name makes sense.
+var tableContext = map[string]bool{
+	"FROM": true, "JOIN": true, "INTO": true, "UPDATE": true, "TABLE": true,
+	".SCHEMA": true,
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Complete returns the candidates for the word ending at pos in input and
+// the offset where that word starts. "alias.col" completes the columns of
+// the table the alias names; after FROM, JOIN, INTO or UPDATE only tables
+// are offered; elsewhere tables, the columns of tables the statement
+// mentions and keywords. An empty word completes only where a table is
+// expected.
+func (c *Catalog) Complete(input string, pos int) (int, []string) {
+	runes := []rune(input)
+	if pos > len(runes) {
+		pos = len(runes)
+	}
+	start := pos
+	for start > 0 && isWordRune(runes[start-1]) {
+		start--
+	}
+	word := string(runes[start:pos])
+	before := strings.Fields(string(runes[:start]))
+	prev := ""
+	if len(before) > 0 {
+		prev = strings.ToUpper(before[len(before)-1])
+	}
+
+	// Dot commands at the start of the buffer
+	if strings.HasPrefix(word, ".") && len(before) == 0 {
+		return start, matching(MetaCommands, word)
+	}
+
+	if i := strings.LastIndex(word, "."); i >= 0 {
+		qualifier, col := word[:i], word[i+1:]
+		table := c.resolve(input, qualifier)
+		var out []string
+		for _, name := range matching(c.Columns(table), col) {
+			out = append(out, qualifier+"."+name)
+		}
+		return start, out
+	}
+
+	if tableContext[prev] {
+		return start, matching(c.Tables, word)
+	}
+	if word == "" {
+		return start, nil
+	}
+
+	seen := map[string]bool{}
+	var out []string
+	add := func(names []string) {
+		for _, n := range matching(names, word) {
+			if !seen[strings.ToLower(n)] {
+				seen[strings.ToLower(n)] = true
+				out = append(out, n)
+			}
+		}
+	}
+	add(c.Tables)
+	for _, t := range c.mentioned(input) {
+		add(c.Columns(t))
+	}
+	kw := keywords
+	if strings.ToLower(word) == word {
+		kw = make([]string, len(keywords))
+		for i, k := range keywords {
+			kw[i] = strings.ToLower(k)
+		}
+	}
+	add(kw)
+	sort.Strings(out)
+	return start, out
+}
+
+// mentioned lists the catalog tables named anywhere in input.
+func (c *Catalog) mentioned(input string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range tokens(input) {
+		name := t.Ident()
+		if _, ok := c.columns[name]; ok && !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// resolve maps a qualifier to a table: a table name, or an alias declared
+// as "table alias" or "table AS alias" in input.
+func (c *Catalog) resolve(input, qualifier string) string {
+	q := strings.ToLower(qualifier)
+	if _, ok := c.columns[q]; ok {
+		return q
+	}
+	toks := tokens(input)
+	for i, t := range toks {
+		if _, ok := c.columns[t.Ident()]; !ok {
+			continue
+		}
+		j := i + 1
+		if j < len(toks) && toks[j].Upper() == "AS" {
+			j++
+		}
+		if j < len(toks) && toks[j].Ident() == q {
+			return t.Ident()
+		}
+	}
+	return ""
+}
+
+// tokens tokenizes input, dropping an unterminated tail so a half-typed
+// statement still yields its complete tokens.
+func tokens(input string) []sqlparse.Token {
+	for len(input) > 0 {
+		toks, err := sqlparse.Tokenize(input)
+		if err == nil {
+			return toks
+		}
+		input = input[:len(input)-1]
+	}
+	return nil
+}
+
+// matching returns the names that start with prefix, ignoring case.
+func matching(names []string, prefix string) []string {
+	var out []string
+	lower := strings.ToLower(prefix)
+	for _, n := range names {
+		if strings.HasPrefix(strings.ToLower(n), lower) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// CommonPrefix returns the longest prefix, ignoring case, that every
+// candidate shares, spelled as in the first.
+func CommonPrefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	first := []rune(candidates[0])
+	n := len(first)
+	for _, c := range candidates[1:] {
+		r := []rune(c)
+		i := 0
+		for i < n && i < len(r) && unicode.ToLower(r[i]) == unicode.ToLower(first[i]) {
+			i++
+		}
+		n = i
+	}
+	return string(first[:n])
+}
+
+// maxHistory is how many entries a history file keeps.
+const maxHistory = 1000
+
+// History is the statements run against one database, oldest first.
+type History struct {
+	path    string
+	Entries []string
+}
+
+// HistoryPath returns ~/.grove/d1_history/<database>.jsonl, or "" without
+// a home directory.
+func HistoryPath(database string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".grove", "d1_history", database+".jsonl")
+}
+
+// LoadHistory reads a history file, one JSON string per line so entries
+// can span lines. A missing file is an empty history; unreadable lines
+// are skipped.
+func LoadHistory(path string) (*History, error) {
+	h := &History{path: path}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var entry string
+		if json.Unmarshal(sc.Bytes(), &entry) == nil && entry != "" {
+			h.Entries = append(h.Entries, entry)
+		}
+	}
+	return h, sc.Err()
+}
+
+// Add appends an entry unless it repeats the last one, keeping the newest
+// maxHistory.
+func (h *History) Add(entry string) {
+	if entry == "" || (len(h.Entries) > 0 && h.Entries[len(h.Entries)-1] == entry) {
+		return
+	}
+	h.Entries = append(h.Entries, entry)
+	if len(h.Entries) > maxHistory {
+		h.Entries = h.Entries[len(h.Entries)-maxHistory:]
+	}
+}
+
+// Save writes the history file atomically.
+func (h *History) Save() error {
+	if h.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, e := range h.Entries {
+		line, _ := json.Marshal(e)
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
@@ -0,0 +1,104 @@
+package d1shell
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReady(t *testing.T) {
+	tests := map[string]bool{
+		"":                               false,
+		"SELECT 1":                       false,
+		"SELECT 1;":                      true,
+		"SELECT 1; -- done":              true,
+		"SELECT ';":                      false,
+		"SELECT ';';":                    true,
+		"SELECT *\nFROM posts\n":         false,
+		"SELECT *\nFROM posts\nLIMIT 5;": true,
+		"  .tables":                      true,
+		"/* open comment;":               false,
+	}
+	for buf, want := range tests {
+		if got := Ready(buf); got != want {
+			t.Errorf("Ready(%q) = %v, want %v", buf, got, want)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	c := NewCatalog(map[string][]string{
+		"posts":    {"id", "title", "tenant_id"},
+		"tenants":  {"id", "subdomain"},
+		"sessions": {"id", "user_id"},
+	})
+	tests := []struct {
+		input string
+		start int
+		want  []string
+	}{
+		{"SELECT * FROM ", 14, []string{"posts", "sessions", "tenants"}},
+		{"SELECT * FROM te", 14, []string{"tenants"}},
+		{"SELECT * FROM posts p WHERE p.t", 28, []string{"p.title", "p.tenant_id"}},
+		{"SELECT posts.", 7, []string{"posts.id", "posts.title", "posts.tenant_id"}},
+		{"SELECT * FROM tenants t JOIN posts AS x ON x.te", 43, []string{"x.tenant_id"}},
+		{"SELECT t FROM posts", 7, []string{"tenant_id", "tenants", "then", "title"}},
+		{"SELECT ", 7, nil},
+		{"sel", 0, []string{"select"}},
+		{".sc", 0, []string{".schema"}},
+		{".schema p", 8, []string{"posts"}},
+	}
+	for _, tt := range tests {
+		pos := len([]rune(tt.input))
+		if tt.input == "SELECT t FROM posts" {
+			pos = 8
+		}
+		start, got := c.Complete(tt.input, pos)
+		if start != tt.start || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q, %d) = %d %v, want %d %v", tt.input, pos, start, got, tt.start, tt.want)
+		}
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	if got := CommonPrefix([]string{"tenant_id", "Tenants", "tenure"}); got != "ten" {
+		t.Errorf("CommonPrefix = %q", got)
+	}
+	if got := CommonPrefix(nil); got != "" {
+		t.Errorf("CommonPrefix(nil) = %q", got)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d1_history", "db.jsonl")
+	h, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Add("SELECT 1;")
+	h.Add("SELECT 1;")
+	h.Add("SELECT *\nFROM posts;")
+	for i := 0; i < maxHistory; i++ {
+		h.Add(strings.Repeat("x", i%2+1))
+	}
+	if err := h.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err = LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Entries) != maxHistory {
+		t.Fatalf("kept %d entries, want %d", len(h.Entries), maxHistory)
+	}
+	h, _ = LoadHistory(path)
+	h.Entries = nil
+	h.Add("SELECT *\nFROM posts;")
+	h.Save()
+	h, _ = LoadHistory(path)
+	if len(h.Entries) != 1 || h.Entries[0] != "SELECT *\nFROM posts;" {
+		t.Errorf("multi-line entry = %q", h.Entries)
+	}
+}
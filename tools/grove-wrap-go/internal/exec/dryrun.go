@@ -0,0 +1,408 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlparse"
+)
+
+// DryRunEnv is set in the environment of every child process while dry-run
+// is on, so nested gw invocations and plugins inherit the mode.
+const DryRunEnv = "GW_DRY_RUN"
+
+// Planned is a mutating invocation that dry-run mode skipped.
+type Planned struct {
+	Binary string   `json:"binary"`
+	Args   []string `json:"args"`
+	Dir    string   `json:"dir,omitempty"`
+	// StdinBytes is the length of the redacted stdin, 0 when none was piped.
+	StdinBytes int `json:"stdin_bytes,omitempty"`
+}
+
+// Argv returns the binary followed by its arguments.
+func (p Planned) Argv() []string {
+	return append([]string{p.Binary}, p.Args...)
+}
+
+// String renders the invocation as a shell-like line with stdin redacted.
+func (p Planned) String() string {
+	parts := make([]string, 0, len(p.Args)+1)
+	for _, a := range p.Argv() {
+		parts = append(parts, quoteArg(a))
+	}
+	line := strings.Join(parts, " ")
+	if p.StdinBytes > 0 {
+		line += fmt.Sprintf(" < [%d bytes redacted]", p.StdinBytes)
+	}
+	if p.Dir != "" {
+		line = "(cd " + quoteArg(p.Dir) + " && " + line + ")"
+	}
+	return line
+}
+
+// quoteArg single-quotes an argument when the shell would split or expand it.
+func quoteArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`*?[]{}()<>|&;!#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var (
+	dryRunMu       sync.Mutex
+	dryRun         bool
+	dryRunPlanned  []Planned
+	dryRunReporter func(Planned)
+)
+
+// SetDryRun turns dry-run mode on or off. While on, mutating git, gh and
+// wrangler invocations are recorded instead of run and report success;
+// read-only ones still run so later steps see real state.
+func SetDryRun(on bool) {
+	dryRunMu.Lock()
+	dryRun = on
+	dryRunMu.Unlock()
+	if on {
+		os.Setenv(DryRunEnv, "1")
+	} else {
+		os.Unsetenv(DryRunEnv)
+	}
+}
+
+// DryRun reports whether dry-run mode is on.
+func DryRun() bool {
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+	return dryRun
+}
+
+// SetDryRunReporter sets the function told about each skipped invocation.
+// Without one, skipped invocations are printed to stderr.
+func SetDryRunReporter(fn func(Planned)) {
+	dryRunMu.Lock()
+	dryRunReporter = fn
+	dryRunMu.Unlock()
+}
+
+// PlannedCalls returns the invocations skipped so far, in order.
+func PlannedCalls() []Planned {
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+	return append([]Planned(nil), dryRunPlanned...)
+}
+
+// Plan records p as skipped and reports it. Callers that run something
+// outside this package's runners (plugins) use it to take part in dry-run.
+func Plan(p Planned) {
+	dryRunMu.Lock()
+	dryRunPlanned = append(dryRunPlanned, p)
+	report := dryRunReporter
+	dryRunMu.Unlock()
+
+	if report != nil {
+		report(p)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "dry-run:", p.String())
+}
+
+// intercept is called by every runner before it starts a process. In
+// dry-run mode a mutating invocation is planned rather than run, and
+// intercept returns true with a synthetic success.
+func intercept(dir, name string, args []string, stdin string) (*Result, bool) {
+	if !DryRun() || !IsMutating(name, args) {
+		return nil, false
+	}
+	Plan(Planned{
+		Binary:     name,
+		Args:       append([]string(nil), args...),
+		Dir:        dir,
+		StdinBytes: len(stdin),
+	})
+	return &Result{}, true
+}
+
+// IsMutating reports whether running name with args could change state
+// outside gw: a repository, GitHub, Cloudflare or a package registry.
+// Unknown invocations of git, gh and wrangler count as mutating; other
+// tools (builds, tests, formatters) only for publish.
+func IsMutating(name string, args []string) bool {
+	switch name {
+	case "git":
+		return !gitReadOnly(args)
+	case "gh":
+		return !ghReadOnly(args)
+	case "wrangler":
+		return !wranglerReadOnly(args)
+	case "npx":
+		if len(args) > 0 && args[0] == "wrangler" {
+			return !wranglerReadOnly(args[1:])
+		}
+		return false
+	case "npm", "pnpm", "bun":
+		return len(args) > 0 && args[0] == "publish"
+	}
+	return false
+}
+
+// positionals returns the non-flag arguments, stopping at "--".
+func positionals(args []string) []string {
+	var out []string
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		if !strings.HasPrefix(a, "-") {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// hasAny reports whether args contains any of flags, as "--x" or "--x=...".
+func hasAny(args []string, flags ...string) bool {
+	for _, a := range args {
+		if a == "--" {
+			return false
+		}
+		name, _, _ := strings.Cut(a, "=")
+		for _, f := range flags {
+			if name == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gitReadOnlyCommands never write to the repository or a remote.
+var gitReadOnlyCommands = map[string]bool{
+	"status": true, "log": true, "diff": true, "show": true, "rev-parse": true,
+	"rev-list": true, "merge-base": true, "ls-files": true, "ls-remote": true,
+	"ls-tree": true, "cat-file": true, "describe": true, "blame": true,
+	"shortlog": true, "for-each-ref": true, "name-rev": true, "grep": true,
+	"check-ignore": true, "show-ref": true, "diff-tree": true, "diff-index": true,
+	"diff-files": true, "cherry": true, "range-diff": true, "var": true,
+	"version": true, "--version": true, "help": true, "count-objects": true,
+}
+
+// gitReadOnly classifies a git invocation.
+func gitReadOnly(args []string) bool {
+	// Global options before the subcommand
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "--version" {
+		if args[0] == "-C" || args[0] == "-c" {
+			if len(args) < 2 {
+				return false
+			}
+			args = args[1:]
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return true
+	}
+	sub, rest := args[0], args[1:]
+	if gitReadOnlyCommands[sub] {
+		return true
+	}
+
+	pos := positionals(rest)
+	switch sub {
+	case "branch":
+		if hasAny(rest, "-d", "-D", "--delete", "-m", "-M", "--move", "-c", "-C", "--copy",
+			"-u", "--set-upstream-to", "--unset-upstream", "--edit-description", "-f", "--force") {
+			return false
+		}
+		return len(pos) == 0 || hasAny(rest, "-l", "--list", "-a", "--all", "-r", "--remotes",
+			"--merged", "--no-merged", "--contains", "--no-contains", "--points-at", "--show-current")
+	case "tag":
+		if hasAny(rest, "-d", "--delete", "-a", "--annotate", "-s", "--sign", "-m", "--message", "-f", "--force") {
+			return false
+		}
+		return len(pos) == 0 || hasAny(rest, "-l", "--list", "--contains", "--no-contains", "--merged", "--points-at")
+	case "stash":
+		return len(pos) > 0 && (pos[0] == "list" || pos[0] == "show")
+	case "worktree":
+		return len(pos) > 0 && pos[0] == "list"
+	case "remote":
+		return len(pos) == 0 || pos[0] == "show" || pos[0] == "get-url"
+	case "bisect":
+		return len(pos) > 0 && (pos[0] == "log" || pos[0] == "visualize" || pos[0] == "view")
+	case "reflog":
+		return len(pos) == 0 || pos[0] == "show"
+	case "config":
+		return hasAny(rest, "--get", "--get-all", "--get-regexp", "--list", "-l") ||
+			(len(pos) > 0 && (pos[0] == "get" || pos[0] == "list"))
+	case "symbolic-ref":
+		return len(pos) <= 1 && !hasAny(rest, "-d", "--delete")
+	}
+	return false
+}
+
+// ghReadOnlyCommands maps gh command groups to their read-only subcommands.
+var ghReadOnlyCommands = map[string]map[string]bool{
+	"pr":       {"list": true, "view": true, "status": true, "diff": true, "checks": true},
+	"issue":    {"list": true, "view": true, "status": true},
+	"run":      {"list": true, "view": true, "watch": true},
+	"workflow": {"list": true, "view": true},
+	"repo":     {"list": true, "view": true},
+	"release":  {"list": true, "view": true},
+	"label":    {"list": true},
+	"auth":     {"status": true, "token": true},
+	"project":  {"list": true, "view": true, "item-list": true, "field-list": true},
+	"cache":    {"list": true},
+	"secret":   {"list": true},
+	"variable": {"list": true, "get": true},
+	"gist":     {"list": true, "view": true},
+	"ruleset":  {"list": true, "view": true, "check": true},
+}
+
+// ghReadOnly classifies a gh invocation.
+func ghReadOnly(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	switch args[0] {
+	case "--version", "version", "help", "status", "search", "browse":
+		return true
+	case "api":
+		return ghAPIReadOnly(args[1:])
+	}
+	pos := positionals(args[1:])
+	subs, ok := ghReadOnlyCommands[args[0]]
+	return ok && len(pos) > 0 && subs[pos[0]]
+}
+
+// ghAPIReadOnly classifies `gh api`: GET requests and GraphQL queries are
+// read-only; anything with a body or another method is not.
+func ghAPIReadOnly(args []string) bool {
+	method := ""
+	hasFields := false
+	query := ""
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		name, value, hasValue := strings.Cut(a, "=")
+		if !strings.HasPrefix(a, "--") {
+			// Short flags take their value as the next argument
+			name, hasValue = a, false
+		}
+		switch name {
+		case "-X", "--method", "-f", "-F", "--field", "--raw-field", "--input":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return false
+				}
+				i++
+				value = args[i]
+			}
+			if name == "-X" || name == "--method" {
+				method = strings.ToUpper(value)
+				continue
+			}
+			hasFields = true
+			if k, v, ok := strings.Cut(value, "="); ok && k == "query" {
+				query = v
+			}
+		}
+	}
+
+	if method != "" {
+		return method == "GET" || method == "HEAD"
+	}
+	if !hasFields {
+		return true
+	}
+	pos := positionals(args)
+	if len(pos) > 0 && pos[0] == "graphql" && query != "" {
+		q := strings.TrimSpace(query)
+		return !strings.HasPrefix(q, "mutation") && !strings.HasPrefix(q, "subscription")
+	}
+	return false
+}
+
+// wranglerReadOnlyVerbs end a wrangler command that only reads.
+var wranglerReadOnlyVerbs = map[string]bool{
+	"list": true, "info": true, "get": true, "view": true, "status": true,
+	"insights": true, "whoami": true, "tail": true, "--version": true,
+	"-v": true, "version": true, "help": true, "--help": true,
+}
+
+// wranglerReadOnly classifies a wrangler invocation.
+func wranglerReadOnly(args []string) bool {
+	var words []string
+	for _, w := range positionals(args) {
+		// Legacy "kv:key" spelling
+		words = append(words, strings.Split(w, ":")...)
+	}
+	if len(args) > 0 && (args[0] == "--version" || args[0] == "-v" || args[0] == "--help") {
+		return true
+	}
+	if len(words) == 0 {
+		return false
+	}
+	// A caller-chosen local state directory, such as the scratch database
+	// gw d1 diff replays migrations into
+	if hasAny(args, "--persist-to") && !hasAny(args, "--remote") {
+		return true
+	}
+
+	switch {
+	case words[0] == "deploy":
+		return hasAny(args, "--dry-run")
+	case len(words) >= 2 && words[0] == "d1" && words[1] == "execute":
+		return d1ExecuteReadOnly(args)
+	case len(words) >= 2 && words[0] == "d1" && words[1] == "export":
+		return true
+	}
+
+	// The verb is the last word before resource names: "kv key list <ns>"
+	// has "list" at depth 3, "whoami" at depth 1.
+	for i := 0; i < len(words) && i < 3; i++ {
+		if wranglerReadOnlyVerbs[words[i]] {
+			return true
+		}
+	}
+	return false
+}
+
+// d1ExecuteReadOnly parses the SQL of `wrangler d1 execute` and reports
+// whether every statement only reads. SQL it cannot see or parse counts
+// as a write.
+func d1ExecuteReadOnly(args []string) bool {
+	var sql string
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if name != "--command" && name != "--file" {
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return false
+			}
+			i++
+			value = args[i]
+		}
+		if name == "--file" {
+			data, err := os.ReadFile(value)
+			if err != nil {
+				return false
+			}
+			value = string(data)
+		}
+		sql = value
+	}
+
+	script, err := sqlparse.Parse(sql)
+	if err != nil || len(script.Statements) == 0 {
+		return false
+	}
+	for _, stmt := range script.Statements {
+		if !stmt.IsRead() {
+			return false
+		}
+	}
+	return true
+}
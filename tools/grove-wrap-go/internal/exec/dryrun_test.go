@@ -0,0 +1,139 @@
+package exec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsMutating(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want bool
+	}{
+		{"git status --porcelain", false},
+		{"git -C /tmp log --oneline -5", false},
+		{"git branch -a", false},
+		{"git branch --merged main", false},
+		{"git branch feature", true},
+		{"git branch -d feature", true},
+		{"git tag -l", false},
+		{"git tag -a v1.0 -m release", true},
+		{"git stash list", false},
+		{"git stash", true},
+		{"git worktree list", false},
+		{"git worktree remove ../wt", true},
+		{"git config --get user.name", false},
+		{"git config user.name Autumn", true},
+		{"git push origin main", true},
+		{"git commit -m msg", true},
+		{"git fetch origin", true},
+		{"gh pr list --state open", false},
+		{"gh pr view 12 --json title", false},
+		{"gh pr create --title x", true},
+		{"gh issue close 4", true},
+		{"gh auth status", false},
+		{"gh api rate_limit", false},
+		{"gh api repos/o/r/issues -f title=x", true},
+		{"gh api -X GET search/issues -f q=bug", false},
+		{"gh api --method=DELETE repos/o/r", true},
+		{"gh api graphql -f query=query{viewer{login}}", false},
+		{"gh api graphql -f query=mutation{addStar}", true},
+		{"wrangler whoami", false},
+		{"wrangler kv:key get --namespace-id abc key", false},
+		{"wrangler kv:key put --namespace-id abc key value", true},
+		{"wrangler kv key list --namespace-id abc", false},
+		{"wrangler r2 object get bucket/key --file out", false},
+		{"wrangler r2 object delete bucket/key", true},
+		{"wrangler d1 list", false},
+		{"wrangler d1 migrations apply db", true},
+		{"wrangler d1 migrations apply db --local --persist-to /tmp/x", false},
+		{"wrangler d1 export db --output x.sql", false},
+		{"wrangler deploy --dry-run", false},
+		{"wrangler deploy", true},
+		{"wrangler secret put NAME", true},
+		{"npx wrangler d1 list", false},
+		{"npx wrangler deploy", true},
+		{"npx prettier --check .", false},
+		{"npm publish --access public", true},
+		{"pnpm run check", false},
+		{"go test ./...", false},
+	}
+	for _, tt := range tests {
+		fields := strings.Fields(tt.cmd)
+		if got := IsMutating(fields[0], fields[1:]); got != tt.want {
+			t.Errorf("IsMutating(%q) = %v, want %v", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestIsMutatingD1Execute(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT * FROM posts", false},
+		{"SELECT 1; SELECT 2", false},
+		{"DELETE FROM posts WHERE id = 1", true},
+		{"SELECT 1; DROP TABLE posts", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		args := []string{"d1", "execute", "db", "--remote", "--command", tt.sql}
+		if got := IsMutating("wrangler", args); got != tt.want {
+			t.Errorf("d1 execute %q: IsMutating = %v, want %v", tt.sql, got, tt.want)
+		}
+		args = []string{"d1", "execute", "db", "--command=" + tt.sql}
+		if got := IsMutating("wrangler", args); got != tt.want {
+			t.Errorf("d1 execute --command=%q: IsMutating = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "q.sql")
+	os.WriteFile(file, []byte("UPDATE posts SET title = 'x'"), 0o644)
+	if !IsMutating("wrangler", []string{"d1", "execute", "db", "--file", file}) {
+		t.Error("d1 execute --file with UPDATE should be mutating")
+	}
+	if !IsMutating("wrangler", []string{"d1", "execute", "db", "--file", file + ".missing"}) {
+		t.Error("d1 execute with an unreadable file should count as mutating")
+	}
+}
+
+func TestDryRunSkipsMutatingCalls(t *testing.T) {
+	var reported []Planned
+	SetDryRunReporter(func(p Planned) { reported = append(reported, p) })
+	SetDryRun(true)
+	defer func() {
+		SetDryRun(false)
+		SetDryRunReporter(nil)
+		dryRunPlanned = nil
+	}()
+
+	if os.Getenv(DryRunEnv) != "1" {
+		t.Errorf("%s should be exported to child processes", DryRunEnv)
+	}
+
+	// Would fail outside a repository, or with nothing staged; skipped instead
+	result, err := RunWithStdin("s3cret", "git", "commit", "-m", "dry run")
+	if err != nil || !result.OK() {
+		t.Fatalf("dry-run commit = %+v, %v; want synthetic success", result, err)
+	}
+
+	// Read-only calls still run
+	result, err = Run("git", "--version")
+	if err != nil || !strings.Contains(result.Stdout, "git version") {
+		t.Errorf("git --version should still run in dry-run, got %+v, %v", result, err)
+	}
+
+	planned := PlannedCalls()
+	if len(planned) != 1 || len(reported) != 1 {
+		t.Fatalf("planned %d calls, reported %d; want 1 each", len(planned), len(reported))
+	}
+	if got := planned[0].String(); got != "git commit -m 'dry run' < [6 bytes redacted]" {
+		t.Errorf("Planned.String() = %q", got)
+	}
+	if strings.Contains(planned[0].String(), "s3cret") {
+		t.Error("stdin must not appear in the planned call")
+	}
+}
@@ -0,0 +1,34 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// RunEditor opens path in the user's editor ($VISUAL, then $EDITOR, then
+// vi) with the terminal attached, and waits for it to exit.
+//
+// The editor is not checked against the allowlist: it comes from the user's
+// own environment, not from command input. It is split on whitespace (so
+// "code --wait" works) and never run through a shell.
+func RunEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	fields := strings.Fields(editor)
+
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package exec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// RunPlugin runs an external gw-<name> plugin with the terminal attached
+// and returns its exit code. env is appended to the inherited environment.
+// When stdin is non-nil it is fed to the plugin instead of the terminal.
+//
+// Plugins are not on the allowlist: they are found by gw's own discovery
+// (~/.grove/plugins/ and PATH) and only run after their manifest's safety
+// tier has been checked.
+func RunPlugin(path string, args, env []string, stdin []byte) (int, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	} else {
+		cmd.Stdin = os.Stdin
+	}
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), nil
+		}
+		return 1, fmt.Errorf("failed to execute plugin %s: %w", path, err)
+	}
+	return 0, nil
+}
@@ -4,6 +4,9 @@
 // Commands are executed with argument lists (no shell expansion) to prevent
 // injection. Output capture and streaming are both supported.
 //
+// In dry-run mode (SetDryRun) every runner skips mutating invocations,
+// recording them instead; see IsMutating.
+//
 // Security: Only allowlisted binaries can be executed. The binary name is
 // validated against a known set to prevent path traversal and arbitrary
 // command execution.
@@ -13,9 +16,11 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -62,6 +67,38 @@ func (r *Result) Lines() []string {
 	return lines
 }
 
+// stderrTailBytes is how much of a failed command's stderr is remembered.
+const stderrTailBytes = 2048
+
+var (
+	failureMu     sync.Mutex
+	failureStderr string
+)
+
+// noteFailure remembers the tail of stderr from a command that exited
+// non-zero, so gw history can store what went wrong.
+func noteFailure(r *Result) {
+	tail := strings.TrimSpace(r.Stderr)
+	if tail == "" {
+		return
+	}
+	if len(tail) > stderrTailBytes {
+		tail = tail[len(tail)-stderrTailBytes:]
+	}
+	failureMu.Lock()
+	failureStderr = tail
+	failureMu.Unlock()
+}
+
+// LastFailureStderr returns the tail of stderr from the most recent captured
+// command that exited non-zero, or "" if none has. Streaming commands write
+// straight to the terminal and are not captured.
+func LastFailureStderr() string {
+	failureMu.Lock()
+	defer failureMu.Unlock()
+	return failureStderr
+}
+
 // DefaultTimeout is the maximum time a subprocess can run.
 const DefaultTimeout = 30 * time.Second
 
@@ -92,6 +129,10 @@ func RunContext(ctx context.Context, name string, args ...string) (*Result, erro
 		return nil, fmt.Errorf("binary name must not contain path separators: %q", name)
 	}
 
+	if r, skipped := intercept("", name, args, ""); skipped {
+		return r, nil
+	}
+
 	cmd := exec.CommandContext(ctx, name, args...)
 
 	var stdout, stderr bytes.Buffer
@@ -108,6 +149,7 @@ func RunContext(ctx context.Context, name string, args ...string) (*Result, erro
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			result.ExitCode = exitErr.ExitCode()
+			noteFailure(result)
 			return result, nil
 		}
 		return result, fmt.Errorf("failed to execute %s: %w", name, err)
@@ -128,6 +170,10 @@ func RunStreaming(name string, args ...string) (int, error) {
 		return 1, fmt.Errorf("binary name must not contain path separators: %q", name)
 	}
 
+	if _, skipped := intercept("", name, args, ""); skipped {
+		return 0, nil
+	}
+
 	cmd := exec.Command(name, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -155,6 +201,10 @@ func RunStreamingInDir(dir string, name string, args ...string) (int, error) {
 		return 1, fmt.Errorf("binary name must not contain path separators: %q", name)
 	}
 
+	if _, skipped := intercept(dir, name, args, ""); skipped {
+		return 0, nil
+	}
+
 	cmd := exec.Command(name, args...)
 	cmd.Dir = dir
 	cmd.Stdout = os.Stdout
@@ -184,6 +234,10 @@ func RunWithStdin(stdinData string, name string, args ...string) (*Result, error
 		return nil, fmt.Errorf("binary name must not contain path separators: %q", name)
 	}
 
+	if r, skipped := intercept("", name, args, stdinData); skipped {
+		return r, nil
+	}
+
 	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Stdin = strings.NewReader(stdinData)
 
@@ -201,6 +255,7 @@ func RunWithStdin(stdinData string, name string, args ...string) (*Result, error
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			result.ExitCode = exitErr.ExitCode()
+			noteFailure(result)
 			return result, nil
 		}
 		return result, fmt.Errorf("failed to execute %s: %w", name, err)
@@ -209,6 +264,71 @@ func RunWithStdin(stdinData string, name string, args ...string) (*Result, error
 	return result, nil
 }
 
+// maxPipedStdout is how much stdout RunPipe keeps for error messages.
+const maxPipedStdout = 4096
+
+// RunPipe executes an allowlisted command and hands its stdout to consume
+// as it is produced, so large output is never held in memory. Stderr is
+// captured; Result.Stdout holds only the first few KB, for error messages.
+// No timeout is applied. A consume error is returned only when the command
+// itself succeeded — a failing command's own error is the more useful one.
+func RunPipe(consume func(io.Reader) error, name string, args ...string) (*Result, error) {
+	if !allowedBinaries[name] {
+		return nil, fmt.Errorf("binary %q is not in the gw allowlist", name)
+	}
+	if strings.ContainsAny(name, "/\\") {
+		return nil, fmt.Errorf("binary name must not contain path separators: %q", name)
+	}
+
+	if r, skipped := intercept("", name, args, ""); skipped {
+		return r, nil
+	}
+
+	cmd := exec.Command(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute %s: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to execute %s: %w", name, err)
+	}
+
+	head := &headBuffer{max: maxPipedStdout}
+	consumeErr := consume(io.TeeReader(stdout, head))
+	// Drain whatever consume left so the command can exit
+	io.Copy(head, stdout)
+	err = cmd.Wait()
+
+	result := &Result{Stdout: head.String(), Stderr: stderr.String()}
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			result.ExitCode = exitErr.ExitCode()
+			noteFailure(result)
+			return result, nil
+		}
+		return result, fmt.Errorf("failed to execute %s: %w", name, err)
+	}
+	return result, consumeErr
+}
+
+// headBuffer keeps the first max bytes written to it and discards the rest.
+type headBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (h *headBuffer) Write(p []byte) (int, error) {
+	if room := h.max - h.Len(); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		h.Buffer.Write(p[:room])
+	}
+	return len(p), nil
+}
+
 // RunInDir executes an allowlisted command in the specified directory.
 func RunInDir(dir string, name string, args ...string) (*Result, error) {
 	return RunInDirWithTimeout(DefaultTimeout, dir, name, args...)
@@ -228,6 +348,10 @@ func RunInDirWithTimeout(timeout time.Duration, dir string, name string, args ..
 		return nil, fmt.Errorf("binary name must not contain path separators: %q", name)
 	}
 
+	if r, skipped := intercept(dir, name, args, ""); skipped {
+		return r, nil
+	}
+
 	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Dir = dir
 
@@ -245,6 +369,7 @@ func RunInDirWithTimeout(timeout time.Duration, dir string, name string, args ..
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			result.ExitCode = exitErr.ExitCode()
+			noteFailure(result)
 			return result, nil
 		}
 		return result, fmt.Errorf("failed to execute %s: %w", name, err)
@@ -1,6 +1,9 @@
 package exec
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestRunAllowedBinary(t *testing.T) {
 	// "git" is allowlisted
@@ -86,3 +89,24 @@ func TestCurrentBranch(t *testing.T) {
 		t.Error("CurrentBranch() returned empty string")
 	}
 }
+
+func TestLastFailureStderr(t *testing.T) {
+	result, err := Run("git", "no-such-subcommand")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.OK() {
+		t.Fatal("unknown git subcommand should fail")
+	}
+	if got := LastFailureStderr(); got == "" || got != strings.TrimSpace(result.Stderr) {
+		t.Errorf("LastFailureStderr() = %q, want the failed command's stderr", got)
+	}
+}
+
+func TestNoteFailureKeepsTail(t *testing.T) {
+	noteFailure(&Result{Stderr: strings.Repeat("a", stderrTailBytes) + "END"})
+	got := LastFailureStderr()
+	if len(got) != stderrTailBytes || !strings.HasSuffix(got, "END") {
+		t.Errorf("tail should keep the last %d bytes, got %d bytes", stderrTailBytes, len(got))
+	}
+}
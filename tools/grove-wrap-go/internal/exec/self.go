@@ -0,0 +1,47 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// RunSelf runs the current gw binary with args and captures its output.
+// env is appended to the inherited environment. stdin is empty, so a child
+// that tries to prompt sees EOF instead of waiting.
+//
+// The binary is located with os.Executable rather than PATH, so the child
+// is always the same build as the parent. Failures are not noted for
+// history: the child records its own entry.
+func RunSelf(ctx context.Context, env []string, args ...string) (*Result, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot locate the gw binary: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, self, args...)
+	cmd.Env = append(os.Environ(), env...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+
+	result := &Result{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			result.ExitCode = exitErr.ExitCode()
+			return result, nil
+		}
+		return result, fmt.Errorf("failed to execute gw: %w", err)
+	}
+
+	return result, nil
+}
@@ -2,6 +2,7 @@ package exec
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -17,6 +18,15 @@ func Wrangler(args ...string) (*Result, error) {
 	return Run("npx", append([]string{"wrangler"}, args...)...)
 }
 
+// WranglerWithTimeout runs a wrangler command with its own timeout, for
+// transfers that can outlast DefaultTimeout.
+func WranglerWithTimeout(timeout time.Duration, args ...string) (*Result, error) {
+	if _, ok := Which("wrangler"); ok {
+		return RunWithTimeout(timeout, "wrangler", args...)
+	}
+	return RunWithTimeout(timeout, "npx", append([]string{"wrangler"}, args...)...)
+}
+
 // WranglerOutput runs a wrangler command and returns stdout, or an error.
 func WranglerOutput(args ...string) (string, error) {
 	result, err := Wrangler(args...)
@@ -36,6 +46,30 @@ func WranglerOutput(args ...string) (string, error) {
 	return result.Stdout, nil
 }
 
+// WranglerPipe runs a wrangler command, handing its stdout to consume as
+// it arrives. A failing command is reported like WranglerOutput does.
+func WranglerPipe(consume func(io.Reader) error, args ...string) error {
+	name, cmdArgs := "wrangler", args
+	if _, ok := Which("wrangler"); !ok {
+		name, cmdArgs = "npx", append([]string{"wrangler"}, args...)
+	}
+	result, err := RunPipe(consume, name, cmdArgs...)
+	if err != nil {
+		return err
+	}
+	if !result.OK() {
+		msg := strings.TrimSpace(result.Stderr)
+		if msg == "" {
+			msg = strings.TrimSpace(result.Stdout)
+		}
+		if msg == "" {
+			msg = fmt.Sprintf("exited with code %d", result.ExitCode)
+		}
+		return fmt.Errorf("wrangler: %s", msg)
+	}
+	return nil
+}
+
 // WranglerInteractive runs a wrangler command with stdin/stdout/stderr
 // connected directly to the terminal. Used for streaming commands like logs.
 func WranglerInteractive(args ...string) (*Result, error) {
@@ -57,6 +91,10 @@ func WranglerInteractive(args ...string) (*Result, error) {
 		return nil, fmt.Errorf("binary name must not contain path separators: %q", name)
 	}
 
+	if r, skipped := intercept("", name, cmdArgs, ""); skipped {
+		return r, nil
+	}
+
 	cmd := exec.Command(name, cmdArgs...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
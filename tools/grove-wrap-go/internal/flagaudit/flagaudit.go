@@ -0,0 +1,239 @@
+// Package flagaudit reconciles the feature flags stored in KV with the
+// flags the code reads.
+//
+// Reads are found the way gf flags finds them, by searching the source for
+// the engine's flag functions called with a literal name:
+// isFeatureEnabled("jxl_encoding", ...), getFeatureValue, getVariant,
+// getFlag, evaluateFlag and getFeatureFlag take the name first, and
+// isGraftEnabled takes it first or after the loaded grafts. Names built at
+// runtime cannot be seen, which is what the ignore list is for.
+package flagaudit
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/flagrules"
+)
+
+// flagRead matches a flag function called with a literal flag name.
+var flagRead = regexp.MustCompile(`\b(isFeatureEnabled|getFeatureValue|getVariant|getFlag|evaluateFlag|getFeatureFlag|isGraftEnabled)\s*(?:<[^>()]*>)?\(\s*(?:[\w.?]+\s*,\s*)?["'` + "`" + `]([\w.:-]+)["'` + "`" + `]`)
+
+// sourceExts are the files searched for flag reads.
+var sourceExts = map[string]bool{".ts": true, ".js": true, ".svelte": true, ".tsx": true, ".jsx": true}
+
+// skipDirs hold dependencies and build output rather than source.
+var skipDirs = map[string]bool{"node_modules": true, "dist": true, "build": true, "coverage": true}
+
+// Usage is one place the code reads a flag.
+type Usage struct {
+	Flag string `json:"flag"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Call string `json:"call"`
+}
+
+// isTestFile reports whether a file holds tests, whose flags are fixtures
+// rather than reads.
+func isTestFile(name string) bool {
+	return strings.Contains(name, ".test.") || strings.Contains(name, ".spec.")
+}
+
+// Scan searches dirs, relative to root, for flag reads. Files are reported
+// relative to root. Directories that do not exist are skipped.
+func Scan(root string, dirs []string) ([]Usage, error) {
+	var usages []Usage
+	for _, dir := range dirs {
+		start := dir
+		if !filepath.IsAbs(start) {
+			start = filepath.Join(root, dir)
+		}
+		if _, err := os.Stat(start); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if skipDirs[d.Name()] || (strings.HasPrefix(d.Name(), ".") && p != start) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !sourceExts[filepath.Ext(p)] || isTestFile(d.Name()) {
+				return nil
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				rel = p
+			}
+			usages = append(usages, scanSource(filepath.ToSlash(rel), data)...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return usages, nil
+}
+
+// scanSource finds the flag reads in one file. Calls on comment lines are
+// examples in doc comments, not reads.
+func scanSource(file string, data []byte) []Usage {
+	var usages []Usage
+	for _, m := range flagRead.FindAllSubmatchIndex(data, -1) {
+		lineStart := bytes.LastIndexByte(data[:m[0]], '\n') + 1
+		lead := bytes.TrimSpace(data[lineStart:m[0]])
+		if bytes.HasPrefix(lead, []byte("*")) || bytes.HasPrefix(lead, []byte("//")) || bytes.HasPrefix(lead, []byte("/*")) {
+			continue
+		}
+		usages = append(usages, Usage{
+			Flag: string(data[m[4]:m[5]]),
+			File: file,
+			Line: bytes.Count(data[:m[0]], []byte("\n")) + 1,
+			Call: string(data[m[2]:m[3]]),
+		})
+	}
+	return usages
+}
+
+// Flag is a flag stored in KV and when it last changed, zero when unknown.
+type Flag struct {
+	Name    string
+	Raw     string
+	Enabled bool
+	Changed time.Time
+}
+
+// UpdatedAt reads the updated_at gw stamps on flag values, zero when the
+// value has none.
+func UpdatedAt(raw string) time.Time {
+	m := updatedAt.FindStringSubmatch(raw)
+	if m == nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, m[1])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+var updatedAt = regexp.MustCompile(`"updated_at"\s*:\s*"([^"]+)"`)
+
+// Settled reports whether a flag is the same for every tenant: fully on
+// (100) or fully off (0). Rules that single out tenants or plans mean it
+// is still being rolled out.
+func Settled(enabled bool, raw string) (percent int, ok bool) {
+	if !enabled {
+		return 0, true
+	}
+	rules, err := flagrules.Read(raw)
+	if err != nil {
+		return 0, false
+	}
+	if rules.Empty() {
+		return 100, true
+	}
+	if len(rules.Allow) > 0 || len(rules.Deny) > 0 || len(rules.Plans) > 0 || rules.Percent == nil {
+		return 0, false
+	}
+	switch *rules.Percent {
+	case 0:
+		return 0, true
+	case 100:
+		return 100, true
+	}
+	return 0, false
+}
+
+// Finding kinds.
+const (
+	Unused  = "unused"  // in KV, never read by the code
+	Missing = "missing" // read by the code, not in KV
+	Stale   = "stale"   // fully on or off for longer than the threshold
+)
+
+// Finding is one flag the audit reports.
+type Finding struct {
+	Kind    string     `json:"kind"`
+	Flag    string     `json:"flag"`
+	Detail  string     `json:"detail"`
+	Files   []string   `json:"files,omitempty"`
+	Percent *int       `json:"percent,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+	Days    int        `json:"days,omitempty"`
+}
+
+// Audit compares the stored flags with the code's reads. A settled flag is
+// stale once it has not changed for staleDays; flags whose last change is
+// unknown are never reported stale. Ignored flags are left out.
+func Audit(flags []Flag, usages []Usage, ignore []string, staleDays int, now time.Time) []Finding {
+	ignored := map[string]bool{}
+	for _, name := range ignore {
+		ignored[name] = true
+	}
+	reads := map[string][]string{}
+	for _, u := range usages {
+		loc := u.File + ":" + strconv.Itoa(u.Line)
+		reads[u.Flag] = append(reads[u.Flag], loc)
+	}
+	stored := map[string]bool{}
+
+	var findings []Finding
+	for _, f := range flags {
+		stored[f.Name] = true
+		if ignored[f.Name] {
+			continue
+		}
+		if len(reads[f.Name]) == 0 {
+			findings = append(findings, Finding{Kind: Unused, Flag: f.Name, Detail: "stored in KV but never read by the code"})
+			continue
+		}
+		pct, ok := Settled(f.Enabled, f.Raw)
+		if !ok || f.Changed.IsZero() {
+			continue
+		}
+		days := int(now.Sub(f.Changed).Hours() / 24)
+		if days < staleDays {
+			continue
+		}
+		state := "off for everyone"
+		if pct == 100 {
+			state = "on for everyone"
+		}
+		since := f.Changed
+		findings = append(findings, Finding{
+			Kind: Stale, Flag: f.Name, Percent: &pct, Since: &since, Days: days,
+			Detail: state + " for " + strconv.Itoa(days) + " days, ready to remove",
+			Files:  reads[f.Name],
+		})
+	}
+	for name, files := range reads {
+		if stored[name] || ignored[name] {
+			continue
+		}
+		findings = append(findings, Finding{Kind: Missing, Flag: name, Detail: "read by the code but not in KV", Files: files})
+	}
+
+	order := map[string]int{Missing: 0, Unused: 1, Stale: 2}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Kind != findings[j].Kind {
+			return order[findings[i].Kind] < order[findings[j].Kind]
+		}
+		return findings[i].Flag < findings[j].Flag
+	})
+	return findings
+}
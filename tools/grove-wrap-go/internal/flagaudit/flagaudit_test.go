@@ -0,0 +1,128 @@
+package flagaudit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanSource(t *testing.T) {
+	src := `const useJxl = await isFeatureEnabled('jxl_encoding', { tenantId }, env);
+const max = await getFeatureValue<number>("max_uploads", ctx, env, 10);
+const fireside = isGraftEnabled(data.grafts, "fireside_mode");
+const pricing = await isGraftEnabled(
+	'pricing',
+	{ productId: 'grove' },
+);
+const dynamic = await isFeatureEnabled(flagId, ctx, env);
+ * const example = await isFeatureEnabled('doc_example', ctx, env);
+// isFeatureEnabled("commented_out", ctx, env)
+`
+	got := scanSource("a.ts", []byte(src))
+	want := []Usage{
+		{"jxl_encoding", "a.ts", 1, "isFeatureEnabled"},
+		{"max_uploads", "a.ts", 2, "getFeatureValue"},
+		{"fireside_mode", "a.ts", 3, "isGraftEnabled"},
+		{"pricing", "a.ts", 4, "isGraftEnabled"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d usages, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("usage %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestScanSkipsTestsAndDependencies(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, body string) {
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("libs/a.ts", `isFeatureEnabled("real", c, e)`)
+	write("libs/a.test.ts", `isFeatureEnabled("fixture", c, e)`)
+	write("libs/node_modules/x/index.js", `isFeatureEnabled("vendored", c, e)`)
+	write("libs/readme.md", `isFeatureEnabled("docs", c, e)`)
+
+	got, err := Scan(root, []string{"libs", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Flag != "real" || got[0].File != "libs/a.ts" {
+		t.Errorf("Scan = %+v, want only the read in libs/a.ts", got)
+	}
+}
+
+func TestSettled(t *testing.T) {
+	tests := []struct {
+		enabled bool
+		raw     string
+		pct     int
+		ok      bool
+	}{
+		{false, "false", 0, true},
+		{true, "true", 100, true},
+		{true, `{"enabled":true,"rules":{"percent":100}}`, 100, true},
+		{true, `{"enabled":true,"rules":{"percent":0}}`, 0, true},
+		{true, `{"enabled":true,"rules":{"percent":25}}`, 0, false},
+		{true, `{"enabled":true,"rules":{"percent":100,"deny":["spam"]}}`, 0, false},
+		{true, `{"enabled":true,"rules":{"allow":["autumn"]}}`, 0, false},
+	}
+	for _, tt := range tests {
+		pct, ok := Settled(tt.enabled, tt.raw)
+		if pct != tt.pct || ok != tt.ok {
+			t.Errorf("Settled(%v, %s) = %d, %v; want %d, %v", tt.enabled, tt.raw, pct, ok, tt.pct, tt.ok)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -45)
+	recent := now.AddDate(0, 0, -3)
+	flags := []Flag{
+		{Name: "orphan", Raw: "true", Enabled: true, Changed: old},
+		{Name: "done", Raw: "true", Enabled: true, Changed: old},
+		{Name: "fresh", Raw: "true", Enabled: true, Changed: recent},
+		{Name: "rolling", Raw: `{"enabled":true,"rules":{"percent":50}}`, Enabled: true, Changed: old},
+		{Name: "undated", Raw: "false"},
+		{Name: "kept", Raw: "true", Enabled: true},
+	}
+	usages := []Usage{
+		{Flag: "done", File: "a.ts", Line: 3},
+		{Flag: "fresh", File: "a.ts", Line: 4},
+		{Flag: "rolling", File: "b.ts", Line: 1},
+		{Flag: "undated", File: "b.ts", Line: 2},
+		{Flag: "absent", File: "c.ts", Line: 9},
+		{Flag: "dynamic", File: "c.ts", Line: 10},
+	}
+	got := Audit(flags, usages, []string{"kept", "dynamic"}, 30, now)
+
+	want := []struct{ kind, flag string }{
+		{Missing, "absent"},
+		{Unused, "orphan"},
+		{Stale, "done"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d findings, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Kind != w.kind || got[i].Flag != w.flag {
+			t.Errorf("finding %d = %s %s, want %s %s", i, got[i].Kind, got[i].Flag, w.kind, w.flag)
+		}
+	}
+	if got[0].Files[0] != "c.ts:9" {
+		t.Errorf("missing flag files = %v", got[0].Files)
+	}
+	if got[2].Days != 45 || *got[2].Percent != 100 {
+		t.Errorf("stale finding = %+v, want 45 days at 100%%", got[2])
+	}
+}
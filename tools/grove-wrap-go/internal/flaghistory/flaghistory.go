@@ -0,0 +1,95 @@
+// Package flaghistory keeps a versioned record of every change gw makes to
+// a feature flag: the value before and after, who made it and when.
+//
+// A flag's records live beside it in the flags namespace, under
+// "_history:<flag>", so everyone with access to the flags sees the same
+// history. Versions count up from 1 and are never reused; only the newest
+// MaxRecords records are kept.
+package flaghistory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// KeyPrefix starts the key holding a flag's history.
+const KeyPrefix = "_history:"
+
+// MaxRecords bounds how many records a flag keeps.
+const MaxRecords = 200
+
+// Key returns the key holding a flag's history.
+func Key(flag string) string {
+	return KeyPrefix + flag
+}
+
+// IsKey reports whether a key in the flags namespace holds history rather
+// than a flag.
+func IsKey(key string) bool {
+	return strings.HasPrefix(key, KeyPrefix)
+}
+
+// Record is one change to a flag. Old and New are the raw values, nil when
+// the flag did not exist.
+type Record struct {
+	Version int       `json:"version"`
+	Action  string    `json:"action"` // enable, disable, delete, rollout, target, revert
+	Old     *string   `json:"old"`
+	New     *string   `json:"new"`
+	Actor   string    `json:"actor"`
+	At      time.Time `json:"at"`
+	To      int       `json:"to,omitempty"` // the version a revert went back to
+}
+
+// Parse reads a history value. An empty value is an empty history.
+func Parse(raw string) ([]Record, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var records []Record
+	if err := json.Unmarshal([]byte(raw), &records); err != nil {
+		return nil, fmt.Errorf("invalid flag history: %w", err)
+	}
+	return records, nil
+}
+
+// Append adds r as the next version, dropping the oldest records beyond
+// MaxRecords, and returns the history with the version r was given.
+func Append(records []Record, r Record) ([]Record, int) {
+	r.Version = 1
+	if n := len(records); n > 0 {
+		r.Version = records[n-1].Version + 1
+	}
+	records = append(records, r)
+	if len(records) > MaxRecords {
+		records = records[len(records)-MaxRecords:]
+	}
+	return records, r.Version
+}
+
+// Encode renders a history for storage.
+func Encode(records []Record) (string, error) {
+	data, err := json.Marshal(records)
+	return string(data), err
+}
+
+// StateAt returns the flag's value just after version: the New of that
+// record, or for the version before the oldest record its Old, so version
+// 0 is the flag before gw first changed it. A nil value means the flag did
+// not exist.
+func StateAt(records []Record, version int) (*string, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no history recorded")
+	}
+	if version == records[0].Version-1 {
+		return records[0].Old, nil
+	}
+	for _, r := range records {
+		if r.Version == version {
+			return r.New, nil
+		}
+	}
+	return nil, fmt.Errorf("no version %d (history holds %d to %d)", version, records[0].Version-1, records[len(records)-1].Version)
+}
@@ -0,0 +1,71 @@
+package flaghistory
+
+import (
+	"testing"
+	"time"
+)
+
+func str(s string) *string { return &s }
+
+func TestAppendAndStateAt(t *testing.T) {
+	var records []Record
+	records, v := Append(records, Record{Action: "enable", Old: nil, New: str("true"), At: time.Now()})
+	if v != 1 {
+		t.Fatalf("first version = %d", v)
+	}
+	records, _ = Append(records, Record{Action: "disable", Old: str("true"), New: str("false")})
+	records, v = Append(records, Record{Action: "delete", Old: str("false"), New: nil})
+	if v != 3 {
+		t.Fatalf("third version = %d", v)
+	}
+
+	raw, err := Encode(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err = Parse(raw)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("Parse = %d records, %v", len(records), err)
+	}
+
+	tests := []struct {
+		version int
+		want    *string
+	}{{0, nil}, {1, str("true")}, {2, str("false")}, {3, nil}}
+	for _, tt := range tests {
+		got, err := StateAt(records, tt.version)
+		if err != nil {
+			t.Errorf("StateAt(%d): %v", tt.version, err)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("StateAt(%d) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+	if _, err := StateAt(records, 4); err == nil {
+		t.Error("StateAt accepted a version past the newest")
+	}
+	if _, err := StateAt(nil, 0); err == nil {
+		t.Error("StateAt accepted an empty history")
+	}
+}
+
+func TestAppendTrims(t *testing.T) {
+	var records []Record
+	for i := 0; i < MaxRecords+5; i++ {
+		records, _ = Append(records, Record{Action: "enable", Old: str("a"), New: str("b")})
+	}
+	if len(records) != MaxRecords || records[0].Version != 6 {
+		t.Fatalf("kept %d records from version %d", len(records), records[0].Version)
+	}
+	// The state before the oldest kept record is still known
+	if got, err := StateAt(records, 5); err != nil || *got != "a" {
+		t.Errorf("StateAt(5) = %v, %v", got, err)
+	}
+	if _, err := StateAt(records, 4); err == nil {
+		t.Error("StateAt returned a trimmed version")
+	}
+	if !IsKey(Key("beta")) || IsKey("beta") {
+		t.Error("IsKey does not tell history keys from flags")
+	}
+}
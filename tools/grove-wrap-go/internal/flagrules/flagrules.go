@@ -0,0 +1,210 @@
+// Package flagrules reads, edits and evaluates the targeting rules of a KV
+// feature flag.
+//
+// A flag is a boolean, a string such as "on", or an object with an
+// "enabled" field. An object may also carry rules:
+//
+//	{"enabled": true, "rules": {"deny": ["spam"], "allow": ["autumn"],
+//	  "plans": ["oak", "evergreen"], "percent": 25}}
+//
+// Rules only narrow a flag that is enabled. They are checked in a fixed
+// order: a denied tenant is off, an allowed tenant is on, a tenant outside
+// the listed plans is off, and the rest fall into a percentage rollout
+// bucketed the way the engine buckets them, by SHA-256 of
+// "<flag>:<salt>:<tenant ID>". A flag without rules is on for everyone.
+package flagrules
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Rules narrow who an enabled flag is on for. Allow and Deny hold tenant
+// subdomains or IDs.
+type Rules struct {
+	Allow   []string `json:"allow,omitempty"`
+	Deny    []string `json:"deny,omitempty"`
+	Plans   []string `json:"plans,omitempty"`
+	Percent *int     `json:"percent,omitempty"` // 0-100; nil: no rollout
+	Salt    string   `json:"salt,omitempty"`    // reshuffles the buckets
+}
+
+// Empty reports whether the rules narrow nothing.
+func (r *Rules) Empty() bool {
+	return r == nil || (len(r.Allow) == 0 && len(r.Deny) == 0 && len(r.Plans) == 0 && r.Percent == nil)
+}
+
+// Validate checks the rules for values the evaluator cannot honour.
+func (r *Rules) Validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.Percent != nil && (*r.Percent < 0 || *r.Percent > 100) {
+		return fmt.Errorf("rollout percent %d is outside 0-100", *r.Percent)
+	}
+	for _, t := range r.Allow {
+		if slices.Contains(r.Deny, t) {
+			return fmt.Errorf("tenant %s is both allowed and denied", t)
+		}
+	}
+	for _, lists := range [][]string{r.Allow, r.Deny, r.Plans} {
+		for _, v := range lists {
+			if strings.TrimSpace(v) == "" {
+				return fmt.Errorf("rules contain an empty tenant or plan")
+			}
+		}
+	}
+	return nil
+}
+
+// Summary describes the rules in one line, such as
+// "25% rollout · allow autumn · plans oak".
+func (r *Rules) Summary() string {
+	if r.Empty() {
+		return "none"
+	}
+	var parts []string
+	if r.Percent != nil {
+		parts = append(parts, fmt.Sprintf("%d%% rollout", *r.Percent))
+	}
+	if len(r.Allow) > 0 {
+		parts = append(parts, "allow "+strings.Join(r.Allow, ", "))
+	}
+	if len(r.Deny) > 0 {
+		parts = append(parts, "deny "+strings.Join(r.Deny, ", "))
+	}
+	if len(r.Plans) > 0 {
+		parts = append(parts, "plans "+strings.Join(r.Plans, ", "))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Read returns the rules of a flag value, nil when it has none. Values
+// that are not objects, like the plain booleans older flags hold, have
+// no rules.
+func Read(raw string) (*Rules, error) {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal([]byte(strings.TrimSpace(raw)), &obj) != nil {
+		return nil, nil
+	}
+	data, ok := obj["rules"]
+	if !ok || string(data) == "null" {
+		return nil, nil
+	}
+	var r Rules
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("invalid flag rules: %w", err)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid flag rules: %w", err)
+	}
+	return &r, nil
+}
+
+// Write returns raw with its enabled state and rules replaced, keeping any
+// other fields an object value carries. Empty rules are removed.
+func Write(raw string, enabled bool, rules *Rules, now time.Time) (string, error) {
+	if err := rules.Validate(); err != nil {
+		return "", err
+	}
+	obj := map[string]json.RawMessage{}
+	if json.Unmarshal([]byte(strings.TrimSpace(raw)), &obj) != nil || obj == nil {
+		obj = map[string]json.RawMessage{}
+	}
+	set := func(key string, v any) {
+		data, _ := json.Marshal(v)
+		obj[key] = data
+	}
+	set("enabled", enabled)
+	set("updated_at", now.UTC().Format(time.RFC3339))
+	if rules.Empty() {
+		delete(obj, "rules")
+	} else {
+		set("rules", rules)
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Tenant is who a flag is evaluated for. ID is needed only for rollouts
+// and Plan only for plan rules.
+type Tenant struct {
+	Subdomain string `json:"subdomain"`
+	ID        string `json:"id,omitempty"`
+	Plan      string `json:"plan,omitempty"`
+}
+
+func (t Tenant) in(list []string) bool {
+	return slices.Contains(list, t.Subdomain) || (t.ID != "" && slices.Contains(list, t.ID))
+}
+
+// Result is an evaluation and the rule that decided it: "disabled",
+// "deny", "allow", "plans", "percent" or "default".
+type Result struct {
+	Enabled bool   `json:"enabled"`
+	Rule    string `json:"rule"`
+	Reason  string `json:"reason"`
+	Bucket  *int   `json:"bucket,omitempty"`
+}
+
+// Evaluate decides whether flag is on for a tenant.
+func Evaluate(flag string, enabled bool, rules *Rules, t Tenant) Result {
+	switch {
+	case !enabled:
+		return Result{false, "disabled", "the flag is disabled for everyone", nil}
+	case rules.Empty():
+		return Result{true, "default", "the flag is on with no rules", nil}
+	case t.in(rules.Deny):
+		return Result{false, "deny", t.Subdomain + " is on the deny list", nil}
+	case t.in(rules.Allow):
+		return Result{true, "allow", t.Subdomain + " is on the allow list", nil}
+	}
+	if len(rules.Plans) > 0 {
+		if t.Plan == "" {
+			return Result{false, "plans", "the tenant's plan is unknown", nil}
+		}
+		if !slices.Contains(rules.Plans, t.Plan) {
+			return Result{false, "plans", fmt.Sprintf("plan %s is not one of %s", t.Plan, strings.Join(rules.Plans, ", ")), nil}
+		}
+	}
+	if rules.Percent != nil {
+		// As in the engine, 0 and 100 need no bucket, and so no tenant ID
+		pct := *rules.Percent
+		if pct >= 100 {
+			return Result{true, "percent", "the rollout covers every tenant", nil}
+		}
+		if pct <= 0 {
+			return Result{false, "percent", "the rollout covers no tenants", nil}
+		}
+		if t.ID == "" {
+			return Result{false, "percent", "no tenant ID to place in the rollout", nil}
+		}
+		b := Bucket(flag, rules.Salt, t.ID)
+		if b < pct {
+			return Result{true, "percent", fmt.Sprintf("bucket %d is inside the %d%% rollout", b, pct), &b}
+		}
+		return Result{false, "percent", fmt.Sprintf("bucket %d is outside the %d%% rollout", b, pct), &b}
+	}
+	if len(rules.Plans) > 0 {
+		return Result{true, "plans", "plan " + t.Plan + " is targeted", nil}
+	}
+	return Result{true, "default", "no rule excluded the tenant", nil}
+}
+
+// Bucket places an identifier in 0-99 for a flag, as the engine's
+// getUserBucket does: the first four bytes of a SHA-256, big-endian,
+// modulo 100.
+func Bucket(flag, salt, id string) int {
+	sum := sha256.Sum256([]byte(flag + ":" + salt + ":" + id))
+	return int(binary.BigEndian.Uint32(sum[:4]) % 100)
+}
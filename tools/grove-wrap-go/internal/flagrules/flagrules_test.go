@@ -0,0 +1,110 @@
+package flagrules
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func intp(n int) *int { return &n }
+
+func TestBucket(t *testing.T) {
+	// Computed with the engine's getUserBucket("new-editor", "tenant-123")
+	if got := Bucket("new-editor", "", "tenant-123"); got != 92 {
+		t.Errorf("Bucket = %d, want 92", got)
+	}
+	if Bucket("a", "", "x") == Bucket("a", "salt", "x") && Bucket("b", "", "x") == Bucket("b", "salt", "x") {
+		t.Error("salt does not change buckets")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rules := &Rules{
+		Allow: []string{"autumn"},
+		Deny:  []string{"spam", "id-denied"},
+		Plans: []string{"oak", "evergreen"},
+	}
+	tests := []struct {
+		name    string
+		enabled bool
+		rules   *Rules
+		tenant  Tenant
+		want    bool
+		rule    string
+	}{
+		{"disabled", false, rules, Tenant{Subdomain: "autumn"}, false, "disabled"},
+		{"no rules", true, nil, Tenant{Subdomain: "x"}, true, "default"},
+		{"deny", true, rules, Tenant{Subdomain: "spam", Plan: "oak"}, false, "deny"},
+		{"deny by id", true, rules, Tenant{Subdomain: "x", ID: "id-denied"}, false, "deny"},
+		{"allow beats plans", true, rules, Tenant{Subdomain: "autumn", Plan: "seedling"}, true, "allow"},
+		{"plan outside", true, rules, Tenant{Subdomain: "x", Plan: "seedling"}, false, "plans"},
+		{"plan unknown", true, rules, Tenant{Subdomain: "x"}, false, "plans"},
+		{"plan inside", true, rules, Tenant{Subdomain: "x", Plan: "oak"}, true, "plans"},
+		{"rollout without id", true, &Rules{Percent: intp(50)}, Tenant{Subdomain: "x"}, false, "percent"},
+		{"rollout 100", true, &Rules{Percent: intp(100)}, Tenant{Subdomain: "x", ID: "1"}, true, "percent"},
+		{"rollout 0", true, &Rules{Percent: intp(0)}, Tenant{Subdomain: "x", ID: "1"}, false, "percent"},
+		{"rollout 100 without id", true, &Rules{Percent: intp(100)}, Tenant{Subdomain: "x"}, true, "percent"},
+		{"rollout 0 without id", true, &Rules{Percent: intp(0)}, Tenant{Subdomain: "x"}, false, "percent"},
+		{"rollout bucket 92 of 92", true, &Rules{Percent: intp(92)}, Tenant{Subdomain: "x", ID: "tenant-123"}, false, "percent"},
+		{"rollout bucket 92 of 93", true, &Rules{Percent: intp(93)}, Tenant{Subdomain: "x", ID: "tenant-123"}, true, "percent"},
+	}
+	for _, tt := range tests {
+		got := Evaluate("new-editor", tt.enabled, tt.rules, tt.tenant)
+		if got.Enabled != tt.want || got.Rule != tt.rule {
+			t.Errorf("%s: Evaluate = %+v, want %v by %s", tt.name, got, tt.want, tt.rule)
+		}
+	}
+}
+
+func TestRollout(t *testing.T) {
+	// A 25% rollout lands near a quarter of many tenants
+	on := 0
+	for i := 0; i < 4000; i++ {
+		r := Evaluate("flag", true, &Rules{Percent: intp(25)}, Tenant{Subdomain: "t", ID: fmt.Sprintf("tenant-%d", i)})
+		if r.Enabled {
+			on++
+		}
+	}
+	if on < 850 || on > 1150 {
+		t.Errorf("25%% rollout enabled %d of 4000", on)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	for _, raw := range []string{"true", "on", `"yes"`, `{"enabled": false}`, "0"} {
+		if r, err := Read(raw); r != nil || err != nil {
+			t.Errorf("Read(%q) = %+v, %v; want no rules", raw, r, err)
+		}
+	}
+	if _, err := Read(`{"enabled": true, "rules": {"percent": 120}}`); err == nil {
+		t.Error("a percent over 100 was accepted")
+	}
+	if _, err := Read(`{"enabled": true, "rules": {"percentage": 20}}`); err == nil {
+		t.Error("an unknown rule was accepted")
+	}
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	out, err := Write(`{"enabled": false, "owner": "autumn"}`, true, &Rules{Percent: intp(25), Allow: []string{"willow"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"enabled":true,"owner":"autumn","rules":{"allow":["willow"],"percent":25},"updated_at":"2026-03-01T12:00:00Z"}`
+	if out != want {
+		t.Errorf("Write = %s\nwant    %s", out, want)
+	}
+	r, err := Read(out)
+	if err != nil || r.Percent == nil || *r.Percent != 25 || r.Summary() != "25% rollout · allow willow" {
+		t.Errorf("Read back %+v, %v", r, err)
+	}
+
+	out, _ = Write("true", true, &Rules{}, now)
+	var obj map[string]any
+	json.Unmarshal([]byte(out), &obj)
+	if _, ok := obj["rules"]; ok || obj["enabled"] != true {
+		t.Errorf("Write of a boolean flag with empty rules = %s", out)
+	}
+	if _, err := Write("true", true, &Rules{Allow: []string{"a"}, Deny: []string{"a"}}, now); err == nil {
+		t.Error("a tenant both allowed and denied was accepted")
+	}
+}
@@ -26,6 +26,31 @@ type Entry struct {
 	ExitCode   int    `json:"exit_code"`
 	DurationMS int64  `json:"duration_ms"`
 	Timestamp  string `json:"timestamp"`
+
+	// Argv is the full invocation after "gw", flags included.
+	Argv []string `json:"argv,omitempty"`
+	// Error is the message the command failed with.
+	Error string `json:"error,omitempty"`
+	// SafetyBlock names the safety rule that refused the command, if any.
+	SafetyBlock string `json:"safety_block,omitempty"`
+	// Target is the resource the command's last safety check was for.
+	Target *Target `json:"target,omitempty"`
+	// StderrTail is the end of stderr from the subprocess that failed.
+	StderrTail string `json:"stderr_tail,omitempty"`
+}
+
+// Target names the resource a command acted on, as its safety check saw
+// it, so a replay can be judged against scoped policy rules.
+type Target struct {
+	Branch    string `json:"branch,omitempty"`
+	Database  string `json:"database,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Worker    string `json:"worker,omitempty"`
+}
+
+// Failed reports whether the command exited non-zero.
+func (e Entry) Failed() bool {
+	return e.ExitCode != 0
 }
 
 // HistoryDB holds the in-memory history and the path to the JSON file.
@@ -60,20 +85,23 @@ func Open() (*HistoryDB, error) {
 // RecordCommand appends a new entry to the history, auto-incrementing the ID,
 // and persists the updated list to disk.
 func (h *HistoryDB) RecordCommand(command, args string, isWrite bool, exitCode int, durationMS int64) error {
-	nextID := 1
-	if len(h.entries) > 0 {
-		nextID = h.entries[len(h.entries)-1].ID + 1
-	}
-
-	entry := Entry{
-		ID:         nextID,
+	return h.Record(Entry{
 		Command:    command,
 		Args:       args,
 		IsWrite:    isWrite,
 		ExitCode:   exitCode,
 		DurationMS: durationMS,
-		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// Record appends entry to the history and persists it. ID and Timestamp are
+// assigned here; any values set by the caller are overwritten.
+func (h *HistoryDB) Record(entry Entry) error {
+	entry.ID = 1
+	if len(h.entries) > 0 {
+		entry.ID = h.entries[len(h.entries)-1].ID + 1
 	}
+	entry.Timestamp = time.Now().UTC().Format(time.RFC3339)
 
 	h.entries = append(h.entries, entry)
 
@@ -101,6 +129,21 @@ func (h *HistoryDB) List(limit int) []Entry {
 	return result
 }
 
+// ListFailed returns the last N entries that exited non-zero, most recent
+// first. If limit is <= 0, all failed entries are returned.
+func (h *HistoryDB) ListFailed(limit int) []Entry {
+	var failed []Entry
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if h.entries[i].Failed() {
+			failed = append(failed, h.entries[i])
+			if limit > 0 && len(failed) >= limit {
+				break
+			}
+		}
+	}
+	return failed
+}
+
 // Search returns all entries whose command or args contain the query string
 // (case-insensitive), most recent first.
 func (h *HistoryDB) Search(query string) []Entry {
@@ -0,0 +1,364 @@
+// Package jsonschema checks JSON values against a JSON Schema.
+//
+// It understands the keywords structured config values need: type, enum,
+// const, properties, required, additionalProperties, items, minItems,
+// maxItems, minLength, maxLength, pattern, minimum, maximum, allOf, anyOf,
+// oneOf and local $ref into definitions or $defs. Other keywords are
+// ignored, so a schema written for a complete validator is never stricter
+// here than there.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Schema is a parsed schema document.
+type Schema struct {
+	root any
+}
+
+// Parse reads a schema document.
+func Parse(data []byte) (*Schema, error) {
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	switch root.(type) {
+	case map[string]any, bool:
+	default:
+		return nil, fmt.Errorf("invalid schema: want an object or a boolean")
+	}
+	return &Schema{root: root}, nil
+}
+
+// Load reads a schema file.
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Validate checks a JSON document and returns its problems, each prefixed
+// with the JSON pointer of the value at fault. A document that is not JSON
+// is one problem.
+func (s *Schema) Validate(data []byte) []string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return []string{"not valid JSON: " + err.Error()}
+	}
+	if dec.More() {
+		return []string{"not valid JSON: more than one value"}
+	}
+	var problems []string
+	s.check(s.root, v, "", &problems, 0)
+	return problems
+}
+
+// maxRefDepth stops $ref cycles that never reach a value.
+const maxRefDepth = 32
+
+// check validates v against schema, appending to problems. depth counts
+// the $refs followed to get here.
+func (s *Schema) check(schema, v any, path string, problems *[]string, depth int) {
+	fail := func(format string, args ...any) {
+		at := path
+		if at == "" {
+			at = "/"
+		}
+		*problems = append(*problems, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	if b, ok := schema.(bool); ok {
+		if !b {
+			fail("no value is allowed here")
+		}
+		return
+	}
+	sc, ok := schema.(map[string]any)
+	if !ok {
+		return
+	}
+
+	if ref, ok := sc["$ref"].(string); ok {
+		target, err := s.resolve(ref)
+		if err != nil || depth >= maxRefDepth {
+			fail("cannot follow $ref %s", ref)
+			return
+		}
+		s.check(target, v, path, problems, depth+1)
+	}
+
+	if t, ok := sc["type"]; ok && !matchesType(t, v) {
+		fail("want %s, got %s", typeList(t), typeOf(v))
+		return
+	}
+	if enum, ok := sc["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			found = found || equal(e, v)
+		}
+		if !found {
+			fail("%s is not one of %s", short(v), short(enum))
+		}
+	}
+	if c, ok := sc["const"]; ok && !equal(c, v) {
+		fail("want %s, got %s", short(c), short(v))
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		s.checkObject(sc, val, path, problems, depth, fail)
+	case []any:
+		if n, ok := number(sc["minItems"]); ok && float64(len(val)) < n {
+			fail("want at least %v items, got %d", n, len(val))
+		}
+		if n, ok := number(sc["maxItems"]); ok && float64(len(val)) > n {
+			fail("want at most %v items, got %d", n, len(val))
+		}
+		if items, ok := sc["items"]; ok {
+			for i, item := range val {
+				s.check(items, item, fmt.Sprintf("%s/%d", path, i), problems, depth)
+			}
+		}
+	case string:
+		n := float64(len([]rune(val)))
+		if min, ok := number(sc["minLength"]); ok && n < min {
+			fail("want at least %v characters, got %v", min, n)
+		}
+		if max, ok := number(sc["maxLength"]); ok && n > max {
+			fail("want at most %v characters, got %v", max, n)
+		}
+		if p, ok := sc["pattern"].(string); ok {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				fail("schema pattern %q does not compile", p)
+			} else if !re.MatchString(val) {
+				fail("%s does not match %s", short(val), p)
+			}
+		}
+	case json.Number:
+		f, _ := val.Float64()
+		if min, ok := number(sc["minimum"]); ok && f < min {
+			fail("want at least %v, got %s", min, val)
+		}
+		if max, ok := number(sc["maximum"]); ok && f > max {
+			fail("want at most %v, got %s", max, val)
+		}
+	}
+
+	if all, ok := sc["allOf"].([]any); ok {
+		for _, sub := range all {
+			s.check(sub, v, path, problems, depth)
+		}
+	}
+	if anyOf, ok := sc["anyOf"].([]any); ok && s.passing(anyOf, v, depth) == 0 {
+		fail("matches none of the anyOf schemas")
+	}
+	if one, ok := sc["oneOf"].([]any); ok {
+		if n := s.passing(one, v, depth); n != 1 {
+			fail("matches %d of the oneOf schemas, want exactly 1", n)
+		}
+	}
+}
+
+func (s *Schema) checkObject(sc, val map[string]any, path string, problems *[]string, depth int, fail func(string, ...any)) {
+	if req, ok := sc["required"].([]any); ok {
+		for _, r := range req {
+			if name, ok := r.(string); ok {
+				if _, present := val[name]; !present {
+					fail("missing required property %q", name)
+				}
+			}
+		}
+	}
+	props, _ := sc["properties"].(map[string]any)
+	additional, hasAdditional := sc["additionalProperties"]
+	keys := make([]string, 0, len(val))
+	for k := range val {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		child := path + "/" + escapePointer(k)
+		if p, ok := props[k]; ok {
+			s.check(p, val[k], child, problems, depth)
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if b, ok := additional.(bool); ok && !b {
+			fail("unexpected property %q", k)
+			continue
+		}
+		s.check(additional, val[k], child, problems, depth)
+	}
+}
+
+// passing counts the schemas v satisfies.
+func (s *Schema) passing(schemas []any, v any, depth int) int {
+	n := 0
+	for _, sub := range schemas {
+		var p []string
+		s.check(sub, v, "", &p, depth)
+		if len(p) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// resolve follows a local reference such as "#/definitions/rule".
+func (s *Schema) resolve(ref string) (any, error) {
+	if ref == "#" {
+		return s.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("only local references are supported")
+	}
+	node := s.root
+	for _, part := range strings.Split(ref[2:], "/") {
+		part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("no %s", ref)
+		}
+		if node, ok = m[part]; !ok {
+			return nil, fmt.Errorf("no %s", ref)
+		}
+	}
+	return node, nil
+}
+
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// matchesType checks the type keyword, a name or a list of names.
+func matchesType(t, v any) bool {
+	switch tt := t.(type) {
+	case string:
+		return isType(tt, v)
+	case []any:
+		for _, name := range tt {
+			if s, ok := name.(string); ok && isType(s, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isType(name string, v any) bool {
+	got := typeOf(v)
+	switch name {
+	case "number":
+		return got == "number" || got == "integer"
+	case "integer":
+		if n, ok := v.(json.Number); ok {
+			f, err := n.Float64()
+			return err == nil && f == math.Trunc(f)
+		}
+		return false
+	}
+	return got == name
+}
+
+func typeOf(v any) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if _, err := n.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func typeList(t any) string {
+	if list, ok := t.([]any); ok {
+		var names []string
+		for _, n := range list {
+			names = append(names, fmt.Sprint(n))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// number reads a numeric keyword. Schemas are decoded without UseNumber,
+// so keywords are float64.
+func number(v any) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+// equal compares a schema value (plain float64 numbers) with a document
+// value (json.Number), structurally.
+func equal(a, b any) bool {
+	return canonical(a) == canonical(b)
+}
+
+func canonical(v any) string {
+	data, _ := json.Marshal(normalize(v))
+	return string(data)
+}
+
+func normalize(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		f, err := t.Float64()
+		if err != nil {
+			return t.String()
+		}
+		return f
+	case []any:
+		out := make([]any, len(t))
+		for i, e := range t {
+			out[i] = normalize(e)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, e := range t {
+			out[k] = normalize(e)
+		}
+		return out
+	}
+	return v
+}
+
+// short renders a value for a message, cut to a readable length.
+func short(v any) string {
+	s := canonical(v)
+	if len(s) > 60 {
+		s = s[:57] + "..."
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package jsonschema
+
+import (
+	"reflect"
+	"testing"
+)
+
+const flagSchema = `{
+  "type": "object",
+  "required": ["enabled"],
+  "additionalProperties": false,
+  "properties": {
+    "enabled": {"type": "boolean"},
+    "percent": {"type": "integer", "minimum": 0, "maximum": 100},
+    "owner": {"type": "string", "pattern": "^[a-z-]+$"},
+    "tenants": {"type": "array", "items": {"type": "string", "minLength": 1}, "maxItems": 2},
+    "rules": {"type": "array", "items": {"$ref": "#/$defs/rule"}}
+  },
+  "$defs": {
+    "rule": {
+      "type": "object",
+      "required": ["attribute"],
+      "properties": {
+        "attribute": {"enum": ["tenant", "tier"]},
+        "value": {"oneOf": [{"type": "string"}, {"type": "number"}]}
+      }
+    }
+  }
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(flagSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		doc  string
+		want []string
+	}{
+		{`{"enabled": true, "percent": 25, "owner": "growth"}`, nil},
+		{`{"enabled": true, "rules": [{"attribute": "tier", "value": 2}]}`, nil},
+		{`{"percent": 10}`, []string{`/: missing required property "enabled"`}},
+		{`{"enabled": "yes"}`, []string{"/enabled: want boolean, got string"}},
+		{`{"enabled": true, "percent": 150}`, []string{"/percent: want at most 100, got 150"}},
+		{`{"enabled": true, "percent": 2.5}`, []string{"/percent: want integer, got number"}},
+		{`{"enabled": true, "owner": "Ops"}`, []string{`/owner: "Ops" does not match ^[a-z-]+$`}},
+		{`{"enabled": true, "tenants": ["a", "", "c"]}`, []string{
+			"/tenants: want at most 2 items, got 3",
+			"/tenants/1: want at least 1 characters, got 0",
+		}},
+		{`{"enabled": true, "extra": 1}`, []string{`/: unexpected property "extra"`}},
+		{`{"enabled": true, "rules": [{"attribute": "region", "value": true}]}`, []string{
+			`/rules/0/attribute: "region" is not one of ["tenant","tier"]`,
+			"/rules/0/value: matches 0 of the oneOf schemas, want exactly 1",
+		}},
+		{`{"enabled": true`, []string{"not valid JSON: unexpected EOF"}},
+		{`true`, []string{"/: want object, got boolean"}},
+	}
+	for _, tt := range tests {
+		if got := s.Validate([]byte(tt.doc)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Validate(%s) = %q, want %q", tt.doc, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	if _, err := Parse([]byte(`[1]`)); err == nil {
+		t.Error("an array schema parsed")
+	}
+	s, err := Parse([]byte(`true`))
+	if err != nil || len(s.Validate([]byte(`{"any": 1}`))) != 0 {
+		t.Errorf("the true schema rejected a value: %v", err)
+	}
+	s, _ = Parse([]byte(`{"$ref": "#/nowhere"}`))
+	if got := s.Validate([]byte(`1`)); len(got) != 1 {
+		t.Errorf("dangling $ref = %q", got)
+	}
+	s, _ = Parse([]byte(`{"$ref": "#"}`))
+	if got := s.Validate([]byte(`1`)); len(got) != 1 {
+		t.Errorf("self $ref = %q", got)
+	}
+}
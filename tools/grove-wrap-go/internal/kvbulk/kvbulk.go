@@ -0,0 +1,199 @@
+// Package kvbulk is the export file format and comparison logic behind
+// gw kv export, import and diff.
+//
+// An export is a JSON object holding where it came from and a list of
+// entries. Entries use the field names wrangler's bulk put reads, so the
+// list can be handed to `wrangler kv bulk put` as it is.
+package kvbulk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Entry is one key with its value, metadata and expiration.
+type Entry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Base64 is set when Value holds base64 of a value that is not UTF-8.
+	Base64     bool            `json:"base64,omitempty"`
+	Expiration int64           `json:"expiration,omitempty"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+}
+
+// NewEntry builds an entry, encoding value as base64 when it is not text.
+// A JSON null metadata is dropped.
+func NewEntry(key string, value []byte, expiration int64, metadata json.RawMessage) Entry {
+	e := Entry{Key: key, Expiration: expiration}
+	if utf8.Valid(value) {
+		e.Value = string(value)
+	} else {
+		e.Value, e.Base64 = base64.StdEncoding.EncodeToString(value), true
+	}
+	if m := bytes.TrimSpace(metadata); len(m) > 0 && string(m) != "null" {
+		e.Metadata = m
+	}
+	return e
+}
+
+// Bytes returns the entry's value, decoding base64.
+func (e Entry) Bytes() ([]byte, error) {
+	if e.Base64 {
+		return base64.StdEncoding.DecodeString(e.Value)
+	}
+	return []byte(e.Value), nil
+}
+
+// Dump is an export file.
+type Dump struct {
+	Namespace   string  `json:"namespace"`
+	NamespaceID string  `json:"namespace_id"`
+	Prefix      string  `json:"prefix,omitempty"`
+	ExportedAt  string  `json:"exported_at"`
+	Keys        []Entry `json:"keys"`
+}
+
+// Read parses an export file. A bare JSON array of entries, the file
+// wrangler bulk put takes, is accepted too. Keys must be unique.
+func Read(r io.Reader) (*Dump, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var d Dump
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &d.Keys)
+	} else {
+		err = json.Unmarshal(trimmed, &d)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("not a KV export: %w", err)
+	}
+
+	seen := make(map[string]bool, len(d.Keys))
+	for i, e := range d.Keys {
+		if e.Key == "" {
+			return nil, fmt.Errorf("entry %d has no key", i+1)
+		}
+		if seen[e.Key] {
+			return nil, fmt.Errorf("key %q appears more than once", e.Key)
+		}
+		seen[e.Key] = true
+		if _, err := e.Bytes(); err != nil {
+			return nil, fmt.Errorf("key %q: invalid base64 value", e.Key)
+		}
+	}
+	return &d, nil
+}
+
+// Filter returns the entries whose key starts with prefix.
+func Filter(entries []Entry, prefix string) []Entry {
+	if prefix == "" {
+		return entries
+	}
+	var out []Entry
+	for _, e := range entries {
+		if strings.HasPrefix(e.Key, prefix) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Change is one key that differs between two sets of entries.
+type Change struct {
+	Op     string   `json:"op"` // add, delete, change
+	Key    string   `json:"key"`
+	Fields []string `json:"fields,omitempty"` // for change: value, metadata, expiration
+	From   *Entry   `json:"-"`
+	To     *Entry   `json:"-"`
+}
+
+// Diff lists what it takes to turn from into to, sorted by key: keys only
+// in to are added, keys only in from deleted, and keys in both whose
+// value, metadata or expiration differ changed.
+func Diff(from, to []Entry) []Change {
+	before := make(map[string]*Entry, len(from))
+	for i := range from {
+		before[from[i].Key] = &from[i]
+	}
+	after := make(map[string]*Entry, len(to))
+	for i := range to {
+		after[to[i].Key] = &to[i]
+	}
+
+	var changes []Change
+	for i := range to {
+		b, a := before[to[i].Key], &to[i]
+		if b == nil {
+			changes = append(changes, Change{Op: "add", Key: a.Key, To: a})
+			continue
+		}
+		if fields := differs(b, a); len(fields) > 0 {
+			changes = append(changes, Change{Op: "change", Key: a.Key, Fields: fields, From: b, To: a})
+		}
+	}
+	for i := range from {
+		if after[from[i].Key] == nil {
+			changes = append(changes, Change{Op: "delete", Key: from[i].Key, From: &from[i]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// Count tallies changes by op.
+func Count(changes []Change) (add, change, del int) {
+	for _, c := range changes {
+		switch c.Op {
+		case "add":
+			add++
+		case "change":
+			change++
+		case "delete":
+			del++
+		}
+	}
+	return add, change, del
+}
+
+// differs names the fields in which a and b differ.
+func differs(a, b *Entry) []string {
+	var fields []string
+	av, _ := a.Bytes()
+	bv, _ := b.Bytes()
+	if !bytes.Equal(av, bv) {
+		fields = append(fields, "value")
+	}
+	if !sameJSON(a.Metadata, b.Metadata) {
+		fields = append(fields, "metadata")
+	}
+	if a.Expiration != b.Expiration {
+		fields = append(fields, "expiration")
+	}
+	return fields
+}
+
+// sameJSON compares two JSON documents by value, so key order and spacing
+// do not count. Missing and null are the same.
+func sameJSON(a, b json.RawMessage) bool {
+	norm := func(m json.RawMessage) string {
+		if len(bytes.TrimSpace(m)) == 0 {
+			return "null"
+		}
+		var v any
+		if json.Unmarshal(m, &v) != nil {
+			return string(m)
+		}
+		out, _ := json.Marshal(v)
+		return string(out)
+	}
+	return norm(a) == norm(b)
+}
@@ -0,0 +1,93 @@
+package kvbulk
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewEntry(t *testing.T) {
+	e := NewEntry("k", []byte("hello"), 0, json.RawMessage(" null "))
+	if e.Value != "hello" || e.Base64 || e.Metadata != nil {
+		t.Errorf("text entry = %+v", e)
+	}
+
+	bin := []byte{0xff, 0x00, 0xfe}
+	e = NewEntry("b", bin, 1700000000, json.RawMessage(`{"a":1}`))
+	if !e.Base64 || e.Value != "/wD+" {
+		t.Errorf("binary entry = %+v", e)
+	}
+	if got, err := e.Bytes(); err != nil || string(got) != string(bin) {
+		t.Errorf("Bytes() = %v, %v", got, err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	d, err := Read(strings.NewReader(`{"namespace":"cache","keys":[{"key":"a","value":"1"},{"key":"b","value":"2","expiration":5}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Namespace != "cache" || len(d.Keys) != 2 || d.Keys[1].Expiration != 5 {
+		t.Errorf("Read = %+v", d)
+	}
+
+	// The bare array wrangler bulk put reads
+	d, err = Read(strings.NewReader(`[{"key":"a","value":"1"}]`))
+	if err != nil || len(d.Keys) != 1 {
+		t.Errorf("Read(array) = %+v, %v", d, err)
+	}
+
+	for _, bad := range []string{
+		`{"keys":[{"key":"a","value":"1"},{"key":"a","value":"2"}]}`,
+		`{"keys":[{"value":"1"}]}`,
+		`{"keys":[{"key":"a","value":"!!","base64":true}]}`,
+		`not json`,
+	} {
+		if _, err := Read(strings.NewReader(bad)); err == nil {
+			t.Errorf("Read(%s) should fail", bad)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	from := []Entry{
+		{Key: "same", Value: "x", Metadata: json.RawMessage(`{"a": 1, "b": 2}`)},
+		{Key: "value", Value: "old"},
+		{Key: "meta", Value: "v", Metadata: json.RawMessage(`{"v":1}`)},
+		{Key: "gone", Value: "bye"},
+	}
+	to := []Entry{
+		{Key: "same", Value: "x", Metadata: json.RawMessage(`{"b":2,"a":1}`)},
+		{Key: "value", Value: "new", Expiration: 10},
+		{Key: "meta", Value: "v"},
+		{Key: "added", Value: "hi"},
+	}
+	changes := Diff(from, to)
+
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Op+":"+c.Key+":"+strings.Join(c.Fields, "+"))
+	}
+	want := []string{"add:added:", "delete:gone:", "change:meta:metadata", "change:value:value+expiration"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Diff = %v, want %v", got, want)
+	}
+
+	add, change, del := Count(changes)
+	if add != 1 || change != 2 || del != 1 {
+		t.Errorf("Count = %d, %d, %d", add, change, del)
+	}
+	if len(Diff(to, to)) != 0 {
+		t.Error("a set should not differ from itself")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	entries := []Entry{{Key: "user:1"}, {Key: "post:1"}, {Key: "user:2"}}
+	if got := Filter(entries, "user:"); len(got) != 2 || got[1].Key != "user:2" {
+		t.Errorf("Filter = %+v", got)
+	}
+	if got := Filter(entries, ""); len(got) != 3 {
+		t.Errorf("Filter with no prefix = %+v", got)
+	}
+}
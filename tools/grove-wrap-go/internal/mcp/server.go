@@ -0,0 +1,266 @@
+// Package mcp implements a Model Context Protocol server over stdio.
+//
+// Messages are JSON-RPC 2.0 objects, one per line. The server answers
+// initialize, ping, tools/list, and tools/call; everything else gets a
+// method-not-found error. Tool calls run concurrently and can be cancelled
+// with notifications/cancelled.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LatestProtocolVersion is offered to clients that ask for a version this
+// server does not know.
+const LatestProtocolVersion = "2025-06-18"
+
+// supportedVersions are the protocol revisions the server can speak.
+var supportedVersions = map[string]bool{
+	"2025-06-18": true,
+	"2025-03-26": true,
+	"2024-11-05": true,
+}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// maxMessageBytes bounds a single incoming message.
+const maxMessageBytes = 8 << 20
+
+// Tool describes one callable tool.
+type Tool struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	InputSchema map[string]any   `json:"inputSchema"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations are hints about a tool's behaviour.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint"`
+	DestructiveHint bool   `json:"destructiveHint"`
+	OpenWorldHint   bool   `json:"openWorldHint"`
+}
+
+// Content is one block of a tool result.
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// ToolResult is the outcome of a tool call. IsError marks a failure the
+// model should see, as opposed to a protocol error.
+type ToolResult struct {
+	Content           []Content      `json:"content"`
+	StructuredContent map[string]any `json:"structuredContent,omitempty"`
+	IsError           bool           `json:"isError,omitempty"`
+}
+
+// TextResult returns a result with a single text block.
+func TextResult(text string, isError bool) *ToolResult {
+	return &ToolResult{Content: []Content{{Type: "text", Text: text}}, IsError: isError}
+}
+
+// CallFunc runs a tool. A returned error becomes a JSON-RPC error; failures
+// of the tool itself belong in a ToolResult with IsError set.
+type CallFunc func(ctx context.Context, name string, args map[string]any) (*ToolResult, error)
+
+// Server answers MCP requests for a fixed set of tools.
+type Server struct {
+	Name         string
+	Version      string
+	Instructions string
+	Tools        []Tool
+	Call         CallFunc
+
+	mu      sync.Mutex // guards out
+	out     *json.Encoder
+	pending sync.Map // request id -> context.CancelFunc
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve reads requests from r and writes responses to w until r reaches EOF
+// or ctx is cancelled. In-flight tool calls are cancelled and waited for
+// before it returns.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.out = json.NewEncoder(w)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageBytes)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			s.reply(json.RawMessage("null"), nil, &rpcError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		if msg.JSONRPC != "2.0" || msg.Method == "" {
+			// Responses to requests we never send, or malformed input
+			if msg.Method == "" && len(msg.ID) > 0 {
+				continue
+			}
+			s.reply(idOrNull(msg.ID), nil, &rpcError{Code: codeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"})
+			continue
+		}
+
+		if msg.Method == "tools/call" && len(msg.ID) > 0 {
+			callCtx, callCancel := context.WithCancel(ctx)
+			s.pending.Store(string(msg.ID), callCancel)
+			wg.Add(1)
+			go func(msg message) {
+				defer wg.Done()
+				defer s.pending.Delete(string(msg.ID))
+				defer callCancel()
+				result, rerr := s.callTool(callCtx, msg.Params)
+				s.reply(msg.ID, result, rerr)
+			}(msg)
+			continue
+		}
+
+		result, rerr := s.handle(msg)
+		if len(msg.ID) > 0 {
+			s.reply(msg.ID, result, rerr)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading MCP input: %w", err)
+	}
+	return nil
+}
+
+// handle answers every method except tools/call.
+func (s *Server) handle(msg message) (any, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		version := params.ProtocolVersion
+		if !supportedVersions[version] {
+			version = LatestProtocolVersion
+		}
+		result := map[string]any{
+			"protocolVersion": version,
+			"capabilities": map[string]any{
+				"tools": map[string]any{"listChanged": false},
+			},
+			"serverInfo": map[string]any{"name": s.Name, "version": s.Version},
+		}
+		if s.Instructions != "" {
+			result["instructions"] = s.Instructions
+		}
+		return result, nil
+
+	case "ping":
+		return map[string]any{}, nil
+
+	case "tools/list":
+		return map[string]any{"tools": s.Tools}, nil
+
+	case "notifications/cancelled":
+		var params struct {
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		if json.Unmarshal(msg.Params, &params) == nil {
+			if cancel, ok := s.pending.Load(string(params.RequestID)); ok {
+				cancel.(context.CancelFunc)()
+			}
+		}
+		return nil, nil
+
+	case "notifications/initialized":
+		return nil, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", msg.Method)}
+}
+
+// callTool validates a tools/call request and runs it.
+func (s *Server) callTool(ctx context.Context, raw json.RawMessage) (any, *rpcError) {
+	var params struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	if !s.hasTool(params.Name) {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool %q", params.Name)}
+	}
+	if params.Arguments == nil {
+		params.Arguments = map[string]any{}
+	}
+
+	result, err := s.Call(ctx, params.Name, params.Arguments)
+	if err != nil {
+		return nil, &rpcError{Code: codeInternalError, Message: err.Error()}
+	}
+	return result, nil
+}
+
+func (s *Server) hasTool(name string) bool {
+	for _, t := range s.Tools {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reply writes one response. Encoding errors are dropped: the client is
+// gone if stdout cannot be written.
+func (s *Server) reply(id json.RawMessage, result any, rerr *rpcError) {
+	resp := response{JSONRPC: "2.0", ID: id, Result: result, Error: rerr}
+	if rerr == nil && result == nil {
+		resp.Result = map[string]any{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.out.Encode(resp)
+}
+
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
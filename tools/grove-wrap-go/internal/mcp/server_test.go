@@ -0,0 +1,133 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func serve(t *testing.T, s *Server, lines ...string) map[string]map[string]any {
+	t.Helper()
+	var out bytes.Buffer
+	if err := s.Serve(context.Background(), strings.NewReader(strings.Join(lines, "\n")), &out); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+
+	replies := map[string]map[string]any{}
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var resp map[string]any
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		id, _ := json.Marshal(resp["id"])
+		replies[string(id)] = resp
+	}
+	return replies
+}
+
+func testServer() *Server {
+	return &Server{
+		Name:    "gw",
+		Version: "test",
+		Tools:   []Tool{{Name: "echo", InputSchema: map[string]any{"type": "object"}}},
+		Call: func(ctx context.Context, name string, args map[string]any) (*ToolResult, error) {
+			text, _ := args["text"].(string)
+			return TextResult(text, text == ""), nil
+		},
+	}
+}
+
+func TestServeInitializeNegotiatesVersion(t *testing.T) {
+	replies := serve(t, testServer(),
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+	)
+	if len(replies) != 2 {
+		t.Fatalf("got %d replies, want 2 (notifications get none)", len(replies))
+	}
+	if v := replies["1"]["result"].(map[string]any)["protocolVersion"]; v != "2024-11-05" {
+		t.Errorf("protocolVersion = %v, want the client's", v)
+	}
+	if v := replies["2"]["result"].(map[string]any)["protocolVersion"]; v != LatestProtocolVersion {
+		t.Errorf("protocolVersion = %v, want %s for an unknown version", v, LatestProtocolVersion)
+	}
+}
+
+func TestServeToolsListAndCall(t *testing.T) {
+	replies := serve(t, testServer(),
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo"}}`,
+		`{"jsonrpc":"2.0","id":"s","method":"tools/call","params":{"name":"missing"}}`,
+	)
+
+	tools := replies["1"]["result"].(map[string]any)["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["name"] != "echo" {
+		t.Errorf("tools/list = %v", tools)
+	}
+
+	ok := replies["2"]["result"].(map[string]any)
+	if ok["isError"] != nil || ok["content"].([]any)[0].(map[string]any)["text"] != "hi" {
+		t.Errorf("call result = %v", ok)
+	}
+	if failed := replies["3"]["result"].(map[string]any); failed["isError"] != true {
+		t.Errorf("tool failure should set isError: %v", failed)
+	}
+	if rerr, _ := replies[`"s"`]["error"].(map[string]any); rerr["code"] != float64(codeInvalidParams) {
+		t.Errorf("unknown tool should be invalid params: %v", replies[`"s"`])
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	replies := serve(t, testServer(),
+		`not json`,
+		`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`,
+		`{"jsonrpc":"1.0","id":2,"method":"ping"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"ping"}`,
+	)
+	codes := map[string]float64{"null": codeParseError, "1": codeMethodNotFound, "2": codeInvalidRequest}
+	for id, want := range codes {
+		rerr, _ := replies[id]["error"].(map[string]any)
+		if rerr["code"] != want {
+			t.Errorf("reply %s = %v, want error %v", id, replies[id], want)
+		}
+	}
+	if replies["3"]["error"] != nil {
+		t.Errorf("ping failed: %v", replies["3"])
+	}
+}
+
+func TestServeCancelledCall(t *testing.T) {
+	started := make(chan struct{})
+	s := testServer()
+	s.Call = func(ctx context.Context, name string, args map[string]any) (*ToolResult, error) {
+		close(started)
+		<-ctx.Done()
+		return TextResult("cancelled", true), nil
+	}
+
+	r, w := io.Pipe()
+	done := make(chan map[string]any)
+	go func() {
+		var out bytes.Buffer
+		_ = s.Serve(context.Background(), r, &out)
+		var resp map[string]any
+		_ = json.Unmarshal(out.Bytes(), &resp)
+		done <- resp
+	}()
+
+	w.Write([]byte(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"echo"}}` + "\n"))
+	<-started
+	w.Write([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}` + "\n"))
+	w.Close()
+
+	resp := <-done
+	if resp["result"].(map[string]any)["isError"] != true {
+		t.Errorf("cancelled call = %v", resp)
+	}
+}
@@ -0,0 +1,170 @@
+// Package migrations compares a D1 migrations directory with the
+// d1_migrations table wrangler keeps, and with the content hash gw records
+// for each migration as it applies it, so a file edited after it ran can
+// be flagged.
+//
+// Hashes live in the database itself, in a table next to d1_migrations,
+// so everyone who checks a database compares against the same record:
+//
+//	gw_migration_checksums(name, sha256, recorded_at)
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Migration states.
+const (
+	StatusApplied  = "applied"
+	StatusPending  = "pending"
+	StatusMissing  = "missing"  // recorded as applied, file no longer exists
+	StatusModified = "modified" // applied, but the file changed since
+)
+
+// File is a migration file on disk.
+type File struct {
+	Name   string
+	Path   string
+	SHA256 string
+}
+
+// Applied is a row of the d1_migrations table.
+type Applied struct {
+	ID        int
+	Name      string
+	AppliedAt string
+}
+
+// Migration is the status of one migration.
+type Migration struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	AppliedAt string `json:"applied_at,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+	// RecordedSHA256 is the hash recorded when the migration was applied;
+	// it differs from SHA256 for modified migrations.
+	RecordedSHA256 string `json:"recorded_sha256,omitempty"`
+	// Unrecorded is true for an applied migration with no hash on record,
+	// one applied by wrangler directly or before gw recorded hashes.
+	Unrecorded bool `json:"unrecorded,omitempty"`
+}
+
+// ListFiles returns the .sql files in dir, in the order wrangler applies
+// them, with their content hashes.
+func ListFiles(dir string) ([]File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		files = append(files, File{Name: e.Name(), Path: path, SHA256: hex.EncodeToString(sum[:])})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// Checksum is a recorded migration hash.
+type Checksum struct {
+	SHA256     string `json:"sha256"`
+	RecordedAt string `json:"recorded_at"`
+}
+
+// ChecksumTable is the D1 table recorded hashes live in.
+const ChecksumTable = "gw_migration_checksums"
+
+// ChecksumQuery selects every recorded hash.
+const ChecksumQuery = "SELECT name, sha256, recorded_at FROM " + ChecksumTable
+
+// RecordSQL returns the statements that create the checksum table if
+// needed and record files' hashes. A migration already on record keeps
+// its first hash, so re-recording never hides an edit.
+func RecordSQL(files []File, now time.Time) string {
+	var b strings.Builder
+	b.WriteString("CREATE TABLE IF NOT EXISTS " + ChecksumTable +
+		" (name TEXT PRIMARY KEY, sha256 TEXT NOT NULL, recorded_at TEXT NOT NULL);")
+	at := now.UTC().Format(time.RFC3339)
+	for _, f := range files {
+		fmt.Fprintf(&b, "\nINSERT OR IGNORE INTO %s (name, sha256, recorded_at) VALUES ('%s', '%s', '%s');",
+			ChecksumTable, strings.ReplaceAll(f.Name, "'", "''"), f.SHA256, at)
+	}
+	return b.String()
+}
+
+// Pending returns the files not in applied.
+func Pending(files []File, applied []Applied) []File {
+	done := map[string]bool{}
+	for _, a := range applied {
+		done[a.Name] = true
+	}
+	var pending []File
+	for _, f := range files {
+		if !done[f.Name] {
+			pending = append(pending, f)
+		}
+	}
+	return pending
+}
+
+// Check compares the files with the applied rows and the recorded hashes.
+// Applied migrations come first in the order they ran, then pending ones
+// in file order.
+func Check(files []File, applied []Applied, recorded map[string]Checksum) []Migration {
+	byName := map[string]File{}
+	for _, f := range files {
+		byName[f.Name] = f
+	}
+
+	sorted := append([]Applied(nil), applied...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	var out []Migration
+	for _, a := range sorted {
+		m := Migration{Name: a.Name, Status: StatusApplied, AppliedAt: a.AppliedAt}
+		c, ok := recorded[a.Name]
+		m.RecordedSHA256 = c.SHA256
+		f, exists := byName[a.Name]
+		switch {
+		case !exists:
+			m.Status = StatusMissing
+		case !ok:
+			m.SHA256, m.Unrecorded = f.SHA256, true
+		case c.SHA256 != f.SHA256:
+			m.SHA256, m.Status = f.SHA256, StatusModified
+		default:
+			m.SHA256 = f.SHA256
+		}
+		out = append(out, m)
+	}
+	for _, f := range Pending(files, applied) {
+		out = append(out, Migration{Name: f.Name, Status: StatusPending, SHA256: f.SHA256})
+	}
+	return out
+}
+
+// Count returns how many migrations have status.
+func Count(ms []Migration, status string) int {
+	n := 0
+	for _, m := range ms {
+		if m.Status == status {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeMigrations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestListFiles(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"002_b.sql": "B",
+		"001_a.sql": "A",
+		"README.md": "not a migration",
+	})
+	files, err := ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Name != "001_a.sql" || files[1].Name != "002_b.sql" {
+		t.Fatalf("ListFiles() = %+v", files)
+	}
+	if files[0].SHA256 == files[1].SHA256 || len(files[0].SHA256) != 64 {
+		t.Errorf("unexpected hashes %q, %q", files[0].SHA256, files[1].SHA256)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"001_a.sql": "A",
+		"002_b.sql": "B",
+		"003_c.sql": "C",
+	})
+	files, _ := ListFiles(dir)
+	applied := []Applied{
+		{ID: 2, Name: "002_b.sql", AppliedAt: "2026-01-02"},
+		{ID: 1, Name: "001_a.sql", AppliedAt: "2026-01-01"},
+		{ID: 3, Name: "000_gone.sql", AppliedAt: "2026-01-03"},
+	}
+	recorded := map[string]Checksum{
+		"001_a.sql":    {SHA256: files[0].SHA256},
+		"000_gone.sql": {SHA256: "abc"},
+	}
+
+	ms := Check(files, applied, recorded)
+	want := []struct{ name, status string }{
+		{"001_a.sql", StatusApplied},
+		{"002_b.sql", StatusApplied},
+		{"000_gone.sql", StatusMissing},
+		{"003_c.sql", StatusPending},
+	}
+	if len(ms) != len(want) {
+		t.Fatalf("Check() = %+v", ms)
+	}
+	for i, w := range want {
+		if ms[i].Name != w.name || ms[i].Status != w.status {
+			t.Errorf("Check()[%d] = %s %s, want %s %s", i, ms[i].Name, ms[i].Status, w.name, w.status)
+		}
+	}
+	if ms[0].Unrecorded || !ms[1].Unrecorded {
+		t.Errorf("only 002_b.sql has no hash on record: %+v", ms[:2])
+	}
+	if ms[2].RecordedSHA256 != "abc" {
+		t.Errorf("missing migration should keep its recorded hash, got %+v", ms[2])
+	}
+
+	// Edit an applied migration
+	os.WriteFile(filepath.Join(dir, "001_a.sql"), []byte("A, edited"), 0o644)
+	files, _ = ListFiles(dir)
+	ms = Check(files, applied, recorded)
+	if ms[0].Status != StatusModified || ms[0].SHA256 == ms[0].RecordedSHA256 {
+		t.Errorf("edited migration = %+v, want modified with both hashes", ms[0])
+	}
+	if Count(ms, StatusModified) != 1 || Count(ms, StatusPending) != 1 {
+		t.Errorf("counts = modified %d, pending %d", Count(ms, StatusModified), Count(ms, StatusPending))
+	}
+}
+
+func TestRecordSQL(t *testing.T) {
+	files := []File{{Name: "001_o'brien.sql", SHA256: "aa"}, {Name: "002_b.sql", SHA256: "bb"}}
+	if got := Pending(files, []Applied{{Name: "001_o'brien.sql"}}); len(got) != 1 || got[0].Name != "002_b.sql" {
+		t.Errorf("Pending() = %+v", got)
+	}
+	sql := RecordSQL(files, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS gw_migration_checksums",
+		"INSERT OR IGNORE INTO gw_migration_checksums (name, sha256, recorded_at) VALUES ('001_o''brien.sql', 'aa', '2026-10-01T00:00:00Z');",
+		"VALUES ('002_b.sql', 'bb',",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("RecordSQL() missing %q:\n%s", want, sql)
+		}
+	}
+}
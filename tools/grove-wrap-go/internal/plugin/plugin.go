@@ -0,0 +1,378 @@
+// Package plugin discovers external gw-<name> commands and their manifests.
+//
+// A plugin is an executable named gw-<name> in ~/.grove/plugins/ or on
+// PATH (the plugin directory wins). Beside it sits a TOML manifest,
+// gw-<name>.toml, declaring the safety tier of each subcommand:
+//
+//	description  = "Preview environments"
+//	default_tier = "read"          # for anything not listed; omit to refuse
+//	stdin_config = false           # true: resolved config as JSON on stdin
+//
+//	[[commands]]
+//	name        = "create"
+//	description = "Create a preview"
+//	tier        = "write"
+//	operation   = "preview_create" # defaults to <plugin>_<name>
+//
+// A manifest may also live at ~/.grove/plugins/<name>.toml. Plugins
+// without a valid manifest are reported but never run.
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+)
+
+// Prefix is the executable name prefix that marks a plugin.
+const Prefix = "gw-"
+
+// validName matches plugin and subcommand names.
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Manifest is the parsed gw-<name>.toml.
+type Manifest struct {
+	Description string    `toml:"description"`
+	DefaultTier string    `toml:"default_tier"`
+	StdinConfig bool      `toml:"stdin_config"`
+	Commands    []Command `toml:"commands"`
+}
+
+// Command is one declared subcommand. Name may be several words
+// ("db migrate") for nested subcommands.
+type Command struct {
+	Name        string `toml:"name" json:"name"`
+	Description string `toml:"description" json:"description,omitempty"`
+	Tier        string `toml:"tier" json:"tier"`
+	Operation   string `toml:"operation" json:"operation"`
+
+	tier safety.Tier
+}
+
+// Plugin is a discovered plugin executable.
+type Plugin struct {
+	Name         string
+	Path         string
+	ManifestPath string
+	Manifest     *Manifest
+	// Err explains why the plugin cannot run: missing or invalid manifest.
+	Err error
+
+	defaultTier *safety.Tier
+}
+
+// Invocation is a plugin call resolved against its manifest.
+type Invocation struct {
+	Command   string // declared subcommand, "" when the default tier applied
+	Operation string
+	Tier      safety.Tier
+}
+
+// Discover finds plugins in pluginDir and then each directory of pathDirs.
+// When a name appears twice, the first one found is kept.
+func Discover(pluginDir string, pathDirs []string) []*Plugin {
+	seen := map[string]bool{}
+	var plugins []*Plugin
+
+	dirs := append([]string{pluginDir}, pathDirs...)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := pluginName(e.Name())
+			if !ok || seen[name] {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, load(name, path, pluginDir))
+		}
+	}
+
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// pluginName extracts <name> from gw-<name>[.exe].
+func pluginName(file string) (string, bool) {
+	if !strings.HasPrefix(file, Prefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(file, Prefix)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, ".exe")
+	}
+	if !validName.MatchString(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// isExecutable reports whether path is a regular file that can be run.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.HasSuffix(path, ".exe")
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
+
+// load reads the manifest for the plugin at path.
+func load(name, path, pluginDir string) *Plugin {
+	p := &Plugin{Name: name, Path: path}
+
+	candidates := []string{strings.TrimSuffix(path, ".exe") + ".toml"}
+	if pluginDir != "" {
+		candidates = append(candidates, filepath.Join(pluginDir, name+".toml"))
+	}
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err == nil {
+			p.ManifestPath = c
+			break
+		}
+	}
+	if p.ManifestPath == "" {
+		p.Err = fmt.Errorf("no manifest (expected %s)", candidates[0])
+		return p
+	}
+
+	data, err := os.ReadFile(p.ManifestPath)
+	if err != nil {
+		p.Err = fmt.Errorf("cannot read manifest: %w", err)
+		return p
+	}
+	m, err := ParseManifest(name, data)
+	if err != nil {
+		p.Err = err
+		return p
+	}
+	p.Manifest = m
+	if m.DefaultTier != "" {
+		tier, _ := safety.ParseTier(m.DefaultTier)
+		p.defaultTier = &tier
+	}
+	return p
+}
+
+// ParseManifest parses and validates a manifest for the named plugin,
+// filling in default operation names.
+func ParseManifest(name string, data []byte) (*Manifest, error) {
+	var m Manifest
+	if _, err := toml.Decode(string(data), &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.DefaultTier != "" {
+		if _, err := safety.ParseTier(m.DefaultTier); err != nil {
+			return nil, fmt.Errorf("default_tier: %w", err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i := range m.Commands {
+		c := &m.Commands[i]
+		c.Name = strings.Join(strings.Fields(c.Name), " ")
+		for _, word := range strings.Fields(c.Name) {
+			if !validName.MatchString(word) {
+				return nil, fmt.Errorf("command %q: invalid name", c.Name)
+			}
+		}
+		if c.Name == "" {
+			return nil, fmt.Errorf("command %d has no name", i+1)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("command %q declared twice", c.Name)
+		}
+		seen[c.Name] = true
+
+		if c.Tier == "" {
+			return nil, fmt.Errorf("command %q: tier is required", c.Name)
+		}
+		tier, err := safety.ParseTier(c.Tier)
+		if err != nil {
+			return nil, fmt.Errorf("command %q: %w", c.Name, err)
+		}
+		c.tier = tier
+
+		if c.Operation == "" {
+			c.Operation = operationName(name, c.Name)
+		}
+	}
+	return &m, nil
+}
+
+// operationName derives "<plugin>_<command>" with non-alphanumerics as "_".
+func operationName(plugin, command string) string {
+	op := plugin + "_" + command
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, op)
+}
+
+// Description returns the manifest description, or a placeholder.
+func (p *Plugin) Description() string {
+	if p.Manifest != nil && p.Manifest.Description != "" {
+		return p.Manifest.Description
+	}
+	return "External plugin " + Prefix + p.Name
+}
+
+// Register records every declared operation's tier with the safety
+// package, so policy explain and tier lookups know about them.
+func (p *Plugin) Register() error {
+	if p.Manifest == nil {
+		return nil
+	}
+	for _, c := range p.Manifest.Commands {
+		if err := safety.RegisterPluginOperation(c.Operation, c.tier); err != nil {
+			return fmt.Errorf("command %q: %w", c.Name, err)
+		}
+	}
+	if p.defaultTier != nil {
+		if err := safety.RegisterPluginOperation(p.defaultOperation(), *p.defaultTier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultOperation names calls that fall through to default_tier.
+func (p *Plugin) defaultOperation() string {
+	return operationName(p.Name, "default")
+}
+
+// Resolve matches args against the declared subcommands. The subcommand
+// is the run of positional words, flags skipped; the longest declared name
+// that prefixes it wins. The manifest does not say which flags take a
+// value, so when a flag could be swallowing the next word, both readings
+// are resolved and the higher tier applies. If one reading names an
+// undeclared subcommand while another matches, the strictest declared
+// subcommand applies. Anything undeclared runs at default_tier, or is
+// refused when the manifest has none.
+func (p *Plugin) Resolve(args []string) (*Invocation, error) {
+	if p.Err != nil {
+		return nil, fmt.Errorf("plugin %s cannot run: %w", p.Name, p.Err)
+	}
+
+	longest := 1
+	for _, c := range p.Manifest.Commands {
+		longest = max(longest, len(strings.Fields(c.Name)))
+	}
+
+	var chosen *Invocation
+	undeclared := ""
+	for _, words := range subcommandReadings(args, longest) {
+		inv := p.match(words)
+		if inv == nil {
+			if len(words) > 0 && undeclared == "" {
+				undeclared = words[0]
+			}
+			continue
+		}
+		if chosen == nil || inv.Tier > chosen.Tier {
+			chosen = inv
+		}
+	}
+	if chosen != nil && undeclared != "" {
+		if strictest := p.strictest(); strictest.Tier > chosen.Tier {
+			chosen = strictest
+		}
+	}
+	if chosen != nil {
+		return chosen, nil
+	}
+
+	sub := "(none)"
+	if undeclared != "" {
+		sub = undeclared
+	}
+	return nil, fmt.Errorf("plugin %s does not declare subcommand %s in %s (add it, or set default_tier)",
+		p.Name, sub, p.ManifestPath)
+}
+
+// strictest returns the declared subcommand with the highest tier. The
+// manifest must declare at least one.
+func (p *Plugin) strictest() *Invocation {
+	var best *Command
+	for i := range p.Manifest.Commands {
+		if c := &p.Manifest.Commands[i]; best == nil || c.tier > best.tier {
+			best = c
+		}
+	}
+	return &Invocation{Command: best.Name, Operation: best.Operation, Tier: best.tier}
+}
+
+// match resolves one reading's subcommand words, or returns nil when
+// nothing declared matches and there is no default tier.
+func (p *Plugin) match(words []string) *Invocation {
+	var best *Command
+	for i := range p.Manifest.Commands {
+		c := &p.Manifest.Commands[i]
+		parts := strings.Fields(c.Name)
+		if len(parts) > len(words) || (best != nil && len(parts) <= len(strings.Fields(best.Name))) {
+			continue
+		}
+		if strings.Join(words[:len(parts)], " ") == c.Name {
+			best = c
+		}
+	}
+	if best != nil {
+		return &Invocation{Command: best.Name, Operation: best.Operation, Tier: best.tier}
+	}
+	if p.defaultTier != nil {
+		return &Invocation{Operation: p.defaultOperation(), Tier: *p.defaultTier}
+	}
+	return nil
+}
+
+// subcommandReadings returns every way args can yield positional words,
+// keeping at most limit of them. A flag without "=" followed by a word may
+// be a switch or take that word as its value, so both are tried; "--" ends
+// the flags.
+func subcommandReadings(args []string, limit int) [][]string {
+	var readings [][]string
+	var walk func(i int, words []string)
+	walk = func(i int, words []string) {
+		if len(words) == limit || i == len(args) {
+			readings = append(readings, words)
+			return
+		}
+		a := args[i]
+		switch {
+		case a == "--":
+			rest := args[i+1:]
+			n := min(len(rest), limit-len(words))
+			readings = append(readings, append(words[:len(words):len(words)], rest[:n]...))
+		case strings.HasPrefix(a, "-") && a != "-":
+			walk(i+1, words)
+			if !strings.Contains(a, "=") && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				walk(i+2, words)
+			}
+		default:
+			walk(i+1, append(words[:len(words):len(words)], a))
+		}
+	}
+	walk(0, nil)
+	return readings
+}
@@ -0,0 +1,189 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/safety"
+)
+
+func writePlugin(t *testing.T, dir, name, manifest string) string {
+	t.Helper()
+	path := filepath.Join(dir, Prefix+name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if manifest != "" {
+		if err := os.WriteFile(path+".toml", []byte(manifest), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestDiscover(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("executable bit not meaningful on windows")
+	}
+	pluginDir := t.TempDir()
+	pathDir := t.TempDir()
+
+	writePlugin(t, pluginDir, "preview", `default_tier = "read"`)
+	writePlugin(t, pathDir, "preview", `default_tier = "write"`)
+	writePlugin(t, pathDir, "bare", "")
+	// Not executable, not a plugin
+	os.WriteFile(filepath.Join(pathDir, "gw-notes"), []byte("x"), 0o644)
+	os.WriteFile(filepath.Join(pathDir, "other"), []byte("x"), 0o755)
+
+	plugins := Discover(pluginDir, []string{pathDir, ""})
+	if len(plugins) != 2 {
+		t.Fatalf("Discover() found %d plugins, want 2", len(plugins))
+	}
+	if plugins[0].Name != "bare" || plugins[1].Name != "preview" {
+		t.Errorf("Discover() names = %s, %s; want bare, preview", plugins[0].Name, plugins[1].Name)
+	}
+	if plugins[0].Err == nil {
+		t.Error("plugin without manifest should carry an error")
+	}
+	if filepath.Dir(plugins[1].Path) != pluginDir {
+		t.Errorf("plugin dir should win over PATH, got %s", plugins[1].Path)
+	}
+	if plugins[1].Manifest.DefaultTier != "read" {
+		t.Errorf("DefaultTier = %q, want read", plugins[1].Manifest.DefaultTier)
+	}
+}
+
+func TestDiscoverManifestInPluginDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("executable bit not meaningful on windows")
+	}
+	pluginDir := t.TempDir()
+	pathDir := t.TempDir()
+	writePlugin(t, pathDir, "deploy", "")
+	os.WriteFile(filepath.Join(pluginDir, "deploy.toml"), []byte(`default_tier = "dangerous"`), 0o644)
+
+	plugins := Discover(pluginDir, []string{pathDir})
+	if len(plugins) != 1 || plugins[0].Err != nil {
+		t.Fatalf("Discover() = %+v", plugins)
+	}
+	if plugins[0].ManifestPath != filepath.Join(pluginDir, "deploy.toml") {
+		t.Errorf("ManifestPath = %s", plugins[0].ManifestPath)
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	m, err := ParseManifest("preview", []byte(`
+description = "Preview environments"
+
+[[commands]]
+name = "create"
+tier = "write"
+
+[[commands]]
+name = "db  reset"
+tier = "dangerous"
+operation = "preview_wipe"
+`))
+	if err != nil {
+		t.Fatalf("ParseManifest() error: %v", err)
+	}
+	if m.Commands[0].Operation != "preview_create" {
+		t.Errorf("default operation = %q, want preview_create", m.Commands[0].Operation)
+	}
+	if m.Commands[1].Name != "db reset" || m.Commands[1].Operation != "preview_wipe" {
+		t.Errorf("second command = %+v", m.Commands[1])
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     string
+	}{
+		{"bad toml", `description = `, "invalid manifest"},
+		{"bad default tier", `default_tier = "yolo"`, "default_tier"},
+		{"missing tier", "[[commands]]\nname = \"up\"", "tier is required"},
+		{"bad tier", "[[commands]]\nname = \"up\"\ntier = \"huge\"", `command "up"`},
+		{"no name", "[[commands]]\ntier = \"read\"", "has no name"},
+		{"bad name", "[[commands]]\nname = \"Up!\"\ntier = \"read\"", "invalid name"},
+		{"duplicate", "[[commands]]\nname = \"up\"\ntier = \"read\"\n[[commands]]\nname = \"up\"\ntier = \"write\"", "declared twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifest("p", []byte(tt.manifest))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseManifest() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	m, err := ParseManifest("preview", []byte(`
+[[commands]]
+name = "db"
+tier = "read"
+
+[[commands]]
+name = "db reset"
+tier = "dangerous"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{Name: "preview", Manifest: m, ManifestPath: "gw-preview.toml"}
+
+	tests := []struct {
+		args    []string
+		command string
+		tier    safety.Tier
+	}{
+		{[]string{"db"}, "db", safety.TierRead},
+		{[]string{"db", "reset", "--yes"}, "db reset", safety.TierDangerous},
+		{[]string{"db", "--reset", "reset"}, "db reset", safety.TierDangerous},
+		{[]string{"--profile", "staging", "db", "reset"}, "db reset", safety.TierDangerous},
+		{[]string{"-v", "db", "--env=prod", "reset"}, "db reset", safety.TierDangerous},
+		{[]string{"db", "--", "reset"}, "db reset", safety.TierDangerous},
+		{[]string{"db", "--name", "other"}, "db", safety.TierRead},
+		{[]string{"--x", "deploy", "db"}, "db reset", safety.TierDangerous},
+	}
+	for _, tt := range tests {
+		inv, err := p.Resolve(tt.args)
+		if err != nil {
+			t.Errorf("Resolve(%v) error: %v", tt.args, err)
+			continue
+		}
+		if inv.Command != tt.command || inv.Tier != tt.tier {
+			t.Errorf("Resolve(%v) = %q/%v, want %q/%v", tt.args, inv.Command, inv.Tier, tt.command, tt.tier)
+		}
+	}
+
+	if _, err := p.Resolve([]string{"deploy"}); err == nil {
+		t.Error("undeclared subcommand without default_tier should be refused")
+	}
+	if _, err := p.Resolve([]string{"--x", "deploy"}); err == nil {
+		t.Error("undeclared subcommand after a flag should be refused")
+	}
+
+	write := safety.TierWrite
+	p.defaultTier = &write
+	inv, err := p.Resolve([]string{"deploy"})
+	if err != nil || inv.Tier != safety.TierWrite || inv.Operation != "preview_default" {
+		t.Errorf("Resolve(deploy) with default tier = %+v, %v", inv, err)
+	}
+}
+
+func TestRegisterRejectsBuiltin(t *testing.T) {
+	m, err := ParseManifest("kv", []byte("[[commands]]\nname = \"get\"\ntier = \"read\""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{Name: "kv", Manifest: m}
+	if err := p.Register(); err == nil {
+		t.Error("Register() should refuse to redefine built-in kv_get")
+	}
+}
@@ -0,0 +1,282 @@
+// Package r2sync plans rsync-style transfers between a local directory
+// and an R2 prefix.
+//
+// Files are compared by size, then by MD5 when the object's ETag is one.
+// R2 gives single-part uploads an ETag that is the MD5 of the content;
+// multipart uploads get "<md5 of part md5s>-<parts>", which cannot be
+// compared with a file, so for those equal sizes count as unchanged.
+package r2sync
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// File is a local file, Rel being its slash-separated path under the
+// synced directory.
+type File struct {
+	Rel  string
+	Path string
+	Size int64
+}
+
+// Object is a remote object, Rel being its key under the synced prefix.
+type Object struct {
+	Rel  string
+	Size int64
+	ETag string
+}
+
+// Action is one step of a plan.
+type Action struct {
+	Op     string `json:"op"` // upload, download, delete
+	Rel    string `json:"path"`
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"` // new, size, checksum, extra
+}
+
+// Filter selects paths by glob. A path is kept when it matches an include
+// pattern (or there are none) and no exclude pattern.
+//
+// Patterns use * and ? within one path segment and ** across segments.
+// A pattern without a slash matches a name at any depth, like .gitignore:
+// "*.map" matches "js/app.js.map". A pattern that matches a directory
+// matches everything under it.
+type Filter struct {
+	include, exclude []*regexp.Regexp
+}
+
+// NewFilter compiles include and exclude patterns.
+func NewFilter(include, exclude []string) (*Filter, error) {
+	f := &Filter{}
+	for _, p := range include {
+		re, err := compileGlob(p)
+		if err != nil {
+			return nil, err
+		}
+		f.include = append(f.include, re)
+	}
+	for _, p := range exclude {
+		re, err := compileGlob(p)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, re)
+	}
+	return f, nil
+}
+
+// Match reports whether rel is kept.
+func (f *Filter) Match(rel string) bool {
+	if f == nil {
+		return true
+	}
+	for _, re := range f.exclude {
+		if matchPath(re, rel) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if matchPath(re, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath matches rel or any directory above it.
+func matchPath(re *regexp.Regexp, rel string) bool {
+	for p := rel; p != ""; {
+		if re.MatchString(p) {
+			return true
+		}
+		i := strings.LastIndex(p, "/")
+		if i < 0 {
+			break
+		}
+		p = p[:i]
+	}
+	return false
+}
+
+// compileGlob turns a glob into an anchored regular expression.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	p := strings.Trim(pattern, "/")
+	if p == "" {
+		return nil, fmt.Errorf("empty glob pattern %q", pattern)
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	if !strings.Contains(p, "/") {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// LocalPath is where rel lives under dir. Remote keys are not trusted: a
+// rel that is absolute, has a ".." segment or otherwise resolves outside
+// dir is an error.
+func LocalPath(dir, rel string) (string, error) {
+	native := filepath.FromSlash(rel)
+	if rel == "" || strings.HasPrefix(rel, "/") || filepath.IsAbs(native) || filepath.VolumeName(native) != "" {
+		return "", fmt.Errorf("unsafe path %q: must be relative", rel)
+	}
+	for _, seg := range strings.Split(filepath.ToSlash(native), "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("unsafe path %q: contains ..", rel)
+		}
+	}
+	local := filepath.Join(dir, native)
+	within, err := filepath.Rel(filepath.Clean(dir), local)
+	if err != nil || within == ".." || strings.HasPrefix(within, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("unsafe path %q: outside %s", rel, dir)
+	}
+	return local, nil
+}
+
+// Walk lists the regular files under dir that filter keeps, sorted.
+// Symlinks to files are followed; symlinked directories are not.
+func Walk(dir string, filter *Filter) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !filter.Match(rel) {
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		files = append(files, File{Rel: rel, Path: path, Size: info.Size()})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Rel < files[j].Rel })
+	return files, err
+}
+
+// FileMD5 returns the hex MD5 of a file.
+func FileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// contentMD5 returns the MD5 an ETag carries, or "" for a multipart ETag.
+func contentMD5(etag string) string {
+	etag = strings.ToLower(strings.Trim(etag, `"`))
+	if len(etag) != 32 {
+		return ""
+	}
+	if _, err := hex.DecodeString(etag); err != nil {
+		return ""
+	}
+	return etag
+}
+
+// Plan works out what turns the destination into a copy of the source.
+// upload picks the direction: files are sources for upload, objects for
+// download. With del set, destination entries the source lacks are
+// deleted. hash returns a file's MD5 and is only called when sizes match
+// and the object's ETag is comparable. unchanged counts paths left alone.
+func Plan(files []File, objects []Object, upload, del bool, hash func(File) (string, error)) (actions []Action, unchanged int, err error) {
+	remote := make(map[string]Object, len(objects))
+	for _, o := range objects {
+		remote[o.Rel] = o
+	}
+	local := make(map[string]bool, len(files))
+
+	transfer := "upload"
+	if !upload {
+		transfer = "download"
+	}
+	for _, f := range files {
+		local[f.Rel] = true
+		o, ok := remote[f.Rel]
+		size := f.Size
+		if !upload {
+			size = o.Size
+		}
+		switch {
+		case !ok:
+			if upload {
+				actions = append(actions, Action{Op: "upload", Rel: f.Rel, Size: f.Size, Reason: "new"})
+			} else if del {
+				actions = append(actions, Action{Op: "delete", Rel: f.Rel, Size: f.Size, Reason: "extra"})
+			}
+		case o.Size != f.Size:
+			actions = append(actions, Action{Op: transfer, Rel: f.Rel, Size: size, Reason: "size"})
+		case contentMD5(o.ETag) != "":
+			sum, err := hash(f)
+			if err != nil {
+				return nil, 0, fmt.Errorf("%s: %w", f.Rel, err)
+			}
+			if sum != contentMD5(o.ETag) {
+				actions = append(actions, Action{Op: transfer, Rel: f.Rel, Size: size, Reason: "checksum"})
+			} else {
+				unchanged++
+			}
+		default:
+			unchanged++
+		}
+	}
+	for _, o := range objects {
+		if local[o.Rel] {
+			continue
+		}
+		if !upload {
+			actions = append(actions, Action{Op: "download", Rel: o.Rel, Size: o.Size, Reason: "new"})
+		} else if del {
+			actions = append(actions, Action{Op: "delete", Rel: o.Rel, Size: o.Size, Reason: "extra"})
+		}
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].Rel < actions[j].Rel })
+	return actions, unchanged, nil
+}
@@ -0,0 +1,136 @@
+package r2sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	f, err := NewFilter([]string{"*.js", "images/**"}, []string{"*.map", "node_modules", "images/raw/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"app.js":                  true,
+		"js/vendor/app.js":        true,
+		"js/app.js.map":           false,
+		"node_modules/x/index.js": false,
+		"images/a/b.png":          true,
+		"images/raw/b.png":        false,
+		"images/raw/deep/b.png":   false,
+		"style.css":               false,
+	}
+	for path, want := range tests {
+		if got := f.Match(path); got != want {
+			t.Errorf("Match(%q) = %v, want %v", path, got, want)
+		}
+	}
+
+	var none *Filter
+	if !none.Match("anything") {
+		t.Error("a nil filter should keep everything")
+	}
+	if _, err := NewFilter([]string{"/"}, nil); err == nil {
+		t.Error("an empty pattern should be rejected")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"index.html", "css/site.css", "css/site.css.map", ".DS_Store"} {
+		path := filepath.Join(dir, p)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(p), 0644)
+	}
+	f, _ := NewFilter(nil, []string{"*.map", ".DS_Store"})
+	files, err := Walk(dir, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rels []string
+	for _, file := range files {
+		rels = append(rels, file.Rel)
+	}
+	if got := strings.Join(rels, ","); got != "css/site.css,index.html" {
+		t.Errorf("Walk = %s", got)
+	}
+	if files[1].Size != int64(len("index.html")) {
+		t.Errorf("size = %d", files[1].Size)
+	}
+
+	sum, err := FileMD5(files[1].Path)
+	if err != nil || sum != "eacf331f0ffc35d4b482f1d15a887d3b" {
+		t.Errorf("FileMD5 = %s, %v", sum, err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	files := []File{
+		{Rel: "same.txt", Size: 3},
+		{Rel: "edited.txt", Size: 3},
+		{Rel: "grown.txt", Size: 10},
+		{Rel: "multipart.bin", Size: 100},
+		{Rel: "local-only.txt", Size: 1},
+	}
+	objects := []Object{
+		{Rel: "same.txt", Size: 3, ETag: `"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"`},
+		{Rel: "edited.txt", Size: 3, ETag: "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"},
+		{Rel: "grown.txt", Size: 5, ETag: "cccccccccccccccccccccccccccccccc"},
+		{Rel: "multipart.bin", Size: 100, ETag: "dddddddddddddddddddddddddddddddd-2"},
+		{Rel: "remote-only.txt", Size: 7},
+	}
+	hashed := 0
+	hash := func(f File) (string, error) {
+		hashed++
+		return strings.Repeat("a", 32), nil
+	}
+
+	render := func(actions []Action) string {
+		var out []string
+		for _, a := range actions {
+			out = append(out, a.Op+":"+a.Rel+":"+a.Reason)
+		}
+		return strings.Join(out, " ")
+	}
+
+	actions, unchanged, err := Plan(files, objects, true, false, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "upload:edited.txt:checksum upload:grown.txt:size upload:local-only.txt:new"
+	if got := render(actions); got != want {
+		t.Errorf("upload plan = %s, want %s", got, want)
+	}
+	if unchanged != 2 || hashed != 2 {
+		t.Errorf("unchanged = %d, hashed = %d; want 2, 2", unchanged, hashed)
+	}
+
+	actions, _, _ = Plan(files, objects, true, true, hash)
+	if got := render(actions); !strings.Contains(got, "delete:remote-only.txt:extra") {
+		t.Errorf("upload --delete plan = %s", got)
+	}
+
+	actions, _, _ = Plan(files, objects, false, true, hash)
+	want = "download:edited.txt:checksum download:grown.txt:size delete:local-only.txt:extra download:remote-only.txt:new"
+	if got := render(actions); got != want {
+		t.Errorf("download plan = %s, want %s", got, want)
+	}
+	if actions[1].Size != 5 {
+		t.Errorf("a download should be sized by the object, got %d", actions[1].Size)
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	dir := t.TempDir()
+	got, err := LocalPath(dir, "css/site.css")
+	if err != nil || got != filepath.Join(dir, "css", "site.css") {
+		t.Errorf("LocalPath = %q, %v", got, err)
+	}
+	for _, rel := range []string{"", "/etc/passwd", "../escape", "a/../../escape", "a/..", ".."} {
+		if got, err := LocalPath(dir, rel); err == nil {
+			t.Errorf("LocalPath(%q) = %q, want an error", rel, got)
+		}
+	}
+}
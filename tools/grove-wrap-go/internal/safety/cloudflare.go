@@ -1,54 +1,76 @@
 package safety
 
+import "fmt"
+
 // Cloudflare operation → safety tier mapping.
 // Ported from Python gw's safety model for Wrangler commands.
 var cloudflareOperationTiers = map[string]Tier{
 	// Tier 0: Read operations (always safe)
-	"d1_list":       TierRead,
-	"d1_tables":     TierRead,
-	"d1_schema":     TierRead,
-	"d1_query_read": TierRead,
-	"kv_list":       TierRead,
-	"kv_keys":       TierRead,
-	"kv_get":        TierRead,
-	"r2_list":       TierRead,
-	"r2_ls":         TierRead,
-	"r2_get":        TierRead,
-	"deploy_dry":    TierRead,
-	"logs_tail":     TierRead,
-	"flag_list":     TierRead,
-	"flag_get":      TierRead,
-	"backup_list":   TierRead,
-	"backup_download": TierRead,
-	"do_list":       TierRead,
-	"do_info":       TierRead,
-	"do_alarm":      TierRead,
-	"email_status":  TierRead,
-	"email_rules":   TierRead,
+	"d1_list":              TierRead,
+	"d1_tables":            TierRead,
+	"d1_schema":            TierRead,
+	"d1_query_read":        TierRead,
+	"d1_diff":              TierRead,
+	"d1_migrations_status": TierRead,
+	"d1_export":            TierRead,
+	"d1_shell":             TierRead,
+	"kv_list":              TierRead,
+	"kv_keys":              TierRead,
+	"kv_get":               TierRead,
+	"kv_export":            TierRead,
+	"kv_diff":              TierRead,
+	"r2_list":              TierRead,
+	"r2_ls":                TierRead,
+	"r2_get":               TierRead,
+	"deploy_dry":           TierRead,
+	"logs_tail":            TierRead,
+	"flag_list":            TierRead,
+	"flag_get":             TierRead,
+	"flag_eval":            TierRead,
+	"flag_history":         TierRead,
+	"flag_audit":           TierRead,
+	"backup_list":          TierRead,
+	"backup_download":      TierRead,
+	"backup_verify":        TierRead,
+	"backup_prune_plan":    TierRead,
+	"do_list":              TierRead,
+	"do_info":              TierRead,
+	"do_alarm":             TierRead,
+	"email_status":         TierRead,
+	"email_rules":          TierRead,
 
 	// Tier 1: Write operations (require --write)
-	"d1_query_write":  TierWrite,
-	"d1_migrate":      TierWrite,
-	"d1_migrate_all":  TierWrite,
+	"d1_query_write": TierWrite,
+	"d1_migrate":     TierWrite,
+	"d1_migrate_all": TierWrite,
+	"d1_import":      TierWrite,
 	"kv_put":         TierWrite,
 	"kv_delete":      TierWrite,
+	"kv_import":      TierWrite,
 	"r2_create":      TierWrite,
 	"r2_put":         TierWrite,
+	"r2_sync":        TierWrite,
 	"deploy":         TierWrite,
 	"flag_enable":    TierWrite,
 	"flag_disable":   TierWrite,
+	"flag_rollout":   TierWrite,
+	"flag_target":    TierWrite,
+	"flag_revert":    TierWrite,
 	"backup_create":  TierWrite,
 	"email_test":     TierWrite,
 
 	// Secret vault operations
 	"secret_list":     TierRead,
 	"secret_exists":   TierRead,
+	"secret_pubkey":   TierRead,
 	"secret_init":     TierWrite,
 	"secret_set":      TierWrite,
 	"secret_generate": TierWrite,
 	"secret_delete":   TierWrite,
 	"secret_apply":    TierWrite,
 	"secret_sync":     TierWrite,
+	"secret_rekey":    TierWrite,
+	"secret_receive":  TierWrite,
 
 	// Auth operations
 	"auth_check":         TierRead,
@@ -65,9 +87,11 @@ var cloudflareOperationTiers = map[string]Tier{
 	"tenant_create": TierWrite,
 
 	// Cache operations
-	"cache_list":  TierRead,
-	"cache_stats": TierRead,
-	"cache_purge": TierWrite,
+	"cache_list":          TierRead,
+	"cache_stats":         TierRead,
+	"cache_warm":          TierRead,
+	"cache_purge_preview": TierRead,
+	"cache_purge":         TierWrite,
 
 	// Export operations
 	"export_list":     TierRead,
@@ -86,10 +110,10 @@ var cloudflareOperationTiers = map[string]Tier{
 	"publish_npm": TierWrite,
 
 	// Warden operations
-	"warden_status":        TierRead,
-	"warden_test":          TierRead,
-	"warden_logs":          TierRead,
-	"warden_agent_list":    TierRead,
+	"warden_status":         TierRead,
+	"warden_test":           TierRead,
+	"warden_logs":           TierRead,
+	"warden_agent_list":     TierRead,
 	"warden_agent_register": TierWrite,
 
 	// Loft operations
@@ -117,12 +141,21 @@ var cloudflareOperationTiers = map[string]Tier{
 	"config_tenant_set": TierWrite,
 	"config_show":       TierRead,
 
+	// Undo journal
+	"undo_list": TierRead,
+	"undo":      TierWrite,
+
 	// Tier 2: Destructive operations (require --write + --force)
 	"lattice_posts_delete": TierDangerous,
 	"r2_rm":                TierDangerous,
+	"r2_sync_delete":       TierDangerous,
+	"kv_bulk_delete":       TierDangerous,
+	"d1_export_raw":        TierDangerous,
 	"flag_delete":          TierDangerous,
 	"backup_restore":       TierDangerous,
+	"backup_prune":         TierDangerous,
 	"secret_reveal":        TierDangerous,
+	"secret_share":         TierDangerous,
 	"auth_client_delete":   TierDangerous,
 	"tenant_delete":        TierDangerous,
 	"warden_agent_revoke":  TierDangerous,
@@ -139,14 +172,52 @@ func CloudflareOperationTier(operation string) Tier {
 
 // CheckCloudflareSafety validates a Cloudflare operation against safety rules.
 func CheckCloudflareSafety(operation string, writeFlag, forceFlag, agentMode, interactive bool) error {
+	return CheckCloudflareSafetyTarget(operation, Target{}, writeFlag, forceFlag, agentMode, interactive)
+}
+
+// CheckCloudflareSafetyTarget validates a Cloudflare operation against a
+// specific resource, so policy rules scoped by database, namespace, or
+// worker can apply.
+func CheckCloudflareSafetyTarget(operation string, target Target, writeFlag, forceFlag, agentMode, interactive bool) error {
 	tier := CloudflareOperationTier(operation)
 
-	return Check(CheckOpts{
+	return checkWithPolicy(CheckOpts{
 		Operation:   operation,
-		Tier:        tier,
 		WriteFlag:   writeFlag,
 		ForceFlag:   forceFlag,
 		AgentMode:   agentMode,
 		Interactive: interactive,
+	}, tier, target)
+}
+
+// CheckKVBulkDelete refuses a bulk KV delete of more than max keys from
+// the target namespace. Bulk deletes are already dangerous-tier, so unlike
+// the D1 row limits --force does not lift this ceiling; the caller raises
+// max explicitly instead.
+func CheckKVBulkDelete(opts CheckOpts, target Target, count, max int) error {
+	if count > max {
+		return reportRefusal(opts, target, fmt.Errorf("%d KV keys would be deleted, exceeding limit of %d. Raise safety.max_kv_delete_keys or pass --max-keys %d", count, max, count))
+	}
+	return nil
+}
+
+// reportRefusal reports a refusal by a content check — SQL validation, a
+// row or key limit — to the observer, so it is audited like a blocked tier
+// check, and returns err. The tier recorded is the operation's tier after
+// policy; opts.Tier is ignored.
+func reportRefusal(opts CheckOpts, target Target, err error) error {
+	base := CloudflareOperationTier(opts.Operation)
+	d := activePolicy.Resolve(opts.Operation, base, target)
+	notify(Evaluation{
+		Operation:   opts.Operation,
+		Tier:        d.Tier,
+		BaseTier:    base,
+		WriteFlag:   opts.WriteFlag,
+		ForceFlag:   opts.ForceFlag,
+		AgentMode:   opts.AgentMode,
+		Interactive: opts.Interactive,
+		Target:      target,
+		Err:         err,
 	})
+	return err
 }
@@ -2,20 +2,21 @@ package safety
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/sqlparse"
 )
 
 // ErrorCode identifies the type of database safety violation.
 type ErrorCode string
 
 const (
-	ErrDDLBlocked      ErrorCode = "DDL_BLOCKED"
+	ErrDDLBlocked       ErrorCode = "DDL_BLOCKED"
 	ErrDangerousPattern ErrorCode = "DANGEROUS_PATTERN"
-	ErrMissingWhere    ErrorCode = "MISSING_WHERE"
-	ErrProtectedTable  ErrorCode = "PROTECTED_TABLE"
-	ErrUnsafeDelete    ErrorCode = "UNSAFE_DELETE"
-	ErrUnsafeUpdate    ErrorCode = "UNSAFE_UPDATE"
+	ErrMissingWhere     ErrorCode = "MISSING_WHERE"
+	ErrProtectedTable   ErrorCode = "PROTECTED_TABLE"
+	ErrUnsafeDelete     ErrorCode = "UNSAFE_DELETE"
+	ErrUnsafeUpdate     ErrorCode = "UNSAFE_UPDATE"
 )
 
 // SQLSafetyError is returned when a SQL query violates safety rules.
@@ -29,17 +30,6 @@ func (e *SQLSafetyError) Error() string {
 	return fmt.Sprintf("[%s] %s", e.Code, e.Message)
 }
 
-// Pre-compiled regex patterns for SQL validation.
-var (
-	reStackedQueries = regexp.MustCompile(`;\s*[a-zA-Z]`)
-	reTableName      = regexp.MustCompile(`(?i)(?:FROM|UPDATE|INTO)\s+[` + "`" + `"]?(\w+)[` + "`" + `"]?`)
-	reLimitClause    = regexp.MustCompile(`(?i)LIMIT\s+(\d+)`)
-	reIDEquals       = regexp.MustCompile(`(?i)\bID\b\s*=\s*`)
-	reIDIn           = regexp.MustCompile(`(?i)\bID\b\s+IN\s*\(([^)]+)\)`)
-	reEqualityCond   = regexp.MustCompile(`(?i)\b[A-Z_][A-Z0-9_]*\b\s*=\s*`)
-	reWhereClause    = regexp.MustCompile(`(?i)WHERE\s+(.*?)(?:\s+ORDER\s+BY|\s+GROUP\s+BY|\s+LIMIT|\s*$)`)
-)
-
 // MaxQueryLength is the maximum allowed SQL query length (64KB).
 // Queries longer than this are rejected to prevent resource exhaustion.
 const MaxQueryLength = 65536
@@ -49,6 +39,13 @@ const MaxQueryLength = 65536
 const defaultRowEstimate = 10000
 
 // ValidateSQL checks a SQL query against database safety rules.
+//
+// The query is parsed rather than pattern-matched, so protected tables are
+// found wherever they appear — behind a CTE, in UPDATE ... FROM, in the
+// SELECT of an INSERT ... SELECT, in a subquery, or quoted — and keywords
+// inside string literals are never mistaken for structure.
+//
+// ValidateSQL does not report to the observer; CheckSQL does.
 func ValidateSQL(sql string, protectedTables []string, maxDeleteRows, maxUpdateRows int, skipRowLimits bool) error {
 	if len(sql) > MaxQueryLength {
 		return &SQLSafetyError{
@@ -58,11 +55,22 @@ func ValidateSQL(sql string, protectedTables []string, maxDeleteRows, maxUpdateR
 		}
 	}
 
-	upper := strings.TrimSpace(strings.ToUpper(sql))
-	operation := getSQLOperation(upper)
+	script, err := sqlparse.Parse(sql)
+	if err != nil {
+		return &SQLSafetyError{
+			Code:    ErrDangerousPattern,
+			Message: fmt.Sprintf("query could not be parsed: %v", err),
+			SQL:     sql,
+		}
+	}
+	if len(script.Statements) == 0 {
+		return nil
+	}
+	stmt := script.Statements[0]
+	operation := stmt.Kind
 
 	// Block DDL operations
-	if operation == "CREATE" || operation == "DROP" || operation == "ALTER" || operation == "TRUNCATE" {
+	if stmt.IsDDL() || operation == "ATTACH" || operation == "DETACH" || (operation == "PRAGMA" && stmt.Assigns) {
 		return &SQLSafetyError{
 			Code:    ErrDDLBlocked,
 			Message: fmt.Sprintf("%s operations are blocked for safety", operation),
@@ -70,8 +78,8 @@ func ValidateSQL(sql string, protectedTables []string, maxDeleteRows, maxUpdateR
 		}
 	}
 
-	// Check for dangerous patterns (stacked queries, comments)
-	if hasDangerousPatterns(sql) {
+	// Check for dangerous patterns (stacked queries, comments, compound selects)
+	if len(script.Statements) > 1 || script.HasComments || stmt.Compound {
 		return &SQLSafetyError{
 			Code:    ErrDangerousPattern,
 			Message: "query contains dangerous patterns (multiple statements, comments, etc.)",
@@ -79,8 +87,16 @@ func ValidateSQL(sql string, protectedTables []string, maxDeleteRows, maxUpdateR
 		}
 	}
 
+	if !stmt.IsMutation() {
+		return nil
+	}
+	if operation == "REPLACE" {
+		operation = "INSERT"
+	}
+	hasWhere := stmt.HasWhere && !stmt.WhereIsConstant()
+
 	// DELETE requires WHERE clause
-	if operation == "DELETE" && !hasWhereClause(upper) {
+	if operation == "DELETE" && !hasWhere {
 		return &SQLSafetyError{
 			Code:    ErrMissingWhere,
 			Message: "DELETE without WHERE clause is blocked",
@@ -88,21 +104,28 @@ func ValidateSQL(sql string, protectedTables []string, maxDeleteRows, maxUpdateR
 		}
 	}
 
-	// Check table protection for mutation operations
-	if operation == "DELETE" || operation == "UPDATE" || operation == "INSERT" {
-		tableName := extractTableName(sql)
-		if tableName != "" && isProtectedTable(tableName, protectedTables) {
-			// Protected tables with mutation need WHERE for DELETE/UPDATE
-			if (operation == "DELETE" || operation == "UPDATE") && !hasWhereClause(upper) {
-				return &SQLSafetyError{
-					Code:    ErrMissingWhere,
-					Message: fmt.Sprintf("%s on protected table '%s' without WHERE is blocked", operation, tableName),
-					SQL:     sql,
-				}
+	// Check table protection: the written table, then every table the
+	// statement reads while writing.
+	if stmt.Target != "" && isProtectedTable(stmt.Target, protectedTables) {
+		// Protected tables with mutation need WHERE for DELETE/UPDATE
+		if operation == "UPDATE" && !hasWhere {
+			return &SQLSafetyError{
+				Code:    ErrMissingWhere,
+				Message: fmt.Sprintf("%s on protected table '%s' without WHERE is blocked", operation, stmt.Target),
+				SQL:     sql,
 			}
+		}
+		return &SQLSafetyError{
+			Code:    ErrProtectedTable,
+			Message: fmt.Sprintf("table '%s' is protected", stmt.Target),
+			SQL:     sql,
+		}
+	}
+	for _, table := range stmt.Tables {
+		if isProtectedTable(table, protectedTables) {
 			return &SQLSafetyError{
 				Code:    ErrProtectedTable,
-				Message: fmt.Sprintf("table '%s' is protected", tableName),
+				Message: fmt.Sprintf("%s reads protected table '%s'", operation, table),
 				SQL:     sql,
 			}
 		}
@@ -111,7 +134,7 @@ func ValidateSQL(sql string, protectedTables []string, maxDeleteRows, maxUpdateR
 	// Row limit checks (unless --force)
 	if !skipRowLimits {
 		if operation == "DELETE" {
-			estimated := estimateRows(sql)
+			estimated := EstimateAffectedRows(stmt)
 			if estimated > maxDeleteRows {
 				return &SQLSafetyError{
 					Code:    ErrUnsafeDelete,
@@ -122,7 +145,7 @@ func ValidateSQL(sql string, protectedTables []string, maxDeleteRows, maxUpdateR
 		}
 
 		if operation == "UPDATE" {
-			estimated := estimateRows(sql)
+			estimated := EstimateAffectedRows(stmt)
 			if estimated > maxUpdateRows {
 				return &SQLSafetyError{
 					Code:    ErrUnsafeUpdate,
@@ -134,62 +157,48 @@ func ValidateSQL(sql string, protectedTables []string, maxDeleteRows, maxUpdateR
 	}
 
 	// UPDATE requires WHERE (unless LIMIT is provided)
-	if operation == "UPDATE" && !hasWhereClause(upper) {
-		if !strings.Contains(upper, "LIMIT") {
-			return &SQLSafetyError{
-				Code:    ErrMissingWhere,
-				Message: "UPDATE without WHERE clause is blocked",
-				SQL:     sql,
-			}
+	if operation == "UPDATE" && !hasWhere && !stmt.HasLimit {
+		return &SQLSafetyError{
+			Code:    ErrMissingWhere,
+			Message: "UPDATE without WHERE clause is blocked",
+			SQL:     sql,
 		}
 	}
 
 	return nil
 }
 
-// getSQLOperation returns the SQL operation type from the query.
-func getSQLOperation(upper string) string {
-	upper = strings.TrimSpace(upper)
-	for _, op := range []string{"SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "ALTER", "TRUNCATE"} {
-		if strings.HasPrefix(upper, op) {
-			return op
-		}
-	}
-	return "UNKNOWN"
-}
-
-// reUnionSelect detects UNION-based injection attempts.
-var reUnionSelect = regexp.MustCompile(`(?i)\bUNION\b\s+\bSELECT\b`)
-
-// hasDangerousPatterns checks for stacked queries, SQL comments, and injection patterns.
-func hasDangerousPatterns(sql string) bool {
-	// Stacked queries (semicolon followed by another statement)
-	if reStackedQueries.MatchString(sql) {
-		return true
-	}
-	// SQL comments that might hide malicious code
-	if strings.Contains(sql, "--") || strings.Contains(sql, "/*") {
-		return true
+// CheckSQL runs ValidateSQL on a query bound for the target database and
+// reports a refusal to the observer as a blocked opts.Operation. Allowed
+// queries are not reported; the tier check that follows records them.
+func CheckSQL(opts CheckOpts, target Target, sql string, protectedTables []string, maxDeleteRows, maxUpdateRows int, skipRowLimits bool) error {
+	if err := ValidateSQL(sql, protectedTables, maxDeleteRows, maxUpdateRows, skipRowLimits); err != nil {
+		return reportRefusal(opts, target, err)
 	}
-	// UNION SELECT injection
-	if reUnionSelect.MatchString(sql) {
-		return true
-	}
-	return false
-}
-
-// hasWhereClause checks if the query contains a WHERE keyword.
-func hasWhereClause(upper string) bool {
-	return strings.Contains(upper, "WHERE")
+	return nil
 }
 
-// extractTableName extracts the table name from a SQL query.
-func extractTableName(sql string) string {
-	m := reTableName.FindStringSubmatch(sql)
-	if len(m) > 1 {
-		return strings.ToLower(m[1])
+// CheckAffectedRows compares an exact affected-row count for a DELETE or
+// UPDATE statement against the configured limits and reports a refusal to
+// the observer. Other statements always pass.
+func CheckAffectedRows(opts CheckOpts, target Target, statement string, count, maxDeleteRows, maxUpdateRows int) error {
+	switch strings.ToUpper(statement) {
+	case "DELETE":
+		if count > maxDeleteRows {
+			return reportRefusal(opts, target, &SQLSafetyError{
+				Code:    ErrUnsafeDelete,
+				Message: fmt.Sprintf("%d rows would be deleted, exceeding limit of %d. Use --force to bypass row-limit checks.", count, maxDeleteRows),
+			})
+		}
+	case "UPDATE":
+		if count > maxUpdateRows {
+			return reportRefusal(opts, target, &SQLSafetyError{
+				Code:    ErrUnsafeUpdate,
+				Message: fmt.Sprintf("%d rows would be updated, exceeding limit of %d. Use --force to bypass row-limit checks.", count, maxUpdateRows),
+			})
+		}
 	}
-	return ""
+	return nil
 }
 
 // isProtectedTable checks if a table name is in the protected list.
@@ -205,61 +214,53 @@ func isProtectedTable(table string, protected []string) bool {
 
 // estimateRows estimates how many rows a query might affect.
 func estimateRows(sql string) int {
-	upper := strings.ToUpper(sql)
-
-	// Check for LIMIT clause
-	if strings.Contains(upper, "LIMIT") {
-		m := reLimitClause.FindStringSubmatch(upper)
-		if len(m) > 1 {
-			var n int
-			fmt.Sscanf(m[1], "%d", &n)
-			return n
-		}
-	}
-
-	if !strings.Contains(upper, "WHERE") {
+	script, err := sqlparse.Parse(sql)
+	if err != nil || len(script.Statements) == 0 {
 		return defaultRowEstimate
 	}
+	return EstimateAffectedRows(script.Statements[0])
+}
 
-	// Extract WHERE clause
-	m := reWhereClause.FindStringSubmatch(upper)
-	if len(m) < 2 {
-		return defaultRowEstimate
+// EstimateAffectedRows estimates how many rows a parsed statement might
+// affect from its LIMIT and top-level WHERE conjuncts. It is the fallback
+// when an exact count cannot be taken from the database. Only comparisons
+// against literals count; anything it cannot reason about falls back to
+// defaultRowEstimate.
+func EstimateAffectedRows(stmt *sqlparse.Statement) int {
+	if stmt.HasLimit && stmt.Limit >= 0 {
+		return stmt.Limit
 	}
-	where := m[1]
-
-	// WHERE id = X → 1 row
-	if reIDEquals.MatchString(where) {
-		return 1
+	if !stmt.HasWhere || stmt.WhereIsConstant() {
+		return defaultRowEstimate
 	}
 
-	// WHERE id IN (...) → count items
-	inMatch := reIDIn.FindStringSubmatch(where)
-	if len(inMatch) > 1 {
-		raw := strings.Split(inMatch[1], ",")
-		count := 0
-		for _, item := range raw {
-			if strings.TrimSpace(item) != "" {
-				count++
-			}
+	equalities := 0
+	for _, p := range stmt.Predicates {
+		if !p.Literal {
+			continue
 		}
-		if count == 0 {
-			count = 1
+		switch {
+		case p.Column == "id" && (p.Op == "=" || p.Op == "=="):
+			// WHERE id = X → 1 row
+			return 1
+		case p.Column == "id" && p.Op == "IN":
+			// WHERE id IN (...) → count items
+			if len(p.Values) == 0 {
+				return 1
+			}
+			return len(p.Values)
+		case p.Op == "=" || p.Op == "==":
+			equalities++
 		}
-		return count
 	}
 
-	// Count equality conditions
-	eqMatches := reEqualityCond.FindAllString(where, -1)
-	if len(eqMatches) > 0 {
-		count := len(eqMatches)
-		if count >= 3 {
-			return 1
-		} else if count == 2 {
-			return 10
-		}
+	switch {
+	case equalities >= 3:
+		return 1
+	case equalities == 2:
+		return 10
+	case equalities == 1:
 		return 100
 	}
-
 	return defaultRowEstimate
 }
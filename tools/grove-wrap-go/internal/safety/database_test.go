@@ -148,3 +148,192 @@ func TestEstimateRows(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateSQLAdversarialProtectedTables(t *testing.T) {
+	tests := []string{
+		"WITH x AS (SELECT 1) DELETE FROM users WHERE id = 1",
+		"WITH doomed AS (SELECT id FROM sessions) DELETE FROM posts WHERE id IN (SELECT id FROM doomed)",
+		"UPDATE posts SET author = u.name FROM users u WHERE u.id = posts.author_id",
+		"INSERT INTO posts (title) SELECT email FROM users",
+		`DELETE FROM "users" WHERE id = 1`,
+		"DELETE FROM [Users] WHERE id = 1",
+		"DELETE FROM `users` WHERE id = 1",
+		"DELETE FROM main.users WHERE id = 1",
+		"DELETE FROM posts WHERE author_id IN (SELECT id FROM users WHERE banned = 1)",
+		"UPDATE posts SET title = (SELECT email FROM users LIMIT 1) WHERE id = 1",
+		"INSERT OR REPLACE INTO tenants (id) VALUES (1)",
+		"REPLACE INTO payments (id) VALUES (1)",
+	}
+	for _, sql := range tests {
+		err := ValidateSQL(sql, defaultProtected, 100, 500, false)
+		sqlErr, ok := err.(*SQLSafetyError)
+		if !ok || sqlErr.Code != ErrProtectedTable {
+			t.Errorf("expected PROTECTED_TABLE for %q, got %v", sql, err)
+		}
+	}
+}
+
+func TestValidateSQLAllowsReadsOfProtectedTables(t *testing.T) {
+	err := ValidateSQL("WITH u AS (SELECT id FROM users) SELECT * FROM posts WHERE author_id IN (SELECT id FROM u)", defaultProtected, 100, 500, false)
+	if err != nil {
+		t.Errorf("reads should pass: %v", err)
+	}
+}
+
+func TestValidateSQLLiteralsAreNotStructure(t *testing.T) {
+	tests := []string{
+		"SELECT * FROM posts WHERE title = 'a; DROP TABLE users'",
+		"SELECT * FROM posts WHERE body = '-- not a comment'",
+		"UPDATE posts SET body = '/* hi */ union select' WHERE id = 1",
+		"INSERT INTO posts (title) VALUES ('from users')",
+	}
+	for _, sql := range tests {
+		if err := ValidateSQL(sql, defaultProtected, 100, 500, false); err != nil {
+			t.Errorf("%q should pass: %v", sql, err)
+		}
+	}
+}
+
+func TestValidateSQLBlocksStackedAfterLiteral(t *testing.T) {
+	err := ValidateSQL("SELECT 'x'; DELETE FROM posts WHERE id = 1", defaultProtected, 100, 500, false)
+	if sqlErr, ok := err.(*SQLSafetyError); !ok || sqlErr.Code != ErrDangerousPattern {
+		t.Errorf("expected DANGEROUS_PATTERN, got %v", err)
+	}
+}
+
+func TestValidateSQLTrailingSemicolonAllowed(t *testing.T) {
+	if err := ValidateSQL("SELECT * FROM posts;", defaultProtected, 100, 500, false); err != nil {
+		t.Errorf("single statement with trailing semicolon should pass: %v", err)
+	}
+}
+
+func TestValidateSQLConstantWhereIsMissing(t *testing.T) {
+	tests := []string{
+		"DELETE FROM posts WHERE 1=1",
+		"DELETE FROM posts WHERE 1",
+		"UPDATE posts SET title = 'x' WHERE 'a' = 'a'",
+	}
+	for _, sql := range tests {
+		err := ValidateSQL(sql, defaultProtected, 100, 500, true)
+		if sqlErr, ok := err.(*SQLSafetyError); !ok || sqlErr.Code != ErrMissingWhere {
+			t.Errorf("expected MISSING_WHERE for %q, got %v", sql, err)
+		}
+	}
+}
+
+func TestValidateSQLWhereInSubqueryDoesNotCount(t *testing.T) {
+	err := ValidateSQL("UPDATE posts SET title = (SELECT title FROM drafts WHERE drafts.id = 1)", defaultProtected, 100, 500, true)
+	if sqlErr, ok := err.(*SQLSafetyError); !ok || sqlErr.Code != ErrMissingWhere {
+		t.Errorf("expected MISSING_WHERE, got %v", err)
+	}
+}
+
+func TestValidateSQLBlocksCTEDeleteWithoutWhere(t *testing.T) {
+	err := ValidateSQL("WITH x AS (SELECT 1) DELETE FROM posts", defaultProtected, 100, 500, false)
+	if sqlErr, ok := err.(*SQLSafetyError); !ok || sqlErr.Code != ErrMissingWhere {
+		t.Errorf("expected MISSING_WHERE, got %v", err)
+	}
+}
+
+func TestValidateSQLBlocksSchemaChanges(t *testing.T) {
+	tests := []string{
+		"PRAGMA foreign_keys = OFF",
+		"PRAGMA foreign_keys(0)",
+		"PRAGMA writable_schema(1)",
+		"ATTACH DATABASE 'x.db' AS x",
+		"create temp table t (id)",
+	}
+	for _, sql := range tests {
+		err := ValidateSQL(sql, defaultProtected, 100, 500, false)
+		if sqlErr, ok := err.(*SQLSafetyError); !ok || sqlErr.Code != ErrDDLBlocked {
+			t.Errorf("expected DDL_BLOCKED for %q, got %v", sql, err)
+		}
+	}
+}
+
+func TestValidateSQLBlocksUnterminatedInput(t *testing.T) {
+	err := ValidateSQL("SELECT * FROM posts WHERE title = 'x", defaultProtected, 100, 500, false)
+	if sqlErr, ok := err.(*SQLSafetyError); !ok || sqlErr.Code != ErrDangerousPattern {
+		t.Errorf("expected DANGEROUS_PATTERN, got %v", err)
+	}
+}
+
+func TestEstimateRowsIgnoresUnsafeShapes(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want int
+	}{
+		{"DELETE FROM posts WHERE id = 1 OR 1 = 1", defaultRowEstimate},
+		{"DELETE FROM posts WHERE id = id", defaultRowEstimate},
+		{"DELETE FROM posts WHERE id IN (SELECT post_id FROM flags)", defaultRowEstimate},
+		{`DELETE FROM posts WHERE "ID" = 7`, 1},
+		{"DELETE FROM posts WHERE p.id = ?", 1},
+		{"DELETE FROM posts WHERE title = 'id = 1'", 100},
+	}
+	for _, tt := range tests {
+		if got := estimateRows(tt.sql); got != tt.want {
+			t.Errorf("estimateRows(%q) = %d, want %d", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestCheckAffectedRows(t *testing.T) {
+	tests := []struct {
+		op    string
+		count int
+		code  ErrorCode
+	}{
+		{"DELETE", 50, ""},
+		{"DELETE", 51, ErrUnsafeDelete},
+		{"UPDATE", 200, ""},
+		{"update", 201, ErrUnsafeUpdate},
+		{"INSERT", 100000, ""},
+	}
+	for _, tt := range tests {
+		err := CheckAffectedRows(CheckOpts{Operation: "d1_query_write"}, Target{}, tt.op, tt.count, 50, 200)
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("%s of %d rows should pass: %v", tt.op, tt.count, err)
+			}
+			continue
+		}
+		if sqlErr, ok := err.(*SQLSafetyError); !ok || sqlErr.Code != tt.code {
+			t.Errorf("%s of %d rows: expected %s, got %v", tt.op, tt.count, tt.code, err)
+		}
+	}
+}
+
+func TestContentRefusalsAreReported(t *testing.T) {
+	var got []Evaluation
+	SetObserver(func(e Evaluation) { got = append(got, e) })
+	defer SetObserver(nil)
+
+	opts := CheckOpts{Operation: "d1_query_write", WriteFlag: true}
+	db := Target{Database: "engine"}
+	if err := CheckSQL(opts, db, "SELECT * FROM posts WHERE id = 1", defaultProtected, 100, 500, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("an allowed query should not be reported: %+v", got)
+	}
+
+	refusals := []error{
+		CheckSQL(opts, db, "DELETE FROM posts", defaultProtected, 100, 500, true),
+		CheckAffectedRows(opts, db, "UPDATE", 501, 100, 500),
+		CheckKVBulkDelete(CheckOpts{Operation: "kv_bulk_delete"}, Target{Namespace: "cache"}, 11, 10),
+	}
+	if len(got) != len(refusals) {
+		t.Fatalf("got %d evaluations, want %d", len(got), len(refusals))
+	}
+	for i, err := range refusals {
+		if err == nil || got[i].Err != err {
+			t.Errorf("refusal %d: error %v reported as %v", i, err, got[i].Err)
+		}
+	}
+	if got[0].Operation != "d1_query_write" || got[0].Target != db || got[0].Tier != TierWrite || !got[0].WriteFlag {
+		t.Errorf("SQL refusal reported as %+v", got[0])
+	}
+	if got[2].Operation != "kv_bulk_delete" || got[2].Target.Namespace != "cache" || got[2].Tier != TierDangerous {
+		t.Errorf("KV refusal reported as %+v", got[2])
+	}
+}
@@ -6,49 +6,49 @@ import "strings"
 // Ported directly from Python gw's safety/git.py.
 var gitOperationTiers = map[string]Tier{
 	// Tier 1: Read operations (always safe)
-	"status":       TierRead,
-	"log":          TierRead,
-	"diff":         TierRead,
-	"blame":        TierRead,
-	"show":         TierRead,
-	"branch_list":  TierRead,
-	"stash_list":   TierRead,
-	"remote_list":  TierRead,
-	"fetch":        TierRead,
-	"reflog":       TierRead,
-	"shortlog":     TierRead,
-	"tag_list":     TierRead,
-	"config_get":   TierRead,
+	"status":        TierRead,
+	"log":           TierRead,
+	"diff":          TierRead,
+	"blame":         TierRead,
+	"show":          TierRead,
+	"branch_list":   TierRead,
+	"stash_list":    TierRead,
+	"remote_list":   TierRead,
+	"fetch":         TierRead,
+	"reflog":        TierRead,
+	"shortlog":      TierRead,
+	"tag_list":      TierRead,
+	"config_get":    TierRead,
 	"worktree_list": TierRead,
 
 	// Tier 2: Write operations (require --write)
-	"add":            TierWrite,
-	"commit":         TierWrite,
-	"push":           TierWrite,
-	"branch_create":  TierWrite,
-	"branch_delete":  TierWrite,
-	"checkout":       TierWrite,
-	"switch":         TierWrite,
-	"stash_push":     TierWrite,
-	"stash_pop":      TierWrite,
-	"stash_apply":    TierWrite,
-	"stash_drop":     TierWrite,
-	"pull":           TierWrite,
-	"unstage":        TierWrite,
-	"save":           TierWrite,
-	"wip":            TierWrite,
-	"undo":           TierWrite,
-	"amend":          TierWrite,
-	"sync":           TierWrite,
-	"cherry_pick":    TierWrite,
-	"ship":           TierWrite,
-	"restore":        TierWrite,
-	"tag_create":     TierWrite,
-	"tag_delete":     TierWrite,
-	"remote_add":     TierWrite,
-	"remote_remove":  TierWrite,
-	"remote_rename":  TierWrite,
-	"config_set":     TierWrite,
+	"add":             TierWrite,
+	"commit":          TierWrite,
+	"push":            TierWrite,
+	"branch_create":   TierWrite,
+	"branch_delete":   TierWrite,
+	"checkout":        TierWrite,
+	"switch":          TierWrite,
+	"stash_push":      TierWrite,
+	"stash_pop":       TierWrite,
+	"stash_apply":     TierWrite,
+	"stash_drop":      TierWrite,
+	"pull":            TierWrite,
+	"unstage":         TierWrite,
+	"save":            TierWrite,
+	"wip":             TierWrite,
+	"undo":            TierWrite,
+	"amend":           TierWrite,
+	"sync":            TierWrite,
+	"cherry_pick":     TierWrite,
+	"ship":            TierWrite,
+	"restore":         TierWrite,
+	"tag_create":      TierWrite,
+	"tag_delete":      TierWrite,
+	"remote_add":      TierWrite,
+	"remote_remove":   TierWrite,
+	"remote_rename":   TierWrite,
+	"config_set":      TierWrite,
 	"worktree_create": TierWrite,
 	"worktree_remove": TierWrite,
 	"worktree_prune":  TierWrite,
@@ -85,29 +85,43 @@ func GitOperationTier(operation string) Tier {
 }
 
 // CheckGitSafety validates a git operation against safety rules.
+// The active policy may re-tier the operation for the target branch, but
+// force-pushing to a protected branch is always refused.
 func CheckGitSafety(operation string, writeFlag, forceFlag, agentMode, interactive bool, targetBranch string, protectedBranches []string) error {
 	tier := GitOperationTier(operation)
 
 	// For force-push to a protected branch, escalate to PROTECTED
 	if operation == "push_force" && targetBranch != "" {
 		if IsProtectedBranch(targetBranch, protectedBranches) {
-			return &SafetyError{
+			err := &SafetyError{
 				Message:    "force push to protected branch '" + targetBranch + "' is not allowed",
 				Tier:       TierProtected,
 				Operation:  operation,
 				Suggestion: "Use a feature branch instead",
 			}
+			notify(Evaluation{
+				Operation:   operation,
+				Tier:        TierProtected,
+				BaseTier:    tier,
+				WriteFlag:   writeFlag,
+				ForceFlag:   forceFlag,
+				AgentMode:   agentMode,
+				Interactive: interactive,
+				Target:      Target{Branch: targetBranch},
+				Err:         err,
+			})
+			return err
 		}
 	}
 
-	return Check(CheckOpts{
-		Operation:   operation,
-		Tier:        tier,
-		WriteFlag:   writeFlag,
-		ForceFlag:   forceFlag,
-		AgentMode:   agentMode,
-		Interactive: interactive,
-	})
+	return checkWithPolicy(CheckOpts{
+		Operation:    operation,
+		WriteFlag:    writeFlag,
+		ForceFlag:    forceFlag,
+		AgentMode:    agentMode,
+		Interactive:  interactive,
+		TargetBranch: targetBranch,
+	}, tier, Target{Branch: targetBranch})
 }
 
 // IsProtectedBranch checks if a branch name is in the protected list.
@@ -4,40 +4,40 @@ package safety
 // Ported directly from Python gw's safety/github.py.
 var githubOperationTiers = map[string]Tier{
 	// Tier 1: Read operations (always safe)
-	"pr_list":      TierRead,
-	"pr_view":      TierRead,
-	"pr_status":    TierRead,
-	"pr_checks":    TierRead,
-	"issue_list":   TierRead,
-	"issue_view":   TierRead,
-	"issue_search": TierRead,
-	"run_list":     TierRead,
-	"run_view":     TierRead,
-	"run_watch":    TierRead,
+	"pr_list":       TierRead,
+	"pr_view":       TierRead,
+	"pr_status":     TierRead,
+	"pr_checks":     TierRead,
+	"issue_list":    TierRead,
+	"issue_view":    TierRead,
+	"issue_search":  TierRead,
+	"run_list":      TierRead,
+	"run_view":      TierRead,
+	"run_watch":     TierRead,
 	"project_list":  TierRead,
 	"project_view":  TierRead,
 	"project_items": TierRead,
-	"api_get":      TierRead,
-	"rate_limit":   TierRead,
+	"api_get":       TierRead,
+	"rate_limit":    TierRead,
 
 	// Tier 2: Write operations (require --write)
-	"pr_create":    TierWrite,
-	"pr_comment":   TierWrite,
-	"pr_review":    TierWrite,
-	"pr_edit":      TierWrite,
-	"issue_create":  TierWrite,
-	"issue_batch":   TierWrite,
-	"issue_comment": TierWrite,
-	"issue_edit":    TierWrite,
-	"run_rerun":           TierWrite,
-	"run_cancel":          TierWrite,
-	"workflow_run":        TierWrite,
-	"release_regenerate":  TierWrite,
-	"project_move": TierWrite,
-	"project_field": TierWrite,
-	"project_add":  TierWrite,
-	"api_post":     TierWrite,
-	"api_patch":    TierWrite,
+	"pr_create":          TierWrite,
+	"pr_comment":         TierWrite,
+	"pr_review":          TierWrite,
+	"pr_edit":            TierWrite,
+	"issue_create":       TierWrite,
+	"issue_batch":        TierWrite,
+	"issue_comment":      TierWrite,
+	"issue_edit":         TierWrite,
+	"run_rerun":          TierWrite,
+	"run_cancel":         TierWrite,
+	"workflow_run":       TierWrite,
+	"release_regenerate": TierWrite,
+	"project_move":       TierWrite,
+	"project_field":      TierWrite,
+	"project_add":        TierWrite,
+	"api_post":           TierWrite,
+	"api_patch":          TierWrite,
 
 	// Tier 3: Destructive operations (require --write + confirmation)
 	"pr_merge":       TierDangerous,
@@ -62,14 +62,13 @@ func GitHubOperationTier(operation string) Tier {
 func CheckGitHubSafety(operation string, writeFlag, agentMode, interactive bool) error {
 	tier := GitHubOperationTier(operation)
 
-	return Check(CheckOpts{
+	return checkWithPolicy(CheckOpts{
 		Operation:   operation,
-		Tier:        tier,
 		WriteFlag:   writeFlag,
 		ForceFlag:   true, // GitHub destructive ops don't need --force, just --write
 		AgentMode:   agentMode,
 		Interactive: interactive,
-	})
+	}, tier, Target{})
 }
 
 // APITierFromMethod returns the safety tier for a raw API call based on HTTP method.
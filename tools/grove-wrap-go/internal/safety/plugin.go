@@ -0,0 +1,30 @@
+package safety
+
+import "fmt"
+
+// pluginOperationTiers holds the tiers external plugins declare in their
+// manifests. It is filled at startup and consulted after the built-in
+// domains, so a plugin can never change a built-in operation's tier.
+var pluginOperationTiers = map[string]Tier{}
+
+// RegisterPluginOperation records the tier a plugin manifest declares for
+// an operation. Built-in operation names are rejected.
+func RegisterPluginOperation(operation string, tier Tier) error {
+	if _, domain, ok := LookupOperation(operation); ok && domain != "plugin" {
+		return fmt.Errorf("operation %q is a built-in %s operation", operation, domain)
+	}
+	pluginOperationTiers[operation] = tier
+	return nil
+}
+
+// CheckPluginSafety validates a plugin subcommand at the tier its manifest
+// declares, with policy rules applied by operation name.
+func CheckPluginSafety(operation string, tier Tier, writeFlag, forceFlag, agentMode, interactive bool) error {
+	return checkWithPolicy(CheckOpts{
+		Operation:   operation,
+		WriteFlag:   writeFlag,
+		ForceFlag:   forceFlag,
+		AgentMode:   agentMode,
+		Interactive: interactive,
+	}, tier, Target{})
+}
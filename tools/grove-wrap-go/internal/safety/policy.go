@@ -0,0 +1,303 @@
+package safety
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Policy is a set of declarative rules that override the built-in operation
+// tiers. Policies are loaded from ~/.grove/policy.toml and, optionally, a
+// repo-level .grove/policy.toml in the grove root.
+//
+// Example:
+//
+//	[[rules]]
+//	operation = "merge"
+//	branch    = "release/*"
+//	tier      = "protected"
+//	reason    = "release branches only move through the release workflow"
+//
+//	[[rules]]
+//	operation = "d1_query_write"
+//	database  = "amber"
+//	tier      = "dangerous"
+//
+// Rules are evaluated in load order (user file first, then repo file, then
+// top to bottom). The last matching rule wins, so later lines override
+// earlier ones.
+//
+// A repo file arrives with whatever repository was cloned, so its rules
+// can only raise a tier above what the built-in tiers and the user file
+// give, unless the user file sets trust_repo = true. Among such raise-only
+// rules the strictest match applies, whatever its position.
+//
+// Scope fields only match operations that report the resource: branch for
+// git operations given a branch (merge, push_force, ...), database for D1
+// queries, migrations, exports, imports, the shell and backups, namespace
+// for KV operations, and worker for deploy and secret apply, sync and
+// unapply. Other operations, such as r2_* and flag_*, carry no target, so
+// a rule with any scope field never matches them; use an unscoped rule.
+type Policy struct {
+	TrustRepo bool   `toml:"trust_repo"`
+	Rules     []Rule `toml:"rules"`
+}
+
+// Rule raises or lowers the tier of matching operations.
+// Every non-empty scope field must match for the rule to apply.
+type Rule struct {
+	Operation string `toml:"operation" json:"operation"`           // operation name or glob, e.g. "merge", "d1_*"
+	Branch    string `toml:"branch" json:"branch,omitempty"`       // branch glob, e.g. "release/*"
+	Database  string `toml:"database" json:"database,omitempty"`   // D1 database alias glob
+	Namespace string `toml:"namespace" json:"namespace,omitempty"` // KV namespace alias glob
+	Worker    string `toml:"worker" json:"worker,omitempty"`       // Worker name glob
+	Tier      string `toml:"tier" json:"tier"`                     // read, write, dangerous, or protected
+	Reason    string `toml:"reason" json:"reason,omitempty"`
+
+	Source    string `toml:"-" json:"source"`               // file the rule was loaded from
+	Index     int    `toml:"-" json:"index"`                // 1-based position of the rule within Source
+	RaiseOnly bool   `toml:"-" json:"raise_only,omitempty"` // untrusted repo rule that cannot lower a tier
+}
+
+// Target describes the resource an operation acts on, for scoped rules.
+type Target struct {
+	Branch    string `json:"branch,omitempty"`
+	Database  string `json:"database,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Worker    string `json:"worker,omitempty"`
+}
+
+// Decision is the outcome of resolving an operation against a policy.
+type Decision struct {
+	Operation string
+	BaseTier  Tier
+	Tier      Tier
+	Rule      *Rule  // deciding rule, nil when the built-in tier applies
+	Matched   []Rule // every matching rule, in evaluation order
+}
+
+// activePolicy is consulted by the Check*Safety helpers. Nil means built-in
+// tiers only.
+var activePolicy *Policy
+
+// SetPolicy installs the policy consulted by all safety checks.
+func SetPolicy(p *Policy) {
+	activePolicy = p
+}
+
+// ActivePolicy returns the installed policy, or nil if none is set.
+func ActivePolicy() *Policy {
+	return activePolicy
+}
+
+// ParseTier converts a tier name (case-insensitive) to a Tier.
+func ParseTier(s string) (Tier, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "read":
+		return TierRead, nil
+	case "write":
+		return TierWrite, nil
+	case "dangerous":
+		return TierDangerous, nil
+	case "protected":
+		return TierProtected, nil
+	default:
+		return TierWrite, fmt.Errorf("unknown tier %q (use read, write, dangerous, or protected)", s)
+	}
+}
+
+// ParsePolicy decodes and validates policy TOML. source is recorded on each
+// rule so explain output can point back at the file.
+func ParsePolicy(data []byte, source string) (*Policy, error) {
+	var p Policy
+	if _, err := toml.Decode(string(data), &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		r.Source = source
+		r.Index = i + 1
+		if r.Operation == "" {
+			return nil, fmt.Errorf("%s: rule %d has no operation", source, r.Index)
+		}
+		if _, err := ParseTier(r.Tier); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", source, r.Index, err)
+		}
+		for _, pattern := range []string{r.Operation, r.Branch, r.Database, r.Namespace, r.Worker} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: rule %d: bad pattern %q", source, r.Index, pattern)
+			}
+		}
+	}
+	return &p, nil
+}
+
+// LoadPolicy reads and merges policy files in order. Missing files are
+// skipped. Files that fail to parse are left out and reported in the
+// returned error; rules from the remaining files are still returned.
+func LoadPolicy(paths ...string) (*Policy, error) {
+	merged := &Policy{}
+	var errs []error
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		parsed, err := ParsePolicy(data, p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		merged.TrustRepo = merged.TrustRepo || parsed.TrustRepo
+		merged.Rules = append(merged.Rules, parsed.Rules...)
+	}
+	return merged, errors.Join(errs...)
+}
+
+// LoadUserAndRepoPolicy loads the user's policy file and then the repo's.
+// Repo rules are marked RaiseOnly unless the user file trusts the repo;
+// trust_repo in the repo file itself counts for nothing.
+func LoadUserAndRepoPolicy(userPath, repoPath string) (*Policy, error) {
+	merged, userErr := LoadPolicy(userPath)
+	repo, repoErr := LoadPolicy(repoPath)
+	for _, r := range repo.Rules {
+		r.RaiseOnly = !merged.TrustRepo
+		merged.Rules = append(merged.Rules, r)
+	}
+	return merged, errors.Join(userErr, repoErr)
+}
+
+// Resolve applies the policy to an operation's built-in tier.
+// A nil policy returns the base tier unchanged.
+func (p *Policy) Resolve(operation string, base Tier, target Target) Decision {
+	d := Decision{Operation: operation, BaseTier: base, Tier: base}
+	if p == nil {
+		return d
+	}
+	for i := range p.Rules {
+		r := p.Rules[i]
+		if !r.matches(operation, target) {
+			continue
+		}
+		d.Matched = append(d.Matched, r)
+	}
+	// Trusted rules decide first, last match winning; the strictest
+	// raise-only rule then applies if it is stricter still.
+	var raise *Rule
+	var raiseTier Tier
+	for i := range d.Matched {
+		r := &d.Matched[i]
+		tier, _ := ParseTier(r.Tier)
+		if r.RaiseOnly {
+			if raise == nil || tier >= raiseTier {
+				raise, raiseTier = r, tier
+			}
+			continue
+		}
+		d.Rule = r
+		d.Tier = tier
+	}
+	if raise != nil && raiseTier > d.Tier {
+		d.Rule = raise
+		d.Tier = raiseTier
+	}
+	return d
+}
+
+// Describe returns a short "source#index" reference for the rule.
+func (r Rule) Describe() string {
+	return fmt.Sprintf("%s#%d", r.Source, r.Index)
+}
+
+// matches reports whether the rule applies to the operation and target.
+func (r Rule) matches(operation string, target Target) bool {
+	if !globMatch(r.Operation, operation) {
+		return false
+	}
+	scopes := [][2]string{
+		{r.Branch, target.Branch},
+		{r.Database, target.Database},
+		{r.Namespace, target.Namespace},
+		{r.Worker, target.Worker},
+	}
+	for _, s := range scopes {
+		if s[0] != "" && !globMatch(s[0], s[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// globMatch matches value against a glob pattern. Empty values never match.
+func globMatch(pattern, value string) bool {
+	if value == "" {
+		return false
+	}
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
+
+// LookupOperation finds an operation's built-in tier across all domains.
+// Returns the domain name ("git", "github", "cloudflare", "todoist", or
+// "plugin" for tiers declared by plugin manifests) and false if the
+// operation is unknown.
+func LookupOperation(operation string) (Tier, string, bool) {
+	domains := []struct {
+		name  string
+		tiers map[string]Tier
+	}{
+		{"git", gitOperationTiers},
+		{"github", githubOperationTiers},
+		{"cloudflare", cloudflareOperationTiers},
+		{"todoist", todoistOperationTiers},
+		{"plugin", pluginOperationTiers},
+	}
+	for _, d := range domains {
+		if tier, ok := d.tiers[operation]; ok {
+			return tier, d.name, true
+		}
+	}
+	return TierWrite, "", false
+}
+
+// checkWithPolicy resolves base through the active policy and runs Check.
+// When a policy rule decided the tier, blocked errors name the rule.
+func checkWithPolicy(opts CheckOpts, base Tier, target Target) error {
+	d := activePolicy.Resolve(opts.Operation, base, target)
+	opts.Tier = d.Tier
+	err := check(opts)
+	rule := ""
+	if d.Rule != nil {
+		rule = d.Rule.Describe()
+	}
+	if safeErr, ok := err.(*SafetyError); ok && d.Rule != nil {
+		safeErr.Message += fmt.Sprintf(" (policy rule %s)", rule)
+		if d.Rule.Reason != "" {
+			safeErr.Suggestion = d.Rule.Reason
+		}
+	}
+	notify(Evaluation{
+		Operation:   opts.Operation,
+		Tier:        d.Tier,
+		BaseTier:    base,
+		PolicyRule:  rule,
+		WriteFlag:   opts.WriteFlag,
+		ForceFlag:   opts.ForceFlag,
+		AgentMode:   opts.AgentMode,
+		Interactive: opts.Interactive,
+		Target:      target,
+		Err:         err,
+	})
+	return err
+}
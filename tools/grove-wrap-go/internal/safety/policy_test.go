@@ -0,0 +1,314 @@
+package safety
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPolicy = `
+[[rules]]
+operation = "merge"
+branch = "release/*"
+tier = "protected"
+reason = "release branches move through the release workflow"
+
+[[rules]]
+operation = "d1_query_write"
+database = "amber"
+tier = "dangerous"
+
+[[rules]]
+operation = "rebase"
+tier = "write"
+`
+
+func mustParsePolicy(t *testing.T, data string) *Policy {
+	t.Helper()
+	p, err := ParsePolicy([]byte(data), "policy.toml")
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+	return p
+}
+
+func TestParseTier(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Tier
+	}{
+		{"read", TierRead},
+		{"WRITE", TierWrite},
+		{" Dangerous ", TierDangerous},
+		{"protected", TierProtected},
+	}
+	for _, tt := range tests {
+		got, err := ParseTier(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTier(%q) = %s, %v; want %s", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseTier("yolo"); err == nil {
+		t.Error("ParseTier should reject unknown tiers")
+	}
+}
+
+func TestParsePolicyRejectsInvalidRules(t *testing.T) {
+	bad := []string{
+		`[[rules]]
+tier = "write"`,
+		`[[rules]]
+operation = "merge"
+tier = "sometimes"`,
+		`[[rules]]
+operation = "merge["
+tier = "write"`,
+		`not toml at all =`,
+	}
+	for _, data := range bad {
+		if _, err := ParsePolicy([]byte(data), "bad.toml"); err == nil {
+			t.Errorf("ParsePolicy should fail for:\n%s", data)
+		}
+	}
+}
+
+func TestPolicyResolveBranchGlob(t *testing.T) {
+	p := mustParsePolicy(t, testPolicy)
+
+	d := p.Resolve("merge", TierDangerous, Target{Branch: "release/1.4"})
+	if d.Tier != TierProtected {
+		t.Errorf("merge into release/1.4 = %s, want PROTECTED", d.Tier)
+	}
+	if d.Rule == nil || d.Rule.Index != 1 {
+		t.Errorf("expected rule #1 to decide, got %+v", d.Rule)
+	}
+
+	d = p.Resolve("merge", TierDangerous, Target{Branch: "feature/x"})
+	if d.Tier != TierDangerous || d.Rule != nil {
+		t.Errorf("merge into feature/x should keep built-in tier, got %s (%v)", d.Tier, d.Rule)
+	}
+
+	// A scoped rule never matches when the target is unknown.
+	d = p.Resolve("merge", TierDangerous, Target{})
+	if d.Tier != TierDangerous {
+		t.Errorf("merge without branch = %s, want DANGEROUS", d.Tier)
+	}
+}
+
+func TestPolicyResolveDatabaseScope(t *testing.T) {
+	p := mustParsePolicy(t, testPolicy)
+
+	if d := p.Resolve("d1_query_write", TierWrite, Target{Database: "amber"}); d.Tier != TierDangerous {
+		t.Errorf("d1_query_write on amber = %s, want DANGEROUS", d.Tier)
+	}
+	if d := p.Resolve("d1_query_write", TierWrite, Target{Database: "lattice"}); d.Tier != TierWrite {
+		t.Errorf("d1_query_write on lattice = %s, want WRITE", d.Tier)
+	}
+}
+
+func TestPolicyResolveLastRuleWins(t *testing.T) {
+	p := mustParsePolicy(t, `
+[[rules]]
+operation = "kv_*"
+tier = "dangerous"
+
+[[rules]]
+operation = "kv_put"
+namespace = "cache"
+tier = "write"
+`)
+	d := p.Resolve("kv_put", TierWrite, Target{Namespace: "cache"})
+	if d.Tier != TierWrite || len(d.Matched) != 2 {
+		t.Errorf("kv_put on cache = %s with %d matches, want WRITE with 2", d.Tier, len(d.Matched))
+	}
+	d = p.Resolve("kv_delete", TierWrite, Target{Namespace: "cache"})
+	if d.Tier != TierDangerous {
+		t.Errorf("kv_delete on cache = %s, want DANGEROUS", d.Tier)
+	}
+}
+
+func TestNilPolicyResolve(t *testing.T) {
+	var p *Policy
+	d := p.Resolve("merge", TierDangerous, Target{Branch: "main"})
+	if d.Tier != TierDangerous || d.Rule != nil {
+		t.Errorf("nil policy should return base tier, got %s", d.Tier)
+	}
+}
+
+func TestLoadPolicyMergesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.toml")
+	repo := filepath.Join(dir, "repo.toml")
+	os.WriteFile(user, []byte(`[[rules]]
+operation = "rebase"
+tier = "write"
+`), 0o644)
+	os.WriteFile(repo, []byte(`[[rules]]
+operation = "rebase"
+tier = "protected"
+`), 0o644)
+
+	p, err := LoadPolicy(user, filepath.Join(dir, "missing.toml"), repo)
+	if err != nil {
+		t.Fatalf("LoadPolicy: %v", err)
+	}
+	d := p.Resolve("rebase", TierDangerous, Target{})
+	if d.Tier != TierProtected || d.Rule.Source != repo {
+		t.Errorf("repo rule should win, got %s from %s", d.Tier, d.Rule.Source)
+	}
+}
+
+func TestRepoPolicyOnlyRaisesUnlessTrusted(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.toml")
+	repo := filepath.Join(dir, "repo.toml")
+	os.WriteFile(repo, []byte(`trust_repo = true
+
+[[rules]]
+operation = "r2_rm"
+tier = "read"
+
+[[rules]]
+operation = "kv_put"
+tier = "dangerous"
+`), 0o644)
+
+	p, err := LoadUserAndRepoPolicy(user, repo)
+	if err != nil {
+		t.Fatalf("LoadUserAndRepoPolicy: %v", err)
+	}
+	if d := p.Resolve("r2_rm", TierDangerous, Target{}); d.Tier != TierDangerous || d.Rule != nil {
+		t.Errorf("untrusted repo rule lowered r2_rm to %s", d.Tier)
+	}
+	if d := p.Resolve("kv_put", TierWrite, Target{}); d.Tier != TierDangerous || d.Rule == nil || d.Rule.Source != repo {
+		t.Errorf("repo rule should still raise kv_put, got %s", d.Tier)
+	}
+
+	os.WriteFile(user, []byte(`trust_repo = true
+`), 0o644)
+	p, err = LoadUserAndRepoPolicy(user, repo)
+	if err != nil {
+		t.Fatalf("LoadUserAndRepoPolicy: %v", err)
+	}
+	if d := p.Resolve("r2_rm", TierDangerous, Target{}); d.Tier != TierRead {
+		t.Errorf("trusted repo rule should lower r2_rm, got %s", d.Tier)
+	}
+}
+
+func TestRepoPolicyStrictestRaiseWins(t *testing.T) {
+	protected := `[[rules]]
+operation = "kv_put"
+tier = "protected"
+`
+	dangerous := `[[rules]]
+operation = "kv_*"
+tier = "dangerous"
+`
+	for _, order := range [][2]string{{protected, dangerous}, {dangerous, protected}} {
+		dir := t.TempDir()
+		repo := filepath.Join(dir, "repo.toml")
+		os.WriteFile(repo, []byte(order[0]+"\n"+order[1]), 0o644)
+
+		p, err := LoadUserAndRepoPolicy(filepath.Join(dir, "user.toml"), repo)
+		if err != nil {
+			t.Fatalf("LoadUserAndRepoPolicy: %v", err)
+		}
+		d := p.Resolve("kv_put", TierWrite, Target{})
+		if d.Tier != TierProtected || d.Rule == nil || d.Rule.Operation != "kv_put" {
+			t.Errorf("kv_put = %s, want PROTECTED from the kv_put rule\n%s", d.Tier, order[0]+order[1])
+		}
+		if d := p.Resolve("kv_delete", TierWrite, Target{}); d.Tier != TierDangerous {
+			t.Errorf("kv_delete = %s, want DANGEROUS", d.Tier)
+		}
+	}
+}
+
+func TestLoadPolicyKeepsGoodFilesOnError(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.toml")
+	bad := filepath.Join(dir, "bad.toml")
+	os.WriteFile(good, []byte(`[[rules]]
+operation = "rebase"
+tier = "write"
+`), 0o644)
+	os.WriteFile(bad, []byte(`[[rules]]
+operation = "merge"
+tier = "nope"
+`), 0o644)
+
+	p, err := LoadPolicy(good, bad)
+	if err == nil {
+		t.Error("LoadPolicy should report the bad file")
+	}
+	if len(p.Rules) != 1 {
+		t.Errorf("expected 1 rule from the good file, got %d", len(p.Rules))
+	}
+}
+
+func TestCheckGitSafetyUsesPolicy(t *testing.T) {
+	SetPolicy(mustParsePolicy(t, testPolicy))
+	defer SetPolicy(nil)
+
+	err := CheckGitSafety("merge", true, true, false, false, "release/2.0", nil)
+	safeErr, ok := err.(*SafetyError)
+	if !ok {
+		t.Fatalf("merge into release/2.0 should be blocked, got %v", err)
+	}
+	if safeErr.Tier != TierProtected {
+		t.Errorf("expected PROTECTED, got %s", safeErr.Tier)
+	}
+	if safeErr.Suggestion == "" {
+		t.Error("policy reason should be surfaced as the suggestion")
+	}
+
+	// Lowered from DANGEROUS to WRITE: --force is no longer needed.
+	if err := CheckGitSafety("rebase", true, false, false, false, "", nil); err != nil {
+		t.Errorf("rebase lowered to WRITE should pass with --write, got %v", err)
+	}
+}
+
+func TestPolicyCannotUnlockProtectedForcePush(t *testing.T) {
+	SetPolicy(mustParsePolicy(t, `[[rules]]
+operation = "push_force"
+tier = "read"
+`))
+	defer SetPolicy(nil)
+
+	if err := CheckGitSafety("push_force", true, true, false, false, "main", []string{"main"}); err == nil {
+		t.Error("force push to a protected branch must stay blocked regardless of policy")
+	}
+}
+
+func TestCheckCloudflareSafetyTargetUsesPolicy(t *testing.T) {
+	SetPolicy(mustParsePolicy(t, testPolicy))
+	defer SetPolicy(nil)
+
+	if err := CheckCloudflareSafetyTarget("d1_query_write", Target{Database: "amber"}, true, false, false, false); err == nil {
+		t.Error("d1_query_write on amber should require --force under policy")
+	}
+	if err := CheckCloudflareSafetyTarget("d1_query_write", Target{Database: "lattice"}, true, false, false, false); err != nil {
+		t.Errorf("d1_query_write on lattice should pass with --write, got %v", err)
+	}
+}
+
+func TestLookupOperation(t *testing.T) {
+	tests := []struct {
+		op     string
+		tier   Tier
+		domain string
+	}{
+		{"merge", TierDangerous, "git"},
+		{"pr_merge", TierDangerous, "github"},
+		{"kv_put", TierWrite, "cloudflare"},
+		{"todoist_delete_task", TierDangerous, "todoist"},
+	}
+	for _, tt := range tests {
+		tier, domain, ok := LookupOperation(tt.op)
+		if !ok || tier != tt.tier || domain != tt.domain {
+			t.Errorf("LookupOperation(%q) = %s, %q, %v", tt.op, tier, domain, ok)
+		}
+	}
+	if _, _, ok := LookupOperation("no_such_op"); ok {
+		t.Error("unknown operation should not be found")
+	}
+}
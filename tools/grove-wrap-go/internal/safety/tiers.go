@@ -74,9 +74,56 @@ type CheckOpts struct {
 	TargetBranch string // For branch-specific checks
 }
 
+// Evaluation describes a single safety decision, as reported to the observer.
+type Evaluation struct {
+	Operation   string
+	Tier        Tier   // effective tier after policy
+	BaseTier    Tier   // built-in tier before policy
+	PolicyRule  string // deciding policy rule, if any
+	WriteFlag   bool
+	ForceFlag   bool
+	AgentMode   bool
+	Interactive bool
+	Target      Target
+	Err         error // nil when the operation was allowed
+}
+
+// observer receives every safety decision. Nil disables reporting.
+var observer func(Evaluation)
+
+// SetObserver installs a callback that receives every safety decision,
+// allowed or blocked. Used by gw to keep an audit trail.
+func SetObserver(fn func(Evaluation)) {
+	observer = fn
+}
+
+// notify reports a decision to the observer, if one is installed.
+func notify(e Evaluation) {
+	if observer != nil {
+		observer(e)
+	}
+}
+
 // Check validates an operation against the safety tier system.
 // Returns nil if the operation is allowed, or a SafetyError if blocked.
 func Check(opts CheckOpts) error {
+	err := check(opts)
+	notify(Evaluation{
+		Operation:   opts.Operation,
+		Tier:        opts.Tier,
+		BaseTier:    opts.Tier,
+		WriteFlag:   opts.WriteFlag,
+		ForceFlag:   opts.ForceFlag,
+		AgentMode:   opts.AgentMode,
+		Interactive: opts.Interactive,
+		Target:      Target{Branch: opts.TargetBranch},
+		Err:         err,
+	})
+	return err
+}
+
+// check implements Check without reporting to the observer.
+func check(opts CheckOpts) error {
 	switch opts.Tier {
 	case TierRead:
 		return nil
@@ -86,9 +133,9 @@ func Check(opts CheckOpts) error {
 		effectiveWrite := opts.WriteFlag || (opts.Interactive && !opts.AgentMode)
 		if !effectiveWrite {
 			return &SafetyError{
-				Message:   fmt.Sprintf("operation '%s' requires --write flag", opts.Operation),
-				Tier:      opts.Tier,
-				Operation: opts.Operation,
+				Message:    fmt.Sprintf("operation '%s' requires --write flag", opts.Operation),
+				Tier:       opts.Tier,
+				Operation:  opts.Operation,
 				Suggestion: fmt.Sprintf("Add --write flag: gw %s --write", opts.Operation),
 			}
 		}
@@ -105,17 +152,17 @@ func Check(opts CheckOpts) error {
 		}
 		if !opts.WriteFlag {
 			return &SafetyError{
-				Message:   fmt.Sprintf("operation '%s' requires --write flag", opts.Operation),
-				Tier:      opts.Tier,
-				Operation: opts.Operation,
+				Message:    fmt.Sprintf("operation '%s' requires --write flag", opts.Operation),
+				Tier:       opts.Tier,
+				Operation:  opts.Operation,
 				Suggestion: fmt.Sprintf("Add flags: gw %s --write --force", opts.Operation),
 			}
 		}
 		if !opts.ForceFlag {
 			return &SafetyError{
-				Message:   fmt.Sprintf("operation '%s' is dangerous and requires --force flag", opts.Operation),
-				Tier:      opts.Tier,
-				Operation: opts.Operation,
+				Message:    fmt.Sprintf("operation '%s' is dangerous and requires --force flag", opts.Operation),
+				Tier:       opts.Tier,
+				Operation:  opts.Operation,
 				Suggestion: fmt.Sprintf("Add --force flag: gw %s --write --force", opts.Operation),
 			}
 		}
@@ -32,12 +32,11 @@ func TodoistOperationTier(operation string) Tier {
 func CheckTodoistSafety(operation string, writeFlag, forceFlag, agentMode, interactive bool) error {
 	tier := TodoistOperationTier(operation)
 
-	return Check(CheckOpts{
+	return checkWithPolicy(CheckOpts{
 		Operation:   operation,
-		Tier:        tier,
 		WriteFlag:   writeFlag,
 		ForceFlag:   forceFlag,
 		AgentMode:   agentMode,
 		Interactive: interactive,
-	})
+	}, tier, Target{})
 }
@@ -0,0 +1,142 @@
+// Package scrub masks personal data in rows exported from D1.
+//
+// Rules come from the [scrub] section of gw.toml, mapping "table.column"
+// (or "*.column" for every table) to a strategy:
+//
+//	[scrub.columns]
+//	"users.email"      = "email"
+//	"*.display_name"   = "name"
+//	"sessions.token"   = "token"
+//	"users.ip_address" = "null"
+//
+// Masked values are derived from an HMAC of the original under a key
+// chosen per export, so equal inputs stay equal (joins and unique
+// constraints survive) without the output being reversible by guessing.
+package scrub
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Strategies.
+const (
+	Email = "email" // user-<hash>@example.invalid
+	Name  = "name"  // Person <hash>
+	Token = "token" // hex of the same length as the original
+	Null  = "null"  // NULL
+	Keep  = "keep"  // unchanged; overrides a wildcard or a guess
+)
+
+var strategies = map[string]bool{Email: true, Name: true, Token: true, Null: true, Keep: true}
+
+// Validate checks rule keys and strategies.
+func Validate(rules map[string]string) error {
+	keys := make([]string, 0, len(rules))
+	for k := range rules {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		table, column, ok := strings.Cut(k, ".")
+		if !ok || table == "" || column == "" {
+			return fmt.Errorf("scrub rule %q: want \"table.column\" or \"*.column\"", k)
+		}
+		if !strategies[rules[k]] {
+			return fmt.Errorf("scrub rule %q: unknown strategy %q (want email, name, token, null or keep)", k, rules[k])
+		}
+	}
+	return nil
+}
+
+// Guess picks a strategy for a column from its name alone, or "" when the
+// name does not look personal. It is used for protected tables, which are
+// scrubbed even where no rule is configured.
+func Guess(column string) string {
+	c := strings.ToLower(column)
+	switch {
+	case strings.Contains(c, "email"):
+		return Email
+	case strings.Contains(c, "password"), strings.Contains(c, "token"), strings.Contains(c, "secret"),
+		strings.HasSuffix(c, "_hash"), strings.HasSuffix(c, "_key"), c == "salt":
+		return Token
+	case c == "name", c == "username", c == "nickname", strings.HasSuffix(c, "_name"):
+		return Name
+	case strings.Contains(c, "phone"), strings.Contains(c, "address"), c == "ip",
+		strings.HasPrefix(c, "ip_"), strings.Contains(c, "user_agent"):
+		return Null
+	}
+	return ""
+}
+
+// Scrubber applies rules with one key.
+type Scrubber struct {
+	rules map[string]string
+	key   []byte
+}
+
+// New returns a Scrubber for rules, whose keys are matched without regard
+// to case. key should be random and used for one export.
+func New(rules map[string]string, key []byte) *Scrubber {
+	lower := make(map[string]string, len(rules))
+	for k, v := range rules {
+		lower[strings.ToLower(k)] = v
+	}
+	return &Scrubber{rules: lower, key: key}
+}
+
+// Plan returns the strategy for each column of table, "" for none. A
+// "table.column" rule wins over "*.column"; with guess set, columns no
+// rule covers fall back to Guess. Keep comes back as "".
+func (s *Scrubber) Plan(table string, cols []string, guess bool) []string {
+	plan := make([]string, len(cols))
+	for i, c := range cols {
+		strategy, ok := s.rules[strings.ToLower(table+"."+c)]
+		if !ok {
+			strategy, ok = s.rules["*."+strings.ToLower(c)]
+		}
+		if !ok && guess {
+			strategy = Guess(c)
+		}
+		if strategy != Keep {
+			plan[i] = strategy
+		}
+	}
+	return plan
+}
+
+// Apply masks v with strategy. NULL stays NULL.
+func (s *Scrubber) Apply(strategy string, v any) any {
+	if v == nil || strategy == "" || strategy == Keep {
+		return v
+	}
+	text := fmt.Sprint(v)
+	switch strategy {
+	case Null:
+		return nil
+	case Email:
+		return "user-" + s.digest(text, 10) + "@example.invalid"
+	case Name:
+		return "Person " + s.digest(text, 6)
+	case Token:
+		return s.digest(text, len(text))
+	}
+	return v
+}
+
+// digest returns n hex characters derived from text.
+func (s *Scrubber) digest(text string, n int) string {
+	var out strings.Builder
+	block := []byte(text)
+	for out.Len() < n {
+		mac := hmac.New(sha256.New, s.key)
+		mac.Write(block)
+		block = mac.Sum(nil)
+		out.WriteString(hex.EncodeToString(block))
+	}
+	return out.String()[:n]
+}
@@ -0,0 +1,75 @@
+package scrub
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if err := Validate(map[string]string{"users.email": "email", "*.token": "token", "posts.body": "keep"}); err != nil {
+		t.Errorf("valid rules rejected: %v", err)
+	}
+	for _, bad := range []map[string]string{
+		{"email": "email"},
+		{"users.": "email"},
+		{"users.email": "hash"},
+	} {
+		if err := Validate(bad); err == nil {
+			t.Errorf("Validate(%v) should fail", bad)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	s := New(map[string]string{
+		"Users.Email":    "email",
+		"*.display_name": "name",
+		"users.api_key":  "keep",
+	}, []byte("k"))
+	cols := []string{"id", "email", "display_name", "api_key", "password_hash", "bio"}
+
+	got := s.Plan("users", cols, false)
+	want := []string{"", Email, Name, "", "", ""}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Plan(no guess) = %v, want %v", got, want)
+	}
+
+	// Protected tables also guess; keep still wins
+	got = s.Plan("users", cols, true)
+	want = []string{"", Email, Name, "", Token, ""}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Plan(guess) = %v, want %v", got, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	s := New(nil, []byte("export-key"))
+	a := s.Apply(Email, "autumn@grove.place")
+	if a != s.Apply(Email, "autumn@grove.place") {
+		t.Error("same input should scrub the same way")
+	}
+	if a == s.Apply(Email, "someone@grove.place") {
+		t.Error("different inputs should scrub differently")
+	}
+	if e := a.(string); !strings.HasPrefix(e, "user-") || !strings.HasSuffix(e, "@example.invalid") {
+		t.Errorf("email = %q", e)
+	}
+	if a == New(nil, []byte("other-key")).Apply(Email, "autumn@grove.place") {
+		t.Error("a different key should give a different result")
+	}
+
+	token := strings.Repeat("t", 100)
+	if got := s.Apply(Token, token).(string); len(got) != 100 || strings.Contains(got, "t") {
+		t.Errorf("token = %q, want 100 hex characters", got)
+	}
+	if got := s.Apply(Name, "Autumn Brown").(string); !strings.HasPrefix(got, "Person ") {
+		t.Errorf("name = %q", got)
+	}
+	if s.Apply(Null, "1.2.3.4") != nil || s.Apply(Email, nil) != nil {
+		t.Error("null strategy and NULL input should give NULL")
+	}
+	if got := s.Apply(Token, json.Number("12345")); len(got.(string)) != 5 {
+		t.Errorf("numeric token = %v", got)
+	}
+}
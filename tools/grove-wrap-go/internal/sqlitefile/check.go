@@ -0,0 +1,79 @@
+package sqlitefile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TableRows is the number of rows in one table.
+type TableRows struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+}
+
+// CheckResult is what Check found. The file is sound when Problems is
+// empty.
+type CheckResult struct {
+	Problems []string    `json:"problems"`
+	Tables   []TableRows `json:"tables"`
+}
+
+// Check runs PRAGMA integrity_check, collecting at most maxProblems
+// problems, and counts the rows of every table.
+//
+// The error is for a file that cannot be read at all; damage SQLite
+// reports on the way, including a schema too corrupt to load, is a
+// problem.
+func (r *Reader) Check(maxProblems int) (*CheckResult, error) {
+	c := &CheckResult{}
+	rows, err := r.db.Query(fmt.Sprintf("PRAGMA integrity_check(%d)", maxProblems))
+	if err != nil {
+		if !corrupt(err) {
+			return nil, fmt.Errorf("%s: %w", r.path, err)
+		}
+		c.Problems = append(c.Problems, err.Error())
+		return c, nil
+	}
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if msg != "ok" {
+			c.Problems = append(c.Problems, msg)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		c.Problems = append(c.Problems, err.Error())
+	}
+	rows.Close()
+
+	tables, err := r.Tables()
+	if err != nil {
+		c.Problems = append(c.Problems, err.Error())
+		return c, nil
+	}
+	for _, t := range tables {
+		if strings.HasPrefix(strings.ToUpper(t.SQL), "CREATE VIRTUAL") {
+			continue
+		}
+		var n int64
+		err := r.db.QueryRow("SELECT COUNT(*) FROM " + QuoteIdent(t.Name)).Scan(&n)
+		if err != nil {
+			if len(c.Problems) < maxProblems {
+				c.Problems = append(c.Problems, fmt.Sprintf("%s: %v", t.Name, err))
+			}
+			continue
+		}
+		c.Tables = append(c.Tables, TableRows{Name: t.Name, Rows: n})
+	}
+	return c, nil
+}
+
+// corrupt reports whether err is SQLite refusing a damaged file.
+func corrupt(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "malformed") || strings.Contains(msg, "not a database") ||
+		strings.Contains(msg, "corrupt")
+}
@@ -0,0 +1,102 @@
+package sqlitefile
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Reader reads tables from an SQLite file.
+type Reader struct {
+	db   *sql.DB
+	path string
+}
+
+// TableInfo is a table listed in sqlite_master.
+type TableInfo struct {
+	Name string
+	SQL  string
+}
+
+// Open opens an SQLite file for reading. The connection is query-only, so
+// nothing a caller runs through the Reader can change the file.
+func Open(path string) (*Reader, error) {
+	if !isSQLite(path) {
+		return nil, fmt.Errorf("%s is not an SQLite database", path)
+	}
+	db, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("PRAGMA query_only = 1"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Reader{db: db, path: path}, nil
+}
+
+// Close closes the database.
+func (r *Reader) Close() error { return r.db.Close() }
+
+// Tables lists the tables in the database, in schema order.
+func (r *Reader) Tables() ([]TableInfo, error) {
+	rows, err := r.db.Query(`SELECT name, sql FROM sqlite_master WHERE type = 'table' ORDER BY rowid`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.path, err)
+	}
+	defer rows.Close()
+	var tables []TableInfo
+	for rows.Next() {
+		var t TableInfo
+		var sql sql.NullString
+		if err := rows.Scan(&t.Name, &sql); err != nil {
+			return nil, err
+		}
+		t.SQL = sql.String
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+// Scan calls fn for each row of a table in rowid order. Values are nil,
+// int64, float64, string or []byte.
+func (r *Reader) Scan(table string, fn func(rowid int64, values []any) error) error {
+	tables, err := r.Tables()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, t := range tables {
+		found = found || t.Name == table
+	}
+	if !found {
+		return fmt.Errorf("no table %s in the file", table)
+	}
+
+	rows, err := r.db.Query(fmt.Sprintf("SELECT rowid, * FROM %s ORDER BY rowid", QuoteIdent(table)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", table, err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		rowid, _ := values[0].(int64)
+		if err := fn(rowid, append([]any(nil), values[1:]...)); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", table, err)
+	}
+	return nil
+}
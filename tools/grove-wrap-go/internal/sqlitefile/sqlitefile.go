@@ -0,0 +1,112 @@
+// Package sqlitefile reads, writes and checks SQLite database files through
+// the embedded modernc.org/sqlite driver, so gw needs neither cgo nor the
+// sqlite3 binary.
+//
+// It covers what gw's exports and backups need: Writer streams rows into
+// rowid tables with untyped columns, Reader lists and scans tables, and
+// Check runs PRAGMA integrity_check.
+package sqlitefile
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// QuoteIdent double-quotes an SQL identifier.
+func QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Literal renders a value read by Reader as an SQL literal.
+func Literal(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case float64:
+		if math.IsInf(t, 0) || math.IsNaN(t) {
+			return "NULL"
+		}
+		s := strconv.FormatFloat(t, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0" // keep it REAL
+		}
+		return s
+	case []byte:
+		return "X'" + strings.ToUpper(hex.EncodeToString(t)) + "'"
+	default:
+		return "'" + strings.ReplaceAll(fmt.Sprint(t), "'", "''") + "'"
+	}
+}
+
+// value converts a row value to what is stored. Integral numbers are
+// stored as integers, booleans as 0 and 1, []byte as a blob, and anything
+// else as its JSON text.
+func value(v any) any {
+	switch t := v.(type) {
+	case nil, int64, string, []byte:
+		return t
+	case bool:
+		if t {
+			return int64(1)
+		}
+		return int64(0)
+	case int:
+		return int64(t)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return t.String()
+	case float64:
+		if t == math.Trunc(t) && math.Abs(t) < 1<<53 {
+			return int64(t)
+		}
+		return t
+	default:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return nil
+		}
+		return string(data)
+	}
+}
+
+// open opens path with a single connection, so per-connection pragmas
+// stick.
+func open(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// isSQLite reports whether path starts with the SQLite file header.
+func isSQLite(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	h := make([]byte, 16)
+	if _, err := io.ReadFull(f, h); err != nil {
+		return false
+	}
+	return bytes.Equal(h, []byte("SQLite format 3\x00"))
+}
@@ -0,0 +1,221 @@
+package sqlitefile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pageSize is SQLite's default page size, which Writer keeps.
+const pageSize = 4096
+
+func writeDB(t *testing.T, path string, tables map[string]int) {
+	t.Helper()
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"posts", "empty", "notes"} {
+		rows, ok := tables[name]
+		if !ok {
+			continue
+		}
+		tbl, err := w.CreateTable(name, []string{"id", "body", "id", "score"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= rows; i++ {
+			if err := tbl.Insert([]any{int64(i), body(i), nil, float64(i) / 4}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tbl.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func body(i int) string {
+	if i%30000 == 0 {
+		return strings.Repeat("x", 20000) // spills to overflow pages
+	}
+	return fmt.Sprintf("row %d", i)
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.db")
+	const rows = 120000
+	writeDB(t, path, map[string]int{"posts": rows, "empty": 0, "notes": 3})
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	tables, err := r.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 3 || tables[0].Name != "posts" || tables[0].SQL != `CREATE TABLE "posts" ("id", "body", "id_2", "score")` {
+		t.Fatalf("Tables() = %+v", tables)
+	}
+
+	n := 0
+	err = r.Scan("posts", func(rowid int64, v []any) error {
+		n++
+		if rowid != int64(n) || v[0] != int64(n) || v[1] != body(n) || v[2] != nil {
+			return fmt.Errorf("row %d = %d %v", n, rowid, v[:3])
+		}
+		if n == 3 && v[3] != 0.75 {
+			return fmt.Errorf("row 3 score = %#v", v[3])
+		}
+		return nil
+	})
+	if err != nil || n != rows {
+		t.Errorf("Scan(posts) = %d rows, %v", n, err)
+	}
+	if err := r.Scan("empty", func(int64, []any) error { return fmt.Errorf("unexpected row") }); err != nil {
+		t.Error(err)
+	}
+	if err := r.Scan("missing", func(int64, []any) error { return nil }); err == nil {
+		t.Error("scanning a missing table should fail")
+	}
+}
+
+func TestWriterOneTableAtATime(t *testing.T) {
+	w, err := Create(filepath.Join(t.TempDir(), "x.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.CreateTable("a", []string{"x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.CreateTable("b", []string{"x"}); err == nil {
+		t.Error("second open table should be refused")
+	}
+}
+
+func TestEmptyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.db")
+	writeDB(t, path, nil)
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if tables, err := r.Tables(); err != nil || len(tables) != 0 {
+		t.Errorf("Tables() = %v, %v", tables, err)
+	}
+}
+
+func TestOpenRefusesOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("not a database at all"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "is not an SQLite database") {
+		t.Errorf("Open(text file) = %v", err)
+	}
+}
+
+func TestReaderIsReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.db")
+	writeDB(t, path, map[string]int{"notes": 3})
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.db.Exec(`DELETE FROM notes`); err == nil {
+		t.Error("a Reader should not be able to write")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.db")
+	writeDB(t, path, map[string]int{"posts": 70000, "empty": 0, "notes": 3})
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Check(10)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Problems) != 0 {
+		t.Errorf("sound file has problems: %v", res.Problems)
+	}
+	want := []TableRows{{"posts", 70000}, {"empty", 0}, {"notes", 3}}
+	if !reflect.DeepEqual(res.Tables, want) {
+		t.Errorf("Tables = %v, want %v", res.Tables, want)
+	}
+
+	// Zero a page in the middle of the posts tree.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(data[100*pageSize:101*pageSize], make([]byte, pageSize))
+	broken := filepath.Join(dir, "broken.db")
+	if err := os.WriteFile(broken, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err = Open(broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = r.Check(3)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Problems) == 0 || len(res.Problems) > 3 {
+		t.Errorf("zeroed page: problems = %v", res.Problems)
+	}
+
+	// Cut the file short.
+	if err := os.WriteFile(broken, data[:len(data)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err = Open(broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = r.Check(10)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Problems) == 0 {
+		t.Error("truncated file passed the integrity check")
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{nil, "NULL"},
+		{int64(-42), "-42"},
+		{2.0, "2.0"},
+		{0.125, "0.125"},
+		{"it's", "'it''s'"},
+		{[]byte{0xde, 0xad}, "X'DEAD'"},
+	}
+	for _, tt := range tests {
+		if got := Literal(tt.v); got != tt.want {
+			t.Errorf("Literal(%#v) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}
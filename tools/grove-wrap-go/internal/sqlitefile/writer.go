@@ -0,0 +1,148 @@
+package sqlitefile
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Writer builds an SQLite database one table at a time.
+type Writer struct {
+	db    *sql.DB
+	open  *Table
+	names map[string]bool
+}
+
+// Create returns a Writer that builds a database at path, which must not
+// exist or be empty. The file is only consistent once Close returns: there
+// is no rollback journal, since a half-written export is discarded anyway.
+func Create(path string) (*Writer, error) {
+	db, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	// VACUUM writes the header page, so a database closed without tables
+	// is still a valid, empty SQLite file.
+	for _, stmt := range []string{"PRAGMA journal_mode = OFF", "PRAGMA synchronous = OFF", "VACUUM"} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return &Writer{db: db, names: map[string]bool{}}, nil
+}
+
+// CreateTable starts a table with untyped columns. Empty and duplicate
+// column names (SELECT a.id, b.id) are renamed, since they would make the
+// schema invalid. Tables are written one at a time: the previous one must
+// be closed first.
+func (w *Writer) CreateTable(name string, cols []string) (*Table, error) {
+	if w.open != nil {
+		return nil, fmt.Errorf("table %s is still being written", w.open.name)
+	}
+	if w.names[strings.ToLower(name)] {
+		return nil, fmt.Errorf("table %s already written", name)
+	}
+	w.names[strings.ToLower(name)] = true
+	cols = uniqueColumns(cols)
+
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = QuoteIdent(c)
+	}
+	tx, err := w.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	create := fmt.Sprintf("CREATE TABLE %s (%s)", QuoteIdent(name), strings.Join(quoted, ", "))
+	if _, err := tx.Exec(create); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("create table %s: %w", name, err)
+	}
+	insert := fmt.Sprintf("INSERT INTO %s VALUES (%s)", QuoteIdent(name),
+		strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", "))
+	stmt, err := tx.Prepare(insert)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("create table %s: %w", name, err)
+	}
+	t := &Table{w: w, name: name, cols: cols, tx: tx, stmt: stmt}
+	w.open = t
+	return t, nil
+}
+
+// uniqueColumns names empty columns columnN and suffixes repeats _2, _3.
+func uniqueColumns(cols []string) []string {
+	used := map[string]bool{}
+	out := make([]string, len(cols))
+	for i, c := range cols {
+		if c == "" {
+			c = fmt.Sprintf("column%d", i+1)
+		}
+		name := c
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s_%d", c, n)
+		}
+		used[strings.ToLower(name)] = true
+		out[i] = name
+	}
+	return out
+}
+
+// Close finishes any open table and closes the database.
+func (w *Writer) Close() error {
+	var err error
+	if w.open != nil {
+		err = w.open.Close()
+	}
+	if cerr := w.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Table receives the rows of one table.
+type Table struct {
+	w      *Writer
+	name   string
+	cols   []string
+	tx     *sql.Tx
+	stmt   *sql.Stmt
+	args   []any
+	closed bool
+}
+
+// Columns returns the table's column names as written.
+func (t *Table) Columns() []string { return t.cols }
+
+// Insert appends a row; values are in column order.
+func (t *Table) Insert(values []any) error {
+	if t.closed {
+		return fmt.Errorf("%s: table already closed", t.name)
+	}
+	if len(values) != len(t.cols) {
+		return fmt.Errorf("%s: row has %d values for %d columns", t.name, len(values), len(t.cols))
+	}
+	t.args = t.args[:0]
+	for _, v := range values {
+		t.args = append(t.args, value(v))
+	}
+	if _, err := t.stmt.Exec(t.args...); err != nil {
+		return fmt.Errorf("%s: %w", t.name, err)
+	}
+	return nil
+}
+
+// Close commits the table's rows.
+func (t *Table) Close() error {
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	t.w.open = nil
+	t.stmt.Close()
+	if err := t.tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", t.name, err)
+	}
+	return nil
+}
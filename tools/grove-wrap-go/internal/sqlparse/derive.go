@@ -0,0 +1,123 @@
+package sqlparse
+
+import "fmt"
+
+// CountSQL derives a query that counts the rows a DELETE or UPDATE would
+// touch: the same table, WITH clause, and WHERE, as a SELECT COUNT(*) with
+// the count in column "n". It does not account for the statement's own
+// LIMIT; callers cap the count with Limit. Returns false for any other
+// statement.
+func (s *Statement) CountSQL() (string, bool) {
+	return s.affectedRowsSQL("COUNT(*) AS n", 0)
+}
+
+// PreviewSQL derives a query that returns up to n of the rows a DELETE or
+// UPDATE would touch. Returns false for any other statement.
+func (s *Statement) PreviewSQL(n int) (string, bool) {
+	if s.Limit >= 0 && s.Limit < n {
+		n = s.Limit
+	}
+	return s.affectedRowsSQL("*", n)
+}
+
+// affectedRowsSQL builds "[WITH ...] SELECT <columns> FROM <target> [WHERE ...]".
+//
+// UPDATE ... FROM joins other tables; a target row is updated when at least
+// one joined row satisfies the WHERE, so the derived query moves the FROM
+// list and WHERE into an EXISTS subquery correlated with the target.
+func (s *Statement) affectedRowsSQL(columns string, limit int) (string, bool) {
+	if s.Explain || (s.Kind != "DELETE" && s.Kind != "UPDATE") {
+		return "", false
+	}
+	refStart, refEnd := s.targetRef()
+	if refStart >= refEnd {
+		return "", false
+	}
+
+	prefix := ""
+	if s.with >= 0 {
+		prefix = s.sqlRange(s.with, s.body) + " "
+	}
+	query := fmt.Sprintf("%sSELECT %s FROM %s", prefix, columns, s.sqlRange(refStart, refEnd))
+
+	where := ""
+	if start, end := s.clause("WHERE"); start >= 0 && start < end {
+		where = s.sqlRange(start, end)
+	}
+
+	if fromStart, fromEnd := s.updateFrom(); fromStart < fromEnd {
+		inner := "SELECT 1 FROM " + s.sqlRange(fromStart, fromEnd)
+		if where != "" {
+			inner += " WHERE " + where
+		}
+		query += " WHERE EXISTS (" + inner + ")"
+	} else if where != "" {
+		query += " WHERE " + where
+	}
+
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	return query, true
+}
+
+// targetRef returns the token range of the qualified table name (with any
+// alias and INDEXED BY) that a DELETE or UPDATE writes.
+func (s *Statement) targetRef() (int, int) {
+	start := s.body + 1
+	switch s.Kind {
+	case "DELETE":
+		start++ // FROM
+	case "UPDATE":
+		if start+1 < len(s.tokens) && s.tokens[start].Upper() == "OR" {
+			start += 2
+		}
+	}
+
+	end := start
+	for ; end < len(s.tokens); end++ {
+		switch s.tokens[end].Upper() {
+		case "SET", "WHERE", "RETURNING", "ORDER", "LIMIT":
+			return start, end
+		}
+	}
+	return start, end
+}
+
+// updateFrom returns the token range of the FROM list of an
+// UPDATE ... FROM statement, or an empty range.
+func (s *Statement) updateFrom() (int, int) {
+	if s.Kind != "UPDATE" {
+		return 0, 0
+	}
+	depth := 0
+	start := -1
+	for i := s.body; i < len(s.tokens); i++ {
+		switch s.tokens[i].Text {
+		case "(":
+			depth++
+			continue
+		case ")":
+			depth--
+			continue
+		}
+		if depth != 0 {
+			continue
+		}
+		word := s.tokens[i].Upper()
+		if start < 0 {
+			if word == "FROM" && s.tokens[i-1].Upper() != "DISTINCT" {
+				start = i + 1
+			}
+			continue
+		}
+		switch word {
+		case "WHERE", "RETURNING", "ORDER", "LIMIT":
+			return start, i
+		}
+	}
+	if start < 0 {
+		return 0, 0
+	}
+	return start, len(s.tokens)
+}
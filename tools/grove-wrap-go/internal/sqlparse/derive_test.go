@@ -0,0 +1,55 @@
+package sqlparse
+
+import "testing"
+
+func TestCountSQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"DELETE FROM posts WHERE status = 'draft'", "SELECT COUNT(*) AS n FROM posts WHERE status = 'draft'"},
+		{"DELETE FROM posts", "SELECT COUNT(*) AS n FROM posts"},
+		{"DELETE FROM posts WHERE id > 5 ORDER BY id LIMIT 10", "SELECT COUNT(*) AS n FROM posts WHERE id > 5"},
+		{"UPDATE posts SET title = 'x' WHERE tenant_id = ?1", "SELECT COUNT(*) AS n FROM posts WHERE tenant_id = ?1"},
+		{"UPDATE OR IGNORE posts AS p SET title = 'x' WHERE p.id IN (1, 2)", "SELECT COUNT(*) AS n FROM posts AS p WHERE p.id IN (1, 2)"},
+		{
+			"WITH old AS (SELECT id FROM posts WHERE created < 5) DELETE FROM posts WHERE id IN (SELECT id FROM old)",
+			"WITH old AS (SELECT id FROM posts WHERE created < 5) SELECT COUNT(*) AS n FROM posts WHERE id IN (SELECT id FROM old)",
+		},
+		{
+			"UPDATE posts SET author = u.name FROM users u WHERE u.id = posts.author_id RETURNING id",
+			"SELECT COUNT(*) AS n FROM posts WHERE EXISTS (SELECT 1 FROM users u WHERE u.id = posts.author_id)",
+		},
+	}
+	for _, tt := range tests {
+		got, ok := parseOne(t, tt.sql).CountSQL()
+		if !ok || got != tt.want {
+			t.Errorf("CountSQL(%q)\n got  %q (%v)\n want %q", tt.sql, got, ok, tt.want)
+		}
+	}
+}
+
+func TestCountSQLOnlyForDeleteAndUpdate(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT * FROM posts",
+		"INSERT INTO posts (id) VALUES (1)",
+		"EXPLAIN DELETE FROM posts WHERE id = 1",
+	} {
+		if _, ok := parseOne(t, sql).CountSQL(); ok {
+			t.Errorf("CountSQL(%q) should not derive a query", sql)
+		}
+	}
+}
+
+func TestPreviewSQL(t *testing.T) {
+	got, ok := parseOne(t, "DELETE FROM posts WHERE status = 'draft'").PreviewSQL(5)
+	if want := "SELECT * FROM posts WHERE status = 'draft' LIMIT 5"; !ok || got != want {
+		t.Errorf("PreviewSQL = %q, want %q", got, want)
+	}
+
+	// The statement's own smaller LIMIT caps the preview
+	got, _ = parseOne(t, "UPDATE posts SET a = 1 WHERE b = 2 LIMIT 3").PreviewSQL(5)
+	if want := "SELECT * FROM posts WHERE b = 2 LIMIT 3"; got != want {
+		t.Errorf("PreviewSQL = %q, want %q", got, want)
+	}
+}
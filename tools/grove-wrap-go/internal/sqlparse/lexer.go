@@ -0,0 +1,238 @@
+// Package sqlparse tokenizes and structurally parses the SQLite dialect
+// used by Cloudflare D1.
+//
+// It is not a full SQLite grammar. It understands enough structure —
+// quoting, comments, statement boundaries, WITH clauses, table references
+// at any nesting depth, and the top-level WHERE/LIMIT of each statement —
+// to make safety decisions without being fooled by the cases a regex is:
+// CTEs, UPDATE ... FROM, INSERT ... SELECT, quoted identifiers, and
+// subqueries.
+package sqlparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TokenKind classifies a lexical token.
+type TokenKind int
+
+const (
+	TokWord        TokenKind = iota // bare word: keyword or unquoted identifier
+	TokQuotedIdent                  // "ident", `ident`, or [ident]
+	TokString                       // 'string literal'
+	TokNumber                       // 42, 3.14, 1e9, 0xFF
+	TokBlob                         // X'00ff'
+	TokParam                        // ?, ?1, :name, @name, $name
+	TokOp                           // operators and punctuation
+	TokComment                      // -- line or /* block */ comment
+	TokSemicolon                    // statement separator
+)
+
+// Token is a single lexical token with its position in the source.
+type Token struct {
+	Kind TokenKind
+	Text string // raw source text, including quotes
+	Pos  int    // byte offset in the source
+}
+
+// End returns the byte offset just past the token.
+func (t Token) End() int {
+	return t.Pos + len(t.Text)
+}
+
+// Upper returns the upper-cased text of a bare word, or "" for other kinds.
+func (t Token) Upper() string {
+	if t.Kind != TokWord {
+		return ""
+	}
+	return strings.ToUpper(t.Text)
+}
+
+// Ident returns the identifier a token names, unquoted and lower-cased.
+// Returns "" for tokens that cannot name an identifier.
+func (t Token) Ident() string {
+	switch t.Kind {
+	case TokWord:
+		return strings.ToLower(t.Text)
+	case TokQuotedIdent:
+		inner := t.Text[1 : len(t.Text)-1]
+		switch t.Text[0] {
+		case '"':
+			inner = strings.ReplaceAll(inner, `""`, `"`)
+		case '`':
+			inner = strings.ReplaceAll(inner, "``", "`")
+		}
+		return strings.ToLower(inner)
+	case TokString:
+		// SQLite accepts 'name' where an identifier is expected.
+		return strings.ToLower(strings.ReplaceAll(t.Text[1:len(t.Text)-1], "''", "'"))
+	}
+	return ""
+}
+
+// multiCharOps are operators longer than one byte, longest first.
+var multiCharOps = []string{"->>", "||", "<<", ">>", "<=", ">=", "==", "!=", "<>", "->"}
+
+// Tokenize splits sql into tokens. Whitespace is dropped; comments are kept
+// as TokComment so callers can decide how to treat them. Unterminated
+// strings, quoted identifiers, and block comments are errors.
+func Tokenize(sql string) ([]Token, error) {
+	var tokens []Token
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			i++
+			continue
+
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end
+			}
+			tokens = append(tokens, Token{TokComment, sql[start:i], start})
+
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated block comment at offset %d", start)
+			}
+			i += 2 + end + 2
+			tokens = append(tokens, Token{TokComment, sql[start:i], start})
+
+		case c == '\'':
+			end, err := scanQuoted(sql, i, '\'', true)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			tokens = append(tokens, Token{TokString, sql[start:i], start})
+
+		case c == '"' || c == '`':
+			end, err := scanQuoted(sql, i, c, true)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			tokens = append(tokens, Token{TokQuotedIdent, sql[start:i], start})
+
+		case c == '[':
+			end, err := scanQuoted(sql, i, ']', false)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			tokens = append(tokens, Token{TokQuotedIdent, sql[start:i], start})
+
+		case (c == 'x' || c == 'X') && i+1 < len(sql) && sql[i+1] == '\'':
+			end, err := scanQuoted(sql, i+1, '\'', false)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			tokens = append(tokens, Token{TokBlob, sql[start:i], start})
+
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			i = scanNumber(sql, i)
+			tokens = append(tokens, Token{TokNumber, sql[start:i], start})
+
+		case c == '?':
+			i++
+			for i < len(sql) && isDigit(sql[i]) {
+				i++
+			}
+			tokens = append(tokens, Token{TokParam, sql[start:i], start})
+
+		case (c == ':' || c == '@' || c == '$') && i+1 < len(sql) && isWordByte(sql[i+1]):
+			i++
+			for i < len(sql) && isWordByte(sql[i]) {
+				i++
+			}
+			tokens = append(tokens, Token{TokParam, sql[start:i], start})
+
+		case isWordStart(c):
+			for i < len(sql) && (isWordByte(sql[i]) || sql[i] == '$') {
+				i++
+			}
+			tokens = append(tokens, Token{TokWord, sql[start:i], start})
+
+		case c == ';':
+			i++
+			tokens = append(tokens, Token{TokSemicolon, ";", start})
+
+		default:
+			op := string(c)
+			for _, m := range multiCharOps {
+				if strings.HasPrefix(sql[i:], m) {
+					op = m
+					break
+				}
+			}
+			i += len(op)
+			tokens = append(tokens, Token{TokOp, op, start})
+		}
+	}
+	return tokens, nil
+}
+
+// scanQuoted returns the offset just past a quoted run starting at sql[i].
+// When doubled is true, two closing quotes in a row are an escaped quote.
+func scanQuoted(sql string, i int, closer byte, doubled bool) (int, error) {
+	for j := i + 1; j < len(sql); j++ {
+		if sql[j] != closer {
+			continue
+		}
+		if doubled && j+1 < len(sql) && sql[j+1] == closer {
+			j++
+			continue
+		}
+		return j + 1, nil
+	}
+	return 0, fmt.Errorf("unterminated %c at offset %d", sql[i], i)
+}
+
+// scanNumber returns the offset just past a numeric literal at sql[i].
+func scanNumber(sql string, i int) int {
+	if sql[i] == '0' && i+1 < len(sql) && (sql[i+1] == 'x' || sql[i+1] == 'X') {
+		i += 2
+		for i < len(sql) && isHexDigit(sql[i]) {
+			i++
+		}
+		return i
+	}
+	for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.' || sql[i] == '_') {
+		i++
+	}
+	if i < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+		j := i + 1
+		if j < len(sql) && (sql[j] == '+' || sql[j] == '-') {
+			j++
+		}
+		if j < len(sql) && isDigit(sql[j]) {
+			i = j
+			for i < len(sql) && isDigit(sql[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool    { return c >= '0' && c <= '9' }
+func isHexDigit(c byte) bool { return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') }
+
+// isWordStart reports whether c can begin a bare word. Bytes >= 0x80 are
+// accepted so UTF-8 identifiers tokenize as words, as in SQLite.
+func isWordStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c >= 0x80
+}
+
+func isWordByte(c byte) bool {
+	return isWordStart(c) || isDigit(c)
+}
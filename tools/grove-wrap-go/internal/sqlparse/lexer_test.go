@@ -0,0 +1,85 @@
+package sqlparse
+
+import "testing"
+
+func TestTokenizeKinds(t *testing.T) {
+	tokens, err := Tokenize(`SELECT "a""b", [c d], 'it''s', x'ff', 1.5e3, ?1, :name -- tail`)
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+
+	want := []struct {
+		kind TokenKind
+		text string
+	}{
+		{TokWord, "SELECT"},
+		{TokQuotedIdent, `"a""b"`},
+		{TokOp, ","},
+		{TokQuotedIdent, "[c d]"},
+		{TokOp, ","},
+		{TokString, "'it''s'"},
+		{TokOp, ","},
+		{TokBlob, "x'ff'"},
+		{TokOp, ","},
+		{TokNumber, "1.5e3"},
+		{TokOp, ","},
+		{TokParam, "?1"},
+		{TokOp, ","},
+		{TokParam, ":name"},
+		{TokComment, "-- tail"},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d: %+v", len(tokens), len(want), tokens)
+	}
+	for i, w := range want {
+		if tokens[i].Kind != w.kind || tokens[i].Text != w.text {
+			t.Errorf("token %d = {%d %q}, want {%d %q}", i, tokens[i].Kind, tokens[i].Text, w.kind, w.text)
+		}
+	}
+}
+
+func TestTokenizeQuotedContentsAreOpaque(t *testing.T) {
+	tokens, err := Tokenize(`SELECT 'a; DROP TABLE users -- x /* y'`)
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+	if len(tokens) != 2 || tokens[1].Kind != TokString {
+		t.Errorf("string literal should be a single token, got %+v", tokens)
+	}
+}
+
+func TestTokenizeUnterminated(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT 'abc",
+		`SELECT "abc`,
+		"SELECT [abc",
+		"SELECT 1 /* open",
+	} {
+		if _, err := Tokenize(sql); err == nil {
+			t.Errorf("expected error for %q", sql)
+		}
+	}
+}
+
+func TestTokenIdent(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"Users", "users"},
+		{`"Users"`, "users"},
+		{"`users`", "users"},
+		{"[users]", "users"},
+		{`"we""ird"`, `we"ird`},
+		{"42", ""},
+	}
+	for _, tt := range tests {
+		tokens, err := Tokenize(tt.sql)
+		if err != nil || len(tokens) != 1 {
+			t.Fatalf("Tokenize(%q) = %v, %v", tt.sql, tokens, err)
+		}
+		if got := tokens[0].Ident(); got != tt.want {
+			t.Errorf("Ident(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
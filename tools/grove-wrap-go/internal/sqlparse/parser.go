@@ -0,0 +1,712 @@
+package sqlparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Script is the parsed form of a SQL string.
+type Script struct {
+	Statements  []*Statement
+	HasComments bool // true if any -- or /* */ comment appeared
+}
+
+// Statement is the structural summary of one SQL statement.
+type Statement struct {
+	Kind       string      // leading keyword after WITH/EXPLAIN, upper-cased: SELECT, INSERT, UPDATE, DELETE, ...
+	Explain    bool        // statement was prefixed with EXPLAIN [QUERY PLAN]
+	Target     string      // table written by INSERT/REPLACE/UPDATE/DELETE or named by DDL, lower-cased
+	Tables     []string    // every real table referenced at any depth, lower-cased, deduplicated
+	CTEs       []string    // names defined by the WITH clause
+	HasWhere   bool        // the statement itself (not a subquery) has a WHERE clause
+	Where      string      // source text of that WHERE clause
+	Predicates []Predicate // top-level AND-conjuncts of the WHERE clause
+	HasLimit   bool        // the statement itself has a LIMIT clause
+	Limit      int         // LIMIT value, or -1 if absent or not a literal
+	Compound   bool        // UNION, INTERSECT, or EXCEPT appears anywhere
+	Assigns    bool        // PRAGMA that sets a value (PRAGMA x = y, or PRAGMA x(y) outside readPragmas)
+	Text       string      // source text of the statement
+
+	src    string // the full source the tokens were read from
+	tokens []Token
+	with   int // index of the WITH keyword in tokens, or -1
+	body   int // index of the leading keyword in tokens
+}
+
+// Predicate is one AND-conjunct of a WHERE clause. Column, Op, and Values
+// are filled in when the conjunct has the simple "column op value" shape.
+type Predicate struct {
+	Text     string
+	Column   string   // lower-cased column name, qualifier stripped
+	Op       string   // upper-cased: =, !=, <, IN, NOT IN, LIKE, IS, BETWEEN, ...
+	Values   []string // right-hand side; one entry per IN-list item
+	Literal  bool     // every value is a single literal or bound parameter
+	Subquery bool     // right-hand side is a subquery
+}
+
+// mutationKinds are statement kinds that write data.
+var mutationKinds = map[string]bool{
+	"INSERT": true, "REPLACE": true, "UPDATE": true, "DELETE": true,
+}
+
+// ddlKinds are statement kinds that change the schema.
+var ddlKinds = map[string]bool{
+	"CREATE": true, "DROP": true, "ALTER": true, "TRUNCATE": true,
+}
+
+// IsMutation reports whether the statement writes data.
+func (s *Statement) IsMutation() bool {
+	return !s.Explain && mutationKinds[s.Kind]
+}
+
+// IsDDL reports whether the statement changes the schema.
+func (s *Statement) IsDDL() bool {
+	return !s.Explain && ddlKinds[s.Kind]
+}
+
+// IsRead reports whether the statement only reads.
+func (s *Statement) IsRead() bool {
+	return s.Explain || s.Kind == "SELECT" || s.Kind == "VALUES" || (s.Kind == "PRAGMA" && !s.Assigns)
+}
+
+// WhereIsConstant reports whether the WHERE clause references no columns,
+// e.g. "WHERE 1" or "WHERE 1 = 1", and so filters nothing.
+func (s *Statement) WhereIsConstant() bool {
+	if !s.HasWhere {
+		return false
+	}
+	start, end := s.clause("WHERE")
+	toks := s.tokens[start:end]
+	for i, t := range toks {
+		if t.Kind == TokQuotedIdent {
+			return false
+		}
+		if t.Kind == TokWord && !keywords[t.Upper()] {
+			// name( is a function call, not a column
+			if i+1 < len(toks) && toks[i+1].Text == "(" {
+				continue
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// Parse tokenizes sql and summarises each statement. Empty statements
+// (such as a trailing semicolon) are dropped.
+func Parse(sql string) (*Script, error) {
+	tokens, err := Tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	script := &Script{}
+	var current []Token
+	flush := func() error {
+		if len(current) == 0 {
+			return nil
+		}
+		stmt, err := parseStatement(sql, current)
+		if err != nil {
+			return err
+		}
+		script.Statements = append(script.Statements, stmt)
+		current = nil
+		return nil
+	}
+
+	// blocks counts open BEGIN/CASE ... END pairs inside a CREATE TRIGGER
+	// body, whose semicolons end the trigger's statements, not this one.
+	blocks := 0
+	for _, t := range tokens {
+		switch t.Kind {
+		case TokComment:
+			script.HasComments = true
+		case TokSemicolon:
+			if blocks > 0 {
+				current = append(current, t)
+				continue
+			}
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		default:
+			if isTrigger(current) {
+				switch t.Upper() {
+				case "BEGIN", "CASE":
+					blocks++
+				case "END":
+					blocks--
+				}
+			}
+			current = append(current, t)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// isTrigger reports whether toks begin CREATE [TEMP] TRIGGER.
+func isTrigger(toks []Token) bool {
+	if len(toks) < 2 || toks[0].Upper() != "CREATE" {
+		return false
+	}
+	if toks[1].Upper() == "TRIGGER" {
+		return true
+	}
+	return len(toks) > 2 && toks[2].Upper() == "TRIGGER"
+}
+
+// parseStatement builds a Statement from the tokens of one statement.
+func parseStatement(sql string, toks []Token) (*Statement, error) {
+	s := &Statement{
+		src:    sql,
+		tokens: toks,
+		with:   -1,
+		Limit:  -1,
+		Text:   strings.TrimSpace(sql[toks[0].Pos:toks[len(toks)-1].End()]),
+	}
+
+	i := 0
+	if toks[i].Upper() == "EXPLAIN" {
+		s.Explain = true
+		i++
+		if i+1 < len(toks) && toks[i].Upper() == "QUERY" && toks[i+1].Upper() == "PLAN" {
+			i += 2
+		}
+	}
+	if i < len(toks) && toks[i].Upper() == "WITH" {
+		s.with = i
+		next, err := s.parseWith(i + 1)
+		if err != nil {
+			return nil, err
+		}
+		i = next
+	}
+	if i >= len(toks) || toks[i].Kind != TokWord {
+		return nil, fmt.Errorf("expected a statement keyword in %q", s.Text)
+	}
+	s.body = i
+	s.Kind = toks[i].Upper()
+
+	s.collectTables()
+	s.parseClauses()
+	return s, nil
+}
+
+// parseWith reads "[RECURSIVE] name [(cols)] AS [NOT] [MATERIALIZED] (...)"
+// entries and returns the index of the token after the last one.
+func (s *Statement) parseWith(i int) (int, error) {
+	toks := s.tokens
+	if i < len(toks) && toks[i].Upper() == "RECURSIVE" {
+		i++
+	}
+	for {
+		if i >= len(toks) || toks[i].Ident() == "" {
+			return 0, fmt.Errorf("expected a CTE name after WITH")
+		}
+		s.CTEs = append(s.CTEs, toks[i].Ident())
+		i++
+		if i < len(toks) && toks[i].Text == "(" {
+			i = skipParens(toks, i)
+		}
+		if i >= len(toks) || toks[i].Upper() != "AS" {
+			return 0, fmt.Errorf("expected AS in WITH clause")
+		}
+		i++
+		for i < len(toks) && (toks[i].Upper() == "NOT" || toks[i].Upper() == "MATERIALIZED") {
+			i++
+		}
+		if i >= len(toks) || toks[i].Text != "(" {
+			return 0, fmt.Errorf("expected ( after AS in WITH clause")
+		}
+		i = skipParens(toks, i)
+		if i < len(toks) && toks[i].Text == "," {
+			i++
+			continue
+		}
+		return i, nil
+	}
+}
+
+// collectTables walks every token, at every depth, and records the tables
+// introduced by FROM, JOIN, INTO, UPDATE, and TABLE.
+func (s *Statement) collectTables() {
+	toks := s.tokens
+	seen := map[string]bool{}
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			s.Tables = append(s.Tables, name)
+		}
+	}
+
+	for i := 0; i < len(toks); i++ {
+		word := toks[i].Upper()
+		prev := ""
+		if i > 0 {
+			prev = toks[i-1].Upper()
+		}
+
+		switch word {
+		case "FROM":
+			// IS [NOT] DISTINCT FROM is a comparison, not a table list
+			if prev == "DISTINCT" {
+				continue
+			}
+			j := i + 1
+			for {
+				name, next := tableRef(toks, j, true)
+				add(name)
+				if next < len(toks) && toks[next].Text == "," {
+					j = next + 1
+					continue
+				}
+				break
+			}
+
+		case "JOIN", "INTO":
+			name, _ := tableRef(toks, i+1, word == "JOIN")
+			add(name)
+			if word == "INTO" {
+				s.setTarget(name)
+			}
+
+		case "UPDATE":
+			// ON CONFLICT ... DO UPDATE and ON UPDATE are not table references
+			if prev == "DO" || prev == "ON" {
+				continue
+			}
+			j := i + 1
+			if j+1 < len(toks) && toks[j].Upper() == "OR" {
+				j += 2 // UPDATE OR REPLACE/IGNORE/...
+			}
+			name, _ := tableRef(toks, j, false)
+			add(name)
+			if i == s.body {
+				s.setTarget(name)
+			}
+
+		case "TABLE", "VIEW", "TRIGGER", "INDEX":
+			if !ddlKinds[s.Kind] || i != s.body+1 && !(i == s.body+2 && (toks[s.body+1].Upper() == "TEMP" || toks[s.body+1].Upper() == "UNIQUE")) {
+				continue
+			}
+			j := i + 1
+			for j < len(toks) && (toks[j].Upper() == "IF" || toks[j].Upper() == "NOT" || toks[j].Upper() == "EXISTS") {
+				j++
+			}
+			name, next := tableRef(toks, j, false)
+			if word == "TABLE" {
+				add(name)
+			}
+			s.setTarget(name)
+			// CREATE INDEX/TRIGGER name ... ON table
+			if word == "INDEX" || word == "TRIGGER" {
+				for k := next; k < len(toks); k++ {
+					if toks[k].Upper() == "ON" {
+						on, _ := tableRef(toks, k+1, false)
+						add(on)
+						break
+					}
+				}
+			}
+
+		case "TRUNCATE":
+			j := i + 1
+			if j < len(toks) && toks[j].Upper() == "TABLE" {
+				j++
+			}
+			name, _ := tableRef(toks, j, false)
+			add(name)
+			s.setTarget(name)
+		}
+	}
+
+	// DELETE FROM <target>
+	if s.Kind == "DELETE" && len(toks) > s.body+1 && toks[s.body+1].Upper() == "FROM" {
+		name, _ := tableRef(toks, s.body+2, false)
+		s.setTarget(name)
+	}
+
+	// References to CTE names are not real tables. The write target is
+	// kept even if it shadows a CTE name: SQLite cannot write to a CTE,
+	// so the statement writes to the real table.
+	if len(s.CTEs) > 0 {
+		cte := map[string]bool{}
+		for _, c := range s.CTEs {
+			cte[c] = true
+		}
+		var real []string
+		for _, t := range s.Tables {
+			if !cte[t] || t == s.Target {
+				real = append(real, t)
+			}
+		}
+		s.Tables = real
+	}
+}
+
+// setTarget records the written table for mutation and DDL statements.
+func (s *Statement) setTarget(name string) {
+	if s.Target == "" && (mutationKinds[s.Kind] || ddlKinds[s.Kind]) {
+		s.Target = name
+	}
+}
+
+// tableRef reads a (possibly schema-qualified) table name at toks[i].
+// Returns "" for subqueries and, where funcs is set (FROM and JOIN),
+// table-valued functions; elsewhere a following "(" is a column list. The
+// second result is the index after the reference and any alias.
+func tableRef(toks []Token, i int, funcs bool) (string, int) {
+	if i >= len(toks) || toks[i].Text == "(" {
+		return "", i
+	}
+	if toks[i].Kind == TokWord && keywords[toks[i].Upper()] {
+		return "", i
+	}
+	name := toks[i].Ident()
+	if name == "" {
+		return "", i
+	}
+	i++
+	if i+1 < len(toks) && toks[i].Text == "." {
+		name = toks[i+1].Ident()
+		i += 2
+	}
+	// name(...) is a table-valued function such as json_each
+	if funcs && i < len(toks) && toks[i].Text == "(" {
+		return "", skipParens(toks, i)
+	}
+	// optional [AS] alias
+	if i < len(toks) && toks[i].Upper() == "AS" {
+		i += 2
+	} else if i < len(toks) && (toks[i].Kind == TokQuotedIdent || (toks[i].Kind == TokWord && !keywords[toks[i].Upper()])) {
+		i++
+	}
+	return name, i
+}
+
+// clauseEnd lists the keywords that end a top-level WHERE clause.
+var clauseEnd = map[string]bool{
+	"GROUP": true, "ORDER": true, "LIMIT": true, "RETURNING": true, "WINDOW": true,
+	"HAVING": true, "UNION": true, "INTERSECT": true, "EXCEPT": true, "ON": true,
+}
+
+// readPragmas take an argument that names what to read, such as a table
+// or index, rather than a value to set.
+var readPragmas = map[string]bool{
+	"table_info": true, "table_xinfo": true, "table_list": true,
+	"index_list": true, "index_info": true, "index_xinfo": true,
+	"foreign_key_list": true, "foreign_key_check": true,
+	"integrity_check": true, "quick_check": true,
+}
+
+// pragmaAssigns reports whether a PRAGMA sets a value: PRAGMA x = y, or
+// PRAGMA x(y), which for most pragmas is the same assignment. The name may
+// carry a schema prefix (PRAGMA main.x).
+func (s *Statement) pragmaAssigns() bool {
+	toks := s.tokens[s.body+1:]
+	if len(toks) >= 2 && toks[1].Text == "." {
+		toks = toks[2:]
+	}
+	if len(toks) < 2 {
+		return false
+	}
+	name := strings.ToLower(strings.Trim(toks[0].Text, "\"`[]"))
+	switch toks[1].Text {
+	case "=":
+		return true
+	case "(":
+		return !readPragmas[name]
+	}
+	return false
+}
+
+// parseClauses finds the statement's own WHERE and LIMIT (depth 0 relative
+// to the statement body) and notes compound selects and PRAGMA assignment.
+func (s *Statement) parseClauses() {
+	toks := s.tokens
+	for _, t := range toks {
+		switch t.Upper() {
+		case "UNION", "INTERSECT", "EXCEPT":
+			s.Compound = true
+		}
+	}
+	if s.Kind == "PRAGMA" {
+		s.Assigns = s.pragmaAssigns()
+	}
+
+	if start, end := s.clause("WHERE"); start >= 0 {
+		s.HasWhere = true
+		if start < end {
+			s.Where = strings.TrimSpace(s.sqlRange(start, end))
+			s.Predicates = s.splitPredicates(start, end)
+		}
+	}
+
+	if start, end := s.clause("LIMIT"); start >= 0 {
+		s.HasLimit = true
+		lim := toks[start:end]
+		// LIMIT <offset>, <count> puts the count second
+		for k, t := range lim {
+			if t.Text == "," && k+1 < len(lim) {
+				lim = lim[k+1:]
+				break
+			}
+		}
+		if len(lim) > 0 && lim[0].Kind == TokNumber {
+			if n, err := strconv.Atoi(lim[0].Text); err == nil {
+				s.Limit = n
+			}
+		}
+	}
+}
+
+// clause returns the token range [start, end) of the body of a depth-0
+// clause introduced by keyword, or (-1, -1) if there is none. A WHERE
+// inside ON CONFLICT (an upsert) is not the statement's WHERE.
+func (s *Statement) clause(keyword string) (int, int) {
+	toks := s.tokens
+	depth := 0
+	start := -1
+	for i := s.body; i < len(toks); i++ {
+		switch toks[i].Text {
+		case "(":
+			depth++
+			continue
+		case ")":
+			depth--
+			continue
+		}
+		if depth != 0 {
+			continue
+		}
+		word := toks[i].Upper()
+		if start < 0 {
+			if word == "CONFLICT" && s.Kind != "SELECT" {
+				return -1, -1
+			}
+			if word == keyword {
+				start = i + 1
+			}
+			continue
+		}
+		if clauseEnd[word] || (keyword == "LIMIT" && word == "OFFSET") {
+			return start, i
+		}
+	}
+	if start < 0 {
+		return -1, -1
+	}
+	return start, len(toks)
+}
+
+// sqlRange returns the source text covering tokens [start, end).
+func (s *Statement) sqlRange(start, end int) string {
+	if start >= end {
+		return ""
+	}
+	return s.src[s.tokens[start].Pos:s.tokens[end-1].End()]
+}
+
+// splitPredicates splits tokens [start, end) on depth-0 AND, keeping the
+// AND that belongs to BETWEEN x AND y inside its conjunct.
+func (s *Statement) splitPredicates(start, end int) []Predicate {
+	var preds []Predicate
+	depth := 0
+	between := false
+	from := start
+	for i := start; i < end; i++ {
+		switch s.tokens[i].Text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if depth != 0 {
+			continue
+		}
+		switch s.tokens[i].Upper() {
+		case "BETWEEN":
+			between = true
+		case "AND":
+			if between {
+				between = false
+				continue
+			}
+			preds = append(preds, s.predicate(from, i))
+			from = i + 1
+		}
+	}
+	if from < end {
+		preds = append(preds, s.predicate(from, end))
+	}
+	return preds
+}
+
+// comparisonOps are the operators recognised in "column op value".
+var comparisonOps = map[string]bool{
+	"=": true, "==": true, "!=": true, "<>": true, "<": true, ">": true, "<=": true, ">=": true,
+}
+
+// predicate summarises tokens [start, end) as a Predicate.
+func (s *Statement) predicate(start, end int) Predicate {
+	toks := s.tokens[start:end]
+	p := Predicate{Text: s.sqlRange(start, end)}
+	if len(toks) < 2 || toks[0].Ident() == "" || toks[0].Kind == TokString {
+		return p
+	}
+	if toks[0].Kind == TokWord && keywords[toks[0].Upper()] {
+		return p
+	}
+	// "a = 1 OR b = 2" is a disjunction, not a simple comparison
+	depth := 0
+	for _, t := range toks {
+		switch t.Text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if depth == 0 && t.Upper() == "OR" {
+			return p
+		}
+	}
+
+	col := toks[0].Ident()
+	i := 1
+	if i+1 < len(toks) && toks[i].Text == "." {
+		col = toks[i+1].Ident()
+		i += 2
+	}
+	if i >= len(toks) {
+		return p
+	}
+
+	op := toks[i].Upper()
+	if op == "" {
+		op = toks[i].Text
+	}
+	i++
+	switch {
+	case comparisonOps[op], op == "LIKE", op == "GLOB", op == "BETWEEN":
+	case op == "IS" || op == "NOT":
+		if i < len(toks) && (toks[i].Upper() == "NOT" || toks[i].Upper() == "IN" || toks[i].Upper() == "LIKE") {
+			op += " " + toks[i].Upper()
+			i++
+		}
+	case op == "IN":
+	default:
+		return p
+	}
+	p.Column = col
+	p.Op = op
+
+	if strings.HasSuffix(op, "IN") && i < len(toks) && toks[i].Text == "(" {
+		closeIdx := skipParens(toks, i) - 1
+		inner := toks[i+1 : closeIdx]
+		if len(inner) > 0 && (inner[0].Upper() == "SELECT" || inner[0].Upper() == "WITH") {
+			p.Subquery = true
+			return p
+		}
+		p.Literal = true
+		add := func(v []Token) {
+			p.Values = append(p.Values, joinTokens(v))
+			p.Literal = p.Literal && isLiteral(v)
+		}
+		depth := 0
+		from := 0
+		for k, t := range inner {
+			switch t.Text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			case ",":
+				if depth == 0 {
+					add(inner[from:k])
+					from = k + 1
+				}
+			}
+		}
+		if from < len(inner) {
+			add(inner[from:])
+		}
+		return p
+	}
+
+	if i < len(toks) {
+		if toks[i].Text == "(" && i+1 < len(toks) && (toks[i+1].Upper() == "SELECT" || toks[i+1].Upper() == "WITH") {
+			p.Subquery = true
+		}
+		p.Values = []string{joinTokens(toks[i:])}
+		p.Literal = isLiteral(toks[i:])
+	}
+	return p
+}
+
+// isLiteral reports whether toks is a single literal value or parameter,
+// optionally negated.
+func isLiteral(toks []Token) bool {
+	if len(toks) == 2 && (toks[0].Text == "-" || toks[0].Text == "+") && toks[1].Kind == TokNumber {
+		return true
+	}
+	if len(toks) != 1 {
+		return false
+	}
+	switch toks[0].Kind {
+	case TokNumber, TokString, TokBlob, TokParam:
+		return true
+	}
+	return toks[0].Upper() == "NULL" || toks[0].Upper() == "TRUE" || toks[0].Upper() == "FALSE"
+}
+
+// joinTokens renders tokens back to text separated by single spaces.
+func joinTokens(toks []Token) string {
+	parts := make([]string, len(toks))
+	for i, t := range toks {
+		parts[i] = t.Text
+	}
+	return strings.Join(parts, " ")
+}
+
+// skipParens returns the index just past the parenthesis group that opens
+// at toks[i]. Unbalanced input returns len(toks).
+func skipParens(toks []Token, i int) int {
+	depth := 0
+	for ; i < len(toks); i++ {
+		switch toks[i].Text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(toks)
+}
+
+// keywords are bare words that never name a table or column in the
+// positions this parser inspects.
+var keywords = map[string]bool{
+	"ABORT": true, "ALL": true, "AND": true, "AS": true, "ASC": true, "BETWEEN": true,
+	"BY": true, "CASE": true, "CAST": true, "COLLATE": true, "CONFLICT": true,
+	"CROSS": true, "CURRENT_DATE": true, "CURRENT_TIME": true, "CURRENT_TIMESTAMP": true,
+	"DEFAULT": true, "DELETE": true, "DESC": true, "DISTINCT": true, "DO": true,
+	"ELSE": true, "END": true, "ESCAPE": true, "EXCEPT": true, "EXISTS": true,
+	"FAIL": true, "FALSE": true, "FILTER": true, "FROM": true, "FULL": true,
+	"GLOB": true, "GROUP": true, "HAVING": true, "IGNORE": true, "IN": true,
+	"INDEXED": true, "INNER": true, "INSERT": true, "INTERSECT": true, "INTO": true,
+	"IS": true, "ISNULL": true, "JOIN": true, "LEFT": true, "LIKE": true, "LIMIT": true,
+	"MATCH": true, "NATURAL": true, "NOT": true, "NOTHING": true, "NOTNULL": true,
+	"NULL": true, "OFFSET": true, "ON": true, "OR": true, "ORDER": true, "OUTER": true,
+	"OVER": true, "REGEXP": true, "REPLACE": true, "RETURNING": true, "RIGHT": true,
+	"ROLLBACK": true, "SELECT": true, "SET": true, "THEN": true, "TRUE": true,
+	"UNION": true, "UPDATE": true, "USING": true, "VALUES": true, "WHEN": true,
+	"WHERE": true, "WINDOW": true, "WITH": true,
+}
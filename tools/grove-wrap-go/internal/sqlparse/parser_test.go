@@ -0,0 +1,246 @@
+package sqlparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func parseOne(t *testing.T, sql string) *Statement {
+	t.Helper()
+	script, err := Parse(sql)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", sql, err)
+	}
+	if len(script.Statements) != 1 {
+		t.Fatalf("Parse(%q) gave %d statements, want 1", sql, len(script.Statements))
+	}
+	return script.Statements[0]
+}
+
+func TestParseKindTargetAndTables(t *testing.T) {
+	tests := []struct {
+		sql    string
+		kind   string
+		target string
+		tables []string
+	}{
+		{"SELECT * FROM posts", "SELECT", "", []string{"posts"}},
+		{"select p.id from posts p join users u on u.id = p.author_id", "SELECT", "", []string{"posts", "users"}},
+		{"SELECT * FROM posts, comments c WHERE c.post_id = posts.id", "SELECT", "", []string{"posts", "comments"}},
+		{"DELETE FROM posts WHERE id = 1", "DELETE", "posts", []string{"posts"}},
+		{`DELETE FROM "Users" WHERE id = 1`, "DELETE", "users", []string{"users"}},
+		{"DELETE FROM [users] WHERE id = 1", "DELETE", "users", []string{"users"}},
+		{"DELETE FROM main.users WHERE id = 1", "DELETE", "users", []string{"users"}},
+		{"INSERT INTO posts (title) VALUES ('x')", "INSERT", "posts", []string{"posts"}},
+		{"INSERT OR REPLACE INTO posts (title) VALUES ('x')", "INSERT", "posts", []string{"posts"}},
+		{"REPLACE INTO posts (title) VALUES ('x')", "REPLACE", "posts", []string{"posts"}},
+		{"INSERT INTO posts (title) SELECT email FROM users", "INSERT", "posts", []string{"posts", "users"}},
+		{"UPDATE posts SET author = u.name FROM users u WHERE u.id = posts.author_id", "UPDATE", "posts", []string{"posts", "users"}},
+		{"UPDATE OR IGNORE posts SET title = 'x' WHERE id = 1", "UPDATE", "posts", []string{"posts"}},
+		{"WITH x AS (SELECT id FROM users) DELETE FROM posts WHERE author_id IN (SELECT id FROM x)", "DELETE", "posts", []string{"users", "posts"}},
+		{"WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t) SELECT n FROM t", "SELECT", "", nil},
+		{"DELETE FROM posts WHERE author_id IN (SELECT id FROM sessions)", "DELETE", "posts", []string{"posts", "sessions"}},
+		{"SELECT value FROM json_each('[1,2]')", "SELECT", "", nil},
+		{"INSERT INTO posts (id) VALUES (1) ON CONFLICT (id) DO UPDATE SET title = 'x'", "INSERT", "posts", []string{"posts"}},
+		{"SELECT * FROM posts WHERE a IS NOT DISTINCT FROM b", "SELECT", "", []string{"posts"}},
+		{"DROP TABLE IF EXISTS users", "DROP", "users", []string{"users"}},
+		{"CREATE INDEX idx_posts ON posts (slug)", "CREATE", "idx_posts", []string{"posts"}},
+		{"CREATE UNIQUE INDEX idx_posts ON posts (slug)", "CREATE", "idx_posts", []string{"posts"}},
+		{"EXPLAIN QUERY PLAN DELETE FROM posts", "DELETE", "posts", []string{"posts"}},
+	}
+
+	for _, tt := range tests {
+		s := parseOne(t, tt.sql)
+		if s.Kind != tt.kind {
+			t.Errorf("%q: Kind = %q, want %q", tt.sql, s.Kind, tt.kind)
+		}
+		if s.Target != tt.target {
+			t.Errorf("%q: Target = %q, want %q", tt.sql, s.Target, tt.target)
+		}
+		if !reflect.DeepEqual(s.Tables, tt.tables) {
+			t.Errorf("%q: Tables = %v, want %v", tt.sql, s.Tables, tt.tables)
+		}
+	}
+}
+
+func TestParseSplitsStatements(t *testing.T) {
+	script, err := Parse("SELECT 'a;b' FROM posts; DELETE FROM users WHERE id = 1;")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(script.Statements) != 2 {
+		t.Fatalf("got %d statements, want 2", len(script.Statements))
+	}
+	if script.Statements[1].Kind != "DELETE" || script.Statements[1].Target != "users" {
+		t.Errorf("second statement = %+v", script.Statements[1])
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	script, _ := Parse("SELECT 1 -- note")
+	if !script.HasComments {
+		t.Error("comment should be detected")
+	}
+	script, _ = Parse("SELECT '-- not a comment', '/* nor this */'")
+	if script.HasComments {
+		t.Error("comment markers inside strings are not comments")
+	}
+}
+
+func TestParseWhereAndPredicates(t *testing.T) {
+	s := parseOne(t, "DELETE FROM posts WHERE tenant_id = 'abc' AND created BETWEEN 1 AND 5 AND id IN (1, 2, 3) ORDER BY id LIMIT 10")
+	if !s.HasWhere {
+		t.Fatal("WHERE not found")
+	}
+	if len(s.Predicates) != 3 {
+		t.Fatalf("got %d predicates, want 3: %+v", len(s.Predicates), s.Predicates)
+	}
+	if p := s.Predicates[0]; p.Column != "tenant_id" || p.Op != "=" || !p.Literal {
+		t.Errorf("predicate 0 = %+v", p)
+	}
+	if p := s.Predicates[1]; p.Column != "created" || p.Op != "BETWEEN" {
+		t.Errorf("predicate 1 = %+v", p)
+	}
+	if p := s.Predicates[2]; p.Column != "id" || p.Op != "IN" || len(p.Values) != 3 {
+		t.Errorf("predicate 2 = %+v", p)
+	}
+	if !s.HasLimit || s.Limit != 10 {
+		t.Errorf("LIMIT = %v %d, want 10", s.HasLimit, s.Limit)
+	}
+}
+
+func TestParseSubqueryWhereIsNotStatementWhere(t *testing.T) {
+	s := parseOne(t, "DELETE FROM posts WHERE id IN (SELECT post_id FROM flags WHERE n > 1)")
+	if len(s.Predicates) != 1 || !s.Predicates[0].Subquery {
+		t.Errorf("predicates = %+v", s.Predicates)
+	}
+
+	s = parseOne(t, "UPDATE posts SET title = (SELECT title FROM drafts WHERE drafts.id = 1)")
+	if s.HasWhere {
+		t.Error("WHERE inside a subquery should not count as the statement's WHERE")
+	}
+
+	s = parseOne(t, "INSERT INTO posts (id) VALUES (1) ON CONFLICT (id) WHERE id > 0 DO NOTHING")
+	if s.HasWhere {
+		t.Error("upsert WHERE should not count as the statement's WHERE")
+	}
+}
+
+func TestParseDisjunctionIsNotSimple(t *testing.T) {
+	s := parseOne(t, "DELETE FROM posts WHERE id = 1 OR 1 = 1")
+	if len(s.Predicates) != 1 || s.Predicates[0].Column != "" {
+		t.Errorf("OR conjunct should not be summarised as a comparison: %+v", s.Predicates)
+	}
+}
+
+func TestParseColumnComparisonIsNotLiteral(t *testing.T) {
+	s := parseOne(t, "DELETE FROM posts WHERE id = id")
+	if s.Predicates[0].Literal {
+		t.Error("comparison against a column should not be literal")
+	}
+}
+
+func TestWhereIsConstant(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"DELETE FROM posts WHERE 1", true},
+		{"DELETE FROM posts WHERE 1 = 1", true},
+		{"DELETE FROM posts WHERE 'a' = 'a' AND abs(-1) = 1", true},
+		{"DELETE FROM posts WHERE TRUE", true},
+		{"DELETE FROM posts WHERE id = 1", false},
+		{`DELETE FROM posts WHERE "id" = 1`, false},
+		{"DELETE FROM posts", false},
+	}
+	for _, tt := range tests {
+		if got := parseOne(t, tt.sql).WhereIsConstant(); got != tt.want {
+			t.Errorf("WhereIsConstant(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestParseLimitForms(t *testing.T) {
+	tests := []struct {
+		sql   string
+		limit int
+	}{
+		{"SELECT * FROM posts LIMIT 5", 5},
+		{"SELECT * FROM posts LIMIT 5 OFFSET 10", 5},
+		{"SELECT * FROM posts LIMIT 10, 5", 5},
+		{"SELECT * FROM posts LIMIT ?", -1},
+		{"SELECT * FROM posts WHERE id IN (SELECT id FROM t LIMIT 3)", -1},
+	}
+	for _, tt := range tests {
+		if got := parseOne(t, tt.sql).Limit; got != tt.limit {
+			t.Errorf("Limit(%q) = %d, want %d", tt.sql, got, tt.limit)
+		}
+	}
+}
+
+func TestParseStatementClassification(t *testing.T) {
+	tests := []struct {
+		sql      string
+		mutation bool
+		ddl      bool
+		read     bool
+	}{
+		{"SELECT 1", false, false, true},
+		{"EXPLAIN DELETE FROM posts", false, false, true},
+		{"WITH x AS (SELECT 1) INSERT INTO posts SELECT * FROM x", true, false, false},
+		{"PRAGMA table_info(posts)", false, false, true},
+		{"PRAGMA foreign_keys = OFF", false, false, false},
+		{"PRAGMA foreign_keys", false, false, true},
+		{"PRAGMA foreign_keys(0)", false, false, false},
+		{"PRAGMA writable_schema(1)", false, false, false},
+		{"PRAGMA main.journal_mode(DELETE)", false, false, false},
+		{"PRAGMA main.index_list(posts)", false, false, true},
+		{`PRAGMA "table_info"("posts")`, false, false, true},
+		{"ALTER TABLE posts ADD COLUMN x TEXT", false, true, false},
+	}
+	for _, tt := range tests {
+		s := parseOne(t, tt.sql)
+		if s.IsMutation() != tt.mutation || s.IsDDL() != tt.ddl || s.IsRead() != tt.read {
+			t.Errorf("%q: mutation=%v ddl=%v read=%v, want %v %v %v",
+				tt.sql, s.IsMutation(), s.IsDDL(), s.IsRead(), tt.mutation, tt.ddl, tt.read)
+		}
+	}
+}
+
+func TestParseCompound(t *testing.T) {
+	if !parseOne(t, "SELECT a FROM posts UNION SELECT b FROM users").Compound {
+		t.Error("UNION should mark the statement compound")
+	}
+	if parseOne(t, "SELECT 'union' FROM posts").Compound {
+		t.Error("UNION inside a string is not compound")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT 'unterminated",
+		"WITH x SELECT 1",
+		"(SELECT 1)",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("expected error for %q", sql)
+		}
+	}
+}
+
+func TestParseTriggerBodyStaysInOneStatement(t *testing.T) {
+	script, err := Parse(`CREATE TRIGGER touch AFTER UPDATE ON posts BEGIN
+		UPDATE posts SET updated = CASE WHEN 1 THEN 2 END WHERE id = NEW.id;
+		DELETE FROM drafts WHERE post_id = NEW.id;
+	END;
+	DELETE FROM posts WHERE id = 1;`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(script.Statements) != 2 {
+		t.Fatalf("got %d statements, want 2", len(script.Statements))
+	}
+	if script.Statements[0].Kind != "CREATE" || script.Statements[1].Kind != "DELETE" {
+		t.Errorf("kinds = %s, %s", script.Statements[0].Kind, script.Statements[1].Kind)
+	}
+}
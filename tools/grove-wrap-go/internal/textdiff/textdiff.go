@@ -0,0 +1,144 @@
+// Package textdiff compares two texts line by line, for showing what a
+// write would change before it happens.
+package textdiff
+
+import "strings"
+
+// Op says what happened to a line.
+type Op byte
+
+const (
+	Same   Op = ' '
+	Delete Op = '-'
+	Insert Op = '+'
+)
+
+// Line is one line of a diff.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// maxCells bounds the comparison table. Texts larger than this are shown
+// as wholly replaced rather than compared.
+const maxCells = 4_000_000
+
+// Lines returns the shortest edit from a to b as a sequence of lines.
+// Deletions come before insertions where both replace the same lines.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	// Trim the common ends so the table covers only what changed
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+
+	var out []Line
+	for _, l := range x[:pre] {
+		out = append(out, Line{Same, l})
+	}
+	out = append(out, middle(x[pre:len(x)-suf], y[pre:len(y)-suf])...)
+	for _, l := range x[len(x)-suf:] {
+		out = append(out, Line{Same, l})
+	}
+	return out
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// middle diffs the changed region by longest common subsequence.
+func middle(x, y []string) []Line {
+	var out []Line
+	if len(x)*len(y) > maxCells || len(x) == 0 || len(y) == 0 {
+		for _, l := range x {
+			out = append(out, Line{Delete, l})
+		}
+		for _, l := range y {
+			out = append(out, Line{Insert, l})
+		}
+		return out
+	}
+
+	// lcs[i][j] is the common subsequence length of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			out = append(out, Line{Same, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, Line{Delete, x[i]})
+			i++
+		default:
+			out = append(out, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		out = append(out, Line{Delete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		out = append(out, Line{Insert, y[j]})
+	}
+	return out
+}
+
+// Changed reports whether a diff has any insertions or deletions.
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Same {
+			return true
+		}
+	}
+	return false
+}
+
+// Hunks groups the changes of a diff with up to context unchanged lines
+// around each. Changes closer together than twice that share a hunk.
+func Hunks(lines []Line, context int) [][]Line {
+	var hunks [][]Line
+	start, end := -1, -1 // the open hunk is lines[start:end]
+	for i, l := range lines {
+		if l.Op == Same {
+			continue
+		}
+		from, to := max(i-context, 0), min(i+context+1, len(lines))
+		if start >= 0 && from > end {
+			hunks = append(hunks, lines[start:end])
+			start = -1
+		}
+		if start < 0 {
+			start = from
+		}
+		end = to
+	}
+	if start >= 0 {
+		hunks = append(hunks, lines[start:end])
+	}
+	return hunks
+}
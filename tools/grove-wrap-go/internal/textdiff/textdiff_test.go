@@ -0,0 +1,68 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func render(lines []Line) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(string(l.Op) + l.Text + "\n")
+	}
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	a := "{\n  \"enabled\": false,\n  \"percent\": 10,\n  \"owner\": \"ops\"\n}\n"
+	b := "{\n  \"enabled\": true,\n  \"percent\": 10,\n  \"owner\": \"ops\",\n  \"note\": \"rollout\"\n}"
+	want := ` {
+-  "enabled": false,
++  "enabled": true,
+   "percent": 10,
+-  "owner": "ops"
++  "owner": "ops",
++  "note": "rollout"
+ }
+`
+	got := Lines(a, b)
+	if render(got) != want {
+		t.Errorf("Lines =\n%s\nwant\n%s", render(got), want)
+	}
+	if !Changed(got) {
+		t.Error("Changed = false")
+	}
+	if Changed(Lines(a, a)) {
+		t.Error("identical texts reported as changed")
+	}
+	if got := render(Lines("", "x")); got != "+x\n" {
+		t.Errorf("from empty = %q", got)
+	}
+	if got := render(Lines("x", "")); got != "-x\n" {
+		t.Errorf("to empty = %q", got)
+	}
+}
+
+func TestHunks(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 20; i++ {
+		a = append(a, string(rune('a'+i)))
+	}
+	b = append(b, a...)
+	b[2] = "C"
+	b[4] = "E"
+	b[15] = "P"
+	hunks := Hunks(Lines(strings.Join(a, "\n"), strings.Join(b, "\n")), 2)
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2:\n%v", len(hunks), hunks)
+	}
+	if got, want := render(hunks[0]), " a\n b\n-c\n+C\n d\n-e\n+E\n f\n g\n"; got != want {
+		t.Errorf("first hunk =\n%s\nwant\n%s", got, want)
+	}
+	if got, want := render(hunks[1]), " n\n o\n-p\n+P\n q\n r\n"; got != want {
+		t.Errorf("second hunk =\n%s\nwant\n%s", got, want)
+	}
+	if Hunks(Lines("same", "same"), 3) != nil {
+		t.Error("hunks for an unchanged text")
+	}
+}
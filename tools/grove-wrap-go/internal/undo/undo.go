@@ -0,0 +1,320 @@
+// Package undo keeps a journal of the state gw overwrote, so cloud writes
+// can be reversed with gw undo.
+//
+// Each entry is one JSON file under ~/.grove/undo/, named by its ID. An
+// entry records the resource, its state before the write, and a digest of
+// the state the write left behind; undo compares that digest with the live
+// resource to warn when something changed in between. Object contents that
+// are too large for JSON (R2) are kept in a sibling .blob file.
+//
+// Entries are written before the change they describe and marked complete
+// after it succeeds, so a crash mid-write still leaves a way back.
+package undo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	groveDir = ".grove"
+	undoDir  = "undo"
+
+	// maxEntries is how many entries are kept; older ones are pruned.
+	maxEntries = 200
+)
+
+// Resource kinds.
+const (
+	KindKV     = "kv"
+	KindR2     = "r2"
+	KindSecret = "secret"
+)
+
+// HMACPrefix marks a Digest that is a keyed HMAC rather than a SHA-256.
+const HMACPrefix = "hmac:"
+
+// ErrNotFound is returned by Get for an unknown entry ID.
+var ErrNotFound = errors.New("undo entry not found")
+
+// State is a resource's content at one point in time.
+type State struct {
+	// Value is the KV value, or the secret entry sealed with the vault key.
+	Value string `json:"value,omitempty"`
+	// Digest is the SHA-256 of the content, or for secrets HMACPrefix and
+	// an HMAC keyed by the vault, so the journal holds no plain hash of a
+	// secret value.
+	Digest string `json:"digest,omitempty"`
+	Size   int64  `json:"size"`
+	// Metadata and Expiration are the KV key's metadata and Unix expiry.
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+	Expiration int64           `json:"expiration,omitempty"`
+	// Blob names the file in the journal holding R2 object content.
+	Blob string `json:"blob,omitempty"`
+}
+
+// Entry is one journaled write.
+type Entry struct {
+	ID        int    `json:"id"`
+	Timestamp string `json:"timestamp"`
+	// Command is the gw command that made the change, e.g. "gw kv put".
+	Command string `json:"command"`
+	Kind    string `json:"kind"`
+
+	// Resource location. Namespace is the alias the user typed and
+	// NamespaceID what it resolved to; Key is the KV key, R2 object key,
+	// or secret name.
+	Namespace   string `json:"namespace,omitempty"`
+	NamespaceID string `json:"namespace_id,omitempty"`
+	Bucket      string `json:"bucket,omitempty"`
+	Key         string `json:"key"`
+
+	// Before is nil when the resource did not exist; After is nil when the
+	// command removed it.
+	Before *State `json:"before"`
+	After  *State `json:"after"`
+
+	// Pending is set until the change is confirmed to have happened.
+	Pending bool `json:"pending,omitempty"`
+	// UndoneAt is when the entry was undone, and UndoneBy the entry that
+	// journaled the undo itself.
+	UndoneAt string `json:"undone_at,omitempty"`
+	UndoneBy int    `json:"undone_by,omitempty"`
+}
+
+// Resource formats the entry's target for display.
+func (e *Entry) Resource() string {
+	switch e.Kind {
+	case KindKV:
+		return e.Namespace + ":" + e.Key
+	case KindR2:
+		return e.Bucket + "/" + e.Key
+	}
+	return e.Key
+}
+
+// Digest returns the hex SHA-256 of data.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Same reports whether two states have the same content. Two nil states
+// (resource absent) are the same; a state without a digest (secrets
+// journaled before they had one) cannot be compared and is never the same.
+func Same(a, b *State) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Digest != "" && a.Digest == b.Digest
+}
+
+// Journal is the directory of undo entries.
+type Journal struct {
+	dir string
+}
+
+// DefaultDir returns ~/.grove/undo.
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, groveDir, undoDir)
+}
+
+// Open returns the journal in the default directory.
+func Open() (*Journal, error) {
+	dir := DefaultDir()
+	if dir == "" {
+		return nil, fmt.Errorf("cannot determine home directory for undo journal")
+	}
+	return OpenDir(dir), nil
+}
+
+// OpenDir returns the journal stored in dir.
+func OpenDir(dir string) *Journal {
+	return &Journal{dir: dir}
+}
+
+// Dir returns the journal directory.
+func (j *Journal) Dir() string {
+	return j.dir
+}
+
+// BlobPath returns the path of a blob file named by State.Blob.
+func (j *Journal) BlobPath(name string) string {
+	return filepath.Join(j.dir, filepath.Base(name))
+}
+
+// Reserve claims the next free entry ID and sets it and the timestamp on e,
+// marking it pending. Callers snapshot blobs under that ID and then call
+// Save.
+func (j *Journal) Reserve(e *Entry) error {
+	if err := os.MkdirAll(j.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create undo directory: %w", err)
+	}
+	ids, err := j.ids()
+	if err != nil {
+		return err
+	}
+	next := 1
+	if len(ids) > 0 {
+		next = ids[len(ids)-1] + 1
+	}
+
+	// Another gw process may claim the same ID; O_EXCL settles it
+	for attempt := 0; attempt < 100; attempt++ {
+		f, err := os.OpenFile(j.entryPath(next), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			next++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create undo entry: %w", err)
+		}
+		f.Close()
+
+		e.ID = next
+		e.Timestamp = time.Now().UTC().Format(time.RFC3339)
+		e.Pending = true
+		return nil
+	}
+	return fmt.Errorf("failed to allocate an undo entry ID")
+}
+
+// Add reserves an ID for e, writes it, and prunes the journal.
+func (j *Journal) Add(e *Entry) error {
+	if err := j.Reserve(e); err != nil {
+		return err
+	}
+	if err := j.Save(e); err != nil {
+		return err
+	}
+	j.Prune()
+	return nil
+}
+
+// Save writes e over its existing file.
+func (j *Journal) Save(e *Entry) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal undo entry: %w", err)
+	}
+	tmp := j.entryPath(e.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write undo entry: %w", err)
+	}
+	if err := os.Rename(tmp, j.entryPath(e.ID)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write undo entry: %w", err)
+	}
+	return nil
+}
+
+// Complete clears Pending once the journaled change has happened.
+func (j *Journal) Complete(e *Entry) error {
+	e.Pending = false
+	return j.Save(e)
+}
+
+// Remove deletes an entry and its blobs.
+func (j *Journal) Remove(e *Entry) error {
+	for _, s := range []*State{e.Before, e.After} {
+		if s != nil && s.Blob != "" {
+			os.Remove(j.BlobPath(s.Blob))
+		}
+	}
+	if err := os.Remove(j.entryPath(e.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove undo entry: %w", err)
+	}
+	return nil
+}
+
+// Get loads one entry.
+func (j *Journal) Get(id int) (*Entry, error) {
+	data, err := os.ReadFile(j.entryPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: #%d", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read undo entry: %w", err)
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("undo entry #%d is corrupt: %w", id, err)
+	}
+	return &e, nil
+}
+
+// List returns up to limit entries, newest first. A limit of 0 returns all.
+// Entries that cannot be read (half-written reservations) are skipped.
+func (j *Journal) List(limit int) ([]Entry, error) {
+	ids, err := j.ids()
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for i := len(ids) - 1; i >= 0; i-- {
+		e, err := j.Get(ids[i])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, *e)
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// ids returns the IDs of all entry files in ascending order.
+func (j *Journal) ids() ([]int, error) {
+	files, err := os.ReadDir(j.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read undo directory: %w", err)
+	}
+	var ids []int
+	for _, f := range files {
+		name, ok := strings.CutSuffix(f.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if id, err := strconv.Atoi(name); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// Prune removes the oldest entries beyond maxEntries.
+func (j *Journal) Prune() {
+	ids, err := j.ids()
+	if err != nil || len(ids) <= maxEntries {
+		return
+	}
+	for _, id := range ids[:len(ids)-maxEntries] {
+		if e, err := j.Get(id); err == nil {
+			j.Remove(e)
+		} else {
+			os.Remove(j.entryPath(id))
+		}
+	}
+}
+
+func (j *Journal) entryPath(id int) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%06d.json", id))
+}
@@ -0,0 +1,115 @@
+package undo
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestJournalAddGetList(t *testing.T) {
+	j := OpenDir(t.TempDir())
+
+	first := &Entry{Command: "gw kv put", Kind: KindKV, Namespace: "cache", Key: "a", After: &State{Digest: Digest([]byte("x"))}}
+	second := &Entry{Command: "gw kv delete", Kind: KindKV, Namespace: "cache", Key: "b", Before: &State{Value: "old"}}
+	for _, e := range []*Entry{first, second} {
+		if err := j.Add(e); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if first.ID != 1 || second.ID != 2 || !first.Pending || first.Timestamp == "" {
+		t.Fatalf("IDs = %d, %d; pending=%v", first.ID, second.ID, first.Pending)
+	}
+
+	if err := j.Complete(first); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	got, err := j.Get(1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Pending || got.Resource() != "cache:a" {
+		t.Errorf("entry 1 = %+v", got)
+	}
+
+	entries, err := j.List(0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != 2 {
+		t.Errorf("List should be newest first: %+v", entries)
+	}
+	if _, err := j.Get(9); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(9) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestJournalRemoveDeletesBlobs(t *testing.T) {
+	j := OpenDir(t.TempDir())
+	e := &Entry{Kind: KindR2, Bucket: "b", Key: "k"}
+	if err := j.Reserve(e); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	blob := "000001-before.blob"
+	if err := os.WriteFile(j.BlobPath(blob), []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e.Before = &State{Blob: blob}
+	if err := j.Save(e); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if err := j.Remove(e); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(j.BlobPath(blob)); !os.IsNotExist(err) {
+		t.Error("blob should be removed with its entry")
+	}
+	if _, err := j.Get(e.ID); !errors.Is(err, ErrNotFound) {
+		t.Error("entry should be gone")
+	}
+}
+
+func TestJournalListSkipsReservations(t *testing.T) {
+	j := OpenDir(t.TempDir())
+	if err := j.Reserve(&Entry{}); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := j.Add(&Entry{Key: "k"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	entries, _ := j.List(0)
+	if len(entries) != 1 || entries[0].ID != 2 {
+		t.Errorf("List = %+v, want only the saved entry #2", entries)
+	}
+}
+
+func TestJournalPrune(t *testing.T) {
+	j := OpenDir(t.TempDir())
+	for i := 0; i < maxEntries+3; i++ {
+		if err := j.Add(&Entry{Key: "k"}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	entries, _ := j.List(0)
+	if len(entries) != maxEntries {
+		t.Fatalf("kept %d entries, want %d", len(entries), maxEntries)
+	}
+	if oldest := entries[len(entries)-1].ID; oldest != 4 {
+		t.Errorf("oldest kept = #%d, want #4", oldest)
+	}
+}
+
+func TestSame(t *testing.T) {
+	a := &State{Digest: Digest([]byte("x"))}
+	b := &State{Digest: Digest([]byte("x"))}
+	c := &State{Digest: Digest([]byte("y"))}
+	if !Same(nil, nil) || !Same(a, b) {
+		t.Error("equal states should be the same")
+	}
+	if Same(a, c) || Same(a, nil) || Same(nil, a) {
+		t.Error("different states should differ")
+	}
+	if Same(&State{Size: 1}, &State{Size: 1}) {
+		t.Error("states without a digest cannot be known to be the same")
+	}
+}
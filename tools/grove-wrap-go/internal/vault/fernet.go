@@ -1,7 +1,9 @@
-// Package vault implements an encrypted secrets vault compatible with
-// Python gw's Fernet-based storage.
+// Package vault implements an encrypted secrets vault using the same
+// Fernet encryption as Python gw. Version 1 files, which Python gw also
+// writes, are read and migrated to version 2, whose per-environment
+// secrets Python gw cannot read.
 //
-// File format: [version: 1 byte (0x01)] [salt: 16 bytes] [fernet token: rest]
+// File format: [version: 1 byte (0x02)] [salt: 16 bytes] [fernet token: rest]
 // Key derivation: PBKDF2-SHA256(password, salt, 100000, 32) → base64url
 // Fernet spec: https://github.com/fernet/spec/blob/master/Spec.md
 package vault
@@ -25,8 +27,14 @@ const (
 	// fernetVersion is the Fernet token version byte.
 	fernetVersion = 0x80
 
-	// vaultFileVersion is our vault file format version.
-	vaultFileVersion = 0x01
+	// vaultFileVersion is our vault file format version. Version 2 keys
+	// each secret's values by environment; version 1 vaults held one value
+	// per secret and are migrated when unlocked.
+	vaultFileVersion = 0x02
+
+	// legacyVaultFileVersion is the version 1 format, also written by the
+	// Python gw.
+	legacyVaultFileVersion = 0x01
 
 	// saltLen is the PBKDF2 salt length in bytes.
 	saltLen = 16
@@ -39,10 +47,10 @@ const (
 )
 
 var (
-	ErrBadToken    = errors.New("fernet: invalid token")
-	ErrBadVersion  = errors.New("fernet: wrong token version")
-	ErrBadHMAC     = errors.New("fernet: HMAC verification failed")
-	ErrBadPadding  = errors.New("fernet: invalid PKCS7 padding")
+	ErrBadToken     = errors.New("fernet: invalid token")
+	ErrBadVersion   = errors.New("fernet: wrong token version")
+	ErrBadHMAC      = errors.New("fernet: HMAC verification failed")
+	ErrBadPadding   = errors.New("fernet: invalid PKCS7 padding")
 	ErrTokenExpired = errors.New("fernet: token expired")
 )
 
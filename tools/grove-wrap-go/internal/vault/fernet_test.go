@@ -230,8 +230,8 @@ func TestPythonVaultFormatCompat(t *testing.T) {
 		t.Fatalf("decrypt failed: %v", err)
 	}
 
-	// Step 1: Try Go format
-	var data vaultData
+	// Step 1: Try Go format (version 1 vaults hold one entry per secret)
+	var data legacyVaultData
 	if err := json.Unmarshal(plaintext, &data); err != nil {
 		t.Fatalf("unmarshal failed: %v", err)
 	}
@@ -43,7 +43,25 @@ func GetNewVaultPassword() (string, error) {
 		return pw, nil
 	}
 
-	fmt.Fprint(os.Stderr, "New vault password (min 8 chars): ")
+	return promptNewPassword("New vault password (min 8 chars): ")
+}
+
+// GetReplacementVaultPassword prompts for the password a vault is being
+// rekeyed to, with confirmation. GW_VAULT_PASSWORD holds the current
+// password, so the new one is read from GW_VAULT_NEW_PASSWORD instead.
+func GetReplacementVaultPassword() (string, error) {
+	if pw := os.Getenv("GW_VAULT_NEW_PASSWORD"); pw != "" {
+		if len(pw) < 8 {
+			return "", fmt.Errorf("password must be at least 8 characters")
+		}
+		return pw, nil
+	}
+	return promptNewPassword("New vault password (min 8 chars): ")
+}
+
+// promptNewPassword reads a password of at least 8 characters twice.
+func promptNewPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
 	pw1, err := term.ReadPassword(int(syscall.Stdin))
 	fmt.Fprintln(os.Stderr)
 	if err != nil {
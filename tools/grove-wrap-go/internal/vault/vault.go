@@ -1,11 +1,16 @@
 package vault
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"time"
 )
 
@@ -20,8 +25,50 @@ type SecretEntry struct {
 	DeployedTo map[string]string `json:"deployed_to,omitempty"`
 }
 
+// DefaultEnv holds the value a secret has when no environment is named,
+// and the value every environment without its own falls back to.
+const DefaultEnv = "default"
+
+// Secret holds a secret's entries by environment name.
+type Secret map[string]*SecretEntry
+
+// Envs returns the secret's environment names, sorted.
+func (s Secret) Envs() []string {
+	envs := make([]string, 0, len(s))
+	for env := range s {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	return envs
+}
+
+// envName matches the environment names wrangler accepts.
+var envName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// ValidateEnv checks an environment name.
+func ValidateEnv(env string) error {
+	if !envName.MatchString(env) {
+		return fmt.Errorf("invalid environment %q: use letters, digits, - and _", env)
+	}
+	return nil
+}
+
+// envOrDefault maps the empty environment to DefaultEnv.
+func envOrDefault(env string) string {
+	if env == "" {
+		return DefaultEnv
+	}
+	return env
+}
+
 // vaultData is the JSON structure stored encrypted in the vault file.
 type vaultData struct {
+	Secrets  map[string]Secret `json:"secrets"`
+	Identity string            `json:"identity,omitempty"` // see vaultshare
+}
+
+// legacyVaultData is the version 1 structure, one entry per secret.
+type legacyVaultData struct {
 	Secrets map[string]*SecretEntry `json:"secrets"`
 }
 
@@ -32,6 +79,7 @@ type SecretsVault struct {
 	key      []byte // 32-byte derived key
 	data     *vaultData
 	unlocked bool
+	migrated bool
 }
 
 // DefaultVaultPath returns the default vault file location.
@@ -67,7 +115,7 @@ func Create(password string) (*SecretsVault, error) {
 		salt: salt,
 		key:  key,
 		data: &vaultData{
-			Secrets: make(map[string]*SecretEntry),
+			Secrets: make(map[string]Secret),
 		},
 		unlocked: true,
 	}
@@ -93,7 +141,7 @@ func Unlock(password string) (*SecretsVault, error) {
 
 	// Parse header
 	version := raw[0]
-	if version != vaultFileVersion {
+	if version != vaultFileVersion && version != legacyVaultFileVersion {
 		return nil, fmt.Errorf("unsupported vault version: %d", version)
 	}
 
@@ -107,12 +155,45 @@ func Unlock(password string) (*SecretsVault, error) {
 		return nil, fmt.Errorf("wrong password or corrupted vault")
 	}
 
-	// Parse JSON — handle both Go format {"secrets": {...}} and
-	// Python format {"SECRET_NAME": {"value": "...", ...}, ...}
+	v := &SecretsVault{
+		path:     path,
+		salt:     salt,
+		key:      key,
+		unlocked: true,
+	}
+	if version == legacyVaultFileVersion {
+		secrets, err := parseLegacySecrets(plaintext)
+		if err != nil {
+			return nil, err
+		}
+		v.data = &vaultData{Secrets: make(map[string]Secret, len(secrets))}
+		for name, entry := range secrets {
+			v.data.Secrets[name] = Secret{DefaultEnv: entry}
+		}
+		if err := v.migrate(raw); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
 	var data vaultData
 	if err := json.Unmarshal(plaintext, &data); err != nil {
 		return nil, fmt.Errorf("failed to parse vault data: %w", err)
 	}
+	if data.Secrets == nil {
+		data.Secrets = make(map[string]Secret)
+	}
+	v.data = &data
+	return v, nil
+}
+
+// parseLegacySecrets reads version 1 vault data, in either the Go format
+// {"secrets": {...}} or the Python format {"SECRET_NAME": {"value": "...", ...}, ...}.
+func parseLegacySecrets(plaintext []byte) (map[string]*SecretEntry, error) {
+	var data legacyVaultData
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse vault data: %w", err)
+	}
 	if data.Secrets == nil {
 		data.Secrets = make(map[string]*SecretEntry)
 	}
@@ -128,14 +209,30 @@ func Unlock(password string) (*SecretsVault, error) {
 			data.Secrets = parsePythonSecrets(flat)
 		}
 	}
+	return data.Secrets, nil
+}
 
-	return &SecretsVault{
-		path:     path,
-		salt:     salt,
-		key:      key,
-		data:     &data,
-		unlocked: true,
-	}, nil
+// migrate rewrites a version 1 vault in the current format, each secret's
+// value becoming its default. The old file is kept beside the vault as
+// secrets.enc.v1.bak, since tools that only read version 1 cannot read
+// the new one.
+func (v *SecretsVault) migrate(legacy []byte) error {
+	v.migrated = true
+	if dryRun {
+		return nil
+	}
+	if err := os.WriteFile(v.path+".v1.bak", legacy, 0o600); err != nil {
+		return fmt.Errorf("failed to back up vault before migrating: %w", err)
+	}
+	if err := v.Save(); err != nil {
+		return fmt.Errorf("failed to migrate vault: %w", err)
+	}
+	return nil
+}
+
+// Migrated reports whether unlocking converted a version 1 vault.
+func (v *SecretsVault) Migrated() bool {
+	return v.migrated
 }
 
 // UnlockOrCreate opens the vault if it exists, or creates a new one.
@@ -162,28 +259,30 @@ func AutoUnlock() (*SecretsVault, error) {
 	return Unlock(pw)
 }
 
-// Save encrypts and writes the vault to disk.
+// dryRun makes Save a no-op, so commands previewed with gw --dry-run
+// leave the vault file untouched.
+var dryRun bool
+
+// SetDryRun turns dry-run mode on or off for every vault in the process.
+func SetDryRun(on bool) {
+	dryRun = on
+}
+
+// Save encrypts and writes the vault to disk. In dry-run mode it only
+// checks that the vault is unlocked.
 func (v *SecretsVault) Save() error {
 	if !v.unlocked {
 		return fmt.Errorf("vault is locked")
 	}
-
-	plaintext, err := json.Marshal(v.data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal vault: %w", err)
+	if dryRun {
+		return nil
 	}
 
-	token, err := fernetEncrypt(v.key, plaintext)
+	file, err := v.encode(v.salt, v.key)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt vault: %w", err)
+		return err
 	}
 
-	// Build file: version + salt + token
-	file := make([]byte, 0, 1+saltLen+len(token))
-	file = append(file, vaultFileVersion)
-	file = append(file, v.salt...)
-	file = append(file, token...)
-
 	// Ensure directory exists
 	dir := filepath.Dir(v.path)
 	if err := os.MkdirAll(dir, 0o700); err != nil {
@@ -198,23 +297,144 @@ func (v *SecretsVault) Save() error {
 	return nil
 }
 
-// Get retrieves a secret's value by name.
+// encode encrypts the vault data under key, returning the file contents:
+// version + salt + token.
+func (v *SecretsVault) encode(salt, key []byte) ([]byte, error) {
+	plaintext, err := json.Marshal(v.data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vault: %w", err)
+	}
+
+	token, err := fernetEncrypt(key, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt vault: %w", err)
+	}
+
+	file := make([]byte, 0, 1+saltLen+len(token))
+	file = append(file, vaultFileVersion)
+	file = append(file, salt...)
+	file = append(file, token...)
+	return file, nil
+}
+
+// Rekey re-encrypts the vault under a new password with a fresh salt. The
+// old file is copied to secrets.enc.<timestamp>.bak first, and the new one
+// is written to a temporary file and renamed over the vault, so an
+// interrupted rekey leaves either the old vault or the new one, never a
+// mix. Returns the backup path. In dry-run mode nothing is written.
+func (v *SecretsVault) Rekey(newPassword string) (string, error) {
+	if !v.unlocked {
+		return "", fmt.Errorf("vault is locked")
+	}
+	if len(newPassword) < 8 {
+		return "", fmt.Errorf("password must be at least 8 characters")
+	}
+
+	salt, err := generateSalt()
+	if err != nil {
+		return "", err
+	}
+	key := deriveKey(newPassword, salt)
+	backup := v.path + "." + time.Now().UTC().Format("20060102T150405Z") + ".bak"
+	if dryRun {
+		return backup, nil
+	}
+
+	file, err := v.encode(salt, key)
+	if err != nil {
+		return "", err
+	}
+
+	old, err := os.ReadFile(v.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read vault: %w", err)
+	}
+	if err := os.WriteFile(backup, old, 0o600); err != nil {
+		return "", fmt.Errorf("failed to back up vault: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), ".secrets.enc.*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write vault: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(file); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), v.path); err != nil {
+		return "", fmt.Errorf("failed to replace vault: %w", err)
+	}
+
+	v.salt = salt
+	v.key = key
+	return backup, nil
+}
+
+// Identity returns the private key bundles shared with this vault are
+// opened with, "" if none has been made.
+func (v *SecretsVault) Identity() string {
+	return v.data.Identity
+}
+
+// SetIdentity stores the vault's private sharing key.
+func (v *SecretsVault) SetIdentity(identity string) error {
+	v.data.Identity = identity
+	return v.Save()
+}
+
+// Get retrieves a secret's default value by name.
 func (v *SecretsVault) Get(name string) (string, bool) {
-	entry, ok := v.data.Secrets[name]
+	value, _, ok := v.Resolve(name, DefaultEnv)
+	return value, ok
+}
+
+// Resolve returns the value a secret has in env, falling back to its
+// default value, and the environment the value came from.
+func (v *SecretsVault) Resolve(name, env string) (value, from string, ok bool) {
+	secret, ok := v.data.Secrets[name]
 	if !ok {
-		return "", false
+		return "", "", false
+	}
+	env = envOrDefault(env)
+	if entry, ok := secret[env]; ok {
+		return entry.Value, env, true
 	}
-	return entry.Value, true
+	if entry, ok := secret[DefaultEnv]; ok {
+		return entry.Value, DefaultEnv, true
+	}
+	return "", "", false
 }
 
-// Set stores or updates a secret.
+// Set stores or updates a secret's default value.
 func (v *SecretsVault) Set(name, value string) error {
+	return v.SetEnv(name, DefaultEnv, value)
+}
+
+// SetEnv stores or updates a secret's value in env.
+func (v *SecretsVault) SetEnv(name, env, value string) error {
+	env = envOrDefault(env)
+	if err := ValidateEnv(env); err != nil {
+		return err
+	}
 	now := time.Now().UTC().Format(time.RFC3339)
-	if entry, ok := v.data.Secrets[name]; ok {
+	secret, ok := v.data.Secrets[name]
+	if !ok {
+		secret = Secret{}
+		v.data.Secrets[name] = secret
+	}
+	if entry, ok := secret[env]; ok {
 		entry.Value = value
 		entry.UpdatedAt = now
 	} else {
-		v.data.Secrets[name] = &SecretEntry{
+		secret[env] = &SecretEntry{
 			Value:     value,
 			CreatedAt: now,
 			UpdatedAt: now,
@@ -223,7 +443,7 @@ func (v *SecretsVault) Set(name, value string) error {
 	return v.Save()
 }
 
-// Delete removes a secret by name.
+// Delete removes a secret by name, in every environment.
 func (v *SecretsVault) Delete(name string) error {
 	if _, ok := v.data.Secrets[name]; !ok {
 		return fmt.Errorf("secret '%s' not found", name)
@@ -232,31 +452,86 @@ func (v *SecretsVault) Delete(name string) error {
 	return v.Save()
 }
 
-// Exists checks whether a secret exists.
+// DeleteEnv removes a secret's value in env. The secret goes with its
+// last value.
+func (v *SecretsVault) DeleteEnv(name, env string) error {
+	env = envOrDefault(env)
+	if !v.HasEnv(name, env) {
+		return fmt.Errorf("secret '%s' has no %s value", name, env)
+	}
+	delete(v.data.Secrets[name], env)
+	if len(v.data.Secrets[name]) == 0 {
+		delete(v.data.Secrets, name)
+	}
+	return v.Save()
+}
+
+// Exists checks whether a secret exists in any environment.
 func (v *SecretsVault) Exists(name string) bool {
 	_, ok := v.data.Secrets[name]
 	return ok
 }
 
-// List returns metadata for all secrets (values are NOT included).
-func (v *SecretsVault) List() map[string]*SecretEntry {
+// HasEnv checks whether a secret has its own value in env, not counting
+// the default it would fall back to.
+func (v *SecretsVault) HasEnv(name, env string) bool {
+	_, ok := v.data.Secrets[name][envOrDefault(env)]
+	return ok
+}
+
+// List returns metadata for all secrets by environment (values are NOT
+// included).
+func (v *SecretsVault) List() map[string]Secret {
 	// Return copies without values for safety
-	result := make(map[string]*SecretEntry, len(v.data.Secrets))
-	for name, entry := range v.data.Secrets {
-		result[name] = &SecretEntry{
-			CreatedAt:  entry.CreatedAt,
-			UpdatedAt:  entry.UpdatedAt,
-			DeployedTo: entry.DeployedTo,
+	result := make(map[string]Secret, len(v.data.Secrets))
+	for name, secret := range v.data.Secrets {
+		cp := make(Secret, len(secret))
+		for env, entry := range secret {
+			cp[env] = &SecretEntry{
+				CreatedAt:  entry.CreatedAt,
+				UpdatedAt:  entry.UpdatedAt,
+				DeployedTo: entry.DeployedTo,
+			}
 		}
+		result[name] = cp
 	}
 	return result
 }
 
-// RecordDeployment records that a secret was deployed to a target.
+// Envs returns every environment some secret has a value in, sorted, with
+// DefaultEnv first.
+func (v *SecretsVault) Envs() []string {
+	seen := map[string]bool{}
+	for _, secret := range v.data.Secrets {
+		for env := range secret {
+			seen[env] = true
+		}
+	}
+	envs := make([]string, 0, len(seen))
+	for env := range seen {
+		if env != DefaultEnv {
+			envs = append(envs, env)
+		}
+	}
+	sort.Strings(envs)
+	if seen[DefaultEnv] {
+		envs = append([]string{DefaultEnv}, envs...)
+	}
+	return envs
+}
+
+// RecordDeployment records that a secret's default value was deployed to
+// a target.
 func (v *SecretsVault) RecordDeployment(name, target string) error {
-	entry, ok := v.data.Secrets[name]
+	return v.RecordDeploymentEnv(name, DefaultEnv, target)
+}
+
+// RecordDeploymentEnv records that a secret's value in env was deployed to
+// a target.
+func (v *SecretsVault) RecordDeploymentEnv(name, env, target string) error {
+	entry, ok := v.data.Secrets[name][envOrDefault(env)]
 	if !ok {
-		return fmt.Errorf("secret '%s' not found", name)
+		return fmt.Errorf("secret '%s' has no %s value", name, envOrDefault(env))
 	}
 	if entry.DeployedTo == nil {
 		entry.DeployedTo = make(map[string]string)
@@ -265,18 +540,112 @@ func (v *SecretsVault) RecordDeployment(name, target string) error {
 	return v.Save()
 }
 
-// RemoveDeployment removes a target from a secret's deployment tracking.
+// RemoveDeployment removes a target from a secret's deployment tracking,
+// whichever environment's value was deployed there.
 func (v *SecretsVault) RemoveDeployment(name, target string) error {
-	entry, ok := v.data.Secrets[name]
+	secret, ok := v.data.Secrets[name]
 	if !ok {
 		return fmt.Errorf("secret '%s' not found", name)
 	}
+	for _, entry := range secret {
+		if entry.DeployedTo != nil {
+			delete(entry.DeployedTo, target)
+		}
+	}
+	return v.Save()
+}
+
+// copyEntry copies an entry, deployment records included.
+func copyEntry(entry *SecretEntry) *SecretEntry {
+	cp := *entry
 	if entry.DeployedTo != nil {
-		delete(entry.DeployedTo, target)
+		cp.DeployedTo = make(map[string]string, len(entry.DeployedTo))
+		for k, t := range entry.DeployedTo {
+			cp.DeployedTo[k] = t
+		}
+	}
+	return &cp
+}
+
+// Entry returns a copy of a secret's default entry, value included.
+func (v *SecretsVault) Entry(name string) (*SecretEntry, bool) {
+	entry, ok := v.data.Secrets[name][DefaultEnv]
+	if !ok {
+		return nil, false
+	}
+	return copyEntry(entry), true
+}
+
+// Restore puts back a previously copied default entry under name,
+// timestamps and deployment records included.
+func (v *SecretsVault) Restore(name string, entry *SecretEntry) error {
+	if _, ok := v.data.Secrets[name]; !ok {
+		v.data.Secrets[name] = Secret{}
+	}
+	v.data.Secrets[name][DefaultEnv] = copyEntry(entry)
+	return v.Save()
+}
+
+// Secret returns a copy of a secret in every environment, values included.
+func (v *SecretsVault) Secret(name string) (Secret, bool) {
+	secret, ok := v.data.Secrets[name]
+	if !ok {
+		return nil, false
+	}
+	cp := make(Secret, len(secret))
+	for env, entry := range secret {
+		cp[env] = copyEntry(entry)
+	}
+	return cp, true
+}
+
+// RestoreSecret puts back a previously copied secret, replacing all its
+// environments.
+func (v *SecretsVault) RestoreSecret(name string, secret Secret) error {
+	cp := make(Secret, len(secret))
+	for env, entry := range secret {
+		cp[env] = copyEntry(entry)
 	}
+	v.data.Secrets[name] = cp
 	return v.Save()
 }
 
+// Seal encrypts data with the vault key so secret material can be kept
+// outside the vault file (the undo journal) without being readable.
+func (v *SecretsVault) Seal(data []byte) (string, error) {
+	if !v.unlocked {
+		return "", fmt.Errorf("vault is locked")
+	}
+	token, err := fernetEncrypt(v.key, data)
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+// Unseal decrypts a token produced by Seal. It fails if the vault password
+// has changed since.
+func (v *SecretsVault) Unseal(token string) ([]byte, error) {
+	if !v.unlocked {
+		return nil, fmt.Errorf("vault is locked")
+	}
+	return fernetDecrypt(v.key, []byte(token), 0)
+}
+
+// MAC returns the hex HMAC-SHA256 of data under a key derived from the
+// vault key. It lets the undo journal tell whether a secret changed
+// without storing a plain hash that could be checked against guesses.
+func (v *SecretsVault) MAC(data []byte) (string, error) {
+	if !v.unlocked {
+		return "", fmt.Errorf("vault is locked")
+	}
+	derive := hmac.New(sha256.New, v.key)
+	derive.Write([]byte("gw undo digest"))
+	mac := hmac.New(sha256.New, derive.Sum(nil))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
 // Count returns the number of secrets in the vault.
 func (v *SecretsVault) Count() int {
 	return len(v.data.Secrets)
@@ -0,0 +1,207 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVaultSealAndRestore(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	v, err := Create("test-password-123")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := v.Set("API_KEY", "sk-test"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := v.RecordDeployment("API_KEY", "worker:api"); err != nil {
+		t.Fatalf("RecordDeployment: %v", err)
+	}
+
+	entry, ok := v.Entry("API_KEY")
+	if !ok || entry.Value != "sk-test" {
+		t.Fatalf("Entry = %+v, %v", entry, ok)
+	}
+	entry.DeployedTo["mutated"] = "x"
+	if again, _ := v.Entry("API_KEY"); len(again.DeployedTo) != 1 {
+		t.Error("Entry should return a copy")
+	}
+
+	sealed, err := v.Seal([]byte("sk-test"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if sealed == "sk-test" {
+		t.Fatal("Seal returned plaintext")
+	}
+	plain, err := v.Unseal(sealed)
+	if err != nil || string(plain) != "sk-test" {
+		t.Fatalf("Unseal = %q, %v", plain, err)
+	}
+
+	if err := v.Delete("API_KEY"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	delete(entry.DeployedTo, "mutated")
+	if err := v.Restore("API_KEY", entry); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	reopened, err := Unlock("test-password-123")
+	if err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	got, ok := reopened.Entry("API_KEY")
+	if !ok || got.Value != "sk-test" || got.DeployedTo["worker:api"] == "" || got.CreatedAt != entry.CreatedAt {
+		t.Errorf("restored entry = %+v", got)
+	}
+}
+
+func TestVaultEnvironments(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	v, err := Create("test-password-123")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := v.Set("STRIPE_KEY", "sk-default"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := v.SetEnv("STRIPE_KEY", "production", "sk-live"); err != nil {
+		t.Fatalf("SetEnv: %v", err)
+	}
+	if err := v.SetEnv("STAGING_ONLY", "staging", "x"); err != nil {
+		t.Fatalf("SetEnv: %v", err)
+	}
+	if err := v.SetEnv("STRIPE_KEY", "bad env", "x"); err == nil {
+		t.Error("SetEnv accepted an invalid environment")
+	}
+
+	tests := []struct {
+		name, env, value, from string
+		ok                     bool
+	}{
+		{"STRIPE_KEY", "production", "sk-live", "production", true},
+		{"STRIPE_KEY", "staging", "sk-default", DefaultEnv, true},
+		{"STRIPE_KEY", "", "sk-default", DefaultEnv, true},
+		{"STAGING_ONLY", "staging", "x", "staging", true},
+		{"STAGING_ONLY", "production", "", "", false},
+	}
+	for _, tt := range tests {
+		value, from, ok := v.Resolve(tt.name, tt.env)
+		if value != tt.value || from != tt.from || ok != tt.ok {
+			t.Errorf("Resolve(%s, %q) = %q, %q, %v", tt.name, tt.env, value, from, ok)
+		}
+	}
+	if got := v.Envs(); len(got) != 3 || got[0] != DefaultEnv || got[1] != "production" || got[2] != "staging" {
+		t.Errorf("Envs = %v", got)
+	}
+
+	if err := v.DeleteEnv("STAGING_ONLY", "staging"); err != nil {
+		t.Fatalf("DeleteEnv: %v", err)
+	}
+	if v.Exists("STAGING_ONLY") {
+		t.Error("deleting a secret's last value should remove it")
+	}
+}
+
+func TestVaultMigratesVersion1(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	password := "test-password-123"
+	salt, err := generateSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"secrets":{"API_KEY":{"value":"sk-test","created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z","deployed_to":{"grove-api":"2025-01-02T00:00:00Z"}}}}`
+	token, err := fernetEncrypt(deriveKey(password, salt), []byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := append(append([]byte{legacyVaultFileVersion}, salt...), token...)
+	if err := os.MkdirAll(filepath.Dir(DefaultVaultPath()), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(DefaultVaultPath(), file, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := Unlock(password)
+	if err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if !v.Migrated() {
+		t.Error("a version 1 vault should report being migrated")
+	}
+	entry, ok := v.Entry("API_KEY")
+	if !ok || entry.Value != "sk-test" || entry.DeployedTo["grove-api"] == "" {
+		t.Errorf("migrated entry = %+v", entry)
+	}
+
+	raw, err := os.ReadFile(DefaultVaultPath())
+	if err != nil || raw[0] != vaultFileVersion {
+		t.Fatalf("vault not rewritten in the current format: %v", err)
+	}
+	if _, err := os.Stat(DefaultVaultPath() + ".v1.bak"); err != nil {
+		t.Errorf("version 1 backup missing: %v", err)
+	}
+	reopened, err := Unlock(password)
+	if err != nil || reopened.Migrated() {
+		t.Fatalf("reopening the migrated vault: %v", err)
+	}
+	if value, _ := reopened.Get("API_KEY"); value != "sk-test" {
+		t.Errorf("Get after migration = %q", value)
+	}
+}
+
+func TestVaultRekey(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	v, err := Create("old-password-123")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := v.Set("API_KEY", "sk-test"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := v.SetIdentity("GW-SECRET-KEY-1-test"); err != nil {
+		t.Fatalf("SetIdentity: %v", err)
+	}
+	before, err := os.ReadFile(DefaultVaultPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.Rekey("short"); err == nil {
+		t.Error("Rekey should reject a short password")
+	}
+	backup, err := v.Rekey("new-password-456")
+	if err != nil {
+		t.Fatalf("Rekey: %v", err)
+	}
+
+	saved, err := os.ReadFile(backup)
+	if err != nil || string(saved) != string(before) {
+		t.Errorf("backup should hold the old vault file: %v", err)
+	}
+	if _, err := Unlock("old-password-123"); err == nil {
+		t.Error("the old password should no longer unlock the vault")
+	}
+	reopened, err := Unlock("new-password-456")
+	if err != nil {
+		t.Fatalf("Unlock with new password: %v", err)
+	}
+	if value, _ := reopened.Get("API_KEY"); value != "sk-test" || reopened.Identity() != "GW-SECRET-KEY-1-test" {
+		t.Errorf("rekeyed vault lost data: %q, %q", value, reopened.Identity())
+	}
+
+	// The vault keeps working under the new key.
+	if err := v.Set("OTHER", "x"); err != nil {
+		t.Fatalf("Set after Rekey: %v", err)
+	}
+	if _, err := Unlock("new-password-456"); err != nil {
+		t.Errorf("Unlock after a save following Rekey: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(DefaultVaultPath()))
+	if len(entries) != 2 {
+		t.Errorf("vault directory should hold the vault and one backup, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,309 @@
+// Package vaultshare encrypts a chosen set of vault secrets to teammates'
+// X25519 public keys, so secrets can be handed over without their values
+// ever crossing chat or email in plaintext.
+//
+// Every vault can hold an identity: an X25519 private key, kept encrypted
+// inside the vault, whose public half ("gwpub1…") is safe to post
+// anywhere. A bundle is sealed with a random payload key under
+// ChaCha20-Poly1305, and that key is wrapped once per recipient with a key
+// derived by HKDF-SHA256 from an ephemeral X25519 exchange. Only holders of
+// a recipient's identity can open it.
+//
+// Bundles are not signed: anyone holding a recipient's public key can make
+// one, so the sender they name is a claim, not a proof.
+package vaultshare
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/vault"
+)
+
+const (
+	// PublicKeyPrefix starts an encoded public key.
+	PublicKeyPrefix = "gwpub1"
+
+	// identityPrefix starts an encoded private key.
+	identityPrefix = "GW-SECRET-KEY-1-"
+
+	// bundleVersion is the bundle format version.
+	bundleVersion = 1
+
+	// wrapInfo separates the keys this format derives from any other use
+	// of the same exchange.
+	wrapInfo = "gw secret share v1"
+
+	armorBegin = "-----BEGIN GW SECRET BUNDLE-----"
+	armorEnd   = "-----END GW SECRET BUNDLE-----"
+)
+
+// ErrNotRecipient is returned by Open when the bundle was not sealed to
+// the identity.
+var ErrNotRecipient = errors.New("bundle is not addressed to this vault's key")
+
+var b64 = base64.RawURLEncoding
+
+// Identity is an X25519 key pair bundles are opened with.
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// GenerateIdentity makes a new random identity.
+func GenerateIdentity() (*Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// ParseIdentity decodes an identity written by Identity.String.
+func ParseIdentity(s string) (*Identity, error) {
+	raw, err := b64.DecodeString(strings.TrimPrefix(s, identityPrefix))
+	if err != nil || !strings.HasPrefix(s, identityPrefix) {
+		return nil, fmt.Errorf("invalid sharing identity")
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sharing identity: %w", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// String encodes the private key. It is secret.
+func (id *Identity) String() string {
+	return identityPrefix + b64.EncodeToString(id.key.Bytes())
+}
+
+// PublicKey encodes the identity's public key for others to share to.
+func (id *Identity) PublicKey() string {
+	return PublicKeyPrefix + b64.EncodeToString(id.key.PublicKey().Bytes())
+}
+
+// ParsePublicKey decodes a public key written by Identity.PublicKey.
+func ParsePublicKey(s string) (*ecdh.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	raw, err := b64.DecodeString(strings.TrimPrefix(s, PublicKeyPrefix))
+	if err != nil || !strings.HasPrefix(s, PublicKeyPrefix) {
+		return nil, fmt.Errorf("invalid public key %q: expected %s…", s, PublicKeyPrefix)
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %w", s, err)
+	}
+	return key, nil
+}
+
+// Contents is what a bundle carries.
+type Contents struct {
+	From    string                  `json:"from,omitempty"`
+	Created time.Time               `json:"created"`
+	Secrets map[string]vault.Secret `json:"secrets"`
+}
+
+// bundle is the sealed form, before armoring.
+type bundle struct {
+	Version    int      `json:"version"`
+	Recipients []stanza `json:"recipients"`
+	Nonce      string   `json:"nonce"`
+	Payload    string   `json:"payload"`
+}
+
+// stanza wraps the payload key for one recipient.
+type stanza struct {
+	Ephemeral string `json:"epk"`
+	Key       string `json:"key"`
+}
+
+// Seal encrypts contents to every recipient and returns the armored
+// bundle, plain text safe to paste anywhere.
+func Seal(contents Contents, recipients []*ecdh.PublicKey) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients")
+	}
+	plaintext, err := json.Marshal(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	fileKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+
+	b := bundle{Version: bundleVersion}
+	for _, r := range recipients {
+		eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := eph.ECDH(r)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := wrapAEAD(shared, eph.PublicKey().Bytes(), r.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		wrapped := aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil)
+		b.Recipients = append(b.Recipients, stanza{
+			Ephemeral: b64.EncodeToString(eph.PublicKey().Bytes()),
+			Key:       b64.EncodeToString(wrapped),
+		})
+	}
+
+	aad, err := json.Marshal(b.Recipients)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	b.Nonce = b64.EncodeToString(nonce)
+	b.Payload = b64.EncodeToString(aead.Seal(nil, nonce, plaintext, aad))
+
+	sealed, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return armor(sealed), nil
+}
+
+// Open decrypts an armored bundle with id. It returns ErrNotRecipient when
+// none of the bundle's recipients is id.
+func Open(armored []byte, id *Identity) (*Contents, error) {
+	sealed, err := dearmor(armored)
+	if err != nil {
+		return nil, err
+	}
+	var b bundle
+	if err := json.Unmarshal(sealed, &b); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if b.Version != bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+
+	var fileKey []byte
+	for _, s := range b.Recipients {
+		raw, err := b64.DecodeString(s.Ephemeral)
+		if err != nil {
+			continue
+		}
+		eph, err := ecdh.X25519().NewPublicKey(raw)
+		if err != nil {
+			continue
+		}
+		wrapped, err := b64.DecodeString(s.Key)
+		if err != nil {
+			continue
+		}
+		shared, err := id.key.ECDH(eph)
+		if err != nil {
+			continue
+		}
+		aead, err := wrapAEAD(shared, raw, id.key.PublicKey().Bytes())
+		if err != nil {
+			continue
+		}
+		if key, err := aead.Open(nil, make([]byte, aead.NonceSize()), wrapped, nil); err == nil {
+			fileKey = key
+			break
+		}
+	}
+	if fileKey == nil {
+		return nil, ErrNotRecipient
+	}
+
+	aad, err := json.Marshal(b.Recipients)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := b64.DecodeString(b.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle nonce")
+	}
+	payload, err := b64.DecodeString(b.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle payload")
+	}
+	aead, err := chacha20poly1305.New(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid bundle nonce")
+	}
+	plaintext, err := aead.Open(nil, nonce, payload, aad)
+	if err != nil {
+		return nil, fmt.Errorf("bundle is corrupted or was tampered with")
+	}
+
+	var contents Contents
+	if err := json.Unmarshal(plaintext, &contents); err != nil {
+		return nil, fmt.Errorf("invalid bundle contents: %w", err)
+	}
+	return &contents, nil
+}
+
+// wrapAEAD derives the cipher that wraps the payload key for one
+// recipient from their shared X25519 secret. The ephemeral and recipient
+// public keys are the HKDF salt, binding the wrapped key to both.
+func wrapAEAD(shared, eph, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, eph...), recipient...)
+	key, err := hkdf.Key(sha256.New, shared, salt, wrapInfo, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.New(key)
+}
+
+// armor wraps a sealed bundle in base64 lines between BEGIN and END
+// markers.
+func armor(sealed []byte) []byte {
+	enc := base64.StdEncoding.EncodeToString(sealed)
+	var buf bytes.Buffer
+	buf.WriteString(armorBegin + "\n")
+	for len(enc) > 64 {
+		buf.WriteString(enc[:64] + "\n")
+		enc = enc[64:]
+	}
+	buf.WriteString(enc + "\n")
+	buf.WriteString(armorEnd + "\n")
+	return buf.Bytes()
+}
+
+// dearmor reverses armor, ignoring surrounding text and whitespace, as a
+// bundle pasted from chat often carries.
+func dearmor(armored []byte) ([]byte, error) {
+	s := string(armored)
+	begin := strings.Index(s, armorBegin)
+	end := strings.Index(s, armorEnd)
+	if begin < 0 || end < begin {
+		return nil, fmt.Errorf("not a gw secret bundle")
+	}
+	body := strings.Join(strings.Fields(s[begin+len(armorBegin):end]), "")
+	sealed, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	return sealed, nil
+}
@@ -0,0 +1,117 @@
+package vaultshare
+
+import (
+	"crypto/ecdh"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AutumnsGrove/Lattice/tools/grove-wrap-go/internal/vault"
+)
+
+func TestIdentityRoundtrip(t *testing.T) {
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseIdentity(id.String())
+	if err != nil {
+		t.Fatalf("ParseIdentity: %v", err)
+	}
+	if again.PublicKey() != id.PublicKey() {
+		t.Error("parsed identity has a different public key")
+	}
+	if !strings.HasPrefix(id.PublicKey(), PublicKeyPrefix) {
+		t.Errorf("PublicKey = %q", id.PublicKey())
+	}
+	if _, err := ParsePublicKey(" " + id.PublicKey() + "\n"); err != nil {
+		t.Errorf("ParsePublicKey: %v", err)
+	}
+	for _, bad := range []string{"", "gwpub1", "gwpub1!!!", id.String(), strings.TrimPrefix(id.PublicKey(), PublicKeyPrefix)} {
+		if _, err := ParsePublicKey(bad); err == nil {
+			t.Errorf("ParsePublicKey(%q) should fail", bad)
+		}
+	}
+	if _, err := ParseIdentity(id.PublicKey()); err == nil {
+		t.Error("ParseIdentity should reject a public key")
+	}
+}
+
+func TestSealAndOpen(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	bob, _ := GenerateIdentity()
+	eve, _ := GenerateIdentity()
+	var recipients []*ecdh.PublicKey
+	for _, id := range []*Identity{alice, bob} {
+		pub, err := ParsePublicKey(id.PublicKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		recipients = append(recipients, pub)
+	}
+
+	contents := Contents{
+		From:    "autumn@grove.place",
+		Created: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		Secrets: map[string]vault.Secret{
+			"API_KEY": {
+				vault.DefaultEnv: {Value: "sk-default"},
+				"production":     {Value: "sk-prod"},
+			},
+		},
+	}
+	armored, err := Seal(contents, recipients)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if strings.Contains(string(armored), "sk-") {
+		t.Fatal("bundle contains plaintext")
+	}
+
+	// Pasted from chat: surrounding text and rewrapped lines.
+	pasted := "here you go:\n  " + strings.ReplaceAll(string(armored), "\n", "\n  ") + "\nthanks"
+	for _, id := range []*Identity{alice, bob} {
+		got, err := Open([]byte(pasted), id)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if got.From != contents.From || got.Secrets["API_KEY"]["production"].Value != "sk-prod" {
+			t.Errorf("Open = %+v", got)
+		}
+	}
+
+	if _, err := Open(armored, eve); !errors.Is(err, ErrNotRecipient) {
+		t.Errorf("Open with another key = %v, want ErrNotRecipient", err)
+	}
+	if _, err := Open([]byte("not a bundle"), alice); err == nil {
+		t.Error("Open should reject text without a bundle")
+	}
+	if _, err := Seal(contents, nil); err == nil {
+		t.Error("Seal should require a recipient")
+	}
+}
+
+func TestOpenRejectsTampering(t *testing.T) {
+	id, _ := GenerateIdentity()
+	pub, _ := ParsePublicKey(id.PublicKey())
+	armored, err := Seal(Contents{Secrets: map[string]vault.Secret{"K": {vault.DefaultEnv: {Value: "v"}}}}, []*ecdh.PublicKey{pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := dearmor(armored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flip a bit inside the payload ciphertext.
+	i := strings.Index(string(sealed), `"payload":"`) + len(`"payload":"`) + 4
+	tampered := append([]byte{}, sealed...)
+	if tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	if _, err := Open(armor(tampered), id); err == nil || errors.Is(err, ErrNotRecipient) {
+		t.Errorf("Open of a tampered bundle = %v, want a tampering error", err)
+	}
+}